@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleAttach(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	subject, _ := args.FirstAny("subject", "ref")
+	subject = strings.TrimSpace(subject)
+	if subject == "" && len(positionals) > 0 {
+		subject = positionals[0]
+		positionals = positionals[1:]
+	}
+	if subject == "" {
+		return fmt.Errorf("subject reference required")
+	}
+
+	files := append(cleanedValues(args.All("file")), positionals...)
+	if len(files) == 0 {
+		return fmt.Errorf("at least one file required")
+	}
+
+	opts := porter.AttachOptions{}
+	if artifactType, ok := args.FirstAny("artifact-type", "type"); ok {
+		opts.ArtifactType = strings.TrimSpace(artifactType)
+	}
+	if opts.ArtifactType == "" {
+		return fmt.Errorf("--artifact-type is required")
+	}
+	if val, ok := args.Bool("insecure"); ok {
+		opts.Insecure = val
+	}
+
+	annotations, err := parseAnnotations(cleanedValues(args.All("annotation")))
+	if err != nil {
+		return err
+	}
+	opts.Annotations = annotations
+	logger.Debug("Resolved attach options", "subject", subject, "artifact_type", opts.ArtifactType, "files", files, "insecure", opts.Insecure)
+
+	result, err := client.AttachArtifact(ctx, subject, files, opts)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attach result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write attach result: %w", err)
+	}
+	return nil
+}
+
+// parseAnnotations converts key=value pairs into an annotation map.
+func parseAnnotations(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	annotations := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", value)
+		}
+		annotations[key] = val
+	}
+	return annotations, nil
+}
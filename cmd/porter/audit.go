@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+// auditsOperation reports whether operation writes or transfers content and is
+// therefore simulated under --audit-only. Read-only operations run unchanged.
+func auditsOperation(operation string, args types.PluginArgs) bool {
+	switch operation {
+	case "pull", "push", "lock", "attach", "imagify":
+		return true
+	case "versions":
+		use, _ := args.FirstAny("use")
+		return strings.TrimSpace(use) != ""
+	case "verify":
+		repair, _ := args.Bool("repair")
+		return repair
+	case "channel":
+		positionals := cleanedValues(args.Positionals())
+		return len(positionals) > 0 && positionals[0] == "set"
+	}
+	return false
+}
+
+// handleAuditOnly evaluates operation's policies, verification, and planned file
+// changes without transferring or writing anything, and prints the simulated audit
+// record.
+func handleAuditOnly(ctx context.Context, client *porter.Client, operation string, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	var record *porter.AuditRecord
+	var err error
+	switch operation {
+	case "pull":
+		record, err = auditPull(ctx, client, args)
+	case "push":
+		record, err = auditPush(client, args)
+	case "lock":
+		record, err = auditLock(ctx, client, args)
+	case "attach":
+		record, err = auditAttach(args)
+	case "imagify":
+		record, err = auditImagify(ctx, client, args)
+	case "versions":
+		record, err = auditVersions(args)
+	case "verify":
+		record, err = auditVerify(ctx, client, args)
+	case "channel":
+		record, err = auditChannel(ctx, client, args)
+	default:
+		return fmt.Errorf("operation %s cannot be audited", operation)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Simulated operation", "operation", record.Operation, "reference", record.Reference, "allowed", record.Allowed, "changes", len(record.Changes))
+	output, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+func auditPull(ctx context.Context, client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	ref, _ := args.FirstAny("ref", "artifact", "arg0")
+	ref = strings.TrimSpace(ref)
+	familyRef, family := familyReference(args)
+	if family {
+		ref = familyRef
+	}
+	if ref == "" {
+		return nil, fmt.Errorf("artifact reference required")
+	}
+	insecure, _ := args.Bool("insecure")
+	output, _ := args.FirstAny("output", "o")
+	output = strings.TrimSpace(output)
+
+	allPlatforms, _ := args.BoolAny("all-arch")
+	platformSelections := cleanedValues(args.All("platform"))
+	platformSelections = append(platformSelections, cleanedValues(args.All("platforms"))...)
+	if allPlatforms && len(platformSelections) > 0 {
+		return nil, fmt.Errorf("--all-arch cannot be combined with --platform")
+	}
+	exportOpts, err := exportOptionsFromArgs(args, allPlatforms, platformSelections)
+	if err != nil {
+		return nil, err
+	}
+
+	if family {
+		return client.AuditPullFamily(ctx, ref, insecure, output, exportOpts)
+	}
+	if ref, err = channelReference(ctx, client, args, ref, insecure); err != nil {
+		return nil, err
+	}
+	record, err := client.AuditPull(ctx, ref, insecure, output, exportOpts)
+	if err != nil {
+		return nil, err
+	}
+	if locked, _ := args.Bool("locked"); locked {
+		lock, err := porter.LoadLockfile(lockfilePath(args))
+		if err == nil {
+			err = lock.Verify(ref, record.Digest)
+		}
+		record.Check("lockfile", err)
+	}
+	return record, nil
+}
+
+func auditPush(client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	insecure, _ := args.Bool("insecure")
+	positionals := cleanedValues(args.Positionals())
+
+	if source, ok := args.First("from-cache"); ok {
+		if len(positionals) < 1 {
+			return nil, fmt.Errorf("registry reference required")
+		}
+		record := porter.NewAuditRecord("push", positionals[0])
+		record.Note("source", porter.AuditPass, "cached artifact "+strings.TrimSpace(source))
+		record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: positionals[0]})
+		return record, nil
+	}
+
+	if familyPath, ok := args.First("family-index"); ok {
+		if len(positionals) < 1 {
+			return nil, fmt.Errorf("registry reference required")
+		}
+		record := porter.NewAuditRecord("push", positionals[0])
+		_, err := os.Stat(strings.TrimSpace(familyPath))
+		record.Check("family-index", err)
+		record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: positionals[0]})
+		return record, nil
+	}
+
+	if workspacePath, ok := args.First("workspace"); ok {
+		workspacePath = strings.TrimSpace(workspacePath)
+		record := porter.NewAuditRecord("push", workspacePath)
+		workspace, err := porter.LoadWorkspace(workspacePath)
+		record.Check("workspace", err)
+		if err != nil {
+			return record, nil
+		}
+		for _, artifact := range workspace.Artifacts {
+			_, err := os.Stat(artifact.Manifest)
+			record.Check("manifest "+artifact.Name, err)
+			record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: artifact.Reference})
+		}
+		return record, nil
+	}
+
+	if manifestPath, _ := args.FirstAny("manifest", "m"); strings.TrimSpace(manifestPath) != "" {
+		if len(positionals) < 1 {
+			return nil, fmt.Errorf("registry reference required")
+		}
+		record := porter.NewAuditRecord("push", positionals[0])
+		_, err := os.Stat(strings.TrimSpace(manifestPath))
+		record.Check("manifest", err)
+		record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: positionals[0]})
+		return record, nil
+	}
+
+	if len(positionals) < 2 {
+		return nil, fmt.Errorf("artifact path and reference required")
+	}
+	compression, _ := args.First("compression")
+	return client.AuditPush(positionals[0], positionals[1], porter.PushOptions{
+		Insecure:    insecure,
+		Sign:        buildSignConfig(args),
+		SBOMs:       cleanedValues(args.All("sbom")),
+		Compression: strings.ToLower(strings.TrimSpace(compression)),
+	})
+}
+
+func auditChannel(ctx context.Context, client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	channel, ref, err := channelSetArgs(args, cleanedValues(args.Positionals())[1:])
+	if err != nil {
+		return nil, err
+	}
+	insecure, _ := args.Bool("insecure")
+
+	record := porter.NewAuditRecord("channel", ref)
+	digest, err := client.ResolveDigest(ctx, ref, insecure)
+	record.Check("resolve", err)
+	channels, err := client.Channels(ctx, ref, insecure)
+	record.Check("channel_pointer", err)
+	if err != nil {
+		return record, nil
+	}
+	if current, ok := channels.Channels[channel]; ok {
+		record.Note("channel", porter.AuditPass, fmt.Sprintf("%s moves from %s to %s", channel, current.Digest, digest))
+	} else {
+		record.Note("channel", porter.AuditPass, fmt.Sprintf("%s is created at %s", channel, digest))
+	}
+	record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: channels.Repository + ":" + porter.ChannelsTag})
+	return record, nil
+}
+
+func auditLock(ctx context.Context, client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	lockPath := lockfilePath(args)
+	lock, err := porter.LoadLockfile(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	refs := cleanedValues(args.Positionals())
+	if len(refs) == 0 {
+		refs = lock.References()
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("artifact reference required")
+	}
+	insecure, _ := args.Bool("insecure")
+
+	record := porter.NewAuditRecord("lock", strings.Join(refs, ","))
+	for _, ref := range refs {
+		digest, err := client.ResolveDigest(ctx, ref, insecure)
+		if err != nil {
+			record.Check("resolve", err)
+			continue
+		}
+		record.Note("resolve", porter.AuditPass, ref+" -> "+digest)
+	}
+
+	action := porter.ChangeCreate
+	if _, err := os.Stat(lockPath); err == nil {
+		action = porter.ChangeOverwrite
+	}
+	record.Plan(porter.PlannedChange{Action: action, Path: lockPath})
+	return record, nil
+}
+
+func auditAttach(args types.PluginArgs) (*porter.AuditRecord, error) {
+	positionals := cleanedValues(args.Positionals())
+	subject, _ := args.FirstAny("subject", "ref")
+	subject = strings.TrimSpace(subject)
+	if subject == "" && len(positionals) > 0 {
+		subject = positionals[0]
+		positionals = positionals[1:]
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("subject reference required")
+	}
+
+	record := porter.NewAuditRecord("attach", subject)
+	for _, file := range append(cleanedValues(args.All("file")), positionals...) {
+		info, err := os.Stat(file)
+		record.Check("file", err)
+		if err == nil {
+			record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: file, Size: info.Size()})
+		}
+	}
+	return record, nil
+}
+
+func auditImagify(ctx context.Context, client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			ref = positionals[0]
+		}
+	}
+	target, _ := args.FirstAny("tag", "t")
+	target = strings.TrimSpace(target)
+	if ref == "" || target == "" {
+		return nil, fmt.Errorf("artifact reference and target image reference (--tag) required")
+	}
+	insecure, _ := args.Bool("insecure")
+
+	record, err := client.AuditPull(ctx, ref, insecure, "", porter.ExportOptions{})
+	if err != nil {
+		return nil, err
+	}
+	record.Operation = "imagify"
+	record.Plan(porter.PlannedChange{Action: porter.ChangeUpload, Path: target})
+	return record, nil
+}
+
+func auditVersions(args types.PluginArgs) (*porter.AuditRecord, error) {
+	destination, _ := args.FirstAny("dir", "output", "o")
+	destination = strings.TrimSpace(destination)
+	if destination == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			destination = positionals[0]
+		}
+	}
+	if destination == "" {
+		return nil, fmt.Errorf("versioned destination required")
+	}
+	use, _ := args.FirstAny("use")
+	use = strings.TrimSpace(use)
+
+	record := porter.NewAuditRecord("versions", "")
+	versions, err := porter.ListVersions(destination)
+	if err == nil {
+		err = fmt.Errorf("version %s is not installed in %s", use, destination)
+		for _, version := range versions {
+			if version.Version == use {
+				err = nil
+			}
+		}
+	}
+	record.Check("version", err)
+	record.Plan(porter.PlannedChange{Action: porter.ChangeLink, Path: filepath.Join(destination, porter.CurrentVersionLink), Target: use})
+	return record, nil
+}
+
+func auditVerify(ctx context.Context, client *porter.Client, args types.PluginArgs) (*porter.AuditRecord, error) {
+	target, _ := args.FirstAny("id", "ref")
+	target = strings.TrimSpace(target)
+	if target == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			target = positionals[0]
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("cached artifact ID or reference required")
+	}
+
+	report, err := client.VerifyCachedArtifact(ctx, target, porter.VerifyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	record := porter.NewAuditRecord("verify", report.Reference)
+	record.Digest = report.Digest
+	if report.Valid {
+		record.Check("integrity", nil)
+		return record, nil
+	}
+	record.Note("integrity", porter.AuditFail, strings.Join(report.Errors, "; "))
+	for _, blob := range report.Blobs {
+		switch blob.Status {
+		case porter.BlobStatusMissing:
+			record.Plan(porter.PlannedChange{Action: porter.ChangeCreate, Path: report.Path, Digest: blob.Digest, Size: blob.Size})
+		case porter.BlobStatusCorrupt:
+			record.Plan(porter.PlannedChange{Action: porter.ChangeOverwrite, Path: report.Path, Digest: blob.Digest, Size: blob.Size})
+		}
+	}
+	return record, nil
+}
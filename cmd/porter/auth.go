@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleAuth(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		printUsage(stdout, "auth")
+		return nil
+	}
+
+	switch positionals[0] {
+	case "check":
+		return handleAuthCheck(ctx, client, args, positionals[1:], logger, stdout)
+	default:
+		return fmt.Errorf("unknown auth subcommand: %s", positionals[0])
+	}
+}
+
+func handleAuthCheck(ctx context.Context, client *porter.Client, args types.PluginArgs, positionals []string, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" && len(positionals) > 0 {
+		ref = positionals[0]
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	opts := porter.AuthCheckOptions{}
+	if val, ok := args.Bool("insecure"); ok {
+		opts.Insecure = val
+	}
+	if val, ok := args.Bool("push"); ok {
+		opts.Push = val
+	}
+	logger.Debug("Resolved auth check options", "ref", ref, "insecure", opts.Insecure, "push", opts.Push)
+
+	report, err := client.CheckAuth(ctx, ref, opts)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth report: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write auth report: %w", err)
+	}
+	return nil
+}
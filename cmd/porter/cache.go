@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleCache(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		printUsage(stdout, "cache")
+		return nil
+	}
+
+	var result interface{}
+	switch positionals[0] {
+	case "stats":
+		format, err := outputFormat(args)
+		if err != nil {
+			return err
+		}
+		stats, err := client.CacheStats()
+		if err != nil {
+			return err
+		}
+		return writeFormatted(stdout, format, "cache statistics", stats, cacheStatsTable(stats))
+	case "rm":
+		target, _ := args.FirstAny("id", "ref")
+		target = strings.TrimSpace(target)
+		if target == "" && len(positionals) > 1 {
+			target = positionals[1]
+		}
+		if target == "" {
+			return fmt.Errorf("cached artifact ID, reference or digest required")
+		}
+		logger.Debug("Resolved cache rm options", "target", target)
+		var err error
+		if result, err = client.RemoveCachedArtifact(target); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown cache subcommand: %s", positionals[0])
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write cache result: %w", err)
+	}
+	return nil
+}
+
+// cacheStatsTable lists the cache statistics one per row, a row per registry;
+// quiet output prints the bytes the cache uses on disk.
+func cacheStatsTable(stats *porter.CacheStats) func() table {
+	return func() table {
+		t := table{header: []string{"STAT", "VALUE"}}
+		t.rows = append(t.rows,
+			[]string{"artifacts", strconv.Itoa(stats.Artifacts)},
+			[]string{"blobs", strconv.Itoa(stats.Blobs)},
+			[]string{"disk usage", formatSize(stats.DiskBytes)},
+			[]string{"artifact size", formatSize(stats.ArtifactBytes)},
+			[]string{"dedup savings", formatSize(stats.DedupSavedBytes)},
+		)
+		if stats.HeadroomBytes != nil {
+			t.rows = append(t.rows,
+				[]string{"size limit", formatSize(stats.MaxBytes)},
+				[]string{"headroom", formatSize(*stats.HeadroomBytes)},
+			)
+		}
+		for _, registry := range stats.Registries {
+			t.rows = append(t.rows, []string{"registry " + registry.Registry, fmt.Sprintf("%d artifacts, %s", registry.Artifacts, formatSize(registry.Bytes))})
+		}
+		for _, entry := range []struct {
+			name  string
+			entry *porter.CacheEntry
+		}{{"oldest", stats.Oldest}, {"newest", stats.Newest}} {
+			if entry.entry != nil {
+				t.rows = append(t.rows, []string{entry.name, fmt.Sprintf("%s %s (%s)", entry.entry.ID, entry.entry.Reference, entry.entry.CachedAt.Local().Format(time.DateTime))})
+			}
+		}
+		t.quiet = []string{strconv.FormatInt(stats.DiskBytes, 10)}
+		return t
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleCat(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if positionals := cleanedValues(args.Positionals()); ref == "" && len(positionals) > 0 {
+		ref = positionals[0]
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	opts := porter.CatOptions{Layer: -1}
+	opts.Insecure, _ = args.Bool("insecure")
+	opts.Config, _ = args.Bool("config")
+	if val, ok := args.First("digest"); ok {
+		opts.Digest = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("layer"); ok {
+		layer, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || layer < 0 {
+			return fmt.Errorf("invalid --layer %q: expected a non-negative number", val)
+		}
+		opts.Layer = layer
+	}
+	for _, selection := range cleanedValues(args.All("platform")) {
+		platform, err := parsePlatformSelection(selection)
+		if err != nil {
+			return err
+		}
+		opts.Platforms = append(opts.Platforms, platform)
+	}
+	logger.Debug("Resolved cat options", "ref", ref, "layer", opts.Layer, "digest", opts.Digest, "config", opts.Config)
+
+	// The blob is buffered and verified first, so a corrupt or oversized blob
+	// never reaches the pipeline.
+	buf := &limitedBuffer{limit: stdoutLimit}
+	blob, err := client.CatBlob(ctx, ref, buf, opts)
+	if err != nil {
+		return err
+	}
+	// DS carries plugin output as text.
+	if !utf8.Valid(buf.Bytes()) {
+		return fmt.Errorf("blob %s is binary, and DS relays plugin output as text; pull and export it instead", blob.Digest)
+	}
+	if _, err := stdout.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write blob to stdout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleChannel(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		printUsage(stdout, "channel")
+		return nil
+	}
+	insecure, _ := args.Bool("insecure")
+
+	var result interface{}
+	switch positionals[0] {
+	case "set":
+		channel, ref, err := channelSetArgs(args, positionals[1:])
+		if err != nil {
+			return err
+		}
+		logger.Debug("Resolved channel options", "channel", channel, "ref", ref, "insecure", insecure)
+		if result, err = client.SetChannel(ctx, channel, ref, insecure); err != nil {
+			return err
+		}
+	case "list":
+		ref, _ := args.FirstAny("ref")
+		ref = strings.TrimSpace(ref)
+		if ref == "" && len(positionals) > 1 {
+			ref = positionals[1]
+		}
+		if ref == "" {
+			return fmt.Errorf("repository reference required")
+		}
+		var err error
+		if result, err = client.Channels(ctx, ref, insecure); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown channel subcommand: %s", positionals[0])
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write channel result: %w", err)
+	}
+	return nil
+}
+
+// channelSetArgs returns the channel and target reference of `channel set`.
+func channelSetArgs(args types.PluginArgs, positionals []string) (string, string, error) {
+	if len(positionals) == 0 {
+		return "", "", fmt.Errorf("channel name required")
+	}
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" && len(positionals) > 1 {
+		ref = positionals[1]
+	}
+	if ref == "" {
+		return "", "", fmt.Errorf("--ref is required")
+	}
+	return positionals[0], ref, nil
+}
+
+// channelReference resolves ref through the --channel flag, returning ref unchanged
+// when the flag is absent.
+func channelReference(ctx context.Context, client *porter.Client, args types.PluginArgs, ref string, insecure bool) (string, error) {
+	channel, ok := args.First("channel")
+	if !ok {
+		return ref, nil
+	}
+	return client.ResolveChannel(ctx, ref, channel, insecure)
+}
@@ -0,0 +1,969 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+	"github.com/spf13/pflag"
+)
+
+// command describes an operation of the plugin: the flags it accepts, its help
+// text, and how it runs. The plugin manifest, the help overview and the usage of
+// every command are generated from these descriptions.
+type command struct {
+	name string
+	// summary describes the command in the plugin manifest and the help overview.
+	summary string
+	// synopsis is the command line the help overview lists, such as "pull <artifact>".
+	synopsis string
+	// usage lists the command lines of the usage, without the "Usage:" prefix.
+	usage []string
+	// description precedes the flags in the usage and notes follow them.
+	description []string
+	notes       []string
+	examples    []string
+	// maxArgs is the number of positional arguments accepted, or -1 for any.
+	maxArgs int
+	// completes is what shell completion offers for each positional argument:
+	// a completion kind such as "ids" or "refs", or a space-separated list of
+	// words. The last entry applies to any later argument.
+	completes []string
+	// audits marks commands --audit-only simulates.
+	audits bool
+	// hidden keeps the command out of the manifest and the help overview.
+	hidden bool
+	flags  func(fs *pflag.FlagSet)
+	run    func(e *execution) error
+}
+
+// execution is the state of one Execute call a command runs with.
+type execution struct {
+	client     *porter.Client
+	args       types.PluginArgs
+	logger     hclog.Logger
+	build      porter.BuildInfo
+	stdout     *bytes.Buffer
+	stderr     *bytes.Buffer
+	finalizers []types.FinalizerRequest
+	// ctx is the context of the Execute call; cancelling it stops transfers.
+	ctx context.Context
+}
+
+// handler adapts a handleX function to a command.
+func handler(handle func(*porter.Client, types.PluginArgs, hclog.Logger, io.Writer) error) func(e *execution) error {
+	return func(e *execution) error {
+		return handle(e.client, e.args, e.logger, e.stdout)
+	}
+}
+
+// ctxHandler adapts a handleX function that talks to a registry and so honours
+// the context of the Execute call.
+func ctxHandler(handle func(context.Context, *porter.Client, types.PluginArgs, hclog.Logger, io.Writer) error) func(e *execution) error {
+	return func(e *execution) error {
+		return handle(e.ctx, e.client, e.args, e.logger, e.stdout)
+	}
+}
+
+// flagAliases are the names flags are also accepted under.
+var flagAliases = map[string]string{
+	"platforms": "platform",
+	"type":      "artifact-type",
+}
+
+// flagSet returns the flags cmd accepts, including --help and, for commands it
+// simulates, --audit-only.
+func (cmd *command) flagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet(cmd.name, pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	fs.SortFlags = false
+	fs.SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if canonical, ok := flagAliases[name]; ok {
+			return pflag.NormalizedName(canonical)
+		}
+		return pflag.NormalizedName(name)
+	})
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	if cmd.audits {
+		fs.Bool("audit-only", false, "Evaluate policies and print the planned changes without transferring or writing anything")
+	}
+	fs.BoolP("help", "h", false, "Show this help")
+	return fs
+}
+
+// parseArgs checks the key=value arguments DS passes against the flags of cmd. It
+// returns them with every flag under its long name and the positional arguments
+// renumbered, so handlers read them as before. Unknown flags and surplus
+// positional arguments are errors.
+func (cmd *command) parseArgs(pairs []string) (types.PluginArgs, error) {
+	fs := cmd.flagSet()
+	var argv, positionals []string
+	for _, pair := range pairs {
+		key, value, _ := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" {
+			continue
+		}
+		if positionalKey(key) {
+			positionals = append(positionals, value)
+			continue
+		}
+		flag := fs.Lookup(key)
+		if flag == nil && len(key) == 1 {
+			flag = fs.ShorthandLookup(key)
+		}
+		switch {
+		case flag == nil && len(key) == 1:
+			argv = append(argv, "-"+key)
+		case flag == nil:
+			argv = append(argv, "--"+key)
+		case flag.Value.Type() == "bool":
+			set, ok := parseBoolValue(value)
+			if !ok {
+				// DS binds the word after a flag to it, as in --insecure <ref>.
+				positionals = append(positionals, value)
+				set = true
+			}
+			argv = append(argv, "--"+flag.Name+"="+strconv.FormatBool(set))
+		default:
+			argv = append(argv, "--"+flag.Name+"="+value)
+		}
+	}
+	argv = append(append(argv, "--"), positionals...)
+	if err := fs.Parse(argv); err != nil {
+		return types.PluginArgs{}, fmt.Errorf("%w; see ds porter %s --help", err, cmd.name)
+	}
+
+	positionals = cleanedValues(fs.Args())
+	if cmd.maxArgs >= 0 && len(positionals) > cmd.maxArgs {
+		return types.PluginArgs{}, fmt.Errorf("unexpected argument %q; usage: %s", positionals[cmd.maxArgs], cmd.usage[0])
+	}
+	var parsed []string
+	fs.Visit(func(flag *pflag.Flag) {
+		if values, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range values.GetSlice() {
+				parsed = append(parsed, flag.Name+"="+value)
+			}
+			return
+		}
+		parsed = append(parsed, flag.Name+"="+flag.Value.String())
+	})
+	for i, value := range positionals {
+		parsed = append(parsed, "arg"+strconv.Itoa(i)+"="+value)
+	}
+	return types.NewPluginArgs(parsed), nil
+}
+
+// positionalKey reports whether key names a positional argument, as arg0 does.
+func positionalKey(key string) bool {
+	index, ok := strings.CutPrefix(key, "arg")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(index)
+	return err == nil && n >= 0
+}
+
+// parseBoolValue reads the value of a boolean flag the way PluginArgs.Bool does;
+// an empty value sets the flag.
+func parseBoolValue(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "", "true", "t", "1", "yes", "y", "on":
+		return true, true
+	case "false", "f", "0", "no", "n", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// writeUsage writes the usage of cmd: its command lines, description, flags,
+// notes and examples.
+func (cmd *command) writeUsage(w io.Writer) {
+	var lines []string
+	for i, usage := range cmd.usage {
+		prefix := "       "
+		if i == 0 {
+			prefix = "Usage: "
+		}
+		lines = append(lines, prefix+usage)
+	}
+	if len(cmd.description) > 0 {
+		lines = append(append(lines, ""), cmd.description...)
+	}
+	lines = append(lines, "", "Flags:")
+	lines = append(lines, strings.Split(strings.TrimRight(cmd.flagSet().FlagUsages(), "\n"), "\n")...)
+	if len(cmd.notes) > 0 {
+		lines = append(append(lines, ""), cmd.notes...)
+	}
+	if len(cmd.examples) > 0 {
+		lines = append(lines, "", "Examples:")
+		for _, example := range cmd.examples {
+			lines = append(lines, "  "+example)
+		}
+	}
+	writeLines(w, lines)
+}
+
+// lookupCommand returns the command named name.
+func lookupCommand(name string) (*command, bool) {
+	for _, cmd := range porterCommands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+// printUsage writes the usage of the command named name.
+func printUsage(w io.Writer, name string) {
+	if cmd, ok := lookupCommand(name); ok {
+		cmd.writeUsage(w)
+	}
+}
+
+// handleHelp lists the commands, or writes the usage of the one named by the
+// positional argument.
+func handleHelp(_ *porter.Client, args types.PluginArgs, _ hclog.Logger, stdout io.Writer) error {
+	if name, ok := args.Positional(0); ok {
+		cmd, ok := lookupCommand(name)
+		if !ok {
+			return fmt.Errorf("unknown operation: %s", name)
+		}
+		cmd.writeUsage(stdout)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Available commands:")
+	for _, cmd := range porterCommands() {
+		if !cmd.hidden {
+			fmt.Fprintf(tw, "  %s\t%s\n", cmd.synopsis, cmd.summary)
+		}
+	}
+	fmt.Fprintln(tw, "")
+	fmt.Fprintln(tw, "Run `ds porter <command> --help` for the flags of a command.")
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to format help: %w", err)
+	}
+	if _, err := stdout.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write help: %w", err)
+	}
+	return nil
+}
+
+func insecureFlag(fs *pflag.FlagSet) {
+	fs.Bool("insecure", false, "Allow plain HTTP connections to registries")
+}
+
+func formatFlags(fs *pflag.FlagSet, quiet string) {
+	fs.String("format", "", "Result `format`: json (default), table, yaml or template=<go-template>")
+	completeFlag(fs, "format", "json", "table", "yaml", "template=")
+	fs.BoolP("quiet", "q", false, quiet)
+}
+
+// hiddenStrings defines string flags older DS invocations pass in place of
+// positional arguments, such as ref=<artifact-ref>.
+func hiddenStrings(fs *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		fs.String(name, "", "")
+		_ = fs.MarkHidden(name)
+	}
+}
+
+// porterCommands returns the commands of the plugin in the order the manifest
+// and the help overview list them.
+func porterCommands() []*command {
+	return []*command{
+		{
+			name:      "pull",
+			summary:   "Pull an OCI artifact",
+			synopsis:  "pull <artifact>",
+			usage:     []string{"ds porter pull [flags] <artifact-ref>"},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("output", "o", "", "Export the artifact to a file or directory `path`\n"+
+					"Directories receive ds-porter by default; files write the binary directly\n"+
+					"- writes a single-layer artifact to stdout and the JSON result to stderr")
+				fs.StringArray("platform", nil, "Fetch a specific `os/arch` platform (repeatable; e.g. linux/arm64)")
+				fs.Bool("all-arch", false, "Fetch every platform in the index (requires directory output)")
+				insecureFlag(fs)
+				fs.Bool("shared", false, "Treat the output as a network share (lock file, write-then-rename)")
+				fs.Bool("full", false, "Rewrite every file, even those unchanged since the last export")
+				fs.Bool("versioned", false, "Export into <output>/<version> and point <output>/current at it")
+				fs.String("version-name", "", "Version directory `name` for --versioned (default: image version annotation, tag, or digest)")
+				fs.String("output-template", "", "Name exported files flat in <output> with a `template`, e.g. {{.Name}}-{{.OS}}-{{.Arch}}{{.Ext}}")
+				fs.Bool("oci-archive", false, "Write the whole artifact as an oci-layout tarball instead of extracting it")
+				fs.Bool("docker-archive", false, "Write the image of one platform as a tarball for docker load instead of extracting it")
+				fs.Bool(porter.OverwriteReplace, false, "Replace files that already exist in the output (default)")
+				fs.Bool(porter.OverwriteSkipExisting, false, "Leave files that already exist in the output alone")
+				fs.Bool(porter.OverwriteErrorIfExists, false, "Fail when a file already exists in the output")
+				fs.Bool("locked", false, "Fail unless the reference resolves to its digest in the lockfile")
+				fs.String("lockfile", "", "Lockfile `path` used by --locked (default porter.lock)")
+				fs.String("family", "", "Pull every member of the family of `ref`; outputs go to <output>/<member>/")
+				fs.String("channel", "", "Pull the digest the release channel `name` of the repository points at")
+				fs.Bool("dry-run", false, "Pull into the cache and list the files the export would write, without writing them")
+				fs.Bool("require-digest", false, "Refuse references by tag; <artifact-ref> must be repo@sha256:<digest>")
+				fs.Bool("force", false, "Ignore the cached copy, download every blob again, and replace the cache entry")
+				formatFlags(fs, "Log errors only and write just the digest of the pulled artifact")
+				hiddenStrings(fs, "ref", "artifact")
+			},
+			notes: []string{
+				"Behaviour:",
+				"  • Without --platform/--all-arch, the current runtime platform is exported",
+				"  • When multiple platforms are requested, artifacts are written to <dir>/<os>/<arch>/ unless --output-template names them",
+				"  • --output also accepts ssh://host:/path, s3://bucket/prefix, and k8s://ns/configmap/name destinations",
+				"  • Files are written to a temporary name and renamed into place, so failed exports leave no truncated files",
+				"  • NFS and SMB outputs are detected and written under .porter-export.lock",
+				"  • Local outputs record .porter-delivery.json; re-exports skip files whose digest is unchanged",
+				"  • Versioned outputs keep earlier versions; list and switch them with `ds porter versions`",
+				"  • --family pulls every member before exporting any, and fails if a member's version differs",
+				"  • --channel ignores the tag of <artifact-ref>; set channels with `ds porter channel set`",
+				"  • -o - is limited to text layers up to 4 MiB, as DS relays plugin output as text",
+				"  • require_digest in the porter settings applies --require-digest to every pull",
+				"  • A digest already in the cache is not downloaded again unless --force is given",
+			},
+			examples: []string{
+				"ds porter pull ghcr.io/delivery-station/porter:0.2.0 -o ./porter-bin",
+				"ds porter pull localhost/delivery-station/porter:0.2.0 --platform linux/arm64 -o ./out",
+				"ds porter pull ghcr.io/...:0.2.0 --all-arch -o ./artifacts",
+				"ds porter pull ghcr.io/...:0.2.0 --all-arch --output-template '{{.Name}}-{{.OS}}-{{.Arch}}{{.Ext}}' -o ./dist",
+				"ds porter pull ghcr.io/delivery-station/porter:0.2.0 -o ssh://device:/opt/app",
+				"ds porter pull --family ghcr.io/delivery-station/suite:1.4.0 -o ./suite",
+				"ds porter pull --channel stable ghcr.io/delivery-station/porter -o ./porter-bin",
+				"ds porter pull ghcr.io/...:0.2.0 --platform linux/arm64 --platform linux/amd64 -o ./out --dry-run",
+				"ds porter pull ghcr.io/acme/deploy-config:1.0 -o - | kubectl apply -f -",
+				"ds porter pull ghcr.io/delivery-station/porter:0.2.0 --oci-archive -o ./porter.oci.tar",
+				"ds porter pull ghcr.io/acme/service:1.0 --platform linux/amd64 --docker-archive -o ./service.tar",
+			},
+			run: runPull,
+		},
+		{
+			name:     "push",
+			summary:  "Push an OCI artifact",
+			synopsis: "push <path> <ref>",
+			usage: []string{
+				"ds porter push [flags] <binary|dir|oci-layout|docker-save.tar> <ref>",
+				"ds porter push [flags] --manifest <ds.manifest.yaml> <ref>",
+				"ds porter push [flags] --from-cache <cache-id|artifact-ref> <ref>",
+				"ds porter push [flags] --family-index <family.json> <ref>",
+				"ds porter push [flags] --workspace <workspace.yaml>",
+			},
+			description: []string{
+				"Packs a binary or directory, copies an OCI layout or docker save tarball, or",
+				"publishes every platform of a manifest as a multi-arch index, and tags it at <ref>.",
+			},
+			maxArgs:   2,
+			completes: []string{completeFiles, completeRefs},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("manifest", "m", "", "Push the platforms listed in the manifest `file` as one index")
+				insecureFlag(fs)
+				fs.Bool("sign", false, "Sign the manifests and the index with a cosign-compatible signature")
+				fs.String("sign-key", "", "Sign with the PEM private key at `path`, decrypted with $COSIGN_PASSWORD")
+				fs.String("fulcio-url", "", "Fulcio `url` for keyless signing")
+				fs.String("rekor-url", "", "Rekor `url` keyless signatures are recorded in")
+				fs.StringArray("sbom", nil, "Attach the CycloneDX or SPDX document at `path` as a referrer (repeatable)")
+				fs.String("compression", "", "Archive directory entries with `gzip|zstd` (default gzip)")
+				completeFlag(fs, "compression", "gzip", "zstd")
+				fs.Bool("tag-latest", false, "Also tag the index latest, overriding the push.tag_latest setting")
+				fs.Bool("no-latest", false, "Push the index under its tag only")
+				fs.StringArray("tag", nil, "Also tag the pushed index `tag` (repeatable)")
+				fs.Bool("semver-aliases", false, "Also tag the index with the minor and major aliases of its version tag")
+				fs.StringArray("metadata", nil, "Pack build metadata `key=value` into the platform manifest configs (repeatable)")
+				fs.String("from-cache", "", "Republish the cached artifact with this `cache-id|ref` unchanged")
+				completeFlag(fs, "from-cache", completeIDs)
+				fs.String("family-index", "", "Publish the family index described by the JSON `file`")
+				fs.String("workspace", "", "Publish every artifact of the workspace `file`")
+				fs.String("parallel", "", "Push at most `n` workspace artifacts at once")
+				formatFlags(fs, "Log errors only and write just the digest of the pushed artifact")
+			},
+			examples: []string{
+				"ds porter push ./dist/porter ghcr.io/delivery-station/porter:0.2.0 --no-latest",
+				"ds porter push --manifest ds.manifest.yaml ghcr.io/delivery-station/porter:0.2.0 --sign",
+				"ds porter push --from-cache 3f2a9c1d0e4b5a67 registry.internal/porter:0.2.0",
+				"ds porter push --workspace workspace.yaml --parallel 4",
+			},
+			run: ctxHandler(handlePush),
+		},
+		{
+			name:     "list",
+			summary:  "List cached artifacts",
+			synopsis: "list",
+			usage:    []string{"ds porter list [flags]"},
+			description: []string{
+				"Lists the artifacts in the local cache from the cache database, which pulls,",
+				"loads and removals keep current.",
+			},
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("family", "", "Only list the members of the family `name`")
+				fs.Bool("rebuild-index", false, "Reconcile the cache database with the cached artifact directories first")
+				formatFlags(fs, "Write just the cache IDs of the artifacts")
+			},
+			examples: []string{
+				"ds porter list --format table",
+				"ds porter list --family suite -q",
+				"ds porter list --rebuild-index",
+			},
+			run: handler(handleList),
+		},
+		{
+			name:     "execute-plugin",
+			summary:  "Execute a plugin contained in an artifact",
+			synopsis: "execute-plugin",
+			usage:    []string{"ds porter execute-plugin <cache-id> <plugin> [<arg>...]"},
+			description: []string{
+				"Runs a plugin binary of a cached artifact with the remaining arguments.",
+			},
+			maxArgs:   -1,
+			completes: []string{completeIDs, ""},
+			run:       handler(handleExecutePlugin),
+		},
+		{
+			name:     "auth",
+			summary:  "Diagnose registry authentication",
+			synopsis: "auth check <ref>",
+			usage:    []string{"ds porter auth check [flags] <artifact-ref>"},
+			description: []string{
+				"Walks the registry authentication flow step by step with secrets redacted:",
+				"credential source, auth challenge, token endpoint, granted scopes, and anonymous fallback.",
+			},
+			maxArgs:   2,
+			completes: []string{"check", completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.Bool("push", false, "Request push scope in addition to pull")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{"ds porter auth check ghcr.io/delivery-station/porter:0.2.0"},
+			run:      ctxHandler(handleAuth),
+		},
+		{
+			name:     "login",
+			summary:  "Log in to a registry and store its credentials",
+			synopsis: "login <registry>",
+			usage:    []string{"ds porter login [flags] <registry>"},
+			description: []string{
+				"Checks the credentials against the registry, completing its token handshake,",
+				"and stores them in the porter credentials file (~/.ds/porter/credentials.json",
+				"unless credentials_file is set), or in the OS keychain when credentials_store",
+				"selects it. Credentials in the DS configuration take precedence over stored ones.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRegistries},
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("username", "u", "", "Registry `username` (defaults to the current user)")
+				fs.StringP("password", "p", "", "Registry `password` or token (defaults to $"+passwordEnv+")")
+				fs.Bool("insecure", false, "Allow plain HTTP connections to the registry")
+			},
+			examples: []string{passwordEnv + "=$TOKEN ds porter login ghcr.io --username octocat"},
+			run:      ctxHandler(handleLogin),
+		},
+		{
+			name:        "logout",
+			summary:     "Remove stored registry credentials",
+			synopsis:    "logout <registry>",
+			usage:       []string{"ds porter logout <registry>"},
+			description: []string{"Removes the credentials login stored for the registry."},
+			maxArgs:     1,
+			completes:   []string{completeRegistries},
+			examples:    []string{"ds porter logout ghcr.io"},
+			run:         handler(handleLogout),
+		},
+		{
+			name:     "estimate",
+			summary:  "Estimate bytes a pull would transfer",
+			synopsis: "estimate <ref>",
+			usage:    []string{"ds porter estimate [flags] <artifact-ref>"},
+			description: []string{
+				"Resolves manifests without downloading layers and reports, per blob, whether",
+				"it is already in the local cache or would be downloaded by a pull. Every",
+				"platform of an index is counted, as a pull downloads them all.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{"ds porter estimate ghcr.io/delivery-station/porter:0.2.0"},
+			run:      ctxHandler(handleEstimate),
+		},
+		{
+			name:     "attach",
+			summary:  "Attach files to a manifest as a referrer artifact",
+			synopsis: "attach <ref>",
+			usage:    []string{"ds porter attach [flags] <subject-ref> <file>..."},
+			description: []string{
+				"Attaches files to an existing manifest as an OCI referrer artifact",
+				"(test reports, licenses, provenance, ...).",
+			},
+			maxArgs:   -1,
+			completes: []string{completeRefs, completeFiles},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("artifact-type", "", "Artifact `type` of the referrer (required)")
+				fs.StringArray("annotation", nil, "Add a manifest annotation `key=value` (repeatable)")
+				insecureFlag(fs)
+				hiddenStrings(fs, "subject", "ref")
+				fs.StringArray("file", nil, "")
+				_ = fs.MarkHidden("file")
+			},
+			examples: []string{"ds porter attach ghcr.io/delivery-station/porter:0.2.0 --artifact-type application/vnd.test-report+json report.json"},
+			run:      ctxHandler(handleAttach),
+		},
+		{
+			name:     "referrers",
+			summary:  "List artifacts attached to a manifest",
+			synopsis: "referrers <ref>",
+			usage:    []string{"ds porter referrers [flags] <artifact-ref>"},
+			description: []string{
+				"Lists artifacts attached to a manifest (signatures, SBOMs, reports) as JSON.",
+				"Registries without the referrers API are queried through the referrers tag schema,",
+				"and cosign signatures stored under sha256-<digest>.sig tags are included.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("artifact-type", "", "Only list referrers of the artifact `type`")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{
+				"ds porter referrers ghcr.io/delivery-station/porter:0.2.0",
+				"ds porter referrers --artifact-type application/spdx+json ghcr.io/delivery-station/porter:0.2.0",
+			},
+			run: ctxHandler(handleReferrers),
+		},
+		{
+			name:     "exists",
+			summary:  "Check whether a reference exists at its registry",
+			synopsis: "exists <ref>",
+			usage:    []string{"ds porter exists [flags] <ref>"},
+			description: []string{
+				"Checks with a single HEAD request whether a tag or digest exists at its",
+				"registry and prints its digest, size and media type as JSON. Fails when",
+				"the reference does not exist, or with --absent when it does.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.Bool("absent", false, "Fail when the reference exists, e.g. before publishing a tag")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{
+				"ds porter exists ghcr.io/delivery-station/porter:0.2.0",
+				"ds porter exists --absent ghcr.io/delivery-station/porter:0.3.0",
+			},
+			run: ctxHandler(handleExists),
+		},
+		{
+			name:     "cat",
+			summary:  "Write a layer or config blob to stdout",
+			synopsis: "cat <ref>",
+			usage:    []string{"ds porter cat [flags] <ref>"},
+			description: []string{
+				"Writes a blob of an artifact to stdout, straight from the registry and",
+				"without caching it. Without a selector the only layer of the manifest is",
+				"written. Blobs must be text and fit into " + strconv.Itoa(stdoutLimit>>10) + " KiB.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("layer", "", "Write the layer at position `n`, counting from 0")
+				fs.String("digest", "", "Write the layer, config or manifest with this `digest`")
+				fs.Bool("config", false, "Write the config of the manifest")
+				fs.StringArray("platform", nil, "Select the `os/arch` manifest of a multi-platform artifact")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{
+				"ds porter cat ghcr.io/delivery-station/porter:0.2.0 --config --platform linux/amd64",
+				"ds porter cat ghcr.io/delivery-station/notes:1.0 --layer 1",
+			},
+			run: ctxHandler(handleCat),
+		},
+		{
+			name:     "tags",
+			summary:  "List the tags of a repository",
+			synopsis: "tags <repository>",
+			usage:    []string{"ds porter tags [flags] <repository>"},
+			description: []string{
+				"Lists the tags of a repository as JSON, following the registry's pagination.",
+				"latest names the highest version among the listed tags.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("match", "", "Keep tags the whole regular expression `regex` matches")
+				fs.String("semver", "", "Keep versions meeting the `constraint`, e.g. '>=1.2, <2'")
+				fs.String("limit", "", "Return at most `n` tags; next names the tag to continue after")
+				fs.String("last", "", "List the tags after `tag`, e.g. the next of a previous page")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{
+				"ds porter tags ghcr.io/delivery-station/porter --semver '>=0.2, <1'",
+				"ds porter tags ghcr.io/delivery-station/porter --limit 50 --last 0.1.9",
+			},
+			run: ctxHandler(handleTags),
+		},
+		{
+			name:     "repos",
+			summary:  "List the repositories of a registry",
+			synopsis: "repos <registry>",
+			usage:    []string{"ds porter repos [flags] <registry>"},
+			description: []string{
+				"Lists the repositories of a registry as JSON through its catalog API,",
+				"following its pagination. Registries without the catalog API fail the listing.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRegistries},
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("prefix", "", "Keep repositories whose name starts with `prefix`, e.g. team/")
+				fs.String("limit", "", "Return at most `n` repositories; next names the one to continue after")
+				fs.String("last", "", "List the repositories after `repo`, e.g. the next of a previous page")
+				fs.Bool("insecure", false, "Allow plain HTTP connections to the registry")
+				hiddenStrings(fs, "registry")
+			},
+			examples: []string{"ds porter repos registry.internal:5000 --prefix delivery-station/"},
+			run:      ctxHandler(handleRepos),
+		},
+		{
+			name:     "lock",
+			summary:  "Record resolved digests in porter.lock",
+			synopsis: "lock [<ref>...]",
+			usage:    []string{"ds porter lock [flags] [<artifact-ref>...]"},
+			description: []string{
+				"Resolves each reference and records its digest in the lockfile. Without",
+				"references, every entry already in the lockfile is re-resolved.",
+				"Use `ds porter pull --locked` to fail when a tag no longer matches.",
+			},
+			maxArgs:   -1,
+			completes: []string{completeRefs},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("lockfile", "", "Lockfile `path` to update (default porter.lock)")
+				insecureFlag(fs)
+			},
+			examples: []string{"ds porter lock ghcr.io/delivery-station/porter:0.2.0"},
+			run:      ctxHandler(handleLock),
+		},
+		{
+			name:     "verify",
+			summary:  "Check the integrity of a cached artifact",
+			synopsis: "verify <id|ref>",
+			usage:    []string{"ds porter verify [flags] <cache-id|artifact-ref>"},
+			description: []string{
+				"Recomputes the digest of every blob in a cached artifact's OCI layout, checks that",
+				"index and manifest references resolve, and reports the result as JSON.",
+			},
+			maxArgs:   1,
+			completes: []string{completeIDs},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.Bool("repair", false, "Re-fetch missing or corrupt blobs from the original reference")
+				fs.Bool("insecure", false, "Allow plain HTTP connections when repairing")
+				hiddenStrings(fs, "id", "ref")
+			},
+			examples: []string{
+				"ds porter verify ghcr.io/delivery-station/porter:0.2.0",
+				"ds porter verify 3f2a9c1d0e4b5a67 --repair",
+			},
+			run: ctxHandler(handleVerify),
+		},
+		{
+			name:     "diff",
+			summary:  "Compare the layers, annotations and platforms of two artifacts",
+			synopsis: "diff <a> <b>",
+			usage:    []string{"ds porter diff [flags] <left> <right>"},
+			description: []string{
+				"Compares two artifacts and reports, as JSON, the platforms only one of them",
+				"covers and, per platform, the layers added, removed or changed and the",
+				"annotations that differ. Layers are matched by title, or by position when",
+				"they have none. Each artifact is a reference resolved at its registry, or the",
+				"cache ID or digest of a cached artifact. Layer content is not downloaded.",
+			},
+			maxArgs:   2,
+			completes: []string{completeIDs},
+			flags:     insecureFlag,
+			examples: []string{
+				"ds porter diff ghcr.io/delivery-station/porter:0.2.0 ghcr.io/delivery-station/porter:0.3.0",
+				"ds porter diff 3f2a9c1e ghcr.io/delivery-station/porter:0.3.0",
+			},
+			run: ctxHandler(handleDiff),
+		},
+		{
+			name:     "save",
+			summary:  "Write a cached artifact to a portable tarball",
+			synopsis: "save <id|ref>",
+			usage:    []string{"ds porter save -o <file> <cache-id|artifact-ref>"},
+			description: []string{
+				"Writes a cached artifact to a single portable tarball: its cache metadata and an",
+				"OCI layout with every blob it references. 'ds porter load' restores it into the",
+				"cache of another machine with the same cache ID and digests.",
+			},
+			maxArgs:   1,
+			completes: []string{completeIDs},
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("output", "o", "", "`file` to write the portable archive to")
+			},
+			examples: []string{
+				"ds porter save -o porter-0.2.0.tar ghcr.io/delivery-station/porter:0.2.0",
+				"ds porter save -o /media/usb/tool.tar 3f2a9c1d0e4b5a67",
+			},
+			run: handler(handleSave),
+		},
+		{
+			name:     "load",
+			summary:  "Restore a portable tarball into the cache",
+			synopsis: "load <file>",
+			usage:    []string{"ds porter load <file>"},
+			description: []string{
+				"Restores a portable archive written by 'ds porter save' into the cache, verifying",
+				"every blob against its digest, under the cache ID it had on the saving machine.",
+				"Republish it with 'ds porter push --from-cache <cache-id|artifact-ref> <ref>'.",
+			},
+			maxArgs:   1,
+			completes: []string{completeFiles},
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("input", "i", "", "")
+				_ = fs.MarkHidden("input")
+			},
+			examples: []string{"ds porter load /media/usb/porter-0.2.0.tar"},
+			run:      handler(handleLoad),
+		},
+		{
+			name:     "cache",
+			summary:  "Inspect and manage the local artifact cache",
+			synopsis: "cache stats|rm",
+			usage: []string{
+				"ds porter cache stats [flags]",
+				"ds porter cache rm <cache-id|artifact-ref|digest>",
+			},
+			description: []string{
+				"stats reports the disk the cache uses, its artifacts per registry, what the shared",
+				"blob store saves by keeping blobs common to several artifacts once, and the oldest",
+				"and newest artifacts.",
+				"",
+				"rm removes one cached artifact: its OCI layout, its record, and the blobs of the",
+				"shared blob store no other cached artifact references. Prints the removed",
+				"artifact with the number of blobs removed and the bytes freed.",
+			},
+			maxArgs:   2,
+			completes: []string{"stats rm", completeIDs},
+			flags: func(fs *pflag.FlagSet) {
+				formatFlags(fs, "Write just the bytes the cache uses on disk (stats)")
+				hiddenStrings(fs, "id", "ref")
+			},
+			examples: []string{
+				"ds porter cache stats --format table",
+				"ds porter cache stats | jq '.registries'",
+				"ds porter cache rm 3f2a9c1d0e4b5a67",
+				"ds porter cache rm ghcr.io/delivery-station/porter:0.2.0",
+			},
+			run: handler(handleCache),
+		},
+		{
+			name:     "imagify",
+			summary:  "Wrap an exported binary into a container image",
+			synopsis: "imagify <ref>",
+			usage:    []string{"ds porter imagify [flags] --tag <image-ref> <artifact-ref>"},
+			description: []string{
+				"Wraps the exported binary into a minimal container image: the base image plus one",
+				"layer holding the exported files, pushed without Docker or BuildKit.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("tag", "t", "", "Image `ref` to push (required)")
+				fs.String("base", "", "Base image `ref` (default "+porter.DefaultImagifyBase+")")
+				fs.String("platform", "", "`os/arch` platform of the binary and base image (default linux/<host arch>)")
+				fs.String("dir", "", "Directory `path` inside the image for the files (default "+porter.DefaultImagifyDir+")")
+				fs.StringArray("entrypoint", nil, "Entrypoint `arg` (repeatable; defaults to the single exported file)")
+				insecureFlag(fs)
+				hiddenStrings(fs, "ref")
+			},
+			examples: []string{"ds porter imagify ghcr.io/delivery-station/porter:0.2.0 --base gcr.io/distroless/static -t ghcr.io/acme/porter-image:0.2.0"},
+			run:      ctxHandler(handleImagify),
+		},
+		{
+			name:     "versions",
+			summary:  "List or switch versions of a versioned export",
+			synopsis: "versions <dir>",
+			usage:    []string{"ds porter versions [flags] <dir>"},
+			description: []string{
+				"Lists the versions installed in a directory written by `ds porter pull --versioned`",
+				"as JSON, newest first, marking the one the current symlink points at.",
+			},
+			maxArgs:   1,
+			completes: []string{completeFiles},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("use", "", "Point <dir>/current at an installed `version` before listing")
+				hiddenStrings(fs, "dir")
+				fs.StringP("output", "o", "", "")
+				_ = fs.MarkHidden("output")
+			},
+			examples: []string{
+				"ds porter versions /opt/app",
+				"ds porter versions /opt/app --use 0.2.0",
+			},
+			run: handler(handleVersions),
+		},
+		{
+			name:     "stats",
+			summary:  "Report cache efficiency per pulled reference",
+			synopsis: "stats [<ref>]",
+			usage:    []string{"ds porter stats [<artifact-ref>]"},
+			description: []string{
+				"Reports cache efficiency per pulled reference as JSON, most recently used first:",
+				"pull count, pulls served entirely from cache, bytes downloaded and saved, hit",
+				"ratio, average transfer speed, and last access.",
+			},
+			maxArgs:   1,
+			completes: []string{completeRefs},
+			flags: func(fs *pflag.FlagSet) {
+				hiddenStrings(fs, "ref", "artifact")
+			},
+			examples: []string{
+				"ds porter stats | jq 'sort_by(.bytes_saved) | reverse'",
+				"ds porter stats ghcr.io/delivery-station/porter:0.2.0",
+			},
+			run: handler(handleStats),
+		},
+		{
+			name:     "metrics",
+			summary:  "Expose pull and push metrics for Prometheus",
+			synopsis: "metrics",
+			usage:    []string{"ds porter metrics"},
+			description: []string{
+				"Writes the metrics accumulated by every pull and push in the Prometheus text",
+				"format: operations by outcome, bytes transferred and reused, retries, cache",
+				"hits and the hit ratio, and a histogram of operation durations. Each pull and",
+				"push result also carries a metrics summary of its own operation.",
+			},
+			examples: []string{
+				"ds porter metrics > /var/lib/node_exporter/textfile/porter.prom",
+			},
+			run: handler(handleMetrics),
+		},
+		{
+			name:     "channel",
+			summary:  "Maintain release channel pointers",
+			synopsis: "channel set|list",
+			usage: []string{
+				"ds porter channel set <channel> --ref <artifact-ref> [flags]",
+				"ds porter channel list <repository>",
+			},
+			description: []string{
+				"Maintains release channels: named pointers, such as stable or beta, from a",
+				"repository to an artifact digest. Channels are stored in the channel pointer",
+				"artifact <repository>:" + porter.ChannelsTag + " and resolved by `ds porter pull --channel`.",
+			},
+			maxArgs:   3,
+			completes: []string{"set list", ""},
+			audits:    true,
+			flags: func(fs *pflag.FlagSet) {
+				fs.String("ref", "", "Artifact `ref` the channel points at; its repository holds the channel")
+				insecureFlag(fs)
+			},
+			examples: []string{
+				"ds porter channel set stable --ref ghcr.io/acme/tool:1.4.2",
+				"ds porter channel list ghcr.io/acme/tool",
+				"ds porter pull --channel stable ghcr.io/acme/tool",
+			},
+			run: ctxHandler(handleChannel),
+		},
+		{
+			name:     "manifest",
+			summary:  "Generate and validate ds.manifest.yaml files",
+			synopsis: "manifest init|validate",
+			usage: []string{
+				"ds porter manifest init [flags] [<build-dir>]",
+				"ds porter manifest validate [<manifest>]",
+			},
+			description: []string{
+				"init scans a build output directory (default dist) and writes a ds.manifest.yaml",
+				"with one entry per platform its paths name, such as porter_linux_amd64/ or",
+				"bin/darwin/arm64/. A platform with one executable gets that binary; one with",
+				"several files gets their directory, archived at push. Annotations porter cannot",
+				"detect are set to TODO. Entry paths are relative to the manifest.",
+				"",
+				"validate strictly parses a manifest (default ds.manifest.yaml) as push does and",
+				"reports every unknown field, missing or nonexistent path, duplicate platform and",
+				"invalid platform string with its line.",
+			},
+			maxArgs:   2,
+			completes: []string{"init validate", completeFiles},
+			flags: func(fs *pflag.FlagSet) {
+				fs.StringP("output", "o", "", "Manifest `file` to write; entry paths are relative to it (default ds.manifest.yaml)")
+				fs.String("name", "", "Name annotation of the entries (default the detected binary `name`)")
+				fs.String("artifact-type", "", "Artifact `type` of the manifest")
+				fs.Bool("force", false, "Overwrite an existing manifest")
+				hiddenStrings(fs, "dir")
+			},
+			examples: []string{
+				"ds porter manifest init dist",
+				"ds porter manifest init --name porter -o release/ds.manifest.yaml build/bin",
+				"ds porter manifest validate release/ds.manifest.yaml",
+			},
+			run: handler(handleManifest),
+		},
+		{
+			name:     "completion",
+			summary:  "Generate shell completion scripts",
+			synopsis: "completion bash|zsh|fish",
+			usage:    []string{"ds porter completion bash|zsh|fish"},
+			description: []string{
+				"Write a script completing ds porter commands, flags, cached artifact IDs and",
+				"configured registry names. The script asks 'ds porter completion ids' and",
+				"'ds porter completion registries' for the current cache and configuration.",
+			},
+			examples: []string{
+				"source <(ds porter completion bash)",
+				"ds porter completion zsh > \"${fpath[1]}/_ds\"",
+				"ds porter completion fish > ~/.config/fish/completions/ds.fish",
+			},
+			maxArgs:   1,
+			completes: []string{"bash zsh fish"},
+			run:       handler(handleCompletion),
+		},
+		{
+			name:     "version",
+			summary:  "Display plugin version information",
+			synopsis: "version [--json]",
+			usage:    []string{"ds porter version [flags]"},
+			flags: func(fs *pflag.FlagSet) {
+				fs.Bool("json", false, "Write the version report as JSON")
+			},
+			run: func(e *execution) error {
+				return handleVersion(e.client, e.args, e.build, e.stdout)
+			},
+		},
+		{
+			name:      "help",
+			summary:   "List the commands or show the usage of one",
+			synopsis:  "help [<command>]",
+			usage:     []string{"ds porter help [<command>]"},
+			maxArgs:   1,
+			completes: []string{completeCommands},
+			hidden:    true,
+			run:       handler(handleHelp),
+		},
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+	"github.com/spf13/pflag"
+)
+
+// Completion kinds of positional arguments and flag values. Any other entry of
+// command.completes or a completion annotation is a list of words.
+const (
+	// completeIDs offers the IDs of cached artifacts.
+	completeIDs = "ids"
+	// completeRegistries offers the registries the configuration names.
+	completeRegistries = "registries"
+	// completeRefs offers configured registries as the start of a reference.
+	completeRefs     = "refs"
+	completeFiles    = "files"
+	completeCommands = "commands"
+)
+
+// completionAnnotation is the pflag annotation holding what completion offers
+// for the value of a flag; flags without it complete file names.
+const completionAnnotation = "porter_completion"
+
+// completeFlag records what completion offers for the value of the flag name.
+func completeFlag(fs *pflag.FlagSet, name string, values ...string) {
+	_ = fs.SetAnnotation(name, completionAnnotation, values)
+}
+
+func handleCompletion(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	shell, ok := args.Positional(0)
+	if !ok {
+		printUsage(stdout, "completion")
+		return nil
+	}
+	logger.Debug("Generating completion", "shell", shell)
+
+	var lines []string
+	switch shell {
+	case "bash":
+		return writeBashCompletion(stdout, false)
+	case "zsh":
+		return writeBashCompletion(stdout, true)
+	case "fish":
+		return writeFishCompletion(stdout)
+	case completeIDs:
+		artifacts, err := client.ListCachedArtifacts()
+		if err != nil {
+			return err
+		}
+		for _, artifact := range artifacts {
+			lines = append(lines, artifact.ID)
+		}
+	case completeRegistries:
+		lines = client.RegistryNames()
+	default:
+		return fmt.Errorf("unsupported shell %q: expected bash, zsh or fish", shell)
+	}
+	writeLines(stdout, lines)
+	return nil
+}
+
+// completionSpec is what the completion scripts know about a command.
+type completionSpec struct {
+	name    string
+	summary string
+	// flags are the visible flags, and valueFlags the spellings of those that
+	// take a value.
+	flags      []*pflag.Flag
+	valueFlags []string
+	completes  []string
+	maxArgs    int
+}
+
+// completionSpecs describes the listed commands, plus help, for completion.
+func completionSpecs() []completionSpec {
+	var specs []completionSpec
+	for _, cmd := range porterCommands() {
+		if cmd.hidden && cmd.name != "help" {
+			continue
+		}
+		spec := completionSpec{name: cmd.name, summary: cmd.summary, completes: cmd.completes, maxArgs: cmd.maxArgs}
+		cmd.flagSet().VisitAll(func(flag *pflag.Flag) {
+			if flag.Hidden {
+				return
+			}
+			spec.flags = append(spec.flags, flag)
+			if flag.Value.Type() != "bool" {
+				spec.valueFlags = append(spec.valueFlags, flagSpellings(flag)...)
+			}
+		})
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// flagSpellings returns --name and, when the flag has one, -shorthand.
+func flagSpellings(flag *pflag.Flag) []string {
+	spellings := []string{"--" + flag.Name}
+	if flag.Shorthand != "" {
+		spellings = append(spellings, "-"+flag.Shorthand)
+	}
+	return spellings
+}
+
+// flagValueCompletions maps the spellings of value flags with a completion
+// annotation to it, across commands.
+func flagValueCompletions(specs []completionSpec) map[string]string {
+	values := make(map[string]string)
+	for _, spec := range specs {
+		for _, flag := range spec.flags {
+			if annotation := flag.Annotations[completionAnnotation]; len(annotation) > 0 {
+				for _, spelling := range flagSpellings(flag) {
+					values[spelling] = strings.Join(annotation, " ")
+				}
+			}
+		}
+	}
+	return values
+}
+
+func commandNames(specs []completionSpec) string {
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// bashCompletion is the fixed part of the bash script, formatted with the
+// command names, the flag tables of each command and the completions of flag
+// values.
+const bashCompletion = `# bash completion for ds porter, generated by ds porter completion.
+# Other ds commands fall through to the completion ds had before.
+__ds_porter_previous=$(complete -p ds 2>/dev/null | sed -n 's/.*-F \([^ ]*\).*/\1/p')
+[[ $__ds_porter_previous == _ds_porter ]] && __ds_porter_previous=
+__ds_porter_commands=%s
+
+__ds_porter_values() {
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -S "$2" -W "$(ds porter completion "$1" 2>/dev/null)" -- "$cur"))
+    [[ -n $2 ]] && type compopt &>/dev/null && compopt -o nospace
+}
+
+__ds_porter_complete() {
+    case $1 in
+    '') ;;
+    ids | registries) __ds_porter_values "$1" ;;
+    refs) __ds_porter_values registries / ;;
+    files) COMPREPLY=($(compgen -f -- "$cur")) ;;
+    commands) COMPREPLY=($(compgen -W "$__ds_porter_commands" -- "$cur")) ;;
+    *) COMPREPLY=($(compgen -W "$1" -- "$cur")) ;;
+    esac
+}
+
+_ds_porter() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+    COMPREPLY=()
+    if [[ $COMP_CWORD -lt 2 || ${COMP_WORDS[1]} != porter ]]; then
+        [[ -n $__ds_porter_previous ]] && "$__ds_porter_previous" "$@"
+        [[ $COMP_CWORD -eq 1 ]] && COMPREPLY+=($(compgen -W porter -- "$cur"))
+        return 0
+    fi
+    if [[ $COMP_CWORD -eq 2 ]]; then
+        __ds_porter_complete commands
+        return 0
+    fi
+
+    local flags values completes=() max=-1
+%s
+    if [[ " $values " == *" $prev "* ]]; then
+        case $prev in
+%s        *) __ds_porter_complete files ;;
+        esac
+        return 0
+    fi
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+        return 0
+    fi
+
+    local i n=0
+    for ((i = 3; i < COMP_CWORD; i++)); do
+        if [[ ${COMP_WORDS[i]} == -* ]]; then
+            [[ " $values " == *" ${COMP_WORDS[i]} "* ]] && ((i++))
+            continue
+        fi
+        ((n++))
+    done
+    [[ ${#completes[@]} -eq 0 ]] || [[ $max -ge 0 && $n -ge $max ]] && return 0
+    [[ $n -ge ${#completes[@]} ]] && n=$((${#completes[@]} - 1))
+    __ds_porter_complete "${completes[n]}"
+    return 0
+}
+
+complete -F _ds_porter ds
+`
+
+// writeBashCompletion writes the bash script; for zsh it runs under bashcompinit.
+func writeBashCompletion(w io.Writer, zsh bool) error {
+	specs := completionSpecs()
+	var tables strings.Builder
+	tables.WriteString("    case ${COMP_WORDS[2]} in\n")
+	for _, spec := range specs {
+		var flags []string
+		for _, flag := range spec.flags {
+			flags = append(flags, flagSpellings(flag)...)
+		}
+		quoted := make([]string, len(spec.completes))
+		for i, entry := range spec.completes {
+			quoted[i] = shellQuote(entry)
+		}
+		fmt.Fprintf(&tables, "    %s)\n", spec.name)
+		fmt.Fprintf(&tables, "        flags=%s\n", shellQuote(strings.Join(flags, " ")))
+		fmt.Fprintf(&tables, "        values=%s\n", shellQuote(strings.Join(spec.valueFlags, " ")))
+		fmt.Fprintf(&tables, "        completes=(%s) max=%d\n", strings.Join(quoted, " "), spec.maxArgs)
+		tables.WriteString("        ;;\n")
+	}
+	tables.WriteString("    *) return 0 ;;\n    esac\n")
+
+	var values strings.Builder
+	valueCompletions := flagValueCompletions(specs)
+	for _, spelling := range sortedFlagSpellings(valueCompletions) {
+		fmt.Fprintf(&values, "        %s) __ds_porter_complete %s ;;\n", spelling, shellQuote(valueCompletions[spelling]))
+	}
+
+	script := fmt.Sprintf(bashCompletion, shellQuote(commandNames(specs)), tables.String(), values.String())
+	if zsh {
+		script = "#compdef ds\n# zsh completion for ds porter, through the bash completion zsh emulates.\n" +
+			"autoload -U +X bashcompinit && bashcompinit\n\n" + script
+	}
+	if _, err := io.WriteString(w, script); err != nil {
+		return fmt.Errorf("failed to write completion script: %w", err)
+	}
+	return nil
+}
+
+// fishCompletion is the fixed part of the fish script; %s receives the value
+// flags of each command.
+const fishCompletion = `# fish completion for ds porter, generated by ds porter completion.
+function __ds_porter_command
+    set -l words (commandline -opc)
+    test (count $words) -ge 3; and test "$words[2]" = porter; and echo $words[3]
+end
+
+function __ds_porter_needs_command
+    set -l words (commandline -opc)
+    test (count $words) -eq 2; and test "$words[2]" = porter
+end
+
+function __ds_porter_using -a command
+    set -l current (__ds_porter_command)
+    test "$current" = $command
+end
+
+function __ds_porter_value_flags -a command
+    switch $command
+%s    end
+end
+
+# __ds_porter_arg succeeds when the word being completed is positional argument
+# position of command, or a later one when position ends in +.
+function __ds_porter_arg -a command position
+    __ds_porter_using $command; or return 1
+    set -l words (commandline -opc)
+    set -l values (__ds_porter_value_flags $command)
+    set -l n 0
+    set -l skip 0
+    for word in $words[4..-1]
+        if test $skip -eq 1
+            set skip 0
+        else if string match -q -- '-*' $word
+            contains -- $word $values; and set skip 1
+        else
+            set n (math $n + 1)
+        end
+    end
+    if string match -q -- '*+' $position
+        test $n -ge (string trim -r -c + -- $position)
+    else
+        test $n -eq $position
+    end
+end
+
+function __ds_porter_values -a kind suffix
+    for value in (ds porter completion $kind 2>/dev/null)
+        echo $value$suffix
+    end
+end
+
+complete -c ds -f -n '__fish_use_subcommand' -a porter -d 'Fetch and deliver OCI artifacts'
+`
+
+// writeFishCompletion writes the fish script.
+func writeFishCompletion(w io.Writer) error {
+	specs := completionSpecs()
+	var valueFlags strings.Builder
+	for _, spec := range specs {
+		if len(spec.valueFlags) > 0 {
+			fmt.Fprintf(&valueFlags, "        case %s\n            printf '%%s\\n' %s\n", spec.name, strings.Join(spec.valueFlags, " "))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, fishCompletion, valueFlags.String())
+	for _, spec := range specs {
+		fmt.Fprintf(&b, "\ncomplete -c ds -f -n '__ds_porter_needs_command' -a %s -d %s\n", spec.name, fishQuote(spec.summary))
+		condition := fishQuote("__ds_porter_using " + spec.name)
+		for _, flag := range spec.flags {
+			line := "complete -c ds -n " + condition
+			if flag.Shorthand != "" {
+				line += " -s " + flag.Shorthand
+			}
+			line += " -l " + flag.Name
+			if flag.Value.Type() != "bool" {
+				if annotation := flag.Annotations[completionAnnotation]; len(annotation) > 0 {
+					line += " -x -a " + fishQuote(fishArguments(strings.Join(annotation, " ")))
+				} else {
+					line += " -r -F"
+				}
+			}
+			_, usage := pflag.UnquoteUsage(flag)
+			usage, _, _ = strings.Cut(usage, "\n")
+			fmt.Fprintf(&b, "%s -d %s\n", line, fishQuote(usage))
+		}
+		for n, entry := range spec.completes {
+			if spec.maxArgs >= 0 && n >= spec.maxArgs {
+				break
+			}
+			position := fmt.Sprint(n)
+			if n == len(spec.completes)-1 && (spec.maxArgs < 0 || spec.maxArgs > len(spec.completes)) {
+				position += "+"
+			}
+			condition := fishQuote(fmt.Sprintf("__ds_porter_arg %s %s", spec.name, position))
+			switch entry {
+			case "":
+			case completeFiles:
+				fmt.Fprintf(&b, "complete -c ds -n %s -F\n", condition)
+			default:
+				fmt.Fprintf(&b, "complete -c ds -f -n %s -a %s\n", condition, fishQuote(fishArguments(entry)))
+			}
+		}
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write completion script: %w", err)
+	}
+	return nil
+}
+
+// fishArguments returns the fish -a argument of a completion kind or word list.
+func fishArguments(entry string) string {
+	switch entry {
+	case completeIDs, completeRegistries:
+		return "(__ds_porter_values " + entry + ")"
+	case completeRefs:
+		return "(__ds_porter_values registries /)"
+	case completeCommands:
+		return commandNames(completionSpecs())
+	}
+	return entry
+}
+
+func sortedFlagSpellings(values map[string]string) []string {
+	spellings := make([]string, 0, len(values))
+	for spelling := range values {
+		spellings = append(spellings, spelling)
+	}
+	sort.Strings(spellings)
+	return spellings
+}
+
+// shellQuote quotes value for bash and zsh.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes value for fish, which escapes quotes inside quoted strings.
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleDiff(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) != 2 {
+		return fmt.Errorf("two artifacts required")
+	}
+	left, right := strings.TrimSpace(positionals[0]), strings.TrimSpace(positionals[1])
+	insecure, _ := args.Bool("insecure")
+	logger.Debug("Resolved diff options", "left", left, "right", right, "insecure", insecure)
+
+	diff, err := client.DiffArtifacts(ctx, left, right, insecure)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(diff)
+	if err != nil {
+		return fmt.Errorf("failed to marshal diff: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleEstimate(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			ref = positionals[0]
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	insecure := false
+	if val, ok := args.Bool("insecure"); ok {
+		insecure = val
+	}
+
+	logger.Debug("Resolved estimate options", "ref", ref, "insecure", insecure)
+
+	estimate, err := client.EstimateTransfer(ctx, ref, insecure)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(estimate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer estimate: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write transfer estimate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+)
+
+// defaultEventOperation is the operation subscribers are run with unless they
+// name another.
+const defaultEventOperation = "event"
+
+// finalizerEvents delivers events to the DS host as finalizer requests: once the
+// operation succeeds, DS runs every subscriber plugin with each event as
+// key=value arguments, the first being event=<type>.
+type finalizerEvents struct {
+	subscribers []types.FinalizerRequest
+
+	mu     sync.Mutex
+	events []porter.Event
+}
+
+// newFinalizerEvents returns the publisher for subscribers, given as name or
+// name:operation, or nil when there are none.
+func newFinalizerEvents(subscribers []string) *finalizerEvents {
+	if len(subscribers) == 0 {
+		return nil
+	}
+	events := &finalizerEvents{}
+	for _, subscriber := range subscribers {
+		name, operation, _ := strings.Cut(subscriber, ":")
+		name, operation = strings.TrimSpace(name), strings.TrimSpace(operation)
+		if name == "" {
+			continue
+		}
+		if operation == "" {
+			operation = defaultEventOperation
+		}
+		events.subscribers = append(events.subscribers, types.FinalizerRequest{Name: name, Operation: operation})
+	}
+	return events
+}
+
+func (f *finalizerEvents) Publish(_ context.Context, event porter.Event) error {
+	f.mu.Lock()
+	f.events = append(f.events, event)
+	f.mu.Unlock()
+	return nil
+}
+
+// requests returns a finalizer request per subscriber and event published.
+func (f *finalizerEvents) requests() []types.FinalizerRequest {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []types.FinalizerRequest
+	for _, event := range f.events {
+		data := event.Data()
+		args := []string{"event=" + event.Type}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, fmt.Sprintf("%s=%v", key, data[key]))
+		}
+		for _, subscriber := range f.subscribers {
+			requests = append(requests, types.FinalizerRequest{
+				Name:      subscriber.Name,
+				Operation: subscriber.Operation,
+				Args:      append([]string(nil), args...),
+			})
+		}
+	}
+	return requests
+}
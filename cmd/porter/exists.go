@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleExists(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if positionals := cleanedValues(args.Positionals()); ref == "" && len(positionals) > 0 {
+		ref = positionals[0]
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+	insecure, _ := args.Bool("insecure")
+	absent, _ := args.Bool("absent")
+	logger.Debug("Resolved exists options", "ref", ref, "absent", absent)
+
+	result, err := client.CheckExists(ctx, ref, insecure)
+	if err != nil {
+		return err
+	}
+	switch {
+	case absent && result.Exists:
+		return fmt.Errorf("%s already exists with digest %s", reference.Redact(ref), result.Digest)
+	case !absent && !result.Exists:
+		return fmt.Errorf("%s does not exist", reference.Redact(ref))
+	}
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exists result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write exists result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+// familyReference returns the reference of a `pull --family` invocation. The flag
+// either carries the reference or precedes it as a positional.
+func familyReference(args types.PluginArgs) (string, bool) {
+	value, ok := args.First("family")
+	if !ok {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || value == "true" {
+		value, _ = args.FirstAny("ref", "artifact", "arg0")
+		value = strings.TrimSpace(value)
+	}
+	return value, true
+}
+
+// handlePullFamily pulls every member of a family and, with --output, exports each
+// member into <output>/<member>. Nothing is exported unless all members were pulled.
+func handlePullFamily(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) (*porter.FamilyResult, error) {
+	ref, _ := familyReference(args)
+	if ref == "" {
+		return nil, fmt.Errorf("family reference required")
+	}
+	if _, ok := args.First("channel"); ok {
+		return nil, fmt.Errorf("--channel cannot be combined with --family")
+	}
+	if dryRun, _ := args.Bool("dry-run"); dryRun {
+		return nil, fmt.Errorf("--dry-run cannot be combined with --family")
+	}
+	if force, _ := args.Bool("force"); force {
+		return nil, fmt.Errorf("--force cannot be combined with --family")
+	}
+	if streamsToStdout(args) {
+		return nil, fmt.Errorf("-o - cannot be combined with --family")
+	}
+	format, err := outputFormat(args)
+	if err != nil {
+		return nil, err
+	}
+	insecure, _ := args.Bool("insecure")
+	requireDigest, _ := args.Bool("require-digest")
+	if err := client.CheckDigestReference(ref, requireDigest); err != nil {
+		return nil, err
+	}
+	output, _ := args.FirstAny("output", "o")
+	output = strings.TrimSpace(output)
+
+	allPlatforms, _ := args.BoolAny("all-arch")
+	platformSelections := cleanedValues(args.All("platform"))
+	platformSelections = append(platformSelections, cleanedValues(args.All("platforms"))...)
+	if allPlatforms && len(platformSelections) > 0 {
+		return nil, fmt.Errorf("--all-arch cannot be combined with --platform")
+	}
+	exportOpts, err := exportOptionsFromArgs(args, allPlatforms, platformSelections)
+	if err != nil {
+		return nil, err
+	}
+
+	family, err := client.PullFamily(ctx, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Family pull completed", "family", family.Name, "version", family.Version, "members", len(family.Members))
+
+	if output != "" {
+		if _, err := client.ExportFamily(ctx, family, output, exportOpts); err != nil {
+			return nil, err
+		}
+	}
+	for _, member := range family.Members {
+		destination := ""
+		if output != "" {
+			destination = porter.FamilyMemberDestination(output, member.Metadata[porter.AnnotationFamilyMember])
+		}
+		if err := resolveFinalizers(client, member, destination, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeFormatted(stdout, format, "family result", family, artifactTable(family.Members...)); err != nil {
+		return nil, err
+	}
+	return family, nil
+}
+
+// pushFamilyIndex publishes the family described by the JSON file at path.
+func pushFamilyIndex(ctx context.Context, client *porter.Client, path, ref string, insecure bool, format string, stdout io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read family index: %w", err)
+	}
+	var family porter.FamilyIndex
+	if err := json.Unmarshal(data, &family); err != nil {
+		return fmt.Errorf("failed to parse family index %s: %w", path, err)
+	}
+	result, err := client.PushFamilyIndex(ctx, family, ref, insecure)
+	if err != nil {
+		return err
+	}
+	return writePushResult(stdout, format, result)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+// resolveFinalizers fixes the arguments of the finalizer result requests and
+// records a preview of it on result. Arguments the artifact supplies are rendered
+// as templates; without any, an exported artifact's finalizer receives its
+// destination. destination is empty when nothing was exported.
+func resolveFinalizers(client *porter.Client, result *porter.ArtifactResult, destination string, logger hclog.Logger) error {
+	if result == nil || strings.TrimSpace(firstNonEmpty(result.Metadata, "ds.finalizer", "finalizer")) == "" {
+		return nil
+	}
+
+	resolved := ""
+	if destination != "" && len(result.ExportedFiles) > 0 {
+		resolved = destination
+		if !porter.IsRemoteDestination(destination) {
+			if abs, err := filepath.Abs(destination); err == nil {
+				resolved = abs
+			} else {
+				logger.Warn("Failed to resolve absolute path for finalizer", "path", destination, "error", err)
+			}
+			if _, err := os.Stat(resolved); err != nil {
+				logger.Warn("Finalizer path does not exist", "path", resolved, "error", err)
+			}
+		}
+	}
+
+	args := parseFinalizerArgs(strings.TrimSpace(firstNonEmpty(result.Metadata, "ds.finalizer.args", "finalizer.args")))
+	if len(args) == 0 && resolved != "" {
+		args = []string{resolved}
+	}
+	args, err := porter.RenderFinalizerArgs(args, result, resolved)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		if encoded, err := json.Marshal(args); err != nil {
+			logger.Warn("Failed to encode finalizer arguments", "args", args, "error", err)
+		} else {
+			result.Metadata["ds.finalizer.args"] = string(encoded)
+		}
+	}
+
+	result.Finalizers = nil
+	for _, request := range finalizersFromMetadata(result.Metadata) {
+		path, installed := client.FinalizerPlugin(request.Name)
+		if !installed {
+			logger.Warn("Finalizer plugin is not installed", "finalizer", request.Name)
+		}
+		result.Finalizers = append(result.Finalizers, porter.FinalizerPreview{
+			Name:       request.Name,
+			Operation:  request.Operation,
+			Args:       request.Args,
+			Installed:  installed,
+			PluginPath: path,
+		})
+	}
+	return nil
+}
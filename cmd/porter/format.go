@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with --format. JSON stays the default so automation
+// keeps parsing results; table is for people reading them in a terminal.
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+	formatYAML  = "yaml"
+	// formatQuiet is selected with -q/--quiet: one value per line, such as the
+	// digest a pull or push resolved, for shell pipelines.
+	formatQuiet = "quiet"
+	// formatTemplatePrefix introduces a Go template rendered with the result, as
+	// in --format 'template={{.Digest}} {{.Reference}}'.
+	formatTemplatePrefix = "template="
+)
+
+// outputFormat reads --format and -q/--quiet. A template is parsed here, so a
+// typo fails before the command does any work.
+func outputFormat(args types.PluginArgs) (string, error) {
+	value, ok := args.First("format")
+	if quiet, _ := args.BoolAny("quiet", "q"); quiet {
+		if ok {
+			return "", fmt.Errorf("--quiet cannot be combined with --format")
+		}
+		return formatQuiet, nil
+	}
+	if !ok {
+		return formatJSON, nil
+	}
+	if body, ok := strings.CutPrefix(strings.TrimLeft(value, " "), formatTemplatePrefix); ok {
+		if _, err := parseOutputTemplate(body); err != nil {
+			return "", err
+		}
+		return formatTemplatePrefix + body, nil
+	}
+	switch format := strings.ToLower(strings.TrimSpace(value)); format {
+	case formatJSON, formatTable, formatYAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid --format %q: expected json, table, yaml or template=<go-template>", value)
+	}
+}
+
+// outputTemplateFuncs are the functions --format templates may call besides the
+// text/template builtins.
+var outputTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+	"join":  strings.Join,
+	"short": shortDigest,
+	"size":  formatSize,
+}
+
+func parseOutputTemplate(body string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(outputTemplateFuncs).Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// writeTemplate renders the template body with value, once per element when
+// value is a list, ending each rendering with a newline.
+func writeTemplate(w io.Writer, body, what string, value interface{}) error {
+	tmpl, err := parseOutputTemplate(body)
+	if err != nil {
+		return err
+	}
+	items := []interface{}{value}
+	if list := reflect.ValueOf(value); list.Kind() == reflect.Slice {
+		items = make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = list.Index(i).Interface()
+		}
+	}
+	var buf bytes.Buffer
+	for _, item := range items {
+		if err := tmpl.Execute(&buf, item); err != nil {
+			return fmt.Errorf("failed to render %s: %w", what, err)
+		}
+		buf.WriteByte('\n')
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", what, err)
+	}
+	return nil
+}
+
+// table is the tabular form of a result: a header, its rows, and the values
+// quiet output prints.
+type table struct {
+	header []string
+	rows   [][]string
+	quiet  []string
+}
+
+// writeFormatted writes value to w in format, naming it what in errors. YAML
+// keeps the keys and field order of the JSON form; templates see the Go fields.
+func writeFormatted(w io.Writer, format, what string, value interface{}, tabular func() table) error {
+	if body, ok := strings.CutPrefix(format, formatTemplatePrefix); ok {
+		return writeTemplate(w, body, what, value)
+	}
+	if format == formatQuiet {
+		var buf bytes.Buffer
+		for _, line := range tabular().quiet {
+			buf.WriteString(line + "\n")
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", what, err)
+		}
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", what, err)
+	}
+	switch format {
+	case formatTable:
+		var buf bytes.Buffer
+		t := tabular()
+		tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to format %s: %w", what, err)
+		}
+		encoded = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	case formatYAML:
+		// JSON is YAML, so decoding it into a node keeps the field order.
+		var node yaml.Node
+		if err := yaml.Unmarshal(encoded, &node); err != nil {
+			return fmt.Errorf("failed to convert %s to YAML: %w", what, err)
+		}
+		blockStyle(&node)
+		if encoded, err = yaml.Marshal(&node); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", what, err)
+		}
+		encoded = bytes.TrimSuffix(encoded, []byte("\n"))
+	}
+	if _, err := fmt.Fprintln(w, string(encoded)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", what, err)
+	}
+	return nil
+}
+
+// blockStyle drops the flow style and quoting a node decoded from JSON carries.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// artifactTable lists artifacts one per row; quiet output prints their digests.
+func artifactTable(artifacts ...*porter.ArtifactResult) func() table {
+	return func() table {
+		t := table{header: []string{"ID", "REFERENCE", "DIGEST", "SIZE", "CACHED"}}
+		for _, artifact := range artifacts {
+			cached := "-"
+			if !artifact.CachedAt.IsZero() {
+				cached = artifact.CachedAt.Local().Format(time.DateTime)
+			}
+			t.rows = append(t.rows, []string{artifact.ID, artifact.Reference, shortDigest(artifact.Digest), formatSize(artifact.Size), cached})
+			t.quiet = append(t.quiet, artifact.Digest)
+		}
+		return t
+	}
+}
+
+// cacheTable lists cached artifacts as artifactTable does; quiet output prints
+// their cache IDs.
+func cacheTable(artifacts []*porter.ArtifactResult) func() table {
+	return func() table {
+		t := artifactTable(artifacts...)()
+		t.quiet = t.quiet[:0]
+		for _, artifact := range artifacts {
+			t.quiet = append(t.quiet, artifact.ID)
+		}
+		return t
+	}
+}
+
+// workspaceTable lists the artifacts of a workspace push one per row; quiet
+// output prints the digests of those pushed.
+func workspaceTable(result *porter.WorkspacePushResult) func() table {
+	return func() table {
+		t := table{header: []string{"NAME", "REFERENCE", "DIGEST", "ERROR"}}
+		for _, artifact := range result.Artifacts {
+			t.rows = append(t.rows, []string{artifact.Name, artifact.Reference, shortDigest(artifact.Digest), artifact.Error})
+			if artifact.Digest != "" {
+				t.quiet = append(t.quiet, artifact.Digest)
+			}
+		}
+		return t
+	}
+}
+
+// shortDigest abbreviates a digest to its algorithm and first 12 hex characters.
+func shortDigest(digest string) string {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || len(encoded) <= 12 {
+		return digest
+	}
+	return algorithm + ":" + encoded[:12]
+}
+
+// formatSize renders a byte count with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	value, suffix := float64(size)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + suffix
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleImagify(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			ref = positionals[0]
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	opts := porter.ImagifyOptions{}
+	opts.Target, _ = args.FirstAny("tag", "t")
+	opts.Target = strings.TrimSpace(opts.Target)
+	if opts.Target == "" {
+		return fmt.Errorf("target image reference required (--tag)")
+	}
+	opts.Base, _ = args.FirstAny("base")
+	opts.Base = strings.TrimSpace(opts.Base)
+	opts.Dir, _ = args.FirstAny("dir")
+	opts.Dir = strings.TrimSpace(opts.Dir)
+	opts.Entrypoint = cleanedValues(args.All("entrypoint"))
+	if val, ok := args.Bool("insecure"); ok {
+		opts.Insecure = val
+	}
+	if selection, ok := args.FirstAny("platform"); ok && strings.TrimSpace(selection) != "" {
+		platform, err := parsePlatformSelection(selection)
+		if err != nil {
+			return err
+		}
+		opts.Platform = &platform
+	}
+	logger.Debug("Resolved imagify options", "ref", ref, "target", opts.Target, "base", opts.Base, "dir", opts.Dir, "insecure", opts.Insecure)
+
+	result, err := client.Imagify(ctx, ref, opts)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal imagify result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write imagify result: %w", err)
+	}
+	return nil
+}
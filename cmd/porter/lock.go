@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleLock(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	lockPath := lockfilePath(args)
+	lock, err := porter.LoadLockfile(lockPath)
+	if err != nil {
+		return err
+	}
+
+	refs := cleanedValues(args.Positionals())
+	if len(refs) == 0 {
+		refs = lock.References()
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	insecure := false
+	if val, ok := args.Bool("insecure"); ok {
+		insecure = val
+	}
+	logger.Debug("Resolved lock options", "lockfile", lockPath, "refs", refs, "insecure", insecure)
+
+	locked := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		digest, err := client.ResolveDigest(ctx, ref, insecure)
+		if err != nil {
+			return err
+		}
+		if previous, ok := lock.Lookup(ref); ok && previous.Digest != digest {
+			logger.Info("Updating locked digest", "ref", ref, "previous", previous.Digest, "digest", digest)
+		}
+		lock.Record(ref, digest)
+		locked[ref] = digest
+	}
+
+	if err := lock.Save(lockPath); err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(map[string]interface{}{"lockfile": lockPath, "artifacts": locked})
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write lock result: %w", err)
+	}
+	return nil
+}
+
+// lockfilePath returns the lockfile selected by --lockfile, defaulting to porter.lock.
+func lockfilePath(args types.PluginArgs) string {
+	if value, ok := args.FirstAny("lockfile"); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return porter.DefaultLockfile
+}
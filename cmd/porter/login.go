@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+// passwordEnv supplies the login password when --password is not given, so it
+// stays out of shell history and process listings.
+const passwordEnv = "PORTER_REGISTRY_PASSWORD"
+
+func handleLogin(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		return fmt.Errorf("registry required")
+	}
+
+	opts := porter.LoginOptions{}
+	if val, ok := args.FirstAny("username", "u"); ok {
+		opts.Username = strings.TrimSpace(val)
+	}
+	if val, ok := args.FirstAny("password", "p"); ok {
+		opts.Password = val
+	} else {
+		opts.Password = os.Getenv(passwordEnv)
+	}
+	if opts.Password == "" {
+		return fmt.Errorf("password required: pass --password or set %s", passwordEnv)
+	}
+	if val, ok := args.Bool("insecure"); ok {
+		opts.Insecure = val
+	}
+	logger.Debug("Resolved login options", "registry", positionals[0], "username", opts.Username, "insecure", opts.Insecure)
+
+	result, err := client.Login(ctx, positionals[0], opts)
+	if err != nil {
+		return err
+	}
+	return writeLoginResult(stdout, result)
+}
+
+func handleLogout(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		return fmt.Errorf("registry required")
+	}
+	logger.Debug("Removing stored credentials", "registry", positionals[0])
+
+	result, err := client.Logout(positionals[0])
+	if err != nil {
+		return err
+	}
+	return writeLoginResult(stdout, result)
+}
+
+func writeLoginResult(stdout io.Writer, result *porter.LoginResult) error {
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal login result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write login result: %w", err)
+	}
+	return nil
+}
@@ -2,17 +2,15 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/delivery-station/porter/pkg/porter"
 	"github.com/delivery-station/porter/pkg/release"
-	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/go-hclog"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -38,9 +36,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger := hclog.New(&hclog.LoggerOptions{Name: "porter"})
-
-	porterPlugin := NewPorterPlugin(logger, version, commit, date)
+	porterPlugin := NewPorterPlugin(nil, version, commit, date)
 
 	plugin.Serve(&plugin.ServeConfig{
 		HandshakeConfig: pkgplugin.Handshake,
@@ -51,18 +47,50 @@ func main() {
 	})
 }
 
-func handlePull(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) (*porter.ArtifactResult, error) {
-	if help, ok := args.BoolAny("help", "h"); ok && help {
-		printPullUsage(stdout)
-		return nil, nil
+// runPull pulls an artifact or a family, writes the result and requests the
+// finalizers of what it pulled.
+func runPull(e *execution) error {
+	if _, ok := familyReference(e.args); ok {
+		family, err := handlePullFamily(e.ctx, e.client, e.args, e.logger, e.stdout)
+		if err != nil {
+			return err
+		}
+		for _, member := range family.Members {
+			e.finalizers = append(e.finalizers, finalizersFromMetadata(member.Metadata)...)
+		}
+		return nil
 	}
 
+	result, err := handlePull(e.ctx, e.client, e.args, e.logger, e.stdout)
+	if err != nil {
+		return err
+	}
+	// Streamed content owns stdout, so the result goes to stderr.
+	resultBuf := e.stdout
+	if streamsToStdout(e.args) {
+		resultBuf = e.stderr
+	}
+	format, _ := outputFormat(e.args)
+	if err := writeFormatted(resultBuf, format, "result", result, artifactTable(result)); err != nil {
+		return err
+	}
+	// A dry run only previews its finalizers.
+	if dryRun, _ := e.args.Bool("dry-run"); !dryRun {
+		e.finalizers = append(e.finalizers, finalizersFromMetadata(result.Metadata)...)
+	}
+	return nil
+}
+
+func handlePull(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) (*porter.ArtifactResult, error) {
 	ref, _ := args.FirstAny("ref", "artifact", "arg0")
 	ref = strings.TrimSpace(ref)
 	if ref == "" {
-		printPullUsage(stdout)
 		return nil, fmt.Errorf("artifact reference required")
 	}
+	// The result is written by the caller; an invalid format fails before the pull.
+	if _, err := outputFormat(args); err != nil {
+		return nil, err
+	}
 
 	insecure := false
 	if val, ok := args.Bool("insecure"); ok {
@@ -83,9 +111,50 @@ func handlePull(client *porter.Client, args types.PluginArgs, logger hclog.Logge
 	if allPlatforms && len(platformSelections) > 0 {
 		return nil, fmt.Errorf("--all-arch cannot be combined with --platform")
 	}
-	logger.Debug("Resolved pull options", "ref", ref, "insecure", insecure, "output", output, "all_platforms", allPlatforms, "platforms", platformSelections)
+	locked := false
+	if val, ok := args.Bool("locked"); ok {
+		locked = val
+	}
+	force, _ := args.Bool("force")
+	dryRun, _ := args.Bool("dry-run")
+	if dryRun && output == "" {
+		return nil, fmt.Errorf("--dry-run requires --output")
+	}
+	if dryRun && output == porter.StdoutDestination {
+		return nil, fmt.Errorf("--dry-run cannot be combined with -o -")
+	}
+	logger.Debug("Resolved pull options", "ref", ref, "insecure", insecure, "output", output, "all_platforms", allPlatforms, "platforms", platformSelections, "locked", locked, "force", force)
+
+	ref, err := channelReference(ctx, client, args, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+	// A channel resolves to a digest, so --channel satisfies --require-digest.
+	requireDigest, _ := args.Bool("require-digest")
+	if err := client.CheckDigestReference(ref, requireDigest); err != nil {
+		return nil, err
+	}
+
+	if locked {
+		lock, err := porter.LoadLockfile(lockfilePath(args))
+		if err != nil {
+			return nil, err
+		}
+		digest, err := client.ResolveDigest(ctx, ref, insecure)
+		if err != nil {
+			return nil, err
+		}
+		if err := lock.Verify(ref, digest); err != nil {
+			return nil, err
+		}
+		// Pull the locked digest rather than the tag, which may move before the
+		// content is downloaded.
+		if ref, err = lock.Pin(ref); err != nil {
+			return nil, err
+		}
+	}
 
-	result, err := client.PullArtifact(ref, insecure)
+	result, err := client.PullArtifactWithOptions(ctx, ref, porter.PullOptions{Insecure: insecure, Force: force})
 	if err != nil {
 		return nil, err
 	}
@@ -93,13 +162,37 @@ func handlePull(client *porter.Client, args types.PluginArgs, logger hclog.Logge
 		logger.Debug("Pull completed", "ref", ref, "digest", result.Digest, "cached", result.Cached, "cache_path", result.LocalPath)
 	}
 
+	finalizerDestination := ""
 	if output != "" {
-		exportOpts, err := buildExportOptions(allPlatforms, platformSelections)
+		exportOpts, err := exportOptionsFromArgs(args, allPlatforms, platformSelections)
 		if err != nil {
 			return nil, err
 		}
 
-		exportedPaths, err := client.ExportArtifact(result, output, exportOpts)
+		if output == porter.StdoutDestination {
+			if result.Streamed, err = streamToStdout(ctx, client, result, exportOpts, stdout); err != nil {
+				return nil, fmt.Errorf("failed to export artifact: %w", err)
+			}
+			if err := resolveFinalizers(client, result, "", logger); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+
+		if dryRun {
+			planned, err := client.PlanExport(ctx, result, output, exportOpts)
+			if err != nil {
+				return nil, err
+			}
+			result.PlannedFiles = planned
+			logger.Info("Planned export", "destination", output, "files", len(planned))
+			if err := resolveFinalizers(client, result, "", logger); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+
+		exportedPaths, err := client.ExportArtifact(ctx, result, output, exportOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to export artifact: %w", err)
 		}
@@ -109,33 +202,20 @@ func handlePull(client *porter.Client, args types.PluginArgs, logger hclog.Logge
 		}
 
 		if len(exportedPaths) > 0 {
-			if finalizerName := firstNonEmpty(result.Metadata, "ds.finalizer", "finalizer"); strings.TrimSpace(finalizerName) != "" {
-				if _, ok := result.Metadata["ds.finalizer.args"]; !ok {
-					resolved := output
-					if abs, err := filepath.Abs(output); err == nil {
-						resolved = abs
-					} else {
-						logger.Warn("Failed to resolve absolute path for finalizer", "path", output, "error", err)
-					}
-
-					if _, err := os.Stat(resolved); err != nil {
-						logger.Warn("Finalizer path does not exist", "path", resolved, "error", err)
-					}
-
-					argsPayload := []string{resolved}
-					encoded, err := json.Marshal(argsPayload)
-					if err != nil {
-						logger.Warn("Failed to encode finalizer arguments", "path", resolved, "error", err)
-						result.Metadata["ds.finalizer.args"] = resolved
-					} else {
-						result.Metadata["ds.finalizer.args"] = string(encoded)
-					}
+			finalizerDestination = output
+			if exportOpts.Versioned {
+				// Finalizers act on the version just installed.
+				if dir, err := porter.VersionedDestination(result, output, exportOpts); err == nil {
+					finalizerDestination = dir
 				}
 			}
 			logger.Debug("Exported artifact content", "paths", exportedPaths)
 		}
 	}
 
+	if err := resolveFinalizers(client, result, finalizerDestination, logger); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
@@ -153,6 +233,56 @@ func cleanedValues(values []string) []string {
 	return out
 }
 
+// exportOptionsFromArgs builds the export options of a pull from its flags.
+func exportOptionsFromArgs(args types.PluginArgs, allPlatforms bool, platformSelections []string) (porter.ExportOptions, error) {
+	exportOpts, err := buildExportOptions(allPlatforms, platformSelections)
+	if err != nil {
+		return porter.ExportOptions{}, err
+	}
+
+	if val, ok := args.Bool("shared"); ok {
+		exportOpts.Shared = val
+	}
+	if val, ok := args.Bool("full"); ok {
+		exportOpts.Full = val
+	}
+	if val, ok := args.Bool("versioned"); ok {
+		exportOpts.Versioned = val
+	}
+	if val, ok := args.FirstAny("version-name"); ok {
+		exportOpts.Version = strings.TrimSpace(val)
+	}
+	if exportOpts.Version != "" && !exportOpts.Versioned {
+		return porter.ExportOptions{}, fmt.Errorf("--version-name requires --versioned")
+	}
+	if val, ok := args.FirstAny("output-template"); ok {
+		exportOpts.OutputTemplate = strings.TrimSpace(val)
+	}
+	if val, ok := args.Bool("oci-archive"); ok && val {
+		if allPlatforms || len(platformSelections) > 0 {
+			return porter.ExportOptions{}, fmt.Errorf("--oci-archive packages every platform and cannot be combined with --platform or --all-arch")
+		}
+		exportOpts.OCIArchive = true
+	}
+	if val, ok := args.Bool("docker-archive"); ok && val {
+		if exportOpts.OCIArchive || allPlatforms {
+			return porter.ExportOptions{}, fmt.Errorf("--docker-archive writes one platform and cannot be combined with --oci-archive or --all-arch")
+		}
+		exportOpts.DockerArchive = true
+	}
+	for _, policy := range []string{porter.OverwriteReplace, porter.OverwriteSkipExisting, porter.OverwriteErrorIfExists} {
+		set, _ := args.Bool(policy)
+		if !set {
+			continue
+		}
+		if exportOpts.Overwrite != "" {
+			return porter.ExportOptions{}, fmt.Errorf("--%s cannot be combined with --%s", policy, exportOpts.Overwrite)
+		}
+		exportOpts.Overwrite = policy
+	}
+	return exportOpts, nil
+}
+
 func buildExportOptions(allPlatforms bool, selections []string) (porter.ExportOptions, error) {
 	if allPlatforms {
 		return porter.ExportOptions{AllPlatforms: true, UsePlatformSubdirs: true}, nil
@@ -203,30 +333,7 @@ func parsePlatformSelection(value string) (ocispec.Platform, error) {
 	return plat, nil
 }
 
-func printPullUsage(w io.Writer) {
-	lines := []string{
-		"Usage: ds porter pull [flags] <artifact-ref>",
-		"",
-		"Flags:",
-		"  --output, -o <path>   Export artifact to a file or directory",
-		"                         Directories receive ds-porter by default; files write the binary directly",
-		"  --platform <os/arch>  Fetch a specific platform (repeatable; e.g. linux/arm64)",
-		"  --all-arch            Fetch every platform in the index (requires directory output)",
-		"  --insecure            Allow plain HTTP connections to registries",
-		"",
-		"Behaviour:",
-		"  • Without --platform/--all-arch, the current runtime platform is exported",
-		"  • When multiple platforms are requested, artifacts are written to <dir>/<os>/<arch>/",
-		"",
-		"Examples:",
-		"  ds porter pull ghcr.io/delivery-station/porter:0.2.0 -o ./porter-bin",
-		"  ds porter pull localhost/delivery-station/porter:0.2.0 --platform linux/arm64 -o ./out",
-		"  ds porter pull ghcr.io/...:0.2.0 --all-arch -o ./artifacts",
-	}
-	writeLines(w, lines)
-}
-
-func handlePush(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+func handlePush(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
 	manifestPath, _ := args.FirstAny("manifest", "m")
 	manifestPath = strings.TrimSpace(manifestPath)
 
@@ -235,14 +342,84 @@ func handlePush(client *porter.Client, args types.PluginArgs, logger hclog.Logge
 		insecure = val
 	}
 
+	signConfig := buildSignConfig(args)
+	sboms := cleanedValues(args.All("sbom"))
+	compression, _ := args.First("compression")
+	compression = strings.ToLower(strings.TrimSpace(compression))
+	if _, err := release.ArchiveMediaType(compression); err != nil {
+		return err
+	}
+
+	tagLatest, err := tagLatestFromArgs(args)
+	if err != nil {
+		return err
+	}
+	tags := cleanedValues(args.All("tag"))
+	semverAliases, _ := args.Bool("semver-aliases")
+	metadata, err := metadataFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	format, err := outputFormat(args)
+	if err != nil {
+		return err
+	}
 	positionals := cleanedValues(args.Positionals())
 
+	if source, ok := args.First("from-cache"); ok {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			return fmt.Errorf("--from-cache requires an artifact ID or reference")
+		}
+		if manifestPath != "" || signConfig.Enabled || len(sboms) > 0 || metadata != nil {
+			return fmt.Errorf("--from-cache cannot be combined with --manifest, --sign, --sbom, or --metadata")
+		}
+		if len(positionals) < 1 {
+			return fmt.Errorf("registry reference required")
+		}
+		result, err := client.PushFromCache(ctx, source, positionals[0], porter.PushOptions{Insecure: insecure, Tags: tags, SemverAliases: semverAliases})
+		if err != nil {
+			return err
+		}
+		return writePushResult(stdout, format, result)
+	}
+
+	if familyPath, ok := args.First("family-index"); ok {
+		familyPath = strings.TrimSpace(familyPath)
+		if familyPath == "" {
+			return fmt.Errorf("--family-index requires a family index file")
+		}
+		if manifestPath != "" || signConfig.Enabled || len(sboms) > 0 || len(tags) > 0 || semverAliases || metadata != nil {
+			return fmt.Errorf("--family-index cannot be combined with --manifest, --sign, --sbom, --tag, --semver-aliases, or --metadata")
+		}
+		if len(positionals) < 1 {
+			return fmt.Errorf("registry reference required")
+		}
+		return pushFamilyIndex(ctx, client, familyPath, positionals[0], insecure, format, stdout)
+	}
+
+	if workspacePath, ok := args.First("workspace"); ok {
+		workspacePath = strings.TrimSpace(workspacePath)
+		if workspacePath == "" {
+			return fmt.Errorf("--workspace requires a workspace file")
+		}
+		if manifestPath != "" || len(positionals) > 0 {
+			return fmt.Errorf("--workspace cannot be combined with --manifest or positional arguments")
+		}
+		return pushWorkspace(ctx, client, workspacePath, args, porter.PushOptions{Insecure: insecure, Sign: signConfig, SBOMs: sboms, Compression: compression, TagLatest: tagLatest, Tags: tags, SemverAliases: semverAliases, Metadata: metadata}, format, stdout)
+	}
+
 	if manifestPath != "" {
 		if len(positionals) < 1 {
 			return fmt.Errorf("registry reference required")
 		}
+		if format != formatJSON && format != formatQuiet {
+			return fmt.Errorf("--format cannot be combined with --manifest, which reports progress as text")
+		}
 		ref := positionals[0]
-		return handleMultiArchPush(client, ref, manifestPath, logger, stdout, insecure)
+		opts := porter.PushOptions{Insecure: insecure, Sign: signConfig, SBOMs: sboms, Compression: compression, TagLatest: tagLatest, Tags: tags, SemverAliases: semverAliases, Metadata: metadata}
+		return handleMultiArchPush(ctx, client, ref, manifestPath, stdout, opts, format == formatQuiet)
 	}
 
 	if len(positionals) < 2 {
@@ -252,19 +429,98 @@ func handlePush(client *porter.Client, args types.PluginArgs, logger hclog.Logge
 	path := positionals[0]
 	ref := positionals[1]
 
-	result, err := client.PushArtifact(path, ref, insecure)
+	result, err := client.PushArtifact(ctx, path, ref, porter.PushOptions{Insecure: insecure, Sign: signConfig, SBOMs: sboms, Compression: compression, TagLatest: tagLatest, Tags: tags, SemverAliases: semverAliases, Metadata: metadata})
 	if err != nil {
 		return err
 	}
+	return writePushResult(stdout, format, result)
+}
 
-	output, err := json.Marshal(result)
+// pushWorkspace publishes every artifact of the workspace file at path and writes
+// the consolidated summary. It fails when any artifact failed.
+func pushWorkspace(ctx context.Context, client *porter.Client, path string, args types.PluginArgs, opts porter.PushOptions, format string, stdout io.Writer) error {
+	workspace, err := porter.LoadWorkspace(path)
 	if err != nil {
-		return fmt.Errorf("failed to marshal push result: %w", err)
+		return err
 	}
-	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
-		return fmt.Errorf("failed to write push result: %w", err)
+	if value, ok := args.First("parallel"); ok {
+		parallel, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || parallel < 1 {
+			return fmt.Errorf("--parallel must be a positive integer")
+		}
+		workspace.Parallelism = parallel
 	}
-	return nil
+
+	result, pushErr := client.PushWorkspace(ctx, workspace, opts)
+	if result == nil {
+		return pushErr
+	}
+	if err := writeFormatted(stdout, format, "workspace push result", result, workspaceTable(result)); err != nil {
+		return err
+	}
+	return pushErr
+}
+
+func writePushResult(stdout io.Writer, format string, result *porter.ArtifactResult) error {
+	return writeFormatted(stdout, format, "push result", result, artifactTable(result))
+}
+
+// tagLatestFromArgs reads --tag-latest and --no-latest; nil leaves the choice to
+// the push.tag_latest setting.
+func tagLatestFromArgs(args types.PluginArgs) (*bool, error) {
+	tagLatest, tagSet := args.Bool("tag-latest")
+	noLatest, noSet := args.Bool("no-latest")
+	switch {
+	case tagSet && noSet && tagLatest == noLatest:
+		return nil, fmt.Errorf("--tag-latest and --no-latest cannot be combined")
+	case noSet:
+		value := !noLatest
+		return &value, nil
+	case tagSet:
+		return &tagLatest, nil
+	}
+	return nil, nil
+}
+
+// metadataFromArgs reads the repeatable --metadata key=value flag into the
+// metadata packed into the platform manifest configs, or nil without it.
+func metadataFromArgs(args types.PluginArgs) (*release.ArtifactMetadata, error) {
+	values := cleanedValues(args.All("metadata"))
+	if len(values) == 0 {
+		return nil, nil
+	}
+	metadata := &release.ArtifactMetadata{}
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid metadata %q, expected key=value", value)
+		}
+		if err := release.SetMetadataField(metadata, strings.TrimSpace(key), strings.TrimSpace(val)); err != nil {
+			return nil, err
+		}
+	}
+	return metadata, metadata.Validate()
+}
+
+// buildSignConfig reads the signing flags. A --sign-key selects key-file signing;
+// otherwise keyless signing uses the ambient OIDC identity of the environment.
+func buildSignConfig(args types.PluginArgs) release.SignConfig {
+	cfg := release.SignConfig{}
+	if val, ok := args.Bool("sign"); ok {
+		cfg.Enabled = val
+	}
+	if key, ok := args.First("sign-key"); ok && strings.TrimSpace(key) != "" {
+		cfg.Enabled = true
+		cfg.KeyPath = strings.TrimSpace(key)
+		cfg.KeyPassword = os.Getenv("COSIGN_PASSWORD")
+	}
+	if val, ok := args.First("fulcio-url"); ok {
+		cfg.FulcioURL = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("rekor-url"); ok {
+		cfg.RekorURL = strings.TrimSpace(val)
+	}
+	return cfg
 }
 
 func writeLines(w io.Writer, lines []string) {
@@ -276,51 +532,49 @@ func writeLines(w io.Writer, lines []string) {
 	}
 }
 
-func handleMultiArchPush(client *porter.Client, ref, manifestPath string, logger hclog.Logger, stdout io.Writer, insecure bool) error {
-	// Parse registry and repository from ref
-	// ref format: registry/repo[:tag]
-	// We need to split this for ReleaseConfig
-	// Actually, let's just pass the full ref and let the pusher handle it
-
-	parsedRef, err := name.ParseReference(ref)
-	if err != nil {
-		return fmt.Errorf("invalid reference %q: %w", ref, err)
-	}
-
-	username, password := client.ResolveCredentials(parsedRef.Context().RegistryStr())
-
-	// Config
-	config := release.ReleaseConfig{
-		Reference:    ref,
-		Username:     username,
-		Password:     password,
-		ManifestPath: manifestPath,
-		TagLatest:    true, // Default to true
-		Insecure:     insecure,
+func handleMultiArchPush(ctx context.Context, client *porter.Client, ref, manifestPath string, stdout io.Writer, opts porter.PushOptions, quiet bool) error {
+	if !quiet {
+		_, err := client.PushManifest(ctx, manifestPath, ref, stdout, opts)
+		return err
 	}
-
-	pusher, err := release.NewPusher(config)
+	// Quiet pushes drop the progress and print the digest of the index.
+	result, err := client.PushManifest(ctx, manifestPath, ref, io.Discard, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create pusher: %w", err)
+		return err
 	}
-
-	return pusher.Push(context.Background(), stdout)
+	if _, err := fmt.Fprintln(stdout, result.Digest); err != nil {
+		return fmt.Errorf("failed to write push result: %w", err)
+	}
+	return nil
 }
 
-func handleList(client *porter.Client, _ types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
-	artifacts, err := client.ListCachedArtifacts()
+func handleList(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	format, err := outputFormat(args)
 	if err != nil {
 		return err
 	}
-
-	output, err := json.Marshal(artifacts)
+	var artifacts []*porter.ArtifactResult
+	if rebuild, _ := args.Bool("rebuild-index"); rebuild {
+		logger.Debug("Rebuilding cache index")
+		artifacts, err = client.RebuildCacheIndex()
+	} else {
+		artifacts, err = client.ListCachedArtifacts()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal artifact list: %w", err)
+		return err
 	}
-	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
-		return fmt.Errorf("failed to write artifact list: %w", err)
+
+	if family, ok := args.First("family"); ok && strings.TrimSpace(family) != "" {
+		members := make([]*porter.ArtifactResult, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			if artifact.Metadata[porter.AnnotationFamily] == strings.TrimSpace(family) {
+				members = append(members, artifact)
+			}
+		}
+		artifacts = members
 	}
-	return nil
+
+	return writeFormatted(stdout, format, "artifact list", artifacts, cacheTable(artifacts))
 }
 
 func handleExecutePlugin(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
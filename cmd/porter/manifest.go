@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleManifest(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) == 0 {
+		printUsage(stdout, "manifest")
+		return nil
+	}
+
+	var result interface{}
+	switch positionals[0] {
+	case "init":
+		dir, _ := args.FirstAny("dir")
+		dir = strings.TrimSpace(dir)
+		if dir == "" && len(positionals) > 1 {
+			dir = positionals[1]
+		}
+		if dir == "" {
+			dir = "dist"
+		}
+		opts := porter.ManifestInitOptions{}
+		opts.Output, _ = args.FirstAny("output", "o")
+		opts.Output = strings.TrimSpace(opts.Output)
+		opts.Name, _ = args.First("name")
+		opts.Name = strings.TrimSpace(opts.Name)
+		opts.ArtifactType, _ = args.First("artifact-type")
+		opts.ArtifactType = strings.TrimSpace(opts.ArtifactType)
+		opts.Force, _ = args.Bool("force")
+		logger.Debug("Resolved manifest init options", "dir", dir, "output", opts.Output, "name", opts.Name, "force", opts.Force)
+
+		var err error
+		if result, err = porter.InitManifest(dir, opts); err != nil {
+			return err
+		}
+	case "validate":
+		path := porter.DefaultManifestFile
+		if len(positionals) > 1 {
+			path = positionals[1]
+		}
+		logger.Debug("Validating manifest", "path", path)
+
+		// Schema errors name each problem with its line; stdout is dropped on error.
+		manifest, err := release.LoadManifest(path)
+		if err != nil {
+			return err
+		}
+		result = struct {
+			Path      string                  `json:"path"`
+			Valid     bool                    `json:"valid"`
+			Manifests []release.ManifestEntry `json:"manifests"`
+		}{Path: path, Valid: true, Manifests: manifest.Manifests}
+	default:
+		return fmt.Errorf("unknown manifest subcommand: %s", positionals[0])
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest result: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write manifest result: %w", err)
+	}
+	return nil
+}
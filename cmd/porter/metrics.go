@@ -0,0 +1,14 @@
+package main
+
+import (
+	"io"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleMetrics(client *porter.Client, _ types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	logger.Debug("Writing operation metrics")
+	return client.WriteMetrics(stdout)
+}
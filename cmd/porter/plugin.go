@@ -23,29 +23,39 @@ type PorterPlugin struct {
 	date        string
 	logCloser   io.Closer
 	lastLogging porter.NormalizedLogging
+	// redactor scrubs secrets from everything the plugin logs and the errors it
+	// returns; each run registers the secrets of its configuration.
+	redactor *porter.Redactor
 }
 
+// NewPorterPlugin returns the plugin. A nil logger logs to stderr through the
+// plugin's redactor.
 func NewPorterPlugin(logger hclog.Logger, version, commit, date string) *PorterPlugin {
+	redactor := porter.NewRedactor()
+	if logger == nil {
+		logger = hclog.New(&hclog.LoggerOptions{Name: "porter", Output: redactor.Writer(os.Stderr)})
+	}
 	return &PorterPlugin{
-		logger:  logger,
-		version: version,
-		commit:  commit,
-		date:    date,
+		logger:   logger,
+		version:  version,
+		commit:   commit,
+		date:     date,
+		redactor: redactor,
 	}
 }
 
 func (p *PorterPlugin) GetManifest(ctx context.Context) (*types.PluginInfo, error) {
+	var commands []types.PluginCommand
+	for _, cmd := range porterCommands() {
+		if !cmd.hidden {
+			commands = append(commands, types.PluginCommand{Name: cmd.name, Description: cmd.summary})
+		}
+	}
 	return &types.PluginInfo{
 		Name:        "porter",
 		Version:     p.version,
 		Description: "Fetch and deliver OCI artifacts",
-		Commands: []types.PluginCommand{
-			{Name: "pull", Description: "Pull an OCI artifact"},
-			{Name: "push", Description: "Push an OCI artifact"},
-			{Name: "list", Description: "List cached artifacts"},
-			{Name: "execute-plugin", Description: "Execute a plugin contained in an artifact"},
-			{Name: "version", Description: "Display plugin version information"},
-		},
+		Commands:    commands,
 		Platform: types.PluginPlatform{
 			OS:   []string{"linux", "darwin", "windows"},
 			Arch: []string{"amd64", "arm64"},
@@ -72,6 +82,20 @@ func (p *PorterPlugin) Execute(ctx context.Context, operation string, args []str
 		p.logger.Warn("Received unknown log format from DS", "format", config.Logging.Format)
 	}
 
+	// Arguments are checked before anything runs; errors are reported once the
+	// client is up, with logging configured.
+	cmd, known := lookupCommand(operation)
+	var parsedArgs types.PluginArgs
+	var parseErr error
+	if known {
+		parsedArgs, parseErr = cmd.parseArgs(args)
+	}
+
+	// Quiet output carries only the result, so only errors are logged.
+	if quiet, _ := parsedArgs.Bool("quiet"); quiet {
+		normalizedLogging.Level = "error"
+	}
+
 	outputTarget := logOutputTarget(normalizedLogging.Output)
 	p.logger.Debug("Applying DS logging configuration", "level", normalizedLogging.Level, "format", normalizedLogging.Format, "output", outputTarget)
 
@@ -83,12 +107,18 @@ func (p *PorterPlugin) Execute(ctx context.Context, operation string, args []str
 	config.Logging.Level = normalizedLogging.Level
 	config.Logging.Format = normalizedLogging.Format
 	config.Logging.Output = normalizedLogging.Output
+	config.Version = p.version
+	config.Redactor = p.redactor
+	events := newFinalizerEvents(config.EventSubscribers)
+	if events != nil {
+		config.Events = events
+	}
 
 	client, err := porter.NewClient(config, p.logger)
 	if err != nil {
 		return &types.ExecutionResult{
 			ExitCode: 1,
-			Error:    fmt.Sprintf("Failed to create porter client: %v", err),
+			Error:    p.redactor.Redact(fmt.Sprintf("Failed to create porter client: %v", err)),
 		}, nil
 	}
 	defer func() {
@@ -97,64 +127,71 @@ func (p *PorterPlugin) Execute(ctx context.Context, operation string, args []str
 		}
 	}()
 
-	// Capture stdout
-	var stdoutBuf bytes.Buffer
-	var errExec error
-	finalizers := []types.FinalizerRequest{}
-	parsedArgs := types.NewPluginArgs(args)
+	exec := &execution{
+		client:     client,
+		args:       parsedArgs,
+		logger:     p.logger,
+		build:      porter.BuildInfo{Version: p.version, Commit: p.commit, Date: p.date},
+		stdout:     &bytes.Buffer{},
+		stderr:     &bytes.Buffer{},
+		finalizers: []types.FinalizerRequest{},
+		ctx:        ctx,
+	}
 	p.logger.Debug("Executing porter operation", "operation", operation, "arg_count", len(args))
 
-	switch operation {
-	case "pull":
-		var pullResult *porter.ArtifactResult
-		pullResult, errExec = handlePull(client, parsedArgs, p.logger, &stdoutBuf)
-		if errExec == nil && pullResult != nil {
-			jsonOutput, marshalErr := json.Marshal(pullResult)
-			if marshalErr != nil {
-				errExec = fmt.Errorf("failed to marshal result: %w", marshalErr)
-			} else {
-				stdoutBuf.Write(jsonOutput)
-				stdoutBuf.WriteByte('\n')
-				finalizers = append(finalizers, finalizersFromMetadata(pullResult.Metadata)...)
-			}
-		}
-	case "push":
-		errExec = handlePush(client, parsedArgs, p.logger, &stdoutBuf)
-	case "list":
-		errExec = handleList(client, parsedArgs, p.logger, &stdoutBuf)
-	case "execute-plugin":
-		errExec = handleExecutePlugin(client, parsedArgs, p.logger, &stdoutBuf)
-	case "help":
-		stdoutBuf.WriteString(`Available commands:
-  pull <artifact>    Pull an artifact
-  push <artifact>    Push an artifact
-  list               List artifacts
-  execute-plugin     Execute a plugin
-  version            Show plugin version
-`)
-	case "version":
-		stdoutBuf.WriteString(fmt.Sprintf("porter version %s\n  commit: %s\n  built:  %s", p.version, p.commit, p.date))
-	default:
+	var errExec error
+	switch help, _ := parsedArgs.Bool("help"); {
+	case !known:
 		errExec = fmt.Errorf("unknown operation: %s", operation)
+	case parseErr != nil:
+		errExec = parseErr
+	case help:
+		cmd.writeUsage(exec.stdout)
+	default:
+		if auditOnly, _ := parsedArgs.Bool("audit-only"); auditOnly && auditsOperation(operation, parsedArgs) {
+			p.logger.Debug("Simulating operation in audit-only mode", "operation", operation)
+			errExec = handleAuditOnly(exec.ctx, client, operation, parsedArgs, p.logger, exec.stdout)
+			break
+		}
+		errExec = cmd.run(exec)
 	}
 
 	if errExec != nil {
-		return &types.ExecutionResult{
+		return p.sealResult(config, &types.ExecutionResult{
 			ExitCode: 1,
-			Error:    errExec.Error(),
-		}, nil
+			Error:    p.redactor.Redact(errExec.Error()),
+		}), nil
 	}
 
-	return &types.ExecutionResult{
-		Stdout:     stdoutBuf.String(),
+	exec.finalizers = append(exec.finalizers, events.requests()...)
+	return p.sealResult(config, &types.ExecutionResult{
+		Stdout:     exec.stdout.String(),
+		Stderr:     p.redactor.Redact(exec.stderr.String()),
 		ExitCode:   0,
-		Finalizers: finalizers,
-	}, nil
+		Finalizers: exec.finalizers,
+	}), nil
+}
+
+// sealResult signs the result with the host-provided key so DS can detect tampering
+// by components relaying plugin output. Results are returned unchanged when no key is configured.
+func (p *PorterPlugin) sealResult(config *porter.Config, result *types.ExecutionResult) *types.ExecutionResult {
+	key, err := porter.DecodeResultSigningKey(config.ResultSigningKey)
+	if err == nil {
+		err = porter.SignExecutionResult(result, key)
+	}
+	if err != nil {
+		p.logger.Error("Failed to sign execution result", "error", err)
+		return &types.ExecutionResult{
+			ExitCode: 1,
+			Error:    fmt.Sprintf("failed to sign execution result: %v", err),
+		}
+	}
+	return result
 }
 
 func (p *PorterPlugin) applyLoggingConfig(normalized porter.NormalizedLogging) error {
 	if p.logger == nil {
-		logger, closer, err := newLoggerForConfig(normalized)
+		logger, closer, err := newLoggerForConfig(normalized, p.redactor)
 		if err != nil {
 			return err
 		}
@@ -175,7 +212,7 @@ func (p *PorterPlugin) applyLoggingConfig(normalized porter.NormalizedLogging) e
 		return nil
 	}
 
-	logger, closer, err := newLoggerForConfig(normalized)
+	logger, closer, err := newLoggerForConfig(normalized, p.redactor)
 	if err != nil {
 		return err
 	}
@@ -191,7 +228,7 @@ func (p *PorterPlugin) applyLoggingConfig(normalized porter.NormalizedLogging) e
 	return nil
 }
 
-func newLoggerForConfig(normalized porter.NormalizedLogging) (hclog.Logger, io.Closer, error) {
+func newLoggerForConfig(normalized porter.NormalizedLogging, redactor *porter.Redactor) (hclog.Logger, io.Closer, error) {
 	writer, closer, err := resolveLogOutput(normalized.Output)
 	if err != nil {
 		return nil, nil, err
@@ -204,7 +241,7 @@ func newLoggerForConfig(normalized porter.NormalizedLogging) (hclog.Logger, io.C
 
 	opts := &hclog.LoggerOptions{
 		Name:       "porter",
-		Output:     writer,
+		Output:     redactor.Writer(writer),
 		Level:      lvl,
 		JSONFormat: normalized.IsJSON(),
 		Color:      hclog.AutoColor,
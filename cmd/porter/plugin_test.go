@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/hashicorp/go-hclog"
 )
 
@@ -141,3 +149,369 @@ func TestPorterPlugin_Execute_Version(t *testing.T) {
 		t.Fatalf("expected logger level debug, got %s", plugin.logger.GetLevel())
 	}
 }
+
+func TestPorterPlugin_Execute_VersionJSON(t *testing.T) {
+	plugin := NewPorterPlugin(hclog.NewNullLogger(), "0.1.0", "test-commit", "test-date")
+
+	result, err := plugin.Execute(newHostConfigContext(t), "version", []string{"json=true"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", result.ExitCode, result.Error)
+	}
+
+	var report porter.VersionReport
+	if err := json.Unmarshal([]byte(result.Stdout), &report); err != nil {
+		t.Fatalf("expected JSON report, got %q: %v", result.Stdout, err)
+	}
+	if report.Version != "0.1.0" || report.Commit != "test-commit" || report.Date != "test-date" {
+		t.Fatalf("unexpected build info %+v", report.BuildInfo)
+	}
+	if _, ok := report.Dependencies["oras.land/oras-go/v2"]; !ok {
+		t.Fatalf("expected ORAS version in %v", report.Dependencies)
+	}
+	if report.CacheFormatVersion != porter.CacheFormatVersion || report.ManifestSchemaVersion != 2 {
+		t.Fatalf("unexpected format versions %+v", report)
+	}
+}
+
+func TestPorterPlugin_Execute_SignsResult(t *testing.T) {
+	logger := hclog.New(&hclog.LoggerOptions{Name: "test", Level: hclog.Info})
+	plugin := NewPorterPlugin(logger, "0.1.0", "test-commit", "test-date")
+
+	provider := &stubHostConfigProvider{
+		cfg: &types.Config{
+			Cache: types.CacheConfig{Dir: t.TempDir()},
+			Plugins: types.PluginsConfig{
+				Settings: map[string]map[string]interface{}{
+					"porter": {"result_signing_key": "shared-secret"},
+				},
+			},
+		},
+	}
+	ctx := types.WithHostConfigProvider(context.Background(), provider)
+
+	result, err := plugin.Execute(ctx, "version", []string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	verified, err := porter.VerifyExecutionResult(result, []byte("shared-secret"))
+	if err != nil {
+		t.Fatalf("expected signed result, got %v", err)
+	}
+	if !strings.Contains(verified.Stdout, "porter version 0.1.0") {
+		t.Fatalf("unexpected stdout %q", verified.Stdout)
+	}
+}
+
+func TestPorterPlugin_Execute_AuditOnly(t *testing.T) {
+	logger := hclog.New(&hclog.LoggerOptions{Name: "test", Level: hclog.Info})
+	plugin := NewPorterPlugin(logger, "0.1.0", "test-commit", "test-date")
+	ctx := newHostConfigContext(t)
+
+	dir := t.TempDir()
+	result, err := plugin.Execute(ctx, "versions", []string{"audit-only=true", "arg0=" + dir, "use=1.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", result.ExitCode, result.Error)
+	}
+
+	var record porter.AuditRecord
+	if err := json.Unmarshal([]byte(result.Stdout), &record); err != nil {
+		t.Fatalf("expected an audit record, got %q: %v", result.Stdout, err)
+	}
+	if !record.Simulated || record.Allowed {
+		t.Fatalf("expected a simulated, refused record, got %+v", record)
+	}
+	if len(record.Changes) != 1 || record.Changes[0].Action != porter.ChangeLink {
+		t.Fatalf("expected a planned link change, got %+v", record.Changes)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, porter.CurrentVersionLink)); !os.IsNotExist(err) {
+		t.Fatalf("audit-only must not switch the current link, got %v", err)
+	}
+}
+
+func TestPorterPlugin_Execute_PushManifestWritesAuditLog(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	ref := strings.TrimPrefix(server.URL, "http://") + "/team/tool:1.0.0"
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("linux tool"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(dir, porter.DefaultManifestFile)
+	if err := os.WriteFile(manifestPath, []byte("manifests:\n  - platform: linux/amd64\n    path: tool\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	provider := &stubHostConfigProvider{cfg: &types.Config{Cache: types.CacheConfig{Dir: cacheDir}}}
+	ctx := types.WithHostConfigProvider(context.Background(), provider)
+	plugin := NewPorterPlugin(hclog.NewNullLogger(), "0.1.0", "test-commit", "test-date")
+	result, err := plugin.Execute(ctx, "push", []string{"manifest=" + manifestPath, "insecure=true", "quiet", "arg0=" + ref})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", result.ExitCode, result.Error)
+	}
+	digest := strings.TrimSpace(result.Stdout)
+
+	data, err := os.ReadFile(filepath.Join(cacheDir, "porter", porter.AuditLogFile))
+	if err != nil {
+		t.Fatalf("expected an audit log: %v", err)
+	}
+	var entry porter.AuditLogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("expected one audit line, got %q: %v", data, err)
+	}
+	if entry.Operation != "push" || entry.Outcome != porter.OutcomeSuccess || entry.Reference != ref || entry.Digest != digest {
+		t.Fatalf("unexpected audit entry %+v for %s@%s", entry, ref, digest)
+	}
+}
+
+func TestPorterPlugin_Execute_CommandHelp(t *testing.T) {
+	plugin := NewPorterPlugin(hclog.NewNullLogger(), "0.1.0", "test-commit", "test-date")
+
+	result, err := plugin.Execute(newHostConfigContext(t), "cat", []string{"h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", result.ExitCode, result.Error)
+	}
+	for _, want := range []string{"Usage: ds porter cat [flags] <ref>", "--layer n", "--platform os/arch", "Examples:"} {
+		if !strings.Contains(result.Stdout, want) {
+			t.Fatalf("expected usage to contain %q, got %q", want, result.Stdout)
+		}
+	}
+}
+
+func TestPorterPlugin_Execute_Completion(t *testing.T) {
+	plugin := NewPorterPlugin(hclog.NewNullLogger(), "0.1.0", "test-commit", "test-date")
+
+	for shell, wants := range map[string][]string{
+		"bash": {"complete -F _ds_porter ds", "pull push list", "--from-cache) __ds_porter_complete 'ids' ;;"},
+		"fish": {"-a pull -d 'Pull an OCI artifact'", "-l compression -x -a 'gzip zstd'", "__ds_porter_values registries /"},
+	} {
+		result, err := plugin.Execute(newHostConfigContext(t), "completion", []string{"arg0=" + shell})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.ExitCode != 0 {
+			t.Fatalf("expected exit code 0 for %s, got %d: %s", shell, result.ExitCode, result.Error)
+		}
+		for _, want := range wants {
+			if !strings.Contains(result.Stdout, want) {
+				t.Fatalf("expected the %s script to contain %q", shell, want)
+			}
+		}
+	}
+}
+
+func TestCommandParseArgs(t *testing.T) {
+	pull, _ := lookupCommand("pull")
+	// DS binds the reference after --insecure to the flag.
+	args, err := pull.parseArgs([]string{"insecure=ghcr.io/acme/tool:1.0", "o=./out", "platforms=linux/arm64", "platform=linux/amd64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ref, _ := args.Positional(0); ref != "ghcr.io/acme/tool:1.0" {
+		t.Fatalf("expected the reference as positional, got %q", ref)
+	}
+	if insecure, _ := args.Bool("insecure"); !insecure {
+		t.Fatalf("expected --insecure to be set")
+	}
+	if output, _ := args.First("output"); output != "./out" {
+		t.Fatalf("expected -o under --output, got %q", output)
+	}
+	if platforms := args.All("platform"); len(platforms) != 2 || platforms[0] != "linux/arm64" {
+		t.Fatalf("expected both platforms, got %v", platforms)
+	}
+
+	if _, err := pull.parseArgs([]string{"arg0=ghcr.io/acme/tool:1.0", "outptu=./out"}); err == nil || !strings.Contains(err.Error(), "unknown flag: --outptu") {
+		t.Fatalf("expected an unknown flag error, got %v", err)
+	}
+	if _, err := pull.parseArgs([]string{"arg0=ghcr.io/acme/tool:1.0", "arg1=./out"}); err == nil || !strings.Contains(err.Error(), `unexpected argument "./out"`) {
+		t.Fatalf("expected an unexpected argument error, got %v", err)
+	}
+}
+
+func TestResolveFinalizersPreviewsTemplatedArgs(t *testing.T) {
+	pluginDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(pluginDir, "ds-uploader"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+	client, err := porter.NewClient(&porter.Config{CacheDir: t.TempDir(), PluginsDir: pluginDir}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	output := t.TempDir()
+	result := &porter.ArtifactResult{
+		Reference:     "ghcr.io/team/tool:2.0.0",
+		ExportedFiles: []string{filepath.Join(output, "tool")},
+		Metadata: map[string]string{
+			"ds.finalizer":           "uploader",
+			"ds.finalizer.operation": "publish",
+			"ds.finalizer.args":      `["{{.Path}}/tool", "--version={{.Version}}"]`,
+		},
+	}
+	if err := resolveFinalizers(client, result, output, hclog.NewNullLogger()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Finalizers) != 1 {
+		t.Fatalf("expected one finalizer preview, got %+v", result.Finalizers)
+	}
+	preview := result.Finalizers[0]
+	if preview.Name != "uploader" || preview.Operation != "publish" || !preview.Installed {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	expected := []string{output + "/tool", "--version=2.0.0"}
+	if strings.Join(preview.Args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected args %v, got %v", expected, preview.Args)
+	}
+	requests := finalizersFromMetadata(result.Metadata)
+	if len(requests) != 1 || strings.Join(requests[0].Args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("finalizer request must match the preview, got %+v", requests)
+	}
+}
+
+func TestResolveFinalizersDefaultsToDestination(t *testing.T) {
+	client, err := porter.NewClient(&porter.Config{CacheDir: t.TempDir()}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	output := t.TempDir()
+	result := &porter.ArtifactResult{
+		ExportedFiles: []string{filepath.Join(output, "tool")},
+		Metadata:      map[string]string{"ds.finalizer": "uploader"},
+	}
+	if err := resolveFinalizers(client, result, output, hclog.NewNullLogger()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Finalizers) != 1 {
+		t.Fatalf("expected one finalizer preview, got %+v", result.Finalizers)
+	}
+	preview := result.Finalizers[0]
+	if preview.Installed || preview.Operation != "upload" || len(preview.Args) != 1 || preview.Args[0] != output {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+}
+
+func TestWriteFormatted(t *testing.T) {
+	artifact := &porter.ArtifactResult{
+		ID:        "abc123",
+		Reference: "ghcr.io/team/tool:1.0",
+		Digest:    "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Size:      2048,
+		Metadata:  map[string]string{"version": "1.0", "signed": "true"},
+	}
+
+	var out strings.Builder
+	if err := writeFormatted(&out, formatTable, "artifact list", []*porter.ArtifactResult{artifact}, artifactTable(artifact)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID ") || !strings.Contains(lines[1], "sha256:0123456789ab  2.0 KiB") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writeFormatted(&out, formatYAML, "artifact", artifact, artifactTable(artifact)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"id: abc123\n", "size: 2048\n", `version: "1.0"`, `signed: "true"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected YAML to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Index(out.String(), "id:") > strings.Index(out.String(), "reference:") {
+		t.Fatalf("expected YAML to keep the JSON field order, got:\n%s", out.String())
+	}
+}
+
+func TestWriteFormattedTemplate(t *testing.T) {
+	artifacts := []*porter.ArtifactResult{
+		{ID: "a1", Reference: "ghcr.io/team/tool:1.0", Digest: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{ID: "b2", Reference: "ghcr.io/team/tool:2.0", Digest: "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"},
+	}
+	format, err := outputFormat(types.NewPluginArgs([]string{"format=template={{.ID}} {{short .Digest}} {{.Reference}}"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := writeFormatted(&out, format, "artifact list", artifacts, artifactTable(artifacts...)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "a1 sha256:0123456789ab ghcr.io/team/tool:1.0\nb2 sha256:fedcba987654 ghcr.io/team/tool:2.0\n"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+
+	if _, err := outputFormat(types.NewPluginArgs([]string{"format=template={{.ID"})); err == nil || !strings.Contains(err.Error(), "failed to parse --format template") {
+		t.Fatalf("expected a template parse error, got %v", err)
+	}
+}
+
+func TestWriteFormattedQuiet(t *testing.T) {
+	artifacts := []*porter.ArtifactResult{
+		{ID: "a1", Digest: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{ID: "b2", Digest: "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"},
+	}
+	format, err := outputFormat(types.NewPluginArgs([]string{"q"}))
+	if err != nil || format != formatQuiet {
+		t.Fatalf("expected quiet format, got %q, %v", format, err)
+	}
+
+	var out strings.Builder
+	if err := writeFormatted(&out, format, "artifact list", artifacts, cacheTable(artifacts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "a1\nb2\n" {
+		t.Fatalf("expected cache IDs, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := writeFormatted(&out, format, "result", artifacts[0], artifactTable(artifacts[0])); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != artifacts[0].Digest+"\n" {
+		t.Fatalf("expected the digest, got %q", out.String())
+	}
+
+	if _, err := outputFormat(types.NewPluginArgs([]string{"quiet", "format=yaml"})); err == nil {
+		t.Fatalf("expected --quiet and --format to conflict")
+	}
+}
+
+func TestFinalizerEventsRequestSubscribers(t *testing.T) {
+	events := newFinalizerEvents([]string{"notify", "inventory:record"})
+	_ = events.Publish(context.Background(), porter.Event{Type: porter.EventArtifactPulled, Reference: "ghcr.io/team/tool:1.0.0", Digest: "sha256:abc", Size: 11})
+
+	requests := events.requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected a request per subscriber, got %+v", requests)
+	}
+	if requests[0].Name != "notify" || requests[0].Operation != defaultEventOperation {
+		t.Fatalf("unexpected first request: %+v", requests[0])
+	}
+	if requests[1].Name != "inventory" || requests[1].Operation != "record" {
+		t.Fatalf("unexpected second request: %+v", requests[1])
+	}
+	args := strings.Join(requests[0].Args, " ")
+	if !strings.HasPrefix(args, "event=artifact.pulled ") || !strings.Contains(args, "ref=ghcr.io/team/tool:1.0.0") || !strings.Contains(args, "size=11") {
+		t.Fatalf("unexpected event arguments: %s", args)
+	}
+	if newFinalizerEvents(nil).requests() != nil {
+		t.Fatalf("expected no requests without subscribers")
+	}
+}
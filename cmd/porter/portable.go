@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleSave(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	positionals := cleanedValues(args.Positionals())
+	if len(positionals) < 1 {
+		return fmt.Errorf("cached artifact ID or reference required")
+	}
+	target := positionals[0]
+	output, _ := args.FirstAny("output", "o")
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return fmt.Errorf("--output is required: the file to write the portable archive to")
+	}
+	logger.Debug("Resolved save options", "target", target, "output", output)
+
+	archive, err := client.SaveArtifact(target, output)
+	if err != nil {
+		return err
+	}
+	return writePortableArchive(stdout, archive)
+}
+
+func handleLoad(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	input, _ := args.FirstAny("input", "i")
+	input = strings.TrimSpace(input)
+	if input == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			input = positionals[0]
+		}
+	}
+	if input == "" {
+		return fmt.Errorf("portable archive required")
+	}
+	logger.Debug("Resolved load options", "input", input)
+
+	archive, err := client.LoadArtifact(input)
+	if err != nil {
+		return err
+	}
+	return writePortableArchive(stdout, archive)
+}
+
+func writePortableArchive(w io.Writer, archive *porter.PortableArchive) error {
+	output, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("failed to marshal portable archive: %w", err)
+	}
+	if _, err := fmt.Fprintln(w, string(output)); err != nil {
+		return fmt.Errorf("failed to write portable archive: %w", err)
+	}
+	return nil
+}
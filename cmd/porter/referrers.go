@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleReferrers(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			ref = positionals[0]
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("artifact reference required")
+	}
+
+	insecure := false
+	if val, ok := args.Bool("insecure"); ok {
+		insecure = val
+	}
+	artifactType, _ := args.FirstAny("artifact-type", "type")
+	artifactType = strings.TrimSpace(artifactType)
+	logger.Debug("Resolved referrers options", "ref", ref, "insecure", insecure, "artifact_type", artifactType)
+
+	report, err := client.ListReferrers(ctx, ref, insecure, artifactType)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal referrers: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write referrers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleRepos(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	registry, _ := args.FirstAny("registry")
+	registry = strings.TrimSpace(registry)
+	if positionals := cleanedValues(args.Positionals()); registry == "" && len(positionals) > 0 {
+		registry = positionals[0]
+	}
+	if registry == "" {
+		return fmt.Errorf("registry required")
+	}
+
+	opts := porter.RepositoryListOptions{}
+	opts.Insecure, _ = args.Bool("insecure")
+	if val, ok := args.First("prefix"); ok {
+		opts.Prefix = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("last"); ok {
+		opts.Last = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("limit"); ok {
+		limit, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid --limit %q: expected a non-negative number", val)
+		}
+		opts.Limit = limit
+	}
+	logger.Debug("Resolved repos options", "registry", registry, "prefix", opts.Prefix, "last", opts.Last, "limit", opts.Limit)
+
+	list, err := client.ListRepositories(ctx, registry, opts)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal repository list: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write repository list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleStats(client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref", "artifact")
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			ref = positionals[0]
+		}
+	}
+	logger.Debug("Resolved stats options", "ref", ref)
+
+	stats, err := client.PullStats(ref)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pull statistics: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write pull statistics: %w", err)
+	}
+	return nil
+}
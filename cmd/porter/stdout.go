@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+)
+
+// stdoutLimit bounds the layer a pull streams to stdout. DS relays plugin output
+// in a single gRPC message, which its client caps at 4 MiB.
+const stdoutLimit = 4<<20 - 64<<10
+
+// streamsToStdout reports whether a pull exports to porter.StdoutDestination, in
+// which case its JSON result is reported on stderr.
+func streamsToStdout(args types.PluginArgs) bool {
+	output, _ := args.FirstAny("output", "o")
+	return strings.TrimSpace(output) == porter.StdoutDestination
+}
+
+// streamToStdout writes the layer of result to stdout. The layer is buffered and
+// verified first, so a corrupt or oversized layer never reaches the pipeline.
+func streamToStdout(ctx context.Context, client *porter.Client, result *porter.ArtifactResult, opts porter.ExportOptions, stdout io.Writer) (*porter.StreamedLayer, error) {
+	if opts.Versioned || opts.OutputTemplate != "" || opts.AllPlatforms || opts.OCIArchive || opts.DockerArchive {
+		return nil, fmt.Errorf("-o - streams a single layer and cannot be combined with --versioned, --output-template, --all-arch, --oci-archive or --docker-archive")
+	}
+	buf := &limitedBuffer{limit: stdoutLimit}
+	layer, err := client.WriteArtifact(ctx, result, buf, opts)
+	if err != nil {
+		return nil, err
+	}
+	// DS carries plugin output as text.
+	if !utf8.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("layer %s is binary, and DS relays plugin output as text; export it to a file instead", layer.Digest)
+	}
+	if _, err := stdout.Write(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write layer to stdout: %w", err)
+	}
+	return layer, nil
+}
+
+// limitedBuffer is a bytes.Buffer that refuses to grow past limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("layer exceeds the %d bytes DS can relay on stdout; export it to a file instead", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
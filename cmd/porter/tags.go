@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleTags(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	ref, _ := args.FirstAny("ref")
+	ref = strings.TrimSpace(ref)
+	if positionals := cleanedValues(args.Positionals()); ref == "" && len(positionals) > 0 {
+		ref = positionals[0]
+	}
+	if ref == "" {
+		return fmt.Errorf("repository reference required")
+	}
+
+	opts := porter.TagListOptions{}
+	opts.Insecure, _ = args.Bool("insecure")
+	if val, ok := args.First("match"); ok {
+		opts.Match = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("semver"); ok {
+		opts.Semver = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("last"); ok {
+		opts.Last = strings.TrimSpace(val)
+	}
+	if val, ok := args.First("limit"); ok {
+		limit, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid --limit %q: expected a non-negative number", val)
+		}
+		opts.Limit = limit
+	}
+	logger.Debug("Resolved tags options", "ref", ref, "match", opts.Match, "semver", opts.Semver, "last", opts.Last, "limit", opts.Limit)
+
+	list, err := client.ListTags(ctx, ref, opts)
+	if err != nil {
+		return err
+	}
+	output, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tag list: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write tag list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleVerify(ctx context.Context, client *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	target, _ := args.FirstAny("id", "ref")
+	target = strings.TrimSpace(target)
+	if target == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			target = positionals[0]
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("cached artifact ID or reference required")
+	}
+
+	opts := porter.VerifyOptions{}
+	if val, ok := args.Bool("repair"); ok {
+		opts.Repair = val
+	}
+	if val, ok := args.Bool("insecure"); ok {
+		opts.Insecure = val
+	}
+	logger.Debug("Resolved verify options", "target", target, "repair", opts.Repair, "insecure", opts.Insecure)
+
+	report, err := client.VerifyCachedArtifact(ctx, target, opts)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verify report: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write verify report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+)
+
+func handleVersion(client *porter.Client, args types.PluginArgs, build porter.BuildInfo, stdout io.Writer) error {
+	report := client.VersionReport(build)
+	if asJSON, _ := args.Bool("json"); asJSON {
+		output, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("failed to marshal version report: %w", err)
+		}
+		if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+			return fmt.Errorf("failed to write version report: %w", err)
+		}
+		return nil
+	}
+
+	lines := []string{
+		"porter version " + report.Version,
+		"  commit: " + report.Commit,
+		"  built:  " + report.Date,
+		"  go:     " + report.GoVersion + " " + report.Platform,
+		fmt.Sprintf("  cache format: %d", report.CacheFormatVersion),
+		fmt.Sprintf("  manifest schema: %d (%s)", report.ManifestSchemaVersion, strings.Join(report.ManifestMediaTypes, ", ")),
+	}
+	modules := make([]string, 0, len(report.Dependencies))
+	for module := range report.Dependencies {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	lines = append(lines, "  dependencies:")
+	for _, module := range modules {
+		lines = append(lines, "    "+module+" "+report.Dependencies[module])
+	}
+	features := "none"
+	if len(report.Features) > 0 {
+		features = strings.Join(report.Features, ", ")
+	}
+	lines = append(lines, "  features: "+features)
+	writeLines(stdout, lines)
+	return nil
+}
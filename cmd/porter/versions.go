@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/porter"
+	"github.com/hashicorp/go-hclog"
+)
+
+func handleVersions(_ *porter.Client, args types.PluginArgs, logger hclog.Logger, stdout io.Writer) error {
+	destination, _ := args.FirstAny("dir", "output", "o")
+	destination = strings.TrimSpace(destination)
+	if destination == "" {
+		if positionals := cleanedValues(args.Positionals()); len(positionals) > 0 {
+			destination = positionals[0]
+		}
+	}
+	if destination == "" {
+		return fmt.Errorf("versioned destination required")
+	}
+
+	use, _ := args.FirstAny("use")
+	use = strings.TrimSpace(use)
+	logger.Debug("Resolved versions options", "dir", destination, "use", use)
+
+	if use != "" {
+		if err := porter.SwitchVersion(destination, use); err != nil {
+			return err
+		}
+		logger.Info("Switched current version", "dir", destination, "version", use)
+	}
+
+	versions, err := porter.ListVersions(destination)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal version list: %w", err)
+	}
+	if _, err := fmt.Fprintln(stdout, string(output)); err != nil {
+		return fmt.Errorf("failed to write version list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package doh resolves host names with DNS-over-HTTPS (RFC 8484) for networks
+// where the system resolver cannot see registry hosts or is filtered.
+package doh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	mediaType = "application/dns-message"
+	// maxResponseSize bounds the size of a DNS response read from the server.
+	maxResponseSize = 64 << 10
+	// minTTL keeps answers with very short TTLs from causing a query per connection.
+	minTTL = 30 * time.Second
+)
+
+// Resolver looks up addresses through a DoH endpoint and caches them for their TTL.
+type Resolver struct {
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedAnswer
+}
+
+type cachedAnswer struct {
+	addrs   []string
+	expires time.Time
+}
+
+// New returns a Resolver that queries endpoint, e.g. "https://1.1.1.1/dns-query".
+// client carries the queries; nil uses a client that resolves the endpoint itself
+// with the system resolver.
+func New(endpoint string, client *http.Client) (*Resolver, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if !strings.HasPrefix(endpoint, "https://") && !strings.HasPrefix(endpoint, "http://") {
+		return nil, fmt.Errorf("invalid DNS-over-HTTPS endpoint %q: must be an http(s) URL", endpoint)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Resolver{
+		endpoint: endpoint,
+		client:   client,
+		now:      time.Now,
+		cache:    make(map[string]cachedAnswer),
+	}, nil
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of host. IP literals are returned
+// unchanged.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, ".")) + "."
+
+	r.mu.Lock()
+	if cached, ok := r.cache[name]; ok && r.now().Before(cached.expires) {
+		r.mu.Unlock()
+		return cached.addrs, nil
+	}
+	r.mu.Unlock()
+
+	var addrs []string
+	ttl := time.Duration(0)
+	var firstErr error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, answerTTL, err := r.query(ctx, name, qtype)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		addrs = append(addrs, found...)
+		if ttl == 0 || (answerTTL > 0 && answerTTL < ttl) {
+			ttl = answerTTL
+		}
+	}
+	if len(addrs) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	r.mu.Lock()
+	r.cache[name] = cachedAnswer{addrs: addrs, expires: r.now().Add(ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// DialContext resolves the host of address through the resolver and dials the
+// returned addresses in order until one connects.
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var dialErr error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErr = err
+		}
+		return nil, dialErr
+	}
+}
+
+func (r *Resolver) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid host name %q: %w", name, err)
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode DNS query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create DNS-over-HTTPS request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+	req.Header.Set("Accept", mediaType)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("DNS-over-HTTPS query for %s failed: %w", name, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("DNS-over-HTTPS query for %s failed: %s", name, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read DNS-over-HTTPS response: %w", err)
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("invalid DNS-over-HTTPS response: %w", err)
+	}
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: strings.TrimSuffix(name, "."), IsNotFound: true}
+	default:
+		return nil, 0, fmt.Errorf("DNS-over-HTTPS query for %s failed: %s", name, answer.RCode)
+	}
+
+	var addrs []string
+	var ttl time.Duration
+	for _, rr := range answer.Answers {
+		var ip net.IP
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue // CNAMEs are followed by the server
+		}
+		addrs = append(addrs, ip.String())
+		if recordTTL := time.Duration(rr.Header.TTL) * time.Second; ttl == 0 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+	}
+	return addrs, ttl, nil
+}
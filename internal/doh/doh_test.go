@@ -0,0 +1,99 @@
+package doh
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newServer answers A queries for the names in records and NXDOMAIN otherwise.
+func newServer(t *testing.T, records map[string][4]byte) (*httptest.Server, *int32) {
+	t.Helper()
+	var queries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		assert.Equal(t, mediaType, r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var query dnsmessage.Message
+		require.NoError(t, query.Unpack(body))
+
+		question := query.Questions[0]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: dnsmessage.RCodeSuccess},
+			Questions: query.Questions,
+		}
+		addr, ok := records[question.Name.String()]
+		switch {
+		case !ok:
+			reply.RCode = dnsmessage.RCodeNameError
+		case question.Type == dnsmessage.TypeA:
+			reply.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.AResource{A: addr},
+			}}
+		}
+		packed, err := reply.Pack()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", mediaType)
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries
+}
+
+func TestLookupHost(t *testing.T) {
+	server, queries := newServer(t, map[string][4]byte{"registry.internal.": {10, 1, 2, 3}})
+	resolver, err := New(server.URL, nil)
+	require.NoError(t, err)
+
+	addrs, err := resolver.LookupHost(context.Background(), "Registry.Internal")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.1.2.3"}, addrs)
+	assert.Equal(t, int32(2), atomic.LoadInt32(queries), "one A and one AAAA query")
+
+	_, err = resolver.LookupHost(context.Background(), "registry.internal")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(queries), "answers are cached")
+
+	addrs, err = resolver.LookupHost(context.Background(), "192.0.2.7")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.7"}, addrs)
+
+	_, err = resolver.LookupHost(context.Background(), "missing.internal")
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	assert.True(t, dnsErr.IsNotFound)
+
+	_, err = New("dns.internal:53", nil)
+	assert.Error(t, err)
+}
+
+func TestDialContext(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(target.Close)
+	_, port, err := net.SplitHostPort(target.Listener.Addr().String())
+	require.NoError(t, err)
+
+	server, _ := newServer(t, map[string][4]byte{"target.internal.": {127, 0, 0, 1}})
+	resolver, err := New(server.URL, nil)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{DialContext: resolver.DialContext(&net.Dialer{})}}
+	resp, err := client.Get("http://target.internal:" + port + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+}
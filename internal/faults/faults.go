@@ -0,0 +1,199 @@
+// Package faults provides an opt-in failure injection layer for exercising porter's
+// resilience features (retries, resumable transfers, integrity checks) in automated
+// tests and staging environments. It is inert unless PORTER_FAULTS is set.
+package faults
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// EnvVar names the environment variable holding the fault specification.
+const EnvVar = "PORTER_FAULTS"
+
+// Spec describes the faults to inject. A zero value injects nothing.
+type Spec struct {
+	// Delay is added before every matching registry request.
+	Delay time.Duration
+	// DropAfter cuts matching response bodies and export streams after this many bytes.
+	// Zero disables dropping.
+	DropAfter int64
+	// Corrupt flips the byte at CorruptAt of matching response bodies and export streams.
+	Corrupt   bool
+	CorruptAt int64
+	// Match restricts faults to request paths or blob digests containing this substring.
+	Match string
+	// Times limits how many streams are faulted; zero means unlimited.
+	Times int64
+}
+
+// Injector applies a Spec to HTTP transports and readers.
+type Injector struct {
+	spec    Spec
+	applied atomic.Int64
+}
+
+// ErrInjectedDrop is returned when a stream is cut by a DropAfter fault.
+var ErrInjectedDrop = fmt.Errorf("fault injection: connection dropped: %w", io.ErrUnexpectedEOF)
+
+// FromEnv builds an Injector from PORTER_FAULTS. It returns nil when the variable is unset.
+//
+// The specification is a comma-separated list of key=value pairs:
+//
+//	delay=250ms,drop-after=4096,corrupt-at=10,match=/blobs/,times=1
+func FromEnv() (*Injector, error) {
+	value := strings.TrimSpace(os.Getenv(EnvVar))
+	if value == "" {
+		return nil, nil
+	}
+	spec, err := Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return New(spec), nil
+}
+
+// New returns an Injector for spec.
+func New(spec Spec) *Injector {
+	return &Injector{spec: spec}
+}
+
+// Parse decodes a fault specification string.
+func Parse(value string) (Spec, error) {
+	var spec Spec
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return Spec{}, fmt.Errorf("invalid fault %q, expected key=value", field)
+		}
+
+		var err error
+		switch strings.TrimSpace(key) {
+		case "delay":
+			spec.Delay, err = time.ParseDuration(val)
+		case "drop-after":
+			spec.DropAfter, err = strconv.ParseInt(val, 10, 64)
+		case "corrupt-at":
+			spec.Corrupt = true
+			spec.CorruptAt, err = strconv.ParseInt(val, 10, 64)
+		case "match":
+			spec.Match = val
+		case "times":
+			spec.Times, err = strconv.ParseInt(val, 10, 64)
+		default:
+			return Spec{}, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return Spec{}, fmt.Errorf("invalid value for fault %q: %w", key, err)
+		}
+	}
+	return spec, nil
+}
+
+// Spec returns the specification the injector applies.
+func (i *Injector) Spec() Spec {
+	return i.spec
+}
+
+// Transport wraps base so matching requests are delayed and their response bodies
+// dropped or corrupted. A nil injector returns base unchanged.
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if i == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !i.matches(req.URL.Path) {
+			return base.RoundTrip(req)
+		}
+		if i.spec.Delay > 0 {
+			select {
+			case <-time.After(i.spec.Delay):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+		resp, err := base.RoundTrip(req)
+		if err != nil || resp.Body == nil || resp.StatusCode >= 300 {
+			return resp, err
+		}
+		if body, ok := i.wrap(resp.Body); ok {
+			resp.Body = body
+		}
+		return resp, nil
+	})
+}
+
+// Reader wraps r so a matching stream (identified by name, typically a digest) is
+// dropped or corrupted. A nil injector returns r unchanged.
+func (i *Injector) Reader(name string, r io.Reader) io.Reader {
+	if i == nil || !i.matches(name) {
+		return r
+	}
+	if wrapped, ok := i.wrap(io.NopCloser(r)); ok {
+		return wrapped
+	}
+	return r
+}
+
+func (i *Injector) matches(name string) bool {
+	return i.spec.Match == "" || strings.Contains(name, i.spec.Match)
+}
+
+func (i *Injector) wrap(body io.ReadCloser) (io.ReadCloser, bool) {
+	if i.spec.DropAfter <= 0 && !i.spec.Corrupt {
+		return body, false
+	}
+	if i.spec.Times > 0 && i.applied.Add(1) > i.spec.Times {
+		return body, false
+	}
+	corruptAt := int64(-1)
+	if i.spec.Corrupt {
+		corruptAt = i.spec.CorruptAt
+	}
+	return &faultyReader{ReadCloser: body, dropAfter: i.spec.DropAfter, corruptAt: corruptAt}, true
+}
+
+type faultyReader struct {
+	io.ReadCloser
+	offset    int64
+	dropAfter int64
+	corruptAt int64
+}
+
+func (r *faultyReader) Read(p []byte) (int, error) {
+	if r.dropAfter > 0 {
+		remaining := r.dropAfter - r.offset
+		if remaining <= 0 {
+			return 0, ErrInjectedDrop
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if r.corruptAt >= r.offset && r.corruptAt < r.offset+int64(n) {
+		p[r.corruptAt-r.offset] ^= 0xff
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,102 @@
+package faults
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	spec, err := Parse("delay=250ms, drop-after=4096,corrupt-at=10,match=/blobs/,times=2")
+	require.NoError(t, err)
+	assert.Equal(t, Spec{
+		Delay:     250 * time.Millisecond,
+		DropAfter: 4096,
+		Corrupt:   true,
+		CorruptAt: 10,
+		Match:     "/blobs/",
+		Times:     2,
+	}, spec)
+
+	_, err = Parse("explode=1")
+	assert.ErrorContains(t, err, "unknown fault")
+	_, err = Parse("delay")
+	assert.ErrorContains(t, err, "expected key=value")
+	_, err = Parse("drop-after=lots")
+	assert.Error(t, err)
+}
+
+func TestFromEnvUnset(t *testing.T) {
+	t.Setenv(EnvVar, "")
+	injector, err := FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, injector)
+
+	// A nil injector leaves streams untouched.
+	data, err := io.ReadAll(injector.Reader("sha256:abc", strings.NewReader("payload")))
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+}
+
+func TestReaderDropAndCorrupt(t *testing.T) {
+	dropped := New(Spec{DropAfter: 3})
+	data, err := io.ReadAll(dropped.Reader("sha256:abc", strings.NewReader("payload")))
+	assert.True(t, errors.Is(err, ErrInjectedDrop))
+	assert.Equal(t, "pay", string(data))
+
+	corrupted := New(Spec{Corrupt: true, CorruptAt: 0})
+	data, err = io.ReadAll(corrupted.Reader("sha256:abc", strings.NewReader("payload")))
+	require.NoError(t, err)
+	assert.NotEqual(t, "payload", string(data))
+	assert.Equal(t, "ayload", string(data[1:]))
+}
+
+func TestReaderMatchAndTimes(t *testing.T) {
+	injector := New(Spec{DropAfter: 1, Match: "sha256:bad", Times: 1})
+
+	data, err := io.ReadAll(injector.Reader("sha256:good", strings.NewReader("ok")))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+
+	_, err = io.ReadAll(injector.Reader("sha256:bad", strings.NewReader("ok")))
+	assert.Error(t, err)
+
+	// The fault budget is spent, so the next matching stream is untouched.
+	data, err = io.ReadAll(injector.Reader("sha256:bad", strings.NewReader("ok")))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+}
+
+func TestTransportDelaysAndDrops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 64))
+	}))
+	t.Cleanup(server.Close)
+
+	injector := New(Spec{Delay: 20 * time.Millisecond, DropAfter: 16, Match: "/blobs/"})
+	client := &http.Client{Transport: injector.Transport(nil)}
+
+	start := time.Now()
+	resp, err := client.Get(server.URL + "/v2/team/app/blobs/sha256:abc")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.True(t, errors.Is(err, ErrInjectedDrop))
+	assert.Len(t, data, 16)
+
+	resp, err = client.Get(server.URL + "/v2/team/app/manifests/latest")
+	require.NoError(t, err)
+	data, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Len(t, data, 64)
+}
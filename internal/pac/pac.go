@@ -0,0 +1,263 @@
+// Package pac evaluates proxy auto-config (PAC) scripts so porter can pick the
+// same proxies as browsers in networks that distribute them through PAC files.
+//
+// Scripts run in an embedded JavaScript engine with the standard helper functions
+// (isInNet, dnsResolve, shExpMatch, ...). Time-based helpers always match, since
+// field deployments use PAC files for routing rather than schedules.
+package pac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// maxScriptSize bounds the size of a fetched PAC script.
+const maxScriptSize = 1 << 20
+
+// LookupFunc resolves a host name to its addresses.
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// Script is a compiled PAC script. It is safe for concurrent use; evaluations are
+// serialized because the JavaScript runtime is single-threaded.
+type Script struct {
+	mu      sync.Mutex
+	vm      *goja.Runtime
+	find    goja.Callable
+	lookup  LookupFunc
+	results map[string]string
+}
+
+// Load reads a PAC script from an http(s) URL, a file:// URL, or a local path.
+// Remote scripts are fetched with client.
+func Load(ctx context.Context, location string, client *http.Client) ([]byte, error) {
+	location = strings.TrimSpace(location)
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PAC URL: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch PAC script: %w", err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch PAC script: %s", resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxScriptSize))
+	}
+
+	if parsed, err := url.Parse(location); err == nil && parsed.Scheme == "file" {
+		location = parsed.Path
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PAC script: %w", err)
+	}
+	return data, nil
+}
+
+// Compile prepares source for evaluation. lookup backs dnsResolve and isInNet;
+// nil uses the system resolver.
+func Compile(source []byte, lookup LookupFunc) (*Script, error) {
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	s := &Script{vm: goja.New(), lookup: lookup, results: make(map[string]string)}
+	s.installHelpers()
+
+	if _, err := s.vm.RunString(string(source)); err != nil {
+		return nil, fmt.Errorf("failed to evaluate PAC script: %w", err)
+	}
+	find, ok := goja.AssertFunction(s.vm.Get("FindProxyForURL"))
+	if !ok {
+		return nil, fmt.Errorf("PAC script does not define FindProxyForURL")
+	}
+	s.find = find
+	return s, nil
+}
+
+// FindProxy returns the proxies the script selects for target, in preference order.
+// A nil entry means a direct connection. Results are cached per scheme and host.
+func (s *Script) FindProxy(target *url.URL) ([]*url.URL, error) {
+	host := target.Hostname()
+	key := target.Scheme + "://" + host
+
+	s.mu.Lock()
+	result, ok := s.results[key]
+	if !ok {
+		value, err := s.find(goja.Undefined(), s.vm.ToValue(target.String()), s.vm.ToValue(host))
+		if err != nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("PAC script failed for %s: %w", host, err)
+		}
+		result = value.String()
+		s.results[key] = result
+	}
+	s.mu.Unlock()
+
+	return ParseResult(result)
+}
+
+// ParseResult parses a FindProxyForURL result such as "PROXY a:3128; DIRECT".
+func ParseResult(result string) ([]*url.URL, error) {
+	var proxies []*url.URL
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := strings.ToUpper(fields[0])
+		if kind == "DIRECT" {
+			proxies = append(proxies, nil)
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid PAC result %q", strings.TrimSpace(entry))
+		}
+		var scheme string
+		switch kind {
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			return nil, fmt.Errorf("unsupported PAC proxy type %q", fields[0])
+		}
+		proxies = append(proxies, &url.URL{Scheme: scheme, Host: fields[1]})
+	}
+	if len(proxies) == 0 {
+		// An empty result means a direct connection.
+		proxies = append(proxies, nil)
+	}
+	return proxies, nil
+}
+
+func (s *Script) resolve(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := s.lookup(ctx, host)
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
+func (s *Script) installHelpers() {
+	set := func(name string, fn interface{}) {
+		_ = s.vm.Set(name, fn)
+	}
+
+	set("isPlainHostName", func(host string) bool {
+		return !strings.Contains(host, ".")
+	})
+	set("dnsDomainIs", func(host, domain string) bool {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+	})
+	set("localHostOrDomainIs", func(host, hostdom string) bool {
+		host, hostdom = strings.ToLower(host), strings.ToLower(hostdom)
+		if host == hostdom {
+			return true
+		}
+		return !strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+".")
+	})
+	set("isResolvable", func(host string) bool {
+		return s.resolve(host) != ""
+	})
+	set("dnsResolve", func(host string) interface{} {
+		if ip := s.resolve(host); ip != "" {
+			return ip
+		}
+		return nil
+	})
+	set("isInNet", func(host, pattern, mask string) bool {
+		ip := net.ParseIP(s.resolve(host))
+		base := net.ParseIP(pattern)
+		maskIP := net.ParseIP(mask)
+		if ip == nil || base == nil || maskIP == nil || ip.To4() == nil || base.To4() == nil || maskIP.To4() == nil {
+			return false
+		}
+		m := net.IPMask(maskIP.To4())
+		return ip.To4().Mask(m).Equal(base.To4().Mask(m))
+	})
+	set("myIpAddress", func() string {
+		return localAddress()
+	})
+	set("dnsDomainLevels", func(host string) int {
+		return strings.Count(host, ".")
+	})
+	set("shExpMatch", func(str, pattern string) bool {
+		return globMatch(pattern, str)
+	})
+	always := func(goja.FunctionCall) goja.Value { return s.vm.ToValue(true) }
+	set("weekdayRange", always)
+	set("dateRange", always)
+	set("timeRange", always)
+	set("alert", func(string) {})
+}
+
+// localAddress returns the address of the interface used for outbound traffic.
+func localAddress() string {
+	conn, err := net.Dial("udp", "192.0.2.1:9")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return "127.0.0.1"
+}
+
+// globMatch matches str against a shell expression where "*" spans any characters
+// and "?" matches exactly one.
+func globMatch(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(str); i >= 0; i-- {
+				if globMatch(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+		}
+		pattern, str = pattern[1:], str[1:]
+	}
+	return len(str) == 0
+}
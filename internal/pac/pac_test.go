@@ -0,0 +1,107 @@
+package pac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const script = `
+function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || dnsDomainIs(host, ".corp.internal")) {
+		return "DIRECT";
+	}
+	if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0")) {
+		return "PROXY lab-proxy:3128";
+	}
+	if (shExpMatch(url, "https://ghcr.io/*")) {
+		return "PROXY ghcr-proxy:8080; DIRECT";
+	}
+	return "SOCKS5 socks.corp.internal:1080";
+}
+`
+
+func lookup(_ context.Context, host string) ([]string, error) {
+	if host == "lab.example.com" {
+		return []string{"10.4.5.6"}, nil
+	}
+	return []string{"203.0.113.9"}, nil
+}
+
+func TestFindProxy(t *testing.T) {
+	compiled, err := Compile([]byte(script), lookup)
+	require.NoError(t, err)
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"https://registry/v2/", []string{""}},
+		{"https://harbor.corp.internal/v2/", []string{""}},
+		{"https://lab.example.com/v2/", []string{"http://lab-proxy:3128"}},
+		{"https://ghcr.io/v2/team/tool/manifests/1.0", []string{"http://ghcr-proxy:8080", ""}},
+		{"https://quay.io/v2/", []string{"socks5://socks.corp.internal:1080"}},
+	}
+	for _, tt := range tests {
+		target, err := url.Parse(tt.target)
+		require.NoError(t, err)
+		proxies, err := compiled.FindProxy(target)
+		require.NoError(t, err, tt.target)
+
+		var got []string
+		for _, proxy := range proxies {
+			if proxy == nil {
+				got = append(got, "")
+			} else {
+				got = append(got, proxy.String())
+			}
+		}
+		assert.Equal(t, tt.want, got, tt.target)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, err := Compile([]byte("function other() {}"), lookup)
+	assert.Error(t, err)
+	_, err = Compile([]byte("function FindProxyForURL(url, host) {"), lookup)
+	assert.Error(t, err)
+
+	_, err = ParseResult("QUIC proxy:443")
+	assert.Error(t, err)
+	proxies, err := ParseResult("")
+	require.NoError(t, err)
+	assert.Equal(t, []*url.URL{nil}, proxies)
+}
+
+func TestLoad(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy.pac" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(script))
+	}))
+	t.Cleanup(server.Close)
+
+	data, err := Load(context.Background(), server.URL+"/proxy.pac", server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, script, string(data))
+
+	_, err = Load(context.Background(), server.URL+"/missing.pac", server.Client())
+	assert.Error(t, err)
+	_, err = Load(context.Background(), "/does/not/exist.pac", nil)
+	assert.Error(t, err)
+}
+
+func TestGlobMatch(t *testing.T) {
+	assert.True(t, globMatch("*.example.com", "a.b.example.com"))
+	assert.True(t, globMatch("https://ghcr.io/*", "https://ghcr.io/v2/x/y"))
+	assert.True(t, globMatch("host?", "host1"))
+	assert.False(t, globMatch("host?", "host"))
+	assert.False(t, globMatch("*.example.com", "example.org"))
+}
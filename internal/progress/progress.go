@@ -0,0 +1,357 @@
+// Package progress reports the progress of blob transfers through hclog so the DS
+// host can render long pulls and pushes instead of showing a silent plugin.
+//
+// A Tracker is attached to ORAS copies with Options and Target. Totals grow as the
+// copy discovers manifests, so early ETAs are optimistic for multi-platform indexes.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
+)
+
+// DefaultInterval is the minimum time between two progress log lines.
+const DefaultInterval = 2 * time.Second
+
+// Snapshot is the state of a transfer at one point in time.
+type Snapshot struct {
+	Bytes      int64
+	TotalBytes int64
+	// SkippedBytes is the part of Bytes that was already present and not transferred.
+	SkippedBytes int64
+	Blobs        int
+	TotalBlobs   int
+	// SkippedBlobs counts the layers and configs among Blobs that were not transferred.
+	SkippedBlobs int
+	Elapsed      time.Duration
+}
+
+// Percent returns the completed share of the known total, capped at 100.
+func (s Snapshot) Percent() float64 {
+	if s.TotalBytes <= 0 {
+		return 0
+	}
+	percent := float64(s.Bytes) * 100 / float64(s.TotalBytes)
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// Rate returns the average transfer rate in bytes per second.
+func (s Snapshot) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// ETA estimates the remaining time from the average rate. It is zero when unknown.
+func (s Snapshot) ETA() time.Duration {
+	rate := s.Rate()
+	remaining := s.TotalBytes - s.Bytes
+	if rate <= 0 || remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// Tracker accumulates transfer progress and logs it at most once per interval.
+// A nil *Tracker is valid and records nothing.
+type Tracker struct {
+	logger    hclog.Logger
+	operation string
+	reference string
+	interval  time.Duration
+	now       func() time.Time
+
+	mu         sync.Mutex
+	start      time.Time
+	lastReport time.Time
+	seen       map[digest.Digest]struct{}
+	done       map[digest.Digest]struct{}
+	bytes      int64
+	skipped    int64
+	totalBytes int64
+	blobs      int
+	skipBlobs  int
+	totalBlobs int
+}
+
+// New returns a Tracker that logs progress of operation ("pull", "push", ...) on ref.
+func New(logger hclog.Logger, operation, ref string) *Tracker {
+	now := time.Now
+	return &Tracker{
+		logger:    logger,
+		operation: operation,
+		reference: ref,
+		interval:  DefaultInterval,
+		now:       now,
+		start:     now(),
+		seen:      make(map[digest.Digest]struct{}),
+		done:      make(map[digest.Digest]struct{}),
+	}
+}
+
+// SetInterval changes the minimum time between log lines; zero logs every update.
+func (t *Tracker) SetInterval(interval time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.interval = interval
+	t.mu.Unlock()
+}
+
+// Discover adds desc to the expected totals. Repeated descriptors are counted once.
+func (t *Tracker) Discover(desc ocispec.Descriptor) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.seen[desc.Digest]; ok {
+		return
+	}
+	t.seen[desc.Digest] = struct{}{}
+	t.totalBytes += desc.Size
+	if !isManifest(desc) {
+		t.totalBlobs++
+	}
+}
+
+// Add records n transferred bytes.
+func (t *Tracker) Add(n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.bytes += n
+	t.reportLocked(false)
+	t.mu.Unlock()
+}
+
+// Complete marks desc as finished. Skipped descriptors were not transferred, so
+// their whole size is credited at once.
+func (t *Tracker) Complete(desc ocispec.Descriptor, skipped bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.done[desc.Digest]; ok {
+		return
+	}
+	t.done[desc.Digest] = struct{}{}
+	if _, ok := t.seen[desc.Digest]; !ok {
+		t.seen[desc.Digest] = struct{}{}
+		t.totalBytes += desc.Size
+		if !isManifest(desc) {
+			t.totalBlobs++
+		}
+	}
+	if skipped {
+		t.bytes += desc.Size
+		t.skipped += desc.Size
+	}
+	if !isManifest(desc) {
+		t.blobs++
+		if skipped {
+			t.skipBlobs++
+		}
+		t.reportLocked(true)
+	}
+}
+
+// Snapshot returns the current progress.
+func (t *Tracker) Snapshot() Snapshot {
+	if t == nil {
+		return Snapshot{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked()
+}
+
+// Finish logs the final totals of the transfer.
+func (t *Tracker) Finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.snapshotLocked()
+	t.logger.Info("Transfer complete",
+		"operation", t.operation,
+		"ref", t.reference,
+		"bytes", s.Bytes,
+		"layers", fmt.Sprintf("%d/%d", s.Blobs, s.TotalBlobs),
+		"elapsed", s.Elapsed.Round(time.Millisecond).String(),
+		"rate", FormatRate(s.Rate()),
+	)
+}
+
+func (t *Tracker) snapshotLocked() Snapshot {
+	s := Snapshot{
+		Bytes:        t.bytes,
+		TotalBytes:   t.totalBytes,
+		SkippedBytes: t.skipped,
+		Blobs:        t.blobs,
+		TotalBlobs:   t.totalBlobs,
+		SkippedBlobs: t.skipBlobs,
+		Elapsed:      t.now().Sub(t.start),
+	}
+	if s.Bytes > s.TotalBytes {
+		s.Bytes = s.TotalBytes
+	}
+	return s
+}
+
+// reportLocked logs the current progress unless the interval has not yet passed.
+// Completed layers are always reported when the transfer spans several layers.
+func (t *Tracker) reportLocked(layerDone bool) {
+	now := t.now()
+	if now.Sub(t.lastReport) < t.interval && !(layerDone && t.totalBlobs > 1) {
+		return
+	}
+	t.lastReport = now
+
+	s := t.snapshotLocked()
+	args := []interface{}{
+		"operation", t.operation,
+		"ref", t.reference,
+		"bytes", s.Bytes,
+		"total_bytes", s.TotalBytes,
+		"percent", fmt.Sprintf("%.1f", s.Percent()),
+		"layers", fmt.Sprintf("%d/%d", s.Blobs, s.TotalBlobs),
+		"rate", FormatRate(s.Rate()),
+	}
+	if eta := s.ETA(); eta > 0 {
+		args = append(args, "eta", eta.String())
+	}
+	t.logger.Info("Transfer progress", args...)
+}
+
+// Reader counts the bytes read from r towards the transfer.
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &countingReader{r: r, t: t}
+}
+
+// Options installs the tracker's hooks on opts, keeping any hooks already set.
+func (t *Tracker) Options(opts *oras.CopyGraphOptions) {
+	if t == nil {
+		return
+	}
+
+	findSuccessors := opts.FindSuccessors
+	if findSuccessors == nil {
+		findSuccessors = content.Successors
+	}
+	opts.FindSuccessors = func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		t.Discover(desc)
+		successors, err := findSuccessors(ctx, fetcher, desc)
+		for _, successor := range successors {
+			t.Discover(successor)
+		}
+		return successors, err
+	}
+
+	postCopy := opts.PostCopy
+	opts.PostCopy = func(ctx context.Context, desc ocispec.Descriptor) error {
+		t.Complete(desc, false)
+		if postCopy != nil {
+			return postCopy(ctx, desc)
+		}
+		return nil
+	}
+
+	onCopySkipped := opts.OnCopySkipped
+	opts.OnCopySkipped = func(ctx context.Context, desc ocispec.Descriptor) error {
+		t.Complete(desc, true)
+		if onCopySkipped != nil {
+			return onCopySkipped(ctx, desc)
+		}
+		return nil
+	}
+}
+
+// Target wraps dst so the bytes pushed into it are counted.
+func (t *Tracker) Target(dst oras.Target) oras.Target {
+	if t == nil {
+		return dst
+	}
+	return &target{Target: dst, tracker: t}
+}
+
+type target struct {
+	oras.Target
+	tracker *Tracker
+}
+
+func (w *target) Push(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	return w.Target.Push(ctx, desc, w.tracker.Reader(r))
+}
+
+// PushReference keeps pushes by tag in a single request when dst supports it.
+func (w *target) PushReference(ctx context.Context, desc ocispec.Descriptor, r io.Reader, reference string) error {
+	if pusher, ok := w.Target.(registry.ReferencePusher); ok {
+		return pusher.PushReference(ctx, desc, w.tracker.Reader(r), reference)
+	}
+	if err := w.Push(ctx, desc, r); err != nil {
+		return err
+	}
+	return w.Tag(ctx, desc, reference)
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.t.Add(int64(n))
+	return n, err
+}
+
+// FormatRate renders a byte rate with binary units, e.g. "12.5 MiB/s".
+func FormatRate(bytesPerSecond float64) string {
+	return FormatBytes(int64(bytesPerSecond)) + "/s"
+}
+
+// FormatBytes renders a byte count with binary units, e.g. "1.5 GiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func isManifest(desc ocispec.Descriptor) bool {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex,
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func newTestTracker(buf *bytes.Buffer) *Tracker {
+	logger := hclog.New(&hclog.LoggerOptions{Output: buf, Level: hclog.Info})
+	tracker := New(logger, "pull", "registry.test/tool:1.0")
+	tracker.SetInterval(0)
+	return tracker
+}
+
+func TestTrackerReportsCopyProgress(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+
+	push := func(mediaType string, data []byte) ocispec.Descriptor {
+		desc := content.NewDescriptorFromBytes(mediaType, data)
+		require.NoError(t, src.Push(ctx, desc, bytes.NewReader(data)))
+		return desc
+	}
+	layerA := push("application/octet-stream", bytes.Repeat([]byte("a"), 4096))
+	layerB := push("application/octet-stream", bytes.Repeat([]byte("b"), 2048))
+	config := push(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	manifest, err := oras.PackManifest(ctx, src, oras.PackManifestVersion1_1, "application/vnd.test", oras.PackManifestOptions{
+		Layers:           []ocispec.Descriptor{layerA, layerB},
+		ConfigDescriptor: &config,
+	})
+	require.NoError(t, err)
+	require.NoError(t, src.Tag(ctx, manifest, "1.0"))
+
+	var buf bytes.Buffer
+	tracker := newTestTracker(&buf)
+	opts := oras.DefaultCopyOptions
+	tracker.Options(&opts.CopyGraphOptions)
+	_, err = oras.Copy(ctx, src, "1.0", tracker.Target(memory.New()), "1.0", opts)
+	require.NoError(t, err)
+	tracker.Finish()
+
+	snapshot := tracker.Snapshot()
+	total := layerA.Size + layerB.Size + config.Size + manifest.Size
+	assert.Equal(t, total, snapshot.TotalBytes)
+	assert.Equal(t, total, snapshot.Bytes)
+	assert.Equal(t, 3, snapshot.TotalBlobs)
+	assert.Equal(t, 3, snapshot.Blobs)
+	assert.Equal(t, 100.0, snapshot.Percent())
+
+	output := buf.String()
+	assert.Contains(t, output, "Transfer progress")
+	assert.Contains(t, output, "layers=3/3")
+	assert.Contains(t, output, "Transfer complete")
+	assert.Contains(t, output, "operation=pull")
+}
+
+func TestTrackerCreditsSkippedContent(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := newTestTracker(&buf)
+	blob := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromString("x"), Size: 100}
+	index := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromString("i"), Size: 10}
+
+	tracker.Discover(blob)
+	tracker.Discover(blob)
+	tracker.Complete(blob, true)
+	tracker.Complete(blob, true)
+	tracker.Complete(index, true)
+
+	snapshot := tracker.Snapshot()
+	assert.Equal(t, int64(110), snapshot.TotalBytes)
+	assert.Equal(t, int64(110), snapshot.Bytes)
+	assert.Equal(t, int64(110), snapshot.SkippedBytes)
+	assert.Equal(t, 1, snapshot.SkippedBlobs)
+	assert.Equal(t, 1, snapshot.Blobs)
+	assert.Equal(t, 1, snapshot.TotalBlobs)
+}
+
+func TestTrackerThrottlesReports(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := newTestTracker(&buf)
+	now := time.Unix(0, 0)
+	tracker.now = func() time.Time { return now }
+	tracker.start = now
+	tracker.SetInterval(time.Second)
+	tracker.Discover(ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromString("x"), Size: 1000})
+
+	_, err := io.Copy(io.Discard, tracker.Reader(strings.NewReader(strings.Repeat("x", 100))))
+	require.NoError(t, err)
+	tracker.Add(100)
+	assert.Equal(t, 1, strings.Count(buf.String(), "Transfer progress"))
+
+	now = now.Add(2 * time.Second)
+	tracker.Add(100)
+	assert.Equal(t, 2, strings.Count(buf.String(), "Transfer progress"))
+	assert.Contains(t, buf.String(), "eta=5s")
+}
+
+func TestSnapshotMath(t *testing.T) {
+	s := Snapshot{Bytes: 50, TotalBytes: 200, Elapsed: 10 * time.Second}
+	assert.Equal(t, 25.0, s.Percent())
+	assert.Equal(t, 5.0, s.Rate())
+	assert.Equal(t, 30*time.Second, s.ETA())
+
+	assert.Zero(t, Snapshot{}.Percent())
+	assert.Zero(t, Snapshot{}.ETA())
+}
+
+func TestNilTrackerIsInert(t *testing.T) {
+	var tracker *Tracker
+	opts := oras.CopyGraphOptions{}
+	tracker.Options(&opts)
+	tracker.Add(10)
+	tracker.Discover(ocispec.Descriptor{})
+	tracker.Complete(ocispec.Descriptor{}, false)
+	tracker.Finish()
+	assert.Nil(t, opts.PostCopy)
+	assert.Equal(t, Snapshot{}, tracker.Snapshot())
+
+	r := strings.NewReader("x")
+	assert.Equal(t, io.Reader(r), tracker.Reader(r))
+	store := memory.New()
+	assert.Equal(t, oras.Target(store), tracker.Target(store))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2<<30))
+	assert.Equal(t, "1.0 MiB/s", FormatRate(1<<20))
+}
@@ -0,0 +1,17 @@
+// Package spans holds the OpenTelemetry helpers porter's client and release
+// pusher share, so their spans report errors the same way.
+package spans
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// End records err on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,39 @@
+// Package tarxattr records extended attributes in the PAX records of tar headers,
+// under the SCHILY.xattr. keys GNU tar and bsdtar use, so push archives carry them
+// and exports restore them.
+package tarxattr
+
+import (
+	"archive/tar"
+	"strings"
+)
+
+// Prefix starts the PAX records that carry extended attributes.
+const Prefix = "SCHILY.xattr."
+
+// Set records xattrs in the PAX records of header.
+func Set(header *tar.Header, xattrs map[string]string) {
+	for name, value := range xattrs {
+		if header.PAXRecords == nil {
+			header.PAXRecords = make(map[string]string)
+		}
+		header.PAXRecords[Prefix+name] = value
+	}
+}
+
+// Get returns the extended attributes recorded in the PAX records of header, or
+// nil when it has none.
+func Get(header *tar.Header) map[string]string {
+	var xattrs map[string]string
+	for key, value := range header.PAXRecords {
+		name, ok := strings.CutPrefix(key, Prefix)
+		if !ok {
+			continue
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[name] = value
+	}
+	return xattrs
+}
@@ -0,0 +1,31 @@
+package tarxattr
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattrsSurviveTarRoundTrip(t *testing.T) {
+	header := &tar.Header{Name: "tool", Mode: 0o755, Format: tar.FormatPAX}
+	header.PAXRecords = map[string]string{"comment": "kept"}
+	Set(header, map[string]string{"user.origin": "ci", "security.capability": "\x01"})
+
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	require.NoError(t, writer.WriteHeader(header))
+	require.NoError(t, writer.Close())
+
+	read, err := tar.NewReader(&buf).Next()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user.origin": "ci", "security.capability": "\x01"}, Get(read))
+	assert.Equal(t, "kept", read.PAXRecords["comment"])
+}
+
+func TestGetWithoutXattrs(t *testing.T) {
+	assert.Nil(t, Get(&tar.Header{Name: "tool"}))
+	assert.Nil(t, Get(&tar.Header{PAXRecords: map[string]string{"comment": "none"}}))
+}
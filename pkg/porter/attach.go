@@ -0,0 +1,78 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/release"
+)
+
+// AttachOptions configures how companion artifacts are attached to a subject.
+type AttachOptions struct {
+	ArtifactType string
+	Annotations  map[string]string
+	Insecure     bool
+}
+
+// AttachResult describes a referrer artifact pushed by AttachArtifact.
+type AttachResult struct {
+	Subject       string   `json:"subject"`
+	SubjectDigest string   `json:"subject_digest"`
+	Digest        string   `json:"digest"`
+	ArtifactType  string   `json:"artifact_type"`
+	Files         []string `json:"files"`
+}
+
+// AttachArtifact uploads files as a single referrer artifact of the manifest
+// identified by subjectRef. The subject must already exist in the registry.
+func (c *Client) AttachArtifact(ctx context.Context, subjectRef string, files []string, opts AttachOptions) (*AttachResult, error) {
+	if strings.TrimSpace(subjectRef) == "" {
+		return nil, fmt.Errorf("subject reference required")
+	}
+	if strings.TrimSpace(opts.ArtifactType) == "" {
+		return nil, fmt.Errorf("artifact type required")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("at least one file required")
+	}
+
+	imgRef, err := c.parseReference(subjectRef, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	_, subject, err := c.resolveRemote(ctx, imgRef, subjectRef, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := c.resolveCredentials(imgRef.Context().RegistryStr())
+	pusher, err := release.NewPusher(release.ReleaseConfig{
+		Reference:   imgRef.Name(),
+		Username:    username,
+		Password:    password,
+		HTTPClient:  c.httpClient(imgRef.Context().RegistryStr()),
+		Insecure:    opts.Insecure,
+		Concurrency: c.concurrency(),
+
+		TracerProvider: c.traceProvider(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pusher: %w", err)
+	}
+
+	desc, err := pusher.PushReferrer(ctx, subject, opts.ArtifactType, files, opts.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Attached referrer artifact", "subject", subjectRef, "digest", desc.Digest, "artifact_type", opts.ArtifactType)
+	return &AttachResult{
+		Subject:       subjectRef,
+		SubjectDigest: subject.Digest.String(),
+		Digest:        desc.Digest.String(),
+		ArtifactType:  opts.ArtifactType,
+		Files:         files,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+func TestAttachArtifactPushesReferrer(t *testing.T) {
+	registry := newTestRegistry(t)
+	subject := registry.AddArtifact("team/app", "1.0.0", "application/octet-stream", []byte("binary"), nil)
+
+	report := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(report, []byte(`{"passed":true}`), 0644))
+
+	client := newTestClient(t, nil)
+	result, err := client.AttachArtifact(context.Background(), registry.Host()+"/team/app:1.0.0", []string{report}, AttachOptions{
+		ArtifactType: "application/vnd.test-report+json",
+		Annotations:  map[string]string{"org.example.suite": "unit"},
+		Insecure:     true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, subject.Digest.String(), result.SubjectDigest)
+
+	repo, err := remote.NewRepository(registry.Host() + "/team/app")
+	require.NoError(t, err)
+	repo.PlainHTTP = true
+
+	var referrers []ocispec.Descriptor
+	err = repo.Referrers(context.Background(), subject, "", func(page []ocispec.Descriptor) error {
+		referrers = append(referrers, page...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, result.Digest, referrers[0].Digest.String())
+	assert.Equal(t, "application/vnd.test-report+json", referrers[0].ArtifactType)
+	assert.Equal(t, "unit", referrers[0].Annotations["org.example.suite"])
+}
+
+func TestAttachArtifactRequiresArtifactType(t *testing.T) {
+	client := newTestClient(t, nil)
+	_, err := client.AttachArtifact(context.Background(), "localhost/team/app:1.0.0", []string{"report.json"}, AttachOptions{})
+	assert.ErrorContains(t, err, "artifact type required")
+}
@@ -0,0 +1,386 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// Results of an audit check.
+const (
+	AuditPass    = "pass"
+	AuditWarn    = "warn"
+	AuditFail    = "fail"
+	AuditSkipped = "skipped"
+)
+
+// Actions of a planned change.
+const (
+	ChangeCreate    = "create"
+	ChangeOverwrite = "overwrite"
+	ChangeUnchanged = "unchanged"
+	ChangeExtract   = "extract"
+	ChangeLink      = "link"
+	ChangeUpload    = "upload"
+	ChangeSkip      = "skip"
+)
+
+// AuditCheck is the outcome of one policy or verification step of an operation.
+type AuditCheck struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// PlannedChange is a write an operation would make.
+type PlannedChange struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// Target is what a link would point at.
+	Target string `json:"target,omitempty"`
+}
+
+// AuditRecord describes an operation: the checks it evaluated and the changes it
+// made or, when Simulated, would make.
+type AuditRecord struct {
+	Time      time.Time         `json:"time"`
+	Operation string            `json:"operation"`
+	Reference string            `json:"reference,omitempty"`
+	Digest    string            `json:"digest,omitempty"`
+	Simulated bool              `json:"simulated"`
+	Allowed   bool              `json:"allowed"`
+	Checks    []AuditCheck      `json:"checks,omitempty"`
+	Changes   []PlannedChange   `json:"changes,omitempty"`
+	Transfer  *TransferEstimate `json:"transfer,omitempty"`
+}
+
+// NewAuditRecord starts a simulated record for operation on reference.
+func NewAuditRecord(operation, reference string) *AuditRecord {
+	return &AuditRecord{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Reference: reference,
+		Simulated: true,
+		Allowed:   true,
+	}
+}
+
+// Check records a check that passed when err is nil and failed otherwise. A failed
+// check means the operation would be refused.
+func (r *AuditRecord) Check(name string, err error) {
+	if err != nil {
+		r.Checks = append(r.Checks, AuditCheck{Name: name, Result: AuditFail, Detail: err.Error()})
+		r.Allowed = false
+		return
+	}
+	r.Checks = append(r.Checks, AuditCheck{Name: name, Result: AuditPass})
+}
+
+// Note records a check that does not affect whether the operation is allowed.
+func (r *AuditRecord) Note(name, result, detail string) {
+	r.Checks = append(r.Checks, AuditCheck{Name: name, Result: result, Detail: detail})
+}
+
+// Plan records a change the operation would make.
+func (r *AuditRecord) Plan(change PlannedChange) {
+	r.Changes = append(r.Changes, change)
+}
+
+// AuditPull evaluates a pull of ref, and its export to destination when one is
+// given, without downloading layers or writing anything. Only manifests are fetched.
+func (c *Client) AuditPull(ctx context.Context, ref string, insecure bool, destination string, opts ExportOptions) (*AuditRecord, error) {
+	record := NewAuditRecord("pull", ref)
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	repo, root, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+	record.Digest = root.Digest.String()
+
+	annotations, err := fetchAnnotations(ctx, repo, root)
+	if err != nil {
+		return nil, err
+	}
+	c.auditRequirements(record, ref, annotations)
+
+	record.Transfer = &TransferEstimate{Reference: ref, Digest: record.Digest}
+	if err := c.estimateNode(ctx, repo, root, nil, record.Transfer, make(map[string]struct{})); err != nil {
+		return nil, err
+	}
+
+	if destination == "" {
+		return record, nil
+	}
+	if opts.OCIArchive {
+		planArchive(record, ref, destination, OCIArchiveExt)
+		return record, nil
+	}
+	if opts.DockerArchive {
+		planArchive(record, ref, destination, DockerArchiveExt)
+		return record, nil
+	}
+	if IsRemoteDestination(destination) {
+		record.Plan(PlannedChange{Action: ChangeUpload, Path: destination, Digest: record.Digest})
+		return record, nil
+	}
+	c.auditHostCapabilities(record, ref, annotations)
+	if destination == StdoutDestination {
+		record.Note("output", AuditPass, "layer streams to stdout; no files are written")
+		return record, nil
+	}
+
+	var link *PlannedChange
+	if opts.Versioned {
+		result := &ArtifactResult{Reference: ref, Digest: record.Digest, Metadata: annotations}
+		dir, err := VersionedDestination(result, destination, opts)
+		record.Check("version", err)
+		if err != nil {
+			return record, nil
+		}
+		versionsDir, version := filepath.Split(dir)
+		link = &PlannedChange{Action: ChangeLink, Path: filepath.Join(versionsDir, CurrentVersionLink), Target: version}
+		destination = dir
+	} else if target, ok := parseDestinationURL(destination); ok {
+		destination = target.Path
+	}
+
+	if err := c.planExport(ctx, record, repo, root, destination, opts, link != nil, false); err != nil {
+		return nil, err
+	}
+	if link != nil {
+		record.Plan(*link)
+	}
+	return record, nil
+}
+
+// auditRequirements records the ds.requires.* evaluation under the configured policy.
+func (c *Client) auditRequirements(record *AuditRecord, ref string, annotations map[string]string) {
+	policy := c.requirementPolicy()
+	if policy == RequirementPolicyIgnore {
+		record.Note("requirements", AuditSkipped, "requirement_policy is ignore")
+		return
+	}
+	unmet, err := c.unmetRequirements(ref, annotations)
+	switch {
+	case err != nil:
+		record.Check("requirements", err)
+	case len(unmet) == 0:
+		record.Check("requirements", nil)
+	case policy == RequirementPolicyWarn:
+		record.Note("requirements", AuditWarn, "requires "+strings.Join(unmet, "; "))
+	default:
+		record.Check("requirements", fmt.Errorf("artifact %s requires %s", ref, strings.Join(unmet, "; ")))
+	}
+}
+
+// planExport records the files ExportArtifact would write for root into the local
+// destination, which is always treated as a directory when isDir is set. Archive
+// layers are recorded as a single extraction unless expand is set, since listing
+// their entries means fetching them.
+func (c *Client) planExport(ctx context.Context, record *AuditRecord, fetcher content.Fetcher, root ocispec.Descriptor, destination string, opts ExportOptions, isDir, expand bool) error {
+	manifests, err := c.selectManifests(ctx, fetcher, root, opts)
+	if err != nil {
+		record.Check("platforms", err)
+		return nil
+	}
+
+	namer, err := newLayerNamer(&ArtifactResult{Reference: record.Reference, Digest: root.Digest.String()}, opts)
+	if err != nil {
+		record.Check("output_template", err)
+		return nil
+	}
+
+	info, statErr := os.Stat(destination)
+	destIsFile := statErr == nil && !info.IsDir()
+	if os.IsNotExist(statErr) && !isDir {
+		destIsFile = destinationLooksLikeFile(destination) && len(manifests) == 1 && !opts.UsePlatformSubdirs && !namer.templated()
+	}
+	dir := destination
+	if destIsFile {
+		dir = filepath.Dir(destination)
+	}
+	if existing := existingParent(dir); existing != "" {
+		record.Check("destination_owner", c.config.Extract.checkDestinationOwner(existing))
+	}
+
+	deliveries := loadDelivery(dir, c.logger)
+	if opts.Full {
+		deliveries.previous = make(map[string]deliveredFile)
+	}
+	subdirs := opts.UsePlatformSubdirs || len(manifests) > 1
+	for _, entry := range manifests {
+		manifest, err := fetchManifest(ctx, fetcher, entry.Descriptor)
+		if err != nil {
+			return err
+		}
+		if destIsFile {
+			if len(manifest.Layers) != 1 {
+				record.Check("layers", fmt.Errorf("expected a single layer, found %d", len(manifest.Layers)))
+				return nil
+			}
+			layer := manifest.Layers[0]
+			record.Plan(PlannedChange{Action: overwriteAction(record, destination, deliveries.plannedAction(destination, layer.Digest, layer.Size), opts.Overwrite), Path: destination, Digest: layer.Digest.String(), Size: layer.Size})
+			continue
+		}
+
+		targetDir := namer.dir(destination, entry.Platform, subdirs)
+		layers, err := orderLayers(manifest.Layers)
+		if err != nil {
+			record.Check("layer_order", err)
+			return nil
+		}
+		for _, layer := range layers {
+			if release.ArchiveCompression(layer.MediaType) != "" {
+				dir, err := namer.archiveDir(targetDir, layer, entry.Platform)
+				if err != nil {
+					record.Check("output_template", err)
+					return nil
+				}
+				if !expand {
+					record.Plan(PlannedChange{Action: ChangeExtract, Path: dir, Digest: layer.Digest.String(), Size: layer.Size})
+					continue
+				}
+				entries, err := fetchArchiveEntries(ctx, fetcher, layer)
+				if err != nil {
+					return err
+				}
+				for _, entry := range entries {
+					path := filepath.Join(dir, entry.Name)
+					record.Plan(PlannedChange{Action: overwriteAction(record, path, deliveries.plannedAction(path, entry.Digest, entry.Size), opts.Overwrite), Path: path, Digest: entry.Digest.String(), Size: entry.Size})
+				}
+				continue
+			}
+			path, err := namer.file(targetDir, layer, entry.Platform)
+			if err != nil {
+				record.Check("output_template", err)
+				return nil
+			}
+			record.Plan(PlannedChange{Action: overwriteAction(record, path, deliveries.plannedAction(path, layer.Digest, layer.Size), opts.Overwrite), Path: path, Digest: layer.Digest.String(), Size: layer.Size})
+		}
+	}
+	return nil
+}
+
+// fetchArchiveEntries lists the regular files of the archive layer.
+func fetchArchiveEntries(ctx context.Context, fetcher content.Fetcher, layer ocispec.Descriptor) ([]archiveEntry, error) {
+	reader, err := fetcher.Fetch(ctx, layer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return listArchive(reader, release.ArchiveCompression(layer.MediaType))
+}
+
+// overwriteAction adjusts the action planned for an existing path to the overwrite
+// policy, recording a failed check when the policy refuses existing files.
+func overwriteAction(record *AuditRecord, path, action, policy string) string {
+	if action == ChangeCreate {
+		return action
+	}
+	switch policy {
+	case OverwriteSkipExisting:
+		return ChangeSkip
+	case OverwriteErrorIfExists:
+		record.Check("overwrite", fmt.Errorf("%s already exists", path))
+	}
+	return action
+}
+
+// fetchAnnotations returns the annotations of the manifest or index desc describes.
+func fetchAnnotations(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (map[string]string, error) {
+	if len(desc.Annotations) > 0 {
+		return desc.Annotations, nil
+	}
+	data, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest %s: %w", desc.Digest, err)
+	}
+	var payload struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+	}
+	if payload.Annotations == nil {
+		payload.Annotations = make(map[string]string)
+	}
+	return payload.Annotations, nil
+}
+
+// existingParent returns path or its nearest ancestor that exists.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return ""
+		}
+		path = parent
+	}
+}
+
+// AuditPush evaluates a push of the file at artifactPath to ref without uploading it.
+func (c *Client) AuditPush(artifactPath, ref string, opts PushOptions) (*AuditRecord, error) {
+	record := NewAuditRecord("push", ref)
+	_, err := c.parseReference(ref, opts.Insecure)
+	record.Check("reference", err)
+	record.Check("naming", c.CheckNaming(ref))
+
+	f, err := os.Open(artifactPath)
+	if err != nil {
+		record.Check("source", err)
+		return record, nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat artifact: %w", err)
+	}
+	// Directories are archived at push time, so their digest is not known yet.
+	change := PlannedChange{Action: ChangeUpload, Path: ref}
+	if info.IsDir() {
+		_, err := release.ArchiveMediaType(opts.Compression)
+		record.Check("compression", err)
+	} else {
+		dgst, err := digest.Canonical.FromReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to digest artifact: %w", err)
+		}
+		change.Digest = dgst.String()
+		change.Size = info.Size()
+	}
+	record.Check("source", nil)
+
+	if opts.Sign.Enabled && opts.Sign.KeyPath != "" {
+		_, err := os.Stat(expandHome(opts.Sign.KeyPath))
+		record.Check("signing_key", err)
+	}
+	for _, sbom := range opts.SBOMs {
+		_, err := os.Stat(sbom)
+		record.Check("sbom", err)
+	}
+
+	record.Plan(change)
+	return record, nil
+}
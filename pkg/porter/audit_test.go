@@ -0,0 +1,113 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditPullPlansWithoutWriting(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"),
+		map[string]string{ocispec.AnnotationTitle: "tool"})
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	client := newTestClient(t, nil)
+	outDir := filepath.Join(t.TempDir(), "out")
+	record, err := client.AuditPull(context.Background(), ref, true, outDir, ExportOptions{})
+	require.NoError(t, err)
+
+	assert.True(t, record.Simulated)
+	assert.True(t, record.Allowed)
+	assert.NotEmpty(t, record.Digest)
+	require.Len(t, record.Changes, 1)
+	assert.Equal(t, PlannedChange{Action: ChangeCreate, Path: filepath.Join(outDir, "tool"), Digest: record.Changes[0].Digest, Size: 11}, record.Changes[0])
+	require.NotNil(t, record.Transfer)
+	assert.Equal(t, int64(11)+record.Transfer.Blobs[0].Size+record.Transfer.Blobs[1].Size, record.Transfer.TotalBytes)
+
+	_, err = os.Stat(outDir)
+	assert.True(t, os.IsNotExist(err), "audit must not create the destination")
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+	for _, request := range registry.Requests() {
+		assert.False(t, strings.HasPrefix(request, "GET ") && strings.Contains(request, "/blobs/"), request)
+	}
+
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	record, err = client.AuditPull(context.Background(), ref, true, outDir, ExportOptions{})
+	require.NoError(t, err)
+	require.Len(t, record.Changes, 1)
+	assert.Equal(t, ChangeUnchanged, record.Changes[0].Action)
+}
+
+func TestAuditPullRecordsRequirements(t *testing.T) {
+	registry := newTestRegistry(t)
+	layer := registry.AddBlob("application/octet-stream", []byte("tool"))
+	config := registry.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	registry.AddManifest("team/tool", "2.0.0", ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Config:      config,
+		Layers:      []ocispec.Descriptor{layer},
+		Annotations: map[string]string{AnnotationRequiresPorter: ">=0.5.0"},
+	})
+	ref := registry.Host() + "/team/tool:2.0.0"
+
+	client := newTestClient(t, nil)
+	client.config.Version = "0.4.2"
+	record, err := client.AuditPull(context.Background(), ref, true, "", ExportOptions{})
+	require.NoError(t, err)
+	assert.False(t, record.Allowed)
+	require.Len(t, record.Checks, 1)
+	assert.Equal(t, AuditFail, record.Checks[0].Result)
+	assert.Contains(t, record.Checks[0].Detail, "porter >=0.5.0 (running 0.4.2)")
+
+	client.config.RequirementPolicy = RequirementPolicyWarn
+	record, err = client.AuditPull(context.Background(), ref, true, "", ExportOptions{})
+	require.NoError(t, err)
+	assert.True(t, record.Allowed)
+	assert.Equal(t, AuditWarn, record.Checks[0].Result)
+}
+
+func TestAuditPullVersioned(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.2.0", "application/octet-stream", []byte("tool"),
+		map[string]string{ocispec.AnnotationTitle: "tool"})
+
+	client := newTestClient(t, nil)
+	outDir := t.TempDir()
+	record, err := client.AuditPull(context.Background(), registry.Host()+"/team/tool:1.2.0", true, outDir, ExportOptions{Versioned: true})
+	require.NoError(t, err)
+	require.Len(t, record.Changes, 2)
+	assert.Equal(t, filepath.Join(outDir, "1.2.0", "tool"), record.Changes[0].Path)
+	assert.Equal(t, PlannedChange{Action: ChangeLink, Path: filepath.Join(outDir, CurrentVersionLink), Target: "1.2.0"}, record.Changes[1])
+}
+
+func TestAuditPush(t *testing.T) {
+	client := newTestClient(t, nil)
+	path := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(path, []byte("tool"), 0o644))
+
+	record, err := client.AuditPush(path, "registry.internal/team/tool:1.0.0", PushOptions{})
+	require.NoError(t, err)
+	assert.True(t, record.Allowed)
+	require.Len(t, record.Changes, 1)
+	assert.Equal(t, ChangeUpload, record.Changes[0].Action)
+	assert.Equal(t, int64(4), record.Changes[0].Size)
+
+	record, err = client.AuditPush(filepath.Join(t.TempDir(), "missing"), "registry.internal/team/tool:1.0.0", PushOptions{})
+	require.NoError(t, err)
+	assert.False(t, record.Allowed)
+	assert.Empty(t, record.Changes)
+}
@@ -0,0 +1,210 @@
+package porter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+)
+
+// AuditLogFile is the audit log in the cache directory unless audit_log.path
+// names another file.
+const AuditLogFile = "audit.jsonl"
+
+// JobIDEnv names the environment variable holding the DS job a command runs for;
+// the audit log records it with every entry.
+const JobIDEnv = "DS_JOB_ID"
+
+const (
+	// defaultAuditLogMaxSize is the size beyond which the audit log is rotated.
+	defaultAuditLogMaxSize = 10 << 20
+	// defaultAuditLogMaxFiles is how many rotated audit logs are kept.
+	defaultAuditLogMaxFiles = 5
+)
+
+// auditLogLock serializes appends and rotations of the audit log between processes.
+const auditLogLock = "audit"
+
+// AuditLogConfig configures the append-only log recording every pull, push and
+// export with who ran it, for which job, and how it ended.
+type AuditLogConfig struct {
+	// Path is the log file; empty uses AuditLogFile in the cache directory.
+	Path string `json:"path,omitempty"`
+	// MaxSize is the size, e.g. "10MiB", the log is rotated at; empty uses 10MiB.
+	MaxSize string `json:"max_size,omitempty"`
+	// MaxFiles is how many rotated logs are kept; zero keeps 5. Older ones are
+	// removed.
+	MaxFiles int `json:"max_files,omitempty"`
+	// Disabled turns the audit log off.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// auditLogConfigFromSettings reads plugins.settings.porter.audit_log.
+func auditLogConfigFromSettings(settings map[string]interface{}) AuditLogConfig {
+	auditLog, _ := settings["audit_log"].(map[string]interface{})
+	return AuditLogConfig{
+		Path:     settingString(auditLog, "path"),
+		MaxSize:  settingString(auditLog, "max_size"),
+		MaxFiles: settingInt(auditLog, "max_files"),
+		Disabled: settingBool(auditLog, "disabled"),
+	}
+}
+
+// AuditLogEntry is one line of the audit log. Reference and Error are redacted.
+type AuditLogEntry struct {
+	Time        time.Time `json:"time"`
+	Operation   string    `json:"operation"`
+	Outcome     string    `json:"outcome"`
+	Reference   string    `json:"reference"`
+	Digest      string    `json:"digest,omitempty"`
+	Registry    string    `json:"registry,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	JobID       string    `json:"job_id,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	Version     string    `json:"porter_version,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// auditLog is where the client appends its audit entries.
+type auditLog struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	user     string
+	host     string
+}
+
+// openAuditLog resolves the audit log of cfg, or returns nil when it is disabled.
+func openAuditLog(cfg *Config) (*auditLog, error) {
+	if cfg.AuditLog.Disabled {
+		return nil, nil
+	}
+	log := &auditLog{
+		path:     cfg.AuditLog.Path,
+		maxBytes: defaultAuditLogMaxSize,
+		maxFiles: cfg.AuditLog.MaxFiles,
+	}
+	if log.path == "" {
+		log.path = filepath.Join(cfg.CacheDir, AuditLogFile)
+	}
+	if cfg.AuditLog.MaxSize != "" {
+		maxBytes, err := parseSize(cfg.AuditLog.MaxSize)
+		if err != nil || maxBytes == 0 {
+			return nil, fmt.Errorf("invalid audit_log.max_size %q", cfg.AuditLog.MaxSize)
+		}
+		log.maxBytes = maxBytes
+	}
+	if log.maxFiles <= 0 {
+		log.maxFiles = defaultAuditLogMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(log.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	if current, err := user.Current(); err == nil {
+		log.user = current.Username
+	} else {
+		log.user = os.Getenv("USER")
+	}
+	log.host, _ = os.Hostname()
+	return log, nil
+}
+
+// audit appends the outcome of operation on ref to the audit log. result, when
+// set, supplies the digest. Failures are only logged: the artifact has been
+// handled by the time it is audited.
+func (c *Client) audit(operation, ref string, result *ArtifactResult, destination string, opErr error) {
+	if c.auditLog == nil {
+		return
+	}
+	entry := AuditLogEntry{
+		Time:        time.Now().UTC(),
+		Operation:   operation,
+		Outcome:     OutcomeSuccess,
+		Reference:   reference.Redact(ref),
+		Destination: c.redactor.Redact(destination),
+		JobID:       c.config.JobID,
+		User:        c.auditLog.user,
+		Host:        c.auditLog.host,
+		Version:     c.config.Version,
+	}
+	if parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases}); err == nil {
+		entry.Registry = parsed.Registry
+		entry.Digest = parsed.Digest.String()
+	}
+	if result != nil && result.Digest != "" {
+		entry.Digest = result.Digest
+	}
+	if opErr != nil {
+		entry.Outcome = OutcomeFailure
+		entry.Error = c.redactor.Redact(opErr.Error())
+	}
+	if err := c.appendAuditLog(entry); err != nil {
+		c.logger.Warn("Failed to write audit log", "path", c.auditLog.path, "error", err)
+	}
+}
+
+// appendAuditLog writes entry as one line, rotating the log first when the line
+// would take it beyond its size limit. Lines are only ever appended, and rotated
+// logs are made read-only.
+func (c *Client) appendAuditLog(entry AuditLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit log entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	lock, err := c.lockCache(auditLogLock, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if info, err := os.Stat(c.auditLog.path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > c.auditLog.maxBytes {
+		if err := c.auditLog.rotate(); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(c.auditLog.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to append to audit log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the log to path.1, shifting earlier rotations up by one and
+// removing the one beyond maxFiles.
+func (l *auditLog) rotate() error {
+	rotated := func(n int) string { return fmt.Sprintf("%s.%d", l.path, n) }
+	if err := os.Remove(rotated(l.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove oldest audit log: %w", err)
+	}
+	for n := l.maxFiles - 1; n >= 1; n-- {
+		if err := os.Rename(rotated(n), rotated(n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, rotated(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := os.Chmod(rotated(1), 0440); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return nil
+}
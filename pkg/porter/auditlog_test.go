@@ -0,0 +1,84 @@
+package porter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditLog(t *testing.T, path string) []AuditLogEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var entries []AuditLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditLogEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestAuditLogRecordsPullsAndExports(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+	client.config.JobID = "job-42"
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	_, err = client.ExportArtifact(context.Background(), result, t.TempDir(), ExportOptions{})
+	require.NoError(t, err)
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/missing:1.0.0", true)
+	require.Error(t, err)
+
+	entries := readAuditLog(t, filepath.Join(client.config.CacheDir, AuditLogFile))
+	require.Len(t, entries, 3)
+	assert.Equal(t, "pull", entries[0].Operation)
+	assert.Equal(t, OutcomeSuccess, entries[0].Outcome)
+	assert.Equal(t, ref, entries[0].Reference)
+	assert.Equal(t, result.Digest, entries[0].Digest)
+	assert.Equal(t, registry.Host(), entries[0].Registry)
+	assert.Equal(t, "job-42", entries[0].JobID)
+	assert.Equal(t, "export", entries[1].Operation)
+	assert.Equal(t, result.Digest, entries[1].Digest)
+	assert.NotEmpty(t, entries[1].Destination)
+	assert.Equal(t, OutcomeFailure, entries[2].Outcome)
+	assert.NotEmpty(t, entries[2].Error)
+}
+
+func TestAuditLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	logger := hclog.NewNullLogger()
+	client, err := NewClient(&Config{
+		CacheDir: t.TempDir(),
+		AuditLog: AuditLogConfig{Path: path, MaxSize: "200", MaxFiles: 2},
+	}, logger)
+	require.NoError(t, err)
+
+	for i := 0; i < 6; i++ {
+		client.audit("pull", "ghcr.io/team/tool:1.0.0", nil, "", nil)
+	}
+
+	current := readAuditLog(t, path)
+	require.NotEmpty(t, current)
+	assert.Equal(t, "ghcr.io", current[0].Registry)
+	info, err := os.Stat(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0440), info.Mode().Perm(), "rotated logs are read-only")
+	_, err = os.Stat(path + ".2")
+	require.NoError(t, err)
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only max_files rotated logs are kept")
+}
@@ -0,0 +1,390 @@
+package porter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Auth check step states.
+const (
+	AuthStepOK      = "ok"
+	AuthStepFailed  = "failed"
+	AuthStepSkipped = "skipped"
+)
+
+const redactedValue = "[redacted]"
+
+// AuthCheckOptions controls the registry authentication walkthrough.
+type AuthCheckOptions struct {
+	Insecure bool
+	Push     bool
+}
+
+// AuthStep records the outcome of a single stage of the authentication flow.
+type AuthStep struct {
+	Name   string            `json:"name"`
+	Status string            `json:"status"`
+	Detail string            `json:"detail,omitempty"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+// AuthReport describes how porter authenticated (or failed to authenticate) against a registry.
+// Secrets are never included; passwords and tokens are replaced by a redaction marker.
+type AuthReport struct {
+	Reference        string     `json:"reference"`
+	Registry         string     `json:"registry"`
+	Repository       string     `json:"repository"`
+	CredentialSource string     `json:"credential_source"`
+	Scheme           string     `json:"scheme,omitempty"`
+	TokenEndpoint    string     `json:"token_endpoint,omitempty"`
+	RequestedScopes  []string   `json:"requested_scopes,omitempty"`
+	GrantedScopes    []string   `json:"granted_scopes,omitempty"`
+	Anonymous        bool       `json:"anonymous"`
+	Success          bool       `json:"success"`
+	Steps            []AuthStep `json:"steps"`
+}
+
+func (r *AuthReport) addStep(name, status, detail string, data map[string]string) {
+	r.Steps = append(r.Steps, AuthStep{Name: name, Status: status, Detail: detail, Data: data})
+}
+
+// CheckAuth walks the registry authentication flow for ref step by step: which credential
+// source is used, the challenge returned by the registry, the token endpoint and scopes
+// granted, whether porter had to fall back to anonymous access, and finally whether the
+// manifest is reachable with the negotiated credentials. Registry failures are
+// reported as failed steps rather than errors, but a cancelled ctx is returned.
+func (c *Client) CheckAuth(ctx context.Context, ref string, opts AuthCheckOptions) (*AuthReport, error) {
+	imgRef, err := c.parseReference(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	registry := imgRef.Context().RegistryStr()
+	repository := imgRef.Context().RepositoryStr()
+
+	report := &AuthReport{
+		Reference:  ref,
+		Registry:   registry,
+		Repository: repository,
+	}
+
+	username, password, source := c.resolveCredentialSource(registry)
+	report.CredentialSource = source
+	report.Anonymous = password == ""
+	report.addStep("credentials", AuthStepOK, fmt.Sprintf("using %s credentials", report.CredentialSource), map[string]string{
+		"source":   report.CredentialSource,
+		"username": username,
+		"password": redactSecret(password),
+	})
+
+	actions := []string{"pull"}
+	if opts.Push {
+		actions = append(actions, "push")
+	}
+	scope := auth.ScopeRepository(repository, actions...)
+	report.RequestedScopes = []string{scope}
+
+	httpClient := &http.Client{Transport: c.httpClient(registry).Transport, Timeout: 30 * time.Second}
+	scheme := "https"
+	if opts.Insecure {
+		scheme = "http"
+	}
+
+	pingURL := fmt.Sprintf("%s://%s/v2/", scheme, registry)
+	resp, err := authCheckRequest(ctx, httpClient, http.MethodGet, pingURL, "")
+	if err != nil && scheme == "https" && strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
+		scheme = "http"
+		pingURL = fmt.Sprintf("%s://%s/v2/", scheme, registry)
+		resp, err = authCheckRequest(ctx, httpClient, http.MethodGet, pingURL, "")
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report.addStep("ping", AuthStepFailed, err.Error(), map[string]string{"url": pingURL})
+		return report, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	report.addStep("ping", AuthStepOK, fmt.Sprintf("registry answered %d", resp.StatusCode), map[string]string{
+		"url":       pingURL,
+		"status":    fmt.Sprintf("%d", resp.StatusCode),
+		"challenge": challenge,
+	})
+
+	authorization := ""
+	switch {
+	case resp.StatusCode != http.StatusUnauthorized:
+		report.Scheme = "none"
+		report.addStep("challenge", AuthStepSkipped, "registry did not request authentication", nil)
+	default:
+		challengeScheme, params := parseAuthChallenge(challenge)
+		report.Scheme = challengeScheme
+		switch challengeScheme {
+		case "basic":
+			report.addStep("challenge", AuthStepOK, "registry requested basic authentication", nil)
+			if password != "" {
+				authorization = "Basic " + basicAuthValue(username, password)
+			}
+		case "bearer":
+			report.addStep("challenge", AuthStepOK, "registry requested bearer token authentication", params)
+			authorization = c.negotiateAuthToken(ctx, httpClient, report, params, scope, username, password)
+		default:
+			report.addStep("challenge", AuthStepFailed, fmt.Sprintf("unsupported authentication challenge %q", challenge), nil)
+			return report, nil
+		}
+	}
+
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, registry, repository, imgRef.Identifier())
+	resp, err = authCheckRequest(ctx, httpClient, http.MethodHead, manifestURL, authorization)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report.addStep("manifest", AuthStepFailed, err.Error(), map[string]string{"url": manifestURL})
+		return report, nil
+	}
+
+	data := map[string]string{
+		"url":    manifestURL,
+		"status": fmt.Sprintf("%d", resp.StatusCode),
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+			data["digest"] = digest
+		}
+		report.Success = true
+		report.addStep("manifest", AuthStepOK, "manifest is accessible", data)
+	} else {
+		report.addStep("manifest", AuthStepFailed, fmt.Sprintf("registry answered %d", resp.StatusCode), data)
+	}
+
+	c.logger.Debug("Registry auth check completed", "registry", registry, "repository", repository, "success", report.Success)
+	return report, nil
+}
+
+// negotiateAuthToken requests a bearer token from the challenge realm, falling back to an
+// anonymous token when the configured credentials are rejected. It returns the
+// Authorization header value to use for subsequent requests.
+func (c *Client) negotiateAuthToken(ctx context.Context, httpClient *http.Client, report *AuthReport, params map[string]string, scope, username, password string) string {
+	realm := params["realm"]
+	if realm == "" {
+		report.addStep("token", AuthStepFailed, "bearer challenge is missing a realm", nil)
+		return ""
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		report.addStep("token", AuthStepFailed, fmt.Sprintf("invalid realm %q: %v", realm, err), nil)
+		return ""
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+	report.TokenEndpoint = realm
+
+	basic := ""
+	if password != "" {
+		basic = "Basic " + basicAuthValue(username, password)
+	}
+
+	token, status, err := fetchAuthToken(ctx, httpClient, tokenURL.String(), basic)
+	data := map[string]string{
+		"endpoint": realm,
+		"service":  params["service"],
+		"scope":    scope,
+		"status":   fmt.Sprintf("%d", status),
+	}
+	if err == nil {
+		data["token"] = redactSecret(token)
+		report.addStep("token", AuthStepOK, "token issued", data)
+		report.addStep("anonymous-fallback", AuthStepSkipped, "not required", nil)
+		report.GrantedScopes = grantedScopes(report, token)
+		return "Bearer " + token
+	}
+
+	report.addStep("token", AuthStepFailed, err.Error(), data)
+	if basic == "" {
+		report.addStep("anonymous-fallback", AuthStepSkipped, "request was already anonymous", nil)
+		return ""
+	}
+
+	token, status, err = fetchAuthToken(ctx, httpClient, tokenURL.String(), "")
+	if err != nil {
+		report.addStep("anonymous-fallback", AuthStepFailed, err.Error(), map[string]string{"status": fmt.Sprintf("%d", status)})
+		return ""
+	}
+
+	report.Anonymous = true
+	report.addStep("anonymous-fallback", AuthStepOK, "anonymous token issued after credentials were rejected", map[string]string{
+		"status": fmt.Sprintf("%d", status),
+		"token":  redactSecret(token),
+	})
+	report.GrantedScopes = grantedScopes(report, token)
+	return "Bearer " + token
+}
+
+func grantedScopes(report *AuthReport, token string) []string {
+	scopes, ok := decodeTokenScopes(token)
+	if !ok {
+		report.addStep("scopes", AuthStepSkipped, "token is opaque; granted scopes cannot be inspected", nil)
+		return nil
+	}
+	report.addStep("scopes", AuthStepOK, fmt.Sprintf("%d scope(s) granted", len(scopes)), map[string]string{
+		"granted": strings.Join(scopes, " "),
+	})
+	return scopes
+}
+
+func fetchAuthToken(ctx context.Context, httpClient *http.Client, tokenURL, authorization string) (string, int, error) {
+	resp, err := authCheckRequest(ctx, httpClient, http.MethodGet, tokenURL, authorization)
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", resp.StatusCode, fmt.Errorf("token endpoint answered %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(resp.Body, &payload); err != nil {
+		return "", resp.StatusCode, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	token := payload.Token
+	if token == "" {
+		token = payload.AccessToken
+	}
+	if token == "" {
+		return "", resp.StatusCode, fmt.Errorf("token response did not contain a token")
+	}
+	return token, resp.StatusCode, nil
+}
+
+type authCheckResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func authCheckRequest(ctx context.Context, httpClient *http.Client, method, target, authorization string) (*authCheckResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if method == http.MethodHead {
+		req.Header.Set("Accept", strings.Join([]string{ocispec.MediaTypeImageIndex, ocispec.MediaTypeImageManifest}, ", "))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return &authCheckResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// parseAuthChallenge splits a WWW-Authenticate header into its lower-cased scheme and parameters.
+func parseAuthChallenge(header string) (string, map[string]string) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", nil
+	}
+
+	scheme, rest, _ := strings.Cut(header, " ")
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(strings.TrimSpace(value[end+2:]), ",")
+			continue
+		}
+
+		if idx := strings.Index(value, ","); idx >= 0 {
+			params[key] = value[:idx]
+			rest = value[idx+1:]
+			continue
+		}
+		params[key] = value
+		break
+	}
+
+	return strings.ToLower(scheme), params
+}
+
+// decodeTokenScopes extracts the "access" claim from a JWT bearer token without verifying it.
+func decodeTokenScopes(token string) ([]string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, false
+	}
+
+	var claims struct {
+		Access []struct {
+			Type    string   `json:"type"`
+			Name    string   `json:"name"`
+			Actions []string `json:"actions"`
+		} `json:"access"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+
+	scopes := make([]string, 0, len(claims.Access))
+	for _, access := range claims.Access {
+		scopes = append(scopes, fmt.Sprintf("%s:%s:%s", access.Type, access.Name, strings.Join(access.Actions, ",")))
+	}
+	return scopes, true
+}
+
+func basicAuthValue(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
@@ -0,0 +1,103 @@
+package porter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenRegistry(t *testing.T, validUser, validPassword string) *httptest.Server {
+	t.Helper()
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"access": []map[string]interface{}{
+			{"type": "repository", "name": "team/app", "actions": []string{"pull"}},
+		},
+	})
+	require.NoError(t, err)
+	token := "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if user, pass, ok := r.BasicAuth(); ok && (user != validUser || pass != validPassword) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+		case r.Header.Get("Authorization") != "Bearer "+token:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/manifests/"):
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckAuthWithCredentials(t *testing.T) {
+	server := newTokenRegistry(t, "alice", "s3cret")
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := newTestClient(t, []RegistryConfig{{Name: host, URL: host, Username: "alice", Password: "s3cret"}})
+	report, err := client.CheckAuth(context.Background(), host+"/team/app:1.0.0", AuthCheckOptions{Insecure: true})
+	require.NoError(t, err)
+
+	assert.True(t, report.Success)
+	assert.False(t, report.Anonymous)
+	assert.Equal(t, "porter-config", report.CredentialSource)
+	assert.Equal(t, "bearer", report.Scheme)
+	assert.Equal(t, server.URL+"/token", report.TokenEndpoint)
+	assert.Equal(t, []string{"repository:team/app:pull"}, report.RequestedScopes)
+	assert.Equal(t, []string{"repository:team/app:pull"}, report.GrantedScopes)
+
+	encoded, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "s3cret")
+	assert.NotContains(t, string(encoded), "e30.")
+}
+
+func TestCheckAuthFallsBackToAnonymous(t *testing.T) {
+	server := newTokenRegistry(t, "alice", "s3cret")
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := newTestClient(t, []RegistryConfig{{Name: host, URL: host, Username: "alice", Password: "wrong"}})
+	report, err := client.CheckAuth(context.Background(), host+"/team/app:1.0.0", AuthCheckOptions{Insecure: true})
+	require.NoError(t, err)
+
+	assert.True(t, report.Success)
+	assert.True(t, report.Anonymous)
+
+	statuses := map[string]string{}
+	for _, step := range report.Steps {
+		statuses[step.Name] = step.Status
+	}
+	assert.Equal(t, AuthStepFailed, statuses["token"])
+	assert.Equal(t, AuthStepOK, statuses["anonymous-fallback"])
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.example/token",service="registry.example",scope="repository:a/b:pull,push"`)
+
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, "https://auth.example/token", params["realm"])
+	assert.Equal(t, "registry.example", params["service"])
+	assert.Equal(t, "repository:a/b:pull,push", params["scope"])
+
+	scheme, params = parseAuthChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, "registry", params["realm"])
+}
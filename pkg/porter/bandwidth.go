@@ -0,0 +1,99 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseBandwidth parses a transfer rate such as "20MiB/s"; the "/s" is optional
+// and sizes take the suffixes of parseSize.
+func parseBandwidth(value string) (int64, error) {
+	rate, err := parseSize(strings.TrimSuffix(strings.TrimSpace(value), "/s"))
+	if err != nil || rate == 0 {
+		return 0, fmt.Errorf("invalid max_bandwidth %q: expected a rate such as 20MiB/s", value)
+	}
+	return rate, nil
+}
+
+// bandwidthLimiter paces the registry traffic of a client to at most rate bytes
+// per second. Parallel transfers, uploads and downloads alike, share the rate.
+type bandwidthLimiter struct {
+	rate int64
+
+	mu sync.Mutex
+	// next is when the bytes let through so far have been paid for at rate.
+	next time.Time
+}
+
+// wait blocks until n bytes just moved are paid for, or ctx is done. Time the
+// limiter was idle is not saved up for a later burst.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// chunk bounds a single read to an eighth of a second's worth of bytes, so slow
+// rates are paced smoothly rather than in long pauses.
+func (l *bandwidthLimiter) chunk(p []byte) []byte {
+	if limit := max(l.rate/8, 1); int64(len(p)) > limit {
+		return p[:limit]
+	}
+	return p
+}
+
+// bandwidthTransport paces request and response bodies through the limiter.
+type bandwidthTransport struct {
+	base    http.RoundTripper
+	limiter *bandwidthLimiter
+}
+
+func (t *bandwidthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &limitedBody{ReadCloser: req.Body, ctx: req.Context(), limiter: t.limiter}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, ctx: req.Context(), limiter: t.limiter}
+	return resp, nil
+}
+
+// limitedBody reads at the rate of its limiter.
+type limitedBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *bandwidthLimiter
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(b.limiter.chunk(p))
+	if waitErr := b.limiter.wait(b.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
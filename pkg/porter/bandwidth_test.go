@@ -0,0 +1,72 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for value, want := range map[string]int64{
+		"20MiB/s": 20 << 20,
+		"512KiB":  512 << 10,
+		"1MB/s":   1000 * 1000,
+	} {
+		rate, err := parseBandwidth(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, rate, value)
+	}
+	for _, value := range []string{"fast", "0/s", "-1MiB/s"} {
+		_, err := parseBandwidth(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBandwidthTransportPacesBodies(t *testing.T) {
+	limiter := &bandwidthLimiter{rate: 256 << 10}
+	upload := make([]byte, 32<<10)
+	download := make([]byte, 32<<10)
+	base := roundTripper(func(req *http.Request) (*http.Response, error) {
+		sent, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		assert.Len(t, sent, len(upload))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(download)), Request: req}, nil
+	})
+	req, err := http.NewRequest(http.MethodPut, "https://registry.example.com/v2/team/tool/blobs/uploads/1", bytes.NewReader(upload))
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp, err := (&bandwidthTransport{base: base, limiter: limiter}).RoundTrip(req)
+	require.NoError(t, err)
+	received, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, received, len(download))
+	// 64KiB in both directions together at 256KiB/s take a quarter second.
+	assert.GreaterOrEqual(t, time.Since(start), 240*time.Millisecond)
+}
+
+func TestBandwidthWaitStopsWhenCancelled(t *testing.T) {
+	limiter := &bandwidthLimiter{rate: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.wait(ctx, 1<<20), context.Canceled)
+}
+
+func TestInvalidMaxBandwidthIsRejected(t *testing.T) {
+	_, err := NewClient(&Config{
+		CacheDir:        t.TempDir(),
+		CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+		MaxBandwidth:    "unlimited",
+	}, hclog.NewNullLogger())
+	assert.EqualError(t, err, `invalid max_bandwidth "unlimited": expected a rate such as 20MiB/s`)
+}
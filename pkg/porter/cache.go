@@ -0,0 +1,408 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// BlobStoreDir is the directory under the cache that holds the blobs of every cached
+// artifact. Each artifact layout links its own blobs directory to it, so identical
+// blobs, such as layers shared between platforms or re-tagged artifacts, are stored once.
+const BlobStoreDir = "blobs"
+
+// StagingDir is the directory under the cache that pushes archive directories into.
+const StagingDir = "staging"
+
+// UploadSessionsDir is the directory under the cache that records the chunked
+// upload sessions of pushes in progress.
+const UploadSessionsDir = "uploads"
+
+// reservedCacheDir reports whether name is a cache directory that holds no artifact.
+func reservedCacheDir(name string) bool {
+	return name == BlobStoreDir || name == StagingDir || name == CacheLockDir || name == UploadSessionsDir
+}
+
+// blobGCGrace keeps recently written blobs from being collected while a concurrent
+// pull that has not yet written its index still needs them.
+const blobGCGrace = time.Hour
+
+// blobPath returns the location of a blob inside an OCI layout directory.
+func blobPath(layoutPath string, dgst digest.Digest) string {
+	return filepath.Join(layoutPath, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// newLayout opens the OCI layout of a cached artifact at path, linking its blobs
+// directory to the shared blob store. Where symbolic links are unavailable the layout
+// keeps its own blobs, which are then deduplicated with hard links.
+func (c *Client) newLayout(path string) (*oci.Store, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	link := filepath.Join(path, "blobs")
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Join(c.config.CacheDir, BlobStoreDir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create blob store: %w", err)
+		}
+		target, err := filepath.Rel(path, filepath.Join(c.config.CacheDir, BlobStoreDir))
+		if err == nil {
+			err = os.Symlink(target, link)
+		}
+		if err != nil {
+			c.logger.Debug("Shared blob store unavailable, layout keeps its own blobs", "path", path, "error", err)
+		}
+	}
+	return oci.New(path)
+}
+
+// sharedLayout is the pull target for a layout backed by the shared blob store.
+// Manifests already in the store, from another tag of the same artifact, are
+// reported missing so the copy still walks their graph and counts the blobs it
+// reuses, and pushing them again records them in this layout's index.
+type sharedLayout struct {
+	*oci.Store
+}
+
+func (s sharedLayout) Exists(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	if isManifestDescriptor(desc) {
+		return false, nil
+	}
+	return s.Store.Exists(ctx, desc)
+}
+
+func (s sharedLayout) Push(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	err := s.Store.Push(ctx, desc, r)
+	if errors.Is(err, errdef.ErrAlreadyExists) && isManifestDescriptor(desc) {
+		return s.Store.Tag(ctx, desc, desc.Digest.String())
+	}
+	return err
+}
+
+// findCachedBlob looks for a blob in the shared blob store and in artifact layouts
+// that predate it.
+func (c *Client) findCachedBlob(dgst digest.Digest) (string, bool) {
+	if dgst.Validate() != nil {
+		return "", false
+	}
+
+	shared := blobPath(c.config.CacheDir, dgst)
+	if info, err := os.Stat(shared); err == nil && info.Mode().IsRegular() {
+		return shared, true
+	}
+
+	entries, err := os.ReadDir(c.config.CacheDir)
+	if err != nil {
+		return "", false
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || reservedCacheDir(entry.Name()) {
+			continue
+		}
+		candidate := blobPath(filepath.Join(c.config.CacheDir, entry.Name()), dgst)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// reuseCachedBlobs returns an ORAS PreCopy hook that satisfies layer and config blobs
+// from other cached layouts instead of downloading them again. Manifests are always
+// fetched so the destination store records the artifact graph.
+func (c *Client) reuseCachedBlobs(layoutPath string) func(context.Context, ocispec.Descriptor) error {
+	return func(ctx context.Context, desc ocispec.Descriptor) error {
+		if isManifestDescriptor(desc) {
+			return nil
+		}
+
+		target := blobPath(layoutPath, desc.Digest)
+		if _, err := os.Stat(target); err == nil {
+			return nil
+		}
+
+		source, ok := c.findCachedBlob(desc.Digest)
+		if !ok {
+			return nil
+		}
+
+		if err := linkOrCopyFile(source, target); err != nil {
+			c.logger.Debug("Failed to reuse cached blob", "digest", desc.Digest, "error", err)
+			return nil
+		}
+
+		c.logger.Debug("Reusing cached blob", "digest", desc.Digest, "size", desc.Size)
+		return oras.SkipNode
+	}
+}
+
+// linkOrCopyFile hard-links source to target, falling back to a copy when linking
+// is not possible (e.g. across filesystems).
+func linkOrCopyFile(source, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".porter-blob-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to place blob: %w", err)
+	}
+	return nil
+}
+
+// pruneBlobStore removes blobs from the shared store that no cached artifact
+// references any more, and returns how many were removed and the bytes freed.
+// Blobs written within blobGCGrace are kept.
+func (c *Client) pruneBlobStore() (int, int64, error) {
+	storeDir := filepath.Join(c.config.CacheDir, BlobStoreDir)
+	if _, err := os.Stat(storeDir); os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	lock, err := c.lockCache(cacheWideLock, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer lock.Unlock()
+
+	referenced, err := c.referencedBlobs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	removed := 0
+	var freed int64
+	cutoff := time.Now().Add(-blobGCGrace)
+	err = filepath.WalkDir(storeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(storeDir, path)
+		if err != nil {
+			return err
+		}
+		algorithm, encoded, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok {
+			return nil
+		}
+		if _, ok := referenced[digest.NewDigestFromEncoded(digest.Algorithm(algorithm), encoded)]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return removed, freed, fmt.Errorf("failed to prune blob store: %w", err)
+	}
+	if removed > 0 {
+		c.logger.Debug("Pruned unreferenced blobs", "count", removed, "bytes", freed)
+	}
+	return removed, freed, nil
+}
+
+// referencedBlobs returns every blob reachable from the index of a cached artifact.
+func (c *Client) referencedBlobs() (map[digest.Digest]struct{}, error) {
+	entries, err := os.ReadDir(c.config.CacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	referenced := make(map[digest.Digest]struct{})
+	for _, entry := range entries {
+		if !entry.IsDir() || reservedCacheDir(entry.Name()) {
+			continue
+		}
+		markLayoutBlobs(filepath.Join(c.config.CacheDir, entry.Name()), referenced)
+	}
+	return referenced, nil
+}
+
+// markLayoutBlobs adds every blob reachable from the index of layout to
+// referenced. Blobs that are missing or unreadable end the walk of their branch.
+func markLayoutBlobs(layout string, referenced map[digest.Digest]struct{}) {
+	var mark func(desc ocispec.Descriptor)
+	mark = func(desc ocispec.Descriptor) {
+		if _, ok := referenced[desc.Digest]; ok || desc.Digest.Validate() != nil {
+			return
+		}
+		referenced[desc.Digest] = struct{}{}
+		if !isManifestDescriptor(desc) {
+			return
+		}
+		data, err := os.ReadFile(blobPath(layout, desc.Digest))
+		if err != nil {
+			return
+		}
+		var node struct {
+			Config    *ocispec.Descriptor  `json:"config"`
+			Layers    []ocispec.Descriptor `json:"layers"`
+			Manifests []ocispec.Descriptor `json:"manifests"`
+		}
+		if json.Unmarshal(data, &node) != nil {
+			return
+		}
+		if node.Config != nil {
+			mark(*node.Config)
+		}
+		for _, child := range append(node.Layers, node.Manifests...) {
+			mark(child)
+		}
+	}
+
+	var index ocispec.Index
+	if err := readJSONFile(filepath.Join(layout, ocispec.ImageIndexFile), &index); err != nil {
+		return
+	}
+	for _, desc := range index.Manifests {
+		mark(desc)
+	}
+}
+
+func isManifestDescriptor(desc ocispec.Descriptor) bool {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex,
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json":
+		return true
+	}
+	return false
+}
+
+// fetchManifest retrieves and decodes an image manifest from a content fetcher.
+func fetchManifest(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (ocispec.Manifest, error) {
+	var manifest ocispec.Manifest
+	data, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to fetch manifest %s: %w", desc.Digest, err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+	}
+	return manifest, nil
+}
+
+// fetchIndex retrieves and decodes an image index from a content fetcher.
+func fetchIndex(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (ocispec.Index, error) {
+	var index ocispec.Index
+	data, err := content.FetchAll(ctx, fetcher, desc)
+	if err != nil {
+		return index, fmt.Errorf("failed to fetch index %s: %w", desc.Digest, err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return index, fmt.Errorf("failed to parse index %s: %w", desc.Digest, err)
+	}
+	return index, nil
+}
+
+// shareLayoutBlobs moves the blobs of a layout that keeps its own, as a forced
+// pull writes them, into the shared blob store, replacing the copies there, and
+// links the layout's blobs directory to the store.
+func (c *Client) shareLayoutBlobs(layout string) error {
+	var moved []digest.Digest
+	root := filepath.Join(layout, "blobs")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		dgst := digest.NewDigestFromEncoded(digest.Algorithm(filepath.Base(filepath.Dir(path))), d.Name())
+		if dgst.Validate() != nil {
+			return nil
+		}
+		target := blobPath(c.config.CacheDir, dgst)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, target); err != nil {
+			return err
+		}
+		moved = append(moved, dgst)
+		return nil
+	})
+	if err == nil {
+		err = os.RemoveAll(root)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move blobs to the blob store: %w", err)
+	}
+
+	if _, err := c.newLayout(layout); err != nil {
+		return fmt.Errorf("failed to create OCI store: %w", err)
+	}
+	if info, err := os.Lstat(root); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	// Without symbolic links the layout keeps hard links to the store.
+	for _, dgst := range moved {
+		if err := linkOrCopyFile(blobPath(c.config.CacheDir, dgst), blobPath(layout, dgst)); err != nil {
+			return fmt.Errorf("failed to link blob %s: %w", dgst, err)
+		}
+	}
+	return nil
+}
+
+// replaceCachedLayout swaps the cached layout at path for the one staged at
+// staged. Readers see either the old or the new layout, never a mix.
+func (c *Client) replaceCachedLayout(staged, path string) error {
+	old := path + ".replaced"
+	if err := os.RemoveAll(old); err != nil {
+		return fmt.Errorf("failed to replace cached artifact: %w", err)
+	}
+	if err := os.Rename(path, old); err != nil {
+		return fmt.Errorf("failed to replace cached artifact: %w", err)
+	}
+	if err := os.Rename(staged, path); err != nil {
+		if restoreErr := os.Rename(old, path); restoreErr != nil {
+			c.logger.Warn("Failed to restore cached artifact", "path", path, "error", restoreErr)
+		}
+		return fmt.Errorf("failed to replace cached artifact: %w", err)
+	}
+	if err := os.RemoveAll(old); err != nil {
+		c.logger.Warn("Failed to remove replaced artifact", "path", old, "error", err)
+	}
+	return nil
+}
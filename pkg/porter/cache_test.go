@@ -0,0 +1,90 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullStoresSharedBlobsOnce(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("shared tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, map[string]string{"build": "1"})
+	registry.AddArtifact("team/tool", "1.0.1", "application/octet-stream", data, map[string]string{"build": "2"})
+
+	client := newTestClient(t, nil)
+	first, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	second, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.1", true)
+	require.NoError(t, err)
+	require.NotEqual(t, first.LocalPath, second.LocalPath)
+
+	shared := blobPath(client.config.CacheDir, digest.FromBytes(data))
+	for _, layout := range []string{first.LocalPath, second.LocalPath} {
+		info, err := os.Lstat(filepath.Join(layout, "blobs"))
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&os.ModeSymlink, "layout blobs must link to the shared store")
+
+		same, err := os.Stat(blobPath(layout, digest.FromBytes(data)))
+		require.NoError(t, err)
+		stored, err := os.Stat(shared)
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(same, stored))
+	}
+
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 2)
+}
+
+func TestPullRecordsManifestAlreadyInStore(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	registry.AddArtifact("team/tool", "latest", "application/octet-stream", data, nil)
+
+	client := newTestClient(t, nil)
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:latest", true)
+	require.NoError(t, err)
+
+	report, err := client.VerifyCachedArtifact(context.Background(), result.ID, VerifyOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid, report.Errors)
+}
+
+func TestPruneBlobStoreRemovesUnreferencedBlobs(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	orphan := []byte("left behind")
+	orphanPath := blobPath(client.config.CacheDir, digest.FromBytes(orphan))
+	require.NoError(t, os.WriteFile(orphanPath, orphan, 0644))
+	recent := []byte("still being pulled")
+	recentPath := blobPath(client.config.CacheDir, digest.FromBytes(recent))
+	require.NoError(t, os.WriteFile(recentPath, recent, 0644))
+
+	old := time.Now().Add(-2 * blobGCGrace)
+	require.NoError(t, os.Chtimes(orphanPath, old, old))
+	require.NoError(t, os.Chtimes(blobPath(client.config.CacheDir, digest.FromBytes(data)), old, old))
+
+	removed, freed, err := client.pruneBlobStore()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, int64(len(orphan)), freed)
+	assert.NoFileExists(t, orphanPath)
+	assert.FileExists(t, recentPath)
+	assert.FileExists(t, blobPath(result.LocalPath, digest.FromBytes(data)))
+}
@@ -0,0 +1,504 @@
+package porter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	bolt "go.etcd.io/bbolt"
+)
+
+// CacheDBFile is the bbolt database under the cache that records every cached
+// artifact: its metadata, the references it was pulled with, the size of its
+// blobs, and when it was last used.
+const CacheDBFile = "cache.db"
+
+// legacyMetadataFile and legacyCacheIndexFile are where cache format 1 kept the
+// artifact records. Opening the database imports and then removes them.
+const (
+	legacyMetadataFile   = "metadata.json"
+	legacyCacheIndexFile = "cache-index.jsonl"
+)
+
+// cacheDBTimeout bounds the wait for another process holding the database open
+// for writing.
+const cacheDBTimeout = 30 * time.Second
+
+// Buckets of the cache database. Artifacts are keyed by cache ID; references and
+// digests map to the cache ID of their artifact.
+var (
+	artifactsBucket  = []byte("artifacts")
+	referencesBucket = []byte("references")
+	digestsBucket    = []byte("digests")
+	cacheMetaBucket  = []byte("meta")
+	migratedKey      = []byte("migrated")
+)
+
+// cacheRecord is what the cache database holds about one cached artifact.
+type cacheRecord struct {
+	Artifact *ArtifactResult `json:"artifact"`
+	// References are every reference pulled to the artifact, such as several
+	// tags of one digest.
+	References []string `json:"references,omitempty"`
+	// BlobBytes is the size of every blob the artifact's index reaches.
+	BlobBytes  int64     `json:"blob_bytes"`
+	AccessedAt time.Time `json:"accessed_at"`
+	// Validated records when the registry last resolved each tag reference to
+	// the artifact's digest; the cache TTL counts from there.
+	Validated map[string]time.Time `json:"validated,omitempty"`
+}
+
+// updateCacheDB runs fn in a read-write transaction of the cache database. The
+// database is opened for each transaction, so porter processes sharing the cache
+// wait only for each other's transactions.
+func (c *Client) updateCacheDB(fn func(tx *bolt.Tx) error) error {
+	if err := os.MkdirAll(c.config.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	db, err := bolt.Open(filepath.Join(c.config.CacheDir, CacheDBFile), 0644, &bolt.Options{Timeout: cacheDBTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open cache database: %w", err)
+	}
+	defer db.Close()
+
+	var migrated []string
+	err = db.Update(func(tx *bolt.Tx) error {
+		if migrated, err = c.migrateCacheDB(tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	c.removeLegacyMetadata(migrated)
+	return nil
+}
+
+// viewCacheDB runs fn in a read-only transaction of the cache database. Without
+// a cache directory there is nothing to read and fn is not called.
+func (c *Client) viewCacheDB(fn func(tx *bolt.Tx) error) error {
+	path := filepath.Join(c.config.CacheDir, CacheDBFile)
+	if _, err := os.Stat(c.config.CacheDir); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Creating the database migrates a cache of format 1.
+		return c.updateCacheDB(fn)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: cacheDBTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open cache database: %w", err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// migrateCacheDB creates the buckets and, the first time, imports the
+// metadata.json of every artifact directory. It returns the artifact IDs whose
+// metadata.json may be removed once the transaction commits.
+func (c *Client) migrateCacheDB(tx *bolt.Tx) ([]string, error) {
+	for _, name := range [][]byte{artifactsBucket, referencesBucket, digestsBucket, cacheMetaBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return nil, fmt.Errorf("failed to create cache database bucket %s: %w", name, err)
+		}
+	}
+	meta := tx.Bucket(cacheMetaBucket)
+	if meta.Get(migratedKey) != nil {
+		return nil, nil
+	}
+
+	imported, err := c.importLegacyMetadata(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.Put(migratedKey, []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return nil, fmt.Errorf("failed to record cache migration: %w", err)
+	}
+	if len(imported) > 0 {
+		c.logger.Info("Migrated cache metadata into the cache database", "artifacts", len(imported))
+	}
+	return imported, nil
+}
+
+// importLegacyMetadata records the artifact of every directory holding a
+// metadata.json the database does not know, and returns their IDs.
+func (c *Client) importLegacyMetadata(tx *bolt.Tx) ([]string, error) {
+	entries, err := os.ReadDir(c.config.CacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	imported := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || reservedCacheDir(entry.Name()) {
+			continue
+		}
+		artifactID := entry.Name()
+		if tx.Bucket(artifactsBucket).Get([]byte(artifactID)) != nil {
+			continue
+		}
+		var artifact ArtifactResult
+		if err := readJSONFile(filepath.Join(c.config.CacheDir, artifactID, legacyMetadataFile), &artifact); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				c.logger.Warn("Failed to load metadata", "artifact", artifactID, "error", err)
+			}
+			continue
+		}
+		artifact.ID = artifactID
+		record := &cacheRecord{AccessedAt: artifact.CachedAt}
+		if err := c.putCacheRecord(tx, &artifact, record); err != nil {
+			return nil, err
+		}
+		imported = append(imported, artifactID)
+	}
+	return imported, nil
+}
+
+// removeLegacyMetadata removes the cache format 1 files the database replaced.
+// artifactIDs is nil when nothing was imported.
+func (c *Client) removeLegacyMetadata(artifactIDs []string) {
+	for _, artifactID := range artifactIDs {
+		if err := os.Remove(filepath.Join(c.config.CacheDir, artifactID, legacyMetadataFile)); err != nil && !os.IsNotExist(err) {
+			c.logger.Warn("Failed to remove migrated metadata", "artifact", artifactID, "error", err)
+		}
+	}
+	if artifactIDs != nil {
+		_ = os.Remove(filepath.Join(c.config.CacheDir, legacyCacheIndexFile))
+	}
+}
+
+// putCacheRecord stores artifact in record, replacing the references and digest
+// entries of an earlier record of its ID.
+func (c *Client) putCacheRecord(tx *bolt.Tx, artifact *ArtifactResult, record *cacheRecord) error {
+	id := []byte(artifact.ID)
+	if err := deleteCacheRecord(tx, artifact.ID); err != nil {
+		return err
+	}
+	record.Artifact = artifact
+	if artifact.Reference != "" && !slices.Contains(record.References, artifact.Reference) {
+		record.References = append(record.References, artifact.Reference)
+	}
+	if record.BlobBytes == 0 {
+		record.BlobBytes = layoutBlobBytes(filepath.Join(c.config.CacheDir, artifact.ID))
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := tx.Bucket(artifactsBucket).Put(id, data); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	for _, ref := range record.References {
+		if err := tx.Bucket(referencesBucket).Put([]byte(ref), id); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+	}
+	if artifact.Digest != "" {
+		if err := tx.Bucket(digestsBucket).Put([]byte(artifact.Digest), id); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+// getCacheRecord returns the record of artifactID, or nil when there is none.
+func getCacheRecord(tx *bolt.Tx, artifactID string) (*cacheRecord, error) {
+	data := tx.Bucket(artifactsBucket).Get([]byte(artifactID))
+	if data == nil {
+		return nil, nil
+	}
+	var record cacheRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata of %s: %w", artifactID, err)
+	}
+	if record.Artifact == nil {
+		return nil, fmt.Errorf("metadata of %s has no artifact", artifactID)
+	}
+	return &record, nil
+}
+
+// deleteCacheRecord removes the record of artifactID with the references and
+// digest that point at it.
+func deleteCacheRecord(tx *bolt.Tx, artifactID string) error {
+	record, err := getCacheRecord(tx, artifactID)
+	if err != nil || record == nil {
+		return err
+	}
+	for _, ref := range record.References {
+		if string(tx.Bucket(referencesBucket).Get([]byte(ref))) == artifactID {
+			if err := tx.Bucket(referencesBucket).Delete([]byte(ref)); err != nil {
+				return fmt.Errorf("failed to delete metadata: %w", err)
+			}
+		}
+	}
+	if digest := record.Artifact.Digest; digest != "" && string(tx.Bucket(digestsBucket).Get([]byte(digest))) == artifactID {
+		if err := tx.Bucket(digestsBucket).Delete([]byte(digest)); err != nil {
+			return fmt.Errorf("failed to delete metadata: %w", err)
+		}
+	}
+	if err := tx.Bucket(artifactsBucket).Delete([]byte(artifactID)); err != nil {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	return nil
+}
+
+// saveArtifactMetadata records artifact in the cache database and marks it used.
+// References it was cached under before are kept.
+func (c *Client) saveArtifactMetadata(artifact *ArtifactResult) error {
+	return c.savePulledArtifact(artifact, false)
+}
+
+// savePulledArtifact records artifact like saveArtifactMetadata. validated
+// reports that the registry just resolved the reference of artifact to it.
+func (c *Client) savePulledArtifact(artifact *ArtifactResult, validated bool) error {
+	// Whether a pull was served from the cache, and what it cost, says nothing
+	// about the record.
+	stored := *artifact
+	stored.Cached = true
+	stored.Metrics = nil
+	stored.RateLimit = nil
+	artifact = &stored
+	return c.updateCacheDB(func(tx *bolt.Tx) error {
+		record, err := getCacheRecord(tx, artifact.ID)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			record = &cacheRecord{}
+		}
+		record.BlobBytes = 0
+		record.AccessedAt = time.Now()
+		if validated && artifact.Reference != "" {
+			if record.Validated == nil {
+				record.Validated = make(map[string]time.Time)
+			}
+			record.Validated[artifact.Reference] = record.AccessedAt
+		}
+		return c.putCacheRecord(tx, artifact, record)
+	})
+}
+
+// validatedDigest returns the digest the registry resolved ref to within the
+// cache TTL, or "" when ref has to be resolved again.
+func (c *Client) validatedDigest(ref string) digest.Digest {
+	if c.config.CacheTTL <= 0 {
+		return ""
+	}
+	var dgst digest.Digest
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		id := tx.Bucket(referencesBucket).Get([]byte(ref))
+		if id == nil {
+			return nil
+		}
+		record, err := getCacheRecord(tx, string(id))
+		if err != nil || record == nil {
+			return err
+		}
+		if validatedAt, ok := record.Validated[ref]; ok && time.Since(validatedAt) < c.config.CacheTTL {
+			dgst = digest.Digest(record.Artifact.Digest)
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Debug("Failed to look up cached reference", "ref", reference.Redact(ref), "error", err)
+		return ""
+	}
+	return dgst
+}
+
+func (c *Client) loadArtifactMetadata(artifactID string) (*ArtifactResult, error) {
+	var artifact *ArtifactResult
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		record, err := getCacheRecord(tx, artifactID)
+		if record != nil {
+			artifact = record.Artifact
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if artifact == nil {
+		return nil, fmt.Errorf("failed to read metadata: artifact %s is not cached", artifactID)
+	}
+	return artifact, nil
+}
+
+// forgetCachedArtifact removes the record of artifactID from the cache database,
+// leaving its layout to the caller.
+func (c *Client) forgetCachedArtifact(artifactID string) error {
+	return c.updateCacheDB(func(tx *bolt.Tx) error {
+		return deleteCacheRecord(tx, artifactID)
+	})
+}
+
+// markAccessed records that the cached artifact artifactID was used now.
+func (c *Client) markAccessed(artifactID string) {
+	err := c.updateCacheDB(func(tx *bolt.Tx) error {
+		record, err := getCacheRecord(tx, artifactID)
+		if err != nil || record == nil {
+			return err
+		}
+		record.AccessedAt = time.Now()
+		return c.putCacheRecord(tx, record.Artifact, record)
+	})
+	if err != nil {
+		c.logger.Warn("Failed to record cache access", "artifact", artifactID, "error", err)
+	}
+}
+
+// lookupCachedArtifact returns the cached artifact target names, as a cache ID, a
+// reference it was pulled with, or its digest, or nil when none matches.
+func (c *Client) lookupCachedArtifact(target string) (*ArtifactResult, error) {
+	var artifact *ArtifactResult
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		ids := [][]byte{[]byte(target), tx.Bucket(referencesBucket).Get([]byte(target)), tx.Bucket(digestsBucket).Get([]byte(target))}
+		for _, id := range ids {
+			if id == nil {
+				continue
+			}
+			record, err := getCacheRecord(tx, string(id))
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				artifact = record.Artifact
+				return nil
+			}
+		}
+		return nil
+	})
+	return artifact, err
+}
+
+// ListCachedArtifacts lists all cached artifacts, ordered by ID, from the cache
+// database.
+func (c *Client) ListCachedArtifacts() ([]*ArtifactResult, error) {
+	artifacts := []*ArtifactResult{}
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		return tx.Bucket(artifactsBucket).ForEach(func(k, _ []byte) error {
+			record, err := getCacheRecord(tx, string(k))
+			if err != nil {
+				c.logger.Warn("Failed to load metadata", "artifact", string(k), "error", err)
+				return nil
+			}
+			artifacts = append(artifacts, record.Artifact)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// RebuildCacheIndex reconciles the cache database with the cache directory: it
+// drops the records of artifacts whose layout is gone, such as after artifact
+// directories were deleted by hand, imports any metadata.json left by an older
+// porter, and recomputes blob sizes. It returns the cached artifacts.
+func (c *Client) RebuildCacheIndex() ([]*ArtifactResult, error) {
+	if _, err := os.Stat(c.config.CacheDir); os.IsNotExist(err) {
+		return []*ArtifactResult{}, nil
+	}
+	var imported []string
+	err := c.updateCacheDB(func(tx *bolt.Tx) error {
+		var ids []string
+		if err := tx.Bucket(artifactsBucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			record, err := getCacheRecord(tx, id)
+			if err == nil {
+				_, err = os.Stat(filepath.Join(c.config.CacheDir, id, ocispec.ImageIndexFile))
+			}
+			if err != nil {
+				c.logger.Info("Dropping cache record without a cached layout", "artifact", id, "error", err)
+				if err := deleteCacheRecord(tx, id); err != nil {
+					// A record that does not parse cannot name its references.
+					if err := tx.Bucket(artifactsBucket).Delete([]byte(id)); err != nil {
+						return fmt.Errorf("failed to delete metadata: %w", err)
+					}
+				}
+				continue
+			}
+			record.BlobBytes = 0
+			if err := c.putCacheRecord(tx, record.Artifact, record); err != nil {
+				return err
+			}
+		}
+		var err error
+		imported, err = c.importLegacyMetadata(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.removeLegacyMetadata(imported)
+	return c.ListCachedArtifacts()
+}
+
+// layoutBlobBytes returns the size of every blob reachable from the index of
+// layout, or 0 when the layout is incomplete.
+func layoutBlobBytes(layout string) int64 {
+	var index ocispec.Index
+	if err := readJSONFile(filepath.Join(layout, ocispec.ImageIndexFile), &index); err != nil {
+		return 0
+	}
+	blobs, err := layoutGraph(layout, index)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, blob := range blobs {
+		size += blob.Size
+	}
+	return size
+}
+
+// cachedPull returns the cached artifact root resolves to, marked as served from
+// the cache, with the transfer a pull of it skips. It returns nil when the cache
+// does not hold root or its layout is incomplete.
+func (c *Client) cachedPull(root ocispec.Descriptor) (*ArtifactResult, progress.Snapshot) {
+	if root.Digest.Validate() != nil {
+		return nil, progress.Snapshot{}
+	}
+	artifact, err := c.loadArtifactMetadata(root.Digest.Encoded()[:16])
+	if err != nil || artifact.Digest != root.Digest.String() {
+		return nil, progress.Snapshot{}
+	}
+	var index ocispec.Index
+	err = readJSONFile(filepath.Join(c.config.CacheDir, artifact.ID, ocispec.ImageIndexFile), &index)
+	var blobs []ocispec.Descriptor
+	if err == nil {
+		blobs, err = layoutGraph(filepath.Join(c.config.CacheDir, artifact.ID), index)
+	}
+	if err != nil {
+		c.logger.Debug("Cached artifact is incomplete, pulling it again", "id", artifact.ID, "error", err)
+		return nil, progress.Snapshot{}
+	}
+
+	var snapshot progress.Snapshot
+	for _, blob := range blobs {
+		snapshot.Bytes += blob.Size
+		if !isManifestDescriptor(blob) {
+			snapshot.Blobs++
+		}
+	}
+	snapshot.TotalBytes, snapshot.SkippedBytes = snapshot.Bytes, snapshot.Bytes
+	snapshot.TotalBlobs, snapshot.SkippedBlobs = snapshot.Blobs, snapshot.Blobs
+	artifact.Cached = true
+	return artifact, snapshot
+}
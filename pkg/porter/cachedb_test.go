@@ -0,0 +1,117 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCacheDBMigratesLegacyMetadata(t *testing.T) {
+	client := newTestClient(t, nil)
+	cacheDir := client.config.CacheDir
+	for _, artifact := range []ArtifactResult{
+		{ID: "aaaa", Reference: "registry.test/team/a:1.0", Digest: "sha256:aaaa"},
+		{ID: "bbbb", Reference: "registry.test/team/b:1.0", Digest: "sha256:bbbb"},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheDir, artifact.ID), 0755))
+		data, err := json.Marshal(artifact)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, artifact.ID, legacyMetadataFile), data, 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, legacyCacheIndexFile), []byte("{}\n"), 0644))
+
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aaaa", "bbbb"}, artifactIDs(artifacts))
+	assert.FileExists(t, filepath.Join(cacheDir, CacheDBFile))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "aaaa", legacyMetadataFile))
+	assert.NoFileExists(t, filepath.Join(cacheDir, legacyCacheIndexFile))
+
+	artifact, err := client.findCachedArtifact("registry.test/team/b:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "bbbb", artifact.ID)
+	artifact, err = client.findCachedArtifact("sha256:aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa", artifact.ID)
+}
+
+func TestCacheDBRecordsReferencesAndAccess(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	registry.AddArtifact("team/tool", "stable", "application/octet-stream", []byte("tool binary"), nil)
+
+	client := newTestClient(t, nil)
+	first, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	second, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:stable", true)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, second.ID)
+
+	record := cachedRecord(t, client, first.ID)
+	assert.Equal(t, []string{first.Reference, second.Reference}, record.References)
+	assert.Positive(t, record.BlobBytes)
+	artifact, err := client.findCachedArtifact(first.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, artifact.ID)
+
+	accessed := record.AccessedAt
+	time.Sleep(10 * time.Millisecond)
+	client.markAccessed(first.ID)
+	assert.True(t, cachedRecord(t, client, first.ID).AccessedAt.After(accessed))
+
+	_, err = client.RemoveCachedArtifact(second.Reference)
+	require.NoError(t, err)
+	_, err = client.findCachedArtifact(first.Reference)
+	assert.ErrorContains(t, err, "not found in cache")
+}
+
+func TestRebuildCacheIndexDropsMissingLayouts(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	registry.AddArtifact("team/other", "2.0.0", "application/octet-stream", []byte("other"), nil)
+
+	client := newTestClient(t, nil)
+	tool, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	other, err := client.PullArtifact(context.Background(), registry.Host()+"/team/other:2.0.0", true)
+	require.NoError(t, err)
+
+	// Artifacts removed behind porter's back stay listed until the index is rebuilt.
+	require.NoError(t, os.RemoveAll(filepath.Join(client.config.CacheDir, other.ID)))
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{tool.ID, other.ID}, artifactIDs(artifacts))
+
+	artifacts, err = client.RebuildCacheIndex()
+	require.NoError(t, err)
+	assert.Equal(t, []string{tool.ID}, artifactIDs(artifacts))
+	_, err = client.findCachedArtifact(other.Reference)
+	assert.ErrorContains(t, err, "not found in cache")
+}
+
+func cachedRecord(t *testing.T, client *Client, artifactID string) *cacheRecord {
+	t.Helper()
+	var record *cacheRecord
+	require.NoError(t, client.viewCacheDB(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getCacheRecord(tx, artifactID)
+		return err
+	}))
+	require.NotNil(t, record)
+	return record
+}
+
+func artifactIDs(artifacts []*ArtifactResult) []string {
+	ids := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		ids = append(ids, artifact.ID)
+	}
+	return ids
+}
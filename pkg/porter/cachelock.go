@@ -0,0 +1,62 @@
+package porter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// CacheLockDir is the directory under the cache holding the advisory lock files
+// that coordinate porter processes sharing the cache.
+const CacheLockDir = "locks"
+
+// cacheWideLock is the lock over the whole cache. Pulls and loads hold it shared
+// while they add blobs no index references yet; removing blobs holds it
+// exclusively.
+const cacheWideLock = "cache"
+
+// cacheLock is an advisory lock on a file under CacheLockDir.
+type cacheLock struct {
+	file   *os.File
+	name   string
+	logger hclog.Logger
+}
+
+// lockCache takes the lock name, which is cacheWideLock, metricsLock or a cache ID, waiting
+// while another process holds it. Lock files are never removed, as removing one
+// would let two processes lock different files of the same name.
+func (c *Client) lockCache(name string, exclusive bool) (*cacheLock, error) {
+	dir := filepath.Join(c.config.CacheDir, CacheLockDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache lock directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache lock %s: %w", name, err)
+	}
+
+	locked, err := lockFile(file, exclusive, false)
+	if err == nil && !locked {
+		c.logger.Debug("Waiting for cache lock", "lock", name, "exclusive", exclusive)
+		_, err = lockFile(file, exclusive, true)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock cache %s: %w", name, err)
+	}
+	return &cacheLock{file: file, name: name, logger: c.logger}, nil
+}
+
+// Unlock releases the lock. Releasing it again does nothing.
+func (l *cacheLock) Unlock() {
+	if l == nil || l.file == nil {
+		return
+	}
+	if err := unlockFile(l.file); err != nil {
+		l.logger.Warn("Failed to release cache lock", "lock", l.name, "error", err)
+	}
+	_ = l.file.Close()
+	l.file = nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package porter
+
+import "os"
+
+// lockFile is not implemented without flock(2) or LockFileEx; concurrent porter
+// processes are not coordinated there.
+func lockFile(*os.File, bool, bool) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
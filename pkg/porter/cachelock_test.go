@@ -0,0 +1,70 @@
+package porter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheLockExcludesOtherHolders(t *testing.T) {
+	client := newTestClient(t, nil)
+
+	exclusive, err := client.lockCache(cacheWideLock, true)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		shared, err := client.lockCache(cacheWideLock, false)
+		if err == nil {
+			shared.Unlock()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("shared lock taken while the exclusive lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	exclusive.Unlock()
+	exclusive.Unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared lock not taken after the exclusive lock was released")
+	}
+}
+
+func TestConcurrentPullsOfOneReference(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+
+	const pulls = 4
+	results := make([]*ArtifactResult, pulls)
+	errs := make([]error, pulls)
+	var wg sync.WaitGroup
+	for i := 0; i < pulls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < pulls; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, results[0].ID, results[i].ID)
+	}
+	report, err := client.VerifyCachedArtifact(context.Background(), results[0].ID, VerifyOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 1)
+}
@@ -0,0 +1,159 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// PushFromCache republishes a cached artifact to dest without re-assembling it.
+// The whole graph under the cached root is copied as pulled, so an index keeps all
+// of its platforms and every manifest keeps its annotations and digest. target is a
+// cache ID, the reference the artifact was pulled with, or its digest.
+func (c *Client) PushFromCache(ctx context.Context, target, dest string, opts PushOptions) (*ArtifactResult, error) {
+	return c.push(ctx, dest, func(ctx context.Context) (*ArtifactResult, error) {
+		return c.pushFromCache(ctx, target, dest, opts)
+	})
+}
+
+func (c *Client) pushFromCache(ctx context.Context, target, dest string, opts PushOptions) (*ArtifactResult, error) {
+	if dest == "" {
+		return nil, fmt.Errorf("artifact reference required")
+	}
+	if err := c.CheckNaming(dest); err != nil {
+		return nil, err
+	}
+	if err := c.CheckPushTags(dest, opts.Tags); err != nil {
+		return nil, err
+	}
+	opts, err := c.ApplySemverAliases(ctx, dest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	artifact, err := c.findCachedArtifact(target)
+	if err != nil {
+		return nil, err
+	}
+	root, err := cachedRootDescriptor(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := oci.NewFromFS(ctx, os.DirFS(artifact.LocalPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached layout: %w", err)
+	}
+
+	c.logger.Info("Pushing cached artifact", "id", artifact.ID, "digest", root.Digest, "destination", reference.Redact(dest))
+	if err := c.pushGraph(ctx, store, root, dest, opts); err != nil {
+		return nil, fmt.Errorf("failed to push cached artifact: %w", err)
+	}
+	c.markAccessed(artifact.ID)
+
+	metadata := make(map[string]string, len(artifact.Metadata)+2)
+	for k, v := range artifact.Metadata {
+		metadata[k] = v
+	}
+	metadata["pushed.reference"] = dest
+	metadata["source.reference"] = artifact.Reference
+	recordPushTags(metadata, opts.Tags)
+
+	c.logger.Info("Cached artifact pushed successfully", "reference", reference.Redact(dest), "digest", root.Digest.String())
+
+	return &ArtifactResult{
+		ID:         artifact.ID,
+		Reference:  dest,
+		Digest:     root.Digest.String(),
+		Size:       root.Size,
+		LocalPath:  artifact.LocalPath,
+		Metadata:   metadata,
+		PluginInfo: artifact.PluginInfo,
+		Cached:     true,
+		CachedAt:   artifact.CachedAt,
+	}, nil
+}
+
+// cachedRootDescriptor finds the descriptor of the artifact's root in the layout's
+// index.json, which carries the media type and size needed to copy it.
+func cachedRootDescriptor(artifact *ArtifactResult) (ocispec.Descriptor, error) {
+	var index ocispec.Index
+	if err := readJSONFile(filepath.Join(artifact.LocalPath, ocispec.ImageIndexFile), &index); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to read cached layout: %w", err)
+	}
+	for _, desc := range index.Manifests {
+		if desc.Digest.String() == artifact.Digest {
+			return desc, nil
+		}
+	}
+	if artifact.Digest == "" && len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+	return ocispec.Descriptor{}, errors.New("cached layout does not reference the artifact digest; run 'ds porter verify' on it")
+}
+
+// pushGraph copies the graph under root from store to dest verbatim, so every
+// blob keeps its digest, and tags root unless dest names it by digest, then with
+// opts.Tags.
+func (c *Client) pushGraph(ctx context.Context, store content.ReadOnlyStorage, root ocispec.Descriptor, dest string, opts PushOptions) error {
+	destRef, err := c.parseReference(dest, opts.Insecure)
+	if err != nil {
+		return fmt.Errorf("invalid reference %q: %w", dest, err)
+	}
+	tag := destRef.Identifier()
+	if reference.IsDigest(tag) {
+		if tag != root.Digest.String() {
+			return fmt.Errorf("destination digest %s does not match artifact %s", tag, root.Digest)
+		}
+		tag = ""
+	}
+
+	repo, err := c.remoteRepository(destRef, opts.Insecure)
+	if err != nil {
+		return err
+	}
+
+	tracker := progress.New(c.logger, "push", reference.Redact(dest))
+	copyOpts := oras.CopyGraphOptions{Concurrency: c.concurrency()}
+	tracker.Options(&copyOpts)
+	push := func() error {
+		if err := oras.CopyGraph(ctx, store, tracker.Target(repo), root, copyOpts); err != nil {
+			return err
+		}
+		if tag != "" {
+			if err := repo.Tag(ctx, root, tag); err != nil {
+				return err
+			}
+		}
+		for _, extra := range opts.Tags {
+			if extra == tag {
+				continue
+			}
+			if err := repo.Tag(ctx, root, extra); err != nil {
+				return fmt.Errorf("failed to tag %s: %w", extra, err)
+			}
+		}
+		return nil
+	}
+
+	err = push()
+	if err != nil && !opts.Insecure && isPlainHTTPResponseError(err) {
+		c.logger.Warn("Retrying push over plain HTTP", "ref", reference.Redact(dest))
+		repo.PlainHTTP = true
+		err = push()
+	}
+	if err != nil {
+		return err
+	}
+	tracker.Finish()
+	return nil
+}
@@ -0,0 +1,68 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushFromCacheCopiesWholeIndex(t *testing.T) {
+	source := newTestRegistry(t)
+	var manifests []ocispec.Descriptor
+	for _, platform := range []ocispec.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}} {
+		desc := source.AddArtifact("team/tool", "", "application/octet-stream", []byte("tool for "+platform.Architecture), map[string]string{"arch": platform.Architecture})
+		desc.Platform = &platform
+		manifests = append(manifests, desc)
+	}
+	indexDesc := source.AddManifest("team/tool", "1.0.0", ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageIndex,
+		Manifests:   manifests,
+		Annotations: map[string]string{"org.opencontainers.image.version": "1.0.0"},
+	})
+
+	client := newTestClient(t, nil)
+	pulled, err := client.PullArtifact(context.Background(), source.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	dest := newTestRegistry(t)
+	result, err := client.PushFromCache(context.Background(), pulled.ID, dest.Host()+"/mirror/tool:stable", PushOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, indexDesc.Digest.String(), result.Digest)
+	assert.Equal(t, source.Host()+"/team/tool:1.0.0", result.Metadata["source.reference"])
+
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	assert.Equal(t, indexDesc.Digest.String(), dest.tags["mirror/tool"]["stable"])
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(dest.manifests[indexDesc.Digest.String()].data, &index))
+	assert.Equal(t, "1.0.0", index.Annotations["org.opencontainers.image.version"])
+	for _, desc := range manifests {
+		assert.Contains(t, dest.manifests, desc.Digest.String())
+	}
+	for dgst := range source.blobs {
+		assert.Contains(t, dest.blobs, dgst)
+	}
+}
+
+func TestPushFromCacheRejectsMismatchedDigest(t *testing.T) {
+	source := newTestRegistry(t)
+	source.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+
+	client := newTestClient(t, nil)
+	pulled, err := client.PullArtifact(context.Background(), source.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	dest := newTestRegistry(t)
+	_, err = client.PushFromCache(context.Background(), pulled.Reference, dest.Host()+"/mirror/tool@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", PushOptions{Insecure: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+
+	_, err = client.PushFromCache(context.Background(), "missing", dest.Host()+"/mirror/tool:1", PushOptions{Insecure: true})
+	require.Error(t, err)
+}
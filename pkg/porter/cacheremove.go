@@ -0,0 +1,112 @@
+package porter
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// RemovedArtifact describes a cached artifact RemoveCachedArtifact evicted.
+type RemovedArtifact struct {
+	ID        string `json:"id"`
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+	// Blobs counts the shared blobs no other cached artifact referenced, which
+	// were removed with it.
+	Blobs      int   `json:"blobs"`
+	FreedBytes int64 `json:"freedBytes"`
+}
+
+// RemoveCachedArtifact deletes one cached artifact: its OCI layout, its record,
+// and the blobs of the shared store that no other cached artifact references.
+// Unlike the blob store prune, the orphaned blobs go regardless of their age, as
+// they were reached from the removed index. target is a cache ID, the reference
+// the artifact was pulled with, or its digest.
+func (c *Client) RemoveCachedArtifact(target string) (*RemovedArtifact, error) {
+	artifact, err := c.findCachedArtifact(target)
+	if err != nil {
+		return nil, err
+	}
+	// No pull may be adding blobs the removal would take for orphans.
+	storeLock, err := c.lockCache(cacheWideLock, true)
+	if err != nil {
+		return nil, err
+	}
+	defer storeLock.Unlock()
+	artifactLock, err := c.lockCache(artifact.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	defer artifactLock.Unlock()
+	layout := filepath.Join(c.config.CacheDir, artifact.ID)
+
+	owned := make(map[digest.Digest]struct{})
+	markLayoutBlobs(layout, owned)
+	freed, err := layoutFileSize(layout)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.forgetCachedArtifact(artifact.ID); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(layout); err != nil {
+		return nil, fmt.Errorf("failed to remove cached artifact %s: %w", artifact.ID, err)
+	}
+
+	result := &RemovedArtifact{ID: artifact.ID, Reference: artifact.Reference, Digest: artifact.Digest, FreedBytes: freed}
+	referenced, err := c.referencedBlobs()
+	if err != nil {
+		return result, err
+	}
+	for dgst := range owned {
+		if _, ok := referenced[dgst]; ok {
+			continue
+		}
+		path := blobPath(c.config.CacheDir, dgst)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return result, fmt.Errorf("failed to remove blob %s: %w", dgst, err)
+		}
+		result.Blobs++
+		result.FreedBytes += info.Size()
+	}
+
+	c.logger.Info("Removed cached artifact", "id", artifact.ID, "reference", artifact.Reference, "blobs", result.Blobs, "freed", result.FreedBytes)
+	return result, nil
+}
+
+// layoutFileSize returns the size of the files of a cached layout outside its
+// blobs directory, which links to or shares its files with the blob store.
+func layoutFileSize(layout string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(layout, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == filepath.Join(layout, "blobs") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cached layout: %w", err)
+	}
+	return size, nil
+}
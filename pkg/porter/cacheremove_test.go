@@ -0,0 +1,56 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveCachedArtifactKeepsSharedBlobs(t *testing.T) {
+	registry := newTestRegistry(t)
+	shared := []byte("tool binary")
+	other := []byte("other binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", shared, nil)
+	registry.AddArtifact("team/tool", "1.0.1", "application/octet-stream", shared, map[string]string{"org.opencontainers.image.title": "tool"})
+	registry.AddArtifact("team/other", "2.0.0", "application/octet-stream", other, nil)
+
+	client := newTestClient(t, nil)
+	first, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	second, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.1", true)
+	require.NoError(t, err)
+	require.NotEqual(t, first.ID, second.ID)
+	third, err := client.PullArtifact(context.Background(), registry.Host()+"/team/other:2.0.0", true)
+	require.NoError(t, err)
+
+	removed, err := client.RemoveCachedArtifact(first.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, removed.ID)
+	// Only the manifest was its own; 1.0.1 still uses the layer and the config.
+	assert.Equal(t, 1, removed.Blobs)
+	assert.Positive(t, removed.FreedBytes)
+	assert.NoDirExists(t, filepath.Join(client.config.CacheDir, first.ID))
+	assert.FileExists(t, blobPath(client.config.CacheDir, digest.FromBytes(shared)))
+
+	removed, err = client.RemoveCachedArtifact(third.ID)
+	require.NoError(t, err)
+	assert.Equal(t, third.Digest, removed.Digest)
+	assert.Positive(t, removed.Blobs)
+	assert.Greater(t, removed.FreedBytes, int64(len(other)))
+	assert.NoFileExists(t, blobPath(client.config.CacheDir, digest.FromBytes(other)))
+
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, second.ID, artifacts[0].ID)
+	_, err = os.Stat(blobPath(second.LocalPath, digest.FromBytes(shared)))
+	assert.NoError(t, err)
+
+	_, err = client.RemoveCachedArtifact(third.ID)
+	assert.ErrorContains(t, err, "not found in cache")
+}
@@ -0,0 +1,47 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2"
+)
+
+func TestReuseCachedBlobsSkipsCachedContent(t *testing.T) {
+	client := newTestClient(t, nil)
+	data := []byte("tool binary")
+	desc := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromBytes(data), Size: int64(len(data))}
+	seedCachedBlob(t, client.config.CacheDir, desc, data)
+
+	layout := filepath.Join(t.TempDir(), "layout")
+	reuse := client.reuseCachedBlobs(layout)
+	assert.Equal(t, oras.SkipNode, reuse(context.Background(), desc))
+	reused, err := os.ReadFile(blobPath(layout, desc.Digest))
+	require.NoError(t, err)
+	assert.Equal(t, data, reused)
+
+	// Manifests are always fetched, and so is content no cached layout holds.
+	manifest := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: desc.Digest, Size: desc.Size}
+	assert.NoError(t, reuse(context.Background(), manifest))
+	missing := []byte("other binary")
+	assert.NoError(t, reuse(context.Background(), ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromBytes(missing), Size: int64(len(missing))}))
+	_, err = os.Stat(blobPath(layout, digest.FromBytes(missing)))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLinkOrCopyFile(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source")
+	require.NoError(t, os.WriteFile(source, []byte("blob"), 0644))
+	target := filepath.Join(t.TempDir(), "blobs", "sha256", "target")
+
+	require.NoError(t, linkOrCopyFile(source, target))
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "blob", string(data))
+}
@@ -0,0 +1,91 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Policies for a pull that would grow the cache beyond Config.CacheMaxSize.
+const (
+	// CacheFullEvict removes the least recently used artifacts until the pull fits.
+	CacheFullEvict = "evict"
+	// CacheFullRefuse fails the pull and leaves the cache alone.
+	CacheFullRefuse = "refuse"
+)
+
+// cacheExcess returns how many bytes the cache would exceed its size limit by
+// once a pull of root downloaded what it lacks. It fails when the pull cannot
+// fit at all, or would exceed the limit and the policy refuses it.
+func (c *Client) cacheExcess(ctx context.Context, ref string, endpoints []pullEndpoint, root ocispec.Descriptor) (int64, error) {
+	policy := c.config.CacheFullPolicy
+	if policy == "" {
+		policy = CacheFullEvict
+	}
+	if policy != CacheFullEvict && policy != CacheFullRefuse {
+		return 0, fmt.Errorf("unknown cache_full_policy %q: expected %s or %s", policy, CacheFullEvict, CacheFullRefuse)
+	}
+
+	estimate := &TransferEstimate{}
+	_, err := c.tryEndpoints(ctx, ref, endpoints, func(endpoint pullEndpoint) error {
+		estimate = &TransferEstimate{}
+		return c.estimateNode(ctx, endpoint.repo, root, nil, estimate, make(map[string]struct{}))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to size %s for the cache size limit: %w", reference.Redact(ref), err)
+	}
+	if estimate.DownloadBytes > c.config.CacheMaxSize {
+		return 0, fmt.Errorf("pulling %s needs %d bytes, more than the cache size limit of %d bytes", reference.Redact(ref), estimate.DownloadBytes, c.config.CacheMaxSize)
+	}
+	usage, err := c.cacheDiskUsage()
+	if err != nil {
+		return 0, err
+	}
+	excess := usage + estimate.DownloadBytes - c.config.CacheMaxSize
+	if excess > 0 && policy == CacheFullRefuse {
+		return 0, fmt.Errorf("pulling %s needs %d bytes, but the cache has %d of %d bytes free", reference.Redact(ref), estimate.DownloadBytes, max(c.config.CacheMaxSize-usage, 0), c.config.CacheMaxSize)
+	}
+	return max(excess, 0), nil
+}
+
+// evictCachedArtifacts removes cached artifacts, least recently used first,
+// until they have freed at least bytes.
+func (c *Client) evictCachedArtifacts(bytes int64) error {
+	var records []*cacheRecord
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		return tx.Bucket(artifactsBucket).ForEach(func(k, _ []byte) error {
+			record, err := getCacheRecord(tx, string(k))
+			if err != nil {
+				c.logger.Warn("Failed to load metadata", "artifact", string(k), "error", err)
+				return nil
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].AccessedAt.Before(records[j].AccessedAt) })
+
+	var freed int64
+	for _, record := range records {
+		if freed >= bytes {
+			break
+		}
+		removed, err := c.RemoveCachedArtifact(record.Artifact.ID)
+		if err != nil {
+			return fmt.Errorf("failed to evict cached artifact %s: %w", record.Artifact.ID, err)
+		}
+		c.logger.Info("Evicted cached artifact to stay within the cache size limit", "id", removed.ID, "reference", reference.Redact(removed.Reference), "freed", removed.FreedBytes)
+		freed += removed.FreedBytes
+	}
+	if freed < bytes {
+		return fmt.Errorf("evicting every cached artifact freed %d of the %d bytes the pull needs", freed, bytes)
+	}
+	return nil
+}
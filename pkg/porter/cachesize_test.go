@@ -0,0 +1,53 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullEvictsLeastRecentlyUsedOverCacheMaxSize(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary one"), nil)
+	registry.AddArtifact("team/tool", "2.0.0", "application/octet-stream", []byte("tool binary two"), nil)
+	registry.AddArtifact("team/tool", "3.0.0", "application/octet-stream", []byte("tool binary three"), nil)
+	client := newTestClient(t, nil)
+
+	oldest, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	recent, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:2.0.0", true)
+	require.NoError(t, err)
+	client.markAccessed(oldest.ID)
+	client.markAccessed(recent.ID)
+
+	usage, err := client.cacheDiskUsage()
+	require.NoError(t, err)
+	client.config.CacheMaxSize = usage + 10
+	stats, err := client.CacheStats()
+	require.NoError(t, err)
+	assert.Equal(t, usage+10, stats.MaxBytes)
+	require.NotNil(t, stats.HeadroomBytes)
+	assert.Equal(t, int64(10), *stats.HeadroomBytes)
+
+	client.config.CacheFullPolicy = CacheFullRefuse
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:3.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "free")
+	cached, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{oldest.ID, recent.ID}, artifactIDs(cached))
+
+	client.config.CacheFullPolicy = ""
+	pulled, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:3.0.0", true)
+	require.NoError(t, err)
+	cached, err = client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{recent.ID, pulled.ID}, artifactIDs(cached))
+
+	client.config.CacheMaxSize = 10
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than the cache size limit")
+}
@@ -0,0 +1,165 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	bolt "go.etcd.io/bbolt"
+)
+
+// CacheStats summarizes the local cache for sizing cache volumes. ArtifactBytes
+// counts the blobs of every artifact as if each were stored alone; the shared blob
+// store keeps each blob once, and DedupSavedBytes is the difference.
+type CacheStats struct {
+	Artifacts       int                  `json:"artifacts"`
+	Blobs           int                  `json:"blobs"`
+	DiskBytes       int64                `json:"disk_bytes"`
+	ArtifactBytes   int64                `json:"artifact_bytes"`
+	UniqueBlobBytes int64                `json:"unique_blob_bytes"`
+	DedupSavedBytes int64                `json:"dedup_saved_bytes"`
+	Registries      []RegistryCacheStats `json:"registries"`
+	Oldest          *CacheEntry          `json:"oldest,omitempty"`
+	Newest          *CacheEntry          `json:"newest,omitempty"`
+	// MaxBytes is the cache size limit and HeadroomBytes what pulls may still add
+	// before reaching it. Both are unset when the cache size is unbounded.
+	MaxBytes      int64  `json:"max_bytes,omitempty"`
+	HeadroomBytes *int64 `json:"headroom_bytes,omitempty"`
+}
+
+// RegistryCacheStats is the share of the cache holding artifacts pulled from one
+// registry. Bytes counts the blobs of its artifacts, shared ones included.
+type RegistryCacheStats struct {
+	Registry  string `json:"registry"`
+	Artifacts int    `json:"artifacts"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// CacheEntry identifies a cached artifact and when it was cached and last used.
+type CacheEntry struct {
+	ID         string    `json:"id"`
+	Reference  string    `json:"reference"`
+	Digest     string    `json:"digest"`
+	CachedAt   time.Time `json:"cached_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// CacheStats reports the size of the cache, its artifacts per registry, what the
+// shared blob store saves, and its oldest and newest artifacts.
+func (c *Client) CacheStats() (*CacheStats, error) {
+	var records []*cacheRecord
+	err := c.viewCacheDB(func(tx *bolt.Tx) error {
+		return tx.Bucket(artifactsBucket).ForEach(func(k, _ []byte) error {
+			record, err := getCacheRecord(tx, string(k))
+			if err != nil {
+				c.logger.Warn("Failed to load metadata", "artifact", string(k), "error", err)
+				return nil
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CacheStats{Artifacts: len(records), Registries: []RegistryCacheStats{}}
+	unique := make(map[digest.Digest]int64)
+	registries := make(map[string]*RegistryCacheStats)
+	for _, record := range records {
+		artifact := record.Artifact
+		bytes := record.BlobBytes
+		layout := filepath.Join(c.config.CacheDir, artifact.ID)
+		var index ocispec.Index
+		err := readJSONFile(filepath.Join(layout, ocispec.ImageIndexFile), &index)
+		var blobs []ocispec.Descriptor
+		if err == nil {
+			blobs, err = layoutGraph(layout, index)
+		}
+		if err != nil {
+			c.logger.Warn("Cached artifact is incomplete, counting its recorded size", "artifact", artifact.ID, "error", err)
+		} else {
+			bytes = 0
+			for _, blob := range blobs {
+				bytes += blob.Size
+				unique[blob.Digest] = blob.Size
+			}
+		}
+		stats.ArtifactBytes += bytes
+
+		registry := "unknown"
+		if ref, err := reference.Parse(artifact.Reference); err == nil {
+			registry = ref.Registry
+		}
+		if registries[registry] == nil {
+			registries[registry] = &RegistryCacheStats{Registry: registry}
+		}
+		registries[registry].Artifacts++
+		registries[registry].Bytes += bytes
+
+		entry := &CacheEntry{ID: artifact.ID, Reference: artifact.Reference, Digest: artifact.Digest, CachedAt: artifact.CachedAt, AccessedAt: record.AccessedAt}
+		if stats.Oldest == nil || entry.CachedAt.Before(stats.Oldest.CachedAt) {
+			stats.Oldest = entry
+		}
+		if stats.Newest == nil || entry.CachedAt.After(stats.Newest.CachedAt) {
+			stats.Newest = entry
+		}
+	}
+
+	stats.Blobs = len(unique)
+	for _, size := range unique {
+		stats.UniqueBlobBytes += size
+	}
+	stats.DedupSavedBytes = stats.ArtifactBytes - stats.UniqueBlobBytes
+	for _, name := range sortedKeys(registries) {
+		stats.Registries = append(stats.Registries, *registries[name])
+	}
+	sort.SliceStable(stats.Registries, func(i, j int) bool { return stats.Registries[i].Bytes > stats.Registries[j].Bytes })
+
+	if stats.DiskBytes, err = c.cacheDiskUsage(); err != nil {
+		return nil, err
+	}
+	if c.config.CacheMaxSize > 0 {
+		stats.MaxBytes = c.config.CacheMaxSize
+		headroom := max(stats.MaxBytes-stats.DiskBytes, 0)
+		stats.HeadroomBytes = &headroom
+	}
+	return stats, nil
+}
+
+// cacheDiskUsage returns the size of the files under the cache directory. The
+// blobs directories of artifact layouts are skipped: they link to or share their
+// files with the blob store.
+func (c *Client) cacheDiskUsage() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(c.config.CacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == c.config.CacheDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == "blobs" && filepath.Dir(filepath.Dir(path)) == c.config.CacheDir {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure cache directory: %w", err)
+	}
+	return size, nil
+}
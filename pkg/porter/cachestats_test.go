@@ -0,0 +1,43 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheStatsReportsDedupAndRegistries(t *testing.T) {
+	registry := newTestRegistry(t)
+	shared := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", shared, nil)
+	registry.AddArtifact("team/tool", "1.0.1", "application/octet-stream", shared, map[string]string{"org.opencontainers.image.title": "tool"})
+
+	client := newTestClient(t, nil)
+	empty, err := client.CacheStats()
+	require.NoError(t, err)
+	assert.Zero(t, empty.Artifacts)
+	assert.Empty(t, empty.Registries)
+
+	first, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	second, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.1", true)
+	require.NoError(t, err)
+
+	stats, err := client.CacheStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Artifacts)
+	// Two manifests, and the layer and config both share.
+	assert.Equal(t, 4, stats.Blobs)
+	assert.Equal(t, stats.ArtifactBytes-stats.UniqueBlobBytes, stats.DedupSavedBytes)
+	assert.GreaterOrEqual(t, stats.DedupSavedBytes, int64(len(shared)))
+	assert.Greater(t, stats.DiskBytes, stats.UniqueBlobBytes)
+	require.Len(t, stats.Registries, 1)
+	assert.Equal(t, registry.Host(), stats.Registries[0].Registry)
+	assert.Equal(t, 2, stats.Registries[0].Artifacts)
+	assert.Equal(t, stats.ArtifactBytes, stats.Registries[0].Bytes)
+	require.NotNil(t, stats.Oldest)
+	assert.Equal(t, first.ID, stats.Oldest.ID)
+	assert.Equal(t, second.ID, stats.Newest.ID)
+}
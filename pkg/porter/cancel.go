@@ -0,0 +1,48 @@
+package porter
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// contextReader fails reads once ctx is done, so copying local content, which
+// never waits on the network, still stops when the operation is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// discardCancelledPull removes the staging layout a cancelled pull copied into.
+// When an earlier pull left a cached artifact at that path, only the partial
+// downloads are removed. Blobs already moved into the shared store stay until
+// the next prune.
+func (c *Client) discardCancelledPull(artifactID, cachePath string, force bool) {
+	target := cachePath
+	if !force {
+		var cached bool
+		_ = c.viewCacheDB(func(tx *bolt.Tx) error {
+			record, err := getCacheRecord(tx, artifactID)
+			cached = err == nil && record != nil
+			return nil
+		})
+		if cached {
+			target = filepath.Join(cachePath, "partial")
+		}
+	}
+	if err := os.RemoveAll(target); err != nil {
+		c.logger.Warn("Failed to remove the staging layout of a cancelled pull", "path", target, "error", err)
+		return
+	}
+	c.logger.Info("Pull cancelled, removed its staging layout", "path", target)
+}
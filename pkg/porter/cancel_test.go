@@ -0,0 +1,144 @@
+package porter
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullWithCancelledContextFails(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("layer"), nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.PullArtifact(ctx, ref, true)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "error = %v", err)
+	assert.NoDirExists(t, filepath.Join(client.config.CacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(ref)))[:16]))
+
+	cached, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Empty(t, cached)
+}
+
+func TestDiscardCancelledPullRemovesStagingLayout(t *testing.T) {
+	client := newTestClient(t, nil)
+	staging := filepath.Join(client.config.CacheDir, "0123456789abcdef")
+	partial := partialBlobPath(staging, digest.FromString("layer"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(partial), 0755))
+	require.NoError(t, os.WriteFile(partial, []byte(strings.Repeat("x", 10)), 0644))
+
+	client.discardCancelledPull("0123456789abcdef", staging, false)
+	assert.NoDirExists(t, staging)
+}
+
+func TestPushFromCacheWithCancelledContextFails(t *testing.T) {
+	source := newTestRegistry(t)
+	source.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+
+	client := newTestClient(t, nil)
+	pulled, err := client.PullArtifact(context.Background(), source.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	dest := newTestRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.PushFromCache(ctx, pulled.ID, dest.Host()+"/mirror/tool:1.0.0", PushOptions{Insecure: true})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "error = %v", err)
+
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	assert.Empty(t, dest.tags["mirror/tool"])
+}
+
+func TestResolveChannelWithCancelledContextFails(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	client := newTestClient(t, nil)
+	repo := registry.Host() + "/team/tool"
+	_, err := client.SetChannel(context.Background(), "stable", repo+":1.0.0", true)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.ResolveChannel(ctx, repo, "stable", true)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "error = %v", err)
+}
+
+func TestRegistryQueriesWithCancelledContextFail(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	attachment := filepath.Join(t.TempDir(), "sbom.json")
+	require.NoError(t, os.WriteFile(attachment, []byte("{}"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queries := map[string]func() error{
+		"estimate": func() error {
+			_, err := client.EstimateTransfer(ctx, ref, true)
+			return err
+		},
+		"auth check": func() error {
+			_, err := client.CheckAuth(ctx, ref, AuthCheckOptions{Insecure: true})
+			return err
+		},
+		"diff": func() error {
+			_, err := client.DiffArtifacts(ctx, ref, ref, true)
+			return err
+		},
+		"attach": func() error {
+			_, err := client.AttachArtifact(ctx, ref, []string{attachment}, AttachOptions{ArtifactType: "application/spdx+json", Insecure: true})
+			return err
+		},
+		"referrers": func() error {
+			_, err := client.ListReferrers(ctx, ref, true, "")
+			return err
+		},
+		"repos": func() error {
+			_, err := client.ListRepositories(ctx, registry.Host(), RepositoryListOptions{Insecure: true})
+			return err
+		},
+		"exists": func() error {
+			_, err := client.CheckExists(ctx, ref, true)
+			return err
+		},
+		"tags": func() error {
+			_, err := client.ListTags(ctx, ref, TagListOptions{Insecure: true})
+			return err
+		},
+		"cat": func() error {
+			_, err := client.CatBlob(ctx, ref, io.Discard, CatOptions{Layer: 0, Insecure: true})
+			return err
+		},
+		"login": func() error {
+			_, err := client.Login(ctx, registry.Host(), LoginOptions{Username: "user", Password: "secret", Insecure: true})
+			return err
+		},
+	}
+	for name, query := range queries {
+		err := query()
+		require.Error(t, err, name)
+		assert.True(t, errors.Is(err, context.Canceled), "%s: error = %v", name, err)
+	}
+}
@@ -0,0 +1,198 @@
+package porter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/delivery-station/porter/internal/progress"
+)
+
+// AnnotationRequiresFeature lists the host capabilities an artifact needs, e.g.
+// "systemd, docker, min-memory=2GiB".
+const AnnotationRequiresFeature = "ds.requires.feature"
+
+// Host capabilities porter detects.
+const (
+	CapabilitySystemd   = "systemd"
+	CapabilityDocker    = "docker"
+	CapabilityGPU       = "gpu"
+	CapabilityMinMemory = "min-memory"
+)
+
+// HostCapabilities describes what the host porter exports to can run.
+type HostCapabilities struct {
+	// Features holds the detected capabilities, plus those the operator declares
+	// with settings.porter.host_features.
+	Features map[string]bool `json:"features"`
+	// MemoryBytes is the total memory of the host; zero when unknown.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+}
+
+// UnsatisfiedRequirement is returned when the host lacks capabilities an artifact
+// declares in its ds.requires.feature annotation.
+type UnsatisfiedRequirement struct {
+	Reference string   `json:"reference"`
+	Missing   []string `json:"missing"`
+}
+
+func (e *UnsatisfiedRequirement) Error() string {
+	return fmt.Sprintf("artifact %s requires host capabilities this host lacks: %s", e.Reference, strings.Join(e.Missing, ", "))
+}
+
+// detectHostCapabilities is replaced in tests.
+var detectHostCapabilities = hostCapabilities
+
+func hostCapabilities() HostCapabilities {
+	caps := HostCapabilities{Features: make(map[string]bool)}
+	if dockerAvailable() {
+		caps.Features[CapabilityDocker] = true
+	}
+	detectPlatformCapabilities(&caps)
+	return caps
+}
+
+// dockerAvailable reports whether a Docker engine is reachable through DOCKER_HOST
+// or its default socket.
+func dockerAvailable() bool {
+	if strings.TrimSpace(os.Getenv("DOCKER_HOST")) != "" {
+		return true
+	}
+	for _, socket := range []string{"/var/run/docker.sock", `\\.\pipe\docker_engine`} {
+		if _, err := os.Stat(socket); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostCapabilities compares the ds.requires.feature annotation of an artifact
+// against the capabilities of this host, under the configured requirement policy.
+func (c *Client) checkHostCapabilities(ref string, annotations map[string]string) error {
+	policy := c.requirementPolicy()
+	if policy == RequirementPolicyIgnore {
+		return nil
+	}
+
+	missing, err := c.missingCapabilities(ref, annotations)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if policy == RequirementPolicyWarn {
+		c.logger.Warn("Host lacks capabilities the artifact requires", "ref", ref, "missing", strings.Join(missing, ", "))
+		return nil
+	}
+	return &UnsatisfiedRequirement{Reference: ref, Missing: missing}
+}
+
+// auditHostCapabilities records the ds.requires.feature evaluation under the
+// configured policy.
+func (c *Client) auditHostCapabilities(record *AuditRecord, ref string, annotations map[string]string) {
+	if strings.TrimSpace(annotations[AnnotationRequiresFeature]) == "" {
+		return
+	}
+	policy := c.requirementPolicy()
+	if policy == RequirementPolicyIgnore {
+		record.Note("host_capabilities", AuditSkipped, "requirement_policy is ignore")
+		return
+	}
+	missing, err := c.missingCapabilities(ref, annotations)
+	switch {
+	case err != nil:
+		record.Check("host_capabilities", err)
+	case len(missing) == 0:
+		record.Check("host_capabilities", nil)
+	case policy == RequirementPolicyWarn:
+		record.Note("host_capabilities", AuditWarn, "host lacks "+strings.Join(missing, ", "))
+	default:
+		record.Check("host_capabilities", &UnsatisfiedRequirement{Reference: ref, Missing: missing})
+	}
+}
+
+// missingCapabilities returns each capability of the ds.requires.feature annotation
+// this host does not have. A min-memory requirement that cannot be evaluated
+// because the host memory is unknown is logged and skipped.
+func (c *Client) missingCapabilities(ref string, annotations map[string]string) ([]string, error) {
+	required := strings.TrimSpace(annotations[AnnotationRequiresFeature])
+	if required == "" {
+		return nil, nil
+	}
+
+	caps := detectHostCapabilities()
+	for _, feature := range c.config.HostFeatures {
+		if feature = strings.ToLower(strings.TrimSpace(feature)); feature != "" {
+			caps.Features[feature] = true
+		}
+	}
+
+	var missing []string
+	for _, entry := range strings.Split(required, ",") {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(entry), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		if name == CapabilityMinMemory {
+			want, err := parseSize(value)
+			if !hasValue || err != nil {
+				return nil, fmt.Errorf("artifact %s has an invalid %s annotation: %s needs a size such as 2GiB", ref, AnnotationRequiresFeature, CapabilityMinMemory)
+			}
+			if caps.MemoryBytes == 0 {
+				c.logger.Warn("Cannot check memory requirement; host memory unknown", "requires", value)
+				continue
+			}
+			if caps.MemoryBytes < want {
+				missing = append(missing, fmt.Sprintf("%s=%s (host has %s)", CapabilityMinMemory, strings.TrimSpace(value), progress.FormatBytes(caps.MemoryBytes)))
+			}
+			continue
+		}
+
+		if !caps.Features[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// parseSize parses sizes such as "512MiB", "2G", or "1073741824". Binary
+// suffixes (Ki, Mi, Gi, Ti, optionally followed by B) use powers of 1024, decimal
+// ones (K, M, G, T, optionally followed by B) powers of 1000.
+func parseSize(value string) (int64, error) {
+	trimmed := strings.TrimSpace(value)
+	end := len(trimmed)
+	for end > 0 && (trimmed[end-1] < '0' || trimmed[end-1] > '9') {
+		end--
+	}
+	number, unit := trimmed[:end], strings.ToLower(strings.TrimSpace(trimmed[end:]))
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	unit = strings.TrimSuffix(unit, "b")
+	multipliers := map[string]int64{
+		"":   1,
+		"k":  1000,
+		"m":  1000 * 1000,
+		"g":  1000 * 1000 * 1000,
+		"t":  1000 * 1000 * 1000 * 1000,
+		"ki": 1 << 10,
+		"mi": 1 << 20,
+		"gi": 1 << 30,
+		"ti": 1 << 40,
+	}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package porter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// detectPlatformCapabilities detects systemd, GPU devices, and total memory.
+func detectPlatformCapabilities(caps *HostCapabilities) {
+	// sd_booted(3): systemd is PID 1 when this directory exists.
+	if info, err := os.Stat("/run/systemd/system"); err == nil && info.IsDir() {
+		caps.Features[CapabilitySystemd] = true
+	}
+
+	if _, err := os.Stat("/dev/nvidia0"); err == nil {
+		caps.Features[CapabilityGPU] = true
+	} else if nodes, _ := filepath.Glob("/dev/dri/renderD*"); len(nodes) > 0 {
+		caps.Features[CapabilityGPU] = true
+	}
+
+	caps.MemoryBytes = totalMemory()
+}
+
+// totalMemory returns MemTotal from /proc/meminfo, or zero when it is unavailable.
+func totalMemory() int64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kib, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kib * 1024
+		}
+	}
+	return 0
+}
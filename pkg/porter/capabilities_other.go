@@ -0,0 +1,7 @@
+//go:build !linux
+
+package porter
+
+// detectPlatformCapabilities detects nothing beyond Docker outside Linux; declare
+// other capabilities with settings.porter.host_features.
+func detectPlatformCapabilities(*HostCapabilities) {}
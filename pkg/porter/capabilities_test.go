@@ -0,0 +1,73 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubHostCapabilities(t *testing.T, caps HostCapabilities) {
+	t.Helper()
+	previous := detectHostCapabilities
+	detectHostCapabilities = func() HostCapabilities {
+		features := make(map[string]bool, len(caps.Features))
+		for name, ok := range caps.Features {
+			features[name] = ok
+		}
+		return HostCapabilities{Features: features, MemoryBytes: caps.MemoryBytes}
+	}
+	t.Cleanup(func() { detectHostCapabilities = previous })
+}
+
+func TestExportEnforcesHostCapabilities(t *testing.T) {
+	stubHostCapabilities(t, HostCapabilities{Features: map[string]bool{CapabilitySystemd: true}, MemoryBytes: 1 << 30})
+
+	registry := newTestRegistry(t)
+	addAnnotatedArtifact(registry, "team/agent", "1.0.0", []byte("agent"), map[string]string{
+		AnnotationRequiresFeature: "systemd, docker, gpu, min-memory=2GiB",
+	})
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/agent:1.0.0", true)
+	require.NoError(t, err)
+
+	out := filepath.Join(t.TempDir(), "agent")
+	_, err = client.ExportArtifact(context.Background(), result, out, ExportOptions{})
+	var unsatisfied *UnsatisfiedRequirement
+	require.True(t, errors.As(err, &unsatisfied), "got %v", err)
+	assert.Equal(t, []string{"docker", "gpu", "min-memory=2GiB (host has 1.0 GiB)"}, unsatisfied.Missing)
+	_, statErr := os.Stat(out)
+	assert.True(t, os.IsNotExist(statErr), "nothing may be exported")
+
+	client.config.HostFeatures = []string{"Docker", "gpu"}
+	stubHostCapabilities(t, HostCapabilities{Features: map[string]bool{CapabilitySystemd: true}, MemoryBytes: 4 << 30})
+	_, err = client.ExportArtifact(context.Background(), result, out, ExportOptions{})
+	require.NoError(t, err)
+
+	client.config.HostFeatures = nil
+	client.config.RequirementPolicy = RequirementPolicyWarn
+	_, err = client.ExportArtifact(context.Background(), result, filepath.Join(t.TempDir(), "agent"), ExportOptions{})
+	require.NoError(t, err)
+}
+
+func TestParseMemorySize(t *testing.T) {
+	for value, want := range map[string]int64{
+		"1024":   1024,
+		"512MiB": 512 << 20,
+		"2Gi":    2 << 30,
+		"2G":     2000000000,
+		"4 GB":   4000000000,
+	} {
+		got, err := parseSize(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	for _, value := range []string{"", "GiB", "2PiB", "-1"} {
+		_, err := parseSize(value)
+		assert.Error(t, err, value)
+	}
+}
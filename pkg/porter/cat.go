@@ -0,0 +1,153 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// CatOptions selects the blob CatBlob writes. At most one of Layer, Digest and
+// Config is set; without any, the only layer of the manifest is written.
+type CatOptions struct {
+	Insecure bool
+	// Layer is the position of the layer in the manifest, counting from 0, or -1.
+	Layer int
+	// Digest names a layer, config or manifest anywhere in the artifact.
+	Digest string
+	// Config selects the config of the manifest.
+	Config bool
+	// Platforms selects the manifest of a multi-platform artifact.
+	Platforms []ocispec.Platform
+}
+
+// CatBlob writes a blob of ref to w straight from the registry, without caching
+// it, and describes the blob it wrote. The blob is verified against its digest
+// as it is written, so when it does not match w has already received it; the
+// returned error reports that.
+func (c *Client) CatBlob(ctx context.Context, ref string, w io.Writer, opts CatOptions) (*StreamedLayer, error) {
+	selectors := 0
+	for _, set := range []bool{opts.Layer >= 0, opts.Digest != "", opts.Config} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return nil, fmt.Errorf("select a blob with only one of --layer, --digest and --config")
+	}
+
+	imgRef, err := c.parseReference(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, root, err := c.resolveRemote(ctx, imgRef, ref, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	var blob ocispec.Descriptor
+	var platform *ocispec.Platform
+	if opts.Digest != "" {
+		dgst, err := digest.Parse(opts.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest %q: %w", opts.Digest, err)
+		}
+		blob, platform, err = findBlob(ctx, repo, root, dgst, make(map[string]struct{}))
+		if err != nil {
+			return nil, err
+		}
+		if blob.Digest == "" {
+			return nil, fmt.Errorf("%s is not part of %s", dgst, reference.Redact(ref))
+		}
+	} else {
+		manifests, err := c.selectManifests(ctx, repo, root, ExportOptions{Platforms: opts.Platforms})
+		if err != nil {
+			return nil, err
+		}
+		if len(manifests) != 1 {
+			return nil, fmt.Errorf("%d platforms match; select one with --platform", len(manifests))
+		}
+		platform = manifests[0].Platform
+		manifest, err := fetchManifest(ctx, repo, manifests[0].Descriptor)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case opts.Config:
+			blob = manifest.Config
+		case opts.Layer >= 0:
+			if opts.Layer >= len(manifest.Layers) {
+				return nil, fmt.Errorf("layer %d out of range; the manifest has %d layers", opts.Layer, len(manifest.Layers))
+			}
+			blob = manifest.Layers[opts.Layer]
+		case len(manifest.Layers) == 1:
+			blob = manifest.Layers[0]
+		default:
+			return nil, fmt.Errorf("the manifest has %d layers; select one with --layer, --digest or --config", len(manifest.Layers))
+		}
+	}
+
+	reader, err := repo.Fetch(ctx, blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch blob %s: %w", blob.Digest, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	verifier := blob.Digest.Verifier()
+	if _, err := io.Copy(w, io.TeeReader(reader, verifier)); err != nil {
+		return nil, fmt.Errorf("failed to write blob %s: %w", blob.Digest, err)
+	}
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("blob %s does not match its digest", blob.Digest)
+	}
+
+	c.logger.Info("Wrote blob", "ref", reference.Redact(ref), "digest", blob.Digest, "size", blob.Size)
+	return &StreamedLayer{
+		Digest:    blob.Digest.String(),
+		Size:      blob.Size,
+		MediaType: blob.MediaType,
+		Platform:  platform,
+	}, nil
+}
+
+// findBlob searches the graph under desc for the descriptor of dgst, returning a
+// zero descriptor when the artifact does not reference it.
+func findBlob(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, dgst digest.Digest, seen map[string]struct{}) (ocispec.Descriptor, *ocispec.Platform, error) {
+	if desc.Digest == dgst {
+		return desc, desc.Platform, nil
+	}
+	if _, ok := seen[desc.Digest.String()]; ok {
+		return ocispec.Descriptor{}, nil, nil
+	}
+	seen[desc.Digest.String()] = struct{}{}
+
+	if isIndexDescriptor(desc) {
+		index, err := fetchIndex(ctx, fetcher, desc)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		for _, child := range index.Manifests {
+			found, platform, err := findBlob(ctx, fetcher, child, dgst, seen)
+			if err != nil || found.Digest != "" {
+				return found, platform, err
+			}
+		}
+		return ocispec.Descriptor{}, nil, nil
+	}
+
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	for _, blob := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+		if blob.Digest == dgst {
+			return blob, desc.Platform, nil
+		}
+	}
+	return ocispec.Descriptor{}, nil, nil
+}
@@ -0,0 +1,49 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatBlobSelectsLayerConfigAndDigest(t *testing.T) {
+	registry := newTestRegistry(t)
+	publishRelease(registry, "1.0.0", nil, map[string]map[string]string{
+		"amd64": {"a.txt": "first layer", "b.txt": "second layer"},
+		"arm64": {"a.txt": "arm layer"},
+	})
+	ref := registry.Host() + "/team/app:1.0.0"
+	client := newTestClient(t, nil)
+	amd64 := []ocispec.Platform{{OS: "linux", Architecture: "amd64"}}
+
+	var out bytes.Buffer
+	blob, err := client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: 1, Insecure: true, Platforms: amd64})
+	require.NoError(t, err)
+	assert.Equal(t, "second layer", out.String())
+	assert.Equal(t, "linux/amd64", formatPlatform(blob.Platform))
+
+	out.Reset()
+	blob, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: -1, Config: true, Insecure: true, Platforms: amd64})
+	require.NoError(t, err)
+	assert.Equal(t, "{}", out.String())
+	assert.Equal(t, ocispec.MediaTypeEmptyJSON, blob.MediaType)
+
+	out.Reset()
+	_, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: -1, Digest: digest.FromString("arm layer").String(), Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, "arm layer", out.String())
+
+	_, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: -1, Insecure: true})
+	assert.ErrorContains(t, err, "2 platforms match")
+	_, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: -1, Insecure: true, Platforms: amd64})
+	assert.ErrorContains(t, err, "select one with --layer")
+	_, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: 5, Insecure: true, Platforms: amd64})
+	assert.ErrorContains(t, err, "out of range")
+	_, err = client.CatBlob(context.Background(), ref, &out, CatOptions{Layer: -1, Digest: digest.FromString("other").String(), Insecure: true})
+	assert.ErrorContains(t, err, "is not part of")
+}
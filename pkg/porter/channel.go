@@ -0,0 +1,267 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+const (
+	// ArtifactTypeChannels marks a channel pointer artifact, whose single layer is a
+	// ChannelIndex document.
+	ArtifactTypeChannels = "application/vnd.delivery-station.channels.v1+json"
+	// ChannelsTag is the tag of the channel pointer artifact in each repository.
+	ChannelsTag = "ds-channels"
+
+	channelUpdateAttempts = 5
+)
+
+// ChannelIndex maps the release channels of a repository, such as "stable" or
+// "beta", to the digests they currently point at.
+type ChannelIndex struct {
+	Channels map[string]ChannelEntry `json:"channels"`
+}
+
+// ChannelEntry is the artifact a channel points at.
+type ChannelEntry struct {
+	Digest string `json:"digest"`
+	// Reference is the reference the channel was set from, e.g. the release tag.
+	Reference string    `json:"reference,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChannelUpdate describes a channel moved by SetChannel.
+type ChannelUpdate struct {
+	Repository string `json:"repository"`
+	Channel    string `json:"channel"`
+	Digest     string `json:"digest"`
+	// Previous is the digest the channel pointed at before; empty for a new channel.
+	Previous string `json:"previous,omitempty"`
+	// Pointer is the digest of the new channel pointer artifact.
+	Pointer string `json:"pointer"`
+}
+
+// ChannelList is the result of Channels.
+type ChannelList struct {
+	Repository string                  `json:"repository"`
+	Pointer    string                  `json:"pointer,omitempty"`
+	Channels   map[string]ChannelEntry `json:"channels"`
+}
+
+func validateChannelName(channel string) error {
+	if !reference.IsTag(channel) {
+		return fmt.Errorf("invalid channel name %q: use letters, digits, '.', '_' and '-'", channel)
+	}
+	return nil
+}
+
+// SetChannel points channel of the repository of ref at the digest ref resolves
+// to, by publishing a new channel pointer artifact at <repository>:ds-channels.
+// Registries cannot swap a tag conditionally, so the pointer is re-read right
+// before the tag is moved and the update retried when another publisher changed
+// it in the meantime.
+func (c *Client) SetChannel(ctx context.Context, channel, ref string, insecure bool) (*ChannelUpdate, error) {
+	channel = strings.TrimSpace(channel)
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	target := parsed.Digest.String()
+	if !parsed.IsDigest() {
+		if target, err = c.ResolveDigest(ctx, ref, insecure); err != nil {
+			return nil, err
+		}
+	}
+
+	repo, err := c.channelRepository(parsed, insecure)
+	if err != nil {
+		return nil, err
+	}
+	push := &imagePusher{client: c, ctx: ctx, dst: repo, insecure: insecure}
+
+	for attempt := 1; attempt <= channelUpdateAttempts; attempt++ {
+		current, found, err := resolveChannels(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		index := &ChannelIndex{Channels: make(map[string]ChannelEntry)}
+		if found {
+			if index, err = loadChannels(ctx, repo, current); err != nil {
+				return nil, err
+			}
+		}
+
+		update := &ChannelUpdate{Repository: parsed.Name(), Channel: channel, Digest: target, Previous: index.Channels[channel].Digest}
+		index.Channels[channel] = ChannelEntry{Digest: target, Reference: parsed.String(), UpdatedAt: time.Now().UTC()}
+		pointer, err := pushChannels(push, index)
+		if err != nil {
+			return nil, err
+		}
+
+		latest, stillFound, err := resolveChannels(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		if stillFound != found || latest.Digest != current.Digest {
+			c.logger.Warn("Channel pointer changed concurrently, retrying", "repository", parsed.Name(), "attempt", attempt)
+			continue
+		}
+		if err := repo.Tag(ctx, pointer, ChannelsTag); err != nil {
+			return nil, fmt.Errorf("failed to tag channel pointer: %w", err)
+		}
+
+		update.Pointer = pointer.Digest.String()
+		c.logger.Info("Set channel", "repository", parsed.Name(), "channel", channel, "digest", target, "previous", update.Previous)
+		return update, nil
+	}
+	return nil, fmt.Errorf("channel pointer of %s kept changing; gave up after %d attempts", parsed.Name(), channelUpdateAttempts)
+}
+
+// Channels lists the channels of the repository of ref.
+func (c *Client) Channels(ctx context.Context, ref string, insecure bool) (*ChannelList, error) {
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, err := c.channelRepository(parsed, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ChannelList{Repository: parsed.Name(), Channels: make(map[string]ChannelEntry)}
+	desc, found, err := resolveChannels(ctx, repo)
+	if err != nil || !found {
+		return list, err
+	}
+	index, err := loadChannels(ctx, repo, desc)
+	if err != nil {
+		return nil, err
+	}
+	list.Pointer = desc.Digest.String()
+	list.Channels = index.Channels
+	return list, nil
+}
+
+// ResolveChannel returns the digest reference channel of the repository of ref
+// points at. Any tag or digest of ref is ignored.
+func (c *Client) ResolveChannel(ctx context.Context, ref, channel string, insecure bool) (string, error) {
+	channel = strings.TrimSpace(channel)
+	if err := validateChannelName(channel); err != nil {
+		return "", err
+	}
+	list, err := c.Channels(ctx, ref, insecure)
+	if err != nil {
+		return "", err
+	}
+	entry, ok := list.Channels[channel]
+	if !ok {
+		known := make([]string, 0, len(list.Channels))
+		for name := range list.Channels {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		if len(known) == 0 {
+			return "", fmt.Errorf("%s has no release channels", list.Repository)
+		}
+		return "", fmt.Errorf("%s has no channel %q; channels: %s", list.Repository, channel, strings.Join(known, ", "))
+	}
+	c.logger.Debug("Resolved channel", "repository", list.Repository, "channel", channel, "digest", entry.Digest)
+	return list.Repository + "@" + entry.Digest, nil
+}
+
+func (c *Client) channelRepository(parsed reference.Reference, insecure bool) (*remote.Repository, error) {
+	imgRef, err := c.parseReference(parsed.Name()+":"+ChannelsTag, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	return c.remoteRepository(imgRef, insecure)
+}
+
+// resolveChannels resolves the channel pointer of repo, reporting false when the
+// repository has none yet.
+func resolveChannels(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, bool, error) {
+	desc, err := repo.Resolve(ctx, ChannelsTag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return ocispec.Descriptor{}, false, nil
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, false, fmt.Errorf("failed to resolve channel pointer: %w", err)
+	}
+	return desc, true, nil
+}
+
+// loadChannels reads the ChannelIndex of the channel pointer artifact desc.
+func loadChannels(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) (*ChannelIndex, error) {
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.ArtifactType != ArtifactTypeChannels {
+		return nil, fmt.Errorf("tag %s holds a %q artifact, not a channel pointer", ChannelsTag, manifest.ArtifactType)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ArtifactTypeChannels {
+			continue
+		}
+		data, err := content.FetchAll(ctx, fetcher, layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch channel pointer: %w", err)
+		}
+		var index ChannelIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse channel pointer: %w", err)
+		}
+		if index.Channels == nil {
+			index.Channels = make(map[string]ChannelEntry)
+		}
+		return &index, nil
+	}
+	return nil, fmt.Errorf("channel pointer artifact has no %s layer", ArtifactTypeChannels)
+}
+
+// pushChannels uploads index as an untagged channel pointer artifact.
+func pushChannels(push *imagePusher, index *ChannelIndex) (ocispec.Descriptor, error) {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode channel pointer: %w", err)
+	}
+	layerDesc := content.NewDescriptorFromBytes(ArtifactTypeChannels, data)
+	manifest := ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: ArtifactTypeChannels,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{layerDesc},
+		Annotations:  map[string]string{ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339)},
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode channel pointer manifest: %w", err)
+	}
+	manifestDesc := content.NewDescriptorFromBytes(manifest.MediaType, manifestData)
+
+	if err := push.bytes(ocispec.DescriptorEmptyJSON, ocispec.DescriptorEmptyJSON.Data); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push channel pointer config: %w", err)
+	}
+	if err := push.bytes(layerDesc, data); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push channel pointer: %w", err)
+	}
+	if err := push.bytes(manifestDesc, manifestData); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push channel pointer manifest: %w", err)
+	}
+	return manifestDesc, nil
+}
@@ -0,0 +1,64 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetChannelPromotesReleases(t *testing.T) {
+	registry := newTestRegistry(t)
+	first := registry.AddArtifact("team/tool", "1.4.1", "application/octet-stream", []byte("tool 1.4.1"), nil)
+	second := registry.AddArtifact("team/tool", "1.4.2", "application/octet-stream", []byte("tool 1.4.2"), nil)
+	client := newTestClient(t, nil)
+	repo := registry.Host() + "/team/tool"
+
+	update, err := client.SetChannel(context.Background(), "stable", repo+":1.4.1", true)
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest.String(), update.Digest)
+	assert.Empty(t, update.Previous)
+	assert.NotEmpty(t, update.Pointer)
+
+	_, err = client.SetChannel(context.Background(), "beta", repo+":1.4.2", true)
+	require.NoError(t, err)
+	update, err = client.SetChannel(context.Background(), "stable", repo+":1.4.2", true)
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest.String(), update.Previous)
+
+	list, err := client.Channels(context.Background(), repo, true)
+	require.NoError(t, err)
+	assert.Equal(t, update.Pointer, list.Pointer)
+	require.Len(t, list.Channels, 2)
+	assert.Equal(t, second.Digest.String(), list.Channels["stable"].Digest)
+	assert.Equal(t, repo+":1.4.2", list.Channels["stable"].Reference)
+
+	resolved, err := client.ResolveChannel(context.Background(), repo+":ignored", "stable", true)
+	require.NoError(t, err)
+	assert.Equal(t, repo+"@"+second.Digest.String(), resolved)
+
+	pulled, err := client.PullArtifact(context.Background(), resolved, true)
+	require.NoError(t, err)
+	assert.Equal(t, second.Digest.String(), pulled.Digest)
+}
+
+func TestResolveChannelErrors(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	client := newTestClient(t, nil)
+	repo := registry.Host() + "/team/tool"
+
+	_, err := client.ResolveChannel(context.Background(), repo, "stable", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no release channels")
+
+	_, err = client.SetChannel(context.Background(), "stable", repo+":1.0.0", true)
+	require.NoError(t, err)
+	_, err = client.ResolveChannel(context.Background(), repo, "beta", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "channels: stable")
+
+	_, err = client.SetChannel(context.Background(), "not a channel", repo+":1.0.0", true)
+	require.Error(t, err)
+}
@@ -2,36 +2,68 @@ package porter
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/delivery-station/porter/internal/spans"
+	"github.com/delivery-station/porter/internal/tarxattr"
+	"github.com/delivery-station/porter/pkg/reference"
 	"github.com/delivery-station/porter/pkg/release"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 // Client handles OCI artifact operations as a DS plugin
 type Client struct {
-	config *Config
-	logger hclog.Logger
+	config  *Config
+	logger  hclog.Logger
+	faults  *faults.Injector
+	staging *release.Staging
+	naming  *namingRules
+	// redactor scrubs the configured secrets, and the credentials resolved since,
+	// from results and errors.
+	redactor *Redactor
+	// policies are the pull policies every pull is evaluated against.
+	policies []PullPolicy
+	// chunkSize is the parsed push.chunk_size.
+	chunkSize int64
+	// tracerProvider exports the spans of the client; nil when tracing is off.
+	tracerProvider *sdktrace.TracerProvider
+	// auditLog records every pull, push and export; nil when it is disabled.
+	auditLog *auditLog
+	// timeouts are the parsed pull, push and request timeouts.
+	timeouts timeouts
+	// bandwidth paces registry traffic to max_bandwidth; nil when uncapped.
+	bandwidth *bandwidthLimiter
+
+	transportsMu sync.Mutex
+	transports   map[string]http.RoundTripper
+	throttles    map[string]*throttle
+	netState     network
 }
 
 // Config holds Porter plugin configuration provided by DS
@@ -40,6 +72,88 @@ type Config struct {
 	CacheDir   string              `json:"cache_dir"`
 	LogLevel   string              `json:"log_level"`
 	Logging    types.LoggingConfig `json:"logging"`
+	Export     ExportConfig        `json:"export"`
+	// CacheTTL is how long a pull by tag trusts that the tag still resolves to
+	// the cached digest before asking the registry again. Zero asks on every pull.
+	// Pulls by digest never ask.
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
+	// CacheMaxSize bounds the bytes the cache directory may use; zero leaves it
+	// unbounded. CacheFullPolicy decides what a pull that would exceed it does:
+	// CacheFullEvict (default) or CacheFullRefuse.
+	CacheMaxSize    int64  `json:"cache_max_size,omitempty"`
+	CacheFullPolicy string `json:"cache_full_policy,omitempty"`
+	// Concurrency bounds how many blobs are transferred, and how many platforms are
+	// exported, in parallel. Zero uses DefaultConcurrency.
+	Concurrency int `json:"concurrency,omitempty"`
+	// Aliases maps short names to repository prefixes, e.g. "ds" to
+	// "ghcr.io/delivery-station", so "ds/porter:1.0" can be used as a reference.
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Mirrors maps a normalized registry to the endpoints pulls try before it, in
+	// order. An endpoint may carry a repository prefix and an http:// scheme.
+	Mirrors map[string][]string `json:"mirrors,omitempty"`
+	// Proxy holds the default proxies for registry and destination traffic.
+	Proxy ProxyConfig `json:"proxy,omitempty"`
+	// DoH is a DNS-over-HTTPS endpoint used instead of the system resolver.
+	DoH string `json:"doh,omitempty"`
+	// Extract relaxes the permission checks applied to exported files.
+	Extract ExtractPolicy `json:"extract,omitempty"`
+	// Staging configures the workspace directories are archived into for pushes.
+	Staging StagingConfig `json:"staging,omitempty"`
+	// ResultSigningKey is the shared key used to HMAC execution results; empty disables signing.
+	ResultSigningKey string `json:"-"`
+	// RequirementPolicy decides what happens when an artifact's ds.requires.*
+	// annotations are not met: "enforce" (default), "warn", or "ignore".
+	RequirementPolicy string `json:"requirement_policy,omitempty"`
+	// Version and DSVersion are the running porter and DS versions checked against
+	// those requirements.
+	Version   string `json:"-"`
+	DSVersion string `json:"-"`
+	// PluginsDir is the DS plugin directory, used to report whether the plugins
+	// named by finalizers are installed.
+	PluginsDir string `json:"plugins_dir,omitempty"`
+	// HostFeatures declares host capabilities porter cannot detect, satisfying
+	// ds.requires.feature annotations that name them.
+	HostFeatures []string `json:"host_features,omitempty"`
+	// Naming restricts the references pushes may write to.
+	Naming NamingPolicy `json:"naming,omitempty"`
+	// Push holds the defaults of push.
+	Push PushConfig `json:"push,omitempty"`
+	// CredentialsFile is where login stores credentials; empty uses
+	// ~/.ds/porter/credentials.json.
+	CredentialsFile string `json:"credentials_file,omitempty"`
+	// CredentialsStore selects where login stores credentials: "file" (the
+	// default), "keychain" for the credential store of the OS, or the name of a
+	// docker-credential helper such as pass.
+	CredentialsStore string `json:"credentials_store,omitempty"`
+	// Redactor scrubs secrets from logs, errors and results. NewClient creates one
+	// when it is nil and registers the secrets of the configuration with it; hosts
+	// set it to share it with the logger they pass.
+	Redactor *Redactor `json:"-"`
+	// PolicyFile is a YAML pull policy file; see PolicyFile.
+	PolicyFile string `json:"policy_file,omitempty"`
+	// PullPolicy is evaluated by every pull in addition to PolicyFile.
+	PullPolicy PullPolicy `json:"-"`
+	// RequireDigest refuses pulls by tag; references must pin a digest.
+	RequireDigest bool `json:"require_digest,omitempty"`
+	// Tracing exports OpenTelemetry traces over OTLP.
+	Tracing TracingConfig `json:"tracing,omitempty"`
+	// AuditLog configures the audit log, and JobID is the DS job recorded in it.
+	AuditLog AuditLogConfig `json:"audit_log,omitempty"`
+	JobID    string         `json:"-"`
+	// Events receives an event for every artifact pulled, exported or pushed;
+	// nil publishes nothing. EventSubscribers are the plugins, as name or
+	// name:operation, the porter plugin asks DS to run with each event.
+	Events           EventPublisher `json:"-"`
+	EventSubscribers []string       `json:"event_subscribers,omitempty"`
+	// PullTimeout and PushTimeout bound a whole pull or push, and RequestTimeout
+	// how long a registry request may make no progress, as durations such as
+	// "30m"; empty never times out.
+	PullTimeout    string `json:"pull_timeout,omitempty"`
+	PushTimeout    string `json:"push_timeout,omitempty"`
+	RequestTimeout string `json:"request_timeout,omitempty"`
+	// MaxBandwidth caps the registry traffic of pulls and pushes, e.g. "20MiB/s";
+	// empty leaves it uncapped.
+	MaxBandwidth string `json:"max_bandwidth,omitempty"`
 }
 
 // RegistryConfig holds OCI registry configuration
@@ -49,6 +163,14 @@ type RegistryConfig struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 	Token    string `json:"token,omitempty"`
+	// Proxy routes traffic to this registry through a proxy URL, or around every
+	// proxy when set to ProxyDirect. Empty uses Config.Proxy.
+	Proxy string `json:"proxy,omitempty"`
+	// ClientCert and ClientKey are PEM files presented to registries that require
+	// mutual TLS. CACert adds a PEM bundle to the trusted roots.
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	CACert     string `json:"ca_cert,omitempty"`
 }
 
 // ArtifactResult represents the result of pull/push operations
@@ -63,6 +185,20 @@ type ArtifactResult struct {
 	Cached        bool                 `json:"cached"`
 	CachedAt      time.Time            `json:"cached_at,omitempty"`
 	ExportedFiles []string             `json:"exported_files,omitempty"`
+	// VerifiedFiles maps each file the export wrote to the digest it had when read
+	// back from the destination. Files skipped as unchanged are not re-read.
+	VerifiedFiles map[string]string `json:"verified_files,omitempty"`
+	// PlannedFiles lists what a dry-run export would write instead of writing it.
+	PlannedFiles []PlannedChange `json:"planned_files,omitempty"`
+	// Streamed is the layer written to stdout by an export to StdoutDestination.
+	Streamed *StreamedLayer `json:"streamed,omitempty"`
+	// Finalizers previews the finalizers DS will run once the pull completes.
+	Finalizers []FinalizerPreview `json:"finalizers,omitempty"`
+	// Metrics summarizes the pull or push that produced the result.
+	Metrics *OperationMetrics `json:"metrics,omitempty"`
+	// RateLimit is the pull rate limit the registry reported, as Docker Hub
+	// does; nil when it reported none or the pull was served from the cache.
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
 }
 
 // PluginExecutionInfo contains information for executing plugins on artifacts
@@ -77,6 +213,77 @@ type ExportOptions struct {
 	AllPlatforms       bool
 	Platforms          []ocispec.Platform
 	UsePlatformSubdirs bool
+	// Shared forces network-share handling (export lock plus write-then-rename) even
+	// when the destination is not detected as an NFS or SMB mount.
+	Shared bool
+	// Full rewrites every file, even those the destination's delivery manifest
+	// shows as unchanged or an interrupted extraction already wrote.
+	Full bool
+	// verified collects the files an export wrote and verified, for
+	// ArtifactResult.VerifiedFiles. Only exports to local destinations set it.
+	verified *exportVerification
+	// Versioned exports into <destination>/<version> and points the
+	// <destination>/current symlink at it once the export succeeds.
+	Versioned bool
+	// Version names the version directory; empty derives it from the artifact.
+	Version string
+	// Overwrite decides what happens to files already present at a local
+	// destination: OverwriteReplace (default), OverwriteSkipExisting, or
+	// OverwriteErrorIfExists.
+	Overwrite string
+	// OutputTemplate names exported files instead of the <os>/<arch>/ scheme, e.g.
+	// "{{.Name}}-{{.OS}}-{{.Arch}}{{.Ext}}". Every platform is then exported flat
+	// into the destination directory; see OutputTemplateData.
+	OutputTemplate string
+	// OCIArchive writes the whole artifact, every platform included, as an
+	// oci-layout tarball instead of extracting its layers. A destination directory
+	// receives <name>.oci.tar.
+	OCIArchive bool
+	// DockerArchive writes the image of the selected platform as a tarball that
+	// docker load accepts instead of extracting its layers. A destination
+	// directory receives <name>.docker.tar.
+	DockerArchive bool
+}
+
+// Overwrite policies for ExportOptions.Overwrite.
+const (
+	// OverwriteReplace atomically replaces existing files.
+	OverwriteReplace = "overwrite"
+	// OverwriteSkipExisting leaves existing files alone.
+	OverwriteSkipExisting = "skip-existing"
+	// OverwriteErrorIfExists fails the export when a file already exists.
+	OverwriteErrorIfExists = "error-if-exists"
+)
+
+// validateOverwrite rejects unknown overwrite policies.
+func validateOverwrite(policy string) error {
+	switch policy {
+	case "", OverwriteReplace, OverwriteSkipExisting, OverwriteErrorIfExists:
+		return nil
+	}
+	return fmt.Errorf("unknown overwrite policy %q: expected %s, %s, or %s", policy, OverwriteReplace, OverwriteSkipExisting, OverwriteErrorIfExists)
+}
+
+// PushOptions controls how artifacts are published.
+type PushOptions struct {
+	Insecure bool
+	Sign     release.SignConfig
+	SBOMs    []string
+	// Compression archives directories with gzip (default) or zstd. Empty uses the
+	// manifest's compression.
+	Compression string
+	// TagLatest overrides whether the index is also tagged "latest"; nil uses the
+	// configured push.tag_latest.
+	TagLatest *bool
+	// Tags are further tags the index is given in the destination repository once
+	// it is uploaded, by tagging it in the registry rather than pushing it again.
+	Tags []string
+	// SemverAliases also tags the index with the major and minor aliases of its
+	// semantic version tag; see ApplySemverAliases.
+	SemverAliases bool
+	// Metadata is packed into the config of every platform manifest, over the
+	// metadata section of a pushed ds.manifest.yaml.
+	Metadata *release.ArtifactMetadata
 }
 
 // LoadConfigFromHost retrieves configuration provided by the DS host via the plugin RPC context.
@@ -111,7 +318,7 @@ func buildConfigFromDS(dsConfig *types.Config) *Config {
 	seen := make(map[string]struct{})
 
 	for _, cred := range dsConfig.Auth.Credentials {
-		host := normalizeRegistryHost(cred.Registry)
+		host := reference.NormalizeRegistry(cred.Registry)
 		if host == "" {
 			continue
 		}
@@ -135,7 +342,7 @@ func buildConfigFromDS(dsConfig *types.Config) *Config {
 		seen[host] = struct{}{}
 	}
 
-	if defaultRegistry := normalizeRegistryHost(dsConfig.Registry.Default); defaultRegistry != "" {
+	if defaultRegistry := reference.NormalizeRegistry(dsConfig.Registry.Default); defaultRegistry != "" {
 		if _, exists := seen[defaultRegistry]; !exists {
 			registries = append(registries, RegistryConfig{
 				Name: defaultRegistry,
@@ -150,11 +357,48 @@ func buildConfigFromDS(dsConfig *types.Config) *Config {
 		Output: strings.TrimSpace(dsConfig.Logging.Output),
 	}
 
+	registries = applyRegistrySettings(registries, dsConfig.Plugins.Settings["porter"])
+	registries = applyRegistryProxies(registries, settingStringMap(dsConfig.Plugins.Settings["porter"], "proxies"))
+
 	return &Config{
 		Registries: registries,
 		CacheDir:   cacheDir,
 		LogLevel:   logging.Level,
 		Logging:    logging,
+		Export:     exportConfigFromSettings(dsConfig.Plugins.Settings),
+		CacheTTL:   dsConfig.Cache.TTL,
+
+		CacheMaxSize:    dsConfig.Cache.MaxSize,
+		CacheFullPolicy: settingString(dsConfig.Plugins.Settings["porter"], "cache_full_policy"),
+
+		Concurrency: settingInt(dsConfig.Plugins.Settings["porter"], "concurrency"),
+		Aliases:     settingStringMap(dsConfig.Plugins.Settings["porter"], "aliases"),
+		Mirrors:     mirrorsFromSettings(dsConfig.Plugins.Settings["porter"], dsConfig.Registry.Default, dsConfig.Registry.Mirrors),
+		Proxy:       proxyConfigFromDS(dsConfig),
+		DoH:         settingString(dsConfig.Plugins.Settings["porter"], "doh"),
+		Extract:     extractPolicyFromSettings(dsConfig.Plugins.Settings["porter"]),
+		Staging:     stagingConfigFromSettings(dsConfig.Plugins.Settings["porter"]),
+
+		RequirementPolicy: settingString(dsConfig.Plugins.Settings["porter"], "requirement_policy"),
+		DSVersion:         strings.TrimSpace(os.Getenv(DSVersionEnv)),
+		PluginsDir:        strings.TrimSpace(dsConfig.Plugins.Dir),
+		HostFeatures:      settingStrings(dsConfig.Plugins.Settings["porter"], "host_features"),
+		Naming:            namingPolicyFromSettings(dsConfig.Plugins.Settings["porter"]),
+		Push:              pushConfigFromSettings(dsConfig.Plugins.Settings["porter"]),
+		CredentialsFile:   settingString(dsConfig.Plugins.Settings["porter"], "credentials_file"),
+		CredentialsStore:  settingString(dsConfig.Plugins.Settings["porter"], "credentials_store"),
+		PolicyFile:        settingString(dsConfig.Plugins.Settings["porter"], "policy_file"),
+		RequireDigest:     settingBool(dsConfig.Plugins.Settings["porter"], "require_digest"),
+		Tracing:           tracingConfigFromSettings(dsConfig.Plugins.Settings["porter"]),
+		AuditLog:          auditLogConfigFromSettings(dsConfig.Plugins.Settings["porter"]),
+		JobID:             strings.TrimSpace(os.Getenv(JobIDEnv)),
+		EventSubscribers:  eventSubscribersFromSettings(dsConfig.Plugins.Settings["porter"]),
+		PullTimeout:       settingString(dsConfig.Plugins.Settings["porter"], "pull_timeout"),
+		PushTimeout:       settingString(dsConfig.Plugins.Settings["porter"], "push_timeout"),
+		RequestTimeout:    settingString(dsConfig.Plugins.Settings["porter"], "request_timeout"),
+		MaxBandwidth:      settingString(dsConfig.Plugins.Settings["porter"], "max_bandwidth"),
+
+		ResultSigningKey: resultSigningKeyFromSettings(dsConfig.Plugins.Settings),
 	}
 }
 
@@ -166,6 +410,11 @@ func NewClient(cfg *Config, logger hclog.Logger) (*Client, error) {
 
 	normalizedLogging := NormalizeLoggingConfig(cfg.Logging, cfg.LogLevel)
 
+	if cfg.Redactor == nil {
+		cfg.Redactor = NewRedactor()
+	}
+	cfg.Redactor.Add(cfg.Secrets()...)
+
 	if logger == nil {
 		lvl := hclog.LevelFromString(normalizedLogging.Level)
 		if lvl == hclog.NoLevel {
@@ -173,6 +422,7 @@ func NewClient(cfg *Config, logger hclog.Logger) (*Client, error) {
 		}
 		logger = hclog.New(&hclog.LoggerOptions{
 			Name:       "porter",
+			Output:     cfg.Redactor.Writer(os.Stderr),
 			Level:      lvl,
 			JSONFormat: normalizedLogging.IsJSON(),
 		})
@@ -197,76 +447,234 @@ func NewClient(cfg *Config, logger hclog.Logger) (*Client, error) {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
+	staging, err := openStaging(cfg)
+	if err != nil {
+		return nil, err
+	}
+	naming, err := compileNaming(cfg.Naming)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := credentialHelper(cfg.CredentialsStore); err != nil {
+		return nil, err
+	}
+	policies, err := pullPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var chunkSize int64
+	if cfg.Push.ChunkSize != "" {
+		if chunkSize, err = parseSize(cfg.Push.ChunkSize); err != nil {
+			return nil, fmt.Errorf("invalid push.chunk_size: %w", err)
+		}
+	}
+
+	injector, err := faults.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", faults.EnvVar, err)
+	}
+	if injector != nil {
+		logger.Warn("Fault injection enabled", "spec", os.Getenv(faults.EnvVar))
+	}
+	auditLog, err := openAuditLog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := parseTimeouts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var bandwidth *bandwidthLimiter
+	if cfg.MaxBandwidth != "" {
+		rate, err := parseBandwidth(cfg.MaxBandwidth)
+		if err != nil {
+			return nil, err
+		}
+		bandwidth = &bandwidthLimiter{rate: rate}
+	}
+	tracerProvider, err := newTracerProvider(cfg.Tracing, cfg.Version)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
-		config: cfg,
-		logger: logger,
+		config:    cfg,
+		logger:    logger,
+		faults:    injector,
+		staging:   staging,
+		naming:    naming,
+		redactor:  cfg.Redactor,
+		policies:  policies,
+		chunkSize: chunkSize,
+
+		tracerProvider: tracerProvider,
+		auditLog:       auditLog,
+		timeouts:       timeouts,
+		bandwidth:      bandwidth,
 	}, nil
 }
 
+// DefaultConcurrency is the number of parallel blob transfers and platform exports
+// used when Config.Concurrency is unset.
+const DefaultConcurrency = 3
+
+// concurrency returns the configured parallelism, falling back to DefaultConcurrency.
+func (c *Client) concurrency() int {
+	if c.config.Concurrency > 0 {
+		return c.config.Concurrency
+	}
+	return DefaultConcurrency
+}
+
+// PullOptions controls how artifacts are pulled.
+type PullOptions struct {
+	Insecure bool
+	// Force ignores the cached copy: every blob is downloaded again and the cache
+	// entry is replaced once the pull is complete.
+	Force bool
+}
+
 // PullArtifact pulls an artifact from an OCI registry
-func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error) {
-	c.logger.Info("Pulling artifact", "ref", ref, "insecure", insecure)
+func (c *Client) PullArtifact(ctx context.Context, ref string, insecure bool) (*ArtifactResult, error) {
+	return c.PullArtifactWithOptions(ctx, ref, PullOptions{Insecure: insecure})
+}
 
-	ctx := context.Background()
+// PullArtifactWithOptions pulls an artifact from an OCI registry as opts asks.
+func (c *Client) PullArtifactWithOptions(ctx context.Context, ref string, opts PullOptions) (*ArtifactResult, error) {
+	ctx, span := c.tracer().Start(hostTraceContext(ctx), "porter.pull", trace.WithAttributes(attribute.String("oci.reference", reference.Redact(ref))))
+	ctx, recorder := withOperationRecorder(ctx)
+	ctx, cancel := withTimeout(ctx, "pull", c.timeouts.pull)
+	defer cancel()
+	result, err := c.pullArtifact(ctx, ref, opts)
+	err = c.checkRateLimit(recorder.rateLimit(), timeoutError(ctx, err))
+	metrics := recorder.finish(progress.Snapshot{}, false)
+	if result != nil {
+		result.RateLimit = recorder.rateLimit()
+		span.SetAttributes(attribute.String("oci.digest", result.Digest), attribute.Bool("porter.cached", result.Cached))
+		metrics = result.Metrics
+	}
+	spans.End(span, err)
+	c.recordOperation("pull", metrics, err)
+	c.audit("pull", ref, result, "", err)
+	if err == nil {
+		c.publish(ctx, EventArtifactPulled, result, "", 0)
+	}
+	return c.redactResult(result), c.redactor.Error(err)
+}
+
+func (c *Client) pullArtifact(ctx context.Context, ref string, opts PullOptions) (*ArtifactResult, error) {
+	insecure := opts.Insecure
+	c.logger.Info("Pulling artifact", "ref", reference.Redact(ref), "insecure", insecure, "force", opts.Force)
+	if err := c.CheckDigestReference(ref, false); err != nil {
+		return nil, err
+	}
 
 	// Parse reference to get registry and repo
 	// We use go-containerregistry for parsing as it's robust, but we'll use ORAS for pulling
-	var opts []name.Option
-	if insecure {
-		opts = append(opts, name.Insecure)
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
 	}
 
-	var imgRef name.Reference
-	var err error
-
-	// Workaround for localhost/ prefix
-	if strings.HasPrefix(ref, "localhost/") {
-		localOpts := append(opts, name.WithDefaultRegistry("localhost"))
-		imgRef, err = name.ParseReference(strings.TrimPrefix(ref, "localhost/"), localOpts...)
-	} else {
-		imgRef, err = name.ParseReference(ref, opts...)
+	// Nothing is downloaded for an artifact the pull policy refuses.
+	evaluated, err := c.checkPullPolicy(ctx, ref, insecure)
+	if err != nil {
+		return nil, err
 	}
 
+	// Setup ORAS repositories: configured mirrors first, then the registry itself
+	endpoints, err := c.pullEndpoints(imgRef, insecure)
 	if err != nil {
-		return nil, fmt.Errorf("invalid reference: %w", err)
+		return nil, err
 	}
 
-	// Setup ORAS repository
-	repoName := imgRef.Context().Name()
-	repo, err := remote.NewRepository(repoName)
+	// Generate artifact ID based on ref (we don't have digest yet)
+	// We'll update it later if needed, but for cache path we need something stable
+	// Using hash of ref for now to start cache dir
+	artifactID := fmt.Sprintf("%x", sha256.Sum256([]byte(ref)))[:16]
+	cachePath := filepath.Join(c.config.CacheDir, artifactID)
+
+	// Blobs land in the shared store before any index references them, so the
+	// pull keeps blob removal out until it is done; concurrent pulls of ref wait
+	// for each other.
+	storeLock, err := c.lockCache(cacheWideLock, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create repository: %w", err)
+		return nil, err
 	}
+	defer func() { storeLock.Unlock() }()
 
-	// Configure auth
-	client := &auth.Client{
-		Client: retry.DefaultClient,
-		Cache:  auth.DefaultCache,
+	// An unchanged artifact is served from the cache without copying anything.
+	// A digest never changes; a tag is trusted for the cache TTL after the
+	// registry last resolved it.
+	root := evaluated
+	validated := root.Digest != ""
+	if pinned, ok := imgRef.(name.Digest); ok && root.Digest == "" {
+		root.Digest = digest.Digest(pinned.DigestStr())
+	}
+	if root.Digest == "" && !opts.Force {
+		root.Digest = c.validatedDigest(ref)
+	}
+	if root.Digest == "" {
+		_, err = c.tryEndpoints(ctx, ref, endpoints, func(endpoint pullEndpoint) error {
+			var err error
+			root, err = endpoint.repo.Resolve(ctx, imgRef.Identifier())
+			return err
+		})
+		if err != nil {
+			c.logger.Debug("Failed to resolve reference before pulling", "ref", reference.Redact(ref), "error", err)
+		}
+		validated = err == nil
+	}
+	if cached, snapshot := c.cachedPull(root); cached != nil && !opts.Force {
+		cached.Reference = ref
+		if err := c.checkRequirements(ref, cached.Metadata); err != nil {
+			return nil, err
+		}
+		if err := c.savePulledArtifact(cached, validated); err != nil {
+			c.logger.Warn("Failed to save artifact metadata", "error", err)
+		}
+		c.recordPullStats(ref, cached.Digest, snapshot)
+		cached.Metrics = operationRecorderFrom(ctx).finish(snapshot, true)
+		c.logger.Info("Artifact already cached, skipping pull", "id", cached.ID, "digest", cached.Digest)
+		return cached, nil
 	}
 
-	// Try to get credentials from config
-	regName := imgRef.Context().RegistryStr()
-	for _, r := range c.config.Registries {
-		if r.URL == regName || r.Name == regName {
-			if r.Username != "" && r.Password != "" {
-				client.Credential = auth.StaticCredential(regName, auth.Credential{
-					Username: r.Username,
-					Password: r.Password,
-				})
+	if c.config.CacheMaxSize > 0 && root.Digest != "" {
+		excess, err := c.cacheExcess(ctx, ref, endpoints, root)
+		if err != nil {
+			return nil, err
+		}
+		if excess > 0 {
+			// Eviction needs the cache to itself.
+			storeLock.Unlock()
+			if err := c.evictCachedArtifacts(excess); err != nil {
+				return nil, err
+			}
+			if storeLock, err = c.lockCache(cacheWideLock, false); err != nil {
+				return nil, err
 			}
 		}
 	}
-	repo.Client = client
-	repo.PlainHTTP = insecure
 
-	// Generate artifact ID based on ref (we don't have digest yet)
-	// We'll update it later if needed, but for cache path we need something stable
-	// Using hash of ref for now to start cache dir
-	artifactID := fmt.Sprintf("%x", sha256.Sum256([]byte(ref)))[:16]
-	cachePath := filepath.Join(c.config.CacheDir, artifactID)
+	pullLock, err := c.lockCache(artifactID, true)
+	if err != nil {
+		return nil, err
+	}
+	defer pullLock.Unlock()
 
-	// Create OCI layout store in cache
-	store, err := oci.New(cachePath)
+	if opts.Force {
+		// A forced pull stages into a layout with blobs of its own, so nothing
+		// cached is reused until the copy is complete.
+		if err := os.RemoveAll(cachePath); err != nil {
+			return nil, fmt.Errorf("failed to clear cache path: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Join(cachePath, "blobs"), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create OCI store: %w", err)
+		}
+	}
+
+	// Create OCI layout store in cache, backed by the shared blob store
+	store, err := c.newLayout(cachePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCI store: %w", err)
 	}
@@ -275,16 +683,45 @@ func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error
 	// We use the tag or digest from ref
 	targetRef := imgRef.Identifier()
 
+	tracker := progress.New(c.logger, "pull", reference.Redact(ref))
+
 	c.logger.Info("Copying artifact to cache", "target", targetRef)
-	desc, err := oras.Copy(ctx, repo, targetRef, store, targetRef, oras.CopyOptions{})
-	if err != nil {
-		if !insecure && strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
-			c.logger.Warn("Retrying pull over plain HTTP", "ref", ref)
+	var desc ocispec.Descriptor
+	served, err := c.tryEndpoints(ctx, ref, endpoints, func(endpoint pullEndpoint) error {
+		repo := endpoint.repo
+		copyOpts := oras.CopyOptions{}
+		copyOpts.Concurrency = c.concurrency()
+		copyOpts.PreCopy = c.prepareBlobs(cachePath, repo, tracker, !opts.Force)
+		tracker.Options(&copyOpts.CopyGraphOptions)
+
+		var err error
+		desc, err = oras.Copy(ctx, repo, targetRef, tracker.Target(sharedLayout{store}), targetRef, copyOpts)
+		if err != nil && !repo.PlainHTTP && isPlainHTTPResponseError(err) {
+			c.logger.Warn("Retrying pull over plain HTTP", "ref", reference.Redact(ref), "endpoint", endpoint.name)
 			repo.PlainHTTP = true
-			desc, err = oras.Copy(ctx, repo, targetRef, store, targetRef, oras.CopyOptions{})
+			desc, err = oras.Copy(ctx, repo, targetRef, tracker.Target(sharedLayout{store}), targetRef, copyOpts)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to copy artifact: %w", err)
+		return err
+	})
+	if err == nil && evaluated.Digest != "" && desc.Digest != evaluated.Digest {
+		// The tag moved, or a mirror served other content, after the policy check.
+		err = fmt.Errorf("pulled %s, but the pull policy evaluated %s", desc.Digest, evaluated.Digest)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			c.discardCancelledPull(artifactID, cachePath, opts.Force)
+		}
+		return nil, fmt.Errorf("failed to copy artifact: %w", err)
+	}
+	tracker.Finish()
+	transfer := tracker.Snapshot()
+
+	if err := os.RemoveAll(filepath.Join(cachePath, "partial")); err != nil {
+		c.logger.Debug("Failed to remove partial blob directory", "path", cachePath, "error", err)
+	}
+	if opts.Force {
+		if err := c.shareLayoutBlobs(cachePath); err != nil {
+			return nil, err
 		}
 	}
 
@@ -298,10 +735,24 @@ func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error
 	// We can rename the directory.
 	finalArtifactID := desc.Digest.Encoded()[:16]
 	finalCachePath := filepath.Join(c.config.CacheDir, finalArtifactID)
+	previouslyCached := false
 
+	var artifactLock *cacheLock
 	if finalCachePath != cachePath {
+		// Pulls of other references to the same digest finish one at a time.
+		if artifactLock, err = c.lockCache(finalArtifactID, true); err != nil {
+			return nil, err
+		}
+		defer artifactLock.Unlock()
+
 		// Check if target exists
-		if _, err := os.Stat(finalCachePath); err == nil {
+		if _, err := os.Stat(finalCachePath); err == nil && opts.Force {
+			previouslyCached = true
+			if err := c.replaceCachedLayout(cachePath, finalCachePath); err != nil {
+				return nil, err
+			}
+		} else if err == nil {
+			previouslyCached = true
 			// Already exists, remove temp
 			if removeErr := os.RemoveAll(cachePath); removeErr != nil {
 				c.logger.Warn("Failed to remove temporary cache path", "path", cachePath, "error", removeErr)
@@ -352,6 +803,9 @@ func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error
 		}
 	}
 
+	// Record which endpoint served the content, a mirror or the registry itself
+	metadata["pulled.endpoint"] = served.name
+
 	// Check for plugin execution info in metadata
 	var pluginInfo *PluginExecutionInfo
 	if pluginName, ok := metadata["ds.plugin.name"]; ok {
@@ -376,14 +830,33 @@ func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error
 		LocalPath:  finalCachePath,
 		Metadata:   metadata,
 		PluginInfo: pluginInfo,
-		Cached:     true,
 		CachedAt:   time.Now(),
 	}
 
+	if err := c.checkRequirements(ref, metadata); err != nil {
+		// Do not leave a refused artifact behind for later exports to pick up.
+		if !previouslyCached {
+			if removeErr := os.RemoveAll(finalCachePath); removeErr != nil {
+				c.logger.Warn("Failed to remove refused artifact", "path", finalCachePath, "error", removeErr)
+			}
+			// The prune takes the cache lock exclusively, which a pull waiting
+			// for the locks of this one would otherwise keep it from.
+			artifactLock.Unlock()
+			pullLock.Unlock()
+			storeLock.Unlock()
+			if _, _, pruneErr := c.pruneBlobStore(); pruneErr != nil {
+				c.logger.Warn("Failed to prune blob store", "error", pruneErr)
+			}
+		}
+		return nil, err
+	}
+
 	// Save artifact metadata
-	if err := c.saveArtifactMetadata(result); err != nil {
+	if err := c.savePulledArtifact(result, true); err != nil {
 		c.logger.Warn("Failed to save artifact metadata", "error", err)
 	}
+	c.recordPullStats(ref, result.Digest, transfer)
+	result.Metrics = operationRecorderFrom(ctx).finish(transfer, transfer.TotalBlobs > 0 && transfer.SkippedBlobs == transfer.TotalBlobs)
 
 	c.logger.Info("Artifact pulled successfully",
 		"id", finalArtifactID,
@@ -394,20 +867,109 @@ func (c *Client) PullArtifact(ref string, insecure bool) (*ArtifactResult, error
 	return result, nil
 }
 
-func normalizeRegistryHost(value string) string {
-	trimmed := strings.TrimSpace(value)
-	trimmed = strings.TrimPrefix(trimmed, "https://")
-	trimmed = strings.TrimPrefix(trimmed, "http://")
-	trimmed = strings.TrimSuffix(trimmed, "/")
-	if trimmed == "" {
-		return ""
+// remoteRepository creates an ORAS repository for imgRef configured with the
+// credentials porter holds for its registry.
+func (c *Client) remoteRepository(imgRef name.Reference, insecure bool) (*remote.Repository, error) {
+	registry := reference.NormalizeRegistry(imgRef.Context().RegistryStr())
+	repo, err := remote.NewRepository(registry + "/" + imgRef.Context().RepositoryStr())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	username, password := c.resolveCredentials(registry)
+	repo.Client = newAuthClient(c.httpClient(registry), registry, username, password)
+	repo.PlainHTTP = insecure
+	return repo, nil
+}
+
+// resolveRemote resolves imgRef in its repository, retrying over plain HTTP when the
+// registry turns out not to speak TLS.
+func (c *Client) resolveRemote(ctx context.Context, imgRef name.Reference, ref string, insecure bool) (*remote.Repository, ocispec.Descriptor, error) {
+	repo, err := c.remoteRepository(imgRef, insecure)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+
+	desc, err := repo.Resolve(ctx, imgRef.Identifier())
+	if err != nil && !insecure && isPlainHTTPResponseError(err) {
+		c.logger.Warn("Retrying resolve over plain HTTP", "ref", reference.Redact(ref))
+		repo.PlainHTTP = true
+		desc, err = repo.Resolve(ctx, imgRef.Identifier())
+	}
+	if err != nil {
+		return nil, ocispec.Descriptor{}, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	return repo, desc, nil
+}
+
+// isPlainHTTPResponseError reports whether err indicates an HTTPS request was
+// answered by a plain HTTP server.
+func isPlainHTTPResponseError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
+
+// parseReference parses an artifact reference, expanding configured aliases, into
+// the go-containerregistry form used for registry calls. The registry is taken from
+// the parsed reference so hosts such as "localhost" are never mistaken for a Docker
+// Hub namespace.
+func (c *Client) parseReference(ref string, insecure bool) (name.Reference, error) {
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []name.Option
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+	registry, err := name.NewRegistry(parsed.Registry, opts...)
+	if err != nil {
+		return nil, err
+	}
+	repo := registry.Repo(parsed.Repository)
+	if parsed.IsDigest() {
+		return repo.Digest(parsed.Digest.String()), nil
+	}
+	return repo.Tag(parsed.Identifier()), nil
+}
+
+// PushArtifact pushes an artifact to an OCI registry. artifactPath is a file, a
+// directory, a ds.manifest.yaml, an OCI layout directory or tarball, which is
+// pushed verbatim, or a docker save tarball, which is pushed as an OCI image.
+func (c *Client) PushArtifact(ctx context.Context, artifactPath string, ref string, pushOpts PushOptions) (*ArtifactResult, error) {
+	return c.push(ctx, ref, func(ctx context.Context) (*ArtifactResult, error) {
+		return c.pushArtifact(ctx, artifactPath, ref, pushOpts)
+	})
+}
+
+// push runs a push to ref under the push timeout. Every push path goes through
+// it, so each one is traced as porter.push, recorded in the push metrics and the
+// audit log, and announced with an artifact.pushed event when it succeeds.
+func (c *Client) push(ctx context.Context, ref string, run func(context.Context) (*ArtifactResult, error)) (*ArtifactResult, error) {
+	ctx, span := c.tracer().Start(hostTraceContext(ctx), "porter.push", trace.WithAttributes(attribute.String("oci.reference", reference.Redact(ref))))
+	ctx, recorder := withOperationRecorder(ctx)
+	ctx, cancel := withTimeout(ctx, "push", c.timeouts.push)
+	defer cancel()
+	result, err := run(ctx)
+	err = timeoutError(ctx, err)
+	metrics := recorder.finish(progress.Snapshot{}, false)
+	if result != nil {
+		span.SetAttributes(attribute.String("oci.digest", result.Digest))
+		if result.Metrics == nil {
+			result.Metrics = metrics
+		}
+		metrics = result.Metrics
+	}
+	spans.End(span, err)
+	c.recordOperation("push", metrics, err)
+	c.audit("push", ref, result, "", err)
+	if err == nil {
+		c.publish(ctx, EventArtifactPushed, result, "", 0)
 	}
-	parts := strings.Split(trimmed, "/")
-	return parts[0]
+	return c.redactResult(result), c.redactor.Error(err)
 }
 
-// PushArtifact pushes an artifact to an OCI registry
-func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*ArtifactResult, error) {
+func (c *Client) pushArtifact(ctx context.Context, artifactPath string, ref string, pushOpts PushOptions) (*ArtifactResult, error) {
 	if ref == "" {
 		return nil, fmt.Errorf("artifact reference required")
 	}
@@ -415,11 +977,41 @@ func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*
 	if artifactPath == "" {
 		return nil, fmt.Errorf("manifest or artifact path required")
 	}
+	if err := c.CheckNaming(ref); err != nil {
+		return nil, err
+	}
+	if err := c.CheckPushTags(ref, pushOpts.Tags); err != nil {
+		return nil, err
+	}
 
 	absPath, err := filepath.Abs(artifactPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve artifact path %s: %w", artifactPath, err)
 	}
+	if pushOpts, err = c.ApplySemverAliases(ctx, ref, pushOpts); err != nil {
+		return nil, err
+	}
+
+	if err := pushOpts.Metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	if index, ok, err := ociLayoutIndex(absPath); err != nil {
+		return nil, err
+	} else if ok {
+		if !pushOpts.Metadata.IsEmpty() {
+			return nil, fmt.Errorf("metadata cannot be packed into an OCI layout push, whose manifests are pushed as they are")
+		}
+		return c.pushOCILayout(ctx, absPath, index, ref, pushOpts)
+	}
+	if archive, ok, err := openDockerArchive(absPath); err != nil {
+		return nil, err
+	} else if ok {
+		if !pushOpts.Metadata.IsEmpty() {
+			return nil, fmt.Errorf("metadata cannot be packed into a docker archive push, whose manifests are pushed as they are")
+		}
+		return c.pushDockerArchive(ctx, absPath, archive, ref, pushOpts)
+	}
 
 	manifest, manifestDir, err := loadPushManifest(absPath)
 	if err != nil {
@@ -430,46 +1022,51 @@ func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*
 		return nil, fmt.Errorf("manifest must contain at least one entry")
 	}
 
-	entries := make(map[release.Platform]release.ManifestEntry, len(manifest.Manifests))
-	var cleanups []func()
-	defer func() {
-		for _, fn := range cleanups {
-			if fn != nil {
-				fn()
-			}
-		}
-	}()
+	compression := pushOpts.Compression
+	if compression == "" {
+		compression = manifest.Compression
+	}
+	if _, err := release.ArchiveMediaType(compression); err != nil {
+		return nil, err
+	}
 
+	entries := make(map[release.Platform]release.ManifestEntry, len(manifest.Manifests))
 	for _, entry := range manifest.Manifests {
-		prepared, platform, cleanup, prepErr := prepareManifestEntry(entry, manifestDir)
+		prepared, platform, prepErr := prepareManifestEntry(entry, manifestDir, compression)
 		if prepErr != nil {
 			return nil, prepErr
 		}
-		if cleanup != nil {
-			cleanups = append(cleanups, cleanup)
-		}
 		entries[platform] = prepared
 	}
 
-	ctx := context.Background()
+	insecure := pushOpts.Insecure
 
-	opts := []name.Option{}
-	if insecure {
-		opts = append(opts, name.Insecure)
-	}
-	parsedRef, err := name.ParseReference(ref, opts...)
+	parsedRef, err := c.parseReference(ref, insecure)
 	if err != nil {
 		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
 	}
 
 	username, password := c.resolveCredentials(parsedRef.Context().RegistryStr())
+	tracker := progress.New(c.logger, "push", reference.Redact(ref))
 	releaseConfig := release.ReleaseConfig{
-		Reference:    ref,
+		Reference:    reference.ExpandAlias(strings.TrimSpace(ref), c.config.Aliases),
 		Username:     username,
 		Password:     password,
+		HTTPClient:   c.httpClient(parsedRef.Context().RegistryStr()),
 		ManifestPath: absPath,
-		TagLatest:    true,
+		TagLatest:    c.TagLatest(pushOpts),
+		Tags:         pushOpts.Tags,
 		Insecure:     insecure,
+		Sign:         pushOpts.Sign,
+		SBOMs:        pushOpts.SBOMs,
+		Concurrency:  c.concurrency(),
+		Progress:     tracker,
+		Staging:      c.staging,
+		ChunkSize:    c.chunkSize,
+		Metadata:     release.MergeMetadata(manifest.Metadata, pushOpts.Metadata),
+
+		UploadSessionsDir: c.UploadSessionsDir(),
+		TracerProvider:    c.traceProvider(),
 	}
 
 	pusher, err := release.NewPusher(releaseConfig)
@@ -486,16 +1083,29 @@ func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*
 	if err != nil {
 		return nil, fmt.Errorf("failed to push manifest index: %w", err)
 	}
+	tracker.Finish()
 
-	repoName, tag := splitReference(ref)
-	repo, err := remote.NewRepository(repoName)
+	attached, err := pusher.AttachIndexSBOMs(ctx, manifest, manifestDir, io.Discard)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create repository: %w", err)
+		return nil, err
+	}
+	var sbomDigests []string
+	for _, desc := range attached {
+		sbomDigests = append(sbomDigests, desc.Digest.String())
+	}
+
+	if pushOpts.Sign.Enabled {
+		if err := pusher.Sign(ctx, descriptors, io.Discard); err != nil {
+			return nil, fmt.Errorf("failed to sign artifact: %w", err)
+		}
 	}
-	repo.Client = newAuthClient(parsedRef.Context().RegistryStr(), username, password)
-	repo.PlainHTTP = insecure
 
-	desc, err := repo.Resolve(ctx, tag)
+	repo, err := c.remoteRepository(parsedRef, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := repo.Resolve(ctx, parsedRef.Identifier())
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve pushed artifact: %w", err)
 	}
@@ -512,12 +1122,16 @@ func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*
 	if manifest.ArtifactType != "" {
 		metadata["artifact.type"] = manifest.ArtifactType
 	}
+	if len(sbomDigests) > 0 {
+		metadata["sbom.referrers"] = strings.Join(sbomDigests, ",")
+	}
 	metadata["pushed.reference"] = refWithTag
+	recordPushTags(metadata, pushOpts.Tags)
 	if refWithTag != ref {
 		metadata["requested.reference"] = ref
 	}
 
-	c.logger.Info("Artifact pushed successfully", "reference", ref, "digest", desc.Digest.String())
+	c.logger.Info("Artifact pushed successfully", "reference", reference.Redact(ref), "digest", desc.Digest.String())
 
 	return &ArtifactResult{
 		ID:        artifactID,
@@ -526,6 +1140,7 @@ func (c *Client) PushArtifact(artifactPath string, ref string, insecure bool) (*
 		Size:      desc.Size,
 		Metadata:  metadata,
 		Cached:    false,
+		Metrics:   operationRecorderFrom(ctx).finish(tracker.Snapshot(), false),
 	}, nil
 }
 
@@ -540,9 +1155,8 @@ func loadPushManifest(path string) (*release.Manifest, string, error) {
 			ArtifactType: release.MediaTypeArtifactIndex,
 			Annotations:  map[string]string{},
 			Manifests: []release.ManifestEntry{{
-				Platform:  defaultPlatform.FormatString(),
-				Path:      path,
-				MediaType: release.MediaTypeArtifactArchive,
+				Platform: defaultPlatform.FormatString(),
+				Path:     path,
 			}},
 		}, filepath.Dir(path), nil
 	}
@@ -572,9 +1186,12 @@ func loadPushManifest(path string) (*release.Manifest, string, error) {
 	}, filepath.Dir(path), nil
 }
 
-func prepareManifestEntry(entry release.ManifestEntry, baseDir string) (release.ManifestEntry, release.Platform, func(), error) {
+// prepareManifestEntry resolves the path and config of entry. Directories keep their path
+// and are archived by the pusher, compressed as their media type names or else
+// with compression.
+func prepareManifestEntry(entry release.ManifestEntry, baseDir, compression string) (release.ManifestEntry, release.Platform, error) {
 	if strings.TrimSpace(entry.Path) == "" {
-		return release.ManifestEntry{}, release.Platform{}, nil, fmt.Errorf("manifest entry missing path")
+		return release.ManifestEntry{}, release.Platform{}, fmt.Errorf("manifest entry missing path")
 	}
 
 	resolvedPath := entry.Path
@@ -585,132 +1202,28 @@ func prepareManifestEntry(entry release.ManifestEntry, baseDir string) (release.
 
 	info, err := os.Stat(resolvedPath)
 	if err != nil {
-		return release.ManifestEntry{}, release.Platform{}, nil, fmt.Errorf("manifest entry path %s: %w", resolvedPath, err)
+		return release.ManifestEntry{}, release.Platform{}, fmt.Errorf("manifest entry path %s: %w", resolvedPath, err)
 	}
 
 	platform, err := parseManifestPlatform(entry.Platform)
 	if err != nil {
-		return release.ManifestEntry{}, release.Platform{}, nil, err
-	}
-
-	var cleanup func()
-	if info.IsDir() {
-		archivePath, archiveCleanup, archiveErr := createArchiveFromDirectory(resolvedPath)
-		if archiveErr != nil {
-			return release.ManifestEntry{}, release.Platform{}, nil, archiveErr
-		}
-		cleanup = archiveCleanup
-		entry.Path = archivePath
-		if strings.TrimSpace(entry.MediaType) == "" {
-			entry.MediaType = release.MediaTypeArtifactArchive
-		}
-	} else {
-		entry.Path = resolvedPath
-		if strings.TrimSpace(entry.MediaType) == "" {
-			entry.MediaType = release.MediaTypeArtifactBinary
-		}
-	}
-
-	return entry, platform, cleanup, nil
-}
-
-func createArchiveFromDirectory(dir string) (string, func(), error) {
-	info, err := os.Stat(dir)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to stat directory %s: %w", dir, err)
-	}
-	if !info.IsDir() {
-		return "", nil, fmt.Errorf("path %s is not a directory", dir)
+		return release.ManifestEntry{}, release.Platform{}, err
 	}
 
-	archiveFile, err := os.CreateTemp("", "ds-porter-archive-*.tar.gz")
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to create temporary archive: %w", err)
-	}
-
-	gzipWriter := gzip.NewWriter(archiveFile)
-	tarWriter := tar.NewWriter(gzipWriter)
-
-	dirAbs, err := filepath.Abs(dir)
-	if err != nil {
-		_ = tarWriter.Close()
-		_ = gzipWriter.Close()
-		_ = archiveFile.Close()
-		_ = os.Remove(archiveFile.Name())
-		return "", nil, fmt.Errorf("failed to resolve directory %s: %w", dir, err)
+	entry.Path = resolvedPath
+	if entry.Config != "" && !filepath.IsAbs(entry.Config) {
+		entry.Config = filepath.Join(baseDir, entry.Config)
 	}
-
-	walkErr := filepath.WalkDir(dirAbs, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-
-		relPath, relErr := filepath.Rel(dirAbs, path)
-		if relErr != nil {
-			return relErr
-		}
-		if relPath == "." {
-			return nil
-		}
-
-		info, infoErr := d.Info()
-		if infoErr != nil {
-			return infoErr
-		}
-
-		header, headerErr := tar.FileInfoHeader(info, "")
-		if headerErr != nil {
-			return headerErr
-		}
-		header.Name = filepath.ToSlash(relPath)
-
-		if d.Type()&os.ModeSymlink != 0 {
-			target, linkErr := os.Readlink(path)
-			if linkErr != nil {
-				return linkErr
+	if strings.TrimSpace(entry.MediaType) == "" {
+		entry.MediaType = release.MediaTypeArtifactBinary
+		if info.IsDir() {
+			if entry.MediaType, err = release.ArchiveMediaType(compression); err != nil {
+				return release.ManifestEntry{}, release.Platform{}, err
 			}
-			header.Linkname = target
-		}
-
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
 		}
-
-		if d.IsDir() {
-			return nil
-		}
-
-		file, openErr := os.Open(path)
-		if openErr != nil {
-			return openErr
-		}
-		if _, copyErr := io.Copy(tarWriter, file); copyErr != nil {
-			_ = file.Close()
-			return copyErr
-		}
-		return file.Close()
-	})
-
-	firstErr := walkErr
-	if closeErr := tarWriter.Close(); firstErr == nil {
-		firstErr = closeErr
-	}
-	if gzipErr := gzipWriter.Close(); firstErr == nil {
-		firstErr = gzipErr
-	}
-	if closeErr := archiveFile.Close(); firstErr == nil {
-		firstErr = closeErr
 	}
 
-	if firstErr != nil {
-		_ = os.Remove(archiveFile.Name())
-		return "", nil, fmt.Errorf("failed to archive directory %s: %w", dir, firstErr)
-	}
-
-	archivePath := archiveFile.Name()
-	return archivePath, func() {
-		_ = os.Remove(archivePath)
-	}, nil
+	return entry, platform, nil
 }
 
 func parseManifestPlatform(value string) (release.Platform, error) {
@@ -726,30 +1239,14 @@ func parseManifestPlatform(value string) (release.Platform, error) {
 	return platform, nil
 }
 
-func splitReference(ref string) (string, string) {
-	base := ref
-	if !strings.Contains(base, ":") {
-		base += ":latest"
-	}
-
-	idx := strings.LastIndex(base, ":")
-	if idx == -1 {
-		return base, "latest"
-	}
-
-	repo := base[:idx]
-	tag := base[idx+1:]
-	return repo, tag
-}
-
-func newAuthClient(registry, username, password string) *auth.Client {
+func newAuthClient(httpClient *http.Client, registry, username, password string) *auth.Client {
 	client := &auth.Client{
-		Client: retry.DefaultClient,
+		Client: httpClient,
 		Cache:  auth.DefaultCache,
 	}
 
 	if username != "" || password != "" {
-		client.Credential = auth.StaticCredential(normalizeRegistry(registry), auth.Credential{
+		client.Credential = auth.StaticCredential(reference.NormalizeRegistry(registry), auth.Credential{
 			Username: username,
 			Password: password,
 		})
@@ -758,11 +1255,35 @@ func newAuthClient(registry, username, password string) *auth.Client {
 	return client
 }
 
+// RegistryNames returns the registries the configuration names, sorted: those
+// with credentials or settings, the DS default registry, and mirrored registries.
+func (c *Client) RegistryNames() []string {
+	seen := make(map[string]struct{})
+	for _, reg := range c.config.Registries {
+		if host := reference.NormalizeRegistry(reg.URL); host != "" {
+			seen[host] = struct{}{}
+		}
+	}
+	for registry := range c.config.Mirrors {
+		if host := reference.NormalizeRegistry(registry); host != "" {
+			seen[host] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
 func (c *Client) resolveCredentials(registry string) (string, string) {
-	normalized := normalizeRegistry(registry)
+	username, password, _ := c.resolveCredentialSource(registry)
+	return username, password
+}
+
+// resolveCredentialSource returns the credentials for registry and where they
+// came from: the DS configuration, then the credentials file login writes.
+func (c *Client) resolveCredentialSource(registry string) (string, string, string) {
+	normalized := reference.NormalizeRegistry(registry)
 	for _, reg := range c.config.Registries {
-		candidateURL := normalizeRegistry(reg.URL)
-		candidateName := normalizeRegistry(reg.Name)
+		candidateURL := reference.NormalizeRegistry(reg.URL)
+		candidateName := reference.NormalizeRegistry(reg.Name)
 		if normalized != "" && normalized != candidateURL && normalized != candidateName {
 			continue
 		}
@@ -772,75 +1293,52 @@ func (c *Client) resolveCredentials(registry string) (string, string) {
 		if password == "" && reg.Token != "" {
 			password = reg.Token
 		}
-		if username == "" && password != "" {
+		if password == "" {
+			// Registries configured for other settings only fall through to login.
+			continue
+		}
+		if username == "" {
 			username = defaultUsername()
 		}
-		source := "porter-config"
 		c.logger.Debug("Resolved registry credentials",
 			"registry", registry,
 			"normalized", normalized,
-			"source", source,
-			"username", username,
-			"password_set", password != "",
+			"source", credentialSourceConfig,
+			"username_set", username != "",
+			"password_set", true,
 		)
-		return username, password
+		return username, password, credentialSourceConfig
+	}
+
+	if normalized != "" {
+		if cred, ok := c.storedCredential(normalized); ok {
+			c.redactor.Add(cred.Password)
+			c.logger.Debug("Resolved registry credentials",
+				"registry", registry,
+				"normalized", normalized,
+				"source", credentialSourceLogin,
+				"username_set", cred.Username != "",
+				"password_set", true,
+			)
+			return cred.Username, cred.Password, credentialSourceLogin
+		}
 	}
 
 	c.logger.Debug("No registry credentials found",
 		"registry", registry,
 		"normalized", normalized,
 	)
-	return "", ""
+	return "", "", credentialSourceAnonymous
 }
 
 // ResolveCredentials exposes the resolved credentials for a registry.
 func (c *Client) ResolveCredentials(registry string) (string, string) {
 	return c.resolveCredentials(registry)
 }
-func normalizeRegistry(value string) string {
-	trimmed := strings.TrimSpace(value)
-	if trimmed == "" {
-		return ""
-	}
-	trimmed = strings.TrimPrefix(trimmed, "https://")
-	trimmed = strings.TrimPrefix(trimmed, "http://")
-	trimmed = strings.TrimSuffix(trimmed, "/")
-	return trimmed
-}
-
 func defaultUsername() string {
 	return "token"
 }
 
-// ListCachedArtifacts lists all cached artifacts
-func (c *Client) ListCachedArtifacts() ([]*ArtifactResult, error) {
-	entries, err := os.ReadDir(c.config.CacheDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []*ArtifactResult{}, nil
-		}
-		return nil, fmt.Errorf("failed to read cache directory: %w", err)
-	}
-
-	var artifacts []*ArtifactResult
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		artifactID := entry.Name()
-		metadata, err := c.loadArtifactMetadata(artifactID)
-		if err != nil {
-			c.logger.Warn("Failed to load metadata", "artifact", artifactID, "error", err)
-			continue
-		}
-
-		artifacts = append(artifacts, metadata)
-	}
-
-	return artifacts, nil
-}
-
 // ExecutePlugin executes a plugin on a cached artifact
 func (c *Client) ExecutePlugin(artifactID string, pluginName string, args []string) error {
 	c.logger.Info("Executing plugin on artifact",
@@ -867,7 +1365,7 @@ func (c *Client) ExecutePlugin(artifactID string, pluginName string, args []stri
 
 // Close cleans up resources
 func (c *Client) Close() error {
-	return nil
+	return c.shutdownTracing()
 }
 
 // Helper methods
@@ -890,17 +1388,63 @@ func (c *Client) getAuthForRegistry(registry string) authn.Authenticator {
 }
 
 // ExportArtifact copies the artifact from cache to the destination
-func (c *Client) ExportArtifact(result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
+func (c *Client) ExportArtifact(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
+	if destination != "" && !IsRemoteDestination(destination) {
+		// Remote destinations are other hosts, whose capabilities are unknown here,
+		// and an OCI or Docker archive is not installed on this host.
+		if !opts.OCIArchive && !opts.DockerArchive {
+			if err := c.checkHostCapabilities(result.Reference, result.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		opts.verified = newExportVerification()
+	}
+	ctx, span := c.tracer().Start(hostTraceContext(ctx), "porter.export", trace.WithAttributes(
+		attribute.String("oci.reference", reference.Redact(result.Reference)),
+		attribute.String("oci.digest", result.Digest),
+		attribute.String("porter.destination", c.redactor.Redact(destination)),
+	))
+	exported, err := c.exportArtifact(ctx, result, destination, opts)
+	span.SetAttributes(attribute.Int("porter.exported_files", len(exported)))
+	spans.End(span, err)
+	c.audit("export", result.Reference, result, destination, err)
+	if err != nil {
+		return nil, err
+	}
+	c.publish(ctx, EventArtifactExported, result, destination, len(exported))
+	result.VerifiedFiles = opts.verified.result()
+	return exported, nil
+}
+
+// exportArtifact exports result without checking host capabilities, for exports
+// that stage files rather than deliver them to this host.
+func (c *Client) exportArtifact(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
 	if destination == "" {
 		return nil, fmt.Errorf("destination required")
 	}
+	if err := validateOverwrite(opts.Overwrite); err != nil {
+		return nil, err
+	}
+
+	if opts.Versioned {
+		return c.exportVersioned(ctx, result, destination, opts)
+	}
+
+	if target, ok := parseDestinationURL(destination); ok {
+		return c.exportToDestination(ctx, result, target, opts)
+	}
+	if opts.OCIArchive {
+		return c.exportOCIArchive(ctx, result, destination, opts)
+	}
+	if opts.DockerArchive {
+		return c.exportDockerArchive(ctx, result, destination, opts)
+	}
 
 	store, err := oci.New(result.LocalPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open OCI store: %w", err)
 	}
 
-	ctx := context.Background()
 	digest := result.Digest
 	if digest == "" {
 		return nil, fmt.Errorf("artifact digest missing")
@@ -921,6 +1465,10 @@ func (c *Client) ExportArtifact(result *ArtifactResult, destination string, opts
 	if len(manifests) == 0 {
 		return nil, fmt.Errorf("no matching platform found for export")
 	}
+	namer, err := newLayerNamer(result, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	destInfo, err := os.Stat(destination)
 	destExists := err == nil
@@ -935,12 +1483,15 @@ func (c *Client) ExportArtifact(result *ArtifactResult, destination string, opts
 	destIsDir := destExists && destInfo.IsDir()
 	destIsFile := destExists && !destIsDir
 
+	if destIsFile && namer.templated() {
+		return nil, fmt.Errorf("destination must be a directory when exporting with an output template")
+	}
 	if destIsFile && (needsSubdirs || multiManifest) {
 		return nil, fmt.Errorf("destination must be a directory when exporting multiple platforms")
 	}
 
 	if !destExists {
-		if needsSubdirs {
+		if needsSubdirs || namer.templated() {
 			if err := os.MkdirAll(destination, 0755); err != nil {
 				return nil, fmt.Errorf("failed to create destination directory: %w", err)
 			}
@@ -959,57 +1510,121 @@ func (c *Client) ExportArtifact(result *ArtifactResult, destination string, opts
 		}
 	}
 
-	baseName := deriveArtifactBaseName(result.Reference)
+	lockDir := destination
+	if destIsFile {
+		lockDir = filepath.Dir(destination)
+	}
+	if err := c.config.Extract.checkDestinationOwner(lockDir); err != nil {
+		return nil, err
+	}
+	writer := exportWriter{shared: opts.Shared || isNetworkShare(lockDir), logger: c.logger, policy: c.config.Extract, overwrite: opts.Overwrite, verified: opts.verified}
+	if writer.shared {
+		c.logger.Debug("Destination is a network share, exporting with lock and rename", "dir", lockDir)
+		release, err := acquireExportLock(lockDir, c.logger)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	writer.delivery = loadDelivery(lockDir, c.logger)
+	if opts.Full {
+		writer.delivery.previous = make(map[string]deliveredFile)
+		writer.full = true
+	}
+
 	var exported []string
 
 	if destIsFile {
 		if multiManifest {
 			return nil, fmt.Errorf("cannot export multiple manifests to a single file")
 		}
-		paths, err := c.exportManifestToFile(ctx, store, manifests[0].Descriptor, destination)
+		paths, err := c.exportManifestToFile(ctx, store, manifests[0], destination, writer)
 		if err != nil {
 			return nil, err
 		}
+		c.saveDelivery(result, writer)
 		return paths, nil
 	}
 
-	// At this point we treat destination as directory (existing or newly created)
-	for _, entry := range manifests {
-		targetDir := destination
-		if needsSubdirs {
-			if entry.Platform != nil && entry.Platform.OS != "" && entry.Platform.Architecture != "" {
-				platformPath := filepath.Join(destination, entry.Platform.OS, entry.Platform.Architecture)
-				if entry.Platform.Variant != "" {
-					platformPath = filepath.Join(platformPath, entry.Platform.Variant)
-				}
-				targetDir = platformPath
-			} else {
-				targetDir = filepath.Join(destination, "unknown")
-			}
+	// At this point we treat destination as directory (existing or newly created).
+	// Manifests exporting into different directories are written in parallel; those
+	// sharing a directory run in order so they never write the same file concurrently.
+	results := make([][]string, len(manifests))
+	var dirs []string
+	byDir := make(map[string][]int)
+	for i, entry := range manifests {
+		targetDir := namer.dir(destination, entry.Platform, needsSubdirs)
+		if _, ok := byDir[targetDir]; !ok {
+			dirs = append(dirs, targetDir)
 		}
+		byDir[targetDir] = append(byDir[targetDir], i)
+	}
 
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create destination directory: %w", err)
-		}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(c.concurrency())
+	for _, targetDir := range dirs {
+		group.Go(func() error {
+			if err := os.MkdirAll(targetDir, 0755); err != nil {
+				return fmt.Errorf("failed to create destination directory: %w", err)
+			}
+			for _, i := range byDir[targetDir] {
+				entry := manifests[i]
+				paths, err := c.exportManifestLayers(groupCtx, store, entry, targetDir, namer, writer)
+				if err != nil {
+					return err
+				}
+				results[i] = paths
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
 
-		paths, err := c.exportManifestLayers(ctx, store, entry.Descriptor, targetDir, baseName, entry.Platform)
-		if err != nil {
-			return nil, err
-		}
+	for _, paths := range results {
 		exported = append(exported, paths...)
 	}
-
+	c.saveDelivery(result, writer)
 	return exported, nil
 }
 
+// platformDir returns the directory a manifest for platform is exported into:
+// destination itself, or <destination>/<os>/<arch>[/<variant>] when platforms are
+// exported side by side.
+func platformDir(destination string, platform *ocispec.Platform, subdirs bool) string {
+	if !subdirs {
+		return destination
+	}
+	if platform == nil || platform.OS == "" || platform.Architecture == "" {
+		return filepath.Join(destination, "unknown")
+	}
+	dir := filepath.Join(destination, platform.OS, platform.Architecture)
+	if platform.Variant != "" {
+		dir = filepath.Join(dir, platform.Variant)
+	}
+	return dir
+}
+
+// saveDelivery updates the destination's delivery manifest. A failure only costs the
+// next export its ability to skip unchanged files, so it is logged, not returned.
+func (c *Client) saveDelivery(result *ArtifactResult, writer exportWriter) {
+	if err := writer.delivery.save(result, writer); err != nil {
+		c.logger.Warn("Failed to update delivery manifest", "error", err)
+	}
+}
+
 type manifestSelection struct {
 	Descriptor ocispec.Descriptor
 	Platform   *ocispec.Platform
+	// ArtifactType is the artifact type of the outermost index that declares one,
+	// i.e. the ds.manifest artifact-type of multi-platform pushes.
+	ArtifactType string
 }
 
-func (c *Client) selectManifests(ctx context.Context, store *oci.Store, root ocispec.Descriptor, opts ExportOptions) ([]manifestSelection, error) {
+func (c *Client) selectManifests(ctx context.Context, store content.Fetcher, root ocispec.Descriptor, opts ExportOptions) ([]manifestSelection, error) {
 	seen := make(map[string]struct{})
-	selections, err := c.collectManifests(ctx, store, root, root.Platform, opts, seen)
+	selections, err := c.collectManifests(ctx, store, root, root.Platform, "", opts, seen)
 	if err != nil {
 		return nil, err
 	}
@@ -1024,7 +1639,7 @@ func (c *Client) selectManifests(ctx context.Context, store *oci.Store, root oci
 	return selections, nil
 }
 
-func (c *Client) collectManifests(ctx context.Context, store *oci.Store, desc ocispec.Descriptor, platformHint *ocispec.Platform, opts ExportOptions, seen map[string]struct{}) ([]manifestSelection, error) {
+func (c *Client) collectManifests(ctx context.Context, store content.Fetcher, desc ocispec.Descriptor, platformHint *ocispec.Platform, artifactType string, opts ExportOptions, seen map[string]struct{}) ([]manifestSelection, error) {
 	key := desc.Digest.String()
 	if key != "" {
 		if _, ok := seen[key]; ok {
@@ -1043,13 +1658,16 @@ func (c *Client) collectManifests(ctx context.Context, store *oci.Store, desc oc
 			return nil, fmt.Errorf("failed to parse index: %w", err)
 		}
 
+		if artifactType == "" {
+			artifactType = index.ArtifactType
+		}
 		var selections []manifestSelection
 		for _, child := range index.Manifests {
 			childHint := child.Platform
 			if childHint == nil {
 				childHint = platformHint
 			}
-			childSelections, err := c.collectManifests(ctx, store, child, childHint, opts, seen)
+			childSelections, err := c.collectManifests(ctx, store, child, childHint, artifactType, opts, seen)
 			if err != nil {
 				return nil, err
 			}
@@ -1067,11 +1685,11 @@ func (c *Client) collectManifests(ctx context.Context, store *oci.Store, desc oc
 		return nil, nil
 	}
 
-	return []manifestSelection{{Descriptor: desc, Platform: platform}}, nil
+	return []manifestSelection{{Descriptor: desc, Platform: platform, ArtifactType: artifactType}}, nil
 }
 
-func (c *Client) exportManifestToFile(ctx context.Context, store *oci.Store, manifestDesc ocispec.Descriptor, destination string) ([]string, error) {
-	manifestBytes, err := content.FetchAll(ctx, store, manifestDesc)
+func (c *Client) exportManifestToFile(ctx context.Context, store *oci.Store, entry manifestSelection, destination string, writer exportWriter) ([]string, error) {
+	manifestBytes, err := content.FetchAll(ctx, store, entry.Descriptor)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
 	}
@@ -1085,6 +1703,16 @@ func (c *Client) exportManifestToFile(ctx context.Context, store *oci.Store, man
 	}
 
 	layer := manifest.Layers[0]
+	mode, enforced := exportedFileMode(layer, manifest.ArtifactType, entry.ArtifactType)
+	if ok, err := writer.claim(destination); err != nil || !ok {
+		return nil, err
+	}
+	writer.delivery.expect(destination, layer.Digest)
+	if writer.unchanged(destination, layer.Size, enforced) {
+		c.logger.Info("Layer unchanged, skipped export", "digest", layer.Digest, "path", destination)
+		return []string{destination}, nil
+	}
+
 	layerReader, err := store.Fetch(ctx, layer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch layer: %w", err)
@@ -1097,24 +1725,30 @@ func (c *Client) exportManifestToFile(ctx context.Context, store *oci.Store, man
 		return nil, fmt.Errorf("failed to create destination path: %w", err)
 	}
 
-	outFile, err := os.Create(destination)
+	outFile, err := writer.create(destination, mode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer func() {
-		_ = outFile.Close()
-	}()
+	outFile.expect(layer.Digest)
 
-	if _, err := io.Copy(outFile, layerReader); err != nil {
+	if _, err := io.Copy(outFile, contextReader{ctx, c.faults.Reader(layer.Digest.String(), layerReader)}); err != nil {
+		outFile.Abort()
 		return nil, fmt.Errorf("failed to copy layer: %w", err)
 	}
+	if err := outFile.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write destination file: %w", err)
+	}
+	if err := enforceMode(destination, enforced); err != nil {
+		return nil, err
+	}
 
 	c.logger.Info("Exported layer", "digest", layer.Digest, "path", destination)
 	return []string{destination}, nil
 }
 
-func (c *Client) exportManifestLayers(ctx context.Context, store *oci.Store, manifestDesc ocispec.Descriptor, destDir, baseName string, platform *ocispec.Platform) ([]string, error) {
-	manifestBytes, err := content.FetchAll(ctx, store, manifestDesc)
+func (c *Client) exportManifestLayers(ctx context.Context, store *oci.Store, entry manifestSelection, destDir string, namer *layerNamer, writer exportWriter) ([]string, error) {
+	platform := entry.Platform
+	manifestBytes, err := content.FetchAll(ctx, store, entry.Descriptor)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
 	}
@@ -1123,45 +1757,92 @@ func (c *Client) exportManifestLayers(ctx context.Context, store *oci.Store, man
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
 	}
 
+	layers, err := orderLayers(manifest.Layers)
+	if err != nil {
+		return nil, err
+	}
+
 	var exported []string
-	for _, layer := range manifest.Layers {
+	for _, layer := range layers {
+		compression := release.ArchiveCompression(layer.MediaType)
+		isArchive := compression != ""
+		var destPath string
+		mode, enforced := exportedFileMode(layer, manifest.ArtifactType, entry.ArtifactType)
+		if isArchive {
+			destPath, err = namer.archiveDir(destDir, layer, platform)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.expectArchiveContents(ctx, store, layer, destPath, writer); err != nil {
+				return nil, err
+			}
+		} else {
+			destPath, err = namer.file(destDir, layer, platform)
+			if err != nil {
+				return nil, err
+			}
+			ok, err := writer.claim(destPath)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			writer.delivery.expect(destPath, layer.Digest)
+			if writer.unchanged(destPath, layer.Size, enforced) {
+				exported = append(exported, destPath)
+				c.logger.Info("Layer unchanged, skipped export", "digest", layer.Digest, "path", destPath)
+				continue
+			}
+		}
+
 		layerReader, err := store.Fetch(ctx, layer)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch layer: %w", err)
 		}
 
-		if strings.Contains(layer.MediaType, "tar+gzip") {
-			paths, err := extractTarGz(layerReader, destDir)
+		if isArchive {
+			journal, err := openExtractJournal(destPath, layer.Digest, writer.full, c.logger)
+			if err != nil {
+				_ = layerReader.Close()
+				return nil, err
+			}
+			verifier := layer.Digest.Verifier()
+			stream := io.TeeReader(contextReader{ctx, c.faults.Reader(layer.Digest.String(), layerReader)}, verifier)
+			paths, err := extractArchive(stream, compression, destPath, writer, journal)
+			if err == nil {
+				err = verifyLayerStream(stream, verifier, layer.Digest)
+			}
 			_ = layerReader.Close()
 			if err != nil {
+				journal.close()
 				return nil, err
 			}
+			journal.finish()
 			exported = append(exported, paths...)
-			c.logger.Info("Extracted archive layer", "digest", layer.Digest, "dir", destDir)
+			c.logger.Info("Extracted archive layer", "digest", layer.Digest, "dir", destPath)
 			continue
 		}
 
-		filename := determineLayerFilename(layer, baseName, platform)
-		destPath := filepath.Join(destDir, filename)
-
 		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
 			_ = layerReader.Close()
 			return nil, fmt.Errorf("failed to create destination directory: %w", err)
 		}
 
-		outFile, err := os.Create(destPath)
+		outFile, err := writer.create(destPath, mode)
 		if err != nil {
 			_ = layerReader.Close()
 			return nil, fmt.Errorf("failed to create file: %w", err)
 		}
+		outFile.expect(layer.Digest)
 
-		if _, err := io.Copy(outFile, layerReader); err != nil {
-			_ = outFile.Close()
+		if _, err := io.Copy(outFile, contextReader{ctx, c.faults.Reader(layer.Digest.String(), layerReader)}); err != nil {
+			outFile.Abort()
 			_ = layerReader.Close()
 			return nil, fmt.Errorf("failed to copy layer: %w", err)
 		}
 
-		if err := outFile.Close(); err != nil {
+		if err := outFile.Commit(); err != nil {
 			_ = layerReader.Close()
 			return nil, fmt.Errorf("failed to close file: %w", err)
 		}
@@ -1169,6 +1850,9 @@ func (c *Client) exportManifestLayers(ctx context.Context, store *oci.Store, man
 		if err := layerReader.Close(); err != nil {
 			return nil, fmt.Errorf("failed to close layer reader: %w", err)
 		}
+		if err := enforceMode(destPath, enforced); err != nil {
+			return nil, err
+		}
 
 		exported = append(exported, destPath)
 		c.logger.Info("Exported layer", "digest", layer.Digest, "path", destPath)
@@ -1177,18 +1861,45 @@ func (c *Client) exportManifestLayers(ctx context.Context, store *oci.Store, man
 	return exported, nil
 }
 
-func extractTarGz(reader io.Reader, destination string) ([]string, error) {
-	gz, err := gzip.NewReader(reader)
+// expectArchiveContents digests the files of an archive layer ahead of extraction
+// when the destination holds an earlier delivery, so unchanged entries can be skipped.
+func (c *Client) expectArchiveContents(ctx context.Context, store *oci.Store, layer ocispec.Descriptor, destDir string, writer exportWriter) error {
+	if !writer.delivery.hasPrevious() {
+		return nil
+	}
+	reader, err := store.Fetch(ctx, layer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init gzip reader: %w", err)
+		return fmt.Errorf("failed to fetch layer: %w", err)
 	}
 	defer func() {
-		_ = gz.Close()
+		_ = reader.Close()
 	}()
 
-	tarReader := tar.NewReader(gz)
+	digests, err := scanArchive(reader, release.ArchiveCompression(layer.MediaType))
+	if err != nil {
+		return err
+	}
+	for name, dgst := range digests {
+		writer.delivery.expect(filepath.Join(destDir, name), dgst)
+	}
+	return nil
+}
+
+// extractArchive unpacks a tar archive compressed with compression into destination.
+// Regular files journal records as completed by an interrupted extraction are
+// verified and kept instead of being written again.
+func extractArchive(reader io.Reader, compression, destination string, writer exportWriter, journal *extractJournal) ([]string, error) {
+	decompressed, err := release.NewDecompressor(reader, compression)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = decompressed.Close()
+	}()
+
+	tarReader := tar.NewReader(decompressed)
 	var extracted []string
-	for {
+	for index := 0; ; index++ {
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
@@ -1205,34 +1916,75 @@ func extractTarGz(reader io.Reader, destination string) ([]string, error) {
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
+			if err := os.MkdirAll(targetPath, writer.policy.dirMode(header.FileInfo().Mode())); err != nil {
 				return nil, fmt.Errorf("failed to create directory %s: %w", targetPath, err)
 			}
 			extracted = append(extracted, targetPath)
 		case tar.TypeReg:
+			mode, err := writer.policy.fileMode(header.Name, header.FileInfo().Mode())
+			if err != nil {
+				return nil, err
+			}
+			if journal.completed(index, cleanName, targetPath, writer) {
+				extracted = append(extracted, targetPath)
+				continue
+			}
+			if ok, err := writer.claim(targetPath); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			if writer.unchanged(targetPath, header.Size, mode) {
+				extracted = append(extracted, targetPath)
+				continue
+			}
 			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 				return nil, fmt.Errorf("failed to create path for %s: %w", targetPath, err)
 			}
-			outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			outFile, err := writer.create(targetPath, mode)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create file %s: %w", targetPath, err)
 			}
+			outFile.restore(header.ModTime, tarxattr.Get(header))
 			if _, err := io.Copy(outFile, tarReader); err != nil {
-				_ = outFile.Close()
+				outFile.Abort()
 				return nil, fmt.Errorf("failed to write file %s: %w", targetPath, err)
 			}
-			if err := outFile.Close(); err != nil {
+			if err := outFile.Commit(); err != nil {
 				return nil, fmt.Errorf("failed to close file %s: %w", targetPath, err)
 			}
+			journal.add(index, cleanName, outFile.digester.Digest(), outFile.size)
 			extracted = append(extracted, targetPath)
 		case tar.TypeSymlink:
+			if ok, err := writer.claim(targetPath); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
 			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 				return nil, fmt.Errorf("failed to create path for symlink %s: %w", targetPath, err)
 			}
-			if err := os.Symlink(header.Linkname, targetPath); err != nil {
+			if err := replaceWithSymlink(header.Linkname, targetPath); err != nil {
 				return nil, fmt.Errorf("failed to create symlink %s: %w", targetPath, err)
 			}
 			extracted = append(extracted, targetPath)
+		case tar.TypeLink:
+			cleanLink := filepath.Clean(header.Linkname)
+			if filepath.IsAbs(cleanLink) || strings.HasPrefix(cleanLink, "..") {
+				return nil, fmt.Errorf("archive entry %s links outside the destination", header.Name)
+			}
+			if ok, err := writer.claim(targetPath); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create path for %s: %w", targetPath, err)
+			}
+			if err := writer.link(filepath.Join(destination, cleanLink), targetPath); err != nil {
+				return nil, fmt.Errorf("failed to link %s: %w", targetPath, err)
+			}
+			extracted = append(extracted, targetPath)
 		default:
 			// Ignore other types for now
 		}
@@ -1276,10 +2028,7 @@ func defaultExtension(layer ocispec.Descriptor, platform *ocispec.Platform) stri
 	if platform != nil && strings.EqualFold(platform.OS, "windows") {
 		return ".exe"
 	}
-	if strings.Contains(layer.MediaType, "tar+gzip") {
-		return ".tar.gz"
-	}
-	return ""
+	return release.ArchiveExtension(release.ArchiveCompression(layer.MediaType))
 }
 
 func sanitizeFilename(name string) string {
@@ -1376,34 +2125,3 @@ func platformMatches(platform *ocispec.Platform, targets []ocispec.Platform) boo
 func isIndexDescriptor(desc ocispec.Descriptor) bool {
 	return desc.MediaType == ocispec.MediaTypeImageIndex || desc.MediaType == "application/vnd.oci.image.index.v1+json"
 }
-
-func (c *Client) saveArtifactMetadata(artifact *ArtifactResult) error {
-	metadataPath := filepath.Join(c.config.CacheDir, artifact.ID, "metadata.json")
-
-	data, err := json.MarshalIndent(artifact, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
-	}
-
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write metadata: %w", err)
-	}
-
-	return nil
-}
-
-func (c *Client) loadArtifactMetadata(artifactID string) (*ArtifactResult, error) {
-	metadataPath := filepath.Join(c.config.CacheDir, artifactID, "metadata.json")
-
-	data, err := os.ReadFile(metadataPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata: %w", err)
-	}
-
-	var artifact ArtifactResult
-	if err := json.Unmarshal(data, &artifact); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-	}
-
-	return &artifact, nil
-}
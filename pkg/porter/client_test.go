@@ -2,14 +2,23 @@ package porter
 
 import (
 	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/internal/faults"
 	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type stubHostConfigProvider struct {
@@ -213,6 +222,20 @@ func TestGetAuthForRegistry(t *testing.T) {
 	}
 }
 
+func TestRegistryNames(t *testing.T) {
+	client, err := NewClient(&Config{
+		CacheDir: t.TempDir(),
+		Registries: []RegistryConfig{
+			{Name: "ghcr", URL: "https://GHCR.io", Token: "token"},
+			{Name: "internal", URL: "registry.internal:5000/team"},
+		},
+		Mirrors: map[string][]string{"index.docker.io": {"mirror.internal"}, "ghcr.io": {"mirror.internal/ghcr"}},
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"docker.io", "ghcr.io", "registry.internal:5000"}, client.RegistryNames())
+}
+
 func TestExecutePlugin(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -257,3 +280,335 @@ func TestClose(t *testing.T) {
 	err = client.Close()
 	assert.NoError(t, err)
 }
+
+func TestPullReusesCachedBlobs(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	client := newTestClient(t, nil)
+	desc := ocispec.Descriptor{Digest: digest.FromBytes(data), Size: int64(len(data))}
+	seedCachedBlob(t, client.config.CacheDir, desc, data)
+
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	for _, req := range registry.Requests() {
+		assert.NotContains(t, req, desc.Digest.Encoded(), "cached layer must not be downloaded")
+	}
+}
+
+func TestPullServesCachedDigestWithoutCopying(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	first, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.False(t, first.Cached)
+
+	before := len(registry.Requests())
+	second, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.True(t, second.Cached)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.Digest, second.Digest)
+	for _, req := range registry.Requests()[before:] {
+		assert.NotContains(t, req, "/blobs/", "cached artifact must not be copied")
+	}
+
+	stats, err := client.PullStats(ref)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats[0].CacheHits)
+}
+
+func TestPullTrustsTagsForCacheTTL(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+	client.config.CacheTTL = buildConfigFromDS(&types.Config{Cache: types.CacheConfig{TTL: time.Hour}}).CacheTTL
+	require.Equal(t, time.Hour, client.config.CacheTTL)
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	first, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	moved := registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary v2"), nil)
+
+	before := len(registry.Requests())
+	cached, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.True(t, cached.Cached)
+	assert.Equal(t, first.Digest, cached.Digest)
+	assert.Len(t, registry.Requests(), before, "a tag within the TTL must not be resolved")
+
+	client.config.CacheTTL = time.Nanosecond
+	revalidated, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.False(t, revalidated.Cached)
+	assert.Equal(t, moved.Digest.String(), revalidated.Digest)
+
+	before = len(registry.Requests())
+	pinned, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool@"+first.Digest, true)
+	require.NoError(t, err)
+	assert.True(t, pinned.Cached)
+	assert.Len(t, registry.Requests(), before, "a digest must not be resolved")
+}
+
+func TestForcePullReplacesCachedCopy(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	first, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	layer := blobPath(client.config.CacheDir, digest.FromBytes(data))
+	require.NoError(t, os.Chmod(layer, 0644))
+	require.NoError(t, os.WriteFile(layer, []byte("stale binary"), 0644))
+
+	before := len(registry.Requests())
+	forced, err := client.PullArtifactWithOptions(context.Background(), ref, PullOptions{Insecure: true, Force: true})
+	require.NoError(t, err)
+	assert.False(t, forced.Cached)
+	assert.Equal(t, first.ID, forced.ID)
+	assert.Contains(t, registry.Requests()[before:], "GET /v2/team/tool/blobs/"+digest.FromBytes(data).String())
+
+	got, err := os.ReadFile(blobPath(forced.LocalPath, digest.FromBytes(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 1)
+	_, err = os.Stat(forced.LocalPath + ".replaced")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPullDetectsInjectedCorruption(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	t.Setenv(faults.EnvVar, "corrupt-at=0,match="+digest.FromBytes(data).Encoded())
+	client := newTestClient(t, nil)
+
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mismatch")
+}
+
+func TestBuildConfigReadsConcurrency(t *testing.T) {
+	cfg := buildConfigFromDS(&types.Config{
+		Plugins: types.PluginsConfig{
+			Settings: map[string]map[string]interface{}{
+				"porter": {"concurrency": float64(8)},
+			},
+		},
+	})
+	assert.Equal(t, 8, cfg.Concurrency)
+
+	client := newTestClient(t, nil)
+	assert.Equal(t, DefaultConcurrency, client.concurrency())
+	client.config.Concurrency = 8
+	assert.Equal(t, 8, client.concurrency())
+}
+
+func TestExportAllPlatformsInParallel(t *testing.T) {
+	registry := newTestRegistry(t)
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "darwin", Architecture: "arm64"},
+	}
+	var manifests []ocispec.Descriptor
+	for _, platform := range platforms {
+		desc := registry.AddArtifact("team/tool", "", "application/octet-stream", []byte("tool for "+platform.OS+"/"+platform.Architecture), nil)
+		desc.Platform = &platform
+		manifests = append(manifests, desc)
+	}
+	registry.AddManifest("team/tool", "1.0.0", ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+
+	client := newTestClient(t, nil)
+	client.config.Concurrency = 2
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{AllPlatforms: true, UsePlatformSubdirs: true})
+	require.NoError(t, err)
+	require.Len(t, exported, len(platforms))
+	for i, platform := range platforms {
+		assert.Equal(t, filepath.Join(outDir, platform.OS, platform.Architecture, "tool"), exported[i])
+		data, err := os.ReadFile(exported[i])
+		require.NoError(t, err)
+		assert.Equal(t, "tool for "+platform.OS+"/"+platform.Architecture, string(data))
+	}
+}
+
+func TestPullExpandsConfiguredAliases(t *testing.T) {
+	cfg := buildConfigFromDS(&types.Config{
+		Plugins: types.PluginsConfig{
+			Settings: map[string]map[string]interface{}{
+				"porter": {"aliases": map[string]interface{}{"ds": "ghcr.io/delivery-station", "blank": ""}},
+			},
+		},
+	})
+	assert.Equal(t, map[string]string{"ds": "ghcr.io/delivery-station"}, cfg.Aliases)
+
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+
+	client := newTestClient(t, nil)
+	client.config.Aliases = map[string]string{"local": registry.Host() + "/team"}
+	result, err := client.PullArtifact(context.Background(), "local/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, "local/tool:1.0.0", result.Reference)
+	assert.NotEmpty(t, result.Digest)
+}
+
+func TestResolveCredentialsNormalizesRegistry(t *testing.T) {
+	client := newTestClient(t, nil)
+	client.config.Registries = []RegistryConfig{
+		{Name: "docker.io", URL: "https://index.docker.io/v1/", Token: "hub-token"},
+		{Name: "ghcr.io", URL: "ghcr.io", Username: "octo", Password: "secret"},
+	}
+
+	username, password := client.resolveCredentials("index.docker.io")
+	assert.Equal(t, "token", username)
+	assert.Equal(t, "hub-token", password)
+
+	username, password = client.resolveCredentials("https://GHCR.io/")
+	assert.Equal(t, "octo", username)
+	assert.Equal(t, "secret", password)
+
+	username, password = client.resolveCredentials("quay.io")
+	assert.Empty(t, username)
+	assert.Empty(t, password)
+}
+
+// TestEveryPushPathGoesThroughPush checks that each exported push is traced,
+// counted in the metrics, published as an event and bounded by the push timeout.
+func TestEveryPushPathGoesThroughPush(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	cacheDir := t.TempDir()
+	newClient := func(pushTimeout string) *Client {
+		client, err := NewClient(&Config{
+			CacheDir:        cacheDir,
+			CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+			PushTimeout:     pushTimeout,
+		}, hclog.NewNullLogger())
+		require.NoError(t, err)
+		return client
+	}
+	// The clients share the cache, and with it the accumulated metrics.
+	pushes := func(client *Client, outcome string) int64 {
+		doc, err := client.loadMetrics()
+		require.NoError(t, err)
+		if totals := doc.Operations["push"]; totals != nil {
+			return totals.Outcomes[outcome]
+		}
+		return 0
+	}
+	pulled, err := newClient("").PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	artifactPath := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(artifactPath, []byte("tool binary"), 0o644))
+	manifestPath := writeTestManifest(t)
+
+	tests := []struct {
+		name string
+		repo string
+		push func(client *Client, ref string) (*ArtifactResult, error)
+	}{
+		{
+			name: "artifact",
+			repo: "team/file",
+			push: func(client *Client, ref string) (*ArtifactResult, error) {
+				return client.PushArtifact(context.Background(), artifactPath, ref, PushOptions{Insecure: true})
+			},
+		},
+		{
+			name: "manifest",
+			repo: "team/multi",
+			push: func(client *Client, ref string) (*ArtifactResult, error) {
+				return client.PushManifest(context.Background(), manifestPath, ref, io.Discard, PushOptions{Insecure: true})
+			},
+		},
+		{
+			name: "from-cache",
+			repo: "mirror/tool",
+			push: func(client *Client, ref string) (*ArtifactResult, error) {
+				return client.PushFromCache(context.Background(), pulled.ID, ref, PushOptions{Insecure: true})
+			},
+		},
+		{
+			name: "family-index",
+			repo: "team/suite",
+			push: func(client *Client, ref string) (*ArtifactResult, error) {
+				return client.PushFamilyIndex(context.Background(), FamilyIndex{
+					Name:    "suite",
+					Version: "1.0.0",
+					Members: []FamilyMember{{Name: "tool", Reference: registry.Host() + "/team/tool:1.0.0"}},
+				}, ref, true)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := registry.Host() + "/" + tt.repo + ":1.0.0"
+			recorder := tracetest.NewSpanRecorder()
+			client := newClient("")
+			client.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			var events []Event
+			client.config.Events = EventPublisherFunc(func(_ context.Context, event Event) error {
+				events = append(events, event)
+				return nil
+			})
+
+			succeeded, failed := pushes(client, OutcomeSuccess), pushes(client, OutcomeFailure)
+			result, err := tt.push(client, ref)
+			require.NoError(t, err)
+
+			var traced []string
+			for _, span := range recorder.Ended() {
+				if span.Name() != "porter.push" {
+					continue
+				}
+				for _, attr := range span.Attributes() {
+					if attr.Key == "oci.digest" {
+						traced = append(traced, attr.Value.AsString())
+					}
+				}
+			}
+			assert.Equal(t, []string{result.Digest}, traced)
+
+			require.NotNil(t, result.Metrics)
+			assert.Positive(t, result.Metrics.Requests)
+			assert.Equal(t, succeeded+1, pushes(client, OutcomeSuccess))
+
+			require.Len(t, events, 1)
+			assert.Equal(t, EventArtifactPushed, events[0].Type)
+			assert.Equal(t, result.Reference, events[0].Reference)
+			assert.Equal(t, result.Digest, events[0].Digest)
+
+			// Faults are read when the client is built, so the slow client comes after the env.
+			t.Setenv(faults.EnvVar, "delay=2s")
+			slow := newClient("50ms")
+			events = nil
+			slow.config.Events = client.config.Events
+			_, err = tt.push(slow, ref)
+			var timeout *TimeoutError
+			require.True(t, errors.As(err, &timeout), "error = %v", err)
+			assert.Equal(t, "push", timeout.Operation)
+			assert.Equal(t, failed+1, pushes(slow, OutcomeFailure))
+			assert.Empty(t, events, "failed pushes publish nothing")
+		})
+	}
+}
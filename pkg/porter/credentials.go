@@ -0,0 +1,197 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// CredentialsFileName is the file login writes under the porter directory of the
+// user's home, ~/.ds/porter, unless credentials_file names another.
+const CredentialsFileName = "credentials.json"
+
+// Credential sources reported by auth check.
+const (
+	credentialSourceConfig    = "porter-config"
+	credentialSourceLogin     = "porter-login"
+	credentialSourceAnonymous = "anonymous"
+)
+
+// storedCredentials is the content of the credentials file: the username and
+// password login validated for each normalized registry.
+type storedCredentials struct {
+	Auths map[string]storedCredential `json:"auths"`
+}
+
+type storedCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginOptions controls Login.
+type LoginOptions struct {
+	Username string
+	Password string
+	Insecure bool
+}
+
+// LoginResult reports a login or logout; it never carries the password.
+type LoginResult struct {
+	Registry string `json:"registry"`
+	Username string `json:"username,omitempty"`
+	// CredentialsFile is the file holding the credentials, and CredentialsStore
+	// the OS credential store instead when credentials_store selects one.
+	CredentialsFile  string `json:"credentials_file,omitempty"`
+	CredentialsStore string `json:"credentials_store,omitempty"`
+	// Removed reports, for logout, whether the registry had stored credentials.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// credentialsPath is the credentials file login writes and pulls and pushes read.
+func (c *Client) credentialsPath() (string, error) {
+	if path := strings.TrimSpace(c.config.CredentialsFile); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the credentials file: %w", err)
+	}
+	return filepath.Join(home, ".ds", "porter", CredentialsFileName), nil
+}
+
+// loadCredentials reads the credentials file; a missing file holds none.
+func loadCredentials(path string) (*storedCredentials, error) {
+	creds := &storedCredentials{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		creds.Auths = map[string]storedCredential{}
+		return creds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+	if creds.Auths == nil {
+		creds.Auths = map[string]storedCredential{}
+	}
+	return creds, nil
+}
+
+// save writes the credentials file atomically, readable by its owner only.
+func (s *storedCredentials) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	// CreateTemp creates the file with mode 0600.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	return nil
+}
+
+// storedCredential returns the credentials login stored for registry.
+func (c *Client) storedCredential(registry string) (storedCredential, bool) {
+	store, err := c.credentialStore()
+	if err != nil {
+		c.logger.Warn("Ignoring stored credentials", "error", err)
+		return storedCredential{}, false
+	}
+	cred, ok, err := store.get(reference.NormalizeRegistry(registry))
+	if err != nil {
+		c.logger.Warn("Ignoring unreadable credential store", "store", store.location(), "error", err)
+		return storedCredential{}, false
+	}
+	return cred, ok && cred.Password != ""
+}
+
+// Login checks username and password against registry, completing its token
+// handshake the way a pull would, and stores them in the credential store once
+// the registry accepts them. Credentials in the DS configuration still take
+// precedence for the registry.
+func (c *Client) Login(ctx context.Context, registry string, opts LoginOptions) (*LoginResult, error) {
+	host := reference.NormalizeRegistry(registry)
+	if host == "" {
+		return nil, fmt.Errorf("registry required")
+	}
+	if opts.Password == "" {
+		return nil, fmt.Errorf("password required")
+	}
+	c.redactor.Add(opts.Password)
+	username := opts.Username
+	if username == "" {
+		username = defaultUsername()
+	}
+
+	reg, err := remote.NewRegistry(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	reg.PlainHTTP = opts.Insecure
+	reg.Client = &auth.Client{
+		Client:     c.httpClient(host),
+		Cache:      auth.NewCache(),
+		Credential: auth.StaticCredential(host, auth.Credential{Username: username, Password: opts.Password}),
+	}
+	if err := reg.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("failed to log in to %s: %w", host, err)
+	}
+
+	store, err := c.credentialStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.put(host, storedCredential{Username: username, Password: opts.Password}); err != nil {
+		return nil, err
+	}
+	c.logger.Info("Logged in to registry", "registry", host, "username", username, "store", store.location())
+	result := &LoginResult{Registry: host, Username: username}
+	store.describe(result)
+	return result, nil
+}
+
+// Logout removes the credentials login stored for registry.
+func (c *Client) Logout(registry string) (*LoginResult, error) {
+	host := reference.NormalizeRegistry(registry)
+	if host == "" {
+		return nil, fmt.Errorf("registry required")
+	}
+	store, err := c.credentialStore()
+	if err != nil {
+		return nil, err
+	}
+	removed, err := store.erase(host)
+	if err != nil {
+		return nil, err
+	}
+	result := &LoginResult{Registry: host, Removed: removed}
+	store.describe(result)
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginStoresValidatedCredentials(t *testing.T) {
+	server := newTokenRegistry(t, "alice", "s3cret")
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := newTestClient(t, nil)
+
+	_, err := client.Login(context.Background(), host, LoginOptions{Username: "alice", Password: "wrong", Insecure: true})
+	require.Error(t, err)
+	path, err := client.credentialsPath()
+	require.NoError(t, err)
+	assert.NoFileExists(t, path, "rejected credentials are not stored")
+
+	result, err := client.Login(context.Background(), host, LoginOptions{Username: "alice", Password: "s3cret", Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, LoginResult{Registry: host, Username: "alice", CredentialsFile: path}, *result)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	report, err := client.CheckAuth(context.Background(), host+"/team/app:1.0.0", AuthCheckOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.True(t, report.Success)
+	assert.False(t, report.Anonymous)
+	assert.Equal(t, "porter-login", report.CredentialSource)
+
+	logout, err := client.Logout(host)
+	require.NoError(t, err)
+	assert.True(t, logout.Removed)
+	username, password := client.ResolveCredentials(host)
+	assert.Empty(t, username)
+	assert.Empty(t, password)
+
+	logout, err = client.Logout(host)
+	require.NoError(t, err)
+	assert.False(t, logout.Removed)
+}
+
+// fakeKeychain answers the docker-credential helper protocol from memory.
+type fakeKeychain struct {
+	secrets map[string]credentials.Credentials
+}
+
+type fakeKeychainCommand struct {
+	keychain *fakeKeychain
+	action   string
+	input    []byte
+}
+
+func (k *fakeKeychain) program(args ...string) client.Program {
+	return &fakeKeychainCommand{keychain: k, action: args[0]}
+}
+
+func (c *fakeKeychainCommand) Input(in io.Reader) {
+	c.input, _ = io.ReadAll(in)
+}
+
+func (c *fakeKeychainCommand) Output() ([]byte, error) {
+	switch c.action {
+	case credentials.ActionStore:
+		var creds credentials.Credentials
+		if err := json.Unmarshal(c.input, &creds); err != nil {
+			return nil, err
+		}
+		c.keychain.secrets[creds.ServerURL] = creds
+		return nil, nil
+	case credentials.ActionGet:
+		creds, ok := c.keychain.secrets[string(c.input)]
+		if !ok {
+			return []byte(credentials.NewErrCredentialsNotFound().Error()), errors.New("exit status 1")
+		}
+		return json.Marshal(creds)
+	case credentials.ActionErase:
+		delete(c.keychain.secrets, string(c.input))
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported action %s", c.action)
+}
+
+func TestLoginStoresCredentialsInKeychain(t *testing.T) {
+	keychain := &fakeKeychain{secrets: map[string]credentials.Credentials{}}
+	var helper string
+	original := newCredentialHelper
+	newCredentialHelper = func(name string) client.ProgramFunc {
+		helper = name
+		return keychain.program
+	}
+	t.Cleanup(func() { newCredentialHelper = original })
+
+	server := newTokenRegistry(t, "alice", "s3cret")
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := newTestClient(t, nil)
+	client.config.CredentialsStore = "keychain"
+
+	result, err := client.Login(context.Background(), host, LoginOptions{Username: "alice", Password: "s3cret", Insecure: true})
+	require.NoError(t, err)
+	assert.Empty(t, result.CredentialsFile)
+	assert.Equal(t, helper, result.CredentialsStore)
+	assert.Equal(t, "s3cret", keychain.secrets[host].Secret)
+	assert.NoFileExists(t, client.config.CredentialsFile, "the keychain replaces the credentials file")
+
+	username, password := client.ResolveCredentials(host)
+	assert.Equal(t, "alice", username)
+	assert.Equal(t, "s3cret", password)
+
+	logout, err := client.Logout(host)
+	require.NoError(t, err)
+	assert.True(t, logout.Removed)
+	assert.Empty(t, keychain.secrets)
+}
+
+func TestCredentialHelper(t *testing.T) {
+	for store, want := range map[string]string{"": "", "file": "", "pass": "pass", "osxkeychain": "osxkeychain"} {
+		got, err := credentialHelper(store)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, store)
+	}
+	native, err := credentialHelper("keychain")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"osxkeychain", "wincred", "secretservice"}, native)
+
+	_, err = credentialHelper("../bin/evil")
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package porter
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// Values of credentials_store besides the name of a credential helper.
+const (
+	// credentialStoreFile keeps credentials in the porter credentials file.
+	credentialStoreFile = "file"
+	// credentialStoreKeychain selects the credential store of the OS: the macOS
+	// Keychain, the Windows Credential Manager, or libsecret elsewhere.
+	credentialStoreKeychain = "keychain"
+)
+
+var credentialHelperName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// newCredentialHelper runs the docker-credential-<name> program, the protocol the
+// OS credential stores are reached through.
+var newCredentialHelper = func(name string) client.ProgramFunc {
+	return client.NewShellProgramFunc("docker-credential-" + name)
+}
+
+// credentialStore holds the credentials login stored, by normalized registry.
+type credentialStore interface {
+	get(registry string) (storedCredential, bool, error)
+	put(registry string, cred storedCredential) error
+	// erase removes the credentials of registry and reports whether it had any.
+	erase(registry string) (bool, error)
+	// location names the store in logs.
+	location() string
+	// describe records the store in a login result.
+	describe(result *LoginResult)
+}
+
+// credentialStore returns the store credentials_store selects.
+func (c *Client) credentialStore() (credentialStore, error) {
+	name, err := credentialHelper(c.config.CredentialsStore)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		path, err := c.credentialsPath()
+		if err != nil {
+			return nil, err
+		}
+		return &fileCredentialStore{path: path}, nil
+	}
+	return &helperCredentialStore{name: name, program: newCredentialHelper(name)}, nil
+}
+
+// credentialHelper resolves a credentials_store value to the name of its
+// credential helper, or "" for the credentials file.
+func credentialHelper(store string) (string, error) {
+	store = strings.ToLower(strings.TrimSpace(store))
+	switch store {
+	case "", credentialStoreFile:
+		return "", nil
+	case credentialStoreKeychain:
+		switch runtime.GOOS {
+		case "darwin":
+			return "osxkeychain", nil
+		case "windows":
+			return "wincred", nil
+		default:
+			return "secretservice", nil
+		}
+	}
+	if !credentialHelperName.MatchString(store) {
+		return "", fmt.Errorf("invalid credentials_store %q: expected file, keychain, or the name of a docker-credential helper", store)
+	}
+	return store, nil
+}
+
+// fileCredentialStore keeps credentials in the porter credentials file.
+type fileCredentialStore struct {
+	path string
+}
+
+func (s *fileCredentialStore) get(registry string) (storedCredential, bool, error) {
+	creds, err := loadCredentials(s.path)
+	if err != nil {
+		return storedCredential{}, false, err
+	}
+	cred, ok := creds.Auths[registry]
+	return cred, ok, nil
+}
+
+func (s *fileCredentialStore) put(registry string, cred storedCredential) error {
+	creds, err := loadCredentials(s.path)
+	if err != nil {
+		return err
+	}
+	creds.Auths[registry] = cred
+	return creds.save(s.path)
+}
+
+func (s *fileCredentialStore) erase(registry string) (bool, error) {
+	creds, err := loadCredentials(s.path)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := creds.Auths[registry]; !ok {
+		return false, nil
+	}
+	delete(creds.Auths, registry)
+	return true, creds.save(s.path)
+}
+
+func (s *fileCredentialStore) location() string {
+	return s.path
+}
+
+func (s *fileCredentialStore) describe(result *LoginResult) {
+	result.CredentialsFile = s.path
+}
+
+// helperCredentialStore keeps credentials in an OS credential store through its
+// docker-credential helper, so no secret is written to disk in plain text.
+type helperCredentialStore struct {
+	name    string
+	program client.ProgramFunc
+}
+
+func (s *helperCredentialStore) get(registry string) (storedCredential, bool, error) {
+	creds, err := client.Get(s.program, registry)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return storedCredential{}, false, nil
+	}
+	if err != nil {
+		return storedCredential{}, false, fmt.Errorf("failed to read credentials from %s: %w", s.name, err)
+	}
+	return storedCredential{Username: creds.Username, Password: creds.Secret}, true, nil
+}
+
+func (s *helperCredentialStore) put(registry string, cred storedCredential) error {
+	err := client.Store(s.program, &credentials.Credentials{
+		ServerURL: registry,
+		Username:  cred.Username,
+		Secret:    cred.Password,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store credentials in %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (s *helperCredentialStore) erase(registry string) (bool, error) {
+	// Helpers differ in whether erasing a missing entry fails, so look first.
+	if _, ok, err := s.get(registry); err != nil || !ok {
+		return false, err
+	}
+	if err := client.Erase(s.program, registry); err != nil {
+		return false, fmt.Errorf("failed to remove credentials from %s: %w", s.name, err)
+	}
+	return true, nil
+}
+
+func (s *helperCredentialStore) location() string {
+	return s.name
+}
+
+func (s *helperCredentialStore) describe(result *LoginResult) {
+	result.CredentialsStore = s.name
+}
@@ -0,0 +1,305 @@
+package porter
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+)
+
+// DeliveryManifestFile records what porter last exported into a local destination.
+// It lets later exports skip files whose content has not changed.
+const DeliveryManifestFile = ".porter-delivery.json"
+
+const deliveryManifestVersion = 1
+
+// deliveryManifest is the on-disk record of an export, keyed by slash-separated
+// paths relative to the directory holding the manifest.
+type deliveryManifest struct {
+	Version   int                      `json:"version"`
+	Reference string                   `json:"reference,omitempty"`
+	Digest    string                   `json:"digest,omitempty"`
+	UpdatedAt time.Time                `json:"updated_at"`
+	Files     map[string]deliveredFile `json:"files"`
+}
+
+// deliveredFile describes one exported file. Size and ModTime detect files changed
+// on the device since the export without re-reading them.
+type deliveredFile struct {
+	Digest  digest.Digest `json:"digest"`
+	Size    int64         `json:"size"`
+	Mode    os.FileMode   `json:"mode,omitempty"`
+	ModTime int64         `json:"mtime"`
+}
+
+// delivery compares an export against the previous delivery manifest of its
+// destination and collects the records for the new one. It is safe for concurrent use.
+type delivery struct {
+	root   string
+	logger hclog.Logger
+
+	mu       sync.Mutex
+	previous map[string]deliveredFile
+	expected map[string]digest.Digest
+	current  deliveryManifest
+	skipped  int
+}
+
+// loadDelivery reads the delivery manifest in root. A missing or unreadable manifest
+// starts an empty one, so the first export writes every file.
+func loadDelivery(root string, logger hclog.Logger) *delivery {
+	d := &delivery{
+		root:     root,
+		logger:   logger,
+		previous: make(map[string]deliveredFile),
+		expected: make(map[string]digest.Digest),
+		current:  deliveryManifest{Version: deliveryManifestVersion, Files: make(map[string]deliveredFile)},
+	}
+
+	var manifest deliveryManifest
+	err := readJSONFile(filepath.Join(root, DeliveryManifestFile), &manifest)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		logger.Warn("Ignoring unreadable delivery manifest", "dir", root, "error", err)
+	case manifest.Version != deliveryManifestVersion:
+		logger.Warn("Ignoring delivery manifest with unknown version", "dir", root, "version", manifest.Version)
+	default:
+		for name, file := range manifest.Files {
+			d.previous[name] = file
+			d.current.Files[name] = file
+		}
+	}
+	return d
+}
+
+func (d *delivery) key(path string) (string, bool) {
+	rel, err := filepath.Rel(d.root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// expect records the digest path will have once exported.
+func (d *delivery) expect(path string, dgst digest.Digest) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.expected[path] = dgst
+	d.mu.Unlock()
+}
+
+// hasPrevious reports whether anything was delivered to the destination before.
+func (d *delivery) hasPrevious() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.previous) > 0
+}
+
+// delivered reports whether the previous delivery wrote path.
+func (d *delivery) delivered(path string) bool {
+	if d == nil {
+		return false
+	}
+	key, ok := d.key(path)
+	if !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok = d.previous[key]
+	return ok
+}
+
+// unchanged reports whether path already holds the expected content: the previous
+// delivery recorded the same digest and size, and the file on disk still has the
+// recorded size and modification time. Only the mode is corrected when it differs.
+func (d *delivery) unchanged(path string, size int64, mode os.FileMode) bool {
+	if d == nil {
+		return false
+	}
+	key, ok := d.key(path)
+	if !ok {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expected, ok := d.expected[path]
+	if !ok {
+		return false
+	}
+	previous, ok := d.previous[key]
+	if !ok || previous.Digest != expected || previous.Size != size {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size || info.ModTime().UnixNano() != previous.ModTime {
+		return false
+	}
+	if mode != 0 && info.Mode().Perm() != mode.Perm() {
+		if err := os.Chmod(path, mode.Perm()); err != nil {
+			return false
+		}
+		previous.Mode = mode.Perm()
+	}
+
+	d.current.Files[key] = previous
+	d.skipped++
+	d.logger.Debug("Skipping unchanged file", "path", path, "digest", expected)
+	return true
+}
+
+// plannedAction reports how an export would change path to give it the content
+// dgst: create it, overwrite it, or leave it unchanged as unchanged would.
+func (d *delivery) plannedAction(path string, dgst digest.Digest, size int64) string {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return ChangeCreate
+	}
+	if key, ok := d.key(path); ok {
+		previous, ok := d.previous[key]
+		if ok && previous.Digest == dgst && previous.Size == size && info.Mode().IsRegular() &&
+			info.Size() == size && info.ModTime().UnixNano() == previous.ModTime {
+			return ChangeUnchanged
+		}
+	}
+	return ChangeOverwrite
+}
+
+// record stores the state of a freshly written file.
+func (d *delivery) record(path string, dgst digest.Digest, size int64, mode os.FileMode) {
+	if d == nil {
+		return
+	}
+	key, ok := d.key(path)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.current.Files[key] = deliveredFile{
+		Digest:  dgst,
+		Size:    size,
+		Mode:    mode.Perm(),
+		ModTime: info.ModTime().UnixNano(),
+	}
+}
+
+// save writes the updated delivery manifest next to the exported files.
+func (d *delivery) save(result *ArtifactResult, writer exportWriter) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	d.current.Reference = result.Reference
+	d.current.Digest = result.Digest
+	d.current.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(d.current, "", "  ")
+	skipped := d.skipped
+	d.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery manifest: %w", err)
+	}
+
+	writer.delivery = nil
+	writer.verified = nil
+	f, err := writer.create(filepath.Join(d.root, DeliveryManifestFile), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write delivery manifest: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to write delivery manifest: %w", err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to write delivery manifest: %w", err)
+	}
+
+	if skipped > 0 {
+		d.logger.Info("Skipped unchanged files", "dir", d.root, "count", skipped)
+	}
+	return nil
+}
+
+// scanArchive returns the digest of every regular file in a compressed tar, keyed
+// by its cleaned entry name, without writing anything.
+func scanArchive(reader io.Reader, compression string) (map[string]digest.Digest, error) {
+	entries, err := listArchive(reader, compression)
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]digest.Digest, len(entries))
+	for _, entry := range entries {
+		digests[entry.Name] = entry.Digest
+	}
+	return digests, nil
+}
+
+// archiveEntry is a regular file of an archive layer, or a hard link to one.
+type archiveEntry struct {
+	Name   string
+	Size   int64
+	Digest digest.Digest
+}
+
+// listArchive returns the regular files of a compressed tar in archive order, with
+// cleaned names, without writing anything. Hard links are listed with the content
+// of the file they link to.
+func listArchive(reader io.Reader, compression string) ([]archiveEntry, error) {
+	decompressed, err := release.NewDecompressor(reader, compression)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = decompressed.Close()
+	}()
+
+	var entries []archiveEntry
+	files := make(map[string]archiveEntry)
+	tarReader := tar.NewReader(decompressed)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive entry: %w", err)
+		}
+		name := filepath.Clean(header.Name)
+		switch header.Typeflag {
+		case tar.TypeReg:
+			digester := digest.Canonical.Digester()
+			size, err := io.Copy(digester.Hash(), tarReader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read archive entry %s: %w", header.Name, err)
+			}
+			files[name] = archiveEntry{Name: name, Size: size, Digest: digester.Digest()}
+			entries = append(entries, files[name])
+		case tar.TypeLink:
+			if target, ok := files[filepath.Clean(header.Linkname)]; ok {
+				entries = append(entries, archiveEntry{Name: name, Size: target.Size, Digest: target.Digest})
+			}
+		}
+	}
+}
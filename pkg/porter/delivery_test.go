@@ -0,0 +1,174 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"bin/tool", "share/data.bin", "README"} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// exportedFiles stats the exported files below dir. Exports replace a file they
+// rewrite, so os.SameFile tells rewritten files apart even when the archive
+// restores their modification time.
+func exportedFiles(t *testing.T, dir string) map[string]os.FileInfo {
+	t.Helper()
+	files := make(map[string]os.FileInfo)
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Name() == DeliveryManifestFile {
+			return err
+		}
+		files[path] = info
+		return nil
+	}))
+	return files
+}
+
+// assertRewritten asserts which of the files stat'ed in before were rewritten.
+func assertRewritten(t *testing.T, before, after map[string]os.FileInfo, rewritten func(path string) bool) {
+	t.Helper()
+	require.Len(t, after, len(before))
+	for path, info := range before {
+		if rewritten(path) {
+			assert.False(t, os.SameFile(info, after[path]), "%s must be rewritten", path)
+		} else {
+			assert.True(t, os.SameFile(info, after[path]), "unchanged file %s must not be rewritten", path)
+		}
+	}
+}
+
+func TestExportSkipsUnchangedFiles(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/bundle", "1.0.0", "application/vnd.oci.image.layer.v1.tar+gzip",
+		tarGz(t, map[string]string{"bin/tool": "tool v1", "share/data.bin": "large data", "README": "readme"}), nil)
+	registry.AddArtifact("team/bundle", "1.0.1", "application/vnd.oci.image.layer.v1.tar+gzip",
+		tarGz(t, map[string]string{"bin/tool": "tool v2", "share/data.bin": "large data", "README": "readme"}), nil)
+
+	client := newTestClient(t, nil)
+	outDir := t.TempDir()
+
+	v1, err := client.PullArtifact(context.Background(), registry.Host()+"/team/bundle:1.0.0", true)
+	require.NoError(t, err)
+	_, err = client.ExportArtifact(context.Background(), v1, outDir, ExportOptions{})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outDir, DeliveryManifestFile))
+	before := exportedFiles(t, outDir)
+	require.Len(t, before, 3)
+	time.Sleep(10 * time.Millisecond)
+
+	v2, err := client.PullArtifact(context.Background(), registry.Host()+"/team/bundle:1.0.1", true)
+	require.NoError(t, err)
+	exported, err := client.ExportArtifact(context.Background(), v2, outDir, ExportOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, exported, filepath.Join(outDir, "share", "data.bin"))
+
+	assertRewritten(t, before, exportedFiles(t, outDir), func(path string) bool { return filepath.Base(path) == "tool" })
+	data, err := os.ReadFile(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	assert.Equal(t, "tool v2", string(data))
+
+	// A file modified on the device since the export is rewritten.
+	tampered := filepath.Join(outDir, "README")
+	require.NoError(t, os.WriteFile(tampered, []byte("edited"), 0644))
+	_, err = client.ExportArtifact(context.Background(), v2, outDir, ExportOptions{})
+	require.NoError(t, err)
+	data, err = os.ReadFile(tampered)
+	require.NoError(t, err)
+	assert.Equal(t, "readme", string(data))
+
+	// --full rewrites everything.
+	before = exportedFiles(t, outDir)
+	time.Sleep(10 * time.Millisecond)
+	_, err = client.ExportArtifact(context.Background(), v2, outDir, ExportOptions{Full: true})
+	require.NoError(t, err)
+	assertRewritten(t, before, exportedFiles(t, outDir), func(string) bool { return true })
+}
+
+func TestExportSkipsUnchangedSingleFile(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	target := filepath.Join(t.TempDir(), "tool.bin")
+	_, err = client.ExportArtifact(context.Background(), result, target, ExportOptions{})
+	require.NoError(t, err)
+	before, err := os.Stat(target)
+	require.NoError(t, err)
+
+	_, err = client.ExportArtifact(context.Background(), result, target, ExportOptions{})
+	require.NoError(t, err)
+	after, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+	assert.Equal(t, before.ModTime(), after.ModTime())
+
+	var manifest deliveryManifest
+	require.NoError(t, readJSONFile(filepath.Join(filepath.Dir(target), DeliveryManifestFile), &manifest))
+	assert.Equal(t, result.Digest, manifest.Digest)
+	require.Contains(t, manifest.Files, "tool.bin")
+	assert.Equal(t, int64(len("tool binary")), manifest.Files["tool.bin"].Size)
+}
+
+func TestExportExtractsZstdArchiveLayer(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("tool v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "README"), []byte("readme"), 0644))
+	staging, err := release.OpenStaging(t.TempDir(), release.StagingOptions{})
+	require.NoError(t, err)
+	archivePath, cleanup, err := staging.ArchiveDirectory(src, release.CompressionZstd)
+	require.NoError(t, err)
+	defer cleanup()
+	archive, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/bundle", "1.0.0", release.MediaTypeArtifactArchiveZstd, archive, nil)
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/bundle:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	assert.Equal(t, "tool v1", string(data))
+	before := exportedFiles(t, outDir)
+	time.Sleep(10 * time.Millisecond)
+
+	// A second export digests the zstd layer and leaves the unchanged files alone.
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	assertRewritten(t, before, exportedFiles(t, outDir), func(string) bool { return false })
+}
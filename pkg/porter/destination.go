@@ -0,0 +1,314 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Destination receives exported files. Implementations deliver content to a local
+// directory or a remote target such as an SSH host or an S3 bucket.
+type Destination interface {
+	// Put writes r to name, a slash-separated path relative to the destination root,
+	// and returns the user-facing location of the written file.
+	Put(ctx context.Context, name string, r io.ReadSeeker, size int64, mode fs.FileMode) (string, error)
+	// Close releases connections held by the destination.
+	Close() error
+}
+
+// Committer is implemented by destinations that deliver all files as a single
+// object. Commit is called once after every file has been Put.
+type Committer interface {
+	Commit(ctx context.Context) error
+}
+
+// DestinationFactory opens a destination for a parsed export URL.
+type DestinationFactory func(c *Client, target *url.URL) (Destination, error)
+
+// destinationBackends maps URL schemes to the backends able to serve them.
+var destinationBackends = map[string]DestinationFactory{
+	"file": newLocalDestination,
+	"ssh":  newSSHDestination,
+	"scp":  newSSHDestination,
+	"s3":   newS3Destination,
+	"k8s":  newKubernetesDestination,
+}
+
+// RegisterDestination makes a destination backend available for the given URL scheme.
+func RegisterDestination(scheme string, factory DestinationFactory) {
+	destinationBackends[strings.ToLower(scheme)] = factory
+}
+
+// ExportConfig holds per-backend credentials for remote export destinations.
+type ExportConfig struct {
+	SSH        SSHConfig        `json:"ssh"`
+	S3         S3Config         `json:"s3"`
+	Kubernetes KubernetesConfig `json:"kubernetes"`
+}
+
+// IsRemoteDestination reports whether destination names a backend other than the
+// local filesystem.
+func IsRemoteDestination(destination string) bool {
+	target, ok := parseDestinationURL(destination)
+	return ok && target.Scheme != "file"
+}
+
+// parseDestinationURL parses destination when it carries a registered URL scheme.
+func parseDestinationURL(destination string) (*url.URL, bool) {
+	scheme, _, found := strings.Cut(destination, "://")
+	if !found {
+		return nil, false
+	}
+	if _, ok := destinationBackends[strings.ToLower(scheme)]; !ok {
+		return nil, false
+	}
+	target, err := url.Parse(destination)
+	if err != nil {
+		return nil, false
+	}
+	target.Scheme = strings.ToLower(target.Scheme)
+	return target, true
+}
+
+// exportToDestination stages the export in a temporary directory and delivers every
+// file to the destination backend selected by the URL scheme.
+func (c *Client) exportToDestination(ctx context.Context, result *ArtifactResult, target *url.URL, opts ExportOptions) ([]string, error) {
+	factory := destinationBackends[target.Scheme]
+	dest, err := factory(c, target)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := dest.Close(); err != nil {
+			c.logger.Warn("Failed to close export destination", "destination", target.Redacted(), "error", err)
+		}
+	}()
+
+	stageDir, err := os.MkdirTemp("", "porter-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(stageDir)
+	}()
+
+	// A remote path that looks like a file receives a single exported file under that
+	// name. Committed destinations name an object rather than a file.
+	committer, batched := dest.(Committer)
+	stageTarget := stageDir
+	if !batched && destinationLooksLikeFile(target.Path) {
+		stageTarget = filepath.Join(stageDir, path.Base(target.Path))
+	}
+
+	staged, err := c.exportArtifact(ctx, result, stageTarget, opts)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(staged)
+
+	delivered := make([]string, 0, len(staged))
+	for _, file := range staged {
+		rel, err := filepath.Rel(stageDir, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve staged file %s: %w", file, err)
+		}
+		location, err := putFile(ctx, dest, filepath.ToSlash(rel), file)
+		if err != nil {
+			return nil, err
+		}
+		c.logger.Info("Delivered exported file", "path", location)
+		delivered = append(delivered, location)
+	}
+
+	if batched {
+		if err := committer.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return delivered, nil
+}
+
+func putFile(ctx context.Context, dest Destination, name, source string) (string, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to open staged file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat staged file: %w", err)
+	}
+
+	location, err := dest.Put(ctx, name, f, info.Size(), info.Mode().Perm())
+	if err != nil {
+		return "", fmt.Errorf("failed to deliver %s: %w", name, err)
+	}
+	return location, nil
+}
+
+// localDestination writes files below a directory on the local filesystem.
+type localDestination struct {
+	root string
+}
+
+func newLocalDestination(_ *Client, target *url.URL) (Destination, error) {
+	root := target.Path
+	if root == "" {
+		root = target.Opaque
+	}
+	if root == "" {
+		return nil, fmt.Errorf("file destination requires a path")
+	}
+	return &localDestination{root: filepath.FromSlash(root)}, nil
+}
+
+func (d *localDestination) Put(_ context.Context, name string, r io.ReadSeeker, _ int64, mode fs.FileMode) (string, error) {
+	target := filepath.Join(d.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("failed to close file: %w", err)
+	}
+	return target, nil
+}
+
+func (d *localDestination) Close() error {
+	return nil
+}
+
+// exportConfigFromSettings reads backend credentials from plugins.settings.porter.
+func exportConfigFromSettings(settings map[string]map[string]interface{}) ExportConfig {
+	porterSettings := settings["porter"]
+	sshSettings, _ := porterSettings["ssh"].(map[string]interface{})
+	s3Settings, _ := porterSettings["s3"].(map[string]interface{})
+	kubernetesSettings, _ := porterSettings["kubernetes"].(map[string]interface{})
+
+	return ExportConfig{
+		SSH: SSHConfig{
+			User:                  settingString(sshSettings, "user"),
+			Password:              settingString(sshSettings, "password"),
+			KeyPath:               settingString(sshSettings, "key_path"),
+			KeyPassphrase:         settingString(sshSettings, "key_passphrase"),
+			KnownHostsPath:        settingString(sshSettings, "known_hosts"),
+			InsecureIgnoreHostKey: settingBool(sshSettings, "insecure_ignore_host_key"),
+		},
+		S3: S3Config{
+			Region:          settingString(s3Settings, "region"),
+			Endpoint:        settingString(s3Settings, "endpoint"),
+			AccessKeyID:     settingString(s3Settings, "access_key_id"),
+			SecretAccessKey: settingString(s3Settings, "secret_access_key"),
+			SessionToken:    settingString(s3Settings, "session_token"),
+			UsePathStyle:    settingBool(s3Settings, "use_path_style"),
+		},
+		Kubernetes: KubernetesConfig{
+			Kubeconfig: settingString(kubernetesSettings, "kubeconfig"),
+			Context:    settingString(kubernetesSettings, "context"),
+		},
+	}
+}
+
+func settingString(settings map[string]interface{}, key string) string {
+	value, _ := settings[key].(string)
+	return strings.TrimSpace(value)
+}
+
+func settingInt(settings map[string]interface{}, key string) int {
+	switch value := settings[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	case string:
+		parsed, _ := strconv.Atoi(strings.TrimSpace(value))
+		return parsed
+	}
+	return 0
+}
+
+func settingBool(settings map[string]interface{}, key string) bool {
+	switch value := settings[key].(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(strings.TrimSpace(value), "true")
+	}
+	return false
+}
+
+func settingStringMap(settings map[string]interface{}, key string) map[string]string {
+	var result map[string]string
+	add := func(k, v string) {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "" || v == "" {
+			return
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[k] = v
+	}
+	switch value := settings[key].(type) {
+	case map[string]string:
+		for k, v := range value {
+			add(k, v)
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			if s, ok := v.(string); ok {
+				add(k, s)
+			}
+		}
+	}
+	return result
+}
+
+// settingStrings reads a list of strings, accepting a YAML sequence or a
+// comma-separated string.
+func settingStrings(settings map[string]interface{}, key string) []string {
+	var result []string
+	add := func(v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	switch value := settings[key].(type) {
+	case string:
+		for _, v := range strings.Split(value, ",") {
+			add(v)
+		}
+	case []string:
+		for _, v := range value {
+			add(v)
+		}
+	case []interface{}:
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				add(s)
+			}
+		}
+	}
+	return result
+}
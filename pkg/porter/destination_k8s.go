@@ -0,0 +1,376 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KubernetesConfig selects the cluster used by k8s:// export destinations. Unset
+// fields fall back to $KUBECONFIG, ~/.kube/config, and finally in-cluster credentials.
+type KubernetesConfig struct {
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// maxKubernetesObjectBytes is the size limit the API server enforces on ConfigMaps and Secrets.
+const maxKubernetesObjectBytes = 1 << 20
+
+const (
+	serviceAccountDir       = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesManager       = "porter"
+	kubernetesKindConfigMap = "configmap"
+	kubernetesKindSecret    = "secret"
+)
+
+var kubernetesKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// kubernetesDestination collects exported files and applies them as a single
+// ConfigMap or Secret when committed. Workloads mount the object as a volume.
+type kubernetesDestination struct {
+	api       *kubernetesAPI
+	namespace string
+	kind      string
+	name      string
+	files     map[string][]byte
+	size      int
+}
+
+// newKubernetesDestination handles k8s://<namespace>/<configmap|secret>/<name>[?context=<ctx>].
+func newKubernetesDestination(c *Client, target *url.URL) (Destination, error) {
+	namespace := target.Host
+	parts := strings.Split(strings.Trim(target.Path, "/"), "/")
+	if namespace == "" || len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("kubernetes destination must be k8s://<namespace>/<configmap|secret>/<name>")
+	}
+
+	kind := strings.ToLower(parts[0])
+	if kind != kubernetesKindConfigMap && kind != kubernetesKindSecret {
+		return nil, fmt.Errorf("unsupported kubernetes object kind %q (expected configmap or secret)", parts[0])
+	}
+
+	cfg := c.config.Export.Kubernetes
+	if kubeContext := target.Query().Get("context"); kubeContext != "" {
+		cfg.Context = kubeContext
+	}
+
+	api, err := newKubernetesAPI(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("Using kubernetes destination", "server", api.server, "namespace", namespace, "kind", kind, "name", parts[1])
+	return &kubernetesDestination{
+		api:       api,
+		namespace: namespace,
+		kind:      kind,
+		name:      parts[1],
+		files:     make(map[string][]byte),
+	}, nil
+}
+
+func (d *kubernetesDestination) Put(_ context.Context, name string, r io.ReadSeeker, size int64, _ fs.FileMode) (string, error) {
+	key := strings.ReplaceAll(name, "/", "_")
+	if !kubernetesKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("file name %q is not a valid %s key", name, d.kind)
+	}
+
+	d.size += int(size)
+	if d.size > maxKubernetesObjectBytes {
+		return "", fmt.Errorf("export exceeds the %d byte limit of a kubernetes %s", maxKubernetesObjectBytes, d.kind)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	d.files[key] = data
+	return fmt.Sprintf("k8s://%s/%s/%s#%s", d.namespace, d.kind, d.name, key), nil
+}
+
+// Commit applies the collected files with server-side apply.
+func (d *kubernetesDestination) Commit(ctx context.Context) error {
+	object := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      d.name,
+			"namespace": d.namespace,
+			"labels": map[string]string{
+				"app.kubernetes.io/managed-by": kubernetesManager,
+			},
+		},
+	}
+
+	encoded := make(map[string]string, len(d.files))
+	for key, data := range d.files {
+		encoded[key] = base64.StdEncoding.EncodeToString(data)
+	}
+
+	resource := "configmaps"
+	if d.kind == kubernetesKindSecret {
+		resource = "secrets"
+		object["apiVersion"] = "v1"
+		object["kind"] = "Secret"
+		object["type"] = "Opaque"
+		object["data"] = encoded
+	} else {
+		object["apiVersion"] = "v1"
+		object["kind"] = "ConfigMap"
+		object["binaryData"] = encoded
+	}
+
+	body, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", d.kind, err)
+	}
+
+	path := fmt.Sprintf("/api/v1/namespaces/%s/%s/%s?fieldManager=%s&force=true",
+		url.PathEscape(d.namespace), resource, url.PathEscape(d.name), kubernetesManager)
+	if err := d.api.do(ctx, http.MethodPatch, path, "application/apply-patch+yaml", body); err != nil {
+		return fmt.Errorf("failed to apply %s %s/%s: %w", d.kind, d.namespace, d.name, err)
+	}
+	return nil
+}
+
+func (d *kubernetesDestination) Close() error {
+	return nil
+}
+
+// kubernetesAPI is a minimal client for the Kubernetes REST API.
+type kubernetesAPI struct {
+	server     string
+	token      string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Username              string      `yaml:"username"`
+			Password              string      `yaml:"password"`
+			Exec                  interface{} `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+func newKubernetesAPI(cfg KubernetesConfig) (*kubernetesAPI, error) {
+	path := expandHome(cfg.Kubeconfig)
+	if path == "" {
+		path = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".kube", "config")
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && cfg.Kubeconfig == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			return inClusterKubernetesAPI()
+		}
+		return nil, fmt.Errorf("failed to read kubeconfig %s: %w", path, err)
+	}
+	return parseKubeconfig(data, filepath.Dir(path), cfg.Context)
+}
+
+func parseKubeconfig(data []byte, baseDir, contextName string) (*kubernetesAPI, error) {
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	var clusterName, userName string
+	found := false
+	for _, ctx := range config.Contexts {
+		if ctx.Name == contextName {
+			clusterName, userName, found = ctx.Context.Cluster, ctx.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig context %q not found", contextName)
+	}
+
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	api := &kubernetesAPI{}
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		api.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		ca, err := pemSource(cluster.Cluster.CertificateAuthorityData, resolve(cluster.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cluster CA: %w", err)
+		}
+		if len(ca) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("cluster CA for %s contains no certificates", clusterName)
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if api.server == "" {
+		return nil, fmt.Errorf("kubeconfig cluster %q not found", clusterName)
+	}
+
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+		if user.User.Exec != nil {
+			return nil, fmt.Errorf("kubeconfig user %q uses an exec credential plugin, which porter does not support", userName)
+		}
+		api.token = user.User.Token
+		if api.token == "" && user.User.TokenFile != "" {
+			token, err := os.ReadFile(resolve(user.User.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read token file: %w", err)
+			}
+			api.token = strings.TrimSpace(string(token))
+		}
+		api.username, api.password = user.User.Username, user.User.Password
+
+		cert, err := pemSource(user.User.ClientCertificateData, resolve(user.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		key, err := pemSource(user.User.ClientKeyData, resolve(user.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client key: %w", err)
+		}
+		if len(cert) > 0 && len(key) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	api.httpClient = newKubernetesHTTPClient(tlsConfig)
+	return api, nil
+}
+
+func inClusterKubernetesAPI() (*kubernetesAPI, error) {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		tlsConfig.RootCAs = pool
+	}
+	return &kubernetesAPI{
+		server:     "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")),
+		token:      strings.TrimSpace(string(token)),
+		httpClient: newKubernetesHTTPClient(tlsConfig),
+	}, nil
+}
+
+func newKubernetesHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 60 * time.Second}
+}
+
+// pemSource returns inline base64 data when present, otherwise the contents of path.
+func pemSource(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return base64.StdEncoding.DecodeString(inline)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+func (a *kubernetesAPI) do(ctx context.Context, method, path, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, a.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case a.token != "":
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	case a.username != "":
+		req.SetBasicAuth(a.username, a.password)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, status.Message)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
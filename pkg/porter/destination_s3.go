@@ -0,0 +1,230 @@
+package porter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config holds credentials for s3:// export destinations. Unset fields fall back to
+// the standard AWS_* environment variables.
+type S3Config struct {
+	Region          string `json:"region,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"-"`
+	SecretAccessKey string `json:"-"`
+	SessionToken    string `json:"-"`
+	UsePathStyle    bool   `json:"use_path_style,omitempty"`
+}
+
+// s3Destination uploads files as objects below a bucket prefix.
+type s3Destination struct {
+	httpClient *http.Client
+	config     S3Config
+	bucket     string
+	prefix     string
+	now        func() time.Time
+}
+
+func newS3Destination(c *Client, target *url.URL) (Destination, error) {
+	cfg := c.config.Export.S3
+	if cfg.Region == "" {
+		cfg.Region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	}
+	if cfg.AccessKeyID == "" && cfg.SecretAccessKey == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no S3 credentials available: configure an access key or set AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY")
+	}
+
+	bucket := target.Host
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 destination requires a bucket, e.g. s3://bucket/prefix")
+	}
+
+	return &s3Destination{
+		httpClient: c.httpClient(""),
+		config:     cfg,
+		bucket:     bucket,
+		prefix:     strings.Trim(target.Path, "/"),
+		now:        time.Now,
+	}, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// objectURL returns the URL of key, using path-style addressing for custom endpoints.
+func (d *s3Destination) objectURL(key string) (*url.URL, error) {
+	escapedKey := s3EscapePath(key)
+	if d.config.Endpoint == "" {
+		host := fmt.Sprintf("s3.%s.amazonaws.com", d.config.Region)
+		if d.config.UsePathStyle {
+			return url.Parse(fmt.Sprintf("https://%s/%s/%s", host, d.bucket, escapedKey))
+		}
+		return url.Parse(fmt.Sprintf("https://%s.%s/%s", d.bucket, host, escapedKey))
+	}
+
+	if _, err := url.Parse(d.config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %w", d.config.Endpoint, err)
+	}
+	return url.Parse(fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(d.config.Endpoint, "/"), s3EscapePath(d.bucket), escapedKey))
+}
+
+func (d *s3Destination) Put(ctx context.Context, name string, r io.ReadSeeker, size int64, _ fs.FileMode) (string, error) {
+	key := path.Join(d.prefix, name)
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", name, err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	payloadHash := hex.EncodeToString(hasher.Sum(nil))
+
+	objectURL, err := d.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL.String(), io.NopCloser(r))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	d.sign(req, payloadHash)
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload s3://%s/%s: %w", d.bucket, key, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("failed to upload s3://%s/%s: %s: %s", d.bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return fmt.Sprintf("s3://%s/%s", d.bucket, key), nil
+}
+
+func (d *s3Destination) Close() error {
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (d *s3Destination) sign(req *http.Request, payloadHash string) {
+	now := d.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if d.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", d.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + d.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+d.config.SecretAccessKey), date)
+	key = hmacSHA256(key, d.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every segment of an object key as required by SigV4: only
+// unreserved characters are left as-is.
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		ch := key[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/':
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,295 @@
+package porter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig holds credentials for ssh:// export destinations. Unset fields fall back
+// to the SSH agent, the default identity files, and ~/.ssh/known_hosts.
+type SSHConfig struct {
+	User                  string `json:"user,omitempty"`
+	Password              string `json:"-"`
+	KeyPath               string `json:"key_path,omitempty"`
+	KeyPassphrase         string `json:"-"`
+	KnownHostsPath        string `json:"known_hosts,omitempty"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key,omitempty"`
+}
+
+// sshDestination delivers files over SSH using the SCP sink protocol.
+type sshDestination struct {
+	client *ssh.Client
+	agent  net.Conn
+	host   string
+	root   string
+}
+
+func newSSHDestination(c *Client, target *url.URL) (Destination, error) {
+	cfg := c.config.Export.SSH
+	cfg.KeyPath = expandHome(cfg.KeyPath)
+	cfg.KnownHostsPath = expandHome(cfg.KnownHostsPath)
+
+	root := path.Clean(target.Path)
+	if !path.IsAbs(root) || root == "/" {
+		return nil, fmt.Errorf("ssh destination requires an absolute path below /, e.g. ssh://host:/opt/app")
+	}
+
+	user := cfg.User
+	if target.User != nil && target.User.Username() != "" {
+		user = target.User.Username()
+	}
+	if user == "" {
+		user = defaultUsername()
+	}
+
+	host := target.Hostname()
+	port := target.Port()
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(host, port)
+
+	hostKeyCallback, err := sshHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+	methods, agentConn, err := sshAuthMethods(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	c.logger.Debug("Connected to SSH destination", "addr", addr, "user", user, "root", root)
+	return &sshDestination{client: client, agent: agentConn, host: host, root: root}, nil
+}
+
+// sshAuthMethods collects the configured key, default identities, SSH agent, and
+// password. The returned agent connection, if any, must be closed by the caller.
+func sshAuthMethods(cfg SSHConfig, target *url.URL) ([]ssh.AuthMethod, net.Conn, error) {
+	var methods []ssh.AuthMethod
+
+	keyPaths := []string{cfg.KeyPath}
+	if cfg.KeyPath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			keyPaths = []string{
+				filepath.Join(home, ".ssh", "id_ed25519"),
+				filepath.Join(home, ".ssh", "id_ecdsa"),
+				filepath.Join(home, ".ssh", "id_rsa"),
+			}
+		}
+	}
+	var signers []ssh.Signer
+	for _, keyPath := range keyPaths {
+		if keyPath == "" {
+			continue
+		}
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			if cfg.KeyPath != "" {
+				return nil, nil, fmt.Errorf("failed to read SSH key %s: %w", keyPath, err)
+			}
+			continue
+		}
+		var signer ssh.Signer
+		if cfg.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(cfg.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(data)
+		}
+		if err != nil {
+			if cfg.KeyPath != "" {
+				return nil, nil, fmt.Errorf("failed to parse SSH key %s: %w", keyPath, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	var agentConn net.Conn
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			agentConn = conn
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	password := cfg.Password
+	if target.User != nil {
+		if urlPassword, ok := target.User.Password(); ok {
+			password = urlPassword
+		}
+	}
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	if len(methods) == 0 {
+		return nil, nil, fmt.Errorf("no SSH credentials available: configure a key, password, or SSH agent")
+	}
+	return methods, agentConn, nil
+}
+
+func sshHostKeyCallback(cfg SSHConfig) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	knownHostsPath := cfg.KnownHostsPath
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts %s: %w", knownHostsPath, err)
+	}
+	return callback, nil
+}
+
+func (d *sshDestination) Put(_ context.Context, name string, r io.ReadSeeker, size int64, mode fs.FileMode) (string, error) {
+	session, err := d.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	// Target the parent so the root directory is created when missing.
+	if err := session.Start("scp -r -t " + shellQuote(path.Dir(d.root))); err != nil {
+		return "", fmt.Errorf("failed to start remote scp: %w", err)
+	}
+
+	name = path.Join(path.Base(d.root), name)
+	if err := scpSend(stdin, bufio.NewReader(stdout), name, r, size, mode); err != nil {
+		_ = stdin.Close()
+		return "", err
+	}
+	if err := stdin.Close(); err != nil {
+		return "", err
+	}
+	if err := session.Wait(); err != nil {
+		return "", fmt.Errorf("remote scp failed: %w", err)
+	}
+
+	return fmt.Sprintf("ssh://%s%s", d.host, path.Join(path.Dir(d.root), name)), nil
+}
+
+func (d *sshDestination) Close() error {
+	if d.agent != nil {
+		_ = d.agent.Close()
+	}
+	return d.client.Close()
+}
+
+// scpSend transmits a single file to an scp sink ("scp -r -t"), emitting directory
+// records for each element of name so missing directories are created remotely.
+func scpSend(w io.Writer, acks *bufio.Reader, name string, r io.Reader, size int64, mode fs.FileMode) error {
+	if err := scpAck(acks); err != nil {
+		return err
+	}
+
+	dirs := strings.Split(path.Dir(name), "/")
+	if path.Dir(name) == "." {
+		dirs = nil
+	}
+	for _, dir := range dirs {
+		if err := scpRecord(w, acks, fmt.Sprintf("D0755 0 %s\n", dir)); err != nil {
+			return err
+		}
+	}
+
+	if err := scpRecord(w, acks, fmt.Sprintf("C%04o %d %s\n", mode.Perm(), size, path.Base(name))); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, r, size); err != nil {
+		return fmt.Errorf("failed to send %s: %w", name, err)
+	}
+	if err := scpRecord(w, acks, "\x00"); err != nil {
+		return err
+	}
+
+	for range dirs {
+		if err := scpRecord(w, acks, "E\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scpRecord(w io.Writer, acks *bufio.Reader, record string) error {
+	if _, err := io.WriteString(w, record); err != nil {
+		return fmt.Errorf("failed to write scp record: %w", err)
+	}
+	return scpAck(acks)
+}
+
+func scpAck(acks *bufio.Reader) error {
+	code, err := acks.ReadByte()
+	if err != nil {
+		return fmt.Errorf("failed to read scp response: %w", err)
+	}
+	if code == 0 {
+		return nil
+	}
+	message, _ := acks.ReadString('\n')
+	return fmt.Errorf("remote scp error: %s", strings.TrimSpace(message))
+}
+
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(value string) string {
+	rest, ok := strings.CutPrefix(value, "~/")
+	if !ok {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	return filepath.Join(home, rest)
+}
+
+// shellQuote quotes value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,285 @@
+package porter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryDestination struct {
+	files map[string][]byte
+	modes map[string]fs.FileMode
+}
+
+func (d *memoryDestination) Put(_ context.Context, name string, r io.ReadSeeker, _ int64, mode fs.FileMode) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	d.files[name] = data
+	d.modes[name] = mode
+	return "mem://" + name, nil
+}
+
+func (d *memoryDestination) Close() error {
+	return nil
+}
+
+func TestExportArtifactToRegisteredDestination(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+
+	dest := &memoryDestination{files: map[string][]byte{}, modes: map[string]fs.FileMode{}}
+	RegisterDestination("mem", func(_ *Client, target *url.URL) (Destination, error) {
+		return dest, nil
+	})
+	t.Cleanup(func() {
+		delete(destinationBackends, "mem")
+	})
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	assert.True(t, IsRemoteDestination("mem://bucket/out"))
+	delivered, err := client.ExportArtifact(context.Background(), result, "mem://bucket/out", ExportOptions{})
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	require.Len(t, dest.files, 1)
+	for name, data := range dest.files {
+		assert.Equal(t, "mem://"+name, delivered[0])
+		assert.Equal(t, "tool binary", string(data))
+	}
+}
+
+func TestLocalFileDestination(t *testing.T) {
+	root := t.TempDir()
+	dest, err := newLocalDestination(nil, &url.URL{Scheme: "file", Path: root})
+	require.NoError(t, err)
+
+	location, err := dest.Put(context.Background(), "linux/amd64/tool", strings.NewReader("data"), 4, 0755)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "linux", "amd64", "tool"), location)
+
+	data, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.False(t, IsRemoteDestination(root))
+}
+
+func TestS3DestinationSignsUploads(t *testing.T) {
+	var gotPath, gotAuth, gotHash string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotHash = r.Header.Get("X-Amz-Content-Sha256")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(t, nil)
+	client.config.Export.S3 = S3Config{
+		Region:          "eu-central-1",
+		Endpoint:        server.URL,
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	}
+
+	dest, err := newS3Destination(client, &url.URL{Scheme: "s3", Host: "releases", Path: "/porter/1.0"})
+	require.NoError(t, err)
+
+	location, err := dest.Put(context.Background(), "linux/amd64/tool name", strings.NewReader("payload"), 7, 0755)
+	require.NoError(t, err)
+	assert.Equal(t, "s3://releases/porter/1.0/linux/amd64/tool name", location)
+	assert.Equal(t, "/releases/porter/1.0/linux/amd64/tool%20name", gotPath)
+	assert.Equal(t, "payload", string(gotBody))
+
+	sum := sha256.Sum256([]byte("payload"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), gotHash)
+	assert.True(t, strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), gotAuth)
+	assert.Contains(t, gotAuth, "/eu-central-1/s3/aws4_request")
+	assert.Contains(t, gotAuth, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date")
+}
+
+func TestS3DestinationRequiresCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	client := newTestClient(t, nil)
+	_, err := newS3Destination(client, &url.URL{Scheme: "s3", Host: "bucket"})
+	assert.ErrorContains(t, err, "no S3 credentials")
+}
+
+// fakeSCPSink implements the receiving side of the scp protocol, recording the
+// records it sees and acknowledging each one.
+func fakeSCPSink(t *testing.T, in io.Reader, out io.Writer) []string {
+	t.Helper()
+	reader := bufio.NewReader(in)
+	var records []string
+	_, _ = out.Write([]byte{0})
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return records
+		}
+		records = append(records, strings.TrimSuffix(line, "\n"))
+		_, _ = out.Write([]byte{0})
+		if strings.HasPrefix(line, "C") {
+			var mode, size int
+			var name string
+			_, err := fmt.Sscanf(line, "C%o %d %s", &mode, &size, &name)
+			require.NoError(t, err)
+			data := make([]byte, size+1)
+			_, err = io.ReadFull(reader, data)
+			require.NoError(t, err)
+			records = append(records, string(data[:size]))
+			_, _ = out.Write([]byte{0})
+		}
+	}
+}
+
+func TestSCPSendEmitsDirectoryRecords(t *testing.T) {
+	toSink, fromClient := io.Pipe()
+	fromSink, toClient := io.Pipe()
+
+	done := make(chan []string)
+	go func() {
+		done <- fakeSCPSink(t, toSink, toClient)
+	}()
+
+	err := scpSend(fromClient, bufio.NewReader(fromSink), "app/linux/tool", bytes.NewReader([]byte("binary")), 6, 0755)
+	require.NoError(t, err)
+	require.NoError(t, fromClient.Close())
+
+	assert.Equal(t, []string{
+		"D0755 0 app",
+		"D0755 0 linux",
+		"C0755 6 tool",
+		"binary",
+		"E",
+		"E",
+	}, <-done)
+}
+
+func TestExportConfigFromSettings(t *testing.T) {
+	cfg := buildConfigFromDS(&types.Config{
+		Plugins: types.PluginsConfig{
+			Settings: map[string]map[string]interface{}{
+				"porter": {
+					"ssh": map[string]interface{}{"user": "deploy", "insecure_ignore_host_key": true},
+					"s3":  map[string]interface{}{"region": "eu-west-1", "use_path_style": "true"},
+				},
+			},
+		},
+	})
+	assert.Equal(t, "deploy", cfg.Export.SSH.User)
+	assert.True(t, cfg.Export.SSH.InsecureIgnoreHostKey)
+	assert.Equal(t, "eu-west-1", cfg.Export.S3.Region)
+	assert.True(t, cfg.Export.S3.UsePathStyle)
+}
+
+func writeKubeconfig(t *testing.T, server string) string {
+	t.Helper()
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: %s
+users:
+- name: deployer
+  user:
+    token: sa-token
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: deployer
+`, server)
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+	return path
+}
+
+func TestKubernetesDestinationAppliesConfigMap(t *testing.T) {
+	var gotMethod, gotPath, gotQuery, gotType, gotAuth string
+	var gotObject map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotQuery = r.Method, r.URL.Path, r.URL.RawQuery
+		gotType, gotAuth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotObject)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(t, nil)
+	client.config.Export.Kubernetes = KubernetesConfig{Kubeconfig: writeKubeconfig(t, server.URL)}
+
+	target, ok := parseDestinationURL("k8s://apps/configmap/sidecar-bundle")
+	require.True(t, ok)
+	dest, err := newKubernetesDestination(client, target)
+	require.NoError(t, err)
+
+	location, err := dest.Put(context.Background(), "linux/amd64/agent", strings.NewReader("\x00binary"), 7, 0755)
+	require.NoError(t, err)
+	assert.Equal(t, "k8s://apps/configmap/sidecar-bundle#linux_amd64_agent", location)
+	require.NoError(t, dest.(Committer).Commit(context.Background()))
+
+	assert.Equal(t, http.MethodPatch, gotMethod)
+	assert.Equal(t, "/api/v1/namespaces/apps/configmaps/sidecar-bundle", gotPath)
+	assert.Equal(t, "fieldManager=porter&force=true", gotQuery)
+	assert.Equal(t, "application/apply-patch+yaml", gotType)
+	assert.Equal(t, "Bearer sa-token", gotAuth)
+	assert.Equal(t, "ConfigMap", gotObject["kind"])
+	binaryData, _ := gotObject["binaryData"].(map[string]interface{})
+	assert.Equal(t, "AGJpbmFyeQ==", binaryData["linux_amd64_agent"])
+}
+
+func TestKubernetesDestinationReportsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `{"kind":"Status","message":"secrets is forbidden"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient(t, nil)
+	client.config.Export.Kubernetes = KubernetesConfig{Kubeconfig: writeKubeconfig(t, server.URL)}
+
+	dest, err := newKubernetesDestination(client, &url.URL{Scheme: "k8s", Host: "apps", Path: "/secret/creds"})
+	require.NoError(t, err)
+	_, err = dest.Put(context.Background(), "token", strings.NewReader("x"), 1, 0600)
+	require.NoError(t, err)
+	assert.ErrorContains(t, dest.(Committer).Commit(context.Background()), "secrets is forbidden")
+}
+
+func TestKubernetesDestinationRejectsOversizedExports(t *testing.T) {
+	client := newTestClient(t, nil)
+	client.config.Export.Kubernetes = KubernetesConfig{Kubeconfig: writeKubeconfig(t, "https://127.0.0.1:6443")}
+
+	dest, err := newKubernetesDestination(client, &url.URL{Scheme: "k8s", Host: "apps", Path: "/configmap/big"})
+	require.NoError(t, err)
+	_, err = dest.Put(context.Background(), "blob", strings.NewReader(""), maxKubernetesObjectBytes+1, 0644)
+	assert.ErrorContains(t, err, "byte limit")
+
+	_, err = newKubernetesDestination(client, &url.URL{Scheme: "k8s", Host: "apps", Path: "/deployment/big"})
+	assert.ErrorContains(t, err, "unsupported kubernetes object kind")
+}
@@ -0,0 +1,362 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// Sources of the artifacts a diff compares.
+const (
+	DiffSourceCache    = "cache"
+	DiffSourceRegistry = "registry"
+)
+
+// ArtifactDiff reports how the right artifact differs from the left one.
+type ArtifactDiff struct {
+	Left  DiffSide `json:"left"`
+	Right DiffSide `json:"right"`
+	// Identical reports whether both sides resolve to the same digest.
+	Identical bool `json:"identical"`
+	// PlatformsAdded and PlatformsRemoved list the platforms only the right or
+	// only the left artifact covers.
+	PlatformsAdded   []string `json:"platforms_added,omitempty"`
+	PlatformsRemoved []string `json:"platforms_removed,omitempty"`
+	// Annotations lists the differing annotations of the root index or manifest.
+	Annotations []AnnotationChange `json:"annotations,omitempty"`
+	// Platforms details the platforms both artifacts cover with different manifests.
+	Platforms []PlatformDiff `json:"platforms,omitempty"`
+}
+
+// DiffSide identifies one of the compared artifacts.
+type DiffSide struct {
+	Reference string `json:"reference"`
+	Source    string `json:"source"`
+	Digest    string `json:"digest"`
+}
+
+// PlatformDiff reports the differences of one platform's manifests.
+type PlatformDiff struct {
+	// Platform is the platform of the manifests, or the manifest digest for the
+	// entries of an index that name no platform. It is empty when both sides
+	// are single manifests.
+	Platform      string             `json:"platform,omitempty"`
+	LeftDigest    string             `json:"left_digest"`
+	RightDigest   string             `json:"right_digest"`
+	ConfigChanged bool               `json:"config_changed,omitempty"`
+	LayersAdded   []LayerSummary     `json:"layers_added,omitempty"`
+	LayersRemoved []LayerSummary     `json:"layers_removed,omitempty"`
+	LayersChanged []LayerChange      `json:"layers_changed,omitempty"`
+	Annotations   []AnnotationChange `json:"annotations,omitempty"`
+}
+
+// LayerSummary describes a layer only one side has.
+type LayerSummary struct {
+	Name      string `json:"name"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+}
+
+// LayerChange describes a layer both sides have with different content.
+type LayerChange struct {
+	Name        string             `json:"name"`
+	LeftDigest  string             `json:"left_digest"`
+	RightDigest string             `json:"right_digest"`
+	LeftSize    int64              `json:"left_size"`
+	RightSize   int64              `json:"right_size"`
+	MediaType   string             `json:"media_type,omitempty"`
+	Annotations []AnnotationChange `json:"annotations,omitempty"`
+}
+
+// AnnotationChange is an annotation whose value differs. Left or Right is empty
+// when only the other side sets it.
+type AnnotationChange struct {
+	Key   string `json:"key"`
+	Left  string `json:"left,omitempty"`
+	Right string `json:"right,omitempty"`
+}
+
+// diffTarget is a resolved side of a diff.
+type diffTarget struct {
+	side    DiffSide
+	fetcher content.Fetcher
+	root    ocispec.Descriptor
+}
+
+// DiffArtifacts compares two artifacts, each either a reference resolved at its
+// registry or a cache ID or digest of a cached artifact, and reports the
+// platforms, layers and annotations that differ. Layers are matched by their
+// title annotation, or by position when they have none, so a layer whose content
+// changed is reported as changed rather than as removed and added. Only
+// manifests, indexes and configs are fetched; layer content is never downloaded.
+func (c *Client) DiffArtifacts(ctx context.Context, left, right string, insecure bool) (*ArtifactDiff, error) {
+	leftTarget, err := c.openDiffTarget(ctx, left, insecure)
+	if err != nil {
+		return nil, err
+	}
+	rightTarget, err := c.openDiffTarget(ctx, right, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ArtifactDiff{
+		Left:      leftTarget.side,
+		Right:     rightTarget.side,
+		Identical: leftTarget.root.Digest == rightTarget.root.Digest,
+	}
+	if diff.Identical {
+		return diff, nil
+	}
+
+	leftManifests, leftAnnotations, err := collectPlatformManifests(ctx, leftTarget)
+	if err != nil {
+		return nil, err
+	}
+	rightManifests, rightAnnotations, err := collectPlatformManifests(ctx, rightTarget)
+	if err != nil {
+		return nil, err
+	}
+	diff.Annotations = diffAnnotations(leftAnnotations, rightAnnotations)
+
+	for _, platform := range sortedKeys(leftManifests) {
+		if _, ok := rightManifests[platform]; !ok {
+			diff.PlatformsRemoved = append(diff.PlatformsRemoved, platform)
+		}
+	}
+	for _, platform := range sortedKeys(rightManifests) {
+		leftDesc, ok := leftManifests[platform]
+		if !ok {
+			diff.PlatformsAdded = append(diff.PlatformsAdded, platform)
+			continue
+		}
+		rightDesc := rightManifests[platform]
+		if leftDesc.Digest == rightDesc.Digest {
+			continue
+		}
+		platformDiff, err := diffManifests(ctx, leftTarget.fetcher, leftDesc, rightTarget.fetcher, rightDesc)
+		if err != nil {
+			return nil, err
+		}
+		platformDiff.Platform = platform
+		diff.Platforms = append(diff.Platforms, platformDiff)
+	}
+
+	c.logger.Debug("Artifacts compared", "left", reference.Redact(left), "right", reference.Redact(right),
+		"platforms_added", len(diff.PlatformsAdded), "platforms_removed", len(diff.PlatformsRemoved), "platforms_changed", len(diff.Platforms))
+	return diff, nil
+}
+
+// openDiffTarget resolves target in the cache when it is the ID or digest of a
+// cached artifact, and at its registry otherwise.
+func (c *Client) openDiffTarget(ctx context.Context, target string, insecure bool) (*diffTarget, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("artifact reference required")
+	}
+	if artifact := c.cachedDiffArtifact(target); artifact != nil {
+		root, err := cachedRootDescriptor(artifact)
+		if err != nil {
+			return nil, err
+		}
+		store, err := oci.NewFromFS(ctx, os.DirFS(artifact.LocalPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cached layout: %w", err)
+		}
+		return &diffTarget{
+			side:    DiffSide{Reference: artifact.Reference, Source: DiffSourceCache, Digest: root.Digest.String()},
+			fetcher: store,
+			root:    root,
+		}, nil
+	}
+
+	imgRef, err := c.parseReference(target, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, root, err := c.resolveRemote(ctx, imgRef, target, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return &diffTarget{
+		side:    DiffSide{Reference: target, Source: DiffSourceRegistry, Digest: root.Digest.String()},
+		fetcher: repo,
+		root:    root,
+	}, nil
+}
+
+// cachedDiffArtifact returns the cached artifact target names by ID or digest. A
+// reference, even one the cache holds, is resolved at its registry, so a diff
+// against a tag always sees what the tag points to now.
+func (c *Client) cachedDiffArtifact(target string) *ArtifactResult {
+	if reference.IsDigest(target) {
+		artifacts, err := c.ListCachedArtifacts()
+		if err != nil {
+			return nil
+		}
+		for _, artifact := range artifacts {
+			if artifact.Digest == target {
+				return artifact
+			}
+		}
+		return nil
+	}
+	if filepath.Base(target) != target {
+		return nil
+	}
+	artifact, err := c.loadArtifactMetadata(target)
+	if err != nil {
+		return nil
+	}
+	return artifact
+}
+
+// collectPlatformManifests returns the manifests of target keyed by platform,
+// with the annotations of its root. A single manifest is keyed by its platform,
+// which is empty unless the descriptor names one.
+func collectPlatformManifests(ctx context.Context, target *diffTarget) (map[string]ocispec.Descriptor, map[string]string, error) {
+	manifests := make(map[string]ocispec.Descriptor)
+	if !isIndexDescriptor(target.root) {
+		manifest, err := fetchManifest(ctx, target.fetcher, target.root)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifests[formatPlatform(target.root.Platform)] = target.root
+		return manifests, manifest.Annotations, nil
+	}
+
+	index, err := fetchIndex(ctx, target.fetcher, target.root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := collectIndexManifests(ctx, target.fetcher, index, manifests, make(map[string]struct{})); err != nil {
+		return nil, nil, err
+	}
+	return manifests, index.Annotations, nil
+}
+
+func collectIndexManifests(ctx context.Context, fetcher content.Fetcher, index ocispec.Index, manifests map[string]ocispec.Descriptor, seen map[string]struct{}) error {
+	for _, child := range index.Manifests {
+		if _, ok := seen[child.Digest.String()]; ok {
+			continue
+		}
+		seen[child.Digest.String()] = struct{}{}
+		if isIndexDescriptor(child) {
+			nested, err := fetchIndex(ctx, fetcher, child)
+			if err != nil {
+				return err
+			}
+			if err := collectIndexManifests(ctx, fetcher, nested, manifests, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		key := formatPlatform(child.Platform)
+		if key == "" {
+			key = child.Digest.String()
+		}
+		manifests[key] = child
+	}
+	return nil
+}
+
+// diffManifests compares the layers, config and annotations of two manifests.
+func diffManifests(ctx context.Context, leftFetcher content.Fetcher, leftDesc ocispec.Descriptor, rightFetcher content.Fetcher, rightDesc ocispec.Descriptor) (PlatformDiff, error) {
+	diff := PlatformDiff{LeftDigest: leftDesc.Digest.String(), RightDigest: rightDesc.Digest.String()}
+	leftManifest, err := fetchManifest(ctx, leftFetcher, leftDesc)
+	if err != nil {
+		return diff, err
+	}
+	rightManifest, err := fetchManifest(ctx, rightFetcher, rightDesc)
+	if err != nil {
+		return diff, err
+	}
+	diff.ConfigChanged = leftManifest.Config.Digest != rightManifest.Config.Digest
+	diff.Annotations = diffAnnotations(leftManifest.Annotations, rightManifest.Annotations)
+
+	leftLayers := layersByName(leftManifest.Layers)
+	rightLayers := layersByName(rightManifest.Layers)
+	for _, name := range sortedKeys(leftLayers) {
+		if _, ok := rightLayers[name]; !ok {
+			diff.LayersRemoved = append(diff.LayersRemoved, layerSummary(name, leftLayers[name]))
+		}
+	}
+	for _, name := range sortedKeys(rightLayers) {
+		rightLayer := rightLayers[name]
+		leftLayer, ok := leftLayers[name]
+		if !ok {
+			diff.LayersAdded = append(diff.LayersAdded, layerSummary(name, rightLayer))
+			continue
+		}
+		annotations := diffAnnotations(leftLayer.Annotations, rightLayer.Annotations)
+		if leftLayer.Digest == rightLayer.Digest && leftLayer.MediaType == rightLayer.MediaType && len(annotations) == 0 {
+			continue
+		}
+		change := LayerChange{
+			Name:        name,
+			LeftDigest:  leftLayer.Digest.String(),
+			RightDigest: rightLayer.Digest.String(),
+			LeftSize:    leftLayer.Size,
+			RightSize:   rightLayer.Size,
+			Annotations: annotations,
+		}
+		if leftLayer.MediaType != rightLayer.MediaType {
+			change.MediaType = rightLayer.MediaType
+		}
+		diff.LayersChanged = append(diff.LayersChanged, change)
+	}
+	return diff, nil
+}
+
+// layersByName keys layers by their title annotation, or by their position for
+// layers without one.
+func layersByName(layers []ocispec.Descriptor) map[string]ocispec.Descriptor {
+	named := make(map[string]ocispec.Descriptor, len(layers))
+	for i, layer := range layers {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		if name == "" {
+			name = fmt.Sprintf("layer %d", i)
+		}
+		named[name] = layer
+	}
+	return named
+}
+
+func layerSummary(name string, layer ocispec.Descriptor) LayerSummary {
+	return LayerSummary{Name: name, Digest: layer.Digest.String(), MediaType: layer.MediaType, Size: layer.Size}
+}
+
+// diffAnnotations lists the keys whose values differ between left and right.
+func diffAnnotations(left, right map[string]string) []AnnotationChange {
+	keys := make(map[string]struct{}, len(left)+len(right))
+	for key := range left {
+		keys[key] = struct{}{}
+	}
+	for key := range right {
+		keys[key] = struct{}{}
+	}
+	var changes []AnnotationChange
+	for _, key := range sortedKeys(keys) {
+		if left[key] != right[key] {
+			changes = append(changes, AnnotationChange{Key: key, Left: left[key], Right: right[key]})
+		}
+	}
+	return changes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishRelease tags an index of team/app with a manifest per architecture,
+// each holding one layer per title.
+func publishRelease(registry *testRegistry, tag string, annotations map[string]string, layers map[string]map[string]string) ocispec.Descriptor {
+	config := registry.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	var manifests []ocispec.Descriptor
+	for _, arch := range sortedKeys(layers) {
+		var descs []ocispec.Descriptor
+		for _, title := range sortedKeys(layers[arch]) {
+			layer := registry.AddBlob("application/octet-stream", []byte(layers[arch][title]))
+			layer.Annotations = map[string]string{ocispec.AnnotationTitle: title}
+			descs = append(descs, layer)
+		}
+		desc := registry.AddManifest("team/app", "", ocispec.MediaTypeImageManifest, ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    descs,
+		})
+		desc.Platform = &ocispec.Platform{OS: "linux", Architecture: arch}
+		manifests = append(manifests, desc)
+	}
+	return registry.AddManifest("team/app", tag, ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageIndex,
+		Manifests:   manifests,
+		Annotations: annotations,
+	})
+}
+
+func TestDiffArtifactsReportsLayersAnnotationsAndPlatforms(t *testing.T) {
+	registry := newTestRegistry(t)
+	publishRelease(registry, "1.0.0", map[string]string{ocispec.AnnotationVersion: "1.0.0"}, map[string]map[string]string{
+		"amd64": {"app": "amd64 v1", "LICENSE": "MIT"},
+		"arm":   {"app": "arm v1"},
+	})
+	publishRelease(registry, "1.1.0", map[string]string{ocispec.AnnotationVersion: "1.1.0"}, map[string]map[string]string{
+		"amd64": {"app": "amd64 v1.1", "README": "docs"},
+		"arm64": {"app": "arm64 v1.1"},
+	})
+
+	client := newTestClient(t, nil)
+	diff, err := client.DiffArtifacts(context.Background(), registry.Host()+"/team/app:1.0.0", registry.Host()+"/team/app:1.1.0", true)
+	require.NoError(t, err)
+
+	assert.False(t, diff.Identical)
+	assert.Equal(t, DiffSourceRegistry, diff.Left.Source)
+	assert.Equal(t, []string{"linux/arm64"}, diff.PlatformsAdded)
+	assert.Equal(t, []string{"linux/arm"}, diff.PlatformsRemoved)
+	assert.Equal(t, []AnnotationChange{{Key: ocispec.AnnotationVersion, Left: "1.0.0", Right: "1.1.0"}}, diff.Annotations)
+
+	require.Len(t, diff.Platforms, 1)
+	amd64 := diff.Platforms[0]
+	assert.Equal(t, "linux/amd64", amd64.Platform)
+	assert.False(t, amd64.ConfigChanged)
+	require.Len(t, amd64.LayersAdded, 1)
+	assert.Equal(t, "README", amd64.LayersAdded[0].Name)
+	require.Len(t, amd64.LayersRemoved, 1)
+	assert.Equal(t, "LICENSE", amd64.LayersRemoved[0].Name)
+	require.Len(t, amd64.LayersChanged, 1)
+	assert.Equal(t, "app", amd64.LayersChanged[0].Name)
+	assert.Equal(t, int64(len("amd64 v1")), amd64.LayersChanged[0].LeftSize)
+	assert.Equal(t, int64(len("amd64 v1.1")), amd64.LayersChanged[0].RightSize)
+
+	for _, req := range registry.Requests() {
+		assert.NotContains(t, req, "/blobs/", "diff must not download blobs")
+	}
+}
+
+func TestDiffArtifactsComparesCachedArtifact(t *testing.T) {
+	registry := newTestRegistry(t)
+	publishRelease(registry, "1.0.0", nil, map[string]map[string]string{"amd64": {"app": "amd64 v1"}})
+	publishRelease(registry, "latest", nil, map[string]map[string]string{"amd64": {"app": "amd64 v2"}})
+
+	client := newTestClient(t, nil)
+	cached, err := client.PullArtifact(context.Background(), registry.Host()+"/team/app:1.0.0", true)
+	require.NoError(t, err)
+
+	diff, err := client.DiffArtifacts(context.Background(), cached.ID, registry.Host()+"/team/app:latest", true)
+	require.NoError(t, err)
+	assert.Equal(t, DiffSourceCache, diff.Left.Source)
+	assert.Equal(t, DiffSourceRegistry, diff.Right.Source)
+	assert.Equal(t, cached.Digest, diff.Left.Digest)
+	require.Len(t, diff.Platforms, 1)
+	require.Len(t, diff.Platforms[0].LayersChanged, 1)
+	assert.Equal(t, "app", diff.Platforms[0].LayersChanged[0].Name)
+
+	diff, err = client.DiffArtifacts(context.Background(), cached.Digest, registry.Host()+"/team/app:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, DiffSourceCache, diff.Left.Source)
+	assert.True(t, diff.Identical)
+}
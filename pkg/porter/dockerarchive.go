@@ -0,0 +1,377 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// DockerArchiveExt is the extension of the tarballs written by exports with
+// ExportOptions.DockerArchive into a directory.
+const DockerArchiveExt = ".docker.tar"
+
+const (
+	// dockerArchiveManifest lists the images of a docker save tarball.
+	dockerArchiveManifest = "manifest.json"
+	dockerConfigMediaType = "application/vnd.docker.container.image.v1+json"
+)
+
+// dockerArchiveImage is an entry of the manifest.json of a docker save tarball.
+type dockerArchiveImage struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// exportDockerArchive writes the image of the selected platform as a tarball that
+// docker load accepts. The tarball is also an oci-layout, as docker save writes
+// since Docker 25, so OCI tooling can read it too.
+func (c *Client) exportDockerArchive(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
+	if opts.Versioned || opts.OutputTemplate != "" {
+		return nil, fmt.Errorf("a Docker archive export cannot be versioned or templated")
+	}
+	store, err := oci.New(result.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI store: %w", err)
+	}
+	root, err := store.Resolve(ctx, result.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve artifact descriptor %s: %w", result.Digest, err)
+	}
+	manifests, err := c.selectManifests(ctx, store, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) != 1 {
+		return nil, fmt.Errorf("%d platforms match; docker load takes one, select it with --platform", len(manifests))
+	}
+	manifestDesc := manifests[0].Descriptor
+	manifest, err := fetchManifest(ctx, store, manifestDesc)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config.MediaType != ocispec.MediaTypeImageConfig && manifest.Config.MediaType != dockerConfigMediaType {
+		return nil, fmt.Errorf("artifact %s is not a container image: its config is %s", result.Reference, manifest.Config.MediaType)
+	}
+	// Pushed artifacts carry an image config with their platform, but also an
+	// artifact type that images do not.
+	if manifest.ArtifactType != "" {
+		return nil, fmt.Errorf("artifact %s is not a container image: its artifact type is %s", result.Reference, manifest.ArtifactType)
+	}
+	blobs, err := artifactBlobs(ctx, store, manifestDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	image := dockerArchiveImage{Config: archiveBlobName(manifest.Config.Digest), RepoTags: []string{}}
+	if tag := dockerRepoTag(result.Reference); tag != "" {
+		image.RepoTags = append(image.RepoTags, tag)
+	}
+	for _, layer := range manifest.Layers {
+		image.Layers = append(image.Layers, archiveBlobName(layer.Digest))
+	}
+	index, err := json.Marshal([]dockerArchiveImage{image})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", dockerArchiveManifest, err)
+	}
+
+	target := archiveTarget(result.Reference, destination, DockerArchiveExt)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	if err := c.config.Extract.checkDestinationOwner(filepath.Dir(target)); err != nil {
+		return nil, err
+	}
+
+	writer := exportWriter{logger: c.logger, policy: c.config.Extract, overwrite: opts.Overwrite, verified: opts.verified}
+	if ok, err := writer.claim(target); err != nil || !ok {
+		return nil, err
+	}
+	outFile, err := writer.create(target, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	err = writeOCIArchive(ctx, outFile, store, manifestDesc, blobs, ociRefName(result.Reference), archiveFile{Name: dockerArchiveManifest, Data: index})
+	if err != nil {
+		outFile.Abort()
+		return nil, err
+	}
+	if err := outFile.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write Docker archive: %w", err)
+	}
+
+	c.logger.Info("Exported Docker archive", "digest", manifestDesc.Digest, "path", target, "layers", len(manifest.Layers))
+	return []string{target}, nil
+}
+
+// dockerRepoTag returns the repository:tag docker load tags the image of ref
+// with; empty for digest references.
+func dockerRepoTag(ref string) string {
+	parsed, err := reference.Parse(ref)
+	if err != nil || parsed.IsDigest() || !parsed.IsTag() {
+		return ""
+	}
+	return parsed.Registry + "/" + parsed.Repository + ":" + parsed.Tag
+}
+
+// dockerArchive is a docker save tarball read as a content store. Its blobs are
+// read from the tarball on demand; only manifest.json, the configs and the image
+// manifest built for it are held in memory.
+type dockerArchive struct {
+	path   string
+	images []dockerArchiveImage
+	// entries holds the digest, size and media type of every file of the tarball.
+	entries map[string]ocispec.Descriptor
+	// names maps blob digests back to the file holding them.
+	names map[digest.Digest]string
+	// inline holds the blobs built rather than read from the tarball.
+	inline map[digest.Digest][]byte
+}
+
+// openDockerArchive reads the docker save tarball at file, digesting each of its
+// files. It reports false when file is not one: a tarball without manifest.json,
+// or an oci-layout, which PushArtifact pushes verbatim instead.
+func openDockerArchive(file string) (*dockerArchive, bool, error) {
+	if info, err := os.Stat(file); err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(file), ".tar") {
+		return nil, false, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// Look for manifest.json before digesting anything; skipping entries seeks.
+	found, isLayout := false, false
+	tarReader := tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, nil
+		}
+		switch path.Clean(header.Name) {
+		case dockerArchiveManifest:
+			found = true
+		case ocispec.ImageLayoutFile:
+			isLayout = true
+		}
+	}
+	if !found || isLayout {
+		return nil, false, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	archive := &dockerArchive{
+		path:    file,
+		entries: make(map[string]ocispec.Descriptor),
+		names:   make(map[digest.Digest]string),
+		inline:  make(map[digest.Digest][]byte),
+	}
+	tarReader = tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if name == dockerArchiveManifest {
+			if err := json.NewDecoder(tarReader).Decode(&archive.images); err != nil {
+				return nil, true, fmt.Errorf("failed to read %s: %w", dockerArchiveManifest, err)
+			}
+			continue
+		}
+		digester := digest.Canonical.Digester()
+		sniffer := &sniffWriter{}
+		size, err := io.Copy(io.MultiWriter(digester.Hash(), sniffer), tarReader)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to read %s in %s: %w", header.Name, file, err)
+		}
+		archive.entries[name] = ocispec.Descriptor{MediaType: sniffer.layerMediaType(), Digest: digester.Digest(), Size: size}
+		archive.names[digester.Digest()] = name
+	}
+	if len(archive.images) == 0 {
+		return nil, true, fmt.Errorf("%s lists no images", dockerArchiveManifest)
+	}
+	return archive, true, nil
+}
+
+// image selects the image to push to ref: the only one, or the one tagged with
+// the tag of ref.
+func (a *dockerArchive) image(ref reference.Reference) (dockerArchiveImage, error) {
+	if len(a.images) == 1 {
+		return a.images[0], nil
+	}
+	var tags []string
+	for _, image := range a.images {
+		for _, tag := range image.RepoTags {
+			if ref.IsTag() && strings.HasSuffix(tag, ":"+ref.Tag) {
+				return image, nil
+			}
+			tags = append(tags, tag)
+		}
+	}
+	return dockerArchiveImage{}, fmt.Errorf("%s lists %d images and none is tagged %q; push one of %s by tag",
+		dockerArchiveManifest, len(a.images), ref.Identifier(), strings.Join(tags, ", "))
+}
+
+// manifest builds the OCI image manifest of image. Layers keep their bytes, so
+// the config, and with it the image ID, is unchanged.
+func (a *dockerArchive) manifest(image dockerArchiveImage) (ocispec.Descriptor, error) {
+	config, ok := a.entries[path.Clean(image.Config)]
+	if !ok {
+		return ocispec.Descriptor{}, fmt.Errorf("image config %s is missing from the archive", image.Config)
+	}
+	config.MediaType = ocispec.MediaTypeImageConfig
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    make([]ocispec.Descriptor, 0, len(image.Layers)),
+	}
+	for _, name := range image.Layers {
+		layer, ok := a.entries[path.Clean(name)]
+		if !ok {
+			return ocispec.Descriptor{}, fmt.Errorf("layer %s is missing from the archive", name)
+		}
+		manifest.Layers = append(manifest.Layers, layer)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode image manifest: %w", err)
+	}
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, data)
+	a.inline[desc.Digest] = data
+	return desc, nil
+}
+
+// Exists implements content.ReadOnlyStorage.
+func (a *dockerArchive) Exists(_ context.Context, target ocispec.Descriptor) (bool, error) {
+	if _, ok := a.inline[target.Digest]; ok {
+		return true, nil
+	}
+	_, ok := a.names[target.Digest]
+	return ok, nil
+}
+
+// Fetch implements content.ReadOnlyStorage, reading blobs from the tarball.
+func (a *dockerArchive) Fetch(_ context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	if data, ok := a.inline[target.Digest]; ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	name, ok := a.names[target.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", target.Digest, errdef.ErrNotFound)
+	}
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	tarReader := tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to find %s in %s: %w", name, a.path, err)
+		}
+		if header.Typeflag == tar.TypeReg && path.Clean(header.Name) == name {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tarReader, f}, nil
+		}
+	}
+}
+
+// pushDockerArchive pushes the image of a docker save tarball that ref selects
+// as an OCI image.
+func (c *Client) pushDockerArchive(ctx context.Context, source string, archive *dockerArchive, ref string, opts PushOptions) (*ArtifactResult, error) {
+	if opts.Sign.Enabled || len(opts.SBOMs) > 0 || opts.Compression != "" {
+		return nil, fmt.Errorf("a Docker archive is pushed as is and cannot be combined with --sign, --sbom or --compression")
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	image, err := archive.image(parsed)
+	if err != nil {
+		return nil, err
+	}
+	root, err := archive.manifest(image)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Pushing Docker archive", "path", source, "digest", root.Digest, "destination", reference.Redact(ref))
+	if err := c.pushGraph(ctx, archive, root, ref, opts); err != nil {
+		return nil, fmt.Errorf("failed to push Docker archive: %w", err)
+	}
+	c.logger.Info("Docker archive pushed successfully", "reference", reference.Redact(ref), "digest", root.Digest.String())
+
+	metadata := map[string]string{
+		"pushed.reference": ref,
+		"source.archive":   source,
+	}
+	recordPushTags(metadata, opts.Tags)
+	if len(image.RepoTags) > 0 {
+		metadata["source.tags"] = strings.Join(image.RepoTags, ",")
+	}
+	return &ArtifactResult{
+		ID:        root.Digest.Encoded()[:16],
+		Reference: ref,
+		Digest:    root.Digest.String(),
+		Size:      root.Size,
+		Metadata:  metadata,
+	}, nil
+}
+
+// sniffWriter keeps the first bytes written to it, to tell compressed layers
+// from plain tarballs.
+type sniffWriter struct {
+	head []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if missing := 4 - len(w.head); missing > 0 {
+		w.head = append(w.head, p[:min(missing, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// layerMediaType returns the OCI layer media type of the sniffed content.
+func (w *sniffWriter) layerMediaType() string {
+	switch {
+	case bytes.HasPrefix(w.head, []byte{0x1f, 0x8b}):
+		return ocispec.MediaTypeImageLayerGzip
+	case bytes.HasPrefix(w.head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ocispec.MediaTypeImageLayerZstd
+	}
+	return ocispec.MediaTypeImageLayer
+}
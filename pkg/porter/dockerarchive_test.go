@@ -0,0 +1,107 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDockerSave writes a tarball in the pre-Docker 25 docker save format.
+func writeDockerSave(t *testing.T, path string, config []byte, layers [][]byte, tags []string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	image := dockerArchiveImage{Config: digest.FromBytes(config).Encoded() + ".json", RepoTags: tags}
+	add(image.Config, config)
+	for i, layer := range layers {
+		name := string(rune('a'+i)) + "/layer.tar"
+		add(name, layer)
+		image.Layers = append(image.Layers, name)
+	}
+	index, err := json.Marshal([]dockerArchiveImage{image})
+	require.NoError(t, err)
+	add(dockerArchiveManifest, index)
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestDockerArchiveRoundTrip(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer := tarGz(t, map[string]string{"bin/tool": "tool v1"})
+	source := filepath.Join(t.TempDir(), "image.tar")
+	writeDockerSave(t, source, config, [][]byte{layer}, []string{"acme/service:1.0"})
+
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/acme/service:1.0"
+	pushed, err := client.PushArtifact(context.Background(), source, ref, PushOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, source, pushed.Metadata["source.archive"])
+
+	pulled, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, pushed.Digest, pulled.Digest)
+
+	outDir := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), pulled, outDir, ExportOptions{DockerArchive: true})
+	require.NoError(t, err)
+	archivePath := filepath.Join(outDir, "service"+DockerArchiveExt)
+	require.Equal(t, []string{archivePath}, exported)
+
+	// The export is loadable: manifest.json names the config and layers, whose
+	// bytes are the ones pushed.
+	files := readTarFiles(t, archivePath)
+	var images []dockerArchiveImage
+	require.NoError(t, json.Unmarshal(files[dockerArchiveManifest], &images))
+	require.Len(t, images, 1)
+	assert.Equal(t, []string{registry.Host() + "/acme/service:1.0"}, images[0].RepoTags)
+	assert.Equal(t, config, files[images[0].Config])
+	require.Len(t, images[0].Layers, 1)
+	assert.Equal(t, layer, files[images[0].Layers[0]])
+	assert.Contains(t, files, ocispec.ImageLayoutFile)
+}
+
+func TestDockerArchiveExportRejectsNonImages(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	client := newTestClient(t, nil)
+	pulled, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	_, err = client.ExportArtifact(context.Background(), pulled, t.TempDir(), ExportOptions{DockerArchive: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a container image")
+}
+
+func readTarFiles(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(reader)
+		require.NoError(t, err)
+		files[header.Name] = buf.Bytes()
+	}
+	return files
+}
@@ -0,0 +1,115 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// PlanExport reports the files ExportArtifact would write for the cached result
+// into destination, with their sizes and digests, without touching the
+// destination. Unlike an audit it reads the cached layers, so archive layers are
+// listed entry by entry. Whatever would make the export fail, such as a platform
+// the artifact lacks, fails the plan too.
+func (c *Client) PlanExport(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]PlannedChange, error) {
+	if destination == "" {
+		return nil, fmt.Errorf("destination required")
+	}
+	if err := validateOverwrite(opts.Overwrite); err != nil {
+		return nil, err
+	}
+	remote := IsRemoteDestination(destination)
+	if !remote && !opts.OCIArchive && !opts.DockerArchive {
+		if err := c.checkHostCapabilities(result.Reference, result.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := oci.New(result.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI store: %w", err)
+	}
+	root, err := store.Resolve(ctx, result.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve artifact descriptor %s: %w", result.Digest, err)
+	}
+
+	record := NewAuditRecord("pull", result.Reference)
+	if opts.OCIArchive {
+		planArchive(record, result.Reference, destination, OCIArchiveExt)
+		return plannedChanges(record)
+	}
+	if opts.DockerArchive {
+		planArchive(record, result.Reference, destination, DockerArchiveExt)
+		return plannedChanges(record)
+	}
+	local := destination
+	var link *PlannedChange
+	switch {
+	case opts.Versioned:
+		dir, err := VersionedDestination(result, destination, opts)
+		if err != nil {
+			return nil, err
+		}
+		versionsDir, version := filepath.Split(dir)
+		link = &PlannedChange{Action: ChangeLink, Path: filepath.Join(versionsDir, CurrentVersionLink), Target: version}
+		local = dir
+	case remote:
+		// Remote destinations receive the files of a fresh staging directory.
+		target, _ := parseDestinationURL(destination)
+		stage, err := os.MkdirTemp("", "porter-plan-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		defer func() {
+			_ = os.RemoveAll(stage)
+		}()
+		local = stage
+		if destinationLooksLikeFile(target.Path) {
+			local = filepath.Join(stage, path.Base(target.Path))
+		}
+		if err := c.planExport(ctx, record, store, root, local, opts, false, true); err != nil {
+			return nil, err
+		}
+		for i, change := range record.Changes {
+			record.Changes[i].Action = ChangeUpload
+			record.Changes[i].Path = destination
+			if rel, err := filepath.Rel(stage, change.Path); err == nil && local == stage {
+				record.Changes[i].Path = strings.TrimSuffix(destination, "/") + "/" + filepath.ToSlash(rel)
+			}
+		}
+		return plannedChanges(record)
+	default:
+		if target, ok := parseDestinationURL(destination); ok {
+			local = target.Path
+		}
+	}
+
+	if err := c.planExport(ctx, record, store, root, local, opts, link != nil, true); err != nil {
+		return nil, err
+	}
+	if link != nil {
+		record.Plan(*link)
+	}
+	return plannedChanges(record)
+}
+
+// plannedChanges returns the changes of record, or an error naming the checks that
+// would refuse them.
+func plannedChanges(record *AuditRecord) ([]PlannedChange, error) {
+	if record.Allowed {
+		return record.Changes, nil
+	}
+	var failed []string
+	for _, check := range record.Checks {
+		if check.Result == AuditFail {
+			failed = append(failed, check.Detail)
+		}
+	}
+	return nil, fmt.Errorf("export would fail: %s", strings.Join(failed, "; "))
+}
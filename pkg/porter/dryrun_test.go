@@ -0,0 +1,64 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanExportListsFilesWithoutWriting(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	planned, err := client.PlanExport(context.Background(), result, outDir, ExportOptions{AllPlatforms: true})
+	require.NoError(t, err)
+	require.Len(t, planned, 2)
+	data := []byte("tool for linux/arm64")
+	assert.Equal(t, PlannedChange{
+		Action: ChangeCreate,
+		Path:   filepath.Join(outDir, "linux", "arm64", "tool"),
+		Digest: digest.FromBytes(data).String(),
+		Size:   int64(len(data)),
+	}, planned[1])
+
+	_, err = os.Stat(outDir)
+	assert.True(t, os.IsNotExist(err), "a dry run must not create the destination")
+
+	_, err = client.PlanExport(context.Background(), result, outDir, ExportOptions{Platforms: []ocispec.Platform{{OS: "windows", Architecture: "amd64"}}})
+	require.Error(t, err)
+}
+
+func TestPlanExportListsArchiveEntries(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/bundle", "1.0.0", "application/vnd.oci.image.layer.v1.tar+gzip",
+		tarGz(t, map[string]string{"bin/tool": "tool v1", "README": "readme"}), nil)
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/bundle:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outDir, "README"), []byte("local"), 0o644))
+
+	planned, err := client.PlanExport(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	require.Len(t, planned, 2)
+	assert.Equal(t, filepath.Join(outDir, "bin", "tool"), planned[0].Path)
+	assert.Equal(t, ChangeCreate, planned[0].Action)
+	assert.Equal(t, digest.FromString("tool v1").String(), planned[0].Digest)
+	assert.Equal(t, ChangeOverwrite, planned[1].Action)
+
+	planned, err = client.PlanExport(context.Background(), result, "s3://bucket/releases", ExportOptions{})
+	require.NoError(t, err)
+	require.Len(t, planned, 2)
+	assert.Equal(t, PlannedChange{Action: ChangeUpload, Path: "s3://bucket/releases/bin/tool", Digest: digest.FromString("tool v1").String(), Size: 7}, planned[0])
+}
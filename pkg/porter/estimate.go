@@ -0,0 +1,154 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// Blob kinds reported by transfer estimates.
+const (
+	BlobKindIndex    = "index"
+	BlobKindManifest = "manifest"
+	BlobKindConfig   = "config"
+	BlobKindLayer    = "layer"
+)
+
+// BlobEstimate describes a single blob that a pull would need.
+type BlobEstimate struct {
+	Kind      string `json:"kind"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+	Platform  string `json:"platform,omitempty"`
+	Cached    bool   `json:"cached"`
+}
+
+// TransferEstimate reports the bytes a pull would transfer given current cache contents.
+type TransferEstimate struct {
+	Reference     string         `json:"reference"`
+	Digest        string         `json:"digest"`
+	Blobs         []BlobEstimate `json:"blobs"`
+	TotalBytes    int64          `json:"total_bytes"`
+	CachedBytes   int64          `json:"cached_bytes"`
+	DownloadBytes int64          `json:"download_bytes"`
+	TotalBlobs    int            `json:"total_blobs"`
+	CachedBlobs   int            `json:"cached_blobs"`
+}
+
+func (e *TransferEstimate) add(blob BlobEstimate) {
+	e.Blobs = append(e.Blobs, blob)
+	e.TotalBlobs++
+	e.TotalBytes += blob.Size
+	if blob.Cached {
+		e.CachedBlobs++
+		e.CachedBytes += blob.Size
+	} else {
+		e.DownloadBytes += blob.Size
+	}
+}
+
+// EstimateTransfer resolves ref and its manifests without downloading layers and reports,
+// per blob, whether the content is already cached or would have to be downloaded.
+// Every platform of an index is counted, as a pull copies them all.
+func (c *Client) EstimateTransfer(ctx context.Context, ref string, insecure bool) (*TransferEstimate, error) {
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	repo, root, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &TransferEstimate{
+		Reference: ref,
+		Digest:    root.Digest.String(),
+	}
+	if err := c.estimateNode(ctx, repo, root, nil, estimate, make(map[string]struct{})); err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("Transfer estimate computed", "ref", ref, "download_bytes", estimate.DownloadBytes, "cached_bytes", estimate.CachedBytes)
+	return estimate, nil
+}
+
+func (c *Client) estimateNode(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, platform *ocispec.Platform, estimate *TransferEstimate, seen map[string]struct{}) error {
+	key := desc.Digest.String()
+	if _, ok := seen[key]; ok {
+		return nil
+	}
+	seen[key] = struct{}{}
+
+	if desc.Platform != nil {
+		platform = desc.Platform
+	}
+
+	if isIndexDescriptor(desc) {
+		estimate.add(c.blobEstimate(BlobKindIndex, desc, nil))
+
+		index, err := fetchIndex(ctx, fetcher, desc)
+		if err != nil {
+			return err
+		}
+		for _, child := range index.Manifests {
+			if err := c.estimateNode(ctx, fetcher, child, platform, estimate, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	estimate.add(c.blobEstimate(BlobKindManifest, desc, platform))
+
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return err
+	}
+
+	if manifest.Config.Digest != "" {
+		if _, ok := seen[manifest.Config.Digest.String()]; !ok {
+			seen[manifest.Config.Digest.String()] = struct{}{}
+			estimate.add(c.blobEstimate(BlobKindConfig, manifest.Config, platform))
+		}
+	}
+	for _, layer := range manifest.Layers {
+		if _, ok := seen[layer.Digest.String()]; ok {
+			continue
+		}
+		seen[layer.Digest.String()] = struct{}{}
+		estimate.add(c.blobEstimate(BlobKindLayer, layer, platform))
+	}
+	return nil
+}
+
+// blobEstimate reports a blob as cached only when a pull would reuse it; manifests
+// and indexes are always fetched from the registry.
+func (c *Client) blobEstimate(kind string, desc ocispec.Descriptor, platform *ocispec.Platform) BlobEstimate {
+	cached := false
+	if kind == BlobKindConfig || kind == BlobKindLayer {
+		_, cached = c.findCachedBlob(desc.Digest)
+	}
+	return BlobEstimate{
+		Kind:      kind,
+		Digest:    desc.Digest.String(),
+		MediaType: desc.MediaType,
+		Size:      desc.Size,
+		Platform:  formatPlatform(platform),
+		Cached:    cached,
+	}
+}
+
+func formatPlatform(platform *ocispec.Platform) string {
+	if platform == nil || (platform.OS == "" && platform.Architecture == "") {
+		return ""
+	}
+	value := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		value += "/" + platform.Variant
+	}
+	return value
+}
@@ -0,0 +1,88 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedCachedBlob(t *testing.T, cacheDir string, desc ocispec.Descriptor, data []byte) {
+	t.Helper()
+	path := blobPath(filepath.Join(cacheDir, "0123456789abcdef"), desc.Digest)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func publishMultiArch(t *testing.T, registry *testRegistry) (amd64Layer, arm64Layer ocispec.Descriptor) {
+	t.Helper()
+	config := registry.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	amd64Layer = registry.AddBlob("application/octet-stream", []byte("amd64 binary contents"))
+	arm64Layer = registry.AddBlob("application/octet-stream", []byte("arm64 binary"))
+
+	manifest := func(layer ocispec.Descriptor) ocispec.Manifest {
+		return ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{layer},
+		}
+	}
+	amd64 := registry.AddManifest("team/app", "", ocispec.MediaTypeImageManifest, manifest(amd64Layer))
+	amd64.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := registry.AddManifest("team/app", "", ocispec.MediaTypeImageManifest, manifest(arm64Layer))
+	arm64.Platform = &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+
+	registry.AddManifest("team/app", "1.0.0", ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{amd64, arm64},
+	})
+	return amd64Layer, arm64Layer
+}
+
+func TestEstimateTransferReportsCachedBlobs(t *testing.T) {
+	registry := newTestRegistry(t)
+	amd64Layer, _ := publishMultiArch(t, registry)
+
+	client := newTestClient(t, nil)
+	seedCachedBlob(t, client.config.CacheDir, amd64Layer, []byte("amd64 binary contents"))
+
+	estimate, err := client.EstimateTransfer(context.Background(), registry.Host()+"/team/app:1.0.0", true)
+	require.NoError(t, err)
+
+	// index + 2 manifests + shared config + 2 layers
+	assert.Equal(t, 6, estimate.TotalBlobs)
+	assert.Equal(t, 1, estimate.CachedBlobs)
+	assert.Equal(t, amd64Layer.Size, estimate.CachedBytes)
+	assert.Equal(t, estimate.TotalBytes-amd64Layer.Size, estimate.DownloadBytes)
+
+	for _, req := range registry.Requests() {
+		assert.NotContains(t, req, "/blobs/", "estimate must not download blobs")
+	}
+}
+
+func TestEstimateTransferCountsEveryPlatform(t *testing.T) {
+	registry := newTestRegistry(t)
+	amd64Layer, arm64Layer := publishMultiArch(t, registry)
+
+	client := newTestClient(t, nil)
+	estimate, err := client.EstimateTransfer(context.Background(), registry.Host()+"/team/app:1.0.0", true)
+	require.NoError(t, err)
+
+	layers := make(map[string]string)
+	for _, blob := range estimate.Blobs {
+		if blob.Kind == BlobKindLayer {
+			layers[blob.Digest] = blob.Platform
+		}
+	}
+	assert.Equal(t, map[string]string{
+		amd64Layer.Digest.String(): "linux/amd64",
+		arm64Layer.Digest.String(): "linux/arm64",
+	}, layers)
+}
@@ -0,0 +1,95 @@
+package porter
+
+import (
+	"context"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+)
+
+// Events published once an artifact has been pulled, exported or pushed.
+const (
+	EventArtifactPulled   = "artifact.pulled"
+	EventArtifactExported = "artifact.exported"
+	EventArtifactPushed   = "artifact.pushed"
+)
+
+// eventSubscribersFromSettings reads plugins.settings.porter.events.subscribers.
+func eventSubscribersFromSettings(settings map[string]interface{}) []string {
+	events, _ := settings["events"].(map[string]interface{})
+	return settingStrings(events, "subscribers")
+}
+
+// Event describes an artifact that entered or left the runner. Reference and
+// Destination are redacted.
+type Event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	Reference   string    `json:"reference"`
+	Digest      string    `json:"digest"`
+	Size        int64     `json:"size"`
+	Cached      bool      `json:"cached,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Files       int       `json:"files,omitempty"`
+	JobID       string    `json:"job_id,omitempty"`
+}
+
+// Data returns the event as the data of a DS event bus event.
+func (e Event) Data() map[string]interface{} {
+	data := map[string]interface{}{
+		"ref":       e.Reference,
+		"digest":    e.Digest,
+		"size":      e.Size,
+		"timestamp": e.Time.Unix(),
+	}
+	if e.Cached {
+		data["cached"] = true
+	}
+	if e.Destination != "" {
+		data["destination"] = e.Destination
+		data["files"] = e.Files
+	}
+	if e.JobID != "" {
+		data["job_id"] = e.JobID
+	}
+	return data
+}
+
+// EventPublisher receives the events of a client. Hosts set Config.Events to
+// forward them to DS.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// EventPublisherFunc adapts a function to an EventPublisher.
+type EventPublisherFunc func(ctx context.Context, event Event) error
+
+// Publish calls f.
+func (f EventPublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// publish sends the event of eventType for result to Config.Events; exports pass
+// their destination and the files they wrote. Failures are only logged: the
+// artifact has been handled by the time it is published.
+func (c *Client) publish(ctx context.Context, eventType string, result *ArtifactResult, destination string, files int) {
+	if c.config.Events == nil || result == nil {
+		return
+	}
+	event := Event{
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+		Reference:   reference.Redact(result.Reference),
+		Digest:      result.Digest,
+		Size:        result.Size,
+		Destination: c.redactor.Redact(destination),
+		Files:       files,
+		JobID:       c.config.JobID,
+	}
+	if eventType == EventArtifactPulled {
+		event.Cached = result.Cached
+	}
+	if err := c.config.Events.Publish(ctx, event); err != nil {
+		c.logger.Warn("Failed to publish event", "event", eventType, "reference", event.Reference, "error", c.redactor.Error(err))
+	}
+}
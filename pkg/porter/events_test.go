@@ -0,0 +1,39 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullAndExportPublishEvents(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+	var events []Event
+	client.config.Events = EventPublisherFunc(func(_ context.Context, event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	destination := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), result, destination, ExportOptions{})
+	require.NoError(t, err)
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/missing:1.0.0", true)
+	require.Error(t, err)
+
+	require.Len(t, events, 2, "failed pulls publish nothing")
+	assert.Equal(t, EventArtifactPulled, events[0].Type)
+	assert.Equal(t, ref, events[0].Reference)
+	assert.Equal(t, result.Digest, events[0].Digest)
+	assert.Equal(t, result.Size, events[0].Size)
+	assert.Equal(t, EventArtifactExported, events[1].Type)
+	assert.Equal(t, destination, events[1].Destination)
+	assert.Equal(t, len(exported), events[1].Files)
+	assert.Equal(t, destination, events[1].Data()["destination"])
+}
@@ -0,0 +1,48 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// ExistsResult reports whether a reference exists at its registry and, when it
+// does, the manifest or index it resolves to.
+type ExistsResult struct {
+	Reference string `json:"reference"`
+	Exists    bool   `json:"exists"`
+	Digest    string `json:"digest,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// CheckExists resolves ref at its registry with a single HEAD request, without
+// fetching any content. A reference the registry does not know is reported with
+// Exists false; failures to ask, such as authentication errors, are returned.
+func (c *Client) CheckExists(ctx context.Context, ref string, insecure bool) (*ExistsResult, error) {
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	result := &ExistsResult{Reference: ref}
+	_, desc, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	var errResp *errcode.ErrorResponse
+	if errors.Is(err, errdef.ErrNotFound) || (errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound) {
+		c.logger.Debug("Reference does not exist", "ref", reference.Redact(ref))
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Exists = true
+	result.Digest = desc.Digest.String()
+	result.Size = desc.Size
+	result.MediaType = desc.MediaType
+	c.logger.Debug("Reference exists", "ref", reference.Redact(ref), "digest", result.Digest)
+	return result, nil
+}
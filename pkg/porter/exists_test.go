@@ -0,0 +1,37 @@
+package porter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckExists(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+
+	result, err := client.CheckExists(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.True(t, result.Exists)
+	assert.Equal(t, desc.Digest.String(), result.Digest)
+	assert.Equal(t, desc.Size, result.Size)
+
+	result, err = client.CheckExists(context.Background(), registry.Host()+"/team/tool@"+desc.Digest.String(), true)
+	require.NoError(t, err)
+	assert.True(t, result.Exists)
+
+	for _, ref := range []string{"/team/tool:2.0.0", "/team/missing:1.0.0"} {
+		result, err = client.CheckExists(context.Background(), registry.Host()+ref, true)
+		require.NoError(t, err, ref)
+		assert.False(t, result.Exists, ref)
+		assert.Empty(t, result.Digest, ref)
+	}
+
+	for _, req := range registry.Requests() {
+		assert.True(t, strings.HasPrefix(req, "HEAD "), req)
+	}
+}
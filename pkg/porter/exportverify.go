@@ -0,0 +1,82 @@
+package porter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// exportVerification collects the digest every exported file was re-read with,
+// for ArtifactResult.VerifiedFiles. It is safe for concurrent use.
+type exportVerification struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func newExportVerification() *exportVerification {
+	return &exportVerification{files: make(map[string]string)}
+}
+
+func (v *exportVerification) add(path string, dgst digest.Digest) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	v.files[path] = dgst.String()
+	v.mu.Unlock()
+}
+
+// result returns the verified files, or nil when none were written.
+func (v *exportVerification) result() map[string]string {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.files) == 0 {
+		return nil
+	}
+	files := make(map[string]string, len(v.files))
+	for path, dgst := range v.files {
+		files[path] = dgst
+	}
+	return files
+}
+
+// verifyExportedFile re-reads path from the destination and compares it with
+// expected, catching corruption introduced by the file system after the write,
+// which NFS mounts have been seen to do silently.
+func verifyExportedFile(path string, expected digest.Digest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to re-read %s for verification: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	verifier := expected.Verifier()
+	if _, err := io.Copy(verifier, f); err != nil {
+		return fmt.Errorf("failed to re-read %s for verification: %w", path, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("exported file %s does not match digest %s after writing", path, expected)
+	}
+	return nil
+}
+
+// verifyLayerStream drains the rest of a layer stream whose content was partly
+// consumed, e.g. by a tar reader that stops at the end-of-archive marker, and
+// reports whether all of it matched the layer digest.
+func verifyLayerStream(reader io.Reader, verifier digest.Verifier, layer digest.Digest) error {
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to read layer %s: %w", layer, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("layer %s does not match its digest; files extracted from it may be corrupt", layer)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportReportsVerifiedFiles(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(outDir, "tool")}, exported)
+	assert.Equal(t, map[string]string{exported[0]: digest.FromBytes(data).String()}, result.VerifiedFiles)
+
+	// A second export skips the unchanged file without re-reading it.
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, result.VerifiedFiles)
+}
+
+func TestExportRejectsCorruptedLayer(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("new tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	result, err := newTestClient(t, nil).PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	previous := filepath.Join(outDir, "tool")
+	require.NoError(t, os.WriteFile(previous, []byte("old tool binary"), 0644))
+
+	t.Setenv(faults.EnvVar, "corrupt-at=3,match="+digest.FromBytes(data).Encoded())
+	_, err = newTestClient(t, nil).ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), digest.FromBytes(data).String())
+
+	kept, err := os.ReadFile(previous)
+	require.NoError(t, err)
+	assert.Equal(t, "old tool binary", string(kept))
+}
+
+func TestVerifyExportedFileDetectsChangedContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(path, []byte("tool binary"), 0644))
+
+	require.NoError(t, verifyExportedFile(path, digest.FromString("tool binary")))
+	err := verifyExportedFile(path, digest.FromString("tool binarY"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+}
@@ -0,0 +1,151 @@
+package porter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+)
+
+// extractJournalPrefix starts the name of the journal an archive extraction keeps
+// in its destination directory, followed by the layer digest. The journal is
+// removed once the extraction completes, so one left behind marks an interrupted
+// extraction the next export of the same layer resumes.
+const extractJournalPrefix = ".porter-extract-"
+
+// journalEntry is a regular file an extraction completed: the index of its entry
+// in the archive, its cleaned name, and the digest and size it was written with.
+type journalEntry struct {
+	Index  int           `json:"index"`
+	Name   string        `json:"name"`
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+}
+
+// extractJournal checkpoints an archive extraction, one JSON line per completed
+// file. Extracted files are synced before they are renamed into place, so a line
+// is only appended for a file that survives a crash; a line lost in one merely
+// costs rewriting its file. A nil journal records and resumes nothing.
+type extractJournal struct {
+	path   string
+	logger hclog.Logger
+	file   *os.File
+	// done holds the entries completed by an interrupted extraction, by index.
+	done map[int]journalEntry
+}
+
+// extractJournalPath returns the journal of extracting layer into dir.
+func extractJournalPath(dir string, layer digest.Digest) string {
+	return filepath.Join(dir, extractJournalPrefix+layer.Encoded()+".jsonl")
+}
+
+// openExtractJournal opens the journal of extracting layer into dir, loading the
+// entries an interrupted extraction completed unless fresh is set.
+func openExtractJournal(dir string, layer digest.Digest, fresh bool, logger hclog.Logger) (*extractJournal, error) {
+	j := &extractJournal{path: extractJournalPath(dir, layer), logger: logger, done: make(map[int]journalEntry)}
+	valid := int64(0)
+	if data, err := os.ReadFile(j.path); err == nil && !fresh {
+		valid = j.load(data)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Ignoring unreadable extraction journal", "path", j.path, "error", err)
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extraction journal: %w", err)
+	}
+	// Drop a line torn by the interruption, and the whole journal of a fresh start.
+	if err := file.Truncate(valid); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to reset extraction journal: %w", err)
+	}
+	if _, err := file.Seek(valid, 0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to reset extraction journal: %w", err)
+	}
+	j.file = file
+	if len(j.done) > 0 {
+		logger.Info("Resuming interrupted extraction", "layer", layer, "completed", len(j.done))
+	}
+	return j, nil
+}
+
+// load reads the complete lines of data into done and returns their length.
+func (j *extractJournal) load(data []byte) int64 {
+	var valid int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry journalEntry
+		if int(valid)+len(line) >= len(data) || json.Unmarshal(line, &entry) != nil || entry.Digest.Validate() != nil {
+			break
+		}
+		j.done[entry.Index] = entry
+		valid += int64(len(line)) + 1
+	}
+	return valid
+}
+
+// completed reports whether the interrupted extraction already wrote entry index,
+// named name, to path: the journal records it, and path still holds a regular
+// file of the recorded size and digest. The file is then recorded as exported.
+func (j *extractJournal) completed(index int, name, path string, writer exportWriter) bool {
+	if j == nil {
+		return false
+	}
+	entry, ok := j.done[index]
+	if !ok || entry.Name != name {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != entry.Size {
+		return false
+	}
+	if dgst, err := digestFile(path); err != nil || dgst != entry.Digest {
+		return false
+	}
+	writer.verified.add(path, entry.Digest)
+	writer.delivery.record(path, entry.Digest, entry.Size, info.Mode())
+	return true
+}
+
+// add checkpoints entry index, named name, as written with dgst and size. The
+// journal only saves work, so failing to write it is logged, not returned.
+func (j *extractJournal) add(index int, name string, dgst digest.Digest, size int64) {
+	if j == nil {
+		return
+	}
+	data, err := json.Marshal(journalEntry{Index: index, Name: name, Digest: dgst, Size: size})
+	if err == nil {
+		_, err = j.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		j.logger.Debug("Failed to checkpoint extraction", "path", j.path, "error", err)
+	}
+}
+
+// close keeps the journal for the next export to resume from.
+func (j *extractJournal) close() {
+	if j == nil {
+		return
+	}
+	_ = j.file.Close()
+}
+
+// finish removes the journal of a completed extraction.
+func (j *extractJournal) finish() {
+	if j == nil {
+		return
+	}
+	_ = j.file.Close()
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		j.logger.Warn("Failed to remove extraction journal", "path", j.path, "error", err)
+	}
+}
@@ -0,0 +1,62 @@
+package porter
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportResumesInterruptedExtraction(t *testing.T) {
+	files := make(map[string]string)
+	for _, name := range []string{"bin/tool", "share/data.bin", "README"} {
+		data := make([]byte, 64<<10)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		files[name] = string(data)
+	}
+	layer := tarGz(t, files)
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/bundle", "1.0.0", "application/vnd.oci.image.layer.v1.tar+gzip", layer, nil)
+	result, err := newTestClient(t, nil).PullArtifact(context.Background(), registry.Host()+"/team/bundle:1.0.0", true)
+	require.NoError(t, err)
+
+	// The layer stream drops after the first file is extracted.
+	outDir := t.TempDir()
+	t.Setenv(faults.EnvVar, "drop-after=100000,times=1,match="+digest.FromBytes(layer).Encoded())
+	client := newTestClient(t, nil)
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.Error(t, err)
+	journal := extractJournalPath(outDir, digest.FromBytes(layer))
+	require.FileExists(t, journal)
+	tool, err := os.Stat(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(outDir, "README"))
+
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, exported, filepath.Join(outDir, "bin", "tool"))
+	resumed, err := os.Stat(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(tool, resumed), "the completed file is not written again")
+	assert.NoFileExists(t, journal)
+	for name, data := range files {
+		got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		assert.Equal(t, data, string(got), name)
+	}
+
+	// --full ignores the journal of an interrupted extraction.
+	require.NoError(t, os.WriteFile(journal, []byte(`{"index":0,"name":"bin/tool","digest":"`+digest.FromString(files["bin/tool"]).String()+`","size":65536}`+"\n"), 0644))
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{Full: true})
+	require.NoError(t, err)
+	rewritten, err := os.Stat(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(tool, rewritten))
+}
@@ -0,0 +1,383 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+const (
+	// AnnotationFamily names the family an artifact belongs to.
+	AnnotationFamily = "ds.family"
+	// AnnotationFamilyMembers lists, comma-separated, the repositories of the other
+	// members of the artifact's family. Members are pulled at the same tag.
+	AnnotationFamilyMembers = "ds.family.members"
+	// AnnotationFamilyMember is recorded on pulled members with their name in the family.
+	AnnotationFamilyMember = "ds.family.member"
+
+	// ArtifactTypeFamily marks a family index artifact, whose single layer is a
+	// FamilyIndex document.
+	ArtifactTypeFamily = "application/vnd.delivery-station.family.v1+json"
+)
+
+// FamilyIndex lists the artifacts released together as a family.
+type FamilyIndex struct {
+	Name    string         `json:"name"`
+	Version string         `json:"version,omitempty"`
+	Members []FamilyMember `json:"members"`
+}
+
+// FamilyMember is one artifact of a family. A reference without a registry is
+// resolved against the registry of the family index.
+type FamilyMember struct {
+	Name      string `json:"name"`
+	Reference string `json:"reference"`
+}
+
+// FamilyResult describes the members fetched by PullFamily.
+type FamilyResult struct {
+	Name      string            `json:"name"`
+	Reference string            `json:"reference"`
+	Version   string            `json:"version,omitempty"`
+	Members   []*ArtifactResult `json:"members"`
+}
+
+// ExportedFiles returns the files exported for every member.
+func (f *FamilyResult) ExportedFiles() []string {
+	var files []string
+	for _, member := range f.Members {
+		files = append(files, member.ExportedFiles...)
+	}
+	return files
+}
+
+// validate checks that the index names the family and each member exactly once.
+func (f FamilyIndex) validate() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return fmt.Errorf("family name required")
+	}
+	if len(f.Members) == 0 {
+		return fmt.Errorf("family %s has no members", f.Name)
+	}
+	seen := make(map[string]struct{}, len(f.Members))
+	for _, member := range f.Members {
+		if err := validateFamilyMemberName(member.Name); err != nil {
+			return err
+		}
+		if _, ok := seen[member.Name]; ok {
+			return fmt.Errorf("family %s lists member %s twice", f.Name, member.Name)
+		}
+		seen[member.Name] = struct{}{}
+		if strings.TrimSpace(member.Reference) == "" {
+			return fmt.Errorf("family member %s has no reference", member.Name)
+		}
+	}
+	return nil
+}
+
+// validateFamilyMemberName rejects names that cannot be used as an export directory.
+func validateFamilyMemberName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid family member name %q", name)
+	}
+	return nil
+}
+
+// PullFamily pulls every member of the family that ref belongs to. ref is either a
+// family index artifact or a member annotated with ds.family.members, in which case
+// the other members are pulled at the same tag. All members are pulled before the
+// call returns, and a member whose version annotation differs from the family's
+// version fails the pull.
+func (c *Client) PullFamily(ctx context.Context, ref string, insecure bool) (*FamilyResult, error) {
+	if err := c.CheckDigestReference(ref, false); err != nil {
+		return nil, err
+	}
+	family, err := c.ResolveFamily(ctx, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FamilyResult{Name: family.Name, Reference: ref, Version: family.Version}
+	for _, member := range family.Members {
+		artifact, err := c.PullArtifact(ctx, member.Reference, insecure)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull family member %s: %w", member.Name, err)
+		}
+		if version := artifact.Metadata[ocispec.AnnotationVersion]; version != "" && family.Version != "" && version != family.Version {
+			return nil, fmt.Errorf("family member %s is at version %s, expected %s", member.Name, version, family.Version)
+		}
+
+		artifact.Metadata[AnnotationFamily] = family.Name
+		artifact.Metadata[AnnotationFamilyMember] = member.Name
+		if err := c.saveArtifactMetadata(artifact); err != nil {
+			c.logger.Warn("Failed to save artifact metadata", "error", err)
+		}
+		result.Members = append(result.Members, artifact)
+	}
+
+	c.logger.Info("Family pulled", "family", family.Name, "version", family.Version, "members", len(result.Members))
+	return result, nil
+}
+
+// ExportFamily exports each member of family into its own directory, named after
+// the member, under destination.
+func (c *Client) ExportFamily(ctx context.Context, family *FamilyResult, destination string, opts ExportOptions) ([]string, error) {
+	var exported []string
+	for _, member := range family.Members {
+		name := member.Metadata[AnnotationFamilyMember]
+		files, err := c.ExportArtifact(ctx, member, FamilyMemberDestination(destination, name), opts)
+		if err != nil {
+			return exported, fmt.Errorf("failed to export family member %s: %w", name, err)
+		}
+		member.ExportedFiles = files
+		exported = append(exported, files...)
+	}
+	return exported, nil
+}
+
+// FamilyMemberDestination returns where ExportFamily writes the member called name.
+func FamilyMemberDestination(destination, name string) string {
+	if IsRemoteDestination(destination) {
+		return strings.TrimSuffix(destination, "/") + "/" + name
+	}
+	return filepath.Join(destination, name)
+}
+
+// AuditPullFamily evaluates a family pull like AuditPull does for each member,
+// combining the members' checks, changes and transfer estimates in one record.
+func (c *Client) AuditPullFamily(ctx context.Context, ref string, insecure bool, destination string, opts ExportOptions) (*AuditRecord, error) {
+	record := NewAuditRecord("pull", ref)
+	family, err := c.ResolveFamily(ctx, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+	record.Note("family", AuditPass, fmt.Sprintf("%s with %d members", family.Name, len(family.Members)))
+
+	record.Transfer = &TransferEstimate{Reference: ref}
+	for _, member := range family.Members {
+		memberDestination := ""
+		if destination != "" {
+			memberDestination = FamilyMemberDestination(destination, member.Name)
+		}
+		memberRecord, err := c.AuditPull(ctx, member.Reference, insecure, memberDestination, opts)
+		if err != nil {
+			record.Check(member.Name+"/resolve", err)
+			continue
+		}
+		for _, check := range memberRecord.Checks {
+			check.Name = member.Name + "/" + check.Name
+			record.Checks = append(record.Checks, check)
+		}
+		record.Allowed = record.Allowed && memberRecord.Allowed
+		record.Changes = append(record.Changes, memberRecord.Changes...)
+		if memberRecord.Transfer != nil {
+			for _, blob := range memberRecord.Transfer.Blobs {
+				record.Transfer.add(blob)
+			}
+		}
+	}
+	return record, nil
+}
+
+// ResolveFamily returns the family ref belongs to, with every member reference
+// made absolute.
+func (c *Client) ResolveFamily(ctx context.Context, ref string, insecure bool) (*FamilyIndex, error) {
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	repo, desc, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	var family *FamilyIndex
+	if desc.MediaType == ocispec.MediaTypeImageManifest {
+		manifest, err := fetchManifest(ctx, repo, desc)
+		if err != nil {
+			return nil, err
+		}
+		if manifest.ArtifactType == ArtifactTypeFamily {
+			if family, err = loadFamilyIndex(ctx, repo, manifest); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if family == nil {
+		annotations, err := fetchAnnotations(ctx, repo, desc)
+		if err != nil {
+			return nil, err
+		}
+		if family, err = familyFromAnnotations(parsed.WithDefaultTag(), annotations); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, member := range family.Members {
+		memberRef, err := reference.ParseWithOptions(member.Reference, reference.Options{DefaultRegistry: parsed.Registry, Aliases: c.config.Aliases})
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for family member %s: %w", member.Name, err)
+		}
+		family.Members[i].Reference = memberRef.String()
+	}
+	return family, nil
+}
+
+// loadFamilyIndex reads the FamilyIndex document of a family index artifact.
+func loadFamilyIndex(ctx context.Context, fetcher content.Fetcher, manifest ocispec.Manifest) (*FamilyIndex, error) {
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ArtifactTypeFamily {
+			continue
+		}
+		data, err := content.FetchAll(ctx, fetcher, layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch family index: %w", err)
+		}
+		var family FamilyIndex
+		if err := json.Unmarshal(data, &family); err != nil {
+			return nil, fmt.Errorf("failed to parse family index: %w", err)
+		}
+		if err := family.validate(); err != nil {
+			return nil, fmt.Errorf("invalid family index: %w", err)
+		}
+		return &family, nil
+	}
+	return nil, fmt.Errorf("family index artifact has no %s layer", ArtifactTypeFamily)
+}
+
+// familyFromAnnotations builds the family of the artifact at ref from its
+// ds.family and ds.family.members annotations. The artifact itself is always a member.
+func familyFromAnnotations(ref reference.Reference, annotations map[string]string) (*FamilyIndex, error) {
+	name := strings.TrimSpace(annotations[AnnotationFamily])
+	members := strings.TrimSpace(annotations[AnnotationFamilyMembers])
+	if name == "" || members == "" {
+		return nil, fmt.Errorf("%s is not part of an artifact family: missing %s or %s annotation", ref.String(), AnnotationFamily, AnnotationFamilyMembers)
+	}
+	if !ref.IsTag() {
+		return nil, fmt.Errorf("family of %s must be pulled by tag so members can be matched to its version", ref.String())
+	}
+
+	family := &FamilyIndex{Name: name, Version: annotations[ocispec.AnnotationVersion]}
+	if family.Version == "" {
+		family.Version = ref.Tag
+	}
+	add := func(repository string) {
+		memberName := path.Base(repository)
+		for _, existing := range family.Members {
+			if existing.Name == memberName {
+				return
+			}
+		}
+		family.Members = append(family.Members, FamilyMember{Name: memberName, Reference: repository + ":" + ref.Tag})
+	}
+	add(ref.Registry + "/" + ref.Repository)
+	for _, repository := range strings.Split(members, ",") {
+		if repository = strings.TrimSpace(repository); repository != "" {
+			add(repository)
+		}
+	}
+	if err := family.validate(); err != nil {
+		return nil, err
+	}
+	return family, nil
+}
+
+// PushFamilyIndex publishes family as a family index artifact at ref. Member tags
+// are resolved to digests first, so every pull of the index fetches the same
+// content.
+func (c *Client) PushFamilyIndex(ctx context.Context, family FamilyIndex, ref string, insecure bool) (*ArtifactResult, error) {
+	return c.push(ctx, ref, func(ctx context.Context) (*ArtifactResult, error) {
+		return c.pushFamilyIndex(ctx, family, ref, insecure)
+	})
+}
+
+func (c *Client) pushFamilyIndex(ctx context.Context, family FamilyIndex, ref string, insecure bool) (*ArtifactResult, error) {
+	if err := family.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.CheckNaming(ref); err != nil {
+		return nil, err
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	for i, member := range family.Members {
+		memberRef, err := reference.ParseWithOptions(member.Reference, reference.Options{DefaultRegistry: parsed.Registry, Aliases: c.config.Aliases})
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for family member %s: %w", member.Name, err)
+		}
+		if !memberRef.IsDigest() {
+			dgst, err := c.ResolveDigest(ctx, memberRef.String(), insecure)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve family member %s: %w", member.Name, err)
+			}
+			family.Members[i].Reference = memberRef.String() + "@" + dgst
+		}
+	}
+
+	data, err := json.Marshal(family)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode family index: %w", err)
+	}
+	layerDesc := content.NewDescriptorFromBytes(ArtifactTypeFamily, data)
+	annotations := map[string]string{AnnotationFamily: family.Name}
+	if family.Version != "" {
+		annotations[ocispec.AnnotationVersion] = family.Version
+	}
+	manifest := ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: ArtifactTypeFamily,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{layerDesc},
+		Annotations:  annotations,
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode family manifest: %w", err)
+	}
+	manifestDesc := content.NewDescriptorFromBytes(manifest.MediaType, manifestData)
+
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, err := c.remoteRepository(imgRef, insecure)
+	if err != nil {
+		return nil, err
+	}
+	push := &imagePusher{client: c, ctx: ctx, dst: repo, insecure: insecure}
+	if err := push.bytes(ocispec.DescriptorEmptyJSON, ocispec.DescriptorEmptyJSON.Data); err != nil {
+		return nil, fmt.Errorf("failed to push family config: %w", err)
+	}
+	if err := push.bytes(layerDesc, data); err != nil {
+		return nil, fmt.Errorf("failed to push family index: %w", err)
+	}
+	if err := repo.PushReference(ctx, manifestDesc, bytes.NewReader(manifestData), imgRef.Identifier()); err != nil {
+		return nil, fmt.Errorf("failed to push family manifest: %w", err)
+	}
+
+	c.logger.Info("Pushed family index", "family", family.Name, "reference", ref, "digest", manifestDesc.Digest, "members", len(family.Members))
+	return &ArtifactResult{
+		ID:        manifestDesc.Digest.Encoded()[:16],
+		Reference: ref,
+		Digest:    manifestDesc.Digest.String(),
+		Size:      manifestDesc.Size,
+		Metadata:  annotations,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addAnnotatedArtifact publishes a single-layer artifact with manifest annotations.
+func addAnnotatedArtifact(registry *testRegistry, repo, tag string, data []byte, annotations map[string]string) ocispec.Descriptor {
+	layer := registry.AddBlob("application/octet-stream", data)
+	layer.Annotations = map[string]string{ocispec.AnnotationTitle: filepath.Base(repo)}
+	config := registry.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	return registry.AddManifest(repo, tag, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/octet-stream",
+		Config:       config,
+		Layers:       []ocispec.Descriptor{layer},
+		Annotations:  annotations,
+	})
+}
+
+func TestPullFamilyFromAnnotations(t *testing.T) {
+	registry := newTestRegistry(t)
+	addAnnotatedArtifact(registry, "team/cli", "1.2.0", []byte("cli"), map[string]string{
+		AnnotationFamily:          "suite",
+		AnnotationFamilyMembers:   "team/daemon, team/docs",
+		ocispec.AnnotationVersion: "1.2.0",
+	})
+	addAnnotatedArtifact(registry, "team/daemon", "1.2.0", []byte("daemon"), map[string]string{ocispec.AnnotationVersion: "1.2.0"})
+	addAnnotatedArtifact(registry, "team/docs", "1.2.0", []byte("docs"), nil)
+	addAnnotatedArtifact(registry, "team/daemon", "1.3.0", []byte("newer daemon"), nil)
+
+	client := newTestClient(t, nil)
+	family, err := client.PullFamily(context.Background(), registry.Host()+"/team/cli:1.2.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, "suite", family.Name)
+	assert.Equal(t, "1.2.0", family.Version)
+	require.Len(t, family.Members, 3)
+
+	var names []string
+	for _, member := range family.Members {
+		names = append(names, member.Metadata[AnnotationFamilyMember])
+		assert.Equal(t, "suite", member.Metadata[AnnotationFamily])
+		assert.True(t, strings.HasSuffix(member.Reference, ":1.2.0"), member.Reference)
+	}
+	assert.Equal(t, []string{"cli", "daemon", "docs"}, names)
+
+	out := t.TempDir()
+	_, err = client.ExportFamily(context.Background(), family, out, ExportOptions{})
+	require.NoError(t, err)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(out, name, name))
+		require.NoError(t, err)
+		assert.Equal(t, name, string(data))
+	}
+
+	cached, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	require.Len(t, cached, 3)
+	for _, artifact := range cached {
+		assert.Equal(t, "suite", artifact.Metadata[AnnotationFamily])
+	}
+}
+
+func TestPullFamilyRejectsMismatchedVersion(t *testing.T) {
+	registry := newTestRegistry(t)
+	addAnnotatedArtifact(registry, "team/cli", "1.2.0", []byte("cli"), map[string]string{
+		AnnotationFamily:        "suite",
+		AnnotationFamilyMembers: "team/daemon",
+	})
+	addAnnotatedArtifact(registry, "team/daemon", "1.2.0", []byte("daemon"), map[string]string{ocispec.AnnotationVersion: "1.1.9"})
+
+	client := newTestClient(t, nil)
+	_, err := client.PullFamily(context.Background(), registry.Host()+"/team/cli:1.2.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "family member daemon is at version 1.1.9, expected 1.2.0")
+}
+
+func TestPullFamilyRequiresFamilyAnnotations(t *testing.T) {
+	registry := newTestRegistry(t)
+	addAnnotatedArtifact(registry, "team/cli", "1.2.0", []byte("cli"), nil)
+
+	client := newTestClient(t, nil)
+	_, err := client.PullFamily(context.Background(), registry.Host()+"/team/cli:1.2.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not part of an artifact family")
+}
+
+func TestPushFamilyIndexPinsMemberDigests(t *testing.T) {
+	registry := newTestRegistry(t)
+	cli := addAnnotatedArtifact(registry, "team/cli", "2.0.0", []byte("cli"), nil)
+	addAnnotatedArtifact(registry, "team/daemon", "2.0.0", []byte("daemon"), nil)
+
+	client := newTestClient(t, nil)
+	_, err := client.PushFamilyIndex(context.Background(), FamilyIndex{
+		Name:    "suite",
+		Version: "2.0.0",
+		Members: []FamilyMember{
+			{Name: "cli", Reference: "team/cli:2.0.0"},
+			{Name: "daemon", Reference: registry.Host() + "/team/daemon:2.0.0"},
+		},
+	}, registry.Host()+"/team/suite:2.0.0", true)
+	require.NoError(t, err)
+
+	// Moving a member tag after publishing must not change what the family pulls.
+	addAnnotatedArtifact(registry, "team/cli", "2.0.0", []byte("rebuilt cli"), nil)
+
+	family, err := client.PullFamily(context.Background(), registry.Host()+"/team/suite:2.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, "suite", family.Name)
+	require.Len(t, family.Members, 2)
+	assert.Equal(t, cli.Digest.String(), family.Members[0].Digest)
+	assert.Equal(t, "daemon", family.Members[1].Metadata[AnnotationFamilyMember])
+}
+
+func TestFamilyIndexValidate(t *testing.T) {
+	assert.Error(t, FamilyIndex{Members: []FamilyMember{{Name: "a", Reference: "r"}}}.validate())
+	assert.Error(t, FamilyIndex{Name: "suite"}.validate())
+	assert.Error(t, FamilyIndex{Name: "suite", Members: []FamilyMember{{Name: "../a", Reference: "r"}}}.validate())
+	assert.Error(t, FamilyIndex{Name: "suite", Members: []FamilyMember{{Name: "a", Reference: "r"}, {Name: "a", Reference: "s"}}}.validate())
+	assert.NoError(t, FamilyIndex{Name: "suite", Members: []FamilyMember{{Name: "a", Reference: "r"}}}.validate())
+}
@@ -0,0 +1,90 @@
+package porter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// FinalizerPreview describes a finalizer an artifact requests, resolved exactly as
+// DS will run it, so it can be reviewed before it executes.
+type FinalizerPreview struct {
+	Name      string   `json:"name"`
+	Operation string   `json:"operation"`
+	Args      []string `json:"args,omitempty"`
+	// Installed reports whether the target plugin is present in the DS plugin
+	// directory of this host.
+	Installed  bool   `json:"installed"`
+	PluginPath string `json:"plugin_path,omitempty"`
+}
+
+// FinalizerTemplateData is available to finalizer arguments written as Go
+// templates, e.g. "{{.Path}}/bin" or "--digest={{.Digest}}".
+type FinalizerTemplateData struct {
+	// Path is the absolute export destination; empty when nothing was exported.
+	Path      string
+	Reference string
+	Digest    string
+	ID        string
+	Version   string
+}
+
+// RenderFinalizerArgs expands the templated arguments of a finalizer requested by
+// result, exported to path. Arguments without template actions are unchanged.
+func RenderFinalizerArgs(args []string, result *ArtifactResult, path string) ([]string, error) {
+	data := FinalizerTemplateData{
+		Path:      path,
+		Reference: result.Reference,
+		Digest:    result.Digest,
+		ID:        result.ID,
+	}
+	if version, err := exportVersion(result, ""); err == nil {
+		data.Version = version
+	}
+
+	rendered := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			rendered = append(rendered, arg)
+			continue
+		}
+		tmpl, err := template.New("finalizer").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse finalizer argument %q: %w", arg, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render finalizer argument %q: %w", arg, err)
+		}
+		rendered = append(rendered, buf.String())
+	}
+	return rendered, nil
+}
+
+// FinalizerPlugin returns where DS would find the plugin named name and whether it
+// is installed there. DS runs plugins from executables named ds-<name> in its
+// plugin directory.
+func (c *Client) FinalizerPlugin(name string) (string, bool) {
+	dir := strings.TrimSpace(c.config.PluginsDir)
+	name = strings.TrimSpace(name)
+	if dir == "" || name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+
+	for _, candidate := range []string{"ds-" + name, "ds-" + name + ".exe", "ds-" + name + ".sh"} {
+		path := filepath.Join(dir, candidate)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			continue
+		}
+		return path, true
+	}
+	return "", false
+}
@@ -0,0 +1,48 @@
+package porter
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderFinalizerArgs(t *testing.T) {
+	result := &ArtifactResult{
+		ID:        "abc",
+		Reference: "ghcr.io/team/tool:1.4.0",
+		Digest:    "sha256:0123",
+	}
+	args, err := RenderFinalizerArgs([]string{"--install", "{{.Path}}/bin", "--ref={{.Reference}}@{{.Version}}"}, result, "/opt/tool")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--install", "/opt/tool/bin", "--ref=ghcr.io/team/tool:1.4.0@1.4.0"}, args)
+
+	_, err = RenderFinalizerArgs([]string{"{{.Missing}}"}, result, "/opt/tool")
+	assert.Error(t, err)
+}
+
+func TestFinalizerPlugin(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ds-uploader"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ds-notes"), []byte("text"), 0644))
+
+	client, err := NewClient(&Config{CacheDir: t.TempDir(), PluginsDir: dir}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	path, ok := client.FinalizerPlugin("uploader")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "ds-uploader"), path)
+
+	_, ok = client.FinalizerPlugin("missing")
+	assert.False(t, ok)
+	_, ok = client.FinalizerPlugin("../uploader")
+	assert.False(t, ok)
+	if runtime.GOOS != "windows" {
+		_, ok = client.FinalizerPlugin("notes")
+		assert.False(t, ok, "non-executable files are not plugins")
+	}
+}
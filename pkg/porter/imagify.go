@@ -0,0 +1,458 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// DefaultImagifyBase is the base image used when ImagifyOptions.Base is empty.
+const DefaultImagifyBase = "gcr.io/distroless/static:latest"
+
+// DefaultImagifyDir is where exported files are placed inside the image.
+const DefaultImagifyDir = "/usr/local/bin"
+
+const (
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerLayerMediaType    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// ImagifyOptions configures how an artifact is wrapped into a container image.
+type ImagifyOptions struct {
+	// Base is the image the exported files are layered onto.
+	Base string
+	// Target is the image reference to push.
+	Target string
+	// Platform selects both the artifact binary and the base image variant.
+	// Defaults to linux on the current architecture.
+	Platform *ocispec.Platform
+	// Dir is the absolute directory inside the image that receives the files.
+	Dir string
+	// Entrypoint overrides the image entrypoint. When empty and a single file is
+	// exported, that file becomes the entrypoint.
+	Entrypoint []string
+	Insecure   bool
+}
+
+// ImagifyResult describes an image pushed by Imagify.
+type ImagifyResult struct {
+	Reference  string   `json:"reference"`
+	Digest     string   `json:"digest"`
+	Base       string   `json:"base"`
+	BaseDigest string   `json:"base_digest"`
+	Platform   string   `json:"platform"`
+	Files      []string `json:"files"`
+	Entrypoint []string `json:"entrypoint,omitempty"`
+}
+
+// Imagify pulls ref, exports it for a single platform, and pushes a container image
+// made of the base image plus one layer holding the exported files.
+func (c *Client) Imagify(ctx context.Context, ref string, opts ImagifyOptions) (*ImagifyResult, error) {
+	if strings.TrimSpace(opts.Target) == "" {
+		return nil, fmt.Errorf("target image reference required")
+	}
+	if opts.Base == "" {
+		opts.Base = DefaultImagifyBase
+	}
+	if opts.Dir == "" {
+		opts.Dir = DefaultImagifyDir
+	}
+	if !path.IsAbs(opts.Dir) {
+		return nil, fmt.Errorf("image directory %q must be absolute", opts.Dir)
+	}
+	platform := ocispec.Platform{OS: "linux", Architecture: runtime.GOARCH}
+	if opts.Platform != nil {
+		platform = *opts.Platform
+	}
+
+	artifact, err := c.PullArtifact(ctx, ref, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	stageDir, err := os.MkdirTemp("", "porter-imagify-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(stageDir)
+	}()
+
+	exported, err := c.exportArtifact(ctx, artifact, stageDir, ExportOptions{Platforms: []ocispec.Platform{platform}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export artifact: %w", err)
+	}
+
+	baseRef, err := c.parseReference(opts.Base, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base reference: %w", err)
+	}
+	baseRepo, baseDesc, err := c.resolveRemote(ctx, baseRef, opts.Base, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	baseManifestDesc, err := selectPlatformManifest(ctx, baseRepo, baseDesc, platform)
+	if err != nil {
+		return nil, fmt.Errorf("base image %s: %w", opts.Base, err)
+	}
+	baseManifest, err := fetchManifest(ctx, baseRepo, baseManifestDesc)
+	if err != nil {
+		return nil, err
+	}
+	baseConfig, err := content.FetchAll(ctx, baseRepo, baseManifest.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch base image config: %w", err)
+	}
+
+	dockerFormat := baseManifestDesc.MediaType == dockerManifestMediaType
+	layerMediaType := ocispec.MediaTypeImageLayerGzip
+	if dockerFormat {
+		layerMediaType = dockerLayerMediaType
+	}
+
+	files, err := imageFiles(stageDir, exported, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	layerPath := filepath.Join(stageDir, ".layer.tar.gz")
+	layerDesc, diffID, err := writeImageLayer(layerPath, files, layerMediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	entrypoint := opts.Entrypoint
+	if len(entrypoint) == 0 && len(files) == 1 {
+		entrypoint = []string{files[0].imagePath}
+	}
+	configData, err := imagifyConfig(baseConfig, diffID, entrypoint, ref)
+	if err != nil {
+		return nil, err
+	}
+	configDesc := content.NewDescriptorFromBytes(baseManifest.Config.MediaType, configData)
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: baseManifestDesc.MediaType,
+		Config:    configDesc,
+		Layers:    append(append([]ocispec.Descriptor{}, baseManifest.Layers...), layerDesc),
+	}
+	if !dockerFormat {
+		manifest.Annotations = map[string]string{
+			ocispec.AnnotationBaseImageName:   baseRef.Context().Name(),
+			ocispec.AnnotationBaseImageDigest: baseManifestDesc.Digest.String(),
+		}
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image manifest: %w", err)
+	}
+	manifestDesc := content.NewDescriptorFromBytes(manifest.MediaType, manifestData)
+
+	targetRef, err := c.parseReference(opts.Target, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target reference: %w", err)
+	}
+	targetRepo, err := c.remoteRepository(targetRef, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	push := &imagePusher{client: c, ctx: ctx, dst: targetRepo, insecure: opts.Insecure}
+
+	for _, layer := range baseManifest.Layers {
+		layer := layer
+		if err := push.blob(layer, func() (io.ReadCloser, error) { return baseRepo.Fetch(ctx, layer) }); err != nil {
+			return nil, fmt.Errorf("failed to copy base layer %s: %w", layer.Digest, err)
+		}
+	}
+	if err := push.blob(layerDesc, func() (io.ReadCloser, error) { return os.Open(layerPath) }); err != nil {
+		return nil, fmt.Errorf("failed to push layer: %w", err)
+	}
+	if err := push.bytes(configDesc, configData); err != nil {
+		return nil, fmt.Errorf("failed to push image config: %w", err)
+	}
+	if err := targetRepo.PushReference(ctx, manifestDesc, bytes.NewReader(manifestData), targetRef.Identifier()); err != nil {
+		return nil, fmt.Errorf("failed to push image manifest: %w", err)
+	}
+
+	imageFilesOut := make([]string, 0, len(files))
+	for _, file := range files {
+		imageFilesOut = append(imageFilesOut, file.imagePath)
+	}
+
+	c.logger.Info("Pushed image", "reference", opts.Target, "digest", manifestDesc.Digest, "base", opts.Base)
+	return &ImagifyResult{
+		Reference:  opts.Target,
+		Digest:     manifestDesc.Digest.String(),
+		Base:       opts.Base,
+		BaseDigest: baseManifestDesc.Digest.String(),
+		Platform:   formatPlatform(&platform),
+		Files:      imageFilesOut,
+		Entrypoint: entrypoint,
+	}, nil
+}
+
+// selectPlatformManifest returns desc when it is a manifest, or the entry of the index
+// matching platform.
+func selectPlatformManifest(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor, platform ocispec.Platform) (ocispec.Descriptor, error) {
+	if !isIndexDescriptor(desc) && desc.MediaType != dockerListMediaType {
+		return desc, nil
+	}
+	index, err := fetchIndex(ctx, fetcher, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	for _, child := range index.Manifests {
+		if child.Platform != nil && platformMatches(child.Platform, []ocispec.Platform{platform}) {
+			return child, nil
+		}
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("no manifest for platform %s", formatPlatform(&platform))
+}
+
+type imageFile struct {
+	source    string
+	imagePath string
+	mode      os.FileMode
+}
+
+// imageFiles maps exported files below stageDir to paths below dir in the image.
+func imageFiles(stageDir string, exported []string, dir string) ([]imageFile, error) {
+	files := make([]imageFile, 0, len(exported))
+	for _, source := range exported {
+		info, err := os.Lstat(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat exported file: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		rel, err := filepath.Rel(stageDir, source)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, imageFile{
+			source:    source,
+			imagePath: path.Join(dir, filepath.ToSlash(rel)),
+			mode:      info.Mode().Perm() | 0555,
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("artifact exported no files")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].imagePath < files[j].imagePath })
+	return files, nil
+}
+
+// writeImageLayer writes a reproducible gzip-compressed tar of files to layerPath and
+// returns its descriptor and uncompressed digest (the config diff ID).
+func writeImageLayer(layerPath string, files []imageFile, mediaType string) (ocispec.Descriptor, digest.Digest, error) {
+	out, err := os.Create(layerPath)
+	if err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to create layer: %w", err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	compressed := &countingHash{Hash: sha256.New()}
+	gz := gzip.NewWriter(io.MultiWriter(out, compressed))
+	uncompressed := sha256.New()
+	tw := tar.NewWriter(io.MultiWriter(gz, uncompressed))
+
+	epoch := time.Unix(0, 0).UTC()
+	dirs := make(map[string]struct{})
+	for _, file := range files {
+		var parents []string
+		for dir := path.Dir(file.imagePath); dir != "/"; dir = path.Dir(dir) {
+			parents = append([]string{dir}, parents...)
+		}
+		for _, dir := range parents {
+			if _, ok := dirs[dir]; ok {
+				continue
+			}
+			dirs[dir] = struct{}{}
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     strings.TrimPrefix(dir, "/") + "/",
+				Mode:     0755,
+				ModTime:  epoch,
+				Format:   tar.FormatPAX,
+			}); err != nil {
+				return ocispec.Descriptor{}, "", fmt.Errorf("failed to write layer: %w", err)
+			}
+		}
+
+		if err := addLayerFile(tw, file, epoch); err != nil {
+			return ocispec.Descriptor{}, "", err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to finish layer: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to finish layer: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to finish layer: %w", err)
+	}
+
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.NewDigest(digest.SHA256, compressed),
+		Size:      compressed.size,
+	}
+	return desc, digest.NewDigest(digest.SHA256, uncompressed), nil
+}
+
+func addLayerFile(tw *tar.Writer, file imageFile, modTime time.Time) error {
+	in, err := os.Open(file.source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.source, err)
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(file.imagePath, "/"),
+		Mode:     int64(file.mode),
+		Size:     info.Size(),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return fmt.Errorf("failed to write layer: %w", err)
+	}
+	if _, err := io.Copy(tw, in); err != nil {
+		return fmt.Errorf("failed to write %s to layer: %w", file.imagePath, err)
+	}
+	return nil
+}
+
+// countingHash is a hash that also records how many bytes it consumed.
+type countingHash struct {
+	hash.Hash
+	size int64
+}
+
+func (h *countingHash) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	return h.Hash.Write(p)
+}
+
+// imagifyConfig appends the new layer to the base image config, preserving fields
+// porter does not know about.
+func imagifyConfig(baseConfig []byte, diffID digest.Digest, entrypoint []string, ref string) ([]byte, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(baseConfig, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse base image config: %w", err)
+	}
+
+	created := time.Now().UTC().Format(time.RFC3339)
+	config["created"] = created
+
+	rootfs, _ := config["rootfs"].(map[string]interface{})
+	if rootfs == nil {
+		rootfs = map[string]interface{}{"type": "layers"}
+	}
+	diffIDs, _ := rootfs["diff_ids"].([]interface{})
+	rootfs["diff_ids"] = append(diffIDs, diffID.String())
+	config["rootfs"] = rootfs
+
+	history, _ := config["history"].([]interface{})
+	config["history"] = append(history, map[string]interface{}{
+		"created":    created,
+		"created_by": "ds porter imagify " + ref,
+	})
+
+	if len(entrypoint) > 0 {
+		runConfig, _ := config["config"].(map[string]interface{})
+		if runConfig == nil {
+			runConfig = map[string]interface{}{}
+		}
+		runConfig["Entrypoint"] = entrypoint
+		delete(runConfig, "Cmd")
+		config["config"] = runConfig
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image config: %w", err)
+	}
+	return data, nil
+}
+
+// imagePusher uploads blobs to a repository, skipping blobs it already holds and
+// falling back to plain HTTP when the registry does not speak TLS.
+type imagePusher struct {
+	client   *Client
+	ctx      context.Context
+	dst      *remote.Repository
+	insecure bool
+}
+
+func (p *imagePusher) exists(desc ocispec.Descriptor) (bool, error) {
+	exists, err := p.dst.Exists(p.ctx, desc)
+	if err != nil && !p.insecure && !p.dst.PlainHTTP && isPlainHTTPResponseError(err) {
+		p.client.logger.Warn("Retrying push over plain HTTP", "repository", p.dst.Reference.String())
+		p.dst.PlainHTTP = true
+		exists, err = p.dst.Exists(p.ctx, desc)
+	}
+	return exists, err
+}
+
+func (p *imagePusher) blob(desc ocispec.Descriptor, open func() (io.ReadCloser, error)) error {
+	exists, err := p.exists(desc)
+	if err != nil {
+		return err
+	}
+	if exists {
+		p.client.logger.Debug("Blob already present", "digest", desc.Digest)
+		return nil
+	}
+
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if err := p.dst.Push(p.ctx, desc, reader); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+func (p *imagePusher) bytes(desc ocispec.Descriptor, data []byte) error {
+	return p.blob(desc, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
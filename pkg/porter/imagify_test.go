@@ -0,0 +1,116 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addBaseImage(registry *testRegistry) ocispec.Descriptor {
+	baseLayer := registry.AddBlob(ocispec.MediaTypeImageLayerGzip, []byte("base layer"))
+	config := registry.AddBlob(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux",`+
+		`"config":{"Cmd":["/bin/sh"],"User":"65532"},"rootfs":{"type":"layers","diff_ids":["sha256:`+
+		digest.FromString("base").Encoded()+`"]}}`))
+	manifest := registry.AddManifest("distroless/static", "", ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{baseLayer},
+	})
+	manifest.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	registry.AddManifest("distroless/static", "latest", ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{manifest},
+	})
+	return manifest
+}
+
+func registryJSON(t *testing.T, registry *testRegistry, dgst string, out interface{}) {
+	t.Helper()
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	data, ok := registry.blobs[dgst]
+	if !ok {
+		data = registry.manifests[dgst].data
+	}
+	require.NoError(t, json.Unmarshal(data, out))
+}
+
+func TestImagifyLayersBinaryOntoBase(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	baseManifest := addBaseImage(registry)
+
+	client := newTestClient(t, nil)
+	result, err := client.Imagify(context.Background(), registry.Host()+"/team/tool:1.0.0", ImagifyOptions{
+		Base:     registry.Host() + "/distroless/static",
+		Target:   registry.Host() + "/team/tool-image:1.0.0",
+		Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, baseManifest.Digest.String(), result.BaseDigest)
+	assert.Equal(t, []string{"/usr/local/bin/tool"}, result.Files)
+	assert.Equal(t, []string{"/usr/local/bin/tool"}, result.Entrypoint)
+	assert.Equal(t, result.Digest, registry.tags["team/tool-image"]["1.0.0"])
+
+	var manifest ocispec.Manifest
+	registryJSON(t, registry, result.Digest, &manifest)
+	require.Len(t, manifest.Layers, 2)
+	assert.Equal(t, digest.FromBytes([]byte("base layer")), manifest.Layers[0].Digest)
+	assert.Equal(t, baseManifest.Digest.String(), manifest.Annotations[ocispec.AnnotationBaseImageDigest])
+
+	var config struct {
+		Config struct {
+			Entrypoint []string
+			Cmd        []string
+			User       string
+		} `json:"config"`
+		RootFS ocispec.RootFS `json:"rootfs"`
+	}
+	registryJSON(t, registry, manifest.Config.Digest.String(), &config)
+	assert.Equal(t, []string{"/usr/local/bin/tool"}, config.Config.Entrypoint)
+	assert.Empty(t, config.Config.Cmd)
+	assert.Equal(t, "65532", config.Config.User)
+	require.Len(t, config.RootFS.DiffIDs, 2)
+
+	registry.mu.Lock()
+	layer := registry.blobs[manifest.Layers[1].Digest.String()]
+	registry.mu.Unlock()
+	gz, err := gzip.NewReader(bytes.NewReader(layer))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromBytes(uncompressed), config.RootFS.DiffIDs[1])
+
+	entries := map[string]*tar.Header{}
+	tr := tar.NewReader(bytes.NewReader(uncompressed))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		entries[header.Name] = header
+	}
+	require.Contains(t, entries, "usr/local/bin/tool")
+	assert.Equal(t, int64(0755), entries["usr/local/bin/tool"].Mode&0777)
+	assert.Contains(t, entries, "usr/local/bin/")
+}
+
+func TestImagifyRequiresTarget(t *testing.T) {
+	client := newTestClient(t, nil)
+	_, err := client.Imagify(context.Background(), "example.com/team/tool:1.0.0", ImagifyOptions{})
+	assert.ErrorContains(t, err, "target image reference required")
+}
@@ -0,0 +1,121 @@
+package porter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layer annotations that decide the order layers are exported in.
+const (
+	// AnnotationLayerOrder ranks a layer; lower ranks are exported first. Layers
+	// without it rank 0, and layers of equal rank keep their manifest order.
+	AnnotationLayerOrder = "ds.layer.order"
+	// AnnotationLayerDepends lists, comma-separated, the layers that must be
+	// exported before this one, each by its title annotation or digest.
+	AnnotationLayerDepends = "ds.layer.depends"
+)
+
+// orderLayers returns layers in export order: every layer after the layers it
+// depends on, and otherwise by ds.layer.order and manifest position. Manifests
+// without ordering annotations keep their order.
+func orderLayers(layers []ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	ranks := make([]int, len(layers))
+	byName := make(map[string]int, len(layers)*2)
+	annotated := false
+	for i, layer := range layers {
+		byName[layer.Digest.String()] = i
+		if title := strings.TrimSpace(layer.Annotations[ocispec.AnnotationTitle]); title != "" {
+			if other, ok := byName[title]; ok && other != i {
+				// Ambiguous titles can only be depended on by digest.
+				byName[title] = -1
+			} else {
+				byName[title] = i
+			}
+		}
+		if value, ok := layer.Annotations[AnnotationLayerOrder]; ok {
+			rank, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("layer %s has invalid %s %q: must be an integer", layerName(layer), AnnotationLayerOrder, value)
+			}
+			ranks[i] = rank
+			annotated = true
+		}
+		if _, ok := layer.Annotations[AnnotationLayerDepends]; ok {
+			annotated = true
+		}
+	}
+	if !annotated {
+		return layers, nil
+	}
+
+	// dependents[i] lists the layers waiting for layer i; pending counts what each
+	// layer still waits for.
+	dependents := make([][]int, len(layers))
+	pending := make([]int, len(layers))
+	for i, layer := range layers {
+		for _, dep := range strings.Split(layer.Annotations[AnnotationLayerDepends], ",") {
+			dep = strings.TrimSpace(dep)
+			if dep == "" {
+				continue
+			}
+			j, ok := byName[dep]
+			switch {
+			case !ok:
+				return nil, fmt.Errorf("layer %s depends on %q, which is not a layer of the manifest", layerName(layer), dep)
+			case j < 0:
+				return nil, fmt.Errorf("layer %s depends on %q, which titles several layers; depend on a digest instead", layerName(layer), dep)
+			case j == i:
+				return nil, fmt.Errorf("layer %s depends on itself", layerName(layer))
+			}
+			dependents[j] = append(dependents[j], i)
+			pending[i]++
+		}
+	}
+
+	var ready []int
+	for i := range layers {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	ordered := make([]ocispec.Descriptor, 0, len(layers))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(a, b int) bool {
+			if ranks[ready[a]] != ranks[ready[b]] {
+				return ranks[ready[a]] < ranks[ready[b]]
+			}
+			return ready[a] < ready[b]
+		})
+		next := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, layers[next])
+		for _, dependent := range dependents[next] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(ordered) < len(layers) {
+		// Layers left pending are on a cycle or wait on one.
+		var cycle []string
+		for i, layer := range layers {
+			if pending[i] > 0 {
+				cycle = append(cycle, layerName(layer))
+			}
+		}
+		return nil, fmt.Errorf("%s annotations form a cycle; layers %s wait on each other", AnnotationLayerDepends, strings.Join(cycle, ", "))
+	}
+	return ordered, nil
+}
+
+// layerName names layer in errors: its title, or its digest.
+func layerName(layer ocispec.Descriptor) string {
+	if title := strings.TrimSpace(layer.Annotations[ocispec.AnnotationTitle]); title != "" {
+		return title
+	}
+	return layer.Digest.String()
+}
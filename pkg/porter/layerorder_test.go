@@ -0,0 +1,62 @@
+package porter
+
+import (
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orderTestLayer(title string, annotations map[string]string) ocispec.Descriptor {
+	layer := ocispec.Descriptor{Digest: digest.FromString(title), Annotations: map[string]string{ocispec.AnnotationTitle: title}}
+	for key, value := range annotations {
+		layer.Annotations[key] = value
+	}
+	return layer
+}
+
+func layerTitles(layers []ocispec.Descriptor) []string {
+	titles := make([]string, len(layers))
+	for i, layer := range layers {
+		titles[i] = layer.Annotations[ocispec.AnnotationTitle]
+	}
+	return titles
+}
+
+func TestOrderLayers(t *testing.T) {
+	plain := []ocispec.Descriptor{orderTestLayer("b", nil), orderTestLayer("a", nil)}
+	ordered, err := orderLayers(plain)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, layerTitles(ordered), "unannotated layers keep manifest order")
+
+	base := orderTestLayer("base", nil)
+	layers := []ocispec.Descriptor{
+		orderTestLayer("config", map[string]string{AnnotationLayerDepends: "base, " + base.Digest.String()}),
+		orderTestLayer("overlay", map[string]string{AnnotationLayerDepends: "config"}),
+		orderTestLayer("docs", map[string]string{AnnotationLayerOrder: "5"}),
+		orderTestLayer("data", map[string]string{AnnotationLayerOrder: "-1"}),
+		base,
+	}
+	ordered, err = orderLayers(layers)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data", "base", "config", "overlay", "docs"}, layerTitles(ordered))
+
+	_, err = orderLayers([]ocispec.Descriptor{
+		orderTestLayer("a", map[string]string{AnnotationLayerDepends: "c"}),
+		orderTestLayer("b", map[string]string{AnnotationLayerDepends: "a"}),
+		orderTestLayer("c", map[string]string{AnnotationLayerDepends: "b"}),
+		orderTestLayer("d", nil),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cycle; layers a, b, c wait")
+
+	_, err = orderLayers([]ocispec.Descriptor{orderTestLayer("a", map[string]string{AnnotationLayerDepends: "missing"})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `depends on "missing"`)
+
+	_, err = orderLayers([]ocispec.Descriptor{orderTestLayer("a", map[string]string{AnnotationLayerOrder: "first"})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be an integer")
+}
@@ -0,0 +1,178 @@
+package porter
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// ociLayoutIndex returns the index.json of the OCI image layout at source, a
+// directory holding an oci-layout file or a .tar of one, such as oras and buildkit
+// write. It reports false when source is not a layout.
+func ociLayoutIndex(source string) (*ocispec.Index, bool, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, false, nil
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(source, ocispec.ImageLayoutFile)); err != nil {
+			return nil, false, nil
+		}
+		var index ocispec.Index
+		if err := readJSONFile(filepath.Join(source, ocispec.ImageIndexFile), &index); err != nil {
+			return nil, true, fmt.Errorf("failed to read OCI layout index: %w", err)
+		}
+		return &index, true, nil
+	}
+	if !strings.EqualFold(filepath.Ext(source), ".tar") {
+		return nil, false, nil
+	}
+	return tarLayoutIndex(source)
+}
+
+// tarLayoutIndex reads index.json from the tarball file, which is a layout
+// only when it also holds an oci-layout file.
+func tarLayoutIndex(file string) (*ocispec.Index, bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var index *ocispec.Index
+	layout := false
+	tarReader := tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Not a tarball; push it as a file.
+			return nil, false, nil
+		}
+		switch path.Clean(header.Name) {
+		case ocispec.ImageLayoutFile:
+			layout = true
+		case ocispec.ImageIndexFile:
+			index = &ocispec.Index{}
+			if err := json.NewDecoder(tarReader).Decode(index); err != nil {
+				return nil, true, fmt.Errorf("failed to read OCI layout index: %w", err)
+			}
+		}
+	}
+	if !layout {
+		return nil, false, nil
+	}
+	if index == nil {
+		return nil, true, fmt.Errorf("OCI layout %s has no %s", file, ocispec.ImageIndexFile)
+	}
+	return index, true, nil
+}
+
+// layoutRoot selects the manifest of index to push to ref: the one ref names by
+// digest, the one whose ref.name annotation is the tag of ref, or else the only
+// named manifest or the only manifest.
+func layoutRoot(index *ocispec.Index, ref reference.Reference) (ocispec.Descriptor, error) {
+	if len(index.Manifests) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("OCI layout index lists no manifests")
+	}
+	if ref.IsDigest() {
+		for _, desc := range index.Manifests {
+			if desc.Digest.String() == ref.Identifier() {
+				return desc, nil
+			}
+		}
+		return ocispec.Descriptor{}, fmt.Errorf("OCI layout has no manifest %s", ref.Identifier())
+	}
+	// Stores such as oras list every manifest they hold, but name only the roots.
+	var named []ocispec.Descriptor
+	var names []string
+	for _, desc := range index.Manifests {
+		name := desc.Annotations[ocispec.AnnotationRefName]
+		if name == "" {
+			continue
+		}
+		if name == ref.Identifier() || strings.HasSuffix(name, ":"+ref.Identifier()) {
+			return desc, nil
+		}
+		named = append(named, desc)
+		names = append(names, name)
+	}
+	switch {
+	case len(index.Manifests) == 1:
+		return index.Manifests[0], nil
+	case len(named) == 1:
+		return named[0], nil
+	case len(named) == 0:
+		return ocispec.Descriptor{}, fmt.Errorf("OCI layout lists %d unnamed manifests; push one by digest", len(index.Manifests))
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("OCI layout names %d manifests but none %q; push one of %s by tag or digest",
+		len(named), ref.Identifier(), strings.Join(names, ", "))
+}
+
+// pushOCILayout pushes the manifest of the OCI layout at source that ref selects,
+// with everything it references, verbatim: digests, annotations and media types
+// are kept as the tool that wrote the layout produced them.
+func (c *Client) pushOCILayout(ctx context.Context, source string, index *ocispec.Index, ref string, opts PushOptions) (*ArtifactResult, error) {
+	if opts.Sign.Enabled || len(opts.SBOMs) > 0 || opts.Compression != "" {
+		return nil, fmt.Errorf("an OCI layout is pushed as is and cannot be combined with --sign, --sbom or --compression")
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	root, err := layoutRoot(index, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	var store content.ReadOnlyStorage
+	if info, statErr := os.Stat(source); statErr == nil && info.IsDir() {
+		store, err = oci.NewFromFS(ctx, os.DirFS(source))
+	} else {
+		store, err = oci.NewFromTar(ctx, source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI layout %s: %w", source, err)
+	}
+
+	c.logger.Info("Pushing OCI layout", "path", source, "digest", root.Digest, "destination", reference.Redact(ref))
+	if err := c.pushGraph(ctx, store, root, ref, opts); err != nil {
+		return nil, fmt.Errorf("failed to push OCI layout: %w", err)
+	}
+	c.logger.Info("OCI layout pushed successfully", "reference", reference.Redact(ref), "digest", root.Digest.String())
+
+	artifactID := root.Digest.Encoded()
+	if len(artifactID) > 16 {
+		artifactID = artifactID[:16]
+	}
+	metadata := map[string]string{
+		"pushed.reference": ref,
+		"source.layout":    source,
+	}
+	recordPushTags(metadata, opts.Tags)
+	if root.ArtifactType != "" {
+		metadata["artifact.type"] = root.ArtifactType
+	}
+	return &ArtifactResult{
+		ID:        artifactID,
+		Reference: ref,
+		Digest:    root.Digest.String(),
+		Size:      root.Size,
+		Metadata:  metadata,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package porter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushOCILayoutVerbatim(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+	outDir := t.TempDir()
+	_, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{OCIArchive: true})
+	require.NoError(t, err)
+	target := newTestRegistry(t)
+
+	sources := map[string]string{
+		"layout":  result.LocalPath,
+		"tarball": filepath.Join(outDir, "tool"+OCIArchiveExt),
+	}
+	for name, source := range sources {
+		ref := target.Host() + "/mirror/tool:" + name
+		pushed, err := client.PushArtifact(context.Background(), source, ref, PushOptions{Insecure: true})
+		require.NoError(t, err, name)
+		assert.Equal(t, result.Digest, pushed.Digest, "%s keeps its digest", name)
+
+		pulled, err := client.PullArtifact(context.Background(), ref, true)
+		require.NoError(t, err, name)
+		assert.Equal(t, result.Digest, pulled.Digest)
+	}
+
+	_, err = client.PushArtifact(context.Background(), result.LocalPath, target.Host()+"/mirror/tool:signed", PushOptions{Insecure: true, Compression: "zstd"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pushed as is")
+}
+
+func TestLayoutRootSelectsByName(t *testing.T) {
+	index := &ocispec.Index{Manifests: []ocispec.Descriptor{
+		{Digest: "sha256:aaaa", Annotations: map[string]string{ocispec.AnnotationRefName: "1.0.0"}},
+		{Digest: "sha256:bbbb", Annotations: map[string]string{ocispec.AnnotationRefName: "example.com/app:2.0.0"}},
+	}}
+
+	root, err := layoutRoot(index, reference.MustParse("registry.example.com/app:2.0.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:bbbb", root.Digest.String())
+
+	_, err = layoutRoot(index, reference.MustParse("registry.example.com/app:3.0.0"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1.0.0, example.com/app:2.0.0")
+}
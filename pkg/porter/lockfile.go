@@ -0,0 +1,157 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLockfile is the lockfile used when no path is given.
+const DefaultLockfile = "porter.lock"
+
+// lockfileVersion is the schema version written to new lockfiles.
+const lockfileVersion = 1
+
+// Lockfile records the digests tagged references resolved to so later pulls can
+// detect upstream retags.
+type Lockfile struct {
+	Version   int                  `yaml:"version"`
+	Artifacts map[string]LockEntry `yaml:"artifacts"`
+}
+
+// LockEntry is the locked digest of a single reference.
+type LockEntry struct {
+	Digest   string    `yaml:"digest"`
+	LockedAt time.Time `yaml:"locked_at"`
+}
+
+// LockMismatchError reports that a reference no longer resolves to its locked digest.
+type LockMismatchError struct {
+	Reference string
+	Locked    string
+	Actual    string
+}
+
+func (e *LockMismatchError) Error() string {
+	return fmt.Sprintf("%s resolves to %s but is locked to %s", e.Reference, e.Actual, e.Locked)
+}
+
+// LoadLockfile reads a lockfile. A missing file yields an empty lockfile.
+func LoadLockfile(path string) (*Lockfile, error) {
+	lock := &Lockfile{Version: lockfileVersion, Artifacts: map[string]LockEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	if lock.Version > lockfileVersion {
+		return nil, fmt.Errorf("lockfile %s has unsupported version %d", path, lock.Version)
+	}
+	if lock.Artifacts == nil {
+		lock.Artifacts = map[string]LockEntry{}
+	}
+	return lock, nil
+}
+
+// Save writes the lockfile atomically.
+func (l *Lockfile) Save(path string) error {
+	l.Version = lockfileVersion
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".porter-lock-*")
+	if err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Record stores the digest ref resolved to.
+func (l *Lockfile) Record(ref, digest string) {
+	l.Artifacts[strings.TrimSpace(ref)] = LockEntry{Digest: digest, LockedAt: time.Now().UTC()}
+}
+
+// Lookup returns the locked entry for ref.
+func (l *Lockfile) Lookup(ref string) (LockEntry, bool) {
+	entry, ok := l.Artifacts[strings.TrimSpace(ref)]
+	return entry, ok
+}
+
+// References returns the locked references in sorted order.
+func (l *Lockfile) References() []string {
+	refs := make([]string, 0, len(l.Artifacts))
+	for ref := range l.Artifacts {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Verify checks that digest matches the entry locked for ref.
+func (l *Lockfile) Verify(ref, digest string) error {
+	entry, ok := l.Lookup(ref)
+	if !ok {
+		return fmt.Errorf("%s is not locked; run `ds porter lock %s` first", ref, ref)
+	}
+	if entry.Digest != digest {
+		return &LockMismatchError{Reference: ref, Locked: entry.Digest, Actual: digest}
+	}
+	return nil
+}
+
+// Pin returns ref pinned to its locked digest, so a pull fetches exactly the
+// locked content even when the tag moves while the pull runs.
+func (l *Lockfile) Pin(ref string) (string, error) {
+	entry, ok := l.Lookup(ref)
+	if !ok {
+		return "", fmt.Errorf("%s is not locked; run `ds porter lock %s` first", ref, ref)
+	}
+	ref = strings.TrimSpace(ref)
+	if strings.Contains(ref, "@") {
+		return ref, nil
+	}
+	return ref + "@" + entry.Digest, nil
+}
+
+// ResolveDigest resolves ref against its registry without downloading content.
+func (c *Client) ResolveDigest(ctx context.Context, ref string, insecure bool) (string, error) {
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return "", fmt.Errorf("invalid reference: %w", err)
+	}
+
+	_, desc, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
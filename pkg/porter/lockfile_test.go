@@ -0,0 +1,93 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultLockfile)
+
+	lock, err := LoadLockfile(path)
+	require.NoError(t, err)
+	assert.Empty(t, lock.References())
+
+	lock.Record("ghcr.io/team/app:0.2.0", "sha256:aaa")
+	lock.Record("ghcr.io/team/tool:1.0.0", "sha256:bbb")
+	require.NoError(t, lock.Save(path))
+
+	loaded, err := LoadLockfile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ghcr.io/team/app:0.2.0", "ghcr.io/team/tool:1.0.0"}, loaded.References())
+
+	entry, ok := loaded.Lookup("ghcr.io/team/app:0.2.0")
+	require.True(t, ok)
+	assert.Equal(t, "sha256:aaa", entry.Digest)
+	assert.False(t, entry.LockedAt.IsZero())
+}
+
+func TestLockfileVerify(t *testing.T) {
+	lock, err := LoadLockfile(filepath.Join(t.TempDir(), DefaultLockfile))
+	require.NoError(t, err)
+	lock.Record("ghcr.io/team/app:0.2.0", "sha256:aaa")
+
+	assert.NoError(t, lock.Verify("ghcr.io/team/app:0.2.0", "sha256:aaa"))
+	assert.ErrorContains(t, lock.Verify("ghcr.io/team/other:1.0", "sha256:aaa"), "not locked")
+
+	err = lock.Verify("ghcr.io/team/app:0.2.0", "sha256:ccc")
+	var mismatch *LockMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "sha256:aaa", mismatch.Locked)
+	assert.Equal(t, "sha256:ccc", mismatch.Actual)
+}
+
+func TestResolveDigestDetectsRetag(t *testing.T) {
+	registry := newTestRegistry(t)
+	first := registry.AddArtifact("team/app", "0.2.0", "application/octet-stream", []byte("v1"), nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/app:0.2.0"
+	digest, err := client.ResolveDigest(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest.String(), digest)
+
+	lock, err := LoadLockfile(filepath.Join(t.TempDir(), DefaultLockfile))
+	require.NoError(t, err)
+	lock.Record(ref, digest)
+
+	registry.AddArtifact("team/app", "0.2.0", "application/octet-stream", []byte("v2"), nil)
+	retagged, err := client.ResolveDigest(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.Error(t, lock.Verify(ref, retagged))
+}
+
+func TestLockfilePinPullsLockedContent(t *testing.T) {
+	registry := newTestRegistry(t)
+	first := registry.AddArtifact("team/app", "0.2.0", "application/octet-stream", []byte("v1"), nil)
+
+	lock, err := LoadLockfile(filepath.Join(t.TempDir(), DefaultLockfile))
+	require.NoError(t, err)
+	ref := registry.Host() + "/team/app:0.2.0"
+	lock.Record(ref, first.Digest.String())
+	_, err = lock.Pin(registry.Host() + "/team/other:1.0")
+	assert.ErrorContains(t, err, "not locked")
+
+	pinned, err := lock.Pin(ref)
+	require.NoError(t, err)
+	assert.Equal(t, ref+"@"+first.Digest.String(), pinned)
+
+	// The tag moves after it was resolved; the pinned pull never sees the new content.
+	retagged := registry.AddArtifact("team/app", "0.2.0", "application/octet-stream", []byte("v2"), nil)
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), pinned, true)
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest.String(), result.Digest)
+	for _, request := range registry.Requests() {
+		assert.NotContains(t, request, retagged.Digest.Encoded())
+	}
+}
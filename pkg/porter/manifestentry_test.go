@@ -0,0 +1,321 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushAppliesEntryAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-darwin"), []byte("darwin tool"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-linux"), []byte("linux tool"), 0o755))
+	manifestPath := filepath.Join(dir, DefaultManifestFile)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`artifact-type: application/vnd.delivery-station.artifact.index.v1+json
+annotations:
+  name: tool
+manifests:
+  - platform: darwin/arm64
+    path: tool-darwin
+    annotations:
+      com.example.min-os-version: "13.0"
+  - platform: linux/amd64
+    path: tool-linux
+    title: tool
+`), 0o644))
+
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	_, err := client.PushArtifact(context.Background(), manifestPath, registry.Host()+"/team/tool:1.0.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(registry.manifests[registry.tags["team/tool"]["1.0.0"]].data, &index))
+	require.Len(t, index.Manifests, 2)
+	for _, desc := range index.Manifests {
+		var manifest ocispec.Manifest
+		require.NoError(t, json.Unmarshal(registry.manifests[desc.Digest.String()].data, &manifest))
+		require.Len(t, manifest.Layers, 1)
+		// The config carries the platform for tools that read it from there, but
+		// is no image config, which would need a diff ID per layer.
+		assert.Equal(t, release.MediaTypeArtifactConfig, manifest.Config.MediaType)
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(registry.blobs[manifest.Config.Digest.String()], &config))
+		assert.Equal(t, desc.Platform.OS, config["os"])
+		assert.Equal(t, desc.Platform.Architecture, config["architecture"])
+		assert.NotContains(t, config, "rootfs")
+		// The index entry carries the platform in its platform field only.
+		assert.NotContains(t, desc.Annotations, "os")
+		assert.NotContains(t, desc.Annotations, "architecture")
+		if desc.Platform.OS == "darwin" {
+			assert.Equal(t, "13.0", manifest.Annotations["com.example.min-os-version"])
+			assert.Equal(t, "13.0", manifest.Layers[0].Annotations["com.example.min-os-version"])
+			assert.Equal(t, "tool-darwin", manifest.Layers[0].Annotations[ocispec.AnnotationTitle])
+		} else {
+			assert.Equal(t, "tool", manifest.Layers[0].Annotations[ocispec.AnnotationTitle])
+			assert.NotContains(t, manifest.Annotations, "com.example.min-os-version")
+			assert.NotContains(t, manifest.Layers[0].Annotations, "com.example.min-os-version")
+		}
+	}
+}
+
+func TestPushPacksEntryConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := []byte(`{"entrypoint":["tool","serve"]}`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("linux tool"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.json"), config, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o644))
+	write := func(configFile string) string {
+		path := filepath.Join(dir, DefaultManifestFile)
+		require.NoError(t, os.WriteFile(path, []byte(`manifests:
+  - platform: linux/amd64
+    path: tool
+    config: `+configFile+`
+    configMediaType: application/vnd.example.plugin.config.v1+json
+`), 0o644))
+		return path
+	}
+
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	_, err := client.PushArtifact(context.Background(), write("plugin.json"), registry.Host()+"/team/tool:1.0.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+
+	registry.mu.Lock()
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(registry.manifests[registry.tags["team/tool"]["1.0.0"]].data, &index))
+	require.Len(t, index.Manifests, 1)
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[index.Manifests[0].Digest.String()].data, &manifest))
+	assert.Equal(t, "application/vnd.example.plugin.config.v1+json", manifest.Config.MediaType)
+	assert.Empty(t, manifest.Config.Annotations)
+	assert.Equal(t, config, registry.blobs[manifest.Config.Digest.String()])
+	registry.mu.Unlock()
+
+	_, err = client.PushArtifact(context.Background(), write("broken.json"), registry.Host()+"/team/tool:1.0.1", PushOptions{Insecure: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not valid JSON")
+}
+
+func TestPushAllSkipsExistingBlobs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, []byte("linux tool"), 0o755))
+	registry := newTestRegistry(t)
+	pusher, err := release.NewPusher(release.ReleaseConfig{Reference: registry.Host() + "/team/tool:1.0.0", Insecure: true})
+	require.NoError(t, err)
+	linux := release.Platform{OS: "linux", Arch: "amd64"}
+
+	var first, second, third bytes.Buffer
+	_, err = pusher.PushAll(context.Background(), map[release.Platform]release.ManifestEntry{linux: {Platform: "linux/amd64", Path: file}}, &first)
+	require.NoError(t, err)
+	assert.NotContains(t, first.String(), "already exists")
+	uploads := len(registry.Requests())
+
+	// A fixed creation time makes the rerun manifests identical to each other, but
+	// not to the first.
+	rerun := map[release.Platform]release.ManifestEntry{linux: {
+		Platform: "linux/amd64", Path: file,
+		Annotations: map[string]string{ocispec.AnnotationCreated: "2026-01-01T00:00:00Z"},
+	}}
+	_, err = pusher.PushAll(context.Background(), rerun, &second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(second.String(), "already exists"), "config and layer: %s", second.String())
+	for _, request := range registry.Requests()[uploads:] {
+		assert.NotContains(t, request, "/blobs/uploads/", "the rerun uploads no blob")
+	}
+
+	descriptors, err := pusher.PushAll(context.Background(), rerun, &third)
+	require.NoError(t, err)
+	assert.Contains(t, third.String(), descriptors[linux].Digest.String()+" already exists")
+	assert.Equal(t, 1, strings.Count(third.String(), "already exists"))
+}
+
+func TestPushAllUploadsLargeBlobsInChunks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, content, 0o755))
+	registry := newTestRegistry(t)
+	// The first chunk reaches the registry only halfway and is resumed.
+	registry.failPatches = 1
+	pusher, err := release.NewPusher(release.ReleaseConfig{
+		Reference: registry.Host() + "/team/tool:1.0.0",
+		Insecure:  true,
+		ChunkSize: 300,
+	})
+	require.NoError(t, err)
+	linux := release.Platform{OS: "linux", Arch: "amd64"}
+
+	_, err = pusher.PushAll(context.Background(), map[release.Platform]release.ManifestEntry{linux: {Platform: "linux/amd64", Path: file}}, io.Discard)
+	require.NoError(t, err)
+
+	patches, statuses := 0, 0
+	for _, request := range registry.Requests() {
+		switch {
+		case strings.HasPrefix(request, "PATCH "):
+			patches++
+		case strings.HasPrefix(request, "GET ") && strings.Contains(request, "/blobs/uploads/"):
+			statuses++
+		}
+	}
+	// Four chunks of at most 300 bytes, plus the failed first one and the retry of
+	// it from the start, which the registry refuses for its Content-Range.
+	assert.Equal(t, 6, patches)
+	assert.Equal(t, 1, statuses)
+	registry.mu.Lock()
+	assert.Equal(t, content, registry.blobs[digest.FromBytes(content).String()])
+	registry.mu.Unlock()
+}
+
+func TestPushPacksMetadataConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("linux tool"), 0o755))
+	manifestPath := filepath.Join(dir, DefaultManifestFile)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`metadata:
+  version: 1.0.0
+  commit: abc123
+  plugin:
+    description: A tool
+manifests:
+  - platform: linux/amd64
+    path: tool
+`), 0o644))
+
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	opts := PushOptions{Insecure: true, Metadata: &release.ArtifactMetadata{Commit: "def456", Created: "2024-05-01T12:00:00Z"}}
+	_, err := client.PushArtifact(context.Background(), manifestPath, registry.Host()+"/team/tool:1.0.0", opts)
+	require.NoError(t, err)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(registry.manifests[registry.tags["team/tool"]["1.0.0"]].data, &index))
+	require.Len(t, index.Manifests, 1)
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[index.Manifests[0].Digest.String()].data, &manifest))
+	var config struct {
+		ocispec.Image
+		Metadata release.ArtifactMetadata `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(registry.blobs[manifest.Config.Digest.String()], &config))
+	assert.Equal(t, "linux", config.OS)
+	assert.Equal(t, release.ArtifactMetadata{
+		Version: "1.0.0",
+		Commit:  "def456",
+		Created: "2024-05-01T12:00:00Z",
+		Plugin:  map[string]string{"description": "A tool"},
+	}, config.Metadata)
+	assert.Equal(t, "def456", config.Config.Labels[ocispec.AnnotationRevision])
+
+	opts.Metadata.Created = "yesterday"
+	_, err = client.PushArtifact(context.Background(), manifestPath, registry.Host()+"/team/tool:1.0.1", opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RFC 3339")
+}
+
+func TestPushSameFileYieldsSameIndex(t *testing.T) {
+	manifestPath := writeTestManifest(t)
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+
+	first, err := client.PushArtifact(context.Background(), manifestPath, registry.Host()+"/team/tool:1.0.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+	time.Sleep(time.Second)
+	second, err := client.PushArtifact(context.Background(), manifestPath, registry.Host()+"/team/tool:1.0.1", PushOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest, second.Digest, "a push must not stamp the time it ran")
+}
+
+func TestPushAllRerunSkipsPushedPlatforms(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-linux"), []byte("linux tool"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-darwin"), []byte("darwin tool"), 0o755))
+	built := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "tool-linux"), built, built))
+	registry := newTestRegistry(t)
+	pusher, err := release.NewPusher(release.ReleaseConfig{Reference: registry.Host() + "/team/tool:1.0.0", Insecure: true})
+	require.NoError(t, err)
+	linux := release.Platform{OS: "linux", Arch: "amd64"}
+	darwin := release.Platform{OS: "darwin", Arch: "arm64"}
+
+	// The first run stops after linux; the rerun pushes both.
+	first, err := pusher.PushAll(context.Background(), map[release.Platform]release.ManifestEntry{
+		linux: {Platform: "linux/amd64", Path: filepath.Join(dir, "tool-linux")},
+	}, io.Discard)
+	require.NoError(t, err)
+	// The manifest is dated by its content, not by the push, so a rerun packs it
+	// again with the same digest.
+	registry.mu.Lock()
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[first[linux].Digest.String()].data, &manifest))
+	registry.mu.Unlock()
+	assert.Equal(t, "2026-03-01T12:00:00Z", manifest.Annotations[ocispec.AnnotationCreated])
+
+	var progress bytes.Buffer
+	rerun, err := pusher.PushAll(context.Background(), map[release.Platform]release.ManifestEntry{
+		linux:  {Platform: "linux/amd64", Path: filepath.Join(dir, "tool-linux")},
+		darwin: {Platform: "darwin/arm64", Path: filepath.Join(dir, "tool-darwin")},
+	}, &progress)
+	require.NoError(t, err)
+	assert.Equal(t, first[linux].Digest, rerun[linux].Digest)
+	assert.Contains(t, progress.String(), first[linux].Digest.String()+" already exists")
+	assert.NotContains(t, progress.String(), rerun[darwin].Digest.String()+" already exists")
+}
+
+func TestPushAllResumesUploadSessionOfEarlierRun(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, content, 0o755))
+	registry := newTestRegistry(t)
+	// Every attempt at the first chunk reaches the registry only halfway, so the
+	// first run gives up part way through the blob.
+	registry.failPatches = 3
+	sessions := t.TempDir()
+	pusher, err := release.NewPusher(release.ReleaseConfig{
+		Reference:         registry.Host() + "/team/tool:1.0.0",
+		Insecure:          true,
+		ChunkSize:         300,
+		UploadSessionsDir: sessions,
+	})
+	require.NoError(t, err)
+	entries := map[release.Platform]release.ManifestEntry{{OS: "linux", Arch: "amd64"}: {Platform: "linux/amd64", Path: file}}
+
+	_, err = pusher.PushAll(context.Background(), entries, io.Discard)
+	require.Error(t, err)
+	registry.mu.Lock()
+	var session string
+	for id, buf := range registry.uploads {
+		if buf.Len() > 0 {
+			session = id
+		}
+	}
+	registry.mu.Unlock()
+	require.NotEmpty(t, session, "the registry holds part of the blob")
+	recorded, err := os.ReadDir(sessions)
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+
+	_, err = pusher.PushAll(context.Background(), entries, io.Discard)
+	require.NoError(t, err)
+	assert.Contains(t, registry.Requests(), "PUT /v2/team/tool/blobs/uploads/"+session, "the rerun completes the session of the first run")
+	registry.mu.Lock()
+	assert.Equal(t, content, registry.blobs[digest.FromBytes(content).String()])
+	registry.mu.Unlock()
+	recorded, err = os.ReadDir(sessions)
+	require.NoError(t, err)
+	assert.Empty(t, recorded)
+}
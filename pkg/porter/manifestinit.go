@@ -0,0 +1,261 @@
+package porter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestPlaceholder is the value manifest init gives annotations it cannot
+// detect, to be replaced before the manifest is pushed.
+const ManifestPlaceholder = "TODO"
+
+// ManifestInitOptions controls InitManifest.
+type ManifestInitOptions struct {
+	// Output is the manifest file to write; entry paths are relative to its
+	// directory. Empty writes ds.manifest.yaml in the current directory.
+	Output string
+	// Name is the name annotation; empty uses the detected binary name.
+	Name string
+	// ArtifactType is the artifact-type of the manifest; empty uses
+	// release.MediaTypeArtifactIndex.
+	ArtifactType string
+	// Force overwrites an existing manifest.
+	Force bool
+}
+
+// ManifestInit reports what InitManifest generated.
+type ManifestInit struct {
+	Path      string                  `json:"path"`
+	Manifests []release.ManifestEntry `json:"manifests"`
+	// Skipped lists the files whose path names no platform.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// DefaultManifestFile is the manifest file name pushes and manifest init use.
+const DefaultManifestFile = "ds.manifest.yaml"
+
+// InitManifest scans dir, a build output such as goreleaser's dist/, and writes a
+// ds.manifest.yaml with one entry per platform it finds. A file belongs to the
+// platform its path names, as in dist/porter_linux_amd64/porter or
+// bin/darwin/arm64/porter. A platform with a single executable gets that binary;
+// one with several files gets the directory that holds them, archived at push.
+// Annotations that cannot be detected are set to ManifestPlaceholder.
+func InitManifest(dir string, opts ManifestInitOptions) (*ManifestInit, error) {
+	output := opts.Output
+	if output == "" {
+		output = DefaultManifestFile
+	}
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve manifest path: %w", err)
+	}
+	if _, err := os.Stat(output); err == nil && !opts.Force {
+		return nil, fmt.Errorf("%s already exists; use --force to overwrite it", output)
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve build directory: %w", err)
+	}
+
+	result := &ManifestInit{Path: output}
+	files := make(map[release.Platform][]string)
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || path == output {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		platform, ok := release.PathPlatform(filepath.ToSlash(rel))
+		if !ok {
+			result.Skipped = append(result.Skipped, filepath.ToSlash(rel))
+			return nil
+		}
+		files[platform] = append(files[platform], path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file under %s names a platform such as linux_amd64 or darwin/arm64", dir)
+	}
+
+	manifest := &release.Manifest{ArtifactType: opts.ArtifactType}
+	if manifest.ArtifactType == "" {
+		manifest.ArtifactType = release.MediaTypeArtifactIndex
+	}
+	var binaries []string
+	for platform, paths := range files {
+		entry, err := platformEntry(root, platform, paths)
+		if err != nil {
+			return nil, err
+		}
+		if entry.MediaType == release.MediaTypeArtifactBinary {
+			binaries = append(binaries, entry.Path)
+		}
+		if entry.Path, err = filepath.Rel(filepath.Dir(output), entry.Path); err != nil {
+			return nil, fmt.Errorf("failed to relate %s to the manifest: %w", entry.Path, err)
+		}
+		entry.Path = filepath.ToSlash(entry.Path)
+		manifest.Manifests = append(manifest.Manifests, entry)
+	}
+	sort.Slice(manifest.Manifests, func(i, j int) bool {
+		return manifest.Manifests[i].Platform < manifest.Manifests[j].Platform
+	})
+	manifest.Annotations = map[string]string{
+		"name":                            manifestName(opts.Name, binaries, root),
+		"version":                         ManifestPlaceholder,
+		"description":                     ManifestPlaceholder,
+		"url":                             ManifestPlaceholder,
+		"org.opencontainers.image.source": ManifestPlaceholder,
+		"vendor":                          ManifestPlaceholder,
+		"license":                         ManifestPlaceholder,
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by 'ds porter manifest init' from %s.\n", filepath.ToSlash(dir))
+	fmt.Fprintf(&buf, "# Replace the %s annotations before pushing.\n", ManifestPlaceholder)
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	result.Manifests = manifest.Manifests
+	return result, nil
+}
+
+// platformEntry picks what to push for platform from the files under root naming
+// it: the only executable, the only file, or else the directory holding them all,
+// provided its own path names the platform.
+func platformEntry(root string, platform release.Platform, paths []string) (release.ManifestEntry, error) {
+	entry := release.ManifestEntry{Platform: platform.FormatString()}
+	var executables []string
+	for _, path := range paths {
+		if isExecutableFile(path) {
+			executables = append(executables, path)
+		}
+	}
+	switch {
+	case len(executables) == 1:
+		entry.Path = executables[0]
+		entry.MediaType = release.MediaTypeArtifactBinary
+		return entry, nil
+	case len(paths) == 1:
+		entry.Path = paths[0]
+		entry.MediaType = fileMediaType(paths[0])
+		return entry, nil
+	}
+	dir := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		for !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			dir = filepath.Dir(dir)
+		}
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return entry, err
+	}
+	if named, ok := release.PathPlatform(filepath.ToSlash(rel)); !ok || named != platform {
+		return entry, fmt.Errorf("found several files for %s outside a directory of their own: %s; move them into one or write the entry by hand",
+			entry.Platform, strings.Join(paths, ", "))
+	}
+	// Directories are archived at push time, with the configured compression.
+	entry.Path = dir
+	return entry, nil
+}
+
+// manifestName is the name annotation: name, the binary name shared by the
+// detected binaries, or the name of the build directory.
+func manifestName(name string, binaries []string, root string) string {
+	if name != "" {
+		return name
+	}
+	detected := ""
+	for _, binary := range binaries {
+		base := strings.TrimSuffix(filepath.Base(binary), ".exe")
+		if detected != "" && base != detected {
+			detected = ""
+			break
+		}
+		detected = base
+	}
+	if detected != "" {
+		return detected
+	}
+	return filepath.Base(root)
+}
+
+// executableMagic are the leading bytes of ELF, PE and Mach-O executables.
+var executableMagic = [][]byte{
+	{0x7f, 'E', 'L', 'F'},
+	{'M', 'Z'},
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+}
+
+// isExecutableFile reports whether path starts like a native executable.
+func isExecutableFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false
+	}
+	for _, magic := range executableMagic {
+		if n >= len(magic) && bytes.Equal(head[:len(magic)], magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileMediaType guesses the media type of a file pushed as is from its name.
+func fileMediaType(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return release.MediaTypeArtifactArchive
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tar.zstd"):
+		return release.MediaTypeArtifactArchiveZstd
+	case strings.HasSuffix(name, ".zip"):
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,71 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var elfHeader = []byte{0x7f, 'E', 'L', 'F', 2, 1, 1}
+
+func writeBuildFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, data, 0755))
+}
+
+func TestInitManifestFromGoreleaserDist(t *testing.T) {
+	root := t.TempDir()
+	dist := filepath.Join(root, "dist")
+	writeBuildFile(t, filepath.Join(dist, "porter_linux_amd64_v1", "porter"), elfHeader)
+	writeBuildFile(t, filepath.Join(dist, "porter_linux_arm_7", "porter"), elfHeader)
+	writeBuildFile(t, filepath.Join(dist, "porter_darwin_arm64", "porter"), []byte{0xcf, 0xfa, 0xed, 0xfe})
+	writeBuildFile(t, filepath.Join(dist, "porter_windows_amd64_v1", "porter.exe"), []byte("MZ\x90\x00"))
+	writeBuildFile(t, filepath.Join(dist, "porter_1.0.0_linux_amd64.tar.gz"), []byte("archive"))
+	writeBuildFile(t, filepath.Join(dist, "checksums.txt"), []byte("sums"))
+
+	output := filepath.Join(root, DefaultManifestFile)
+	result, err := InitManifest(dist, ManifestInitOptions{Output: output})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"checksums.txt"}, result.Skipped)
+
+	manifest, err := release.LoadManifest(output)
+	require.NoError(t, err)
+	assert.Equal(t, release.MediaTypeArtifactIndex, manifest.ArtifactType)
+	assert.Equal(t, "porter", manifest.Annotations["name"])
+	assert.Equal(t, ManifestPlaceholder, manifest.Annotations["version"])
+	assert.Equal(t, []release.ManifestEntry{
+		{Platform: "darwin/arm64", MediaType: release.MediaTypeArtifactBinary, Path: "dist/porter_darwin_arm64/porter"},
+		{Platform: "linux/amd64", MediaType: release.MediaTypeArtifactBinary, Path: "dist/porter_linux_amd64_v1/porter"},
+		{Platform: "linux/arm/v7", MediaType: release.MediaTypeArtifactBinary, Path: "dist/porter_linux_arm_7/porter"},
+		{Platform: "windows/amd64", MediaType: release.MediaTypeArtifactBinary, Path: "dist/porter_windows_amd64_v1/porter.exe"},
+	}, manifest.Manifests)
+
+	_, err = InitManifest(dist, ManifestInitOptions{Output: output})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+}
+
+func TestInitManifestArchivesPlatformDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeBuildFile(t, filepath.Join(root, "bin", "linux", "amd64", "tool"), elfHeader)
+	writeBuildFile(t, filepath.Join(root, "bin", "linux", "amd64", "helper"), elfHeader)
+	writeBuildFile(t, filepath.Join(root, "bin", "linux", "amd64", "README.md"), []byte("docs"))
+
+	output := filepath.Join(root, DefaultManifestFile)
+	result, err := InitManifest(filepath.Join(root, "bin"), ManifestInitOptions{Output: output, Name: "tools"})
+	require.NoError(t, err)
+	require.Len(t, result.Manifests, 1)
+	assert.Equal(t, release.ManifestEntry{Platform: "linux/amd64", Path: "bin/linux/amd64"}, result.Manifests[0])
+
+	// The generated manifest pushes as written.
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	_, err = client.PushArtifact(context.Background(), output, registry.Host()+"/team/tools:1.0.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+}
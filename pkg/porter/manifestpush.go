@@ -0,0 +1,77 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/delivery-station/porter/pkg/release"
+)
+
+// PushManifest pushes the platforms a ds.manifest.yaml lists as one multi-arch
+// index at ref, writing each step of the push to progress as text. The reference
+// is parsed like every other push, so its registry, credentials and HTTP client
+// agree with where the index is pushed.
+func (c *Client) PushManifest(ctx context.Context, manifestPath, ref string, progress io.Writer, opts PushOptions) (*ArtifactResult, error) {
+	return c.push(ctx, ref, func(ctx context.Context) (*ArtifactResult, error) {
+		return c.pushManifest(ctx, manifestPath, ref, progress, opts)
+	})
+}
+
+func (c *Client) pushManifest(ctx context.Context, manifestPath, ref string, progress io.Writer, opts PushOptions) (*ArtifactResult, error) {
+	if err := c.CheckNaming(ref); err != nil {
+		return nil, err
+	}
+	if err := c.CheckPushTags(ref, opts.Tags); err != nil {
+		return nil, err
+	}
+	opts, err := c.ApplySemverAliases(ctx, ref, opts)
+	if err != nil {
+		return nil, err
+	}
+	parsedRef, err := c.parseReference(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+
+	registry := parsedRef.Context().RegistryStr()
+	username, password := c.resolveCredentials(registry)
+	pusher, err := release.NewPusher(release.ReleaseConfig{
+		Reference:    reference.ExpandAlias(strings.TrimSpace(ref), c.config.Aliases),
+		Username:     username,
+		Password:     password,
+		HTTPClient:   c.httpClient(registry),
+		ManifestPath: manifestPath,
+		TagLatest:    c.TagLatest(opts),
+		Tags:         opts.Tags,
+		Insecure:     opts.Insecure,
+		Sign:         opts.Sign,
+		SBOMs:        opts.SBOMs,
+		Compression:  opts.Compression,
+		Staging:      c.staging,
+		ChunkSize:    c.chunkSize,
+		Metadata:     opts.Metadata,
+
+		UploadSessionsDir: c.UploadSessionsDir(),
+		TracerProvider:    c.traceProvider(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pusher: %w", err)
+	}
+
+	if err := pusher.Push(ctx, progress); err != nil {
+		return nil, err
+	}
+	index, err := pusher.ResolveIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactResult{
+		ID:        index.Digest.Encoded()[:16],
+		Reference: parsedRef.String(),
+		Digest:    index.Digest.String(),
+		Size:      index.Size,
+	}, nil
+}
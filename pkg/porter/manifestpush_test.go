@@ -0,0 +1,49 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestManifest writes a ds.manifest.yaml listing one linux/amd64 binary and
+// returns its path.
+func writeTestManifest(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("linux tool"), 0o755))
+	manifestPath := filepath.Join(dir, DefaultManifestFile)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`manifests:
+  - platform: linux/amd64
+    path: tool
+`), 0o644))
+	return manifestPath
+}
+
+func TestPushManifestResolvesAliases(t *testing.T) {
+	manifestPath := writeTestManifest(t)
+	registry := newTestRegistry(t)
+	client, err := NewClient(&Config{
+		CacheDir:        t.TempDir(),
+		CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+		Aliases:         map[string]string{"tools": registry.Host() + "/team"},
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	// An alias is not a Docker Hub namespace: the index lands in the aliased registry.
+	var progress bytes.Buffer
+	result, err := client.PushManifest(context.Background(), manifestPath, "tools/tool:1.0.0", &progress, PushOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Contains(t, progress.String(), "✓ Pushed to")
+	assert.Equal(t, registry.Host()+"/team/tool:1.0.0", result.Reference)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	assert.Equal(t, result.Digest, registry.tags["team/tool"]["1.0.0"])
+}
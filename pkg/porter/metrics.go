@@ -0,0 +1,335 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/delivery-station/porter/internal/progress"
+)
+
+// MetricsFile accumulates the metrics of every pull and push in the cache directory.
+const MetricsFile = "metrics.json"
+
+const metricsVersion = 1
+
+// metricsLock serializes updates of MetricsFile between processes.
+const metricsLock = "metrics"
+
+// Outcomes of an operation in the metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the operation duration
+// histogram.
+var durationBuckets = []float64{0.5, 1, 5, 15, 60, 300, 900}
+
+// OperationMetrics summarizes what one pull or push transferred and how long it
+// took. BytesReused counts blobs that were already in the cache or the registry
+// and were not transferred again.
+type OperationMetrics struct {
+	DurationSeconds  float64 `json:"duration_seconds"`
+	BytesTransferred int64   `json:"bytes_transferred"`
+	BytesReused      int64   `json:"bytes_reused"`
+	Blobs            int     `json:"blobs"`
+	BlobsReused      int     `json:"blobs_reused"`
+	Requests         int     `json:"requests"`
+	Retries          int     `json:"retries"`
+	CacheHit         bool    `json:"cache_hit"`
+}
+
+// operationRecorder counts the registry requests of one operation and how often
+// they were retried. It travels in the operation's context.
+type operationRecorder struct {
+	start time.Time
+
+	mu       sync.Mutex
+	seen     map[*http.Request]struct{}
+	requests int
+	retries  int
+	limit    *RateLimit
+}
+
+type operationRecorderKey struct{}
+
+// withOperationRecorder returns ctx carrying a new recorder started now.
+func withOperationRecorder(ctx context.Context) (context.Context, *operationRecorder) {
+	recorder := &operationRecorder{start: time.Now(), seen: make(map[*http.Request]struct{})}
+	return context.WithValue(ctx, operationRecorderKey{}, recorder), recorder
+}
+
+// operationRecorderFrom returns the recorder of ctx, or nil outside a recorded
+// operation; a nil recorder ignores everything.
+func operationRecorderFrom(ctx context.Context) *operationRecorder {
+	recorder, _ := ctx.Value(operationRecorderKey{}).(*operationRecorder)
+	return recorder
+}
+
+// request counts req, or a retry when the same request was sent before.
+func (r *operationRecorder) request(req *http.Request) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[req]; ok {
+		r.retries++
+		return
+	}
+	r.seen[req] = struct{}{}
+	r.requests++
+}
+
+// retry counts a retry that resends a request rather than repeating it, such as
+// resuming an interrupted blob download.
+func (r *operationRecorder) retry() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.retries++
+	r.mu.Unlock()
+}
+
+// finish summarizes the operation from its transfer progress.
+func (r *operationRecorder) finish(snapshot progress.Snapshot, cacheHit bool) *OperationMetrics {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &OperationMetrics{
+		DurationSeconds:  time.Since(r.start).Seconds(),
+		BytesTransferred: snapshot.Bytes - snapshot.SkippedBytes,
+		BytesReused:      snapshot.SkippedBytes,
+		Blobs:            snapshot.Blobs,
+		BlobsReused:      snapshot.SkippedBlobs,
+		Requests:         r.requests,
+		Retries:          r.retries,
+		CacheHit:         cacheHit,
+	}
+}
+
+// metricsTransport counts every registry request, and every retry of one, in the
+// recorder of the request's operation.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operationRecorderFrom(req.Context()).request(req)
+	return t.base.RoundTrip(req)
+}
+
+// operationTotals accumulates the metrics of one kind of operation.
+// DurationBuckets counts durations per bucket of durationBuckets, with a last
+// bucket for longer ones.
+type operationTotals struct {
+	Outcomes         map[string]int64 `json:"outcomes"`
+	BytesTransferred int64            `json:"bytes_transferred"`
+	BytesReused      int64            `json:"bytes_reused"`
+	Retries          int64            `json:"retries"`
+	CacheHits        int64            `json:"cache_hits"`
+	DurationSeconds  float64          `json:"duration_seconds"`
+	DurationBuckets  []int64          `json:"duration_buckets"`
+}
+
+type metricsDocument struct {
+	Version    int                         `json:"version"`
+	Operations map[string]*operationTotals `json:"operations"`
+}
+
+// recordOperation adds a finished operation to the accumulated metrics. Failures
+// are only logged: metrics never fail an operation.
+func (c *Client) recordOperation(operation string, metrics *OperationMetrics, opErr error) {
+	if metrics == nil {
+		return
+	}
+	lock, err := c.lockCache(metricsLock, true)
+	if err != nil {
+		c.logger.Warn("Failed to record operation metrics", "error", err)
+		return
+	}
+	defer lock.Unlock()
+
+	doc, err := c.loadMetrics()
+	if err != nil {
+		c.logger.Warn("Resetting unreadable operation metrics", "error", err)
+		doc = &metricsDocument{Version: metricsVersion, Operations: make(map[string]*operationTotals)}
+	}
+	totals, ok := doc.Operations[operation]
+	if !ok {
+		totals = &operationTotals{}
+		doc.Operations[operation] = totals
+	}
+	if totals.Outcomes == nil {
+		totals.Outcomes = make(map[string]int64)
+	}
+	if len(totals.DurationBuckets) != len(durationBuckets)+1 {
+		totals.DurationBuckets = make([]int64, len(durationBuckets)+1)
+	}
+
+	outcome := OutcomeSuccess
+	if opErr != nil {
+		outcome = OutcomeFailure
+	}
+	totals.Outcomes[outcome]++
+	totals.BytesTransferred += metrics.BytesTransferred
+	totals.BytesReused += metrics.BytesReused
+	totals.Retries += int64(metrics.Retries)
+	if metrics.CacheHit {
+		totals.CacheHits++
+	}
+	totals.DurationSeconds += metrics.DurationSeconds
+	totals.DurationBuckets[sort.SearchFloat64s(durationBuckets, metrics.DurationSeconds)]++
+
+	if err := c.saveMetrics(doc); err != nil {
+		c.logger.Warn("Failed to save operation metrics", "error", err)
+	}
+}
+
+// WriteMetrics writes the accumulated operation metrics to w in the Prometheus
+// text exposition format.
+func (c *Client) WriteMetrics(w io.Writer) error {
+	doc, err := c.loadMetrics()
+	if err != nil {
+		return err
+	}
+	operations := sortedKeys(doc.Operations)
+
+	m := &metricsWriter{w: w}
+	m.header("porter_operations_total", "counter", "Pulls and pushes by outcome.")
+	for _, op := range operations {
+		for _, outcome := range sortedKeys(doc.Operations[op].Outcomes) {
+			m.sample("porter_operations_total", labels("operation", op, "outcome", outcome), float64(doc.Operations[op].Outcomes[outcome]))
+		}
+	}
+	m.counter("porter_bytes_transferred_total", "Bytes downloaded by pulls and uploaded by pushes.", doc, operations, func(t *operationTotals) float64 { return float64(t.BytesTransferred) })
+	m.counter("porter_bytes_reused_total", "Bytes already present in the cache or the registry and not transferred.", doc, operations, func(t *operationTotals) float64 { return float64(t.BytesReused) })
+	m.counter("porter_retries_total", "Registry requests retried and blob downloads resumed.", doc, operations, func(t *operationTotals) float64 { return float64(t.Retries) })
+	m.counter("porter_cache_hits_total", "Operations that transferred no blob.", doc, operations, func(t *operationTotals) float64 { return float64(t.CacheHits) })
+
+	m.header("porter_cache_hit_ratio", "gauge", "Share of successful operations that transferred no blob.")
+	for _, op := range operations {
+		totals := doc.Operations[op]
+		ratio := 0.0
+		if succeeded := totals.Outcomes[OutcomeSuccess]; succeeded > 0 {
+			ratio = float64(totals.CacheHits) / float64(succeeded)
+		}
+		m.sample("porter_cache_hit_ratio", labels("operation", op), ratio)
+	}
+
+	m.header("porter_operation_duration_seconds", "histogram", "Duration of pulls and pushes.")
+	for _, op := range operations {
+		totals := doc.Operations[op]
+		var cumulative, count int64
+		for i, bound := range durationBuckets {
+			if i < len(totals.DurationBuckets) {
+				cumulative += totals.DurationBuckets[i]
+			}
+			m.sample("porter_operation_duration_seconds_bucket", labels("operation", op, "le", strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+		}
+		for _, n := range totals.DurationBuckets {
+			count += n
+		}
+		m.sample("porter_operation_duration_seconds_bucket", labels("operation", op, "le", "+Inf"), float64(count))
+		m.sample("porter_operation_duration_seconds_sum", labels("operation", op), totals.DurationSeconds)
+		m.sample("porter_operation_duration_seconds_count", labels("operation", op), float64(count))
+	}
+	return m.err
+}
+
+// metricsWriter writes Prometheus text, keeping the first write error.
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (m *metricsWriter) header(name, kind, help string) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+}
+
+func (m *metricsWriter) sample(name, labels string, value float64) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, "%s{%s} %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+}
+
+// counter writes the counter name with one sample per operation.
+func (m *metricsWriter) counter(name, help string, doc *metricsDocument, operations []string, value func(*operationTotals) float64) {
+	m.header(name, "counter", help)
+	for _, op := range operations {
+		m.sample(name, labels("operation", op), value(doc.Operations[op]))
+	}
+}
+
+// labels formats name and value pairs as a Prometheus label set.
+func labels(pairs ...string) string {
+	var out []byte
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, pairs[i]...)
+		out = append(out, '=')
+		out = strconv.AppendQuote(out, pairs[i+1])
+	}
+	return string(out)
+}
+
+func (c *Client) loadMetrics() (*metricsDocument, error) {
+	doc := &metricsDocument{Version: metricsVersion, Operations: make(map[string]*operationTotals)}
+	err := readJSONFile(filepath.Join(c.config.CacheDir, MetricsFile), doc)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return doc, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read operation metrics: %w", err)
+	case doc.Version != metricsVersion:
+		return nil, fmt.Errorf("unsupported operation metrics version %d", doc.Version)
+	}
+	if doc.Operations == nil {
+		doc.Operations = make(map[string]*operationTotals)
+	}
+	return doc, nil
+}
+
+// saveMetrics replaces the metrics file atomically so readers never see a
+// partial document.
+func (c *Client) saveMetrics(doc *metricsDocument) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode operation metrics: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.config.CacheDir, ".metrics-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write operation metrics: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write operation metrics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write operation metrics: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.config.CacheDir, MetricsFile)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write operation metrics: %w", err)
+	}
+	return nil
+}
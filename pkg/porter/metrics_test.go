@@ -0,0 +1,71 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullMetricsAccumulateForPrometheus(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	first, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	require.NotNil(t, first.Metrics)
+	assert.False(t, first.Metrics.CacheHit)
+	assert.Positive(t, first.Metrics.BytesTransferred)
+	assert.Positive(t, first.Metrics.Requests)
+
+	second, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	require.NotNil(t, second.Metrics)
+	assert.True(t, second.Metrics.CacheHit)
+	assert.Zero(t, second.Metrics.BytesTransferred)
+
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/missing:1.0.0", true)
+	require.Error(t, err)
+
+	cached, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	assert.Nil(t, cached[0].Metrics, "metrics describe an operation, not the cached artifact")
+
+	var out bytes.Buffer
+	require.NoError(t, client.WriteMetrics(&out))
+	text := out.String()
+	assert.Contains(t, text, "# TYPE porter_operations_total counter\n")
+	assert.Contains(t, text, `porter_operations_total{operation="pull",outcome="success"} 2`)
+	assert.Contains(t, text, `porter_operations_total{operation="pull",outcome="failure"} 1`)
+	assert.Contains(t, text, `porter_cache_hits_total{operation="pull"} 1`)
+	assert.Contains(t, text, `porter_cache_hit_ratio{operation="pull"} 0.5`)
+	assert.Contains(t, text, `porter_operation_duration_seconds_count{operation="pull"} 3`)
+	assert.Contains(t, text, `porter_operation_duration_seconds_bucket{operation="pull",le="+Inf"} 3`)
+}
+
+func TestMetricsTransportCountsRetries(t *testing.T) {
+	ctx, recorder := withOperationRecorder(context.Background())
+	transport := &metricsTransport{base: failingTransport{err: errors.New("offline")}}
+
+	retried, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://registry.example/v2/", nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _ = transport.RoundTrip(retried)
+	}
+	other, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://registry.example/v2/", nil)
+	require.NoError(t, err)
+	_, _ = transport.RoundTrip(other)
+
+	metrics := recorder.finish(progress.Snapshot{}, false)
+	assert.Equal(t, 2, metrics.Requests)
+	assert.Equal(t, 2, metrics.Retries)
+}
@@ -0,0 +1,143 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/google/go-containerregistry/pkg/name"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// pullEndpoint is one place an artifact can be pulled from: a mirror or the
+// upstream registry itself.
+type pullEndpoint struct {
+	// name identifies the endpoint in logs and result metadata, e.g.
+	// "mirror.internal/ghcr-proxy" or "ghcr.io".
+	name   string
+	mirror bool
+	repo   *remote.Repository
+}
+
+// mirrorsFromSettings reads plugins.settings.porter.mirrors, which maps a registry
+// to the mirror endpoints tried before it, in order:
+//
+//	mirrors:
+//	  ghcr.io:
+//	    - mirror.internal:5000
+//	    - http://harbor.local/ghcr-proxy
+//
+// Registry names are normalized. DS-wide registry.mirrors, when given, apply to the
+// default registry after any porter-specific ones.
+func mirrorsFromSettings(settings map[string]interface{}, defaultRegistry string, dsMirrors []string) map[string][]string {
+	var result map[string][]string
+	add := func(registry string, endpoints []string) {
+		registry = reference.NormalizeRegistry(registry)
+		if registry == "" || len(endpoints) == 0 {
+			return
+		}
+		if result == nil {
+			result = make(map[string][]string)
+		}
+		result[registry] = append(result[registry], endpoints...)
+	}
+
+	if configured, ok := settings["mirrors"].(map[string]interface{}); ok {
+		for registry := range configured {
+			add(registry, settingStrings(configured, registry))
+		}
+	}
+
+	if defaultRegistry = reference.NormalizeRegistry(defaultRegistry); defaultRegistry == "" {
+		defaultRegistry = reference.DefaultRegistry
+	}
+	var endpoints []string
+	for _, endpoint := range dsMirrors {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	add(defaultRegistry, endpoints)
+	return result
+}
+
+// parseMirror splits a mirror endpoint into its host, an optional repository
+// prefix, and whether it is served over plain HTTP.
+func parseMirror(endpoint string) (host, prefix string, plainHTTP bool, err error) {
+	endpoint = strings.TrimSpace(endpoint)
+	switch {
+	case strings.HasPrefix(endpoint, "http://"):
+		plainHTTP = true
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+	}
+	host, prefix, _ = strings.Cut(strings.TrimSuffix(endpoint, "/"), "/")
+	if host == "" {
+		return "", "", false, fmt.Errorf("invalid mirror endpoint %q", endpoint)
+	}
+	return strings.ToLower(host), strings.Trim(prefix, "/"), plainHTTP, nil
+}
+
+// pullEndpoints lists the configured mirrors of imgRef's registry followed by the
+// registry itself. Mirrors that cannot be used are logged and left out.
+func (c *Client) pullEndpoints(imgRef name.Reference, insecure bool) ([]pullEndpoint, error) {
+	registry := reference.NormalizeRegistry(imgRef.Context().RegistryStr())
+	repository := imgRef.Context().RepositoryStr()
+
+	var endpoints []pullEndpoint
+	for _, mirror := range c.config.Mirrors[registry] {
+		host, prefix, plainHTTP, err := parseMirror(mirror)
+		if err != nil {
+			c.logger.Warn("Skipping mirror", "registry", registry, "error", err)
+			continue
+		}
+		path := repository
+		if prefix != "" {
+			path = prefix + "/" + repository
+		}
+		repo, err := remote.NewRepository(host + "/" + path)
+		if err != nil {
+			c.logger.Warn("Skipping mirror", "registry", registry, "mirror", reference.Redact(mirror), "error", err)
+			continue
+		}
+		username, password := c.resolveCredentials(host)
+		repo.Client = newAuthClient(c.httpClient(host), host, username, password)
+		repo.PlainHTTP = insecure || plainHTTP
+
+		endpointName := host
+		if prefix != "" {
+			endpointName += "/" + prefix
+		}
+		endpoints = append(endpoints, pullEndpoint{name: endpointName, mirror: true, repo: repo})
+	}
+
+	repo, err := c.remoteRepository(imgRef, insecure)
+	if err != nil {
+		return nil, err
+	}
+	return append(endpoints, pullEndpoint{name: registry, repo: repo}), nil
+}
+
+// tryEndpoints runs fn against each endpoint in turn until one succeeds. Failures
+// on a mirror fall through to the next endpoint; the upstream error is returned when
+// every endpoint fails.
+func (c *Client) tryEndpoints(ctx context.Context, ref string, endpoints []pullEndpoint, fn func(pullEndpoint) error) (pullEndpoint, error) {
+	var err error
+	for _, endpoint := range endpoints {
+		if err = fn(endpoint); err == nil {
+			if endpoint.mirror {
+				c.logger.Info("Pulled from mirror", "ref", reference.Redact(ref), "mirror", endpoint.name)
+			}
+			return endpoint, nil
+		}
+		if ctx.Err() != nil {
+			return pullEndpoint{}, err
+		}
+		if endpoint.mirror {
+			c.logger.Warn("Mirror failed, trying next endpoint", "ref", reference.Redact(ref), "mirror", endpoint.name, "error", err)
+		}
+	}
+	return pullEndpoint{}, err
+}
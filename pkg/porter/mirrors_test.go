@@ -0,0 +1,82 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorsFromSettings(t *testing.T) {
+	settings := map[string]interface{}{
+		"mirrors": map[string]interface{}{
+			"https://GHCR.io":      []interface{}{"mirror.internal:5000", " http://harbor.local/ghcr-proxy "},
+			"registry.example.com": "a.example.com, b.example.com",
+		},
+	}
+
+	mirrors := mirrorsFromSettings(settings, "", []string{"hub-cache.internal"})
+	assert.Equal(t, map[string][]string{
+		"ghcr.io":              {"mirror.internal:5000", "http://harbor.local/ghcr-proxy"},
+		"registry.example.com": {"a.example.com", "b.example.com"},
+		"docker.io":            {"hub-cache.internal"},
+	}, mirrors)
+
+	assert.Nil(t, mirrorsFromSettings(nil, "ghcr.io", nil))
+	assert.Equal(t, map[string][]string{"ghcr.io": {"cache.internal"}}, mirrorsFromSettings(nil, "ghcr.io", []string{"cache.internal"}))
+}
+
+func TestParseMirror(t *testing.T) {
+	host, prefix, plainHTTP, err := parseMirror("http://Harbor.local:8080/proxy/ghcr/")
+	require.NoError(t, err)
+	assert.Equal(t, "harbor.local:8080", host)
+	assert.Equal(t, "proxy/ghcr", prefix)
+	assert.True(t, plainHTTP)
+
+	host, prefix, plainHTTP, err = parseMirror("https://mirror.internal")
+	require.NoError(t, err)
+	assert.Equal(t, "mirror.internal", host)
+	assert.Empty(t, prefix)
+	assert.False(t, plainHTTP)
+
+	_, _, _, err = parseMirror("http://")
+	assert.Error(t, err)
+}
+
+func TestPullPrefersMirror(t *testing.T) {
+	upstream := newTestRegistry(t)
+	upstream.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	mirror := newTestRegistry(t)
+	mirror.AddArtifact("proxy/team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+
+	client := newTestClient(t, nil)
+	client.config.Mirrors = map[string][]string{
+		upstream.Host(): {"http://" + mirror.Host() + "/proxy"},
+	}
+
+	result, err := client.PullArtifact(context.Background(), upstream.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, mirror.Host()+"/proxy", result.Metadata["pulled.endpoint"])
+	assert.Empty(t, upstream.Requests(), "upstream must not be contacted when the mirror serves the artifact")
+}
+
+func TestPullFallsBackToUpstream(t *testing.T) {
+	upstream := newTestRegistry(t)
+	upstream.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	empty := newTestRegistry(t)
+
+	client := newTestClient(t, nil)
+	client.config.Mirrors = map[string][]string{
+		upstream.Host(): {"127.0.0.1:1", "http://" + empty.Host()},
+	}
+
+	result, err := client.PullArtifact(context.Background(), upstream.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, upstream.Host(), result.Metadata["pulled.endpoint"])
+	assert.NotEmpty(t, empty.Requests())
+
+	upstream.server.Close()
+	_, err = client.PullArtifact(context.Background(), upstream.Host()+"/team/tool:2.0.0", true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package porter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+)
+
+// NamingPolicy restricts the references pushes may write to, so organizational
+// naming conventions are enforced before any upload rather than by the registry
+// rejecting it afterwards. Empty lists allow any name.
+type NamingPolicy struct {
+	// Repositories lists the rules a destination repository, "registry/repository"
+	// after alias expansion, must match one of. A rule ending in "/" is a prefix
+	// the repository must be under; any other rule is a regular expression the
+	// whole name must match.
+	Repositories []string `json:"repositories,omitempty"`
+	// Tags lists the rules a destination tag must match one of: NamingSemver for a
+	// semantic version, or a regular expression the whole tag must match. Pushes by
+	// digest name no tag and are not checked.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// NamingSemver is the tag rule accepting semantic versions such as "1.2.3",
+// "v1.2.3" or "1.2.3-rc.1+build.5".
+const NamingSemver = "semver"
+
+// semverTag matches a semantic version with an optional "v" prefix.
+var semverTag = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// namingPolicyFromSettings reads plugins.settings.porter.naming.
+func namingPolicyFromSettings(settings map[string]interface{}) NamingPolicy {
+	naming, _ := settings["naming"].(map[string]interface{})
+	return NamingPolicy{
+		Repositories: settingStrings(naming, "repositories"),
+		Tags:         settingStrings(naming, "tags"),
+	}
+}
+
+// namingRule is one compiled rule of a NamingPolicy.
+type namingRule struct {
+	source string
+	prefix string
+	re     *regexp.Regexp
+}
+
+func (r namingRule) match(value string) bool {
+	if r.re == nil {
+		return strings.HasPrefix(value, r.prefix)
+	}
+	return r.re.MatchString(value)
+}
+
+// namingRules is a compiled NamingPolicy; nil when the policy has no rules.
+type namingRules struct {
+	repositories []namingRule
+	tags         []namingRule
+}
+
+// compileNaming compiles policy, so a malformed rule fails every operation rather
+// than only the pushes it would have checked.
+func compileNaming(policy NamingPolicy) (*namingRules, error) {
+	if len(policy.Repositories) == 0 && len(policy.Tags) == 0 {
+		return nil, nil
+	}
+	rules := &namingRules{}
+	for _, rule := range policy.Repositories {
+		if strings.HasSuffix(rule, "/") {
+			rules.repositories = append(rules.repositories, namingRule{source: rule, prefix: rule})
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + rule + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid naming.repositories rule %q: %w", rule, err)
+		}
+		rules.repositories = append(rules.repositories, namingRule{source: rule, re: re})
+	}
+	for _, rule := range policy.Tags {
+		if rule == NamingSemver {
+			rules.tags = append(rules.tags, namingRule{source: rule, re: semverTag})
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + rule + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid naming.tags rule %q: %w", rule, err)
+		}
+		rules.tags = append(rules.tags, namingRule{source: rule, re: re})
+	}
+	return rules, nil
+}
+
+// CheckNaming reports whether ref may be pushed to under the configured naming
+// policy. Every push checks its destination with it before uploading anything.
+func (c *Client) CheckNaming(ref string) error {
+	if c.naming == nil {
+		return nil
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	// A reference without tag or digest is pushed to the default tag.
+	parsed = parsed.WithDefaultTag()
+	if err := checkNamingRules(c.naming.repositories, parsed.Name()); err != nil {
+		return fmt.Errorf("repository %s violates the naming policy: %w", parsed.Name(), err)
+	}
+	if parsed.Tag != "" {
+		if err := checkNamingRules(c.naming.tags, parsed.Tag); err != nil {
+			return fmt.Errorf("tag %q violates the naming policy: %w", parsed.Tag, err)
+		}
+	}
+	return nil
+}
+
+// checkNamingRules returns an error listing rules when value matches none of them.
+func checkNamingRules(rules []namingRule, value string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	sources := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		if rule.match(value) {
+			return nil
+		}
+		sources = append(sources, rule.source)
+	}
+	return fmt.Errorf("it must match one of %s", strings.Join(sources, ", "))
+}
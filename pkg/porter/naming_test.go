@@ -0,0 +1,79 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNamingClient(t *testing.T, policy NamingPolicy) *Client {
+	t.Helper()
+	client, err := NewClient(&Config{
+		CacheDir: t.TempDir(),
+		Aliases:  map[string]string{"acme": "ghcr.io/acme/ds-plugins"},
+		Naming:   policy,
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+	return client
+}
+
+func TestCheckNaming(t *testing.T) {
+	client := newNamingClient(t, NamingPolicy{
+		Repositories: []string{"ghcr.io/acme/ds-plugins/", `registry\.example\.com/team-[a-z]+/.*`},
+		Tags:         []string{NamingSemver, "nightly-[0-9]{8}"},
+	})
+
+	for _, ref := range []string{
+		"ghcr.io/acme/ds-plugins/porter:1.2.3",
+		"acme/porter:v2.0.0-rc.1",
+		"registry.example.com/team-core/tool:nightly-20260101",
+		"ghcr.io/acme/ds-plugins/porter@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	} {
+		assert.NoError(t, client.CheckNaming(ref), ref)
+	}
+
+	err := client.CheckNaming("ghcr.io/acme/other/porter:1.2.3")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "repository ghcr.io/acme/other/porter violates the naming policy")
+	assert.Contains(t, err.Error(), "ghcr.io/acme/ds-plugins/")
+
+	err = client.CheckNaming("acme/porter:1.2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `tag "1.2" violates the naming policy`)
+
+	// An untagged reference is pushed to latest, which is not a semantic version.
+	assert.Error(t, client.CheckNaming("acme/porter"))
+}
+
+func TestCompileNamingRejectsInvalidRules(t *testing.T) {
+	_, err := NewClient(&Config{CacheDir: t.TempDir(), Naming: NamingPolicy{Tags: []string{"v[0-9"}}}, hclog.NewNullLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid naming.tags rule")
+}
+
+func TestPushRejectsNamingViolationBeforeUpload(t *testing.T) {
+	registry := newTestRegistry(t)
+	client := newNamingClient(t, NamingPolicy{Tags: []string{NamingSemver}})
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, []byte("tool"), 0644))
+
+	_, err := client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:dev", PushOptions{Insecure: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "violates the naming policy")
+	assert.Empty(t, registry.Requests())
+}
+
+func TestNamingPolicyFromSettings(t *testing.T) {
+	policy := namingPolicyFromSettings(map[string]interface{}{
+		"naming": map[string]interface{}{
+			"repositories": []interface{}{"ghcr.io/acme/"},
+			"tags":         "semver, latest",
+		},
+	})
+	assert.Equal(t, NamingPolicy{Repositories: []string{"ghcr.io/acme/"}, Tags: []string{"semver", "latest"}}, policy)
+}
@@ -0,0 +1,105 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/delivery-station/porter/internal/doh"
+	"github.com/delivery-station/porter/internal/pac"
+)
+
+// network holds the DNS-over-HTTPS resolver and PAC script shared by every
+// transport of a client. Both are set up on first use.
+type network struct {
+	once     sync.Once
+	resolver *doh.Resolver
+	script   *pac.Script
+	err      error
+}
+
+// network returns the client's resolver and PAC script, loading them once.
+func (c *Client) network() *network {
+	c.netState.once.Do(func() {
+		if endpoint := c.config.DoH; endpoint != "" {
+			resolver, err := doh.New(endpoint, nil)
+			if err != nil {
+				c.netState.err = err
+				return
+			}
+			c.netState.resolver = resolver
+		}
+
+		if location := c.config.Proxy.PAC; location != "" {
+			// The PAC file is fetched directly: it decides which proxy to use.
+			fetch := &http.Client{Timeout: 30 * time.Second, Transport: c.baseTransport(nil)}
+			source, err := pac.Load(context.Background(), location, fetch)
+			if err != nil {
+				c.netState.err = err
+				return
+			}
+			var lookup pac.LookupFunc
+			if c.netState.resolver != nil {
+				lookup = c.netState.resolver.LookupHost
+			}
+			script, err := pac.Compile(source, lookup)
+			if err != nil {
+				c.netState.err = err
+				return
+			}
+			c.netState.script = script
+			c.logger.Debug("Loaded proxy auto-config", "location", location)
+		}
+	})
+	return &c.netState
+}
+
+// baseTransport returns a new transport with the given proxy that resolves host
+// names through the DNS-over-HTTPS resolver when one is configured.
+func (c *Client) baseTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if c.netState.resolver != nil {
+		transport.DialContext = c.netState.resolver.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	}
+	return transport
+}
+
+// pacProxy returns a proxy function that asks the PAC script for the proxy of each
+// request and uses the first one it names.
+func pacProxy(script *pac.Script) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxies, err := script.FindProxy(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		return proxies[0], nil
+	}
+}
+
+// withProxyAuth adds the configured proxy credentials to proxies that do not carry
+// their own, such as those chosen by a PAC script.
+func (c *Client) withProxyAuth(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	username, password := c.config.Proxy.Username, c.config.Proxy.Password
+	if proxy == nil || (username == "" && password == "") {
+		return proxy
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.User != nil {
+			return proxyURL, err
+		}
+		authenticated := *proxyURL
+		authenticated.User = url.UserPassword(username, password)
+		return &authenticated, nil
+	}
+}
+
+// errNetwork wraps a failure to set up name resolution or proxy auto-config.
+func errNetwork(err error) error {
+	return fmt.Errorf("failed to configure network: %w", err)
+}
@@ -0,0 +1,120 @@
+package porter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newDoHServer answers A queries for every name with 127.0.0.1 and records the
+// names it was asked for.
+func newDoHServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var query dnsmessage.Message
+		require.NoError(t, query.Unpack(body))
+		question := query.Questions[0]
+		mu.Lock()
+		names = append(names, question.Name.String())
+		mu.Unlock()
+
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true},
+			Questions: query.Questions,
+		}
+		if question.Type == dnsmessage.TypeA {
+			reply.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+			}}
+		}
+		packed, err := reply.Pack()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), names...)
+	}
+}
+
+func TestPullUsesPACProxyWithCredentials(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	proxy, forwarded := newForwardProxy(t)
+
+	var mu sync.Mutex
+	var authorizations []string
+	authenticating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Proxy-Authorization"))
+		mu.Unlock()
+		proxy.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(authenticating.Close)
+
+	script := filepath.Join(t.TempDir(), "proxy.pac")
+	require.NoError(t, os.WriteFile(script, []byte(`function FindProxyForURL(url, host) {
+	if (shExpMatch(host, "127.0.0.*")) {
+		return "PROXY `+strings.TrimPrefix(authenticating.URL, "http://")+`";
+	}
+	return "DIRECT";
+}`), 0o644))
+
+	client := newTestClient(t, nil)
+	client.config.Proxy = ProxyConfig{PAC: script, Username: "field", Password: "s3cret"}
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, forwarded())
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, authorizations)
+	for _, auth := range authorizations {
+		assert.Equal(t, "Basic ZmllbGQ6czNjcmV0", auth)
+	}
+}
+
+func TestPullResolvesThroughDoH(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	_, port, err := net.SplitHostPort(registry.Host())
+	require.NoError(t, err)
+	resolver, names := newDoHServer(t)
+
+	host := "registry.field.test:" + port
+	client := newTestClient(t, nil)
+	client.config.DoH = resolver.URL
+	_, err = client.PullArtifact(context.Background(), host+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Contains(t, names(), "registry.field.test.")
+}
+
+func TestPullFailsOnBrokenPAC(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+
+	client := newTestClient(t, nil)
+	client.config.Proxy = ProxyConfig{PAC: filepath.Join(t.TempDir(), "missing.pac")}
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to configure network")
+}
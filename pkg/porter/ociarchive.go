@@ -0,0 +1,222 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// OCIArchiveExt is the extension of the oci-layout tarballs written by exports
+// with ExportOptions.OCIArchive into a directory.
+const OCIArchiveExt = ".oci.tar"
+
+// exportOCIArchive writes the complete cached artifact, every platform included,
+// as an oci-layout tarball: an oci-layout file, an index.json naming the artifact,
+// and its blobs. A destination directory receives <name>.oci.tar.
+func (c *Client) exportOCIArchive(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
+	if opts.Versioned || opts.OutputTemplate != "" {
+		return nil, fmt.Errorf("an OCI archive export cannot be versioned or templated")
+	}
+	store, err := oci.New(result.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI store: %w", err)
+	}
+	root, err := store.Resolve(ctx, result.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve artifact descriptor %s: %w", result.Digest, err)
+	}
+	blobs, err := artifactBlobs(ctx, store, root)
+	if err != nil {
+		return nil, err
+	}
+
+	target := archiveTarget(result.Reference, destination, OCIArchiveExt)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	if err := c.config.Extract.checkDestinationOwner(filepath.Dir(target)); err != nil {
+		return nil, err
+	}
+
+	writer := exportWriter{logger: c.logger, policy: c.config.Extract, overwrite: opts.Overwrite, verified: opts.verified}
+	if ok, err := writer.claim(target); err != nil || !ok {
+		return nil, err
+	}
+	outFile, err := writer.create(target, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	if err := writeOCIArchive(ctx, outFile, store, root, blobs, ociRefName(result.Reference)); err != nil {
+		outFile.Abort()
+		return nil, err
+	}
+	if err := outFile.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write OCI archive: %w", err)
+	}
+
+	c.logger.Info("Exported OCI archive", "digest", result.Digest, "path", target, "blobs", len(blobs))
+	return []string{target}, nil
+}
+
+// archiveTarget returns the file an archive export of ref to destination writes:
+// destination itself, or <name><ext> in a destination directory.
+func archiveTarget(ref, destination, ext string) string {
+	if info, err := os.Stat(destination); (err == nil && info.IsDir()) || (err != nil && !destinationLooksLikeFile(destination)) {
+		return filepath.Join(destination, deriveArtifactBaseName(ref)+ext)
+	}
+	return destination
+}
+
+// planArchive records the archive with extension ext an archive export to
+// destination would write. Its digest is only known once it is written.
+func planArchive(record *AuditRecord, ref, destination, ext string) {
+	if IsRemoteDestination(destination) {
+		target, _ := parseDestinationURL(destination)
+		location := destination
+		if !destinationLooksLikeFile(target.Path) {
+			location = strings.TrimSuffix(destination, "/") + "/" + deriveArtifactBaseName(ref) + ext
+		}
+		record.Plan(PlannedChange{Action: ChangeUpload, Path: location})
+		return
+	}
+	if target, ok := parseDestinationURL(destination); ok {
+		destination = target.Path
+	}
+	target := archiveTarget(ref, destination, ext)
+	action := ChangeCreate
+	if _, err := os.Lstat(target); err == nil {
+		action = ChangeOverwrite
+	}
+	record.Plan(PlannedChange{Action: action, Path: target})
+}
+
+// artifactBlobs returns root and every descriptor reachable from it, each once.
+func artifactBlobs(ctx context.Context, fetcher content.Fetcher, root ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	var blobs []ocispec.Descriptor
+	seen := make(map[string]struct{})
+	var walk func(desc ocispec.Descriptor) error
+	walk = func(desc ocispec.Descriptor) error {
+		if _, ok := seen[desc.Digest.String()]; ok {
+			return nil
+		}
+		seen[desc.Digest.String()] = struct{}{}
+		blobs = append(blobs, desc)
+		successors, err := content.Successors(ctx, fetcher, desc)
+		if err != nil {
+			return fmt.Errorf("failed to read successors of %s: %w", desc.Digest, err)
+		}
+		for _, successor := range successors {
+			if err := walk(successor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// ociRefName returns the tag of ref, which names the artifact in the archive's
+// index.json for tools such as skopeo; empty for digest references.
+func ociRefName(ref string) string {
+	parsed, err := reference.Parse(ref)
+	if err != nil || !parsed.IsTag() {
+		return ""
+	}
+	return parsed.Identifier()
+}
+
+// archiveFile is a file written into an archive besides the blobs.
+type archiveFile struct {
+	Name string
+	Data []byte
+}
+
+// archiveBlobName returns the name writeOCIArchive stores the blob dgst under.
+func archiveBlobName(dgst digest.Digest) string {
+	return path.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// writeOCIArchive writes the oci-layout tarball of root to w, followed by extra.
+func writeOCIArchive(ctx context.Context, w io.Writer, fetcher content.Fetcher, root ocispec.Descriptor, blobs []ocispec.Descriptor, refName string, extra ...archiveFile) error {
+	indexDesc := root
+	if refName != "" {
+		indexDesc.Annotations = map[string]string{ocispec.AnnotationRefName: refName}
+	}
+	index, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{indexDesc},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode index.json: %w", err)
+	}
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return fmt.Errorf("failed to encode oci-layout: %w", err)
+	}
+
+	modTime := time.Unix(0, 0)
+	tw := tar.NewWriter(w)
+	writeFile := func(name string, size int64, r io.Reader) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := io.Copy(tw, r); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+	if err := writeFile(ocispec.ImageLayoutFile, int64(len(layout)), bytes.NewReader(layout)); err != nil {
+		return err
+	}
+	if err := writeFile(ocispec.ImageIndexFile, int64(len(index)), bytes.NewReader(index)); err != nil {
+		return err
+	}
+	for _, file := range extra {
+		if err := writeFile(file.Name, int64(len(file.Data)), bytes.NewReader(file.Data)); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{"blobs/", "blobs/" + root.Digest.Algorithm().String() + "/"} {
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Mode: 0755, ModTime: modTime, Typeflag: tar.TypeDir}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dir, err)
+		}
+	}
+	for _, blob := range blobs {
+		reader, err := fetcher.Fetch(ctx, blob)
+		if err != nil {
+			return fmt.Errorf("failed to fetch blob %s: %w", blob.Digest, err)
+		}
+		verifier := content.NewVerifyReader(reader, blob)
+		err = writeFile(archiveBlobName(blob.Digest), blob.Size, verifier)
+		if err == nil {
+			err = verifier.Verify()
+		}
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish OCI archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package porter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestExportOCIArchive(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+
+	outDir := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{OCIArchive: true, Platforms: platforms[:1]})
+	require.NoError(t, err)
+	archivePath := filepath.Join(outDir, "tool"+OCIArchiveExt)
+	assert.Equal(t, []string{archivePath}, exported)
+	assert.Contains(t, result.VerifiedFiles, archivePath)
+
+	ctx := context.Background()
+	store, err := oci.NewFromTar(ctx, archivePath)
+	require.NoError(t, err)
+	root, err := store.Resolve(ctx, "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, result.Digest, root.Digest.String())
+
+	// Every platform is packaged, whatever the selection.
+	blobs, err := artifactBlobs(ctx, store, root)
+	require.NoError(t, err)
+	assert.Len(t, blobs, 6, "index, two manifests and layers, and the shared empty config")
+	for _, blob := range blobs {
+		if blob.MediaType != "application/octet-stream" {
+			continue
+		}
+		data, err := content.FetchAll(ctx, store, blob)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "tool for linux/")
+	}
+
+	planned, err := client.PlanExport(ctx, result, outDir, ExportOptions{OCIArchive: true})
+	require.NoError(t, err)
+	assert.Equal(t, []PlannedChange{{Action: ChangeOverwrite, Path: archivePath}}, planned)
+}
@@ -0,0 +1,143 @@
+package porter
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// OutputTemplateData is available to ExportOptions.OutputTemplate, e.g.
+// "{{.Name}}-{{.OS}}-{{.Arch}}{{.Ext}}".
+type OutputTemplateData struct {
+	// Name is the layer title, or the artifact name, without its extension.
+	Name    string
+	OS      string
+	Arch    string
+	Variant string
+	Version string
+	// Ext is the extension of the layer title, or the one porter would append:
+	// ".exe" for Windows binaries and ".tar.gz" or ".tar.zst" for archives. It is
+	// empty for archive layers, which are extracted into a directory of that name.
+	Ext string
+}
+
+// layerNamer decides where the layers of an export are written. Without a
+// template, layers go to their title or the artifact name in their platform's
+// directory. With one, every platform is exported flat into the destination
+// under the rendered name.
+type layerNamer struct {
+	baseName string
+	version  string
+	template *template.Template
+
+	mu      sync.Mutex
+	claimed map[string]string
+}
+
+func newLayerNamer(result *ArtifactResult, opts ExportOptions) (*layerNamer, error) {
+	namer := &layerNamer{baseName: deriveArtifactBaseName(result.Reference)}
+	if strings.TrimSpace(opts.OutputTemplate) == "" {
+		return namer, nil
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(opts.OutputTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output template: %w", err)
+	}
+	namer.template = tmpl
+	namer.claimed = make(map[string]string)
+	if version, err := exportVersion(result, opts.Version); err == nil {
+		namer.version = version
+	}
+	return namer, nil
+}
+
+// templated reports whether names come from an output template.
+func (n *layerNamer) templated() bool {
+	return n.template != nil
+}
+
+// dir returns the directory the layers of a manifest for platform are written to.
+func (n *layerNamer) dir(destination string, platform *ocispec.Platform, subdirs bool) string {
+	if n.templated() {
+		return destination
+	}
+	return platformDir(destination, platform, subdirs)
+}
+
+// file returns the path a non-archive layer is written to inside dir.
+func (n *layerNamer) file(dir string, layer ocispec.Descriptor, platform *ocispec.Platform) (string, error) {
+	if !n.templated() {
+		return filepath.Join(dir, determineLayerFilename(layer, n.baseName, platform)), nil
+	}
+	return n.render(dir, layer, platform, false)
+}
+
+// archiveDir returns the directory an archive layer is extracted into inside dir.
+func (n *layerNamer) archiveDir(dir string, layer ocispec.Descriptor, platform *ocispec.Platform) (string, error) {
+	if !n.templated() {
+		return dir, nil
+	}
+	return n.render(dir, layer, platform, true)
+}
+
+func (n *layerNamer) render(dir string, layer ocispec.Descriptor, platform *ocispec.Platform, archive bool) (string, error) {
+	data := OutputTemplateData{Version: n.version}
+	if platform != nil {
+		data.OS = platform.OS
+		data.Arch = platform.Architecture
+		data.Variant = platform.Variant
+	}
+	if title := strings.TrimSpace(layer.Annotations[ocispec.AnnotationTitle]); title != "" {
+		data.Name, data.Ext = splitExtension(sanitizeFilename(title))
+	} else {
+		data.Name, data.Ext = splitExtension(n.baseName)
+		if data.Name == "" {
+			data.Name = "artifact"
+		}
+		if data.Ext == "" {
+			data.Ext = defaultExtension(layer, platform)
+		}
+	}
+	if archive {
+		data.Ext = ""
+	}
+
+	var buf bytes.Buffer
+	if err := n.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render output template: %w", err)
+	}
+	name := filepath.Clean(strings.TrimSpace(buf.String()))
+	if name == "." || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("output template rendered %q for layer %s, which is not a path inside the destination", buf.String(), layer.Digest)
+	}
+
+	path := filepath.Join(dir, name)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if other, ok := n.claimed[path]; ok && other != layer.Digest.String() {
+		return "", fmt.Errorf("output template writes layers %s and %s to the same path %s", other, layer.Digest, path)
+	}
+	n.claimed[path] = layer.Digest.String()
+	return path, nil
+}
+
+// splitExtension splits name into its stem and extension, keeping compressed tar
+// extensions such as ".tar.gz" whole.
+func splitExtension(name string) (string, string) {
+	for _, ext := range []string{".tar.gz", ".tar.zst"} {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return strings.TrimSuffix(name, ext), ext
+		}
+	}
+	ext := filepath.Ext(name)
+	if ext == name {
+		return name, ""
+	}
+	return strings.TrimSuffix(name, ext), ext
+}
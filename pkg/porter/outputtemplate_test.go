@@ -0,0 +1,84 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pullMultiPlatformTool(t *testing.T, platforms []ocispec.Platform) (*Client, *ArtifactResult) {
+	t.Helper()
+	registry := newTestRegistry(t)
+	var manifests []ocispec.Descriptor
+	for _, platform := range platforms {
+		desc := registry.AddArtifact("team/tool", "", "application/octet-stream", []byte("tool for "+platform.OS+"/"+platform.Architecture), nil)
+		desc.Platform = &platform
+		manifests = append(manifests, desc)
+	}
+	registry.AddManifest("team/tool", "1.0.0", ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	return client, result
+}
+
+func TestExportWithOutputTemplate(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "windows", Architecture: "amd64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+
+	outDir := t.TempDir()
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{
+		AllPlatforms:   true,
+		OutputTemplate: "{{.Name}}-{{.Version}}-{{.OS}}-{{.Arch}}{{.Ext}}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(outDir, "tool-1.0.0-linux-amd64"),
+		filepath.Join(outDir, "tool-1.0.0-windows-amd64.exe"),
+	}, exported)
+	data, err := os.ReadFile(exported[1])
+	require.NoError(t, err)
+	assert.Equal(t, "tool for windows/amd64", string(data))
+}
+
+func TestExportOutputTemplateRejectsCollisions(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+
+	_, err := client.ExportArtifact(context.Background(), result, t.TempDir(), ExportOptions{AllPlatforms: true, OutputTemplate: "{{.Name}}-{{.OS}}"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "to the same path")
+
+	_, err = client.ExportArtifact(context.Background(), result, t.TempDir(), ExportOptions{AllPlatforms: true, OutputTemplate: "../{{.Name}}-{{.Arch}}"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a path inside the destination")
+}
+
+func TestSplitExtension(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"tool":          {"tool", ""},
+		"tool.exe":      {"tool", ".exe"},
+		"bundle.tar.gz": {"bundle", ".tar.gz"},
+		".env":          {".env", ""},
+	} {
+		stem, ext := splitExtension(name)
+		assert.Equal(t, want, [2]string{stem, ext}, name)
+	}
+}
@@ -0,0 +1,127 @@
+package porter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/delivery-station/porter/pkg/release"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ExtractPolicy relaxes the safety checks applied when artifacts are exported.
+// The zero value is the safe default for hosts where porter runs privileged.
+type ExtractPolicy struct {
+	// AllowSetuid keeps setuid and setgid bits on extracted files instead of
+	// refusing the archive.
+	AllowSetuid bool `json:"allow_setuid,omitempty"`
+	// AllowWorldWritable keeps the world-writable bit on extracted files and
+	// directories instead of dropping it.
+	AllowWorldWritable bool `json:"allow_world_writable,omitempty"`
+	// AllowForeignOwner lets a privileged porter export into directories owned by
+	// other users.
+	AllowForeignOwner bool `json:"allow_foreign_owner,omitempty"`
+}
+
+// geteuid is replaced in tests to exercise the privileged checks.
+var geteuid = os.Geteuid
+
+// extractPolicyFromSettings reads plugins.settings.porter.extract.
+func extractPolicyFromSettings(settings map[string]interface{}) ExtractPolicy {
+	extract, _ := settings["extract"].(map[string]interface{})
+	return ExtractPolicy{
+		AllowSetuid:        settingBool(extract, "allow_setuid"),
+		AllowWorldWritable: settingBool(extract, "allow_world_writable"),
+		AllowForeignOwner:  settingBool(extract, "allow_foreign_owner"),
+	}
+}
+
+// fileMode returns the mode name is extracted with under the policy, or an error
+// when the policy refuses it.
+func (p ExtractPolicy) fileMode(name string, mode os.FileMode) (os.FileMode, error) {
+	if mode&(os.ModeSetuid|os.ModeSetgid) != 0 && !p.AllowSetuid {
+		return 0, fmt.Errorf("archive entry %s has the setuid or setgid bit set; set extract.allow_setuid to permit it", name)
+	}
+	if !p.AllowWorldWritable {
+		mode &^= 0o002
+	}
+	return mode, nil
+}
+
+// dirMode returns the permissions a directory is created with under the policy.
+func (p ExtractPolicy) dirMode(mode os.FileMode) os.FileMode {
+	if !p.AllowWorldWritable {
+		mode &^= 0o002
+	}
+	return mode.Perm()
+}
+
+// checkDestinationOwner refuses to export into dir when porter runs as root and dir
+// belongs to another user, who could otherwise swap its contents for symlinks that
+// redirect privileged writes.
+func (p ExtractPolicy) checkDestinationOwner(dir string) error {
+	if p.AllowForeignOwner || geteuid() != 0 {
+		return nil
+	}
+	uid, ok, err := fileOwner(dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat destination: %w", err)
+	}
+	if ok && uid != 0 {
+		return fmt.Errorf("destination %s is owned by uid %d; refusing to export as root (set extract.allow_foreign_owner to permit)", dir, uid)
+	}
+	return nil
+}
+
+// checkNotExecutable refuses to overwrite the binary of the running process, which
+// would change the code a privileged porter re-executes, or crash it mid-run.
+func checkNotExecutable(path string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(self); err == nil {
+		self = resolved
+	}
+	selfInfo, err := os.Stat(self)
+	if err != nil {
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil && os.SameFile(info, selfInfo) {
+		return fmt.Errorf("refusing to overwrite the running executable %s", path)
+	}
+	return nil
+}
+
+// binaryFileMode is the mode exported binaries get, so DS can launch them without
+// a manual chmod.
+const binaryFileMode os.FileMode = 0o755
+
+// exportedFileMode returns the mode a single-file layer is created with, and the
+// mode it must end up with regardless of the umask or an earlier export: 0755 for
+// binaries, judged by the layer media type or the artifact types of its manifest
+// and index, and zero otherwise. Windows has no executable bit.
+func exportedFileMode(layer ocispec.Descriptor, artifactTypes ...string) (os.FileMode, os.FileMode) {
+	if runtime.GOOS == "windows" {
+		return 0o666, 0
+	}
+	for _, mediaType := range append([]string{layer.MediaType}, artifactTypes...) {
+		if release.IsBinaryMediaType(mediaType) {
+			return binaryFileMode, binaryFileMode
+		}
+	}
+	return 0o666, 0
+}
+
+// enforceMode sets mode on path unless it is zero. OpenFile applies the umask to
+// new files and leaves the mode of existing ones alone.
+func enforceMode(path string, mode os.FileMode) error {
+	if mode == 0 {
+		return nil
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package porter
+
+// fileOwner is not implemented without Unix ownership.
+func fileOwner(string) (int, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,145 @@
+package porter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGzModes(t *testing.T, modes map[string]int64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, mode := range modes {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: 4, Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte("data"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestExtractRefusesSetuidByDefault(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions")
+	}
+	archive := tarGzModes(t, map[string]int64{"bin/helper": 0o4755})
+
+	_, err := extractArchive(bytes.NewReader(archive), release.CompressionGzip, t.TempDir(), exportWriter{}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "setuid")
+
+	dir := t.TempDir()
+	_, err = extractArchive(bytes.NewReader(archive), release.CompressionGzip, dir, exportWriter{policy: ExtractPolicy{AllowSetuid: true}}, nil)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "bin", "helper"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSetuid)
+}
+
+func TestExtractDropsWorldWritable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions")
+	}
+	archive := tarGzModes(t, map[string]int64{"shared.cfg": 0o666})
+
+	mode, err := ExtractPolicy{}.fileMode("shared.cfg", 0o777)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o775), mode)
+
+	dir := t.TempDir()
+	_, err = extractArchive(bytes.NewReader(archive), release.CompressionGzip, dir, exportWriter{shared: true}, nil)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "shared.cfg"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0o002)
+
+	mode, err = ExtractPolicy{AllowWorldWritable: true}.fileMode("shared.cfg", 0o666)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o666), mode)
+}
+
+func TestCheckDestinationOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership needs root")
+	}
+	dir := t.TempDir()
+	require.NoError(t, ExtractPolicy{}.checkDestinationOwner(dir))
+
+	require.NoError(t, os.Chown(dir, 1000, 1000))
+	err := ExtractPolicy{}.checkDestinationOwner(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "owned by uid 1000")
+	assert.NoError(t, ExtractPolicy{AllowForeignOwner: true}.checkDestinationOwner(dir))
+
+	original := geteuid
+	geteuid = func() int { return 1000 }
+	t.Cleanup(func() { geteuid = original })
+	assert.NoError(t, ExtractPolicy{}.checkDestinationOwner(dir))
+}
+
+func TestCreateRefusesRunningExecutable(t *testing.T) {
+	self, err := os.Executable()
+	require.NoError(t, err)
+	_, err = exportWriter{}.create(self, 0o755)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "running executable")
+}
+
+func TestExtractPolicyFromSettings(t *testing.T) {
+	policy := extractPolicyFromSettings(map[string]interface{}{
+		"extract": map[string]interface{}{"allow_setuid": true, "allow_foreign_owner": "true"},
+	})
+	assert.Equal(t, ExtractPolicy{AllowSetuid: true, AllowForeignOwner: true}, policy)
+}
+
+func TestExportMarksBinariesExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no executable bit")
+	}
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/plugin", "1.0.0", release.MediaTypeArtifactBinary, []byte("plugin binary"), nil)
+	registry.AddArtifact("team/notes", "1.0.0", "text/plain", []byte("notes"), nil)
+
+	client := newTestClient(t, nil)
+	plugin, err := client.PullArtifact(context.Background(), registry.Host()+"/team/plugin:1.0.0", true)
+	require.NoError(t, err)
+	notes, err := client.PullArtifact(context.Background(), registry.Host()+"/team/notes:1.0.0", true)
+	require.NoError(t, err)
+
+	// An earlier export left the binary without its executable bit.
+	out := t.TempDir()
+	stale := filepath.Join(out, "plugin")
+	require.NoError(t, os.WriteFile(stale, []byte("old"), 0644))
+
+	exported, err := client.ExportArtifact(context.Background(), plugin, out, ExportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{stale}, exported)
+	info, err := os.Stat(stale)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	file := filepath.Join(t.TempDir(), "plugin.bin")
+	_, err = client.ExportArtifact(context.Background(), plugin, file, ExportOptions{})
+	require.NoError(t, err)
+	info, err = os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	exported, err = client.ExportArtifact(context.Background(), notes, t.TempDir(), ExportOptions{})
+	require.NoError(t, err)
+	info, err = os.Stat(exported[0])
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0111, "only binaries are made executable")
+}
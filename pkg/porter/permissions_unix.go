@@ -0,0 +1,21 @@
+//go:build unix
+
+package porter
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid owning path.
+func fileOwner(path string) (int, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false, nil
+	}
+	return int(stat.Uid), true, nil
+}
@@ -0,0 +1,290 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"github.com/delivery-station/porter/pkg/release"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gopkg.in/yaml.v3"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// PullPolicy decides whether an artifact may be pulled. Pulls evaluate it after
+// resolving the artifact and before downloading, caching or exporting any of it,
+// so supply-chain rules live in one place rather than in per-command flags.
+// Policies loaded from policy_file implement it; hosts embedding porter may set
+// their own with Config.PullPolicy.
+type PullPolicy interface {
+	// EvaluatePull returns an error describing why subject must not be pulled.
+	EvaluatePull(ctx context.Context, subject *PolicySubject) error
+}
+
+// PolicySubject describes the artifact a pull policy decides on. Size and Signed
+// query the registry, so policies call them only when a rule needs them.
+type PolicySubject struct {
+	// Reference is the reference as requested.
+	Reference string
+	// Repository is "registry/repository" after alias expansion.
+	Repository string
+	// ByDigest reports whether Reference names a digest rather than a tag.
+	ByDigest bool
+	// Digest is the digest of the root manifest or index.
+	Digest string
+	// Size returns the bytes of every blob of the artifact, across platforms.
+	Size func(ctx context.Context) (int64, error)
+	// Signed reports whether a cosign signature is attached to the artifact.
+	Signed func(ctx context.Context) (bool, error)
+}
+
+// PolicyFile is the YAML policy file policy_file names. Every rule whose match
+// covers the repository applies; a pull fails when any of them is violated.
+type PolicyFile struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule constrains the artifacts of the repositories it matches.
+type PolicyRule struct {
+	// Name identifies the rule in violations; it defaults to Match.
+	Name string `yaml:"name,omitempty"`
+	// Match is a glob over "registry/repository": "*" matches within a path
+	// segment and "**" across segments. Empty matches every repository.
+	Match string `yaml:"match,omitempty"`
+	// RequireSignature refuses artifacts without an attached cosign signature.
+	RequireSignature bool `yaml:"require_signature,omitempty"`
+	// RequireDigest refuses references by tag.
+	RequireDigest bool `yaml:"require_digest,omitempty"`
+	// MaxSize refuses artifacts larger than this size, such as "2GB".
+	MaxSize string `yaml:"max_size,omitempty"`
+	// Deny refuses every artifact of the matched repositories.
+	Deny bool `yaml:"deny,omitempty"`
+}
+
+// policyRule is a compiled PolicyRule.
+type policyRule struct {
+	PolicyRule
+	match   *regexp.Regexp
+	maxSize int64
+}
+
+// filePolicy is the PullPolicy of a policy file.
+type filePolicy struct {
+	rules []policyRule
+}
+
+// LoadPolicy reads and compiles the policy file at path.
+func LoadPolicy(path string) (PullPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var file PolicyFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	policy := &filePolicy{}
+	for i, rule := range file.Rules {
+		compiled, err := compilePolicyRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d of policy file %s: %w", i+1, path, err)
+		}
+		policy.rules = append(policy.rules, compiled)
+	}
+	return policy, nil
+}
+
+func compilePolicyRule(rule PolicyRule) (policyRule, error) {
+	rule.Match = strings.TrimSpace(rule.Match)
+	if rule.Name == "" {
+		rule.Name = rule.Match
+		if rule.Name == "" {
+			rule.Name = "**"
+		}
+	}
+	compiled := policyRule{PolicyRule: rule, match: globPattern(rule.Match)}
+	if rule.MaxSize != "" {
+		size, err := parseSize(rule.MaxSize)
+		if err != nil {
+			return policyRule{}, fmt.Errorf("invalid max_size: %w", err)
+		}
+		compiled.maxSize = size
+	}
+	if !rule.RequireSignature && !rule.RequireDigest && compiled.maxSize == 0 && !rule.Deny {
+		return policyRule{}, fmt.Errorf("rule %q sets no constraint", rule.Name)
+	}
+	return compiled, nil
+}
+
+// globPattern compiles a repository glob; an empty glob matches everything.
+func globPattern(glob string) *regexp.Regexp {
+	if glob == "" {
+		glob = "**"
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func (p *filePolicy) EvaluatePull(ctx context.Context, subject *PolicySubject) error {
+	var violations []string
+	for _, rule := range p.rules {
+		if !rule.match.MatchString(subject.Repository) {
+			continue
+		}
+		if rule.Deny {
+			violations = append(violations, fmt.Sprintf("rule %q denies the repository", rule.Name))
+			continue
+		}
+		if rule.RequireDigest && !subject.ByDigest {
+			violations = append(violations, fmt.Sprintf("rule %q requires a digest reference", rule.Name))
+		}
+		if rule.maxSize > 0 {
+			size, err := subject.Size(ctx)
+			if err != nil {
+				return err
+			}
+			if size > rule.maxSize {
+				violations = append(violations, fmt.Sprintf("rule %q allows at most %s, and the artifact has %d bytes", rule.Name, rule.MaxSize, size))
+			}
+		}
+		if rule.RequireSignature {
+			signed, err := subject.Signed(ctx)
+			if err != nil {
+				return err
+			}
+			if !signed {
+				violations = append(violations, fmt.Sprintf("rule %q requires a cosign signature", rule.Name))
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// pullPolicies returns the policies pulls are evaluated against: the configured
+// hook and the policy file.
+func pullPolicies(cfg *Config) ([]PullPolicy, error) {
+	var policies []PullPolicy
+	if cfg.PullPolicy != nil {
+		policies = append(policies, cfg.PullPolicy)
+	}
+	if path := strings.TrimSpace(cfg.PolicyFile); path != "" {
+		policy, err := LoadPolicy(path)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// checkPullPolicy resolves ref at the registry and evaluates it against the pull
+// policies, returning the descriptor it evaluated. It returns a zero descriptor
+// when no policy is configured.
+func (c *Client) checkPullPolicy(ctx context.Context, ref string, insecure bool) (ocispec.Descriptor, error) {
+	if len(c.policies) == 0 {
+		return ocispec.Descriptor{}, nil
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, root, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	subject := &PolicySubject{
+		Reference:  ref,
+		Repository: parsed.Name(),
+		ByDigest:   parsed.Digest != "",
+		Digest:     root.Digest.String(),
+		Size: func(ctx context.Context) (int64, error) {
+			estimate := &TransferEstimate{}
+			if err := c.estimateNode(ctx, repo, root, nil, estimate, make(map[string]struct{})); err != nil {
+				return 0, fmt.Errorf("failed to size %s for the pull policy: %w", ref, err)
+			}
+			return estimate.TotalBytes, nil
+		},
+		Signed: func(ctx context.Context) (bool, error) {
+			return c.isSigned(ctx, repo, root)
+		},
+	}
+	for _, policy := range c.policies {
+		if err := policy.EvaluatePull(ctx, subject); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("pull of %s refused by policy: %w", reference.Redact(ref), err)
+		}
+	}
+	c.logger.Debug("Pull policy passed", "ref", reference.Redact(ref), "digest", subject.Digest)
+	return root, nil
+}
+
+// isSigned reports whether a cosign signature is attached to subject, under the
+// cosign tag schema or as a referrer.
+func (c *Client) isSigned(ctx context.Context, repo *remote.Repository, subject ocispec.Descriptor) (bool, error) {
+	signature, err := c.cosignSignature(ctx, repo, subject)
+	if err != nil {
+		return false, err
+	}
+	if signature != nil {
+		return true, nil
+	}
+	signed := false
+	err = repo.Referrers(ctx, subject, "", func(referrers []ocispec.Descriptor) error {
+		for _, desc := range referrers {
+			if desc.ArtifactType == release.MediaTypeCosignSimpleSigning || strings.HasPrefix(desc.ArtifactType, "application/vnd.dev.sigstore.bundle") {
+				signed = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list referrers of %s: %w", subject.Digest, err)
+	}
+	return signed, nil
+}
+
+// CheckDigestReference refuses ref when it names a tag rather than a digest and
+// digest references are required, by require_digest or by required. Tags are
+// mutable, so production configurations set require_digest to pull exactly the
+// content that was reviewed.
+func (c *Client) CheckDigestReference(ref string, required bool) error {
+	if !required && !c.config.RequireDigest {
+		return nil
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	if parsed.Digest == "" {
+		return fmt.Errorf("reference %s names a tag, but digest references are required; pull %s@sha256:<digest> instead", reference.Redact(ref), parsed.Name())
+	}
+	return nil
+}
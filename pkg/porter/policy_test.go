@@ -0,0 +1,95 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadPolicyRejectsInvalidRules(t *testing.T) {
+	for content, want := range map[string]string{
+		"rules:\n  - match: ghcr.io/**\n":                     `sets no constraint`,
+		"rules:\n  - match: ghcr.io/**\n    max_size: lots\n": `invalid max_size`,
+		"rules:\n  - match: ghcr.io/**\n    signed: true\n":   `field signed not found`,
+	} {
+		_, err := LoadPolicy(writePolicy(t, content))
+		require.Error(t, err, content)
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestGlobPattern(t *testing.T) {
+	assert.True(t, globPattern("ghcr.io/**").MatchString("ghcr.io/team/tools/cli"))
+	assert.True(t, globPattern("ghcr.io/*/cli").MatchString("ghcr.io/team/cli"))
+	assert.False(t, globPattern("ghcr.io/*/cli").MatchString("ghcr.io/team/tools/cli"))
+	assert.False(t, globPattern("ghcr.io/**").MatchString("ghcr.io.evil/team"))
+	assert.True(t, globPattern("").MatchString("docker.io/library/alpine"))
+}
+
+func TestPullPolicyRefusesBeforeDownloading(t *testing.T) {
+	registry := newTestRegistry(t)
+	artifact := registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	registry.AddArtifact("prod/tool", "1.0.0", "application/octet-stream", []byte("prod binary"), nil)
+
+	policy, err := LoadPolicy(writePolicy(t, strings.Join([]string{
+		"rules:",
+		"  - name: signed-team",
+		"    match: " + registry.Host() + "/team/**",
+		"    require_signature: true",
+		"  - match: " + registry.Host() + "/prod/*",
+		"    require_digest: true",
+		"    max_size: 1KB",
+	}, "\n")))
+	require.NoError(t, err)
+	client := newTestClient(t, nil)
+	client.policies = []PullPolicy{policy}
+
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `rule "signed-team" requires a cosign signature`)
+	for _, request := range registry.Requests() {
+		assert.NotContains(t, request, "/blobs/", "a refused artifact is not downloaded")
+	}
+
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/prod/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires a digest reference")
+	assert.NotContains(t, err.Error(), "allows at most", "the artifact is within max_size")
+
+	registry.AddArtifact("team/tool", release.SignatureTag(artifact.Digest), release.MediaTypeCosignSimpleSigning, []byte(`{"critical":{}}`), nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, artifact.Digest.String(), result.Digest)
+}
+
+func TestRequireDigestRefusesTags(t *testing.T) {
+	registry := newTestRegistry(t)
+	artifact := registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+
+	client := newTestClient(t, nil)
+	assert.NoError(t, client.CheckDigestReference(registry.Host()+"/team/tool:1.0.0", false))
+	assert.Error(t, client.CheckDigestReference(registry.Host()+"/team/tool:1.0.0", true))
+
+	client.config.RequireDigest = true
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "digest references are required")
+	assert.Empty(t, registry.Requests(), "a tag is refused before contacting the registry")
+
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool@"+artifact.Digest.String(), true)
+	require.NoError(t, err)
+	assert.Equal(t, artifact.Digest.String(), result.Digest)
+}
@@ -0,0 +1,353 @@
+package porter
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PortableMetadataFile is the entry of a portable archive holding the cache
+// metadata of its artifact. It is the first entry, so a load knows the cache ID
+// before any blob arrives.
+const PortableMetadataFile = "metadata.json"
+
+// PortableArchive describes a portable archive written by SaveArtifact or read by
+// LoadArtifact.
+type PortableArchive struct {
+	Path     string          `json:"path"`
+	Artifact *ArtifactResult `json:"artifact"`
+	Blobs    int             `json:"blobs"`
+	Size     int64           `json:"size"`
+}
+
+// SaveArtifact writes a cached artifact to output as a portable archive: a tarball
+// of its cache metadata and of an OCI layout holding every blob its index reaches.
+// LoadArtifact restores it into another cache under the same ID and digests, and
+// as an OCI layout tarball it can also be pushed directly. target is a cache ID,
+// the reference the artifact was pulled with, or its digest.
+func (c *Client) SaveArtifact(target, output string) (*PortableArchive, error) {
+	artifact, err := c.findCachedArtifact(target)
+	if err != nil {
+		return nil, err
+	}
+	layout := filepath.Join(c.config.CacheDir, artifact.ID)
+	indexData, err := os.ReadFile(filepath.Join(layout, ocispec.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached layout: %w", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse cached layout index: %w", err)
+	}
+	blobs, err := layoutGraph(layout, index)
+	if err != nil {
+		return nil, fmt.Errorf("cached artifact %s is incomplete, run 'ds porter verify --repair' on it: %w", artifact.ID, err)
+	}
+
+	// The cache path is meaningless on the machine the archive is loaded on.
+	saved := *artifact
+	saved.LocalPath = ""
+	metadata, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	layoutFile, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OCI layout: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".porter-save-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create portable archive: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	// Entries carry the time the artifact was cached, so saving it twice produces
+	// the same archive.
+	modTime := artifact.CachedAt.UTC()
+	tw := tar.NewWriter(tmp)
+	for _, file := range []archiveFile{
+		{Name: PortableMetadataFile, Data: metadata},
+		{Name: ocispec.ImageLayoutFile, Data: layoutFile},
+		{Name: ocispec.ImageIndexFile, Data: indexData},
+	} {
+		if err := writeTarFile(tw, file.Name, file.Data, modTime); err != nil {
+			return nil, fmt.Errorf("failed to write portable archive: %w", err)
+		}
+	}
+	result := &PortableArchive{Path: output, Artifact: &saved, Blobs: len(blobs)}
+	for _, desc := range blobs {
+		if err := writeTarBlob(tw, layout, desc, modTime); err != nil {
+			return nil, err
+		}
+		result.Size += desc.Size
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write portable archive: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to write portable archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write portable archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return nil, fmt.Errorf("failed to place portable archive: %w", err)
+	}
+	c.markAccessed(artifact.ID)
+
+	c.logger.Info("Saved cached artifact", "id", artifact.ID, "digest", artifact.Digest, "path", output, "blobs", result.Blobs, "size", result.Size)
+	return result, nil
+}
+
+// writeTarFile writes data to tw as a regular file named name.
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// writeTarBlob copies the blob desc of layout into tw, failing when the cached
+// bytes no longer match their digest.
+func writeTarBlob(tw *tar.Writer, layout string, desc ocispec.Descriptor, modTime time.Time) error {
+	f, err := os.Open(blobPath(layout, desc.Digest))
+	if err != nil {
+		return fmt.Errorf("failed to open blob %s: %w", desc.Digest, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	name := archiveBlobName(desc.Digest)
+	header := &tar.Header{Name: name, Mode: 0644, Size: desc.Size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write portable archive: %w", err)
+	}
+	verifier := desc.Digest.Verifier()
+	if _, err := io.Copy(tw, io.TeeReader(io.LimitReader(f, desc.Size), verifier)); err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", desc.Digest, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("cached blob %s is corrupt, run 'ds porter verify --repair' on the artifact", desc.Digest)
+	}
+	return nil
+}
+
+// layoutGraph returns every blob reachable from index in layout, each once, and
+// fails when one of them is missing.
+func layoutGraph(layout string, index ocispec.Index) ([]ocispec.Descriptor, error) {
+	var blobs []ocispec.Descriptor
+	seen := make(map[digest.Digest]struct{})
+	var walk func(desc ocispec.Descriptor) error
+	walk = func(desc ocispec.Descriptor) error {
+		if err := desc.Digest.Validate(); err != nil {
+			return fmt.Errorf("invalid digest %q: %w", desc.Digest, err)
+		}
+		if _, ok := seen[desc.Digest]; ok {
+			return nil
+		}
+		seen[desc.Digest] = struct{}{}
+		info, err := os.Stat(blobPath(layout, desc.Digest))
+		if err != nil {
+			return fmt.Errorf("blob %s is missing", desc.Digest)
+		}
+		if info.Size() != desc.Size {
+			return fmt.Errorf("blob %s has %d bytes, expected %d", desc.Digest, info.Size(), desc.Size)
+		}
+		blobs = append(blobs, desc)
+		if !isManifestDescriptor(desc) {
+			return nil
+		}
+		data, err := os.ReadFile(blobPath(layout, desc.Digest))
+		if err != nil {
+			return fmt.Errorf("failed to read manifest %s: %w", desc.Digest, err)
+		}
+		var node struct {
+			Config    *ocispec.Descriptor  `json:"config"`
+			Layers    []ocispec.Descriptor `json:"layers"`
+			Manifests []ocispec.Descriptor `json:"manifests"`
+		}
+		if err := json.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+		}
+		children := append(node.Layers, node.Manifests...)
+		if node.Config != nil {
+			children = append([]ocispec.Descriptor{*node.Config}, children...)
+		}
+		for _, child := range children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, desc := range index.Manifests {
+		if err := walk(desc); err != nil {
+			return nil, err
+		}
+	}
+	return blobs, nil
+}
+
+// LoadArtifact restores the portable archive at source, written by SaveArtifact on
+// another machine, into the cache. The artifact keeps its cache ID, reference and
+// digests, replacing any cached artifact of the same ID. Every blob is verified
+// against its digest, and the artifact is only listed once all of them are in place.
+func (c *Client) LoadArtifact(source string) (*PortableArchive, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open portable archive: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var artifact *ArtifactResult
+	var index *ocispec.Index
+	var indexData []byte
+	var layout string
+	result := &PortableArchive{Path: source}
+
+	// Blobs enter the shared store before the index that references them.
+	storeLock, err := c.lockCache(cacheWideLock, false)
+	if err != nil {
+		return nil, err
+	}
+	defer storeLock.Unlock()
+
+	reader := tar.NewReader(f)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read portable archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		switch {
+		case name == PortableMetadataFile:
+			if artifact != nil {
+				return nil, fmt.Errorf("portable archive has more than one %s", PortableMetadataFile)
+			}
+			artifact = &ArtifactResult{}
+			if err := json.NewDecoder(reader).Decode(artifact); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", PortableMetadataFile, err)
+			}
+			if artifact.ID == "" || filepath.Base(artifact.ID) != artifact.ID || artifact.ID == "." || artifact.ID == ".." || reservedCacheDir(artifact.ID) {
+				return nil, fmt.Errorf("portable archive has invalid cache ID %q", artifact.ID)
+			}
+			artifactLock, err := c.lockCache(artifact.ID, true)
+			if err != nil {
+				return nil, err
+			}
+			defer artifactLock.Unlock()
+			layout = filepath.Join(c.config.CacheDir, artifact.ID)
+			if _, err := c.newLayout(layout); err != nil {
+				return nil, fmt.Errorf("failed to create cache layout: %w", err)
+			}
+			// Unlist a cached artifact of the same ID until the load completes.
+			if err := c.forgetCachedArtifact(artifact.ID); err != nil {
+				return nil, fmt.Errorf("failed to replace cached artifact: %w", err)
+			}
+		case name == ocispec.ImageIndexFile:
+			if indexData, err = io.ReadAll(reader); err != nil {
+				return nil, fmt.Errorf("failed to read portable archive: %w", err)
+			}
+			index = &ocispec.Index{}
+			if err := json.Unmarshal(indexData, index); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", ocispec.ImageIndexFile, err)
+			}
+		case path.Dir(path.Dir(name)) == ocispec.ImageBlobsDir:
+			if layout == "" {
+				return nil, fmt.Errorf("portable archive must start with %s", PortableMetadataFile)
+			}
+			dgst := digest.NewDigestFromEncoded(digest.Algorithm(path.Base(path.Dir(name))), path.Base(name))
+			if err := dgst.Validate(); err != nil {
+				return nil, fmt.Errorf("portable archive holds invalid blob %s: %w", name, err)
+			}
+			if err := loadBlob(reader, blobPath(layout, dgst), dgst); err != nil {
+				return nil, err
+			}
+			result.Blobs++
+			result.Size += header.Size
+		}
+	}
+	if artifact == nil {
+		return nil, fmt.Errorf("portable archive has no %s", PortableMetadataFile)
+	}
+	if index == nil {
+		return nil, fmt.Errorf("portable archive has no %s", ocispec.ImageIndexFile)
+	}
+	if _, err := layoutGraph(layout, *index); err != nil {
+		return nil, fmt.Errorf("portable archive is incomplete: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(layout, ocispec.ImageIndexFile), indexData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write layout index: %w", err)
+	}
+	artifact.LocalPath = layout
+	artifact.Cached = true
+	if _, err := cachedRootDescriptor(artifact); err != nil {
+		return nil, err
+	}
+	if err := c.saveArtifactMetadata(artifact); err != nil {
+		return nil, err
+	}
+	result.Artifact = artifact
+
+	c.logger.Info("Loaded portable archive", "id", artifact.ID, "reference", artifact.Reference, "digest", artifact.Digest, "blobs", result.Blobs)
+	return result, nil
+}
+
+// loadBlob writes the blob read from r to target, keeping a blob already there and
+// refusing bytes that do not match dgst.
+func loadBlob(r io.Reader, target string, dgst digest.Digest) error {
+	if verified, err := digestFile(target); err == nil && verified == dgst {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".porter-blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to load blob %s: %w", dgst, err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(io.MultiWriter(tmp, verifier), r); err != nil {
+		return fmt.Errorf("failed to load blob %s: %w", dgst, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("portable archive blob %s does not match its digest", dgst)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to load blob %s: %w", dgst, err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to place blob %s: %w", dgst, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoadArtifact(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool v1"), nil)
+	source := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	pulled, err := source.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+
+	archivePath := filepath.Join(t.TempDir(), "tool.tar")
+	saved, err := source.SaveArtifact(ref, archivePath)
+	require.NoError(t, err)
+	assert.Equal(t, pulled.ID, saved.Artifact.ID)
+	assert.Equal(t, 3, saved.Blobs)
+	assert.Empty(t, saved.Artifact.LocalPath)
+
+	// Saving again produces the same bytes.
+	first, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+	_, err = source.SaveArtifact(pulled.ID, archivePath)
+	require.NoError(t, err)
+	second, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	target := newTestClient(t, nil)
+	loaded, err := target.LoadArtifact(archivePath)
+	require.NoError(t, err)
+	assert.Equal(t, pulled.ID, loaded.Artifact.ID)
+	assert.Equal(t, pulled.Digest, loaded.Artifact.Digest)
+	assert.Equal(t, ref, loaded.Artifact.Reference)
+	assert.Equal(t, filepath.Join(target.config.CacheDir, pulled.ID), loaded.Artifact.LocalPath)
+
+	report, err := target.VerifyCachedArtifact(context.Background(), pulled.ID, VerifyOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid, report.Errors)
+
+	outDir := t.TempDir()
+	_, err = target.ExportArtifact(context.Background(), loaded.Artifact, outDir, ExportOptions{})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(outDir, "tool"))
+	require.NoError(t, err)
+	assert.Equal(t, "tool v1", string(data))
+}
+
+func TestLoadArtifactRejectsCorruptBlobs(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool v1"), nil)
+	source := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	_, err := source.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	archivePath := filepath.Join(t.TempDir(), "tool.tar")
+	_, err = source.SaveArtifact(ref, archivePath)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+	idx := bytes.Index(data, []byte("tool v1"))
+	require.GreaterOrEqual(t, idx, 0)
+	data[idx] = 'T'
+	require.NoError(t, os.WriteFile(archivePath, data, 0644))
+
+	target := newTestClient(t, nil)
+	_, err = target.LoadArtifact(archivePath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match its digest")
+	artifacts, err := target.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
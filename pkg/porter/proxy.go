@@ -0,0 +1,178 @@
+package porter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/delivery-station/porter/pkg/reference"
+	"golang.org/x/net/http/httpproxy"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// ProxyDirect, as a registry's proxy, bypasses every proxy for that registry.
+const ProxyDirect = "direct"
+
+// ProxyConfig selects the proxies used for traffic without a registry-specific
+// proxy. Empty fields fall back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables.
+type ProxyConfig struct {
+	HTTPProxy  string `json:"http_proxy,omitempty"`
+	HTTPSProxy string `json:"https_proxy,omitempty"`
+	NoProxy    string `json:"no_proxy,omitempty"`
+	// PAC is the URL or path of a proxy auto-config script. It replaces the fields
+	// above for registries without a proxy of their own.
+	PAC string `json:"pac,omitempty"`
+	// Username and Password authenticate to proxies whose URL has no credentials.
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+}
+
+// proxyConfigFromDS combines the DS proxy section with the PAC script and proxy
+// credentials in plugins.settings.porter.proxy.
+func proxyConfigFromDS(dsConfig *types.Config) ProxyConfig {
+	settings, _ := dsConfig.Plugins.Settings["porter"]["proxy"].(map[string]interface{})
+	return ProxyConfig{
+		HTTPProxy:  strings.TrimSpace(dsConfig.Proxy.HTTPProxy),
+		HTTPSProxy: strings.TrimSpace(dsConfig.Proxy.HTTPSProxy),
+		NoProxy:    strings.TrimSpace(dsConfig.Proxy.NoProxy),
+		PAC:        settingString(settings, "pac"),
+		Username:   settingString(settings, "username"),
+		Password:   settingString(settings, "password"),
+	}
+}
+
+// applyRegistryProxies sets the proxy of each registry in proxies, which maps
+// registry names to proxy URLs, adding registries that have no other settings.
+func applyRegistryProxies(registries []RegistryConfig, proxies map[string]string) []RegistryConfig {
+	for registry, proxy := range proxies {
+		host := reference.NormalizeRegistry(registry)
+		if host == "" {
+			continue
+		}
+		found := false
+		for i := range registries {
+			if reference.NormalizeRegistry(registries[i].URL) == host || reference.NormalizeRegistry(registries[i].Name) == host {
+				registries[i].Proxy = proxy
+				found = true
+			}
+		}
+		if !found {
+			registries = append(registries, RegistryConfig{Name: host, URL: host, Proxy: proxy})
+		}
+	}
+	return registries
+}
+
+// httpClient returns the HTTP client used for traffic to registry, with the
+// registry's proxy and client certificates. Clients share one transport per proxy,
+// or per registry when it has its own TLS settings, so connections are reused.
+// When fault injection is enabled the faults sit beneath the retry layer so
+// retries are exercised. Requests wait out the registry's throttle, and throttled
+// requests are retried after the Retry-After the registry sends.
+func (c *Client) httpClient(registry string) *http.Client {
+	if err := c.network().err; err != nil {
+		return &http.Client{Transport: failingTransport{err: errNetwork(err)}}
+	}
+	tlsConfig, err := c.registryTLS(registry)
+	if err != nil {
+		return &http.Client{Transport: failingTransport{err: err}}
+	}
+	proxyKey, proxy := c.proxyFor(registry)
+	key := transportKey(proxyKey, registry, tlsConfig)
+
+	c.transportsMu.Lock()
+	transport, ok := c.transports[key]
+	if !ok {
+		base := c.baseTransport(proxy)
+		if tlsConfig != nil {
+			base.TLSClientConfig = tlsConfig
+		}
+		transport = base
+		if c.transports == nil {
+			c.transports = make(map[string]http.RoundTripper)
+		}
+		c.transports[key] = transport
+	}
+	c.transportsMu.Unlock()
+
+	if c.faults != nil {
+		transport = c.faults.Transport(transport)
+	}
+	if c.timeouts.request > 0 {
+		transport = &requestTimeoutTransport{base: transport, timeout: c.timeouts.request}
+	}
+	if c.bandwidth != nil {
+		transport = &bandwidthTransport{base: transport, limiter: c.bandwidth}
+	}
+	transport = &throttleTransport{base: transport, throttle: c.throttleFor(registry), registry: registry, logger: c.logger}
+	transport = &rateLimitTransport{base: transport, registry: registry}
+	transport = &tracingTransport{base: transport, registry: registry, tracer: c.tracer()}
+	transport = &metricsTransport{base: transport}
+	return &http.Client{Transport: &retry.Transport{
+		Base:   transport,
+		Policy: func() retry.Policy { return throttlePolicy{} },
+	}}
+}
+
+// proxyFor returns the proxy function for registry and a key identifying it. A
+// registry-specific proxy takes precedence over a PAC script, which takes
+// precedence over the configured and environment defaults. An invalid proxy URL
+// fails every request rather than bypassing it.
+func (c *Client) proxyFor(registry string) (string, func(*http.Request) (*url.URL, error)) {
+	if proxy := c.registryProxy(registry); proxy != "" {
+		if strings.EqualFold(proxy, ProxyDirect) {
+			return ProxyDirect, nil
+		}
+		proxyURL, err := parseProxyURL(proxy)
+		if err != nil {
+			return "invalid:" + proxy, func(*http.Request) (*url.URL, error) { return nil, err }
+		}
+		return proxyURL.String(), c.withProxyAuth(http.ProxyURL(proxyURL))
+	}
+
+	if script := c.network().script; script != nil {
+		return "pac", c.withProxyAuth(pacProxy(script))
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if value := strings.TrimSpace(c.config.Proxy.HTTPProxy); value != "" {
+		cfg.HTTPProxy = value
+	}
+	if value := strings.TrimSpace(c.config.Proxy.HTTPSProxy); value != "" {
+		cfg.HTTPSProxy = value
+	}
+	if value := strings.TrimSpace(c.config.Proxy.NoProxy); value != "" {
+		cfg.NoProxy = value
+	}
+	proxy := cfg.ProxyFunc()
+	return "default", c.withProxyAuth(func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	})
+}
+
+// registryProxy returns the proxy configured for registry, if any.
+func (c *Client) registryProxy(registry string) string {
+	reg, _ := c.registryConfig(registry)
+	return strings.TrimSpace(reg.Proxy)
+}
+
+// parseProxyURL parses a proxy URL, defaulting to the http scheme like the
+// standard environment variables do.
+func parseProxyURL(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", reference.Redact(value))
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: unsupported scheme %s", reference.Redact(value), proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
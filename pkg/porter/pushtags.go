@@ -0,0 +1,191 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// PushConfig holds the defaults of push.
+type PushConfig struct {
+	// NoLatest stops pushes from also tagging their index "latest", for registries
+	// where patch releases of older lines are published.
+	NoLatest bool `json:"no_latest,omitempty"`
+	// ChunkSize, such as "100MiB", uploads blobs at least that large in chunks of
+	// that size, one request each, for proxies that time out long uploads. Empty
+	// uploads every blob in a single request.
+	ChunkSize string `json:"chunk_size,omitempty"`
+}
+
+// pushConfigFromSettings reads plugins.settings.porter.push. tag_latest defaults
+// to true.
+func pushConfigFromSettings(settings map[string]interface{}) PushConfig {
+	push, _ := settings["push"].(map[string]interface{})
+	cfg := PushConfig{ChunkSize: settingString(push, "chunk_size")}
+	if _, ok := push["tag_latest"]; ok {
+		cfg.NoLatest = !settingBool(push, "tag_latest")
+	}
+	return cfg
+}
+
+// TagLatest reports whether a push with opts also tags "latest": opts.TagLatest
+// when set, otherwise unless the configuration sets push.tag_latest to false.
+func (c *Client) TagLatest(opts PushOptions) bool {
+	if opts.TagLatest != nil {
+		return *opts.TagLatest
+	}
+	return !c.config.Push.NoLatest
+}
+
+// UploadSessionsDir is where pushes record their chunked upload sessions, so a
+// failed push resumes the blob it left unfinished when run again.
+func (c *Client) UploadSessionsDir() string {
+	return filepath.Join(c.config.CacheDir, UploadSessionsDir)
+}
+
+// CheckPushTags reports whether the pushed index may also be tagged with each of
+// tags in the repository of ref: every tag must be valid and pass the naming
+// policy, like the destination itself.
+func (c *Client) CheckPushTags(ref string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	for _, tag := range tags {
+		if !reference.IsTag(tag) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		tagged := parsed
+		tagged.Tag, tagged.Digest = tag, ""
+		if err := c.CheckNaming(tagged.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordPushTags lists the extra tags of a push in its result metadata.
+func recordPushTags(metadata map[string]string, tags []string) {
+	if len(tags) > 0 {
+		metadata["pushed.tags"] = strings.Join(tags, ",")
+	}
+}
+
+// ApplySemverAliases adds the semantic version aliases of the destination tag of
+// ref to opts.Tags when opts.SemverAliases is set: "1.4" and "1" for "1.4.2", with
+// any "v" prefix kept. Each alias, and "latest" when the push tags it, only moves
+// when no version the repository already has is higher within its line, so a
+// patch release of an older line leaves the newer aliases alone. A pre-release
+// gets no aliases and never moves "latest". Aliases derive from a tag the naming
+// policy already accepted and are not checked against it.
+func (c *Client) ApplySemverAliases(ctx context.Context, ref string, opts PushOptions) (PushOptions, error) {
+	if !opts.SemverAliases {
+		return opts, nil
+	}
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return opts, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	if !semverTag.MatchString(parsed.Tag) {
+		return opts, fmt.Errorf("--semver-aliases requires a semantic version tag such as 1.4.2, not %q", parsed.Tag)
+	}
+	version, err := parseVersion(parsed.Tag)
+	if err != nil {
+		return opts, err
+	}
+	opts.Tags = append([]string(nil), opts.Tags...)
+	if version.prerelease != "" {
+		tagLatest := false
+		opts.TagLatest = &tagLatest
+		return opts, nil
+	}
+
+	existing, err := c.repositoryTags(ctx, ref, opts.Insecure)
+	if err != nil {
+		return opts, err
+	}
+	newerMinor, newerMajor, newer := false, false, false
+	for _, tag := range existing {
+		if !semverTag.MatchString(tag) {
+			continue
+		}
+		other, err := parseVersion(tag)
+		if err != nil || other.prerelease != "" || other.compare(version) <= 0 {
+			continue
+		}
+		newer = true
+		if other.parts[0] == version.parts[0] {
+			newerMajor = true
+			if other.parts[1] == version.parts[1] {
+				newerMinor = true
+			}
+		}
+	}
+
+	prefix := ""
+	if strings.HasPrefix(parsed.Tag, "v") {
+		prefix = "v"
+	}
+	if !newerMinor {
+		opts.Tags = appendTag(opts.Tags, fmt.Sprintf("%s%d.%d", prefix, version.parts[0], version.parts[1]))
+	}
+	if !newerMajor {
+		opts.Tags = appendTag(opts.Tags, fmt.Sprintf("%s%d", prefix, version.parts[0]))
+	}
+	tagLatest := c.TagLatest(opts) && !newer
+	opts.TagLatest = &tagLatest
+	return opts, nil
+}
+
+func appendTag(tags []string, tag string) []string {
+	for _, existing := range tags {
+		if existing == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+// repositoryTags lists the tags of the repository of ref; a repository that does
+// not exist yet has none.
+func (c *Client) repositoryTags(ctx context.Context, ref string, insecure bool) ([]string, error) {
+	parsedRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	repo, err := c.remoteRepository(parsedRef, insecure)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	list := func() error {
+		tags = nil
+		return repo.Tags(ctx, "", func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+	}
+	err = list()
+	if err != nil && !insecure && isPlainHTTPResponseError(err) {
+		repo.PlainHTTP = true
+		err = list()
+	}
+	var errResp *errcode.ErrorResponse
+	if errors.Is(err, errdef.ErrNotFound) || (errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", parsedRef.Context().Name(), err)
+	}
+	return tags, nil
+}
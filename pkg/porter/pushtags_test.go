@@ -0,0 +1,92 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushTagLatest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, []byte("tool"), 0o755))
+	registry := newTestRegistry(t)
+	noLatest := false
+
+	client := newTestClient(t, nil)
+	_, err := client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:1.4.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+	_, err = client.PushArtifact(context.Background(), file, registry.Host()+"/team/patched:1.3.9", PushOptions{Insecure: true, TagLatest: &noLatest})
+	require.NoError(t, err)
+
+	configured, err := NewClient(&Config{CacheDir: t.TempDir(), Push: pushConfigFromSettings(map[string]interface{}{
+		"push": map[string]interface{}{"tag_latest": false},
+	})}, hclog.NewNullLogger())
+	require.NoError(t, err)
+	_, err = configured.PushArtifact(context.Background(), file, registry.Host()+"/team/configured:1.3.9", PushOptions{Insecure: true})
+	require.NoError(t, err)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	assert.Contains(t, registry.tags["team/tool"], "latest")
+	assert.Equal(t, map[string]string{"1.3.9": registry.tags["team/patched"]["1.3.9"]}, registry.tags["team/patched"])
+	assert.NotContains(t, registry.tags["team/configured"], "latest")
+}
+
+func TestPushExtraTags(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, []byte("tool"), 0o755))
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+
+	result, err := client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:v1.4.2", PushOptions{Insecure: true, Tags: []string{"v1.4", "stable", "v1.4.2"}})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4,stable,v1.4.2", result.Metadata["pushed.tags"])
+
+	pulled, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:v1.4.2", true)
+	require.NoError(t, err)
+	_, err = client.PushFromCache(context.Background(), pulled.ID, registry.Host()+"/mirror/tool:v1.4.2", PushOptions{Insecure: true, Tags: []string{"stable"}})
+	require.NoError(t, err)
+
+	_, err = client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:v1.4.3", PushOptions{Insecure: true, Tags: []string{"not a tag"}})
+	require.Error(t, err)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	tags := registry.tags["team/tool"]
+	assert.Len(t, tags, 4)
+	for _, tag := range []string{"v1.4", "stable", "latest"} {
+		assert.Equal(t, tags["v1.4.2"], tags[tag], tag)
+	}
+	assert.Equal(t, tags["v1.4.2"], registry.tags["mirror/tool"]["stable"])
+}
+
+func TestPushSemverAliases(t *testing.T) {
+	dir := t.TempDir()
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+	digests := map[string]string{}
+	for _, version := range []string{"v1.4.2", "v1.3.9", "v2.0.0-rc.1"} {
+		file := filepath.Join(dir, version)
+		require.NoError(t, os.WriteFile(file, []byte("tool "+version), 0o755))
+		result, err := client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:"+version, PushOptions{Insecure: true, SemverAliases: true})
+		require.NoError(t, err)
+		digests[version] = result.Digest
+	}
+	_, err := client.PushArtifact(context.Background(), filepath.Join(dir, "v1.4.2"), registry.Host()+"/team/tool:nightly", PushOptions{Insecure: true, SemverAliases: true})
+	require.Error(t, err)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	tags := registry.tags["team/tool"]
+	assert.Len(t, tags, 7)
+	assert.Equal(t, digests["v1.4.2"], tags["v1.4"])
+	assert.Equal(t, digests["v1.4.2"], tags["v1"], "an older line leaves the major alias alone")
+	assert.Equal(t, digests["v1.4.2"], tags["latest"])
+	assert.Equal(t, digests["v1.3.9"], tags["v1.3"])
+	assert.Equal(t, digests["v2.0.0-rc.1"], tags["v2.0.0-rc.1"])
+}
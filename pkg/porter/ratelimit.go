@@ -0,0 +1,137 @@
+package porter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers in which Docker Hub reports the pull rate limit of the client, as
+// "<count>;w=<window seconds>", and what the limit is counted against: the
+// client's IP address or account.
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitSourceHeader    = "Docker-RateLimit-Source"
+)
+
+// rateLimitLowShare is the share of the limit below which pulls warn that the
+// remaining pulls are running out.
+const rateLimitLowShare = 0.1
+
+// RateLimit is the pull rate limit a registry reported with a pull, as Docker
+// Hub does for anonymous and free accounts.
+type RateLimit struct {
+	Registry      string `json:"registry"`
+	Limit         int    `json:"limit"`
+	Remaining     int    `json:"remaining"`
+	WindowSeconds int    `json:"window_seconds,omitempty"`
+	Source        string `json:"source,omitempty"`
+}
+
+// String describes the limit, e.g. "76 of 100 pulls left per 6h0m0s".
+func (r *RateLimit) String() string {
+	s := fmt.Sprintf("%d of %d pulls left", r.Remaining, r.Limit)
+	if r.WindowSeconds > 0 {
+		s += fmt.Sprintf(" per %s", time.Duration(r.WindowSeconds)*time.Second)
+	}
+	return s
+}
+
+// Low reports whether at most a tenth of the limit is left.
+func (r *RateLimit) Low() bool {
+	return float64(r.Remaining) <= float64(r.Limit)*rateLimitLowShare
+}
+
+// parseRateLimit reads the rate limit headers of a registry response. It reports
+// false when the registry sent none.
+func parseRateLimit(header http.Header) (*RateLimit, bool) {
+	limit, window, ok := parseRateLimitValue(header.Get(RateLimitLimitHeader))
+	if !ok {
+		return nil, false
+	}
+	remaining, _, ok := parseRateLimitValue(header.Get(RateLimitRemainingHeader))
+	if !ok {
+		return nil, false
+	}
+	source := strings.TrimSpace(header.Get(RateLimitSourceHeader))
+	return &RateLimit{Limit: limit, Remaining: remaining, WindowSeconds: window, Source: source}, true
+}
+
+// parseRateLimitValue parses "<count>;w=<window seconds>"; the window is optional.
+func parseRateLimitValue(value string) (int, int, bool) {
+	countPart, params, _ := strings.Cut(value, ";")
+	count, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || count < 0 {
+		return 0, 0, false
+	}
+	window := 0
+	for _, param := range strings.Split(params, ";") {
+		if key, val, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "w" {
+			window, _ = strconv.Atoi(strings.TrimSpace(val))
+		}
+	}
+	return count, window, true
+}
+
+// rateLimitTransport records the rate limit each registry response reports in
+// the recorder of the request's operation.
+type rateLimitTransport struct {
+	base     http.RoundTripper
+	registry string
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if limit, ok := parseRateLimit(resp.Header); ok {
+		limit.Registry = t.registry
+		operationRecorderFrom(req.Context()).observeRateLimit(limit)
+	}
+	return resp, err
+}
+
+// observeRateLimit keeps limit as the latest rate limit of the operation.
+func (r *operationRecorder) observeRateLimit(limit *RateLimit) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.limit = limit
+	r.mu.Unlock()
+}
+
+// rateLimit returns the latest rate limit of the operation, or nil when the
+// registry reported none.
+func (r *operationRecorder) rateLimit() *RateLimit {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limit
+}
+
+// checkRateLimit warns when a pull left few pulls of the registry's rate limit,
+// and explains a failed pull once none are left, rather than leaving the
+// registry's 429 to speak for itself.
+func (c *Client) checkRateLimit(limit *RateLimit, err error) error {
+	if limit == nil {
+		return err
+	}
+	if limit.Remaining == 0 {
+		if err != nil {
+			return fmt.Errorf("%s pull rate limit exhausted (%s); log in to raise it or pull through a mirror: %w", limit.Registry, limit, err)
+		}
+		c.logger.Warn("Registry pull rate limit exhausted", "registry", limit.Registry, "limit", limit.Limit, "window_seconds", limit.WindowSeconds, "source", limit.Source)
+		return nil
+	}
+	if limit.Low() {
+		c.logger.Warn("Registry pull rate limit nearly exhausted", "registry", limit.Registry, "remaining", limit.Remaining, "limit", limit.Limit, "window_seconds", limit.WindowSeconds, "source", limit.Source)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	header := http.Header{}
+	header.Set(RateLimitLimitHeader, "100;w=21600")
+	header.Set(RateLimitRemainingHeader, "76;w=21600")
+	header.Set(RateLimitSourceHeader, "203.0.113.7")
+
+	limit, ok := parseRateLimit(header)
+	require.True(t, ok)
+	assert.Equal(t, &RateLimit{Limit: 100, Remaining: 76, WindowSeconds: 21600, Source: "203.0.113.7"}, limit)
+	assert.Equal(t, "76 of 100 pulls left per 6h0m0s", limit.String())
+	assert.False(t, limit.Low())
+
+	header.Del(RateLimitRemainingHeader)
+	_, ok = parseRateLimit(header)
+	assert.False(t, ok)
+}
+
+func TestPullReportsRateLimit(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("library/tool", "1.0.0", "application/octet-stream", []byte("layer"), nil)
+	registry.headers = http.Header{
+		RateLimitLimitHeader:     {"100;w=21600"},
+		RateLimitRemainingHeader: {"4;w=21600"},
+	}
+	// The first manifest request is throttled; the pull backs off and tries again.
+	throttled := false
+	registry.throttle = func(req *http.Request) bool {
+		if throttled || !strings.Contains(req.URL.Path, "/manifests/") {
+			return false
+		}
+		throttled = true
+		return true
+	}
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/library/tool:1.0.0", true)
+	require.NoError(t, err)
+	require.NotNil(t, result.RateLimit)
+	assert.Equal(t, registry.Host(), result.RateLimit.Registry)
+	assert.Equal(t, 4, result.RateLimit.Remaining)
+	assert.True(t, result.RateLimit.Low())
+	registry.mu.Lock()
+	assert.True(t, throttled)
+	registry.mu.Unlock()
+
+	cached, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	assert.Nil(t, cached[0].RateLimit)
+}
+
+func TestCheckRateLimitExplainsExhaustedLimit(t *testing.T) {
+	client := newTestClient(t, nil)
+	cause := errors.New("response status code 429: toomanyrequests")
+
+	err := client.checkRateLimit(&RateLimit{Registry: "docker.io", Limit: 100, Remaining: 0, WindowSeconds: 21600}, cause)
+	assert.ErrorIs(t, err, cause)
+	assert.EqualError(t, err, "docker.io pull rate limit exhausted (0 of 100 pulls left per 6h0m0s); log in to raise it or pull through a mirror: response status code 429: toomanyrequests")
+
+	assert.Equal(t, cause, client.checkRateLimit(&RateLimit{Registry: "docker.io", Limit: 100, Remaining: 40}, cause))
+	assert.Equal(t, cause, client.checkRateLimit(nil, cause))
+}
@@ -0,0 +1,177 @@
+package porter
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minSecretLength keeps very short values, which would mangle unrelated text,
+// from being scrubbed as literals. The patterns below still cover them where
+// they appear as credentials.
+const minSecretLength = 4
+
+// Credentials that leak through text whatever their value: Authorization headers,
+// URL userinfo, and token-like query parameters.
+var (
+	authorizationPattern = regexp.MustCompile(`(?i)(authorization"?\s*[:=]\s*"?(?:bearer|basic)\s+)[^\s",]+`)
+	userinfoPattern      = regexp.MustCompile(`(?i)([a-z][a-z0-9+.-]*://)[^/\s@"]+@`)
+	queryPattern         = regexp.MustCompile(`(?i)([?&][^=&\s"]*(?:token|password|secret|signature|credential|key)[^=&\s"]*=)[^&\s"]+`)
+)
+
+// Redactor scrubs secrets from log output, error strings, and results: the
+// values it was given, in their raw, JSON-escaped, and URL-escaped forms, and
+// credentials recognizable by their shape. It is safe for concurrent use.
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor for secrets.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{secrets: make(map[string]bool)}
+	r.Add(secrets...)
+	return r
+}
+
+// Add registers more secrets, such as credentials resolved during a run.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	added := false
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			continue
+		}
+		escaped, _ := json.Marshal(secret)
+		for _, form := range []string{secret, string(escaped[1 : len(escaped)-1]), url.QueryEscape(secret)} {
+			if !r.secrets[form] {
+				r.secrets[form] = true
+				added = true
+			}
+		}
+	}
+	if !added {
+		return
+	}
+	forms := make([]string, 0, len(r.secrets))
+	for form := range r.secrets {
+		forms = append(forms, form)
+	}
+	// Longer forms first, so a secret containing another is replaced whole.
+	sort.Slice(forms, func(i, j int) bool {
+		if len(forms[i]) != len(forms[j]) {
+			return len(forms[i]) > len(forms[j])
+		}
+		return forms[i] < forms[j]
+	})
+	pairs := make([]string, 0, 2*len(forms))
+	for _, form := range forms {
+		pairs = append(pairs, form, redactedValue)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with every secret replaced by the redaction marker.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+	s = authorizationPattern.ReplaceAllString(s, "${1}"+redactedValue)
+	s = userinfoPattern.ReplaceAllString(s, "${1}redacted@")
+	return queryPattern.ReplaceAllString(s, "${1}redacted")
+}
+
+// Error returns err with its message redacted. errors.Is and errors.As still see
+// the original chain.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Writer returns a writer that redacts everything written before passing it to
+// w. hclog writes each entry in one call, so no secret is split across writes.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{redactor: r, w: w}
+}
+
+type redactingWriter struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Secrets lists the secrets cfg carries: registry passwords and tokens, proxy
+// credentials, export destination credentials, and the result signing key.
+func (cfg *Config) Secrets() []string {
+	var secrets []string
+	addURL := func(value string) {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				secrets = append(secrets, password)
+			}
+		}
+	}
+	for _, reg := range cfg.Registries {
+		secrets = append(secrets, reg.Password, reg.Token)
+		addURL(reg.Proxy)
+	}
+	addURL(cfg.Proxy.HTTPProxy)
+	addURL(cfg.Proxy.HTTPSProxy)
+	secrets = append(secrets,
+		cfg.Proxy.Password,
+		cfg.Export.S3.SecretAccessKey,
+		cfg.Export.S3.SessionToken,
+		cfg.Export.SSH.Password,
+		cfg.Export.SSH.KeyPassphrase,
+		cfg.ResultSigningKey,
+	)
+	for _, value := range cfg.Tracing.Headers {
+		secrets = append(secrets, value)
+	}
+	return secrets
+}
+
+// redactResult scrubs secrets from the reference and metadata of result, which
+// may echo a reference or an endpoint that carried credentials.
+func (c *Client) redactResult(result *ArtifactResult) *ArtifactResult {
+	if result == nil {
+		return nil
+	}
+	result.Reference = c.redactor.Redact(result.Reference)
+	for key, value := range result.Metadata {
+		result.Metadata[key] = c.redactor.Redact(value)
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/delivery-station/porter/pkg/release"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// Referrer sources reported by ListReferrers.
+const (
+	ReferrerSourceAPI       = "referrers"
+	ReferrerSourceCosignTag = "cosign-tag"
+)
+
+// ReferrerInfo describes an artifact attached to a subject manifest.
+type ReferrerInfo struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"media_type"`
+	ArtifactType string            `json:"artifact_type,omitempty"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Source       string            `json:"source"`
+	Tag          string            `json:"tag,omitempty"`
+}
+
+// ReferrersReport lists the artifacts attached to a reference.
+type ReferrersReport struct {
+	Reference string         `json:"reference"`
+	Subject   string         `json:"subject"`
+	Referrers []ReferrerInfo `json:"referrers"`
+}
+
+// ListReferrers queries the referrers API for ref, falling back to the referrers tag
+// schema on registries without it. Cosign signatures stored under the
+// sha256-<digest>.sig tag are included as well. A non-empty artifactType filters the result.
+func (c *Client) ListReferrers(ctx context.Context, ref string, insecure bool, artifactType string) (*ReferrersReport, error) {
+	imgRef, err := c.parseReference(ref, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	repo, subject, err := c.resolveRemote(ctx, imgRef, ref, insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReferrersReport{
+		Reference: ref,
+		Subject:   subject.Digest.String(),
+		Referrers: []ReferrerInfo{},
+	}
+
+	err = repo.Referrers(ctx, subject, artifactType, func(referrers []ocispec.Descriptor) error {
+		for _, desc := range referrers {
+			report.Referrers = append(report.Referrers, ReferrerInfo{
+				Digest:       desc.Digest.String(),
+				MediaType:    desc.MediaType,
+				ArtifactType: desc.ArtifactType,
+				Size:         desc.Size,
+				Annotations:  desc.Annotations,
+				Source:       ReferrerSourceAPI,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %s: %w", ref, err)
+	}
+
+	if artifactType == "" || artifactType == release.MediaTypeCosignSimpleSigning {
+		signature, err := c.cosignSignature(ctx, repo, subject)
+		if err != nil {
+			return nil, err
+		}
+		if signature != nil {
+			report.Referrers = append(report.Referrers, *signature)
+		}
+	}
+
+	c.logger.Debug("Listed referrers", "ref", ref, "subject", report.Subject, "count", len(report.Referrers))
+	return report, nil
+}
+
+// cosignSignature looks up a cosign signature stored under the tag schema.
+func (c *Client) cosignSignature(ctx context.Context, repo *remote.Repository, subject ocispec.Descriptor) (*ReferrerInfo, error) {
+	tag := release.SignatureTag(subject.Digest)
+	desc, err := repo.Resolve(ctx, tag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve signature tag %s: %w", tag, err)
+	}
+
+	manifest, err := fetchManifest(ctx, repo, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cosign records the signature and certificate on the payload layer.
+	annotations := make(map[string]string)
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+	for _, layer := range manifest.Layers {
+		for k, v := range layer.Annotations {
+			annotations[k] = v
+		}
+	}
+
+	return &ReferrerInfo{
+		Digest:       desc.Digest.String(),
+		MediaType:    desc.MediaType,
+		ArtifactType: release.MediaTypeCosignSimpleSigning,
+		Size:         desc.Size,
+		Annotations:  annotations,
+		Source:       ReferrerSourceCosignTag,
+		Tag:          tag,
+	}, nil
+}
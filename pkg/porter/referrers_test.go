@@ -0,0 +1,107 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delivery-station/porter/pkg/release"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListReferrers(t *testing.T) {
+	registry := newTestRegistry(t)
+	subject := registry.AddArtifact("team/app", "1.0.0", "application/octet-stream", []byte("binary"), nil)
+
+	sbom := filepath.Join(t.TempDir(), "sbom.spdx.json")
+	require.NoError(t, os.WriteFile(sbom, []byte(`{"spdxVersion":"SPDX-2.3"}`), 0644))
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/app:1.0.0"
+	attached, err := client.AttachArtifact(context.Background(), ref, []string{sbom}, AttachOptions{ArtifactType: release.ArtifactTypeSPDXJSON, Insecure: true})
+	require.NoError(t, err)
+
+	payload := registry.AddBlob(release.MediaTypeCosignSimpleSigning, []byte(`{}`))
+	payload.Annotations = map[string]string{release.AnnotationCosignSignature: "c2ln"}
+	registry.AddManifest("team/app", release.SignatureTag(subject.Digest), ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    registry.AddBlob(ocispec.MediaTypeImageConfig, []byte(`{}`)),
+		Layers:    []ocispec.Descriptor{payload},
+	})
+
+	report, err := client.ListReferrers(context.Background(), ref, true, "")
+	require.NoError(t, err)
+	assert.Equal(t, subject.Digest.String(), report.Subject)
+	require.Len(t, report.Referrers, 2)
+	assert.Equal(t, attached.Digest, report.Referrers[0].Digest)
+	assert.Equal(t, release.ArtifactTypeSPDXJSON, report.Referrers[0].ArtifactType)
+	assert.Equal(t, ReferrerSourceAPI, report.Referrers[0].Source)
+	assert.Equal(t, ReferrerSourceCosignTag, report.Referrers[1].Source)
+	assert.Equal(t, "c2ln", report.Referrers[1].Annotations[release.AnnotationCosignSignature])
+
+	filtered, err := client.ListReferrers(context.Background(), ref, true, release.ArtifactTypeSPDXJSON)
+	require.NoError(t, err)
+	require.Len(t, filtered.Referrers, 1)
+	assert.Equal(t, attached.Digest, filtered.Referrers[0].Digest)
+}
+
+func TestListReferrersNone(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/app", "1.0.0", "application/octet-stream", []byte("binary"), nil)
+
+	client := newTestClient(t, nil)
+	report, err := client.ListReferrers(context.Background(), registry.Host()+"/team/app:1.0.0", true, "")
+	require.NoError(t, err)
+	assert.Empty(t, report.Referrers)
+}
+
+func TestPushAttachesSBOMs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("linux tool"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bom.cdx.json"), []byte(`{"bomFormat":"CycloneDX"}`), 0o644))
+	manifestPath := filepath.Join(dir, DefaultManifestFile)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`sboms:
+  - bom.cdx.json
+manifests:
+  - platform: linux/amd64
+    path: tool
+`), 0o644))
+	spdx := filepath.Join(t.TempDir(), "sbom.spdx.json")
+	require.NoError(t, os.WriteFile(spdx, []byte(`{"spdxVersion":"SPDX-2.3"}`), 0o644))
+
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+
+	// PushArtifact and the progress-reporting Pusher.Push share the attachment.
+	ref := registry.Host() + "/team/tool:1.0.0"
+	_, err := client.PushArtifact(context.Background(), manifestPath, ref, PushOptions{Insecure: true, SBOMs: []string{spdx}})
+	require.NoError(t, err)
+	report, err := client.ListReferrers(context.Background(), ref, true, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{release.ArtifactTypeCycloneDXJSON, release.ArtifactTypeSPDXJSON}, referrerTypes(report))
+
+	ref = registry.Host() + "/team/tool:2.0.0"
+	pusher, err := release.NewPusher(release.ReleaseConfig{Reference: ref, ManifestPath: manifestPath, SBOMs: []string{spdx}, Insecure: true})
+	require.NoError(t, err)
+	var progress bytes.Buffer
+	require.NoError(t, pusher.Push(context.Background(), &progress))
+	assert.Equal(t, 2, strings.Count(progress.String(), "✓ Attached"), progress.String())
+	report, err = client.ListReferrers(context.Background(), ref, true, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{release.ArtifactTypeCycloneDXJSON, release.ArtifactTypeSPDXJSON}, referrerTypes(report))
+}
+
+func referrerTypes(report *ReferrersReport) []string {
+	types := make([]string, 0, len(report.Referrers))
+	for _, referrer := range report.Referrers {
+		types = append(types, referrer.ArtifactType)
+	}
+	return types
+}
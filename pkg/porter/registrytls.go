@@ -0,0 +1,130 @@
+package porter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+)
+
+// applyRegistrySettings merges plugins.settings.porter.registries, which maps a
+// registry to its proxy and TLS settings, into registries:
+//
+//	registries:
+//	  registry.internal:
+//	    client_cert: /etc/porter/client.pem
+//	    client_key: /etc/porter/client-key.pem
+//	    ca_cert: /etc/porter/internal-ca.pem
+//
+// Registries without credentials are added.
+func applyRegistrySettings(registries []RegistryConfig, settings map[string]interface{}) []RegistryConfig {
+	configured, _ := settings["registries"].(map[string]interface{})
+	for registry, value := range configured {
+		host := reference.NormalizeRegistry(registry)
+		options, _ := value.(map[string]interface{})
+		if host == "" || options == nil {
+			continue
+		}
+		apply := func(reg *RegistryConfig) {
+			if proxy := settingString(options, "proxy"); proxy != "" {
+				reg.Proxy = proxy
+			}
+			if cert := settingString(options, "client_cert"); cert != "" {
+				reg.ClientCert = cert
+			}
+			if key := settingString(options, "client_key"); key != "" {
+				reg.ClientKey = key
+			}
+			if ca := settingString(options, "ca_cert"); ca != "" {
+				reg.CACert = ca
+			}
+		}
+
+		found := false
+		for i := range registries {
+			if reference.NormalizeRegistry(registries[i].URL) == host || reference.NormalizeRegistry(registries[i].Name) == host {
+				apply(&registries[i])
+				found = true
+			}
+		}
+		if !found {
+			reg := RegistryConfig{Name: host, URL: host}
+			apply(&reg)
+			registries = append(registries, reg)
+		}
+	}
+	return registries
+}
+
+// registryTLS returns the TLS configuration for registry, or nil when it needs none
+// beyond the system defaults.
+func (c *Client) registryTLS(registry string) (*tls.Config, error) {
+	reg, ok := c.registryConfig(registry)
+	if !ok || (reg.ClientCert == "" && reg.ClientKey == "" && reg.CACert == "") {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if reg.ClientCert != "" || reg.ClientKey != "" {
+		if reg.ClientCert == "" || reg.ClientKey == "" {
+			return nil, fmt.Errorf("registry %s needs both client_cert and client_key", reg.Name)
+		}
+		cert, err := tls.LoadX509KeyPair(expandHome(reg.ClientCert), expandHome(reg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate for %s: %w", reg.Name, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if reg.CACert != "" {
+		pem, err := os.ReadFile(expandHome(reg.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate for %s: %w", reg.Name, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA certificate for %s", reg.Name)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// registryConfig returns the configuration entry of registry.
+func (c *Client) registryConfig(registry string) (RegistryConfig, bool) {
+	normalized := reference.NormalizeRegistry(registry)
+	if normalized == "" {
+		return RegistryConfig{}, false
+	}
+	for _, reg := range c.config.Registries {
+		if normalized == reference.NormalizeRegistry(reg.URL) || normalized == reference.NormalizeRegistry(reg.Name) {
+			return reg, true
+		}
+	}
+	return RegistryConfig{}, false
+}
+
+// failingTransport fails every request with err, so a registry whose transport
+// cannot be configured is never contacted with the wrong settings.
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// transportKey identifies the transport of registry: registries with their own TLS
+// settings get a dedicated transport, the others share one per proxy.
+func transportKey(proxyKey, registry string, tlsConfig *tls.Config) string {
+	if tlsConfig == nil {
+		return proxyKey
+	}
+	return proxyKey + "|tls:" + strings.ToLower(reference.NormalizeRegistry(registry))
+}
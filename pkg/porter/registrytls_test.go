@@ -0,0 +1,104 @@
+package porter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCertificate creates a self-signed client certificate and returns the
+// certificate and key file paths along with the parsed certificate.
+func writeClientCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "porter-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certPath, keyPath, cert
+}
+
+func TestApplyRegistrySettings(t *testing.T) {
+	registries := []RegistryConfig{{Name: "registry.internal", URL: "registry.internal", Username: "ci"}}
+	registries = applyRegistrySettings(registries, map[string]interface{}{
+		"registries": map[string]interface{}{
+			"https://registry.internal": map[string]interface{}{
+				"client_cert": "/etc/porter/client.pem",
+				"client_key":  "/etc/porter/client-key.pem",
+			},
+			"ghcr.io": map[string]interface{}{"ca_cert": "/etc/porter/ca.pem", "proxy": ProxyDirect},
+			"ignored": "not a map",
+		},
+	})
+
+	require.Len(t, registries, 2)
+	assert.Equal(t, "ci", registries[0].Username)
+	assert.Equal(t, "/etc/porter/client.pem", registries[0].ClientCert)
+	assert.Equal(t, "/etc/porter/client-key.pem", registries[0].ClientKey)
+	assert.Equal(t, RegistryConfig{Name: "ghcr.io", URL: "ghcr.io", CACert: "/etc/porter/ca.pem", Proxy: ProxyDirect}, registries[1])
+}
+
+func TestPullWithClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCertificate(t, dir)
+
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool"), nil)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(registry.serveHTTP))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	caPath := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	ref := host + "/team/tool:1.0.0"
+
+	client := newTestClient(t, []RegistryConfig{{Name: host, URL: host, ClientCert: certPath, ClientKey: keyPath, CACert: caPath}})
+	result, err := client.PullArtifact(context.Background(), ref, false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Digest)
+
+	withoutCert := newTestClient(t, []RegistryConfig{{Name: host, URL: host, CACert: caPath}})
+	_, err = withoutCert.PullArtifact(context.Background(), ref, false)
+	assert.Error(t, err)
+
+	missingKey := newTestClient(t, []RegistryConfig{{Name: host, URL: host, ClientCert: certPath}})
+	_, err = missingKey.PullArtifact(context.Background(), ref, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs both client_cert and client_key")
+}
@@ -0,0 +1,106 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// RepositoryListOptions filters and pages ListRepositories.
+type RepositoryListOptions struct {
+	Insecure bool
+	// Prefix keeps the repositories whose name starts with it, such as "team/".
+	Prefix string
+	// Last lists the repositories after this one, continuing from the Next of a
+	// previous page.
+	Last string
+	// Limit bounds how many repositories are returned; zero returns all of them.
+	Limit int
+}
+
+// RepositoryList is the result of ListRepositories.
+type RepositoryList struct {
+	Registry     string   `json:"registry"`
+	Repositories []string `json:"repositories"`
+	// Next is the Last of the following page, set when Limit cut the list short.
+	Next string `json:"next,omitempty"`
+}
+
+// errCatalogUnsupported reports a registry that does not offer the catalog API.
+var errCatalogUnsupported = errors.New("the registry does not offer the catalog API")
+
+// errRepositoryPageFull stops the catalog listing once a page holds Limit
+// repositories.
+var errRepositoryPageFull = errors.New("repository page full")
+
+// ListRepositories lists the repositories of registry through the _catalog
+// endpoint, following its pagination. Registries such as Docker Hub and GHCR do
+// not offer the endpoint, or only to administrators, which fails the listing.
+func (c *Client) ListRepositories(ctx context.Context, registry string, opts RepositoryListOptions) (*RepositoryList, error) {
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit %d", opts.Limit)
+	}
+	host := reference.NormalizeRegistry(registry)
+	if host == "" {
+		return nil, fmt.Errorf("registry required")
+	}
+	reg, err := remote.NewRegistry(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	username, password := c.resolveCredentials(host)
+	reg.Client = newAuthClient(c.httpClient(host), host, username, password)
+	reg.PlainHTTP = opts.Insecure
+
+	list := &RepositoryList{Registry: host, Repositories: []string{}}
+	collect := func(page []string) error {
+		for _, repository := range page {
+			if !strings.HasPrefix(repository, opts.Prefix) {
+				continue
+			}
+			if opts.Limit > 0 && len(list.Repositories) == opts.Limit {
+				list.Next = list.Repositories[len(list.Repositories)-1]
+				return errRepositoryPageFull
+			}
+			list.Repositories = append(list.Repositories, repository)
+		}
+		return nil
+	}
+	err = reg.Repositories(ctx, opts.Last, collect)
+	if err != nil && !opts.Insecure && isPlainHTTPResponseError(err) {
+		reg.PlainHTTP = true
+		list.Repositories, list.Next = []string{}, ""
+		err = reg.Repositories(ctx, opts.Last, collect)
+	}
+	var errResp *errcode.ErrorResponse
+	switch {
+	case err == nil, errors.Is(err, errRepositoryPageFull):
+	case errors.Is(err, errdef.ErrNotFound) || (errors.As(err, &errResp) && isCatalogUnsupported(errResp)):
+		return nil, fmt.Errorf("failed to list repositories of %s: %w", host, errCatalogUnsupported)
+	default:
+		return nil, fmt.Errorf("failed to list repositories of %s: %w", host, err)
+	}
+	c.logger.Debug("Listed repositories", "registry", host, "count", len(list.Repositories), "next", list.Next)
+	return list, nil
+}
+
+// isCatalogUnsupported reports whether the registry answered the catalog request
+// as one without the endpoint does.
+func isCatalogUnsupported(errResp *errcode.ErrorResponse) bool {
+	if errResp.StatusCode == http.StatusNotFound || errResp.StatusCode == http.StatusMethodNotAllowed {
+		return true
+	}
+	for _, e := range errResp.Errors {
+		if e.Code == errcode.ErrorCodeUnsupported {
+			return true
+		}
+	}
+	return false
+}
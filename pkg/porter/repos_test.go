@@ -0,0 +1,38 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListRepositoriesFiltersAndPages(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.tagPageSize = 2
+	for _, repo := range []string{"infra/agent", "team/cli", "team/plugin", "team/server", "tools/lint"} {
+		registry.AddArtifact(repo, "1.0.0", "application/octet-stream", []byte(repo), nil)
+	}
+	client := newTestClient(t, nil)
+
+	team, err := client.ListRepositories(context.Background(), registry.Host(), RepositoryListOptions{Insecure: true, Prefix: "team/"})
+	require.NoError(t, err)
+	assert.Equal(t, registry.Host(), team.Registry)
+	assert.Equal(t, []string{"team/cli", "team/plugin", "team/server"}, team.Repositories, "every page is followed")
+
+	page, err := client.ListRepositories(context.Background(), registry.Host(), RepositoryListOptions{Insecure: true, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"infra/agent", "team/cli"}, page.Repositories)
+	assert.Equal(t, "team/cli", page.Next)
+	page, err = client.ListRepositories(context.Background(), registry.Host(), RepositoryListOptions{Insecure: true, Last: page.Next})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/plugin", "team/server", "tools/lint"}, page.Repositories)
+	assert.Empty(t, page.Next)
+
+	registry.noCatalog = true
+	_, err = client.ListRepositories(context.Background(), registry.Host(), RepositoryListOptions{Insecure: true})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errCatalogUnsupported))
+}
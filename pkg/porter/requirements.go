@@ -0,0 +1,196 @@
+package porter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Annotations declaring the tooling versions an artifact needs.
+const (
+	AnnotationRequiresPorter = "ds.requires.porter"
+	AnnotationRequiresDS     = "ds.requires.ds"
+)
+
+// Requirement policies, selected with settings.porter.requirement_policy.
+const (
+	// RequirementPolicyEnforce refuses artifacts whose requirements are not met.
+	RequirementPolicyEnforce = "enforce"
+	// RequirementPolicyWarn logs unmet requirements and delivers anyway.
+	RequirementPolicyWarn = "warn"
+	// RequirementPolicyIgnore skips the check.
+	RequirementPolicyIgnore = "ignore"
+)
+
+// DSVersionEnv names the environment variable holding the version of the DS host.
+const DSVersionEnv = "DS_VERSION"
+
+// checkRequirements compares the ds.requires.* annotations of a pulled artifact
+// against the running porter and DS versions. Requirements that cannot be evaluated
+// because a running version is unknown or a development build are logged and skipped.
+func (c *Client) checkRequirements(ref string, annotations map[string]string) error {
+	policy := c.requirementPolicy()
+	if policy == RequirementPolicyIgnore {
+		return nil
+	}
+
+	unmet, err := c.unmetRequirements(ref, annotations)
+	if err != nil {
+		return err
+	}
+	if len(unmet) == 0 {
+		return nil
+	}
+
+	if policy == RequirementPolicyWarn {
+		c.logger.Warn("Artifact requires newer tooling", "ref", ref, "unmet", strings.Join(unmet, "; "))
+		return nil
+	}
+	return fmt.Errorf("artifact %s requires %s", ref, strings.Join(unmet, "; "))
+}
+
+// requirementPolicy returns the configured requirement policy, defaulting to enforce.
+func (c *Client) requirementPolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(c.config.RequirementPolicy))
+	if policy == "" {
+		return RequirementPolicyEnforce
+	}
+	return policy
+}
+
+// unmetRequirements returns a description of each ds.requires.* annotation the
+// running versions do not satisfy.
+func (c *Client) unmetRequirements(ref string, annotations map[string]string) ([]string, error) {
+	checks := []struct {
+		tool       string
+		annotation string
+		running    string
+	}{
+		{"porter", AnnotationRequiresPorter, c.config.Version},
+		{"DS", AnnotationRequiresDS, c.config.DSVersion},
+	}
+
+	var unmet []string
+	for _, check := range checks {
+		constraint := strings.TrimSpace(annotations[check.annotation])
+		if constraint == "" {
+			continue
+		}
+		running := strings.TrimSpace(check.running)
+		if running == "" || running == "dev" {
+			c.logger.Warn("Cannot check version requirement; running version unknown", "tool", check.tool, "requires", constraint)
+			continue
+		}
+
+		ok, err := satisfiesConstraint(running, constraint)
+		if err != nil {
+			return nil, fmt.Errorf("artifact %s has an invalid %s annotation: %w", ref, check.annotation, err)
+		}
+		if !ok {
+			unmet = append(unmet, fmt.Sprintf("%s %s (running %s)", check.tool, constraint, running))
+		}
+	}
+	return unmet, nil
+}
+
+// satisfiesConstraint reports whether version meets every comma-separated clause of
+// constraint. A clause is an operator (>=, >, <=, <, =, ==, !=) followed by a
+// version; a bare version means ">=".
+func satisfiesConstraint(version, constraint string) (bool, error) {
+	running, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, clause := range strings.Split(constraint, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		op := ">="
+		for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+			if strings.HasPrefix(clause, candidate) {
+				op = candidate
+				clause = strings.TrimSpace(clause[len(candidate):])
+				break
+			}
+		}
+		required, err := parseVersion(clause)
+		if err != nil {
+			return false, err
+		}
+
+		cmp := running.compare(required)
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=", "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// semver is a parsed major.minor.patch[-prerelease] version. Build metadata is ignored.
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseVersion(value string) (semver, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(value), "v")
+	trimmed, _, _ = strings.Cut(trimmed, "+")
+	core, prerelease, _ := strings.Cut(trimmed, "-")
+
+	var v semver
+	v.prerelease = prerelease
+	fields := strings.Split(core, ".")
+	if core == "" || len(fields) > 3 {
+		return semver{}, fmt.Errorf("invalid version %q", value)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return semver{}, fmt.Errorf("invalid version %q", value)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
+
+// compare orders versions by semantic versioning precedence, comparing pre-release
+// identifiers as plain strings.
+func (v semver) compare(other semver) int {
+	for i := range v.parts {
+		if v.parts[i] != other.parts[i] {
+			if v.parts[i] < other.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
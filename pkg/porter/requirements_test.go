@@ -0,0 +1,87 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSatisfiesConstraint(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"0.3.0", ">=0.3.0", true},
+		{"0.3.1", ">=0.3.0", true},
+		{"v1.0.0", ">= 0.9", true},
+		{"0.2.9", ">=0.3.0", false},
+		{"0.3.0", "0.3.0", true},
+		{"0.2.0", "0.3", false},
+		{"0.3.0-rc.1", ">=0.3.0", false},
+		{"0.3.0", ">=0.3.0-rc.1", true},
+		{"0.3.0-rc.2", ">=0.3.0-rc.1", true},
+		{"1.2.3+build.7", "=1.2.3", true},
+		{"1.4.0", ">=1.2, <2", true},
+		{"2.0.0", ">=1.2, <2", false},
+		{"1.0.0", ">1.0.0", false},
+		{"1.0.0", "<=1.0.0", true},
+		{"1.0.0", "!=1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.constraint, func(t *testing.T) {
+			got, err := satisfiesConstraint(tt.version, tt.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := satisfiesConstraint("1.0.0", ">=one")
+	assert.Error(t, err)
+	_, err = satisfiesConstraint("1.0.0.0", ">=1")
+	assert.Error(t, err)
+}
+
+func TestPullEnforcesVersionRequirements(t *testing.T) {
+	registry := newTestRegistry(t)
+	layer := registry.AddBlob("application/octet-stream", []byte("tool"))
+	config := registry.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	registry.AddManifest("team/tool", "2.0.0", ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+		Annotations: map[string]string{
+			AnnotationRequiresPorter: ">=0.5.0",
+			AnnotationRequiresDS:     ">=1.8.0",
+		},
+	})
+	ref := registry.Host() + "/team/tool:2.0.0"
+
+	client := newTestClient(t, nil)
+	client.config.Version = "0.4.2"
+	client.config.DSVersion = "1.6.0"
+	_, err := client.PullArtifact(context.Background(), ref, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "porter >=0.5.0 (running 0.4.2)")
+	assert.Contains(t, err.Error(), "DS >=1.8.0 (running 1.6.0)")
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+
+	client.config.RequirementPolicy = RequirementPolicyWarn
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, ">=0.5.0", result.Metadata[AnnotationRequiresPorter])
+
+	client.config.RequirementPolicy = ""
+	client.config.Version = "0.5.0"
+	client.config.DSVersion = ""
+	_, err = client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err, "unknown DS versions are not enforced")
+}
@@ -0,0 +1,108 @@
+package porter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/delivery-station/ds/pkg/types"
+)
+
+// ResultSignaturePrefix marks the trailing Stderr line that carries the HMAC of a signed
+// execution result.
+const ResultSignaturePrefix = "porter-result-hmac-sha256="
+
+// resultSigningKeySetting is the plugins.settings.porter key holding the shared HMAC key.
+const resultSigningKeySetting = "result_signing_key"
+
+// resultSigningKeyFromSettings extracts the raw shared result signing key from DS plugin settings.
+func resultSigningKeyFromSettings(settings map[string]map[string]interface{}) string {
+	raw, _ := settings["porter"][resultSigningKeySetting].(string)
+	return strings.TrimSpace(raw)
+}
+
+// DecodeResultSigningKey converts a configured signing key into HMAC key bytes. Values
+// prefixed with "base64:" are decoded, anything else is used verbatim.
+func DecodeResultSigningKey(raw string) ([]byte, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if encoded, found := strings.CutPrefix(raw, "base64:"); found {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", resultSigningKeySetting, err)
+		}
+		return key, nil
+	}
+	return []byte(raw), nil
+}
+
+// SignExecutionResult appends an HMAC-SHA256 of the result to its Stderr so the DS host
+// can detect tampering by components relaying plugin output. The MAC covers the JSON
+// encoding of the result as it was before the signature line was added.
+func SignExecutionResult(result *types.ExecutionResult, key []byte) error {
+	if result == nil || len(key) == 0 {
+		return nil
+	}
+
+	if result.Stderr != "" && !strings.HasSuffix(result.Stderr, "\n") {
+		result.Stderr += "\n"
+	}
+
+	mac, err := executionResultMAC(result, key)
+	if err != nil {
+		return err
+	}
+	result.Stderr += ResultSignaturePrefix + hex.EncodeToString(mac) + "\n"
+	return nil
+}
+
+// VerifyExecutionResult checks the signature produced by SignExecutionResult and returns
+// the result with the signature line removed.
+func VerifyExecutionResult(result *types.ExecutionResult, key []byte) (*types.ExecutionResult, error) {
+	if result == nil {
+		return nil, fmt.Errorf("execution result required")
+	}
+
+	stderr := strings.TrimSuffix(result.Stderr, "\n")
+	idx := strings.LastIndex(stderr, "\n")
+	line := stderr[idx+1:]
+	encoded, ok := strings.CutPrefix(line, ResultSignaturePrefix)
+	if !ok {
+		return nil, fmt.Errorf("execution result is not signed")
+	}
+	signature, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result signature: %w", err)
+	}
+
+	unsigned := *result
+	unsigned.Stderr = ""
+	if idx >= 0 {
+		unsigned.Stderr = stderr[:idx+1]
+	}
+
+	expected, err := executionResultMAC(&unsigned, key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(signature, expected) {
+		return nil, fmt.Errorf("execution result signature mismatch")
+	}
+	return &unsigned, nil
+}
+
+func executionResultMAC(result *types.ExecutionResult, key []byte) ([]byte, error) {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode execution result: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,60 @@
+package porter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/delivery-station/ds/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyExecutionResult(t *testing.T) {
+	key := []byte("shared-secret")
+	result := &types.ExecutionResult{
+		Stdout:     "{\"id\":\"abc\"}\n",
+		Stderr:     "warning: slow registry",
+		Finalizers: []types.FinalizerRequest{{Name: "runner", Operation: "run", Args: []string{"--id", "abc"}}},
+	}
+
+	require.NoError(t, SignExecutionResult(result, key))
+	assert.Contains(t, result.Stderr, ResultSignaturePrefix)
+
+	verified, err := VerifyExecutionResult(result, key)
+	require.NoError(t, err)
+	assert.Equal(t, "warning: slow registry\n", verified.Stderr)
+	assert.Equal(t, result.Stdout, verified.Stdout)
+
+	_, err = VerifyExecutionResult(result, []byte("other-secret"))
+	assert.ErrorContains(t, err, "mismatch")
+
+	tampered := *result
+	tampered.Stdout = strings.Replace(result.Stdout, "abc", "xyz", 1)
+	_, err = VerifyExecutionResult(&tampered, key)
+	assert.ErrorContains(t, err, "mismatch")
+}
+
+func TestSignExecutionResultWithoutKey(t *testing.T) {
+	result := &types.ExecutionResult{Stdout: "ok"}
+	require.NoError(t, SignExecutionResult(result, nil))
+	assert.Empty(t, result.Stderr)
+
+	_, err := VerifyExecutionResult(result, []byte("key"))
+	assert.ErrorContains(t, err, "not signed")
+}
+
+func TestResultSigningKeyFromSettings(t *testing.T) {
+	cfg := buildConfigFromDS(&types.Config{
+		Plugins: types.PluginsConfig{
+			Settings: map[string]map[string]interface{}{
+				"porter": {"result_signing_key": "base64:c2VjcmV0"},
+			},
+		},
+	})
+	key, err := DecodeResultSigningKey(cfg.ResultSigningKey)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+
+	_, err = DecodeResultSigningKey("base64:!!")
+	assert.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// resumeAttempts bounds how often a single pull resumes a blob after the connection drops.
+const resumeAttempts = 4
+
+// errRangeUnsupported signals that the registry answered in a way porter cannot
+// resume from; the blob is then left to the regular ORAS copy.
+var errRangeUnsupported = errors.New("registry does not support resumable blob downloads")
+
+// partialBlobPath returns where a partially downloaded blob is kept between attempts.
+// Partial files live inside the pull's layout directory, which is keyed by reference
+// and therefore reused when the same pull is retried.
+func partialBlobPath(layoutPath string, dgst digest.Digest) string {
+	return filepath.Join(layoutPath, "partial", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// prepareBlobs returns an ORAS PreCopy hook that reuses blobs cached by other
+// artifacts, when reuseCached is set, and downloads the remaining config and layer blobs resumably, so an
+// interrupted transfer continues with an HTTP Range request instead of restarting.
+func (c *Client) prepareBlobs(layoutPath string, repo *remote.Repository, tracker *progress.Tracker, reuseCached bool) func(context.Context, ocispec.Descriptor) error {
+	reuse := c.reuseCachedBlobs(layoutPath)
+	if !reuseCached {
+		reuse = func(context.Context, ocispec.Descriptor) error { return nil }
+	}
+	return func(ctx context.Context, desc ocispec.Descriptor) error {
+		if err := reuse(ctx, desc); err != nil || isManifestDescriptor(desc) {
+			if errors.Is(err, oras.SkipNode) {
+				tracker.Complete(desc, true)
+			}
+			return err
+		}
+
+		err := c.downloadBlobResumable(ctx, repo, layoutPath, desc, tracker)
+		if errors.Is(err, errRangeUnsupported) {
+			c.logger.Debug("Falling back to regular blob download", "digest", desc.Digest, "error", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		tracker.Complete(desc, false)
+		return oras.SkipNode
+	}
+}
+
+// downloadBlobResumable fetches desc into the layout, continuing from any partial
+// file left by an earlier attempt. Bytes already on disk count as transferred.
+func (c *Client) downloadBlobResumable(ctx context.Context, repo *remote.Repository, layoutPath string, desc ocispec.Descriptor, tracker *progress.Tracker) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", errRangeUnsupported, err)
+	}
+
+	partial := partialBlobPath(layoutPath, desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		return fmt.Errorf("failed to create partial blob directory: %w", err)
+	}
+	if info, err := os.Stat(partial); err == nil && info.Size() <= desc.Size {
+		tracker.Add(info.Size())
+	}
+
+	var err error
+	for attempt := 0; attempt < resumeAttempts; attempt++ {
+		if err = c.resumeBlob(ctx, repo, desc, partial, tracker); err == nil {
+			break
+		}
+		if errors.Is(err, errRangeUnsupported) || ctx.Err() != nil {
+			return err
+		}
+		c.logger.Warn("Blob download interrupted, resuming", "digest", desc.Digest, "attempt", attempt+1, "error", err)
+		operationRecorderFrom(ctx).retry()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download blob %s: %w", desc.Digest, err)
+	}
+
+	if err := verifyFileDigest(partial, desc); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+
+	target := blobPath(layoutPath, desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(partial, target); err != nil {
+		return fmt.Errorf("failed to place blob: %w", err)
+	}
+	return nil
+}
+
+// resumeBlob requests the bytes of desc not yet present in partial and appends them.
+func (c *Client) resumeBlob(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, partial string, tracker *progress.Tracker) error {
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial blob: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	if offset > desc.Size {
+		offset = 0
+	}
+	if offset == desc.Size && offset > 0 {
+		return nil
+	}
+
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	blobURL := fmt.Sprintf("%s://%s/v2/%s/blobs/%s", scheme, repo.Reference.Host(), repo.Reference.Repository, desc.Digest)
+	ctx = auth.AppendRepositoryScope(ctx, repo.Reference, auth.ActionPull)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRangeUnsupported, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := repo.Client.Do(req)
+	if err != nil {
+		if isPlainHTTPResponseError(err) {
+			return fmt.Errorf("%w: %v", errRangeUnsupported, err)
+		}
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return fmt.Errorf("%w: unexpected Content-Range %q", errRangeUnsupported, resp.Header.Get("Content-Range"))
+		}
+		c.logger.Info("Resuming blob download", "digest", desc.Digest, "offset", offset, "size", desc.Size)
+	case http.StatusOK:
+		// The registry ignored the range; start over.
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		return errors.New("partial blob no longer matches the registry")
+	default:
+		return fmt.Errorf("%w: %s", errRangeUnsupported, resp.Status)
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate partial blob: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, tracker.Reader(io.LimitReader(resp.Body, desc.Size-offset))); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// contentRangeStart parses the first byte position of a "bytes start-end/size" header.
+func contentRangeStart(value string) (int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(start, 10, 64)
+	return offset, err == nil
+}
+
+// verifyFileDigest checks that the file at path matches the size and digest of desc.
+func verifyFileDigest(path string, desc ocispec.Descriptor) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	verifier := desc.Digest.Verifier()
+	size, err := io.Copy(verifier, f)
+	if err != nil {
+		return fmt.Errorf("failed to read downloaded blob: %w", err)
+	}
+	if size != desc.Size || !verifier.Verified() {
+		return fmt.Errorf("downloaded blob %s failed verification: digest mismatch", desc.Digest)
+	}
+	return nil
+}
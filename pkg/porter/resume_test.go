@@ -0,0 +1,64 @@
+package porter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullResumesDroppedBlobDownload(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte(strings.Repeat("layer-bytes-", 64))
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	t.Setenv(faults.EnvVar, "drop-after=100,times=1,match="+digest.FromBytes(data).Encoded())
+	client := newTestClient(t, nil)
+
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bytes=100-"}, registry.Ranges())
+
+	stored, err := os.ReadFile(blobPath(result.LocalPath, digest.FromBytes(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+	assert.NoDirExists(t, filepath.Join(result.LocalPath, "partial"))
+}
+
+func TestPullContinuesPartialBlobFromEarlierRun(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte(strings.Repeat("layer-bytes-", 64))
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	pullDir := filepath.Join(client.config.CacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(ref)))[:16])
+	partial := partialBlobPath(pullDir, digest.FromBytes(data))
+	require.NoError(t, os.MkdirAll(filepath.Dir(partial), 0755))
+	require.NoError(t, os.WriteFile(partial, data[:300], 0644))
+
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bytes=300-"}, registry.Ranges())
+
+	stored, err := os.ReadFile(blobPath(result.LocalPath, digest.FromBytes(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+}
+
+func TestContentRangeStart(t *testing.T) {
+	start, ok := contentRangeStart("bytes 100-767/768")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+
+	_, ok = contentRangeStart("items 1-2/3")
+	assert.False(t, ok)
+}
@@ -0,0 +1,380 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+)
+
+// ExportLockFile is the advisory lock porter holds in a shared destination directory
+// while it writes exported files.
+const ExportLockFile = ".porter-export.lock"
+
+const (
+	// userXattrPrefix starts the extended attributes extraction restores.
+	userXattrPrefix = "user."
+)
+
+const (
+	exportLockTimeout = 5 * time.Minute
+	exportLockStale   = 15 * time.Minute
+	busyRetryAttempts = 8
+	busyRetryDelay    = 100 * time.Millisecond
+)
+
+// exportWriter creates exported files. It writes each file to a temporary name in
+// the same directory and renames it into place, so a failed export never leaves a
+// truncated binary behind and readers never observe a partially written one. On
+// network shares the export also holds ExportLockFile.
+type exportWriter struct {
+	shared bool
+	logger hclog.Logger
+	// delivery, when set, records every committed file for differential exports.
+	delivery *delivery
+	// policy decides which permissions extracted files may keep.
+	policy ExtractPolicy
+	// overwrite is the ExportOptions.Overwrite policy for files already present.
+	overwrite string
+	// verified, when set, collects the digest of every committed file.
+	verified *exportVerification
+	// full rewrites every file, even those an interrupted export completed.
+	full bool
+}
+
+// exportFile is an exported file being written. Commit publishes it; Abort discards it.
+type exportFile struct {
+	*os.File
+	path     string
+	mode     os.FileMode
+	writer   exportWriter
+	digester digest.Digester
+	size     int64
+	// expected is the digest the content must have; empty when only the
+	// re-read after writing is checked.
+	expected digest.Digest
+	// modTime and xattrs, when set, are applied before the file is published.
+	modTime time.Time
+	xattrs  map[string]string
+}
+
+// unchanged reports whether path already holds the content expected for it by an
+// earlier delivery, in which case the caller skips writing it.
+func (w exportWriter) unchanged(path string, size int64, mode os.FileMode) bool {
+	return w.delivery.unchanged(path, size, mode)
+}
+
+// claim applies the overwrite policy to path before it is written. It reports
+// false when an existing file must be left alone, and fails under
+// OverwriteErrorIfExists.
+func (w exportWriter) claim(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	switch w.overwrite {
+	case OverwriteSkipExisting:
+		if w.logger != nil {
+			w.logger.Info("Skipped existing file", "path", path)
+		}
+		return false, nil
+	case OverwriteErrorIfExists:
+		return false, fmt.Errorf("%s already exists; use --overwrite or --skip-existing", path)
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("cannot replace directory %s with a file", path)
+	}
+	if w.logger != nil && !w.delivery.delivered(path) {
+		w.logger.Info("Replacing existing file", "path", path)
+	}
+	return true, nil
+}
+
+func (w exportWriter) newFile(f *os.File, path string, mode os.FileMode) *exportFile {
+	return &exportFile{File: f, path: path, mode: mode, writer: w, digester: digest.Canonical.Digester()}
+}
+
+// expect makes Commit fail unless the written content has digest dgst. It must be
+// called before anything is written.
+func (f *exportFile) expect(dgst digest.Digest) {
+	f.expected = dgst
+	if dgst.Algorithm().Available() {
+		f.digester = dgst.Algorithm().Digester()
+	}
+}
+
+// restore makes the published file keep modTime and the user namespace of xattrs,
+// the metadata an archive entry carries. Other namespaces are dropped: they either
+// grant privileges, such as security.capability, or belong to the file system.
+func (f *exportFile) restore(modTime time.Time, xattrs map[string]string) {
+	f.modTime = modTime
+	for name, value := range xattrs {
+		if !strings.HasPrefix(name, userXattrPrefix) {
+			continue
+		}
+		if f.xattrs == nil {
+			f.xattrs = make(map[string]string)
+		}
+		f.xattrs[name] = value
+	}
+}
+
+// Write writes p and adds it to the file's digest.
+func (f *exportFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	_, _ = f.digester.Hash().Write(p[:n])
+	f.size += int64(n)
+	return n, err
+}
+
+// ReadFrom routes io.Copy through Write so copied content is digested too.
+func (f *exportFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{f}, r)
+}
+
+func (w exportWriter) create(path string, mode os.FileMode) (*exportFile, error) {
+	if err := checkNotExecutable(path); err != nil {
+		return nil, err
+	}
+
+	var f *os.File
+	err := retryBusy(func() error {
+		var err error
+		f, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".porter-*")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp ignores mode and the umask; apply the usual 022 umask ourselves.
+	if err := f.Chmod(mode &^ 0022); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return w.newFile(f, path, mode), nil
+}
+
+// Commit flushes the file and renames it over its final path. Content that does not
+// match the expected digest is discarded before it replaces anything; once in
+// place, the file is re-read and removed if the destination altered it.
+func (f *exportFile) Commit() error {
+	written := f.digester.Digest()
+	if f.expected != "" && written != f.expected {
+		f.Abort()
+		return fmt.Errorf("content written for %s has digest %s, expected %s", f.path, written, f.expected)
+	}
+	if err := f.publish(); err != nil {
+		return err
+	}
+	if err := verifyExportedFile(f.path, written); err != nil {
+		_ = os.Remove(f.path)
+		return err
+	}
+	f.writer.verified.add(f.path, written)
+	f.writer.delivery.record(f.path, written, f.size, f.mode)
+	return nil
+}
+
+func (f *exportFile) publish() error {
+	tempPath := f.Name()
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to flush %s: %w", f.path, err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	for name, value := range f.xattrs {
+		if err := setXattr(tempPath, name, value); err != nil && f.writer.logger != nil {
+			f.writer.logger.Debug("Could not restore extended attribute", "path", f.path, "name", name, "error", err)
+		}
+	}
+	if !f.modTime.IsZero() {
+		if err := os.Chtimes(tempPath, time.Time{}, f.modTime); err != nil {
+			_ = os.Remove(tempPath)
+			return fmt.Errorf("failed to set modification time of %s: %w", f.path, err)
+		}
+	}
+	if err := retryBusy(func() error { return os.Rename(tempPath, f.path) }); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to move %s into place: %w", f.path, err)
+	}
+	return nil
+}
+
+// Abort closes the file and removes the temporary copy.
+func (f *exportFile) Abort() {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// replaceWithSymlink creates a symlink to target at a temporary name next to path
+// and renames it over path, so an existing entry is replaced atomically.
+func replaceWithSymlink(target, path string) error {
+	temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.porter-%d", filepath.Base(path), time.Now().UnixNano()))
+	if err := os.Symlink(target, temp); err != nil {
+		return err
+	}
+	if err := retryBusy(func() error { return os.Rename(temp, path) }); err != nil {
+		_ = os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// link makes path a hard link to target, an exported regular file, and records it
+// like a written file. Where the destination cannot hard link, path gets a copy.
+func (w exportWriter) link(target, path string) error {
+	info, err := os.Lstat(target)
+	if err != nil {
+		return fmt.Errorf("failed to stat link target %s: %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("link target %s is not a regular file", target)
+	}
+	if err := replaceWithHardLink(target, path); err != nil {
+		if w.logger != nil {
+			w.logger.Debug("Copying file the destination cannot hard link", "path", path, "target", target, "error", err)
+		}
+		return w.copyFile(target, path, info)
+	}
+	if w.verified == nil && w.delivery == nil {
+		return nil
+	}
+
+	dgst, err := digestFile(path)
+	if err != nil {
+		return err
+	}
+	w.verified.add(path, dgst)
+	w.delivery.record(path, dgst, info.Size(), info.Mode())
+	return nil
+}
+
+// copyFile exports a copy of the regular file source, described by info, to path.
+func (w exportWriter) copyFile(source, path string, info os.FileInfo) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := w.create(path, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	out.modTime = info.ModTime()
+	if _, err := io.Copy(out, in); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return out.Commit()
+}
+
+// digestFile returns the canonical digest of the file at path.
+func digestFile(path string) (digest.Digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	dgst, err := digest.Canonical.FromReader(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return dgst, nil
+}
+
+// replaceWithHardLink links target at a temporary name next to path and renames it
+// over path, so an existing entry is replaced atomically.
+func replaceWithHardLink(target, path string) error {
+	temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.porter-%d", filepath.Base(path), time.Now().UnixNano()))
+	if err := os.Link(target, temp); err != nil {
+		return err
+	}
+	if err := retryBusy(func() error { return os.Rename(temp, path) }); err != nil {
+		_ = os.Remove(temp)
+		return err
+	}
+	// Renaming over a link to the same file succeeds without removing temp.
+	_ = os.Remove(temp)
+	return nil
+}
+
+// retryBusy retries op while the file system reports the target as busy, which SMB
+// and NFS servers do while another host holds the file open.
+func retryBusy(op func() error) error {
+	delay := busyRetryDelay
+	var err error
+	for attempt := 0; attempt < busyRetryAttempts; attempt++ {
+		if err = op(); err == nil || !isBusyError(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return err
+}
+
+func isBusyError(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY)
+}
+
+// acquireExportLock creates the advisory lock file in dir, waiting while another host
+// holds it. Locks older than exportLockStale are assumed abandoned and taken over.
+func acquireExportLock(dir string, logger hclog.Logger) (func(), error) {
+	lockPath := filepath.Join(dir, ExportLockFile)
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("host=%s pid=%d time=%s\n", hostname, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+
+	deadline := time.Now().Add(exportLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, writeErr := f.WriteString(owner)
+			closeErr := f.Close()
+			if writeErr != nil || closeErr != nil {
+				_ = os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to write export lock %s: %w", lockPath, errors.Join(writeErr, closeErr))
+			}
+			return func() {
+				if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+					logger.Warn("Failed to release export lock", "path", lockPath, "error", err)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) && !isBusyError(err) {
+			return nil, fmt.Errorf("failed to create export lock %s: %w", lockPath, err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > exportLockStale {
+			holder, _ := os.ReadFile(lockPath)
+			logger.Warn("Removing stale export lock", "path", lockPath, "holder", strings.TrimSpace(string(holder)))
+			_ = os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(lockPath)
+			return nil, fmt.Errorf("timed out waiting for export lock %s held by %s", lockPath, strings.TrimSpace(string(holder)))
+		}
+		logger.Debug("Waiting for export lock", "path", lockPath)
+		time.Sleep(time.Second)
+	}
+}
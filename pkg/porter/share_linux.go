@@ -0,0 +1,26 @@
+//go:build linux
+
+package porter
+
+import "syscall"
+
+// Magic numbers reported by statfs(2) for network file systems.
+const (
+	nfsSuperMagic  = 0x6969
+	smbSuperMagic  = 0x517b
+	cifsSuperMagic = 0xff534d42
+	smb2SuperMagic = 0xfe534d42
+)
+
+// isNetworkShare reports whether path lives on an NFS or SMB/CIFS mount.
+func isNetworkShare(path string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return false
+	}
+	switch uint32(stat.Type) {
+	case nfsSuperMagic, smbSuperMagic, cifsSuperMagic, smb2SuperMagic:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package porter
+
+// isNetworkShare reports whether path lives on a network share. Detection is only
+// implemented on Linux; elsewhere, use ExportOptions.Shared.
+func isNetworkShare(string) bool {
+	return false
+}
@@ -0,0 +1,150 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportArtifactToSharedDestination(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	previous := filepath.Join(outDir, "tool")
+	require.NoError(t, os.WriteFile(previous, []byte("old"), 0755))
+	before, err := os.Stat(previous)
+	require.NoError(t, err)
+
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{Shared: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{previous}, exported)
+
+	data, err := os.ReadFile(exported[0])
+	require.NoError(t, err)
+	assert.Equal(t, "tool binary", string(data))
+
+	// The old inode is replaced rather than truncated, so open readers keep a consistent file.
+	after, err := os.Stat(previous)
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(before, after))
+
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.Name() == DeliveryManifestFile {
+			continue
+		}
+		assert.NotContains(t, entry.Name(), ".porter-", "temporary files and the lock must be cleaned up")
+	}
+}
+
+func TestAcquireExportLockTakesOverStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, ExportLockFile)
+	require.NoError(t, os.WriteFile(lockPath, []byte("host=gone pid=1\n"), 0644))
+	stale := time.Now().Add(-2 * exportLockStale)
+	require.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	release, err := acquireExportLock(dir, hclog.NewNullLogger())
+	require.NoError(t, err)
+	holder, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(holder), "pid=")
+	assert.NotContains(t, string(holder), "host=gone")
+
+	release()
+	assert.NoFileExists(t, lockPath)
+}
+
+func TestRetryBusy(t *testing.T) {
+	attempts := 0
+	err := retryBusy(func() error {
+		attempts++
+		if attempts < 3 {
+			return &os.PathError{Op: "rename", Path: "tool", Err: syscall.EBUSY}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = retryBusy(func() error {
+		attempts++
+		return os.ErrPermission
+	})
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestFailedExportKeepsPreviousFile(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("new tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+	result, err := newTestClient(t, nil).PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	previous := filepath.Join(outDir, "tool")
+	require.NoError(t, os.WriteFile(previous, []byte("old tool binary"), 0644))
+
+	t.Setenv(faults.EnvVar, "drop-after=4,match="+digest.FromBytes(data).Encoded())
+	_, err = newTestClient(t, nil).ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.Error(t, err)
+
+	kept, err := os.ReadFile(previous)
+	require.NoError(t, err)
+	assert.Equal(t, "old tool binary", string(kept))
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".porter-", "temporary files must be cleaned up")
+	}
+}
+
+func TestExportOverwritePolicies(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+	result, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	existing := filepath.Join(outDir, "tool")
+	require.NoError(t, os.WriteFile(existing, []byte("local build"), 0644))
+
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{Overwrite: OverwriteErrorIfExists})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+
+	exported, err := client.ExportArtifact(context.Background(), result, outDir, ExportOptions{Overwrite: OverwriteSkipExisting})
+	require.NoError(t, err)
+	assert.Empty(t, exported)
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "local build", string(data))
+
+	exported, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{existing}, exported)
+	data, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "tool binary", string(data))
+
+	_, err = client.ExportArtifact(context.Background(), result, outDir, ExportOptions{Overwrite: "clobber"})
+	assert.Error(t, err)
+}
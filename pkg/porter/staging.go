@@ -0,0 +1,44 @@
+package porter
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/delivery-station/porter/pkg/release"
+)
+
+// StagingConfig configures the workspace under the cache that pushes archive
+// directories into.
+type StagingConfig struct {
+	// MaxSize bounds the total size of the archives staged at once, e.g. "4GiB";
+	// empty leaves it unbounded.
+	MaxSize string `json:"max_size,omitempty"`
+	// Spool writes each directory archive to the workspace before uploading it.
+	// By default archives are streamed straight into the upload, and the workspace
+	// only keeps the digests of archived directories.
+	Spool bool `json:"spool,omitempty"`
+}
+
+// stagingConfigFromSettings reads plugins.settings.porter.staging.
+func stagingConfigFromSettings(settings map[string]interface{}) StagingConfig {
+	staging, _ := settings["staging"].(map[string]interface{})
+	return StagingConfig{
+		MaxSize: settingString(staging, "max_size"),
+		Spool:   settingBool(staging, "spool"),
+	}
+}
+
+// openStaging opens the staging workspace of cfg, removing the archives of pushes
+// that died before cleaning up.
+func openStaging(cfg *Config) (*release.Staging, error) {
+	var opts release.StagingOptions
+	if cfg.Staging.MaxSize != "" {
+		maxBytes, err := parseSize(cfg.Staging.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid staging.max_size: %w", err)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	opts.Stream = !cfg.Staging.Spool
+	return release.OpenStaging(filepath.Join(cfg.CacheDir, StagingDir), opts)
+}
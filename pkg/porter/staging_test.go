@@ -0,0 +1,80 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushDirectoryThroughStaging(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("tool v1"), 0o755))
+	registry := newTestRegistry(t)
+
+	for i, spool := range []bool{true, false} {
+		cacheDir := t.TempDir()
+		client, err := NewClient(&Config{CacheDir: cacheDir, Staging: StagingConfig{Spool: spool}}, hclog.NewNullLogger())
+		require.NoError(t, err)
+
+		ref := registry.Host() + "/team/bundle:" + []string{"spooled", "streamed"}[i]
+		_, err = client.PushArtifact(context.Background(), src, ref, PushOptions{Insecure: true})
+		require.NoError(t, err)
+
+		archives, err := filepath.Glob(filepath.Join(cacheDir, StagingDir, "*.tar"))
+		require.NoError(t, err)
+		assert.Empty(t, archives, "spooled archives are removed after the push")
+
+		pulled, err := client.PullArtifact(context.Background(), ref, true)
+		require.NoError(t, err)
+		outDir := t.TempDir()
+		_, err = client.ExportArtifact(context.Background(), pulled, outDir, ExportOptions{})
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(outDir, "bin", "tool"))
+		require.NoError(t, err)
+		assert.Equal(t, "tool v1", string(data))
+	}
+
+	_, err := NewClient(&Config{CacheDir: t.TempDir(), Staging: StagingConfig{MaxSize: "lots"}}, hclog.NewNullLogger())
+	require.Error(t, err)
+}
+
+func TestPushDirectoryRoundTripsHardLinksAndMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are archived as copies on Windows")
+	}
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0o755))
+	tool := filepath.Join(src, "bin", "tool")
+	require.NoError(t, os.WriteFile(tool, []byte("a large tool binary"), 0o755))
+	require.NoError(t, os.Link(tool, filepath.Join(src, "bin", "tool-alias")))
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	require.NoError(t, os.Chtimes(tool, modTime, modTime))
+
+	registry := newTestRegistry(t)
+	client, err := NewClient(&Config{CacheDir: t.TempDir()}, hclog.NewNullLogger())
+	require.NoError(t, err)
+	ref := registry.Host() + "/team/linked:1.0.0"
+	_, err = client.PushArtifact(context.Background(), src, ref, PushOptions{Insecure: true})
+	require.NoError(t, err)
+
+	pulled, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	_, err = client.ExportArtifact(context.Background(), pulled, outDir, ExportOptions{})
+	require.NoError(t, err)
+
+	original, err := os.Stat(filepath.Join(outDir, "bin", "tool"))
+	require.NoError(t, err)
+	alias, err := os.Stat(filepath.Join(outDir, "bin", "tool-alias"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(original, alias), "the alias is extracted as a hard link")
+	assert.True(t, modTime.Equal(original.ModTime()), "got modification time %s", original.ModTime())
+}
@@ -0,0 +1,169 @@
+package porter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/delivery-station/porter/pkg/reference"
+)
+
+// StatsFile holds per-reference pull statistics in the cache directory.
+const StatsFile = "stats.json"
+
+const statsVersion = 1
+
+// ReferenceStats summarizes how pulls of one reference used the cache. CacheHits
+// counts pulls that found every blob in the cache, and HitRatio is the share of
+// pulled bytes served from it.
+type ReferenceStats struct {
+	Reference       string    `json:"reference"`
+	Pulls           int       `json:"pulls"`
+	CacheHits       int       `json:"cache_hits"`
+	BytesDownloaded int64     `json:"bytes_downloaded"`
+	BytesSaved      int64     `json:"bytes_saved"`
+	TransferSeconds float64   `json:"transfer_seconds"`
+	AverageSpeed    float64   `json:"average_bytes_per_second"`
+	HitRatio        float64   `json:"hit_ratio"`
+	LastDigest      string    `json:"last_digest,omitempty"`
+	LastAccess      time.Time `json:"last_access"`
+}
+
+type statsDocument struct {
+	Version    int                        `json:"version"`
+	References map[string]*ReferenceStats `json:"references"`
+}
+
+// statsKey normalizes ref so that spellings of the same reference share statistics.
+func (c *Client) statsKey(ref string) string {
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return reference.Redact(strings.TrimSpace(ref))
+	}
+	return reference.Redact(parsed.String())
+}
+
+// recordPullStats adds a finished pull of ref to the statistics. Failures are only
+// logged: statistics never fail a pull.
+func (c *Client) recordPullStats(ref, digest string, snapshot progress.Snapshot) {
+	doc, err := c.loadStats()
+	if err != nil {
+		c.logger.Warn("Resetting unreadable pull statistics", "error", err)
+		doc = &statsDocument{Version: statsVersion, References: make(map[string]*ReferenceStats)}
+	}
+
+	key := c.statsKey(ref)
+	stats, ok := doc.References[key]
+	if !ok {
+		stats = &ReferenceStats{Reference: key}
+		doc.References[key] = stats
+	}
+
+	downloaded := snapshot.Bytes - snapshot.SkippedBytes
+	stats.Pulls++
+	if snapshot.TotalBlobs > 0 && snapshot.SkippedBlobs == snapshot.TotalBlobs {
+		stats.CacheHits++
+	}
+	stats.BytesDownloaded += downloaded
+	stats.BytesSaved += snapshot.SkippedBytes
+	if downloaded > 0 {
+		stats.TransferSeconds += snapshot.Elapsed.Seconds()
+	}
+	stats.LastDigest = digest
+	stats.LastAccess = time.Now().UTC()
+
+	if err := c.saveStats(doc); err != nil {
+		c.logger.Warn("Failed to save pull statistics", "error", err)
+	}
+}
+
+// PullStats returns the statistics of every pulled reference, most recently used
+// first, or only those matching ref when it is not empty.
+func (c *Client) PullStats(ref string) ([]ReferenceStats, error) {
+	doc, err := c.loadStats()
+	if err != nil {
+		return nil, err
+	}
+
+	var key string
+	if strings.TrimSpace(ref) != "" {
+		key = c.statsKey(ref)
+	}
+
+	stats := make([]ReferenceStats, 0, len(doc.References))
+	for name, entry := range doc.References {
+		if key != "" && name != key {
+			continue
+		}
+		result := *entry
+		if result.TransferSeconds > 0 {
+			result.AverageSpeed = float64(result.BytesDownloaded) / result.TransferSeconds
+		}
+		if total := result.BytesDownloaded + result.BytesSaved; total > 0 {
+			result.HitRatio = float64(result.BytesSaved) / float64(total)
+		}
+		stats = append(stats, result)
+	}
+	if key != "" && len(stats) == 0 {
+		return nil, fmt.Errorf("no statistics recorded for %s", key)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].LastAccess.Equal(stats[j].LastAccess) {
+			return stats[i].LastAccess.After(stats[j].LastAccess)
+		}
+		return stats[i].Reference < stats[j].Reference
+	})
+	return stats, nil
+}
+
+func (c *Client) loadStats() (*statsDocument, error) {
+	doc := &statsDocument{Version: statsVersion, References: make(map[string]*ReferenceStats)}
+	err := readJSONFile(filepath.Join(c.config.CacheDir, StatsFile), doc)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return doc, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read pull statistics: %w", err)
+	case doc.Version != statsVersion:
+		return nil, fmt.Errorf("unsupported pull statistics version %d", doc.Version)
+	}
+	if doc.References == nil {
+		doc.References = make(map[string]*ReferenceStats)
+	}
+	return doc, nil
+}
+
+// saveStats replaces the statistics file atomically so readers never see a partial
+// document.
+func (c *Client) saveStats(doc *statsDocument) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pull statistics: %w", err)
+	}
+	path := filepath.Join(c.config.CacheDir, StatsFile)
+	tmp, err := os.CreateTemp(c.config.CacheDir, ".stats-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write pull statistics: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write pull statistics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write pull statistics: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write pull statistics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullStatsTrackCacheEfficiency(t *testing.T) {
+	registry := newTestRegistry(t)
+	layer := []byte("a layer that is served from the cache on the second pull")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", layer, nil)
+	registry.AddArtifact("team/other", "1.0.0", "application/octet-stream", []byte("other"), nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+
+	_, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	_, err = client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/other:1.0.0", true)
+	require.NoError(t, err)
+
+	stats, err := client.PullStats(ref)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	tool := stats[0]
+	assert.Equal(t, ref, tool.Reference)
+	assert.Equal(t, 2, tool.Pulls)
+	assert.Equal(t, 1, tool.CacheHits)
+	assert.GreaterOrEqual(t, tool.BytesSaved, int64(len(layer)))
+	assert.Greater(t, tool.BytesDownloaded, int64(len(layer)))
+	assert.Greater(t, tool.HitRatio, 0.0)
+	assert.Greater(t, tool.AverageSpeed, 0.0)
+	assert.NotEmpty(t, tool.LastDigest)
+
+	all, err := client.PullStats("")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, registry.Host()+"/team/other:1.0.0", all[0].Reference)
+
+	_, err = client.PullStats(registry.Host() + "/team/unknown:1.0.0")
+	assert.Error(t, err)
+
+	artifacts, err := client.ListCachedArtifacts()
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 2)
+}
@@ -0,0 +1,87 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// StdoutDestination is the export destination that streams the layer of a
+// single-layer artifact to standard output instead of writing files.
+const StdoutDestination = "-"
+
+// StreamedLayer describes the layer WriteArtifact wrote.
+type StreamedLayer struct {
+	Digest    string            `json:"digest"`
+	Size      int64             `json:"size"`
+	MediaType string            `json:"media_type"`
+	Platform  *ocispec.Platform `json:"platform,omitempty"`
+}
+
+// WriteArtifact writes the single layer of the cached result to w, selecting the
+// platform as ExportArtifact does. The selection must resolve to one manifest with
+// one layer. The layer is written as it is read, so when it turns out not to match
+// its digest w has already received it; the returned error reports that.
+func (c *Client) WriteArtifact(ctx context.Context, result *ArtifactResult, w io.Writer, opts ExportOptions) (*StreamedLayer, error) {
+	if err := c.checkHostCapabilities(result.Reference, result.Metadata); err != nil {
+		return nil, err
+	}
+	store, err := oci.New(result.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI store: %w", err)
+	}
+	root, err := store.Resolve(ctx, result.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve artifact descriptor %s: %w", result.Digest, err)
+	}
+
+	manifests, err := c.selectManifests(ctx, store, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) != 1 {
+		return nil, fmt.Errorf("%d platforms match; select one with --platform to stream to stdout", len(manifests))
+	}
+	manifestBytes, err := content.FetchAll(ctx, store, manifests[0].Descriptor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if len(manifest.Layers) != 1 {
+		return nil, fmt.Errorf("expected a single layer to stream, found %d", len(manifest.Layers))
+	}
+
+	layer := manifest.Layers[0]
+	layerReader, err := store.Fetch(ctx, layer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch layer: %w", err)
+	}
+	defer func() {
+		_ = layerReader.Close()
+	}()
+
+	verifier := layer.Digest.Verifier()
+	stream := io.TeeReader(contextReader{ctx, c.faults.Reader(layer.Digest.String(), layerReader)}, verifier)
+	if _, err := io.Copy(w, stream); err != nil {
+		return nil, fmt.Errorf("failed to stream layer: %w", err)
+	}
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("layer %s does not match its digest", layer.Digest)
+	}
+
+	c.logger.Info("Streamed layer", "digest", layer.Digest, "size", layer.Size)
+	return &StreamedLayer{
+		Digest:    layer.Digest.String(),
+		Size:      layer.Size,
+		MediaType: layer.MediaType,
+		Platform:  manifests[0].Platform,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteArtifactStreamsSingleLayer(t *testing.T) {
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	client, result := pullMultiPlatformTool(t, platforms)
+
+	var out bytes.Buffer
+	layer, err := client.WriteArtifact(context.Background(), result, &out, ExportOptions{Platforms: platforms[1:]})
+	require.NoError(t, err)
+	assert.Equal(t, "tool for linux/arm64", out.String())
+	assert.Equal(t, int64(out.Len()), layer.Size)
+	require.NotNil(t, layer.Platform)
+	assert.Equal(t, "arm64", layer.Platform.Architecture)
+
+	out.Reset()
+	_, err = client.WriteArtifact(context.Background(), result, &out, ExportOptions{AllPlatforms: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--platform")
+	assert.Zero(t, out.Len())
+}
@@ -0,0 +1,119 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// TagListOptions filters and pages ListTags.
+type TagListOptions struct {
+	Insecure bool
+	// Match is a regular expression the whole tag must match.
+	Match string
+	// Semver is a version constraint, such as ">=1.2, <2", in the syntax of the
+	// ds.requires.* annotations. Tags that are not versions are left out.
+	Semver string
+	// Last lists the tags after this one, continuing from the Next of a previous page.
+	Last string
+	// Limit bounds how many tags are returned; zero returns all of them.
+	Limit int
+}
+
+// TagList is the result of ListTags.
+type TagList struct {
+	Repository string   `json:"repository"`
+	Tags       []string `json:"tags"`
+	// Next is the Last of the following page, set when Limit cut the list short.
+	Next string `json:"next,omitempty"`
+	// Latest is the highest version among Tags, set when any tag is a version.
+	Latest string `json:"latest,omitempty"`
+}
+
+// errTagPageFull stops the tag listing once a page holds Limit tags.
+var errTagPageFull = errors.New("tag page full")
+
+// ListTags lists the tags of the repository of ref in the order the registry
+// returns them, following the registry's pagination. Any tag or digest of ref is
+// ignored.
+func (c *Client) ListTags(ctx context.Context, ref string, opts TagListOptions) (*TagList, error) {
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit %d", opts.Limit)
+	}
+	var match *regexp.Regexp
+	if opts.Match != "" {
+		re, err := regexp.Compile("^(?:" + opts.Match + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %w", opts.Match, err)
+		}
+		match = re
+	}
+	if opts.Semver != "" {
+		if _, err := satisfiesConstraint("0.0.0", opts.Semver); err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", opts.Semver, err)
+		}
+	}
+
+	parsed, err := reference.ParseWithOptions(ref, reference.Options{Aliases: c.config.Aliases})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	imgRef, err := c.parseReference(ref, opts.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	repo, err := c.remoteRepository(imgRef, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &TagList{Repository: parsed.Name(), Tags: []string{}}
+	var latest semver
+	collect := func(page []string) error {
+		for _, tag := range page {
+			if match != nil && !match.MatchString(tag) {
+				continue
+			}
+			version, versionErr := parseVersion(tag)
+			if opts.Semver != "" {
+				if versionErr != nil {
+					continue
+				}
+				if ok, _ := satisfiesConstraint(tag, opts.Semver); !ok {
+					continue
+				}
+			}
+			if opts.Limit > 0 && len(list.Tags) == opts.Limit {
+				list.Next = list.Tags[len(list.Tags)-1]
+				return errTagPageFull
+			}
+			list.Tags = append(list.Tags, tag)
+			if versionErr == nil && (list.Latest == "" || version.compare(latest) > 0) {
+				list.Latest, latest = tag, version
+			}
+		}
+		return nil
+	}
+	err = repo.Tags(ctx, opts.Last, collect)
+	if err != nil && !opts.Insecure && isPlainHTTPResponseError(err) {
+		repo.PlainHTTP = true
+		list.Tags, list.Latest, list.Next = []string{}, "", ""
+		err = repo.Tags(ctx, opts.Last, collect)
+	}
+	var errResp *errcode.ErrorResponse
+	switch {
+	case err == nil, errors.Is(err, errTagPageFull):
+	case errors.Is(err, errdef.ErrNotFound) || (errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound):
+		return nil, fmt.Errorf("repository %s not found", list.Repository)
+	default:
+		return nil, fmt.Errorf("failed to list tags of %s: %w", list.Repository, err)
+	}
+	c.logger.Debug("Listed tags", "repository", list.Repository, "count", len(list.Tags), "next", list.Next)
+	return list, nil
+}
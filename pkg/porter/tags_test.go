@@ -0,0 +1,46 @@
+package porter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTagsFiltersAndPages(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.tagPageSize = 2
+	for _, tag := range []string{"0.9.0", "1.0.0", "1.1.0-rc.1", "1.1.0", "2.0.0", "latest", "nightly-42"} {
+		registry.AddArtifact("team/plugin", tag, "application/octet-stream", []byte(tag), nil)
+	}
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/plugin:ignored"
+
+	all, err := client.ListTags(context.Background(), ref, TagListOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0.9.0", "1.0.0", "1.1.0", "1.1.0-rc.1", "2.0.0", "latest", "nightly-42"}, all.Tags, "every page is followed")
+	assert.Equal(t, "2.0.0", all.Latest)
+	assert.Empty(t, all.Next)
+
+	compatible, err := client.ListTags(context.Background(), ref, TagListOptions{Insecure: true, Semver: ">=1.0.0, <2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0", "1.1.0", "1.1.0-rc.1"}, compatible.Tags)
+	assert.Equal(t, "1.1.0", compatible.Latest)
+
+	nightly, err := client.ListTags(context.Background(), ref, TagListOptions{Insecure: true, Match: `nightly-\d+`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nightly-42"}, nightly.Tags)
+	assert.Empty(t, nightly.Latest)
+
+	page, err := client.ListTags(context.Background(), ref, TagListOptions{Insecure: true, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0.9.0", "1.0.0", "1.1.0"}, page.Tags)
+	assert.Equal(t, "1.1.0", page.Next)
+	page, err = client.ListTags(context.Background(), ref, TagListOptions{Insecure: true, Limit: 3, Last: page.Next})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.1.0-rc.1", "2.0.0", "latest"}, page.Tags)
+
+	_, err = client.ListTags(context.Background(), ref, TagListOptions{Insecure: true, Semver: ">=banana"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,432 @@
+package porter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is a minimal in-memory implementation of the OCI distribution API
+// used to exercise pull, push, and inspection paths end to end.
+type testRegistry struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]testManifest
+	tags      map[string]map[string]string
+	uploads   map[string]*bytes.Buffer
+	// uploadSeq numbers upload sessions so concurrent pushes never share an ID.
+	uploadSeq int
+	// failPatches makes that many upload PATCH requests keep half their body and
+	// then fail, as a proxy dropping the connection would.
+	failPatches int
+	// throttle, when set, answers the requests it matches with 429 Too Many
+	// Requests and a one second Retry-After.
+	throttle func(req *http.Request) bool
+	// tagPageSize, when set, caps tag and catalog pages that request no size.
+	tagPageSize int
+	// noCatalog answers catalog requests with 404, as Docker Hub does.
+	noCatalog bool
+	requests  []string
+	ranges    []string
+
+	// headers are added to every response, as Docker Hub adds its rate limit.
+	headers http.Header
+}
+
+type testManifest struct {
+	mediaType string
+	data      []byte
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{
+		t:         t,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]testManifest),
+		tags:      make(map[string]map[string]string),
+		uploads:   make(map[string]*bytes.Buffer),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// newTestClient returns a client with registries configured and its own cache and
+// credentials file. It logs nowhere, so test output stays quiet.
+func newTestClient(t *testing.T, registries []RegistryConfig) *Client {
+	t.Helper()
+	client, err := NewClient(&Config{
+		CacheDir:        t.TempDir(),
+		Registries:      registries,
+		CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+	return client
+}
+
+// Host returns the host:port of the registry for use in references.
+func (r *testRegistry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// Requests returns the "METHOD path" log of requests served so far.
+func (r *testRegistry) Requests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+// Ranges returns the Range headers of blob requests served so far.
+func (r *testRegistry) Ranges() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ranges...)
+}
+
+// AddBlob stores a blob and returns its descriptor.
+func (r *testRegistry) AddBlob(mediaType string, data []byte) ocispec.Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	r.blobs[desc.Digest.String()] = data
+	return desc
+}
+
+// AddManifest stores a manifest or index and optionally tags it.
+func (r *testRegistry) AddManifest(repo, tag, mediaType string, value interface{}) ocispec.Descriptor {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.t.Fatalf("failed to marshal manifest: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	r.manifests[desc.Digest.String()] = testManifest{mediaType: mediaType, data: data}
+	if tag != "" {
+		r.tagLocked(repo, tag, desc.Digest.String())
+	}
+	return desc
+}
+
+// AddArtifact publishes a single-layer artifact manifest and returns the manifest descriptor.
+func (r *testRegistry) AddArtifact(repo, tag, layerMediaType string, layer []byte, annotations map[string]string) ocispec.Descriptor {
+	layerDesc := r.AddBlob(layerMediaType, layer)
+	layerDesc.Annotations = annotations
+	configDesc := r.AddBlob(ocispec.MediaTypeEmptyJSON, []byte("{}"))
+	return r.AddManifest(repo, tag, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: layerMediaType,
+		Config:       configDesc,
+		Layers:       []ocispec.Descriptor{layerDesc},
+	})
+}
+
+func (r *testRegistry) tagLocked(repo, tag, dgst string) {
+	if r.tags[repo] == nil {
+		r.tags[repo] = make(map[string]string)
+	}
+	r.tags[repo][tag] = dgst
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	throttled := r.throttle != nil && r.throttle(req)
+	for key, values := range r.headers {
+		w.Header()[key] = values
+	}
+	r.mu.Unlock()
+	if throttled {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	path := req.URL.Path
+	if path == "/v2/" || path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if !strings.HasPrefix(path, "/v2/") {
+		http.NotFound(w, req)
+		return
+	}
+	path = strings.TrimPrefix(path, "/v2/")
+	if path == "_catalog" {
+		r.serveCatalog(w, req)
+		return
+	}
+
+	for _, marker := range []string{"/manifests/", "/blobs/uploads/", "/blobs/", "/tags/list", "/referrers/"} {
+		idx := strings.LastIndex(path, marker)
+		if idx < 0 {
+			continue
+		}
+		repo := path[:idx]
+		rest := path[idx+len(marker):]
+		switch marker {
+		case "/manifests/":
+			r.serveManifest(w, req, repo, rest)
+		case "/blobs/uploads/":
+			r.serveUpload(w, req, repo, rest)
+		case "/blobs/":
+			r.serveBlob(w, req, rest)
+		case "/tags/list":
+			r.serveTags(w, req, repo)
+		case "/referrers/":
+			r.serveReferrers(w, rest)
+		}
+		return
+	}
+	http.NotFound(w, req)
+}
+
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		dgst := ref
+		if _, err := digest.Parse(ref); err != nil {
+			dgst = r.tags[repo][ref]
+		}
+		m, ok := r.manifests[dgst]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(m.data)))
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(m.data)
+		}
+	case http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		dgst := digest.FromBytes(data).String()
+		r.manifests[dgst] = testManifest{mediaType: req.Header.Get("Content-Type"), data: data}
+		if _, err := digest.Parse(ref); err != nil {
+			r.tagLocked(repo, ref, dgst)
+		}
+		var probe struct {
+			Subject *ocispec.Descriptor `json:"subject"`
+		}
+		if json.Unmarshal(data, &probe) == nil && probe.Subject != nil {
+			w.Header().Set("OCI-Subject", probe.Subject.Digest.String())
+		}
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(r.manifests, ref)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *testRegistry) serveBlob(w http.ResponseWriter, req *http.Request, dgst string) {
+	r.mu.Lock()
+	data, ok := r.blobs[dgst]
+	r.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", dgst)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if rangeHeader := req.Header.Get("Range"); rangeHeader != "" && req.Method == http.MethodGet {
+		r.mu.Lock()
+		r.ranges = append(r.ranges, rangeHeader)
+		r.mu.Unlock()
+		var start int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil || start >= len(data) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(data[start:])
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func (r *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repo, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodPost:
+		if dgst := req.URL.Query().Get("digest"); dgst != "" {
+			data, _ := io.ReadAll(req.Body)
+			r.blobs[dgst] = data
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		r.uploadSeq++
+		id = fmt.Sprintf("upload-%d", r.uploadSeq)
+		r.uploads[id] = &bytes.Buffer{}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch:
+		buf, ok := r.uploads[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if start, _, ok := strings.Cut(req.Header.Get("Content-Range"), "-"); ok && start != fmt.Sprint(buf.Len()) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if r.failPatches > 0 {
+			r.failPatches--
+			data, _ := io.ReadAll(req.Body)
+			buf.Write(data[:len(data)/2])
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.Copy(buf, req.Body)
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.Header().Set("Range", fmt.Sprintf("0-%d", buf.Len()-1))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodGet:
+		buf, ok := r.uploads[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		if buf.Len() > 0 {
+			w.Header().Set("Range", fmt.Sprintf("0-%d", buf.Len()-1))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		buf, ok := r.uploads[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.Copy(buf, req.Body)
+		dgst := req.URL.Query().Get("digest")
+		if digest.FromBytes(buf.Bytes()).String() != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[dgst] = buf.Bytes()
+		delete(r.uploads, id)
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveTags lists the tags of repo a page at a time.
+func (r *testRegistry) serveTags(w http.ResponseWriter, req *http.Request, repo string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tags := make([]string, 0, len(r.tags[repo]))
+	for tag := range r.tags[repo] {
+		tags = append(tags, tag)
+	}
+	tags = r.pageLocked(w, req, "/v2/"+repo+"/tags/list", tags)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+}
+
+// serveCatalog lists the repositories that have tags a page at a time, or answers
+// 404 as registries without the catalog API do.
+func (r *testRegistry) serveCatalog(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.noCatalog {
+		http.NotFound(w, req)
+		return
+	}
+	repositories := make([]string, 0, len(r.tags))
+	for repo := range r.tags {
+		repositories = append(repositories, repo)
+	}
+	repositories = r.pageLocked(w, req, "/v2/_catalog", repositories)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"repositories": repositories})
+}
+
+// pageLocked sorts values and returns those after the last query parameter, in
+// pages of n, linking the next page as registries do.
+func (r *testRegistry) pageLocked(w http.ResponseWriter, req *http.Request, path string, values []string) []string {
+	last := req.URL.Query().Get("last")
+	page := make([]string, 0, len(values))
+	for _, value := range values {
+		if value > last {
+			page = append(page, value)
+		}
+	}
+	sort.Strings(page)
+	n, err := strconv.Atoi(req.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		n = r.tagPageSize
+	}
+	if n > 0 && len(page) > n {
+		page = page[:n]
+		w.Header().Set("Link", fmt.Sprintf(`<%s?n=%d&last=%s>; rel="next"`, path, n, page[n-1]))
+	}
+	return page
+}
+
+func (r *testRegistry) serveReferrers(w http.ResponseWriter, subject string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{},
+	}
+	for dgst, m := range r.manifests {
+		var probe ocispec.Manifest
+		if json.Unmarshal(m.data, &probe) != nil || probe.Subject == nil || probe.Subject.Digest.String() != subject {
+			continue
+		}
+		artifactType := probe.ArtifactType
+		if artifactType == "" {
+			artifactType = probe.Config.MediaType
+		}
+		index.Manifests = append(index.Manifests, ocispec.Descriptor{
+			MediaType:    m.mediaType,
+			ArtifactType: artifactType,
+			Digest:       digest.Digest(dgst),
+			Size:         int64(len(m.data)),
+			Annotations:  probe.Annotations,
+		})
+	}
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	_ = json.NewEncoder(w).Encode(index)
+}
@@ -0,0 +1,147 @@
+package porter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+const (
+	// throttleRetries bounds how often one request is retried while the registry
+	// keeps throttling it.
+	throttleRetries = 8
+	// maxRetryAfter caps the wait a single Retry-After header can impose.
+	maxRetryAfter = 2 * time.Minute
+	// maxThrottleBackoff caps the wait after a 429 response without Retry-After.
+	maxThrottleBackoff = time.Minute
+)
+
+// throttle holds back every request to one registry until the time its last
+// throttling response asked clients to wait for, so the parallel uploads of a
+// push pause together instead of each running into the limit.
+type throttle struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// wait blocks until the throttle lifts or the request is cancelled.
+func (t *throttle) wait(req *http.Request) error {
+	t.mu.Lock()
+	delay := time.Until(t.until)
+	t.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// extend moves the throttle to delay from now, unless it already lasts longer.
+func (t *throttle) extend(delay time.Duration) {
+	until := time.Now().Add(delay)
+	t.mu.Lock()
+	if until.After(t.until) {
+		t.until = until
+	}
+	t.mu.Unlock()
+}
+
+// throttleFor returns the throttle shared by all traffic to registry.
+func (c *Client) throttleFor(registry string) *throttle {
+	c.transportsMu.Lock()
+	defer c.transportsMu.Unlock()
+	if c.throttles == nil {
+		c.throttles = make(map[string]*throttle)
+	}
+	t, ok := c.throttles[registry]
+	if !ok {
+		t = &throttle{}
+		c.throttles[registry] = t
+	}
+	return t
+}
+
+// throttleTransport waits out the registry's throttle before each request and
+// extends it by the Retry-After of each 429 or 503 response.
+type throttleTransport struct {
+	base     http.RoundTripper
+	throttle *throttle
+	registry string
+	logger   hclog.Logger
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.throttle.wait(req); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !isThrottled(resp) {
+		return resp, err
+	}
+	if delay, ok := retryAfter(resp.Header, time.Now()); ok {
+		t.throttle.extend(delay)
+		t.logger.Warn("Registry is throttling requests", "registry", t.registry, "status", resp.StatusCode, "retry_after", delay)
+	}
+	return resp, err
+}
+
+// throttlePolicy retries throttled requests after the Retry-After the registry
+// sent, or with a backoff growing to maxThrottleBackoff when it sent none, for up
+// to throttleRetries attempts. Other failures are retried as ORAS does.
+type throttlePolicy struct{}
+
+func (throttlePolicy) Retry(attempt int, resp *http.Response, err error) (time.Duration, error) {
+	if err != nil || !isThrottled(resp) {
+		return retry.DefaultPolicy.Retry(attempt, resp, err)
+	}
+	if attempt >= throttleRetries {
+		return -1, nil
+	}
+	if delay, ok := retryAfter(resp.Header, time.Now()); ok {
+		return delay, nil
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return retry.DefaultPolicy.Retry(attempt, resp, err)
+	}
+	backoff := time.Second << attempt
+	if backoff > maxThrottleBackoff {
+		backoff = maxThrottleBackoff
+	}
+	return backoff, nil
+}
+
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date, into the
+// wait from now it asks for, capped at maxRetryAfter.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		delay = time.Duration(min(seconds, int64(maxRetryAfter/time.Second))) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = max(date.Sub(now), 0)
+	} else {
+		return 0, false
+	}
+	return min(delay, maxRetryAfter), true
+}
@@ -0,0 +1,72 @@
+package porter
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{value: "", ok: false},
+		{value: "3", want: 3 * time.Second, ok: true},
+		{value: "86400", want: maxRetryAfter, ok: true},
+		{value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second, ok: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, ok: true},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+	} {
+		got, ok := retryAfter(http.Header{"Retry-After": {tc.value}}, now)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.want, got, tc.value)
+	}
+}
+
+func TestPushResumesAfterThrottling(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tool")
+	require.NoError(t, os.WriteFile(file, []byte("throttled tool"), 0o755))
+	registry := newTestRegistry(t)
+	// The first existence check is retried by the HTTP client; the first streamed
+	// blob upload cannot be and fails its platform, which is pushed again.
+	throttled := map[string]bool{}
+	registry.throttle = func(req *http.Request) bool {
+		kind := ""
+		switch {
+		case req.Method == http.MethodHead && strings.Contains(req.URL.Path, "/blobs/"):
+			kind = "head"
+		case req.Method == http.MethodPut && strings.Contains(req.URL.Path, "/blobs/uploads/"):
+			kind = "put"
+		default:
+			return false
+		}
+		if throttled[kind] {
+			return false
+		}
+		throttled[kind] = true
+		return true
+	}
+
+	client := newTestClient(t, nil)
+	start := time.Now()
+	_, err := client.PushArtifact(context.Background(), file, registry.Host()+"/team/tool:1.0.0", PushOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "the push waits out the Retry-After")
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	assert.True(t, throttled["head"])
+	assert.True(t, throttled["put"])
+	assert.Contains(t, registry.tags["team/tool"], "1.0.0")
+}
@@ -0,0 +1,169 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutError reports a pull, push or registry request that ran out of time.
+// It is a net.Error whose Timeout is true, so a timed-out request is retried like
+// a dropped connection.
+type TimeoutError struct {
+	Operation string
+	Limit     time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Operation, e.Limit)
+}
+
+// Timeout reports true.
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary reports true: the operation may succeed when tried again.
+func (e *TimeoutError) Temporary() bool { return true }
+
+// timeouts are the parsed timeouts of a client; zero never times out.
+type timeouts struct {
+	pull    time.Duration
+	push    time.Duration
+	request time.Duration
+}
+
+// parseTimeouts parses the pull, push and request timeouts of cfg.
+func parseTimeouts(cfg *Config) (timeouts, error) {
+	var parsed timeouts
+	for _, setting := range []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"pull_timeout", cfg.PullTimeout, &parsed.pull},
+		{"push_timeout", cfg.PushTimeout, &parsed.push},
+		{"request_timeout", cfg.RequestTimeout, &parsed.request},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(setting.value)
+		if err != nil || timeout < 0 {
+			return timeouts{}, fmt.Errorf("invalid %s %q: expected a duration such as 10m", setting.name, setting.value)
+		}
+		*setting.target = timeout
+	}
+	return parsed, nil
+}
+
+// withTimeout bounds ctx by timeout, after which operation fails with a
+// TimeoutError. A zero timeout leaves ctx unbounded.
+func withTimeout(ctx context.Context, operation string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, &TimeoutError{Operation: operation, Limit: timeout})
+}
+
+// timeoutError names the timeout in err when it was withTimeout's deadline that
+// ended ctx, rather than the caller cancelling it.
+func timeoutError(ctx context.Context, err error) error {
+	var timeout *TimeoutError
+	if err == nil || !errors.As(context.Cause(ctx), &timeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", timeout, err)
+}
+
+// requestTimeoutTransport fails a registry request that makes no progress for
+// timeout: no bytes of its body sent, no response received, or no bytes of the
+// response body read. Large transfers are not cut off as long as they keep
+// moving. A stalled request is retried by the retry layer, and a stalled blob
+// download is resumed.
+type requestTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *requestTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	watchdog := &requestWatchdog{timeout: t.timeout, cancel: cancel}
+	watchdog.timer = time.AfterFunc(t.timeout, watchdog.expire)
+
+	req = req.WithContext(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &watchedBody{ReadCloser: req.Body, watchdog: watchdog}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		watchdog.stop()
+		return nil, watchdog.err(err)
+	}
+	watchdog.touch()
+	resp.Body = &watchedBody{ReadCloser: resp.Body, watchdog: watchdog, response: true}
+	return resp, nil
+}
+
+// requestWatchdog cancels a request once it has made no progress for timeout.
+type requestWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+func (w *requestWatchdog) expire() {
+	w.expired.Store(true)
+	w.cancel()
+}
+
+// touch records progress, restarting the timeout.
+func (w *requestWatchdog) touch() {
+	if !w.expired.Load() {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// stop ends the watch and releases the request's context.
+func (w *requestWatchdog) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+// err replaces err with a TimeoutError when the watchdog cancelled the request.
+func (w *requestWatchdog) err(err error) error {
+	if err != nil && w.expired.Load() {
+		return &TimeoutError{Operation: "registry request", Limit: w.timeout}
+	}
+	return err
+}
+
+// watchedBody reports the progress of a request or response body to its
+// watchdog. The response body ends the watch once it is drained or closed.
+type watchedBody struct {
+	io.ReadCloser
+	watchdog *requestWatchdog
+	response bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watchdog.touch()
+	}
+	if err == io.EOF && b.response {
+		b.watchdog.stop()
+	}
+	return n, b.watchdog.err(err)
+}
+
+func (b *watchedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.response {
+		b.watchdog.stop()
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delivery-station/porter/internal/faults"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stallingTransport answers with body, after blocking until the request is
+// cancelled when stall is set.
+type stallingTransport struct {
+	stall bool
+	body  io.Reader
+}
+
+func (t stallingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.stall {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(t.body), Request: req}, nil
+}
+
+// stallingReader returns data, then blocks until ctx is cancelled.
+type stallingReader struct {
+	ctx  context.Context
+	data *strings.Reader
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if r.data.Len() > 0 {
+		return r.data.Read(p)
+	}
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func TestRequestTimeoutFailsStalledRequest(t *testing.T) {
+	transport := &requestTimeoutTransport{base: stallingTransport{stall: true}, timeout: 20 * time.Millisecond}
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	require.NoError(t, err)
+
+	_, err = transport.RoundTrip(req)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "error = %v", err)
+	assert.True(t, netErr.Timeout())
+	assert.EqualError(t, err, "registry request timed out after 20ms")
+}
+
+func TestRequestTimeoutFailsStalledBody(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://registry.example.com/v2/", nil)
+	require.NoError(t, err)
+
+	base := roundTripper(func(req *http.Request) (*http.Response, error) {
+		body := &stallingReader{ctx: req.Context(), data: strings.NewReader("partial")}
+		return stallingTransport{body: body}.RoundTrip(req)
+	})
+	resp, err := (&requestTimeoutTransport{base: base, timeout: 20 * time.Millisecond}).RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	assert.Equal(t, "partial", string(data))
+	var timeout *TimeoutError
+	assert.True(t, errors.As(err, &timeout), "error = %v", err)
+}
+
+func TestRequestTimeoutKeepsProgressingBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	require.NoError(t, err)
+	body := io.MultiReader(slowReader("a"), slowReader("b"), slowReader("c"))
+	transport := &requestTimeoutTransport{base: stallingTransport{body: body}, timeout: 30 * time.Millisecond}
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(data))
+}
+
+func TestPullTimeout(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("layer"), nil)
+	t.Setenv(faults.EnvVar, "delay=2s")
+
+	client, err := NewClient(&Config{
+		CacheDir:        t.TempDir(),
+		CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+		PullTimeout:     "50ms",
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.Error(t, err)
+	var timeout *TimeoutError
+	require.True(t, errors.As(err, &timeout), "error = %v", err)
+	assert.Equal(t, "pull", timeout.Operation)
+	assert.Contains(t, err.Error(), "pull timed out after 50ms")
+}
+
+func TestInvalidTimeoutIsRejected(t *testing.T) {
+	_, err := NewClient(&Config{CacheDir: t.TempDir(), PushTimeout: "soon"}, hclog.NewNullLogger())
+	assert.EqualError(t, err, `invalid push_timeout "soon": expected a duration such as 10m`)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// slowReader returns data after a pause shorter than the request timeout of
+// TestRequestTimeoutKeepsProgressingBody, so only their sum exceeds it.
+func slowReader(data string) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		time.Sleep(15 * time.Millisecond)
+		return copy(p, data), io.EOF
+	})
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
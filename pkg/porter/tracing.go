@@ -0,0 +1,181 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/internal/spans"
+	"github.com/opencontainers/go-digest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceParentEnv and TraceStateEnv carry the W3C trace context of the DS
+// operation that runs porter; spans of porter join that trace.
+const (
+	TraceParentEnv = "TRACEPARENT"
+	TraceStateEnv  = "TRACESTATE"
+)
+
+// traceProvider returns the provider of the client's spans: its own when tracing
+// is configured, else the global one, which is a no-op unless the process that
+// embeds porter installs a provider.
+func (c *Client) traceProvider() trace.TracerProvider {
+	if c.tracerProvider != nil {
+		return c.tracerProvider
+	}
+	return otel.GetTracerProvider()
+}
+
+// tracer returns the tracer of porter's spans.
+func (c *Client) tracer() trace.Tracer {
+	return c.traceProvider().Tracer("github.com/delivery-station/porter/pkg/porter")
+}
+
+// TracingConfig exports OpenTelemetry traces of pulls, pushes and exports.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector traces are sent to, e.g.
+	// "http://otel-collector:4318"; empty disables tracing.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every export, e.g. for collector authentication.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// tracingConfigFromSettings reads plugins.settings.porter.tracing.
+func tracingConfigFromSettings(settings map[string]interface{}) TracingConfig {
+	tracing, _ := settings["tracing"].(map[string]interface{})
+	return TracingConfig{
+		Endpoint: settingString(tracing, "endpoint"),
+		Headers:  settingStringMap(tracing, "headers"),
+	}
+}
+
+// newTracerProvider creates the provider exporting to cfg.Endpoint. It is kept on
+// the client rather than installed globally, so closing one client does not leave
+// other code tracing into a shut down exporter. It returns nil when tracing is not
+// configured.
+func newTracerProvider(cfg TracingConfig, version string) (*sdktrace.TracerProvider, error) {
+	if strings.TrimSpace(cfg.Endpoint) == "" {
+		return nil, nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(cfg.Endpoint)}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracing.endpoint: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName("ds-porter")}
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(version))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+	)
+	return provider, nil
+}
+
+// shutdownTracing flushes the spans not yet exported.
+func (c *Client) shutdownTracing() error {
+	if c.tracerProvider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.tracerProvider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to flush traces: %w", err)
+	}
+	return nil
+}
+
+// hostTraceContext returns ctx carrying the trace context the DS host passed in
+// TRACEPARENT and TRACESTATE, unless ctx already belongs to a trace.
+func hostTraceContext(ctx context.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if parent := strings.TrimSpace(os.Getenv(TraceParentEnv)); parent != "" {
+		carrier.Set("traceparent", parent)
+		carrier.Set("tracestate", strings.TrimSpace(os.Getenv(TraceStateEnv)))
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// tracingTransport records a span for every registry request, named after what
+// the request does: a blob fetch or upload, a manifest resolve or tag, or a
+// token request for authentication.
+type tracingTransport struct {
+	base     http.RoundTripper
+	registry string
+	tracer   trace.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, attrs := registrySpan(req)
+	attrs = append(attrs,
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", t.registry),
+		attribute.String("url.path", req.URL.Path),
+	)
+	ctx, span := t.tracer.Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		// Challenges and missing blobs are part of the protocol, not failures.
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	spans.End(span, err)
+	return resp, err
+}
+
+// registrySpan names the span of a registry request after the distribution API
+// endpoint it calls.
+func registrySpan(req *http.Request) (string, []attribute.KeyValue) {
+	path := req.URL.Path
+	if !strings.HasPrefix(path, "/v2/") {
+		return "registry.auth", nil
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		rest := path[i+len("/blobs/"):]
+		if strings.HasPrefix(rest, "uploads") {
+			return "registry.blob.upload", nil
+		}
+		attrs := []attribute.KeyValue{attribute.String("oci.digest", rest)}
+		if req.Method == http.MethodHead {
+			return "registry.blob.exists", attrs
+		}
+		return "registry.blob.fetch", attrs
+	}
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		ref := path[i+len("/manifests/"):]
+		attrs := []attribute.KeyValue{attribute.String("oci.reference", ref)}
+		switch {
+		case req.Method == http.MethodPut && digest.Digest(ref).Validate() != nil:
+			return "registry.tag", attrs
+		case req.Method == http.MethodPut:
+			return "registry.manifest.push", attrs
+		case req.Method == http.MethodDelete:
+			return "registry.manifest.delete", attrs
+		case req.Method == http.MethodHead:
+			return "registry.resolve", attrs
+		}
+		return "registry.manifest.fetch", attrs
+	}
+	if path == "/v2/" {
+		return "registry.ping", nil
+	}
+	return "registry.request", nil
+}
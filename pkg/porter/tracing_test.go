@@ -0,0 +1,93 @@
+package porter
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPullSpansJoinHostTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	t.Setenv(TraceParentEnv, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client := newTestClient(t, nil)
+	_, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	pull, ok := spans["porter.pull"]
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", pull.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", pull.Parent().SpanID().String())
+
+	fetch, ok := spans["registry.blob.fetch"]
+	require.True(t, ok)
+	assert.Equal(t, pull.SpanContext().TraceID(), fetch.SpanContext().TraceID())
+}
+
+func TestClientTracingLeavesGlobalProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	global := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(global)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+	client, err := NewClient(&Config{
+		CacheDir:        t.TempDir(),
+		CredentialsFile: filepath.Join(t.TempDir(), CredentialsFileName),
+		Tracing:         TracingConfig{Endpoint: "http://127.0.0.1:1"},
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+	assert.Same(t, global, otel.GetTracerProvider())
+
+	_, err = client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Ended(), "spans of a client with its own exporter stay off the global provider")
+	_ = client.Close()
+	assert.Same(t, global, otel.GetTracerProvider())
+
+	// A client without tracing of its own records into the global provider.
+	_, err = newTestClient(t, nil).PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	assert.NotEmpty(t, recorder.Ended())
+}
+
+func TestRegistrySpanNames(t *testing.T) {
+	tests := []struct {
+		method, path, name string
+	}{
+		{http.MethodGet, "/token", "registry.auth"},
+		{http.MethodGet, "/v2/", "registry.ping"},
+		{http.MethodGet, "/v2/team/tool/blobs/sha256:abc", "registry.blob.fetch"},
+		{http.MethodHead, "/v2/team/tool/blobs/sha256:abc", "registry.blob.exists"},
+		{http.MethodPatch, "/v2/team/tool/blobs/uploads/123", "registry.blob.upload"},
+		{http.MethodHead, "/v2/team/tool/manifests/1.0.0", "registry.resolve"},
+		{http.MethodGet, "/v2/team/tool/manifests/1.0.0", "registry.manifest.fetch"},
+		{http.MethodPut, "/v2/team/tool/manifests/1.0.0", "registry.tag"},
+		{http.MethodPut, "/v2/team/tool/manifests/sha256:" + "a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4", "registry.manifest.push"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "https://registry.example"+tt.path, nil)
+		require.NoError(t, err)
+		name, _ := registrySpan(req)
+		assert.Equal(t, tt.name, name, "%s %s", tt.method, tt.path)
+	}
+}
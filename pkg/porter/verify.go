@@ -0,0 +1,292 @@
+package porter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// Blob verification statuses reported by VerifyCachedArtifact.
+const (
+	BlobStatusOK       = "ok"
+	BlobStatusMissing  = "missing"
+	BlobStatusCorrupt  = "corrupt"
+	BlobStatusRepaired = "repaired"
+)
+
+// VerifyOptions controls cache verification.
+type VerifyOptions struct {
+	// Repair re-fetches missing or corrupt blobs from the artifact's original reference.
+	Repair   bool
+	Insecure bool
+}
+
+// BlobCheck is the verification outcome for a single blob in the cached layout.
+type BlobCheck struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType,omitempty"`
+	Size      int64  `json:"size"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// VerifyReport summarises the integrity of one cached artifact.
+type VerifyReport struct {
+	ID        string      `json:"id"`
+	Reference string      `json:"reference"`
+	Digest    string      `json:"digest"`
+	Path      string      `json:"path"`
+	Valid     bool        `json:"valid"`
+	Errors    []string    `json:"errors,omitempty"`
+	Blobs     []BlobCheck `json:"blobs"`
+	Repaired  int         `json:"repaired,omitempty"`
+}
+
+// VerifyCachedArtifact walks the OCI layout of a cached artifact, recomputes every
+// blob digest, and checks that index and manifest references resolve. target is a
+// cache ID, the reference the artifact was pulled with, or its digest.
+func (c *Client) VerifyCachedArtifact(ctx context.Context, target string, opts VerifyOptions) (*VerifyReport, error) {
+	artifact, err := c.findCachedArtifact(target)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		ID:        artifact.ID,
+		Reference: artifact.Reference,
+		Digest:    artifact.Digest,
+		Path:      artifact.LocalPath,
+		Blobs:     []BlobCheck{},
+	}
+
+	verifier := &layoutVerifier{
+		client: c,
+		ctx:    ctx,
+		path:   artifact.LocalPath,
+		opts:   opts,
+		ref:    artifact.Reference,
+		report: report,
+		seen:   make(map[digest.Digest]struct{}),
+	}
+
+	if _, err := os.Stat(filepath.Join(artifact.LocalPath, ocispec.ImageLayoutFile)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("missing %s", ocispec.ImageLayoutFile))
+	}
+
+	indexData, err := os.ReadFile(filepath.Join(artifact.LocalPath, ocispec.ImageIndexFile))
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to read %s: %v", ocispec.ImageIndexFile, err))
+		return report, nil
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to parse %s: %v", ocispec.ImageIndexFile, err))
+		return report, nil
+	}
+
+	rootFound := false
+	for _, desc := range index.Manifests {
+		if desc.Digest.String() == artifact.Digest {
+			rootFound = true
+		}
+		verifier.verify(desc)
+	}
+	if !rootFound && artifact.Digest != "" {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s does not reference artifact digest %s", ocispec.ImageIndexFile, artifact.Digest))
+	}
+
+	report.Valid = len(report.Errors) == 0
+	for _, blob := range report.Blobs {
+		if blob.Status != BlobStatusOK && blob.Status != BlobStatusRepaired {
+			report.Valid = false
+		}
+	}
+
+	c.logger.Info("Verified cached artifact", "id", artifact.ID, "valid", report.Valid, "blobs", len(report.Blobs), "repaired", report.Repaired)
+	return report, nil
+}
+
+// findCachedArtifact locates a cached artifact by ID, reference, or digest.
+func (c *Client) findCachedArtifact(target string) (*ArtifactResult, error) {
+	artifact, err := c.lookupCachedArtifact(strings.TrimSpace(target))
+	if err != nil {
+		return nil, err
+	}
+	if artifact == nil {
+		return nil, fmt.Errorf("artifact %s not found in cache", target)
+	}
+	return artifact, nil
+}
+
+type layoutVerifier struct {
+	client *Client
+	ctx    context.Context
+	path   string
+	opts   VerifyOptions
+	ref    string
+	repo   *remote.Repository
+	report *VerifyReport
+	seen   map[digest.Digest]struct{}
+}
+
+// verify checks desc and, when it is a valid index or manifest, everything it references.
+func (v *layoutVerifier) verify(desc ocispec.Descriptor) {
+	if _, ok := v.seen[desc.Digest]; ok {
+		return
+	}
+	v.seen[desc.Digest] = struct{}{}
+
+	check := BlobCheck{Digest: desc.Digest.String(), MediaType: desc.MediaType, Size: desc.Size}
+	status, checkErr := v.checkBlob(desc)
+	check.Status = status
+	if checkErr != nil {
+		check.Error = checkErr.Error()
+	}
+
+	if status != BlobStatusOK && v.opts.Repair {
+		if err := v.repairBlob(desc); err != nil {
+			check.Error = fmt.Sprintf("%s; repair failed: %v", check.Error, err)
+		} else {
+			check.Status = BlobStatusRepaired
+			check.Error = ""
+			v.report.Repaired++
+		}
+	}
+	v.report.Blobs = append(v.report.Blobs, check)
+
+	if check.Status != BlobStatusOK && check.Status != BlobStatusRepaired {
+		return
+	}
+
+	path := blobPath(v.path, desc.Digest)
+	switch {
+	case isIndexDescriptor(desc) || desc.MediaType == "application/vnd.docker.distribution.manifest.list.v2+json":
+		var index ocispec.Index
+		if err := readJSONFile(path, &index); err != nil {
+			v.report.Errors = append(v.report.Errors, fmt.Sprintf("failed to parse index %s: %v", desc.Digest, err))
+			return
+		}
+		for _, child := range index.Manifests {
+			v.verify(child)
+		}
+	case isManifestDescriptor(desc):
+		var manifest ocispec.Manifest
+		if err := readJSONFile(path, &manifest); err != nil {
+			v.report.Errors = append(v.report.Errors, fmt.Sprintf("failed to parse manifest %s: %v", desc.Digest, err))
+			return
+		}
+		v.verify(manifest.Config)
+		for _, layer := range manifest.Layers {
+			v.verify(layer)
+		}
+	}
+}
+
+// checkBlob recomputes the digest and size of the blob described by desc.
+func (v *layoutVerifier) checkBlob(desc ocispec.Descriptor) (string, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return BlobStatusCorrupt, fmt.Errorf("invalid digest: %w", err)
+	}
+
+	f, err := os.Open(blobPath(v.path, desc.Digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BlobStatusMissing, errors.New("blob not found in layout")
+		}
+		return BlobStatusCorrupt, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	verifier := desc.Digest.Verifier()
+	size, err := io.Copy(verifier, f)
+	if err != nil {
+		return BlobStatusCorrupt, fmt.Errorf("failed to read blob: %w", err)
+	}
+	if size != desc.Size {
+		return BlobStatusCorrupt, fmt.Errorf("size mismatch: expected %d, found %d", desc.Size, size)
+	}
+	if !verifier.Verified() {
+		return BlobStatusCorrupt, errors.New("digest mismatch")
+	}
+	return BlobStatusOK, nil
+}
+
+// repairBlob downloads desc from the original reference and replaces the local copy.
+// The blob is written to a new file rather than in place because cached blobs may be
+// hard-linked into other layouts.
+func (v *layoutVerifier) repairBlob(desc ocispec.Descriptor) error {
+	if v.repo == nil {
+		imgRef, err := v.client.parseReference(v.ref, v.opts.Insecure)
+		if err != nil {
+			return fmt.Errorf("invalid reference: %w", err)
+		}
+		repo, err := v.client.remoteRepository(imgRef, v.opts.Insecure)
+		if err != nil {
+			return err
+		}
+		v.repo = repo
+	}
+
+	reader, err := v.repo.Fetch(v.ctx, desc)
+	if err != nil && !v.opts.Insecure && isPlainHTTPResponseError(err) {
+		v.repo.PlainHTTP = true
+		reader, err = v.repo.Fetch(v.ctx, desc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch blob: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	target := blobPath(v.path, desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".porter-repair-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	verified := content.NewVerifyReader(reader, desc)
+	if _, err := io.Copy(tmp, verified); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to download blob: %w", err)
+	}
+	if err := verified.Verify(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("downloaded blob failed verification: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to place blob: %w", err)
+	}
+
+	v.client.logger.Info("Repaired cached blob", "digest", desc.Digest, "size", desc.Size)
+	return nil
+}
+
+func readJSONFile(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
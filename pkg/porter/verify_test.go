@@ -0,0 +1,75 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCachedArtifactReportsHealthyLayout(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool binary"), nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+
+	report, err := client.VerifyCachedArtifact(context.Background(), ref, VerifyOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid, report.Errors)
+	assert.Equal(t, result.ID, report.ID)
+	assert.Len(t, report.Blobs, 3, "manifest, config, and layer")
+	for _, blob := range report.Blobs {
+		assert.Equal(t, BlobStatusOK, blob.Status, blob.Digest)
+	}
+
+	byID, err := client.VerifyCachedArtifact(context.Background(), result.ID, VerifyOptions{})
+	require.NoError(t, err)
+	assert.True(t, byID.Valid)
+}
+
+func TestVerifyCachedArtifactDetectsAndRepairsCorruption(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := []byte("tool binary")
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", data, nil)
+
+	client := newTestClient(t, nil)
+	ref := registry.Host() + "/team/tool:1.0.0"
+	result, err := client.PullArtifact(context.Background(), ref, true)
+	require.NoError(t, err)
+
+	layer := blobPath(result.LocalPath, digest.FromBytes(data))
+	require.NoError(t, os.WriteFile(layer, []byte("tool binarY"), 0644))
+
+	report, err := client.VerifyCachedArtifact(context.Background(), ref, VerifyOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.Contains(t, report.Blobs, BlobCheck{
+		Digest:    digest.FromBytes(data).String(),
+		MediaType: "application/octet-stream",
+		Size:      int64(len(data)),
+		Status:    BlobStatusCorrupt,
+		Error:     "digest mismatch",
+	})
+
+	require.NoError(t, os.Remove(layer))
+	report, err = client.VerifyCachedArtifact(context.Background(), ref, VerifyOptions{Insecure: true, Repair: true})
+	require.NoError(t, err)
+	assert.True(t, report.Valid, report.Errors)
+	assert.Equal(t, 1, report.Repaired)
+
+	repaired, err := os.ReadFile(layer)
+	require.NoError(t, err)
+	assert.Equal(t, data, repaired)
+}
+
+func TestVerifyCachedArtifactUnknownTarget(t *testing.T) {
+	client := newTestClient(t, nil)
+	_, err := client.VerifyCachedArtifact(context.Background(), "missing", VerifyOptions{})
+	assert.ErrorContains(t, err, "not found in cache")
+}
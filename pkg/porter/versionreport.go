@@ -0,0 +1,111 @@
+package porter
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sort"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// CacheFormatVersion identifies the on-disk cache layout porter reads and writes:
+// one OCI layout per artifact, sharing the blobs/ store, recorded in cache.db.
+// Version 1 kept a metadata.json in each layout, which porter migrates.
+const CacheFormatVersion = 2
+
+// reportedModules are the libraries whose versions VersionReport lists.
+var reportedModules = []string{
+	"oras.land/oras-go/v2",
+	"github.com/google/go-containerregistry",
+	"github.com/opencontainers/image-spec",
+	"github.com/delivery-station/ds",
+}
+
+// supportedManifestTypes are the manifest and index media types porter pulls,
+// pushes and exports, all of schema version 2.
+var supportedManifestTypes = []string{
+	ocispec.MediaTypeImageManifest,
+	ocispec.MediaTypeImageIndex,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// BuildInfo identifies the running porter binary.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// VersionReport describes the running porter, the libraries it was built with,
+// the formats it understands and the optional features its configuration enables,
+// for support tickets and fleet version audits.
+type VersionReport struct {
+	BuildInfo
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	// Dependencies maps the module path of each reported library to its version.
+	Dependencies map[string]string `json:"dependencies"`
+	// ManifestSchemaVersion is the schema version of every manifest type listed.
+	ManifestSchemaVersion int      `json:"manifest_schema_version"`
+	ManifestMediaTypes    []string `json:"manifest_media_types"`
+	CacheFormatVersion    int      `json:"cache_format_version"`
+	// Features lists the optional features the configuration enables.
+	Features []string `json:"features"`
+}
+
+// VersionReport reports build, the libraries porter was built with, and the
+// features c's configuration enables.
+func (c *Client) VersionReport(build BuildInfo) *VersionReport {
+	report := &VersionReport{
+		BuildInfo:             build,
+		GoVersion:             runtime.Version(),
+		Platform:              runtime.GOOS + "/" + runtime.GOARCH,
+		Dependencies:          make(map[string]string, len(reportedModules)),
+		ManifestSchemaVersion: 2,
+		ManifestMediaTypes:    append([]string(nil), supportedManifestTypes...),
+		CacheFormatVersion:    CacheFormatVersion,
+		Features:              c.enabledFeatures(),
+	}
+	for _, module := range reportedModules {
+		report.Dependencies[module] = "unknown"
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			if _, ok := report.Dependencies[dep.Path]; ok {
+				report.Dependencies[dep.Path] = dep.Version
+			}
+		}
+	}
+	return report
+}
+
+// enabledFeatures names the optional features the configuration turns on, sorted.
+func (c *Client) enabledFeatures() []string {
+	cfg := c.config
+	helper, _ := credentialHelper(cfg.CredentialsStore)
+	features := []string{}
+	for name, enabled := range map[string]bool{
+		"aliases":          len(cfg.Aliases) > 0,
+		"chunked_uploads":  c.chunkSize > 0,
+		"credential_store": helper != "",
+		"doh":              cfg.DoH != "",
+		"extract_relaxed":  cfg.Extract.AllowSetuid || cfg.Extract.AllowWorldWritable || cfg.Extract.AllowForeignOwner,
+		"fault_injection":  c.faults != nil,
+		"mirrors":          len(cfg.Mirrors) > 0,
+		"naming_policy":    c.naming != nil,
+		"pac":              cfg.Proxy.PAC != "",
+		"result_signing":   cfg.ResultSigningKey != "",
+		"staging_spool":    cfg.Staging.Spool,
+		"tracing":          c.tracerProvider != nil,
+	} {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
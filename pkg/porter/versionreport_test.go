@@ -0,0 +1,28 @@
+package porter
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionReportListsEnabledFeatures(t *testing.T) {
+	client, err := NewClient(&Config{
+		CacheDir:         t.TempDir(),
+		Mirrors:          map[string][]string{"docker.io": {"mirror.internal"}},
+		ResultSigningKey: "secret",
+		Naming:           NamingPolicy{Tags: []string{NamingSemver}},
+	}, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	report := client.VersionReport(BuildInfo{Version: "1.2.3", Commit: "abc", Date: "today"})
+	assert.Equal(t, "1.2.3", report.Version)
+	assert.Equal(t, []string{"mirrors", "naming_policy", "result_signing"}, report.Features)
+	assert.Len(t, report.Dependencies, len(reportedModules))
+	assert.Contains(t, report.ManifestMediaTypes, "application/vnd.oci.image.manifest.v1+json")
+
+	plain := newTestClient(t, nil)
+	assert.Empty(t, plain.VersionReport(BuildInfo{}).Features)
+}
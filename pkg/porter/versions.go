@@ -0,0 +1,203 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/pkg/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// CurrentVersionLink is the symlink in a versioned destination that points at the
+// active version directory.
+const CurrentVersionLink = "current"
+
+// InstalledVersion describes one version directory of a versioned destination.
+type InstalledVersion struct {
+	Version    string    `json:"version"`
+	Path       string    `json:"path"`
+	Current    bool      `json:"current"`
+	Reference  string    `json:"reference,omitempty"`
+	Digest     string    `json:"digest,omitempty"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// exportVersion picks the directory name of a versioned export: the explicit
+// version, the artifact's org.opencontainers.image.version annotation, the tag it
+// was pulled with, or a short form of its digest, in that order.
+func exportVersion(result *ArtifactResult, explicit string) (string, error) {
+	candidates := []string{explicit, result.Metadata[ocispec.AnnotationVersion]}
+	if ref, err := reference.Parse(result.Reference); err == nil && ref.Tag != "" && ref.Digest == "" {
+		candidates = append(candidates, ref.Tag)
+	}
+	for _, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			return candidate, validateVersionName(candidate)
+		}
+	}
+
+	digest := strings.TrimPrefix(result.Digest, "sha256:")
+	if len(digest) < 12 {
+		return "", fmt.Errorf("cannot derive a version for %s; set one explicitly", result.Reference)
+	}
+	return "sha256-" + digest[:12], nil
+}
+
+// validateVersionName rejects names that are not a single, ordinary path element.
+func validateVersionName(version string) error {
+	switch {
+	case version == CurrentVersionLink, version == ".", version == "..":
+		return fmt.Errorf("invalid version name %q", version)
+	case strings.HasPrefix(version, "."):
+		return fmt.Errorf("invalid version name %q: must not start with a dot", version)
+	case strings.ContainsAny(version, `/\`):
+		return fmt.Errorf("invalid version name %q: must not contain path separators", version)
+	}
+	return nil
+}
+
+// VersionedDestination returns the directory a versioned export of result into
+// destination writes to.
+func VersionedDestination(result *ArtifactResult, destination string, opts ExportOptions) (string, error) {
+	if target, ok := parseDestinationURL(destination); ok {
+		if target.Scheme != "file" {
+			return "", fmt.Errorf("versioned exports require a local destination")
+		}
+		destination = target.Path
+	}
+	version, err := exportVersion(result, opts.Version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(destination, version), nil
+}
+
+// ListVersions returns the version directories of a versioned destination, newest
+// first, marking the one the current link points at.
+func ListVersions(destination string) ([]InstalledVersion, error) {
+	entries, err := os.ReadDir(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versioned destination: %w", err)
+	}
+	current, _ := currentVersion(destination)
+
+	versions := make([]InstalledVersion, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || validateVersionName(name) != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		installed := InstalledVersion{
+			Version:    name,
+			Path:       filepath.Join(destination, name),
+			Current:    name == current,
+			ModifiedAt: info.ModTime(),
+		}
+		var manifest deliveryManifest
+		if err := readJSONFile(filepath.Join(installed.Path, DeliveryManifestFile), &manifest); err == nil {
+			installed.Reference = manifest.Reference
+			installed.Digest = manifest.Digest
+			installed.ModifiedAt = manifest.UpdatedAt
+		}
+		versions = append(versions, installed)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		if !versions[i].ModifiedAt.Equal(versions[j].ModifiedAt) {
+			return versions[i].ModifiedAt.After(versions[j].ModifiedAt)
+		}
+		return versions[i].Version > versions[j].Version
+	})
+	return versions, nil
+}
+
+// SwitchVersion points the current link of destination at an installed version.
+// The link is replaced with a rename, so readers never see it missing.
+func SwitchVersion(destination, version string) error {
+	if err := validateVersionName(version); err != nil {
+		return err
+	}
+	info, err := os.Stat(filepath.Join(destination, version))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("version %s is not installed in %s", version, destination)
+		}
+		return fmt.Errorf("failed to stat version directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("version %s in %s is not a directory", version, destination)
+	}
+
+	link := filepath.Join(destination, CurrentVersionLink)
+	if existing, err := os.Lstat(link); err == nil && existing.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symlink", link)
+	}
+
+	tmp := filepath.Join(destination, fmt.Sprintf(".%s.%d.tmp", CurrentVersionLink, os.Getpid()))
+	_ = os.Remove(tmp)
+	if err := os.Symlink(version, tmp); err != nil {
+		return fmt.Errorf("failed to create current link: %w", err)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to switch current link: %w", err)
+	}
+	return nil
+}
+
+// currentVersion returns the version the current link points at.
+func currentVersion(destination string) (string, error) {
+	target, err := os.Readlink(filepath.Join(destination, CurrentVersionLink))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(filepath.Clean(target)), nil
+}
+
+// exportVersioned exports result into its version directory under destination and
+// then makes it the current version. The previous version stays installed.
+func (c *Client) exportVersioned(ctx context.Context, result *ArtifactResult, destination string, opts ExportOptions) ([]string, error) {
+	dir, err := VersionedDestination(result, destination, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(dir)
+	created := os.IsNotExist(statErr)
+	// Version names often contain dots, so create the directory up front rather
+	// than have it mistaken for a file path.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create version directory: %w", err)
+	}
+
+	opts.Versioned = false
+	paths, err := c.exportArtifact(ctx, result, dir, opts)
+	if err != nil {
+		// A cancelled install of a new version leaves nothing half-installed
+		// next to the current one.
+		if created && ctx.Err() != nil {
+			if removeErr := os.RemoveAll(dir); removeErr != nil {
+				c.logger.Warn("Failed to remove cancelled version", "dir", dir, "error", removeErr)
+			}
+		}
+		return nil, err
+	}
+
+	root, version := filepath.Split(dir)
+	previous, _ := currentVersion(root)
+	if err := SwitchVersion(root, version); err != nil {
+		return nil, err
+	}
+	c.logger.Info("Switched current version", "dir", filepath.Clean(root), "version", version, "previous", previous)
+	return paths, nil
+}
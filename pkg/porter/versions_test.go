@@ -0,0 +1,87 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportVersion(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	version, err := exportVersion(&ArtifactResult{Reference: "ghcr.io/team/tool:1.2.0", Digest: digest}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0", version)
+
+	version, err = exportVersion(&ArtifactResult{
+		Reference: "ghcr.io/team/tool:latest",
+		Digest:    digest,
+		Metadata:  map[string]string{"org.opencontainers.image.version": "1.3.0"},
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.3.0", version)
+
+	version, err = exportVersion(&ArtifactResult{Reference: "ghcr.io/team/tool@" + digest, Digest: digest}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256-0123456789ab", version)
+
+	version, err = exportVersion(&ArtifactResult{Reference: "ghcr.io/team/tool:1.2.0", Digest: digest}, "stable")
+	require.NoError(t, err)
+	assert.Equal(t, "stable", version)
+
+	for _, invalid := range []string{"current", "..", ".hidden", "a/b"} {
+		_, err := exportVersion(&ArtifactResult{Reference: "ghcr.io/team/tool:1.2.0", Digest: digest}, invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestVersionedExportSwitchesCurrent(t *testing.T) {
+	// A destination URL whose scheme is not registered is a relative local path;
+	// keep anything written to one out of the package directory.
+	t.Chdir(t.TempDir())
+	registry := newTestRegistry(t)
+	registry.AddArtifact("team/tool", "1.0.0", "application/octet-stream", []byte("tool v1"), nil)
+	registry.AddArtifact("team/tool", "1.1.0", "application/octet-stream", []byte("tool v2"), nil)
+
+	client := newTestClient(t, nil)
+	outDir := t.TempDir()
+	opts := ExportOptions{Versioned: true}
+
+	v1, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.0.0", true)
+	require.NoError(t, err)
+	paths, err := client.ExportArtifact(context.Background(), v1, outDir, opts)
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	assert.Equal(t, filepath.Join(outDir, "1.0.0"), filepath.Dir(paths[0]))
+
+	v2, err := client.PullArtifact(context.Background(), registry.Host()+"/team/tool:1.1.0", true)
+	require.NoError(t, err)
+	_, err = client.ExportArtifact(context.Background(), v2, outDir, opts)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, CurrentVersionLink, filepath.Base(paths[0])))
+	require.NoError(t, err)
+	assert.Equal(t, "tool v2", string(data))
+
+	versions, err := ListVersions(outDir)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "1.1.0", versions[0].Version)
+	assert.True(t, versions[0].Current)
+	assert.Equal(t, v2.Digest, versions[0].Digest)
+	assert.Equal(t, "1.0.0", versions[1].Version)
+	assert.False(t, versions[1].Current)
+
+	require.NoError(t, SwitchVersion(outDir, "1.0.0"))
+	data, err = os.ReadFile(filepath.Join(outDir, CurrentVersionLink, filepath.Base(paths[0])))
+	require.NoError(t, err)
+	assert.Equal(t, "tool v1", string(data))
+
+	assert.Error(t, SwitchVersion(outDir, "2.0.0"))
+	_, err = client.ExportArtifact(context.Background(), v1, "s3://bucket/out", opts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package porter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace lists artifacts that `push --workspace` publishes together, e.g. every
+// deliverable of a monorepo.
+type Workspace struct {
+	// Parallelism bounds how many artifacts are pushed at once; zero uses the
+	// configured concurrency.
+	Parallelism int                 `yaml:"parallelism,omitempty"`
+	Artifacts   []WorkspaceArtifact `yaml:"artifacts"`
+}
+
+// WorkspaceArtifact is one artifact of a workspace. Manifest is a ds.manifest.yaml,
+// a file, or a directory, relative to the workspace file.
+type WorkspaceArtifact struct {
+	Name        string   `yaml:"name,omitempty"`
+	Manifest    string   `yaml:"manifest"`
+	Reference   string   `yaml:"reference"`
+	Compression string   `yaml:"compression,omitempty"`
+	SBOMs       []string `yaml:"sboms,omitempty"`
+}
+
+// WorkspacePushResult summarizes a workspace push.
+type WorkspacePushResult struct {
+	Pushed    int                     `json:"pushed"`
+	Failed    int                     `json:"failed"`
+	Artifacts []WorkspaceArtifactPush `json:"artifacts"`
+}
+
+// WorkspaceArtifactPush is the outcome of pushing one workspace artifact.
+type WorkspaceArtifactPush struct {
+	Name      string `json:"name"`
+	Reference string `json:"reference"`
+	Digest    string `json:"digest,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// LoadWorkspace reads the workspace file at path and resolves its manifest paths
+// against the file's directory.
+func LoadWorkspace(path string) (*Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	var workspace Workspace
+	if err := yaml.Unmarshal(data, &workspace); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range workspace.Artifacts {
+		artifact := &workspace.Artifacts[i]
+		artifact.Manifest = strings.TrimSpace(artifact.Manifest)
+		if artifact.Manifest != "" && !filepath.IsAbs(artifact.Manifest) {
+			artifact.Manifest = filepath.Join(dir, artifact.Manifest)
+		}
+		for j, sbom := range artifact.SBOMs {
+			if !filepath.IsAbs(sbom) {
+				artifact.SBOMs[j] = filepath.Join(dir, sbom)
+			}
+		}
+	}
+	if err := workspace.validate(); err != nil {
+		return nil, fmt.Errorf("invalid workspace %s: %w", path, err)
+	}
+	return &workspace, nil
+}
+
+// validate checks that every artifact has a manifest, a reference, and a unique
+// name, defaulting names to references.
+func (w *Workspace) validate() error {
+	if len(w.Artifacts) == 0 {
+		return fmt.Errorf("workspace lists no artifacts")
+	}
+	if w.Parallelism < 0 {
+		return fmt.Errorf("parallelism must not be negative")
+	}
+	seen := make(map[string]struct{}, len(w.Artifacts))
+	for i := range w.Artifacts {
+		artifact := &w.Artifacts[i]
+		artifact.Reference = strings.TrimSpace(artifact.Reference)
+		artifact.Name = strings.TrimSpace(artifact.Name)
+		if artifact.Name == "" {
+			artifact.Name = artifact.Reference
+		}
+		switch {
+		case artifact.Reference == "":
+			return fmt.Errorf("artifact %d has no reference", i+1)
+		case artifact.Manifest == "":
+			return fmt.Errorf("artifact %s has no manifest", artifact.Name)
+		}
+		if _, ok := seen[artifact.Name]; ok {
+			return fmt.Errorf("artifact %s is listed twice", artifact.Name)
+		}
+		seen[artifact.Name] = struct{}{}
+	}
+	return nil
+}
+
+// PushWorkspace pushes every artifact of workspace with the shared options, up to
+// Parallelism at a time. A failed artifact does not stop the others; the result
+// reports each outcome, and the error names the artifacts that failed.
+func (c *Client) PushWorkspace(ctx context.Context, workspace *Workspace, opts PushOptions) (*WorkspacePushResult, error) {
+	if err := workspace.validate(); err != nil {
+		return nil, err
+	}
+	// Reject the whole workspace before any artifact is uploaded.
+	for _, artifact := range workspace.Artifacts {
+		if err := c.CheckNaming(artifact.Reference); err != nil {
+			return nil, fmt.Errorf("workspace artifact %s: %w", artifact.Name, err)
+		}
+		if err := c.CheckPushTags(artifact.Reference, opts.Tags); err != nil {
+			return nil, fmt.Errorf("workspace artifact %s: %w", artifact.Name, err)
+		}
+	}
+
+	parallelism := workspace.Parallelism
+	if parallelism <= 0 {
+		parallelism = c.concurrency()
+	}
+
+	result := &WorkspacePushResult{Artifacts: make([]WorkspaceArtifactPush, len(workspace.Artifacts))}
+	var mu sync.Mutex
+	var failed []string
+	var group errgroup.Group
+	group.SetLimit(parallelism)
+	for i, artifact := range workspace.Artifacts {
+		group.Go(func() error {
+			artifactOpts := opts
+			artifactOpts.SBOMs = append(append([]string(nil), opts.SBOMs...), artifact.SBOMs...)
+			if artifact.Compression != "" {
+				artifactOpts.Compression = artifact.Compression
+			}
+
+			outcome := WorkspaceArtifactPush{Name: artifact.Name, Reference: artifact.Reference}
+			pushed, err := c.PushArtifact(ctx, artifact.Manifest, artifact.Reference, artifactOpts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				c.logger.Error("Workspace artifact push failed", "artifact", artifact.Name, "error", err)
+				outcome.Error = err.Error()
+				result.Failed++
+				failed = append(failed, artifact.Name)
+			} else {
+				outcome.Reference = pushed.Reference
+				outcome.Digest = pushed.Digest
+				result.Pushed++
+			}
+			result.Artifacts[i] = outcome
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	c.logger.Info("Workspace push completed", "pushed", result.Pushed, "failed", result.Failed)
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%d of %d workspace artifacts failed to push: %s", len(failed), len(workspace.Artifacts), strings.Join(failed, ", "))
+	}
+	return result, nil
+}
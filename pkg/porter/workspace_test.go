@@ -0,0 +1,88 @@
+package porter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWorkspaceResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workspace.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+parallelism: 2
+artifacts:
+  - name: cli
+    manifest: cli/ds.manifest.yaml
+    reference: example.com/team/cli:1.0.0
+    sboms: [cli/sbom.json]
+  - manifest: daemon
+    reference: example.com/team/daemon:1.0.0
+`), 0o644))
+
+	workspace, err := LoadWorkspace(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, workspace.Parallelism)
+	require.Len(t, workspace.Artifacts, 2)
+	assert.Equal(t, filepath.Join(dir, "cli", "ds.manifest.yaml"), workspace.Artifacts[0].Manifest)
+	assert.Equal(t, []string{filepath.Join(dir, "cli", "sbom.json")}, workspace.Artifacts[0].SBOMs)
+	assert.Equal(t, "example.com/team/daemon:1.0.0", workspace.Artifacts[1].Name)
+}
+
+func TestLoadWorkspaceRejectsInvalidEntries(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty":        "artifacts: []\n",
+		"no reference": "artifacts:\n  - manifest: cli\n",
+		"no manifest":  "artifacts:\n  - reference: example.com/cli:1\n",
+		"duplicate":    "artifacts:\n  - {name: cli, manifest: a, reference: example.com/a:1}\n  - {name: cli, manifest: b, reference: example.com/b:1}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "workspace.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			_, err := LoadWorkspace(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPushWorkspaceReportsEveryArtifact(t *testing.T) {
+	registry := newTestRegistry(t)
+	client := newTestClient(t, nil)
+
+	dir := t.TempDir()
+	cli := filepath.Join(dir, "cli")
+	daemon := filepath.Join(dir, "daemon")
+	require.NoError(t, os.WriteFile(cli, []byte("cli binary"), 0o755))
+	require.NoError(t, os.WriteFile(daemon, []byte("daemon binary"), 0o755))
+
+	workspace := &Workspace{
+		Parallelism: 2,
+		Artifacts: []WorkspaceArtifact{
+			{Name: "cli", Manifest: cli, Reference: registry.Host() + "/team/cli:1.0.0"},
+			{Name: "missing", Manifest: filepath.Join(dir, "missing"), Reference: registry.Host() + "/team/missing:1.0.0"},
+			{Name: "daemon", Manifest: daemon, Reference: registry.Host() + "/team/daemon:1.0.0"},
+		},
+	}
+
+	result, err := client.PushWorkspace(context.Background(), workspace, PushOptions{Insecure: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing")
+	require.NotNil(t, result)
+
+	assert.Equal(t, 2, result.Pushed)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Artifacts, 3)
+	assert.Equal(t, "cli", result.Artifacts[0].Name)
+	assert.NotEmpty(t, result.Artifacts[0].Digest)
+	assert.Empty(t, result.Artifacts[0].Error)
+	assert.NotEmpty(t, result.Artifacts[1].Error)
+	assert.NotEmpty(t, result.Artifacts[2].Digest)
+
+	pulled, err := client.PullArtifact(context.Background(), registry.Host()+"/team/daemon:1.0.0", true)
+	require.NoError(t, err)
+	assert.Equal(t, result.Artifacts[2].Digest, pulled.Digest)
+}
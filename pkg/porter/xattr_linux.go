@@ -0,0 +1,10 @@
+//go:build linux
+
+package porter
+
+import "syscall"
+
+// setXattr sets the extended attribute name of path to value.
+func setXattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
@@ -0,0 +1,36 @@
+package porter
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFileRestoresUserXattrs(t *testing.T) {
+	dir := t.TempDir()
+	probe := filepath.Join(dir, "probe")
+	require.NoError(t, os.WriteFile(probe, nil, 0o644))
+	if err := setXattr(probe, "user.probe", "1"); err != nil {
+		t.Skipf("file system has no user extended attributes: %v", err)
+	}
+
+	path := filepath.Join(dir, "tool")
+	f, err := exportWriter{}.create(path, 0o755)
+	require.NoError(t, err)
+	f.restore(time.Time{}, map[string]string{"user.origin": "build-42", "security.capability": "raw"})
+	_, err = f.Write([]byte("tool"))
+	require.NoError(t, err)
+	require.NoError(t, f.Commit())
+
+	value := make([]byte, 64)
+	n, err := syscall.Getxattr(path, "user.origin", value)
+	require.NoError(t, err)
+	assert.Equal(t, "build-42", string(value[:n]))
+	_, err = syscall.Getxattr(path, "security.capability", value)
+	assert.ErrorIs(t, err, syscall.ENODATA)
+}
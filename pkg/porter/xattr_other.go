@@ -0,0 +1,10 @@
+//go:build !linux
+
+package porter
+
+import "errors"
+
+// setXattr restores extended attributes on Linux only.
+func setXattr(string, string, string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,337 @@
+// Package reference parses, normalizes, and classifies OCI artifact references.
+//
+// It is the single place porter and the release pipeline decide which part of a
+// reference is the registry, which is the repository, and whether the reference
+// pins a tag or a digest, so that pulls, pushes, and credential lookups agree on
+// the same interpretation.
+package reference
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// DefaultRegistry is the registry assumed when a reference names none.
+	DefaultRegistry = "docker.io"
+	// DefaultTag is the tag assumed when a reference pins neither a tag nor a digest.
+	DefaultTag = "latest"
+	// officialNamespace prefixes single-component Docker Hub repositories.
+	officialNamespace = "library"
+)
+
+// Kind classifies what a reference pins.
+type Kind string
+
+const (
+	// KindNone means the reference names a repository only.
+	KindNone Kind = "none"
+	// KindTag means the reference pins a tag.
+	KindTag Kind = "tag"
+	// KindDigest means the reference pins a content digest, with or without a tag.
+	KindDigest Kind = "digest"
+)
+
+// ErrInvalid is wrapped by every parse error.
+var ErrInvalid = errors.New("invalid reference")
+
+var (
+	tagPattern       = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	componentPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	hostPattern      = regexp.MustCompile(`^(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*|\[[0-9A-Fa-f:.]+\])(?::[0-9]+)?$`)
+)
+
+// dockerHubHosts are the host names that all address Docker Hub.
+var dockerHubHosts = map[string]struct{}{
+	"docker.io":               {},
+	"index.docker.io":         {},
+	"registry-1.docker.io":    {},
+	"registry.hub.docker.com": {},
+}
+
+// Reference is a parsed artifact reference.
+type Reference struct {
+	// Registry is the normalized registry host, including any port.
+	Registry string
+	// Repository is the repository path within the registry.
+	Repository string
+	// Tag is the tag, empty when the reference does not name one.
+	Tag string
+	// Digest is the content digest, empty when the reference does not pin one.
+	Digest digest.Digest
+}
+
+// Options tune how references are parsed.
+type Options struct {
+	// DefaultRegistry replaces DefaultRegistry for references without a registry.
+	// Docker Hub's "library/" namespace is only implied for Docker Hub.
+	DefaultRegistry string
+	// Aliases maps short names to full repository prefixes; see ExpandAlias.
+	Aliases map[string]string
+}
+
+// Parse parses ref using the default options.
+func Parse(ref string) (Reference, error) {
+	return ParseWithOptions(ref, Options{})
+}
+
+// ParseWithOptions parses ref after expanding any alias in opts.
+//
+// The first path component is treated as the registry when it contains a "." or
+// ":" or is "localhost"; otherwise the reference belongs to the default registry.
+func ParseWithOptions(ref string, opts Options) (Reference, error) {
+	original := ref
+	ref = ExpandAlias(strings.TrimSpace(ref), opts.Aliases)
+	if ref == "" {
+		return Reference{}, fmt.Errorf("%w: empty reference", ErrInvalid)
+	}
+	if strings.Contains(ref, "://") {
+		return Reference{}, fmt.Errorf("%w %q: references do not take a scheme", ErrInvalid, Redact(original))
+	}
+
+	var parsed Reference
+	if name, dgst, ok := strings.Cut(ref, "@"); ok {
+		d, err := digest.Parse(dgst)
+		if err != nil {
+			return Reference{}, fmt.Errorf("%w %q: %v", ErrInvalid, Redact(original), err)
+		}
+		parsed.Digest = d
+		ref = name
+	}
+
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		parsed.Tag = ref[idx+1:]
+		ref = ref[:idx]
+		if !tagPattern.MatchString(parsed.Tag) {
+			return Reference{}, fmt.Errorf("%w %q: invalid tag %q", ErrInvalid, Redact(original), parsed.Tag)
+		}
+	}
+
+	registry, repository := splitRegistry(ref)
+	if registry == "" {
+		registry = opts.DefaultRegistry
+		if registry == "" {
+			registry = DefaultRegistry
+		}
+	}
+	parsed.Registry = NormalizeRegistry(registry)
+	if !hostPattern.MatchString(parsed.Registry) {
+		return Reference{}, fmt.Errorf("%w %q: invalid registry %q", ErrInvalid, Redact(original), registry)
+	}
+	if parsed.Registry == DefaultRegistry && !strings.Contains(repository, "/") {
+		repository = officialNamespace + "/" + repository
+	}
+	if repository == "" {
+		return Reference{}, fmt.Errorf("%w %q: missing repository", ErrInvalid, Redact(original))
+	}
+	for _, component := range strings.Split(repository, "/") {
+		if !componentPattern.MatchString(component) {
+			return Reference{}, fmt.Errorf("%w %q: invalid repository component %q", ErrInvalid, Redact(original), component)
+		}
+	}
+	parsed.Repository = repository
+	return parsed, nil
+}
+
+// splitRegistry separates a leading registry host from the repository path.
+func splitRegistry(name string) (string, string) {
+	first, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", name
+	}
+	if first == "localhost" || strings.ContainsAny(first, ".:") || strings.HasPrefix(first, "[") {
+		return first, rest
+	}
+	return "", name
+}
+
+// Normalize parses ref and returns its canonical form, adding the default
+// registry, Docker Hub's library namespace, and the "latest" tag when missing.
+func Normalize(ref string) (string, error) {
+	parsed, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return parsed.WithDefaultTag().String(), nil
+}
+
+// MustParse is like Parse but panics on error. It is intended for constants in tests.
+func MustParse(ref string) Reference {
+	parsed, err := Parse(ref)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// Name returns the fully qualified repository, "registry/repository".
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference in "registry/repository[:tag][@digest]" form.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest.String()
+	}
+	return s
+}
+
+// Identifier returns what a registry resolves for this reference: the digest when
+// pinned, otherwise the tag, otherwise DefaultTag.
+func (r Reference) Identifier() string {
+	switch {
+	case r.Digest != "":
+		return r.Digest.String()
+	case r.Tag != "":
+		return r.Tag
+	default:
+		return DefaultTag
+	}
+}
+
+// WithDefaultTag returns r with DefaultTag set when it pins neither tag nor digest.
+func (r Reference) WithDefaultTag() Reference {
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+	return r
+}
+
+// Kind classifies what r pins.
+func (r Reference) Kind() Kind {
+	switch {
+	case r.Digest != "":
+		return KindDigest
+	case r.Tag != "":
+		return KindTag
+	default:
+		return KindNone
+	}
+}
+
+// IsDigest reports whether r pins a content digest.
+func (r Reference) IsDigest() bool {
+	return r.Digest != ""
+}
+
+// IsTag reports whether r names a tag.
+func (r Reference) IsTag() bool {
+	return r.Tag != ""
+}
+
+// IsDockerHub reports whether r points at Docker Hub.
+func (r Reference) IsDockerHub() bool {
+	return r.Registry == DefaultRegistry
+}
+
+// IsDigest reports whether s is a well-formed content digest such as "sha256:…".
+func IsDigest(s string) bool {
+	_, err := digest.Parse(s)
+	return err == nil
+}
+
+// IsTag reports whether s is a well-formed tag.
+func IsTag(s string) bool {
+	return tagPattern.MatchString(s)
+}
+
+// NormalizeRegistry reduces a registry as written in configuration — with or
+// without scheme, credentials, trailing path, or mixed case — to its host[:port].
+// All Docker Hub host names collapse to "docker.io".
+func NormalizeRegistry(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if scheme, rest, ok := strings.Cut(trimmed, "://"); ok && !strings.ContainsAny(scheme, "/@") {
+		trimmed = rest
+	}
+	trimmed, _, _ = strings.Cut(trimmed, "/")
+	if idx := strings.LastIndex(trimmed, "@"); idx >= 0 {
+		trimmed = trimmed[idx+1:]
+	}
+	host := strings.ToLower(trimmed)
+	if _, ok := dockerHubHosts[host]; ok {
+		return DefaultRegistry
+	}
+	return host
+}
+
+// SameRegistry reports whether a and b address the same registry after normalization.
+func SameRegistry(a, b string) bool {
+	na := NormalizeRegistry(a)
+	return na != "" && na == NormalizeRegistry(b)
+}
+
+// ExpandAlias rewrites ref when its repository part equals an alias or starts with
+// an alias followed by "/". Aliases name full repository prefixes, so with
+// {"ds": "ghcr.io/delivery-station"} the reference "ds/porter:1.0" expands to
+// "ghcr.io/delivery-station/porter:1.0". The longest matching alias wins.
+func ExpandAlias(ref string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return ref
+	}
+	keys := make([]string, 0, len(aliases))
+	for key := range aliases {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	for _, key := range keys {
+		target := strings.TrimSuffix(aliases[key], "/")
+		if key == "" || target == "" || !strings.HasPrefix(ref, key) {
+			continue
+		}
+		rest := ref[len(key):]
+		if rest == "" || strings.ContainsRune("/:@", rune(rest[0])) {
+			return target + rest
+		}
+	}
+	return ref
+}
+
+// Redact removes credentials from a reference or registry URL so it can be logged:
+// URL userinfo and "user:password@" prefixes are replaced, and token-like query
+// parameters are masked.
+func Redact(s string) string {
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			if u.User != nil {
+				u.User = url.User("redacted")
+			}
+			if query := u.Query(); len(query) > 0 {
+				for key := range query {
+					if isSecretParam(key) {
+						query.Set(key, "redacted")
+					}
+				}
+				u.RawQuery = query.Encode()
+			}
+			return u.String()
+		}
+	}
+
+	// "user:password@registry/repo" – the digest separator never precedes a "/".
+	if at := strings.Index(s, "@"); at >= 0 && strings.Contains(s[at:], "/") {
+		return "redacted@" + s[at+1:]
+	}
+	return s
+}
+
+func isSecretParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"token", "password", "secret", "signature", "credential", "key"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package release
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies the inode behind a file with more than one link.
+type fileID struct {
+	dev, ino uint64
+}
+
+// hardLinkID returns the inode of info when other paths may link to it.
+func hardLinkID(info fs.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 || !info.Mode().IsRegular() {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
@@ -0,0 +1,180 @@
+package release
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// archiveDigestsFile caches, in the staging workspace, the digests of directory
+	// archives by directory fingerprint.
+	archiveDigestsFile = "archive-digests.json"
+	// maxArchiveDigests bounds the cache; the least recently used entries go first.
+	maxArchiveDigests = 256
+)
+
+// archiveDigest is the digest and size of a directory archive.
+type archiveDigest struct {
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+	UsedAt time.Time     `json:"used_at"`
+}
+
+// archiveFingerprint hashes the tar headers of dir, which carry the name, mode,
+// size, owner, modification time, link target and extended attributes of every
+// entry, together with compression.
+// Archives of directories with the same fingerprint are identical unless a file
+// was rewritten in place with the same size and modification time.
+func archiveFingerprint(dir, compression string) (string, error) {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "compression %s\n", compression)
+	err := walkArchive(dir, func(_ string, h *tar.Header) error {
+		_, err := fmt.Fprintf(hash, "%q %c %o %d %d %d %d %q %q %q\n",
+			h.Name, h.Typeflag, h.Mode, h.Size, h.ModTime.UnixNano(), h.Uid, h.Gid, h.Uname, h.Gname, h.Linkname)
+		if err != nil {
+			return err
+		}
+		records := make([]string, 0, len(h.PAXRecords))
+		for key := range h.PAXRecords {
+			records = append(records, key)
+		}
+		sort.Strings(records)
+		for _, key := range records {
+			if _, err := fmt.Fprintf(hash, "\t%q %q\n", key, h.PAXRecords[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ArchiveDigest returns the digest and size of the archive of dir compressed with
+// compression. A directory whose fingerprint is unchanged since it was last
+// archived is not read again; a nil Staging always archives it.
+func (s *Staging) ArchiveDigest(dir, compression string) (digest.Digest, int64, error) {
+	var fingerprint string
+	if s != nil {
+		var err error
+		if fingerprint, err = archiveFingerprint(dir, compression); err != nil {
+			return "", 0, fmt.Errorf("failed to fingerprint directory %s: %w", dir, err)
+		}
+		s.mu.Lock()
+		cached, ok := s.loadArchiveDigests()[fingerprint]
+		s.mu.Unlock()
+		if ok {
+			s.storeArchiveDigest(fingerprint, cached)
+			return cached.Digest, cached.Size, nil
+		}
+	}
+
+	digester := digest.Canonical.Digester()
+	counter := &countingWriter{w: digester.Hash()}
+	if err := writeArchive(counter, dir, compression); err != nil {
+		return "", 0, fmt.Errorf("failed to archive directory %s: %w", dir, err)
+	}
+	if s != nil {
+		s.storeArchiveDigest(fingerprint, archiveDigest{Digest: digester.Digest(), Size: counter.n})
+	}
+	return digester.Digest(), counter.n, nil
+}
+
+// loadArchiveDigests reads the digest cache; a missing or unreadable cache is
+// empty. The caller holds s.mu.
+func (s *Staging) loadArchiveDigests() map[string]archiveDigest {
+	digests := make(map[string]archiveDigest)
+	data, err := os.ReadFile(filepath.Join(s.dir, archiveDigestsFile))
+	if err == nil {
+		_ = json.Unmarshal(data, &digests)
+	}
+	return digests
+}
+
+// storeArchiveDigest records entry under fingerprint. The cache only saves work,
+// so failing to write it is not an error.
+func (s *Staging) storeArchiveDigest(fingerprint string, entry archiveDigest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digests := s.loadArchiveDigests()
+	entry.UsedAt = time.Now().UTC()
+	digests[fingerprint] = entry
+	if len(digests) > maxArchiveDigests {
+		keys := make([]string, 0, len(digests))
+		for key := range digests {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return digests[keys[i]].UsedAt.Before(digests[keys[j]].UsedAt) })
+		for _, key := range keys[:len(digests)-maxArchiveDigests] {
+			delete(digests, key)
+		}
+	}
+
+	s.saveArchiveDigests(digests)
+}
+
+// saveArchiveDigests replaces the digest cache. The caller holds s.mu.
+func (s *Staging) saveArchiveDigests(digests map[string]archiveDigest) {
+	data, err := json.Marshal(digests)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(s.dir, archiveDigestsFile+".*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	if closeErr := tmp.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmp.Name(), filepath.Join(s.dir, archiveDigestsFile))
+	}
+	if writeErr != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// forgetArchiveDigest drops the cached digest of dir, e.g. after a file in it was
+// rewritten without changing its size or modification time.
+func (s *Staging) forgetArchiveDigest(dir, compression string) {
+	if s == nil {
+		return
+	}
+	fingerprint, err := archiveFingerprint(dir, compression)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	digests := s.loadArchiveDigests()
+	if _, ok := digests[fingerprint]; !ok {
+		return
+	}
+	delete(digests, fingerprint)
+	s.saveArchiveDigests(digests)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
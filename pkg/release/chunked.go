@@ -0,0 +1,251 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// chunkAttempts bounds how often one chunk is sent before the upload fails.
+const chunkAttempts = 3
+
+// chunkedTarget uploads blobs of at least chunkSize bytes as a chunked upload
+// session, one PATCH request per chunk, so no single request has to carry a
+// multi-gigabyte body through proxies that time such requests out. A chunk whose
+// request fails is resumed from the offset the registry reports having received,
+// and sessions are recorded so a later push resumes a blob this one left
+// unfinished. Manifests and smaller blobs go through repo unchanged.
+type chunkedTarget struct {
+	oras.Target
+	repo      *remote.Repository
+	chunkSize int64
+	sessions  uploadSessions
+}
+
+func (t *chunkedTarget) Push(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	if desc.Size < t.chunkSize || isManifestMediaType(desc.MediaType) {
+		return t.repo.Push(ctx, desc, r)
+	}
+	return t.pushChunked(ctx, desc, r)
+}
+
+// PushReference keeps pushes by tag in a single request; manifests are small.
+func (t *chunkedTarget) PushReference(ctx context.Context, desc ocispec.Descriptor, r io.Reader, reference string) error {
+	return t.repo.PushReference(ctx, desc, r, reference)
+}
+
+var _ registry.ReferencePusher = (*chunkedTarget)(nil)
+
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex,
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json":
+		return true
+	}
+	return false
+}
+
+func (t *chunkedTarget) pushChunked(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	ref := t.repo.Reference
+	ctx = auth.AppendRepositoryScope(ctx, ref, auth.ActionPull, auth.ActionPush)
+	scheme := "https"
+	if t.repo.PlainHTTP {
+		scheme = "http"
+	}
+	start := &url.URL{Scheme: scheme, Host: ref.Host(), Path: "/v2/" + ref.Repository + "/blobs/uploads/"}
+
+	verifier := desc.Digest.Verifier()
+	location, offset, err := t.resumeSession(ctx, desc, r, verifier)
+	if err != nil {
+		return err
+	}
+	if location == nil {
+		resp, err := t.do(ctx, http.MethodPost, start.String(), nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to start chunked upload of %s: %w", desc.Digest, err)
+		}
+		if location, err = uploadLocation(start, resp); err != nil {
+			return err
+		}
+		t.sessions.save(ref, desc.Digest, location)
+	}
+
+	chunk := make([]byte, t.chunkSize)
+	for offset < desc.Size {
+		n, err := io.ReadFull(r, chunk[:min(t.chunkSize, desc.Size-offset)])
+		if err != nil {
+			return fmt.Errorf("failed to read %s at offset %d: %w", desc.Digest, offset, err)
+		}
+		_, _ = verifier.Write(chunk[:n])
+		if location, err = t.patchChunk(ctx, location, chunk[:n], offset); err != nil {
+			return fmt.Errorf("failed to upload %s at offset %d: %w", desc.Digest, offset, err)
+		}
+		t.sessions.save(ref, desc.Digest, location)
+		offset += int64(n)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("content of %s does not match its digest", desc.Digest)
+	}
+
+	query := location.Query()
+	query.Set("digest", desc.Digest.String())
+	location.RawQuery = query.Encode()
+	header := http.Header{"Content-Length": {"0"}}
+	if _, err := t.do(ctx, http.MethodPut, location.String(), header, nil); err != nil {
+		return fmt.Errorf("failed to complete chunked upload of %s: %w", desc.Digest, err)
+	}
+	t.sessions.remove(ref, desc.Digest)
+	return nil
+}
+
+// resumeSession continues the upload session an earlier push of desc recorded,
+// reading the bytes the registry already holds from r into verifier. It returns
+// the location to continue at and the offset reached, or a nil location when
+// there is no session or the registry no longer has it.
+func (t *chunkedTarget) resumeSession(ctx context.Context, desc ocispec.Descriptor, r io.Reader, verifier io.Writer) (*url.URL, int64, error) {
+	ref := t.repo.Reference
+	saved := t.sessions.load(ref, desc.Digest)
+	if saved == nil {
+		return nil, 0, nil
+	}
+	received, location, err := t.uploadStatus(ctx, saved)
+	if err != nil && ctx.Err() != nil {
+		return nil, 0, err
+	}
+	if err != nil || received > desc.Size {
+		t.sessions.remove(ref, desc.Digest)
+		return nil, 0, nil
+	}
+	if _, err := io.CopyN(verifier, r, received); err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s up to offset %d: %w", desc.Digest, received, err)
+	}
+	return location, received, nil
+}
+
+// patchChunk sends data, which starts at offset in the blob, to the upload
+// session at location and returns the location of the next chunk. A failed
+// request is retried from the offset the registry reports having received.
+func (t *chunkedTarget) patchChunk(ctx context.Context, location *url.URL, data []byte, offset int64) (*url.URL, error) {
+	sent := int64(0)
+	var lastErr error
+	for attempt := 0; attempt < chunkAttempts; attempt++ {
+		if attempt > 0 {
+			received, next, err := t.uploadStatus(ctx, location)
+			if err != nil {
+				return nil, fmt.Errorf("%w; resuming failed: %v", lastErr, err)
+			}
+			if received < offset || received > offset+int64(len(data)) {
+				return nil, fmt.Errorf("%w; the registry holds %d bytes, which this chunk cannot resume from", lastErr, received)
+			}
+			location, sent = next, received-offset
+			if sent == int64(len(data)) {
+				return location, nil
+			}
+		}
+		header := http.Header{
+			"Content-Type":   {"application/octet-stream"},
+			"Content-Range":  {fmt.Sprintf("%d-%d", offset+sent, offset+int64(len(data))-1)},
+			"Content-Length": {strconv.Itoa(len(data) - int(sent))},
+		}
+		resp, err := t.do(ctx, http.MethodPatch, location.String(), header, data[sent:])
+		if err == nil {
+			return uploadLocation(location, resp)
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// uploadStatus asks the registry how much of the upload session at location it
+// has received, returning that byte count and the location to continue at.
+func (t *chunkedTarget) uploadStatus(ctx context.Context, location *url.URL) (int64, *url.URL, error) {
+	resp, err := t.do(ctx, http.MethodGet, location.String(), nil, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	next, err := uploadLocation(location, resp)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Range is "0-<last byte received>"; an empty session has none.
+	value := resp.Header.Get("Range")
+	if value == "" {
+		return 0, next, nil
+	}
+	_, last, ok := strings.Cut(value, "-")
+	end, err := strconv.ParseInt(last, 10, 64)
+	if !ok || err != nil {
+		return 0, nil, fmt.Errorf("invalid upload Range %q", value)
+	}
+	return end + 1, next, nil
+}
+
+// do sends a request with body to the registry and returns the response once
+// drained, or an error for any status but 2xx.
+func (t *chunkedTarget) do(ctx context.Context, method, target string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.ContentLength = int64(len(body))
+	client := t.repo.Client
+	if client == nil {
+		client = auth.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Errors read like those of the ORAS repository the other requests go through.
+		errResp := &errcode.ErrorResponse{Method: method, URL: req.URL, StatusCode: resp.StatusCode}
+		var body struct {
+			Errors errcode.Errors `json:"errors"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body) == nil {
+			errResp.Errors = body.Errors
+		}
+		return nil, errResp
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp, nil
+}
+
+// uploadLocation resolves the Location header of an upload response against the
+// URL of its request.
+func uploadLocation(base *url.URL, resp *http.Response) (*url.URL, error) {
+	value := resp.Header.Get("Location")
+	if value == "" {
+		return nil, errors.New("registry returned no upload location")
+	}
+	location, err := base.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload location %q: %w", value, err)
+	}
+	return location, nil
+}
@@ -0,0 +1,87 @@
+package release
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats for directory archives.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// ArchiveMediaType returns the archive layer media type for compression. An empty
+// compression selects gzip.
+func ArchiveMediaType(compression string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(compression)) {
+	case "", CompressionGzip:
+		return MediaTypeArtifactArchive, nil
+	case CompressionZstd:
+		return MediaTypeArtifactArchiveZstd, nil
+	default:
+		return "", fmt.Errorf("unsupported compression %q: expected %s or %s", compression, CompressionGzip, CompressionZstd)
+	}
+}
+
+// ArchiveCompression returns the compression of a tar archive layer media type, or
+// "" when mediaType is not a compressed tar archive.
+func ArchiveCompression(mediaType string) string {
+	switch {
+	case strings.Contains(mediaType, "tar+gzip"):
+		return CompressionGzip
+	case strings.Contains(mediaType, "tar+zstd"):
+		return CompressionZstd
+	default:
+		return ""
+	}
+}
+
+// ArchiveExtension returns the file extension of a tar archive compressed with
+// compression, or "" for an unknown compression.
+func ArchiveExtension(compression string) string {
+	switch compression {
+	case CompressionGzip:
+		return ".tar.gz"
+	case CompressionZstd:
+		return ".tar.zst"
+	}
+	return ""
+}
+
+// NewCompressor returns a writer that compresses to w. Closing it flushes the
+// compressed stream but does not close w.
+func NewCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// NewDecompressor returns a reader that decompresses r.
+func NewDecompressor(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init gzip reader: %w", err)
+		}
+		return gz, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
@@ -0,0 +1,69 @@
+package release
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveMediaType(t *testing.T) {
+	mediaType, err := ArchiveMediaType("")
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeArtifactArchive, mediaType)
+
+	mediaType, err = ArchiveMediaType("ZSTD")
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeArtifactArchiveZstd, mediaType)
+
+	_, err = ArchiveMediaType("xz")
+	assert.Error(t, err)
+}
+
+func TestArchiveCompression(t *testing.T) {
+	assert.Equal(t, CompressionGzip, ArchiveCompression(MediaTypeArtifactArchive))
+	assert.Equal(t, CompressionZstd, ArchiveCompression(MediaTypeArtifactArchiveZstd))
+	assert.Equal(t, CompressionZstd, ArchiveCompression("application/vnd.oci.image.layer.v1.tar+zstd"))
+	assert.Empty(t, ArchiveCompression(MediaTypeArtifactBinary))
+}
+
+func TestArchiveDirectoryWithZstd(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("tool binary"), 0755))
+
+	archivePath, cleanup, err := archiveDirectory(dir, CompressionZstd)
+	require.NoError(t, err)
+	defer cleanup()
+
+	f, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	decompressed, err := NewDecompressor(f, CompressionZstd)
+	require.NoError(t, err)
+	defer func() {
+		_ = decompressed.Close()
+	}()
+
+	files := make(map[string]string)
+	tr := tar.NewReader(decompressed)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[header.Name] = string(data)
+		}
+	}
+	assert.Equal(t, map[string]string{"bin/tool": "tool binary"}, files)
+}
@@ -0,0 +1,244 @@
+package release
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Operating systems and architectures recognized in build output paths, with the
+// aliases build tools use for them.
+var (
+	pathOS = map[string]string{
+		"linux": "linux", "darwin": "darwin", "macos": "darwin", "windows": "windows",
+		"freebsd": "freebsd", "netbsd": "netbsd", "openbsd": "openbsd", "illumos": "illumos",
+	}
+	pathArch = map[string]string{
+		"amd64": "amd64", "x64": "amd64", "arm64": "arm64", "aarch64": "arm64",
+		"386": "386", "i386": "386", "arm": "arm", "armv6": "arm", "armv7": "arm",
+		"ppc64le": "ppc64le", "s390x": "s390x", "riscv64": "riscv64", "mips64le": "mips64le",
+	}
+)
+
+// PathPlatform detects the platform a build output path names, as in
+// dist/porter_linux_amd64_v1/porter or bin/darwin/arm64/porter, from its
+// components split at "/", "_", "-" and ".". An "arm" architecture takes its
+// variant from a following "6", "7", "v6" or "v7" component, or from "armv7".
+func PathPlatform(rel string) (Platform, bool) {
+	// x86_64 is the one name that contains a separator.
+	rel = strings.ReplaceAll(strings.ToLower(filepath.ToSlash(rel)), "x86_64", "amd64")
+	tokens := strings.FieldsFunc(rel, func(r rune) bool {
+		return r == '/' || r == '_' || r == '-' || r == '.'
+	})
+	var platform Platform
+	for i, token := range tokens {
+		if name, ok := pathOS[token]; ok && platform.OS == "" {
+			platform.OS = name
+			continue
+		}
+		arch, ok := pathArch[token]
+		if !ok || platform.OS == "" || platform.Arch != "" {
+			continue
+		}
+		platform.Arch = arch
+		if arch != "arm" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(token, "armv"):
+			platform.Variant = strings.TrimPrefix(token, "arm")
+		case i+1 < len(tokens) && (tokens[i+1] == "6" || tokens[i+1] == "7"):
+			platform.Variant = "v" + tokens[i+1]
+		case i+1 < len(tokens) && (tokens[i+1] == "v6" || tokens[i+1] == "v7"):
+			platform.Variant = tokens[i+1]
+		}
+	}
+	return platform, platform.OS != "" && platform.Arch != ""
+}
+
+// isPathPattern reports whether a manifest entry path is a glob pattern.
+func isPathPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandPattern expands the entry whose path is a glob pattern into one entry per
+// match, relative to dir as the pattern is. Each match takes the platform the part
+// of its path matched by the pattern names, such as linux_amd64 in
+// dist/tool_linux_amd64 for dist/tool_*, and an executable must have been built
+// for that platform. The problems found are returned as messages.
+func expandPattern(dir string, entry ManifestEntry) ([]ManifestEntry, []string) {
+	pattern := filepath.FromSlash(strings.TrimSpace(entry.Path))
+	resolved := pattern
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(dir, resolved)
+	}
+	matches, err := filepath.Glob(resolved)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("invalid path pattern %s: %v", entry.Path, err)}
+	}
+	if len(matches) == 0 {
+		return nil, []string{fmt.Sprintf("path pattern %s matches no files", entry.Path)}
+	}
+	sort.Strings(matches)
+
+	// Only the components the pattern matched name the platform, so a directory
+	// such as builds/linux/ above them cannot.
+	literal := strings.Split(filepath.ToSlash(resolved), "/")
+	for i, component := range literal {
+		if isPathPattern(component) {
+			literal = literal[:i]
+			break
+		}
+	}
+
+	var expanded []ManifestEntry
+	var problems []string
+	for _, match := range matches {
+		components := strings.Split(filepath.ToSlash(match), "/")
+		named := strings.Join(components[len(literal):], "/")
+		path := match
+		if !filepath.IsAbs(pattern) {
+			if path, err = filepath.Rel(dir, match); err != nil {
+				problems = append(problems, fmt.Sprintf("failed to relate %s to the manifest: %v", match, err))
+				continue
+			}
+		}
+		platform, ok := PathPlatform(named)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("path %s matches %s but names no platform such as linux_amd64", filepath.ToSlash(path), entry.Path))
+			continue
+		}
+		if err := checkBinaryPlatform(match, platform); err != nil {
+			problems = append(problems, fmt.Sprintf("path %s %v", filepath.ToSlash(path), err))
+			continue
+		}
+		concrete := entry
+		concrete.Platform = platform.FormatString()
+		concrete.Path = filepath.ToSlash(path)
+		expanded = append(expanded, concrete)
+	}
+	return expanded, problems
+}
+
+// checkBinaryPlatform reports an ELF, Mach-O or PE executable at path built for an
+// operating system or architecture other than platform, with an error that reads
+// as the end of a sentence about path. Other files, directories, and headers that
+// fail to parse are not checked.
+func checkBinaryPlatform(path string, platform Platform) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return nil
+	}
+
+	var format string
+	var archs []string
+	switch {
+	case bytes.Equal(head, []byte{0x7f, 'E', 'L', 'F'}):
+		format = "ELF"
+		if file, err := elf.NewFile(f); err == nil {
+			archs = []string{elfArch(file)}
+		}
+	case bytes.Equal(head, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		format = "Mach-O"
+		if fat, err := macho.NewFatFile(f); err == nil {
+			for _, arch := range fat.Arches {
+				archs = append(archs, machoArch(arch.Cpu))
+			}
+		}
+	case bytes.Equal(head[:3], []byte{0xfe, 0xed, 0xfa}) || bytes.Equal(head[1:], []byte{0xfa, 0xed, 0xfe}):
+		format = "Mach-O"
+		if file, err := macho.NewFile(f); err == nil {
+			archs = []string{machoArch(file.Cpu)}
+		}
+	case bytes.Equal(head[:2], []byte{'M', 'Z'}):
+		format = "PE"
+		if file, err := pe.NewFile(f); err == nil {
+			archs = []string{peArch(file.Machine)}
+		}
+	}
+	if len(archs) == 0 {
+		return nil
+	}
+
+	switch {
+	case format == "Mach-O" && platform.OS != "darwin",
+		format == "PE" && platform.OS != "windows",
+		format == "ELF" && (platform.OS == "darwin" || platform.OS == "windows"):
+		return fmt.Errorf("is named for %s but its %s header is for another operating system", platform.FormatString(), format)
+	}
+	for _, arch := range archs {
+		if arch == "" || arch == platform.Arch {
+			return nil
+		}
+	}
+	return fmt.Errorf("is named for %s but its %s header is for %s", platform.FormatString(), format, strings.Join(archs, ", "))
+}
+
+func elfArch(file *elf.File) string {
+	switch file.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_S390:
+		return "s390x"
+	}
+	if file.Class != elf.ELFCLASS64 || file.Data != elf.ELFDATA2LSB {
+		return ""
+	}
+	switch file.Machine {
+	case elf.EM_PPC64:
+		return "ppc64le"
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_MIPS:
+		return "mips64le"
+	}
+	return ""
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm:
+		return "arm"
+	}
+	return ""
+}
+
+func peArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	}
+	return ""
+}
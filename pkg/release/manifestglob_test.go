@@ -0,0 +1,100 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPlatform(t *testing.T) {
+	for rel, want := range map[string]string{
+		"porter_linux_amd64_v1/porter":  "linux/amd64",
+		"bin/darwin/arm64/porter":       "darwin/arm64",
+		"tool-linux-aarch64":            "linux/arm64",
+		"tool_linux_armv6":              "linux/arm/v6",
+		"out/windows/x86_64/tool.exe":   "windows/amd64",
+		"porter_1.0.0_linux_386.tar.gz": "linux/386",
+	} {
+		platform, ok := PathPlatform(rel)
+		require.True(t, ok, rel)
+		assert.Equal(t, want, platform.FormatString(), rel)
+	}
+	_, ok := PathPlatform("checksums.txt")
+	assert.False(t, ok)
+}
+
+func TestLoadManifestExpandsPathPattern(t *testing.T) {
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "dist", "linux")
+	require.NoError(t, os.MkdirAll(dist, 0755))
+	for _, name := range []string{"tool_darwin_arm64", "tool_linux_amd64", "tool_windows_amd64.exe"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dist, name), []byte(name), 0755))
+	}
+	path := filepath.Join(dir, "ds.manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`manifests:
+  - path: dist/linux/tool_*
+    mediaType: application/vnd.delivery-station.artifact.v1+binary
+    annotations:
+      org.opencontainers.image.version: "1.0.0"
+`), 0644))
+
+	manifest, err := LoadManifest(path)
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 3)
+	assert.Equal(t, ManifestEntry{
+		Platform:    "darwin/arm64",
+		MediaType:   MediaTypeArtifactBinary,
+		Path:        "dist/linux/tool_darwin_arm64",
+		Annotations: map[string]string{"org.opencontainers.image.version": "1.0.0"},
+	}, manifest.Manifests[0])
+	assert.Equal(t, "linux/amd64", manifest.Manifests[1].Platform)
+	assert.Equal(t, "windows/amd64", manifest.Manifests[2].Platform)
+}
+
+func TestLoadManifestChecksPatternMatches(t *testing.T) {
+	format := map[string]string{"linux": "ELF", "darwin": "Mach-O", "windows": "PE"}[runtime.GOOS]
+	if format == "" {
+		t.Skip("test binaries are only checked on linux, darwin and windows")
+	}
+	self, err := os.Executable()
+	require.NoError(t, err)
+	binary, err := os.ReadFile(self)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "dist")
+	require.NoError(t, os.MkdirAll(dist, 0755))
+	other := "arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "amd64"
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dist, "tool_"+runtime.GOOS+"_"+runtime.GOARCH), binary, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dist, "tool_"+runtime.GOOS+"_"+other), binary, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dist, "tool_checksums.txt"), []byte("sums"), 0644))
+	path := filepath.Join(dir, "ds.manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`manifests:
+  - platform: linux/amd64
+    path: dist/tool_*
+  - path: dist/missing_*
+`), 0644))
+
+	_, err = LoadManifest(path)
+	var manifestErr *ManifestError
+	require.True(t, errors.As(err, &manifestErr), "error: %v", err)
+	messages := make([]string, 0, len(manifestErr.Problems))
+	for _, problem := range manifestErr.Problems {
+		messages = append(messages, problem.String())
+	}
+	assert.Equal(t, []string{
+		"line 2: platform cannot be set for path pattern dist/tool_*; each match takes the platform its name gives",
+		"line 3: path dist/tool_checksums.txt matches dist/tool_* but names no platform such as linux_amd64",
+		"line 3: path dist/tool_" + runtime.GOOS + "_" + other + " is named for " + runtime.GOOS + "/" + other +
+			" but its " + format + " header is for " + runtime.GOARCH,
+		"line 4: path pattern dist/missing_* matches no files",
+	}, messages)
+}
@@ -0,0 +1,289 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestProblem is one violation of the ds.manifest.yaml schema, at the line it
+// was found on; Line is 0 when the problem has no single location.
+type ManifestProblem struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p ManifestProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return p.Message
+}
+
+// ManifestError lists every schema violation found in a manifest.
+type ManifestError struct {
+	Path     string
+	Problems []ManifestProblem
+}
+
+func (e *ManifestError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, problem.String())
+	}
+	return fmt.Sprintf("invalid manifest %s: %s", e.Path, strings.Join(lines, "; "))
+}
+
+// manifestFields and manifestEntryFields are the keys the schema allows, from the
+// yaml tags of Manifest and ManifestEntry.
+var (
+	manifestFields      = []string{"artifact-type", "annotations", "compression", "manifests", "sboms", "metadata"}
+	manifestEntryFields = []string{"platform", "mediaType", "path", "annotations", "config", "configMediaType", "title"}
+)
+
+// platformComponent matches one component of an os/arch/variant platform.
+var platformComponent = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// parseManifest strictly decodes the manifest data read from path. Unknown
+// fields, entries without a path or with a path that does not exist, invalid or
+// duplicate platforms, and unsupported compressions are all reported, each with
+// its line.
+func parseManifest(data []byte, path string) (*Manifest, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, &ManifestError{Path: path, Problems: []ManifestProblem{{Message: "manifest is empty"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, &ManifestError{Path: path, Problems: []ManifestProblem{{Line: root.Line, Message: "manifest must be a mapping"}}}
+	}
+
+	var manifest Manifest
+	if err := root.Decode(&manifest); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+		// Type errors carry their line in the message already.
+		manifestErr := &ManifestError{Path: path}
+		for _, message := range typeErr.Errors {
+			manifestErr.Problems = append(manifestErr.Problems, ManifestProblem{Message: message})
+		}
+		return nil, manifestErr
+	}
+
+	v := manifestValidator{dir: filepath.Dir(path)}
+	v.fields(root, manifestFields, "manifest")
+	entries := mappingValue(root, "manifests")
+	switch {
+	case entries == nil || len(entries.Content) == 0:
+		line := root.Line
+		if entries != nil {
+			line = entries.Line
+		}
+		v.add(line, "manifests must list at least one entry")
+	case entries.Kind == yaml.SequenceNode:
+		seen := make(map[string]int)
+		var expanded []ManifestEntry
+		for i, entry := range entries.Content {
+			if i < len(manifest.Manifests) && isPathPattern(manifest.Manifests[i].Path) {
+				expanded = append(expanded, v.pattern(entry, manifest.Manifests[i], seen)...)
+				continue
+			}
+			v.entry(entry, seen)
+			if i < len(manifest.Manifests) {
+				expanded = append(expanded, manifest.Manifests[i])
+			}
+		}
+		manifest.Manifests = expanded
+	}
+	if compression := mappingValue(root, "compression"); compression != nil {
+		if _, err := ArchiveMediaType(compression.Value); err != nil {
+			v.add(compression.Line, "%v", err)
+		}
+	}
+	if metadata := mappingValue(root, "metadata"); metadata != nil && metadata.Kind == yaml.MappingNode {
+		v.fields(metadata, metadataFields, "metadata")
+		if err := manifest.Metadata.Validate(); err != nil {
+			v.add(mappingValue(metadata, "created").Line, "%v", err)
+		}
+	}
+	if sboms := mappingValue(root, "sboms"); sboms != nil && sboms.Kind == yaml.SequenceNode {
+		for _, sbom := range sboms.Content {
+			v.exists(sbom, "sbom")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return nil, &ManifestError{Path: path, Problems: v.problems}
+	}
+	return &manifest, nil
+}
+
+// manifestValidator collects the problems of one manifest.
+type manifestValidator struct {
+	dir      string
+	problems []ManifestProblem
+}
+
+func (v *manifestValidator) add(line int, format string, args ...interface{}) {
+	v.problems = append(v.problems, ManifestProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// fields reports the keys of node that allowed does not list, suggesting the
+// allowed key that differs only in case, such as mediaType for mediatype.
+func (v *manifestValidator) fields(node *yaml.Node, allowed []string, what string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		known := false
+		suggestion := ""
+		for _, field := range allowed {
+			if key.Value == field {
+				known = true
+				break
+			}
+			if strings.EqualFold(strings.ReplaceAll(key.Value, "_", "-"), field) {
+				suggestion = field
+			}
+		}
+		switch {
+		case known:
+		case suggestion != "":
+			v.add(key.Line, "unknown %s field %q, did you mean %q?", what, key.Value, suggestion)
+		default:
+			v.add(key.Line, "unknown %s field %q; expected one of %s", what, key.Value, strings.Join(allowed, ", "))
+		}
+	}
+}
+
+// entry validates one element of manifests, recording its platform in seen.
+func (v *manifestValidator) entry(node *yaml.Node, seen map[string]int) {
+	if node.Kind != yaml.MappingNode {
+		v.add(node.Line, "manifest entry must be a mapping with platform and path")
+		return
+	}
+	v.fields(node, manifestEntryFields, "manifest entry")
+
+	platform := ""
+	if value := mappingValue(node, "platform"); value != nil {
+		platform = strings.TrimSpace(value.Value)
+		if err := validatePlatform(platform); err != nil {
+			v.add(value.Line, "%v", err)
+		}
+	}
+	if first, ok := seen[platform]; ok {
+		v.add(node.Line, "%s is already listed on line %d", platformName(platform), first)
+	} else {
+		seen[platform] = node.Line
+	}
+
+	if config := mappingValue(node, "config"); config != nil && strings.TrimSpace(config.Value) != "" {
+		v.exists(config, "config")
+	} else if mediaType := mappingValue(node, "configMediaType"); mediaType != nil {
+		v.add(mediaType.Line, "configMediaType is set but the entry has no config")
+	}
+
+	v.title(node)
+
+	path := mappingValue(node, "path")
+	if path == nil || strings.TrimSpace(path.Value) == "" {
+		v.add(node.Line, "manifest entry for %s has no path", platformName(platform))
+		return
+	}
+	v.exists(path, "path")
+}
+
+// pattern validates an entry whose path is a glob pattern and returns one entry
+// per match, recording their platforms in seen.
+func (v *manifestValidator) pattern(node *yaml.Node, entry ManifestEntry, seen map[string]int) []ManifestEntry {
+	v.fields(node, manifestEntryFields, "manifest entry")
+	if platform := mappingValue(node, "platform"); platform != nil && strings.TrimSpace(platform.Value) != "" {
+		v.add(platform.Line, "platform cannot be set for path pattern %s; each match takes the platform its name gives", entry.Path)
+	}
+	if config := mappingValue(node, "config"); config != nil && strings.TrimSpace(config.Value) != "" {
+		v.exists(config, "config")
+	}
+	v.title(node)
+	path := mappingValue(node, "path")
+	expanded, problems := expandPattern(v.dir, entry)
+	for _, problem := range problems {
+		v.add(path.Line, "%s", problem)
+	}
+	for _, concrete := range expanded {
+		if first, ok := seen[concrete.Platform]; ok {
+			v.add(path.Line, "%s matches %s, which is already listed on line %d", entry.Path, platformName(concrete.Platform), first)
+			continue
+		}
+		seen[concrete.Platform] = node.Line
+	}
+	return expanded
+}
+
+// title reports an entry title that is not a plain file name, which exports
+// could not write as named.
+func (v *manifestValidator) title(node *yaml.Node) {
+	title := mappingValue(node, "title")
+	if title == nil {
+		return
+	}
+	name := strings.TrimSpace(title.Value)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		v.add(title.Line, "title %q must be a file name without directories", title.Value)
+	}
+}
+
+// exists reports a file named by node that does not exist, resolving relative
+// paths against the manifest directory as pushes do.
+func (v *manifestValidator) exists(node *yaml.Node, what string) {
+	path := strings.TrimSpace(node.Value)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(v.dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add(node.Line, "%s %s does not exist", what, node.Value)
+	}
+}
+
+// validatePlatform checks a manifest platform: "noarch", or os/arch with an
+// optional variant and os version, in lower case.
+func validatePlatform(platform string) error {
+	if platform == "" || platform == "noarch" {
+		return nil
+	}
+	value, _, _ := strings.Cut(platform, ":")
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid platform %q: expected os/arch or os/arch/variant", platform)
+	}
+	for _, part := range parts {
+		if !platformComponent.MatchString(part) {
+			return fmt.Errorf("invalid platform %q: %q is not a lower-case os, arch or variant", platform, part)
+		}
+	}
+	return nil
+}
+
+func platformName(platform string) string {
+	if platform == "" {
+		return "the default platform"
+	}
+	return "platform " + platform
+}
+
+// mappingValue returns the value of key in the mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-linux"), []byte("linux"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool-darwin"), []byte("darwin"), 0755))
+	path := filepath.Join(dir, "ds.manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadManifestAcceptsValidManifest(t *testing.T) {
+	path := writeManifest(t, `artifact-type: application/vnd.delivery-station.artifact.v1+index
+annotations:
+  name: tool
+compression: zstd
+manifests:
+  - platform: linux/amd64
+    mediaType: application/vnd.delivery-station.artifact.v1+binary
+    path: tool-linux
+  - platform: darwin/arm64:14.0
+    path: tool-darwin
+`)
+	manifest, err := LoadManifest(path)
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 2)
+	assert.Equal(t, MediaTypeArtifactBinary, manifest.Manifests[0].MediaType)
+	assert.Equal(t, "darwin/arm64:14.0", manifest.Manifests[1].Platform)
+}
+
+func TestLoadManifestReportsProblemsWithLines(t *testing.T) {
+	path := writeManifest(t, `artifact-type: application/vnd.delivery-station.artifact.v1+index
+manifests:
+  - platform: linux/amd64
+    mediatype: application/vnd.delivery-station.artifact.v1+binary
+    path: tool-linux
+  - platform: linux/amd64
+    path: tool-darwin
+  - platform: Linux
+    path: missing
+  - platform: darwin/arm64
+sbom: sbom.json
+`)
+	_, err := LoadManifest(path)
+	var manifestErr *ManifestError
+	require.True(t, errors.As(err, &manifestErr), "error: %v", err)
+	assert.Equal(t, []ManifestProblem{
+		{Line: 11, Message: `unknown manifest field "sbom"; expected one of artifact-type, annotations, compression, manifests, sboms, metadata`},
+		{Line: 4, Message: `unknown manifest entry field "mediatype", did you mean "mediaType"?`},
+		{Line: 6, Message: "platform linux/amd64 is already listed on line 3"},
+		{Line: 8, Message: `invalid platform "Linux": expected os/arch or os/arch/variant`},
+		{Line: 9, Message: "path missing does not exist"},
+		{Line: 10, Message: "manifest entry for platform darwin/arm64 has no path"},
+	}, manifestErr.Problems)
+	assert.Contains(t, err.Error(), "line 4: unknown manifest entry field \"mediatype\"")
+}
+
+func TestLoadManifestRequiresEntries(t *testing.T) {
+	path := writeManifest(t, "artifact-type: application/vnd.delivery-station.artifact.v1+index\n")
+	_, err := LoadManifest(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "manifests must list at least one entry")
+}
+
+func TestLoadManifestChecksEntryConfig(t *testing.T) {
+	path := writeManifest(t, `manifests:
+  - platform: linux/amd64
+    path: tool-linux
+    config: missing.json
+  - platform: darwin/arm64
+    path: tool-darwin
+    configMediaType: application/vnd.example.config.v1+json
+`)
+	_, err := LoadManifest(path)
+	var manifestErr *ManifestError
+	require.True(t, errors.As(err, &manifestErr), "error: %v", err)
+	assert.Equal(t, []ManifestProblem{
+		{Line: 4, Message: "config missing.json does not exist"},
+		{Line: 7, Message: "configMediaType is set but the entry has no config"},
+	}, manifestErr.Problems)
+}
+
+func TestLoadManifestChecksEntryTitle(t *testing.T) {
+	path := writeManifest(t, `manifests:
+  - platform: linux/amd64
+    path: tool-linux
+    title: tool
+  - platform: darwin/arm64
+    path: tool-darwin
+    title: bin/tool
+`)
+	_, err := LoadManifest(path)
+	var manifestErr *ManifestError
+	require.True(t, errors.As(err, &manifestErr), "error: %v", err)
+	assert.Equal(t, []ManifestProblem{
+		{Line: 7, Message: `title "bin/tool" must be a file name without directories`},
+	}, manifestErr.Problems)
+}
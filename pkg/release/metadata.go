@@ -0,0 +1,174 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"oras.land/oras-go/v2/content"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ArtifactMetadata describes the build of an artifact. It is packed into the
+// config of every platform manifest, so consumers read it as JSON instead of
+// parsing annotations.
+type ArtifactMetadata struct {
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	Commit  string `yaml:"commit,omitempty" json:"commit,omitempty"`
+	// Created is the build date, in RFC 3339 format.
+	Created string `yaml:"created,omitempty" json:"created,omitempty"`
+	// Source is the URL of the source repository.
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+	// Plugin holds the DS plugin metadata, such as its description or the DS
+	// versions it supports.
+	Plugin map[string]string `yaml:"plugin,omitempty" json:"plugin,omitempty"`
+}
+
+// metadataFields are the keys the metadata section of a manifest allows.
+var metadataFields = []string{"version", "commit", "created", "source", "plugin"}
+
+// IsEmpty reports whether m sets no field.
+func (m *ArtifactMetadata) IsEmpty() bool {
+	return m == nil || (m.Version == "" && m.Commit == "" && m.Created == "" && m.Source == "" && len(m.Plugin) == 0)
+}
+
+// Validate checks that Created is an RFC 3339 date.
+func (m *ArtifactMetadata) Validate() error {
+	if m == nil || m.Created == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, m.Created); err != nil {
+		return fmt.Errorf("invalid metadata created %q: expected an RFC 3339 date such as 2024-05-01T12:00:00Z", m.Created)
+	}
+	return nil
+}
+
+// MergeMetadata returns base with the fields over sets replacing its own, and the
+// plugin entries of both. Either may be nil.
+func MergeMetadata(base, over *ArtifactMetadata) *ArtifactMetadata {
+	if over.IsEmpty() {
+		return base
+	}
+	if base.IsEmpty() {
+		return over
+	}
+	merged := *base
+	for _, field := range []struct{ dst, src *string }{
+		{&merged.Version, &over.Version},
+		{&merged.Commit, &over.Commit},
+		{&merged.Created, &over.Created},
+		{&merged.Source, &over.Source},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	if len(over.Plugin) > 0 {
+		merged.Plugin = make(map[string]string, len(base.Plugin)+len(over.Plugin))
+		for key, value := range base.Plugin {
+			merged.Plugin[key] = value
+		}
+		for key, value := range over.Plugin {
+			merged.Plugin[key] = value
+		}
+	}
+	return &merged
+}
+
+// SetMetadataField sets the field of m that key names: version, commit, created,
+// source, or plugin.<name> for an entry of Plugin.
+func SetMetadataField(m *ArtifactMetadata, key, value string) error {
+	switch key {
+	case "version":
+		m.Version = value
+	case "commit":
+		m.Commit = value
+	case "created":
+		m.Created = value
+	case "source":
+		m.Source = value
+	default:
+		name, ok := strings.CutPrefix(key, "plugin.")
+		if !ok || name == "" {
+			return fmt.Errorf("unknown metadata field %q; expected one of version, commit, created, source or plugin.<name>", key)
+		}
+		if m.Plugin == nil {
+			m.Plugin = make(map[string]string)
+		}
+		m.Plugin[name] = value
+	}
+	return nil
+}
+
+// platformConfig is the config of a platform manifest. It uses the field names of
+// an OCI image config for the platform, creation date and labels, but is not one:
+// an image config must list a diff ID per layer, which the binary and archive
+// layers of an artifact do not have.
+type platformConfig struct {
+	*ocispec.Platform
+	Created  *time.Time           `json:"created,omitempty"`
+	Config   *ocispec.ImageConfig `json:"config,omitempty"`
+	Metadata *ArtifactMetadata    `json:"metadata,omitempty"`
+}
+
+// metadataLabels are the image config labels standard tools show for metadata.
+func metadataLabels(metadata *ArtifactMetadata) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range map[string]string{
+		ocispec.AnnotationVersion:  metadata.Version,
+		ocispec.AnnotationRevision: metadata.Commit,
+		ocispec.AnnotationCreated:  metadata.Created,
+		ocispec.AnnotationSource:   metadata.Source,
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// addPlatformConfig adds the config of a platform manifest to store, with
+// MediaTypeArtifactConfig: the os, architecture and variant of platform, so tools
+// that read the platform from the config show it, and metadata when it is not
+// empty. It has no creation time of its own, so pushing the same file again
+// yields the same manifest. Noarch entries carry only metadata; without either
+// the manifest keeps the empty config.
+func addPlatformConfig(ctx context.Context, store *FileStore, platform Platform, metadata *ArtifactMetadata) (*ocispec.Descriptor, error) {
+	var config platformConfig
+	if strings.TrimSpace(platform.OS) != "" {
+		config.Platform = &ocispec.Platform{
+			Architecture: platform.Arch,
+			OS:           platform.OS,
+			Variant:      platform.Variant,
+		}
+	}
+	if !metadata.IsEmpty() {
+		config.Metadata = metadata
+		if labels := metadataLabels(metadata); labels != nil {
+			config.Config = &ocispec.ImageConfig{Labels: labels}
+		}
+		if created, err := time.Parse(time.RFC3339, metadata.Created); err == nil {
+			config.Created = &created
+		}
+	}
+	if config.Platform == nil && config.Metadata == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode platform config: %w", err)
+	}
+	desc := content.NewDescriptorFromBytes(MediaTypeArtifactConfig, data)
+	if err := store.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to add platform config to store: %w", err)
+	}
+	return &desc, nil
+}
@@ -1,19 +1,17 @@
 package release
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
+	"sync"
 
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/memory"
@@ -21,46 +19,82 @@ import (
 	"oras.land/oras-go/v2/registry/remote/auth"
 	"oras.land/oras-go/v2/registry/remote/retry"
 
+	"github.com/delivery-station/porter/internal/progress"
+	"github.com/delivery-station/porter/internal/spans"
+	"github.com/delivery-station/porter/pkg/reference"
 	"github.com/opencontainers/go-digest"
 	"github.com/opencontainers/image-spec/specs-go"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
-	"gopkg.in/yaml.v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Manifest represents the ds.manifest.yaml structure
 type Manifest struct {
 	ArtifactType string            `yaml:"artifact-type"`
 	Annotations  map[string]string `yaml:"annotations"`
-	Manifests    []ManifestEntry   `yaml:"manifests"`
+	// Compression selects how directory entries are archived: gzip (default) or zstd.
+	Compression string          `yaml:"compression,omitempty"`
+	Manifests   []ManifestEntry `yaml:"manifests"`
+	SBOMs       []string        `yaml:"sboms,omitempty"`
+	// Metadata is packed into the config of every platform manifest.
+	Metadata *ArtifactMetadata `yaml:"metadata,omitempty"`
 }
 
 // ManifestEntry represents a platform entry in the manifest
 type ManifestEntry struct {
-	Platform  string `yaml:"platform"`
-	MediaType string `yaml:"mediaType"`
-	Path      string `yaml:"path"`
+	Platform  string `yaml:"platform" json:"platform"`
+	MediaType string `yaml:"mediaType,omitempty" json:"mediaType,omitempty"`
+	Path      string `yaml:"path" json:"path"`
+	// Annotations are set on the platform manifest and its layer, for metadata
+	// that differs between platforms such as a minimum OS version.
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+	// Config is a JSON file packed as the manifest config instead of the empty
+	// config, such as the plugin entrypoints DS reads, with ConfigMediaType or
+	// MediaTypeArtifactConfig.
+	Config          string `yaml:"config,omitempty" json:"config,omitempty"`
+	ConfigMediaType string `yaml:"configMediaType,omitempty" json:"configMediaType,omitempty"`
+	// Title is the file name the layer is exported as, set as its
+	// org.opencontainers.image.title annotation. Empty uses the name of the file,
+	// or of the directory with its archive extension.
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
 }
 
 // Delivery Station media types for general artifacts.
 const (
-	MediaTypeArtifactBinary  = "application/vnd.delivery-station.artifact.v1+binary"
-	MediaTypeArtifactArchive = "application/vnd.delivery-station.artifact.v1+archive.tar+gzip"
-	MediaTypeArtifactIndex   = "application/vnd.delivery-station.artifact.index.v1+json"
+	MediaTypeArtifactBinary      = "application/vnd.delivery-station.artifact.v1+binary"
+	MediaTypeArtifactArchive     = "application/vnd.delivery-station.artifact.v1+archive.tar+gzip"
+	MediaTypeArtifactArchiveZstd = "application/vnd.delivery-station.artifact.v1+archive.tar+zstd"
+	MediaTypeArtifactIndex       = "application/vnd.delivery-station.artifact.index.v1+json"
+	MediaTypeArtifactConfig      = "application/vnd.delivery-station.artifact.config.v1+json"
 )
 
-// LoadManifest reads and parses the manifest file
+// IsBinaryMediaType reports whether a layer media type or artifact type denotes an
+// executable: the Delivery Station binary type, any "+binary" type, or a common
+// executable format.
+func IsBinaryMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch mediaType {
+	case "":
+		return false
+	case MediaTypeArtifactBinary,
+		"application/x-executable",
+		"application/x-elf",
+		"application/x-mach-binary",
+		"application/vnd.microsoft.portable-executable":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+binary")
+}
+
+// LoadManifest reads and strictly parses the manifest file. Schema violations are
+// returned as a *ManifestError listing each with its line.
 func LoadManifest(path string) (*Manifest, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-
-	var manifest Manifest
-	if err := yaml.Unmarshal(data, &manifest); err != nil {
-		return nil, err
-	}
-
-	return &manifest, nil
+	return parseManifest(data, path)
 }
 
 // ParsePlatform parses a platform string (os/arch/variant)
@@ -105,12 +139,42 @@ type BuildConfig struct {
 
 // ReleaseConfig contains configuration for OCI registry push
 type ReleaseConfig struct {
-	Reference    string
-	Username     string
-	Password     string
-	TagLatest    bool
+	Reference string
+	Username  string
+	Password  string
+	TagLatest bool
+	// Tags are further tags the index is given once it is uploaded, by tagging it
+	// in the registry rather than pushing it again.
+	Tags         []string
 	ManifestPath string
 	Insecure     bool
+	Sign         SignConfig
+	SBOMs        []string
+	// Compression selects how directories are archived: gzip (default) or zstd.
+	// Entries whose media type names a compression use that instead.
+	Compression string
+	// Concurrency bounds how many blobs are uploaded in parallel; zero uses the ORAS default.
+	Concurrency int
+	// Progress, when set, receives byte-level progress of every upload.
+	Progress *progress.Tracker
+	// HTTPClient carries registry traffic, e.g. through a proxy; nil uses the ORAS
+	// retrying default client.
+	HTTPClient *http.Client
+	// Staging is the workspace directories are archived into before upload; nil
+	// archives into the system temp directory.
+	Staging *Staging
+	// ChunkSize, when positive, uploads blobs of at least that many bytes in
+	// chunks of that size, one PATCH request each.
+	ChunkSize int64
+	// Metadata is packed into the config of every platform manifest, over the
+	// metadata of the manifest Push loads.
+	Metadata *ArtifactMetadata
+	// UploadSessionsDir, when set, records the chunked upload sessions of blobs
+	// being pushed, so a push that fails part way through a blob resumes its
+	// session on the next run where the registry still holds it.
+	UploadSessionsDir string
+	// TracerProvider records the spans of the push; nil uses the global provider.
+	TracerProvider trace.TracerProvider
 }
 
 // Release orchestrates building and publishing multi-arch artifacts.
@@ -125,23 +189,42 @@ type Pusher struct {
 	client *auth.Client
 }
 
+// copyOptions returns the ORAS copy options used for every upload.
+func (p *Pusher) copyOptions() oras.CopyOptions {
+	opts := oras.CopyOptions{}
+	opts.Concurrency = p.config.Concurrency
+	p.config.Progress.Options(&opts.CopyGraphOptions)
+	return opts
+}
+
+// target wraps repo so uploads are reported to the configured progress tracker.
+func (p *Pusher) target(repo *remote.Repository) oras.Target {
+	if p.config.ChunkSize > 0 {
+		return p.config.Progress.Target(&chunkedTarget{Target: repo, repo: repo, chunkSize: p.config.ChunkSize, sessions: uploadSessions{dir: p.config.UploadSessionsDir}})
+	}
+	return p.config.Progress.Target(repo)
+}
+
 // NewPusher creates a new Pusher
 func NewPusher(config ReleaseConfig) (*Pusher, error) {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = retry.DefaultClient
+	}
 	client := &auth.Client{
-		Client: retry.DefaultClient,
+		Client: httpClient,
 		Cache:  auth.DefaultCache,
 	}
 
 	if config.Username != "" && config.Password != "" {
-		// Parse registry from reference
-		// Assuming reference is registry/repo[:tag]
-		parts := strings.SplitN(config.Reference, "/", 2)
-		if len(parts) > 0 {
-			client.Credential = auth.StaticCredential(parts[0], auth.Credential{
-				Username: config.Username,
-				Password: config.Password,
-			})
+		ref, err := reference.Parse(config.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %q: %w", reference.Redact(config.Reference), err)
 		}
+		client.Credential = auth.StaticCredential(ref.Registry, auth.Credential{
+			Username: config.Username,
+			Password: config.Password,
+		})
 	}
 
 	return &Pusher{
@@ -164,7 +247,9 @@ func writeProgressLine(progress io.Writer, format string, args ...interface{}) e
 }
 
 // Push performs the multi-arch push
-func (p *Pusher) Push(ctx context.Context, progress io.Writer) error {
+func (p *Pusher) Push(ctx context.Context, progress io.Writer) (err error) {
+	ctx, span := p.tracer().Start(ctx, "release.push", trace.WithAttributes(attribute.String("oci.reference", reference.Redact(p.config.Reference))))
+	defer func() { spans.End(span, err) }()
 	if err := writeProgressLine(progress, "=== Porter Plugin Multi-Arch Push ==="); err != nil {
 		return err
 	}
@@ -182,6 +267,13 @@ func (p *Pusher) Push(ctx context.Context, progress io.Writer) error {
 	}
 
 	manifestDir := filepath.Dir(p.config.ManifestPath)
+	if p.config.Compression == "" {
+		p.config.Compression = manifest.Compression
+	}
+	p.config.Metadata = MergeMetadata(manifest.Metadata, p.config.Metadata)
+	if err := p.config.Metadata.Validate(); err != nil {
+		return err
+	}
 
 	// Push artifacts
 	entries := make(map[Platform]ManifestEntry)
@@ -199,9 +291,8 @@ func (p *Pusher) Push(ctx context.Context, progress io.Writer) error {
 		if !filepath.IsAbs(resolvedEntry.Path) {
 			resolvedEntry.Path = filepath.Join(manifestDir, resolvedEntry.Path)
 		}
-
-		if strings.TrimSpace(resolvedEntry.MediaType) == "" {
-			resolvedEntry.MediaType = MediaTypeArtifactBinary
+		if resolvedEntry.Config != "" && !filepath.IsAbs(resolvedEntry.Config) {
+			resolvedEntry.Config = filepath.Join(manifestDir, resolvedEntry.Config)
 		}
 
 		entries[platform] = resolvedEntry
@@ -224,6 +315,19 @@ func (p *Pusher) Push(ctx context.Context, progress io.Writer) error {
 		return fmt.Errorf("push index failed: %w", err)
 	}
 
+	if _, err := p.AttachIndexSBOMs(ctx, manifest, manifestDir, progress); err != nil {
+		return err
+	}
+
+	if p.config.Sign.Enabled {
+		if err := writeProgressLine(progress, "Signing manifests..."); err != nil {
+			return err
+		}
+		if err := p.Sign(ctx, descriptors, progress); err != nil {
+			return fmt.Errorf("signing failed: %w", err)
+		}
+	}
+
 	if err := writeProgressLine(progress, ""); err != nil {
 		return err
 	}
@@ -244,7 +348,7 @@ func (p *Pusher) PushAll(ctx context.Context, entries map[Platform]ManifestEntry
 			return nil, err
 		}
 
-		desc, err := p.PushBinary(ctx, platform, entry)
+		desc, err := p.pushPlatform(ctx, platform, entry, progress)
 		if err != nil {
 			return nil, fmt.Errorf("failed to push %s/%s: %w", platform.OS, platform.Arch, err)
 		}
@@ -263,6 +367,13 @@ func (p *Pusher) PushAll(ctx context.Context, entries map[Platform]ManifestEntry
 
 // PushBinary pushes a single platform binary to the registry
 func (p *Pusher) PushBinary(ctx context.Context, platform Platform, entry ManifestEntry) (ocispec.Descriptor, error) {
+	return p.pushBinary(ctx, platform, entry, nil)
+}
+
+// pushBinary pushes entry, writing a line to progress for every blob the registry
+// already has. Those are found with a HEAD request and not uploaded again, so
+// re-running a release after a partial failure only uploads what is missing.
+func (p *Pusher) pushBinary(ctx context.Context, platform Platform, entry ManifestEntry, progress io.Writer) (ocispec.Descriptor, error) {
 	binaryPath := entry.Path
 
 	info, err := os.Stat(binaryPath)
@@ -271,15 +382,26 @@ func (p *Pusher) PushBinary(ctx context.Context, platform Platform, entry Manife
 	}
 
 	var cleanup func()
+	var compression string
 	if info.IsDir() {
-		archivePath, archiveCleanup, archiveErr := archiveDirectory(binaryPath)
-		if archiveErr != nil {
-			return ocispec.Descriptor{}, fmt.Errorf("failed to archive directory %s: %w", binaryPath, archiveErr)
-		}
-		cleanup = archiveCleanup
-		binaryPath = archivePath
 		if strings.TrimSpace(entry.MediaType) == "" {
-			entry.MediaType = MediaTypeArtifactArchive
+			mediaType, err := ArchiveMediaType(p.config.Compression)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			entry.MediaType = mediaType
+		}
+		compression = ArchiveCompression(entry.MediaType)
+		if compression == "" {
+			compression = CompressionGzip
+		}
+		if !p.config.Staging.Streaming() {
+			archivePath, archiveCleanup, archiveErr := p.config.Staging.ArchiveDirectory(binaryPath, compression)
+			if archiveErr != nil {
+				return ocispec.Descriptor{}, fmt.Errorf("failed to archive directory %s: %w", binaryPath, archiveErr)
+			}
+			cleanup = archiveCleanup
+			binaryPath = archivePath
 		}
 	}
 	if cleanup != nil {
@@ -294,86 +416,165 @@ func (p *Pusher) PushBinary(ctx context.Context, platform Platform, entry Manife
 	if strings.TrimSpace(layerMediaType) == "" {
 		layerMediaType = MediaTypeArtifactBinary
 	}
-	binaryDesc, err := store.AddFile(binaryPath, layerMediaType)
+	var binaryDesc ocispec.Descriptor
+	if info.IsDir() && p.config.Staging.Streaming() {
+		binaryDesc, err = store.AddDirectoryArchive(binaryPath, layerMediaType, compression, p.config.Staging)
+	} else {
+		binaryDesc, err = store.AddFile(binaryPath, layerMediaType)
+	}
 	if err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("failed to add binary to store: %w", err)
 	}
+	if info.IsDir() {
+		// Name the archive after its directory rather than the staged file.
+		binaryDesc.Annotations[ocispec.AnnotationTitle] = filepath.Base(filepath.Clean(entry.Path)) + ArchiveExtension(compression)
+	}
+	for key, value := range entry.Annotations {
+		binaryDesc.Annotations[key] = value
+	}
+	if title := strings.TrimSpace(entry.Title); title != "" {
+		binaryDesc.Annotations[ocispec.AnnotationTitle] = title
+	}
 
 	// Create artifact manifest
 	artifactType := layerMediaType
 	opts := oras.PackManifestOptions{
 		Layers: []ocispec.Descriptor{binaryDesc},
 	}
+	if strings.TrimSpace(entry.Config) != "" {
+		configDesc, err := addConfig(store, entry)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		opts.ConfigDescriptor = &configDesc
+	} else if opts.ConfigDescriptor, err = addPlatformConfig(ctx, store, platform, p.config.Metadata); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	opts.ManifestAnnotations = make(map[string]string, len(entry.Annotations)+1)
+	for key, value := range entry.Annotations {
+		opts.ManifestAnnotations[key] = value
+	}
+	if _, ok := opts.ManifestAnnotations[ocispec.AnnotationCreated]; !ok {
+		created, err := manifestCreated(entry.Path, p.config.Metadata)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to date manifest of %s: %w", entry.Path, err)
+		}
+		opts.ManifestAnnotations[ocispec.AnnotationCreated] = created
+	}
 
 	manifestDesc, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, artifactType, opts)
 	if err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("failed to pack manifest: %w", err)
 	}
 
-	// Add annotations to manifest
-	annotations := map[string]string{
-		ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+	// Push to remote registry by digest
+	// We use the base reference (repo) and push the manifest by digest
+	repo, _, err := p.repository()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	// A manifest the registry already has was pushed whole before.
+	if exists, err := repo.Exists(ctx, manifestDesc); err == nil && exists {
+		if err := writeProgressLine(progress, "  %s already exists", manifestDesc.Digest); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return manifestDesc, nil
+	}
+
+	// Push manifest and blobs. Copy checks each blob with the registry first and
+	// skips those it already has.
+	copyOpts := p.copyOptions()
+	var progressMu sync.Mutex
+	onCopySkipped := copyOpts.OnCopySkipped
+	copyOpts.OnCopySkipped = func(ctx context.Context, desc ocispec.Descriptor) error {
+		progressMu.Lock()
+		err := writeProgressLine(progress, "  %s already exists", desc.Digest)
+		progressMu.Unlock()
+		if err != nil {
+			return err
+		}
+		if onCopySkipped != nil {
+			return onCopySkipped(ctx, desc)
+		}
+		return nil
+	}
+	if _, err := oras.Copy(ctx, store, manifestDesc.Digest.String(), p.target(repo), manifestDesc.Digest.String(), copyOpts); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to copy to registry: %w", err)
+	}
+
+	return manifestDesc, nil
+}
+
+// addConfig adds the config file of entry to store, checking it is JSON.
+func addConfig(store *FileStore, entry ManifestEntry) (ocispec.Descriptor, error) {
+	data, err := os.ReadFile(entry.Config)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to read config %s: %w", entry.Config, err)
 	}
-	if strings.TrimSpace(platform.OS) != "" {
-		annotations["os"] = platform.OS
+	if !json.Valid(data) {
+		return ocispec.Descriptor{}, fmt.Errorf("config %s is not valid JSON", entry.Config)
 	}
-	if strings.TrimSpace(platform.Arch) != "" {
-		annotations["architecture"] = platform.Arch
+	mediaType := strings.TrimSpace(entry.ConfigMediaType)
+	if mediaType == "" {
+		mediaType = MediaTypeArtifactConfig
 	}
-	if strings.TrimSpace(platform.Variant) != "" {
-		annotations["variant"] = platform.Variant
+	desc, err := store.AddFile(entry.Config, mediaType)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to add config to store: %w", err)
 	}
-	manifestDesc.Annotations = annotations
+	// A config is not a file to extract, so it carries no title.
+	desc.Annotations = nil
+	return desc, nil
+}
 
-	// Push to remote registry by digest
-	// We use the base reference (repo) and push the manifest by digest
-	baseRef := p.config.Reference
-	if !strings.Contains(baseRef, ":") {
-		baseRef += ":latest"
+// repository returns the remote repository targeted by the configured reference
+// together with the tag to publish (defaulting to "latest").
+func (p *Pusher) repository() (*remote.Repository, string, error) {
+	ref, err := p.reference()
+	if err != nil {
+		return nil, "", err
 	}
-	parts := strings.Split(baseRef, ":")
-	baseTag := parts[len(parts)-1]
-	repoName := strings.TrimSuffix(baseRef, ":"+baseTag)
 
-	repo, err := remote.NewRepository(repoName)
+	repo, err := remote.NewRepository(ref.Name())
 	if err != nil {
-		return ocispec.Descriptor{}, fmt.Errorf("failed to create repository: %w", err)
+		return nil, "", fmt.Errorf("failed to create repository: %w", err)
 	}
 	repo.Client = p.client
 	repo.PlainHTTP = p.config.Insecure
+	return repo, ref.Tag, nil
+}
 
-	// Push manifest and blobs
-	if _, err := oras.Copy(ctx, store, manifestDesc.Digest.String(), repo, manifestDesc.Digest.String(), oras.CopyOptions{}); err != nil {
-		return ocispec.Descriptor{}, fmt.Errorf("failed to copy to registry: %w", err)
+// reference parses the configured reference, ignoring any digest and defaulting
+// the tag to "latest".
+func (p *Pusher) reference() (reference.Reference, error) {
+	ref, err := reference.Parse(p.config.Reference)
+	if err != nil {
+		return reference.Reference{}, err
 	}
-
-	return manifestDesc, nil
+	ref.Digest = ""
+	return ref.WithDefaultTag(), nil
 }
 
 // PushIndex creates and pushes the multi-arch manifest index
-func (p *Pusher) PushIndex(ctx context.Context, descriptors map[Platform]ocispec.Descriptor, manifest *Manifest) (string, error) {
+func (p *Pusher) PushIndex(ctx context.Context, descriptors map[Platform]ocispec.Descriptor, manifest *Manifest) (_ string, err error) {
+	ctx, span := p.tracer().Start(ctx, "release.push_index", trace.WithAttributes(attribute.String("oci.reference", reference.Redact(p.config.Reference))))
+	defer func() { spans.End(span, err) }()
 	// Create memory store for index
 	store := memory.New()
 
 	var layers []ocispec.Descriptor
 
 	// Base reference
-	baseRef := p.config.Reference
-	if !strings.Contains(baseRef, ":") {
-		baseRef += ":latest"
+	repo, baseTag, err := p.repository()
+	if err != nil {
+		return "", err
 	}
-
-	// Extract tag
-	parts := strings.Split(baseRef, ":")
-	baseTag := parts[len(parts)-1]
-	repoName := strings.TrimSuffix(baseRef, ":"+baseTag)
-
-	repo, err := remote.NewRepository(repoName)
+	ref, err := p.reference()
 	if err != nil {
-		return "", fmt.Errorf("failed to create repository: %w", err)
+		return "", err
 	}
-	repo.Client = p.client
-	repo.PlainHTTP = p.config.Insecure
+	baseRef := ref.String()
 
 	for platform, desc := range descriptors {
 		// Add platform info to descriptor
@@ -438,15 +639,26 @@ func (p *Pusher) PushIndex(ctx context.Context, descriptors map[Platform]ocispec
 	}
 
 	// Push index
-	_, err = oras.Copy(ctx, store, tag, repo, tag, oras.CopyOptions{})
+	_, err = oras.Copy(ctx, store, tag, p.target(repo), tag, p.copyOptions())
 	if err != nil {
 		return "", fmt.Errorf("failed to push index: %w", err)
 	}
 
-	if p.config.TagLatest && tag != "latest" {
+	tagged := map[string]bool{tag: true}
+	if p.config.TagLatest && !tagged["latest"] {
 		if err := repo.Tag(ctx, indexDesc, "latest"); err != nil {
 			return "", fmt.Errorf("failed to tag latest: %w", err)
 		}
+		tagged["latest"] = true
+	}
+	for _, extra := range p.config.Tags {
+		if tagged[extra] {
+			continue
+		}
+		if err := repo.Tag(ctx, indexDesc, extra); err != nil {
+			return "", fmt.Errorf("failed to tag %s: %w", extra, err)
+		}
+		tagged[extra] = true
 	}
 
 	return baseRef, nil
@@ -510,6 +722,11 @@ type FileStore struct {
 type fileEntry struct {
 	path string
 	desc ocispec.Descriptor
+	// archive is set for a directory streamed as an archive compressed with
+	// compression instead of being read from path.
+	archive     string
+	compression string
+	staging     *Staging
 }
 
 // NewFileStore creates a new FileStore
@@ -523,6 +740,9 @@ func NewFileStore() *FileStore {
 // Fetch retrieves content from disk or memory
 func (s *FileStore) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
 	if entry, ok := s.files[target.Digest.String()]; ok {
+		if entry.archive != "" {
+			return streamArchive(entry), nil
+		}
 		if entry.path != "" {
 			return os.Open(entry.path)
 		}
@@ -594,101 +814,46 @@ func (s *FileStore) AddFile(path string, mediaType string) (ocispec.Descriptor,
 	return desc, nil
 }
 
-func archiveDirectory(dir string) (string, func(), error) {
-	info, err := os.Stat(dir)
+// AddDirectoryArchive adds dir to the store as an archive compressed with
+// compression, without writing it to disk: the archive is built each time it is
+// fetched. Its digest comes from staging, which archives dir once more unless it
+// already knows the digest.
+func (s *FileStore) AddDirectoryArchive(dir, mediaType, compression string, staging *Staging) (ocispec.Descriptor, error) {
+	dgst, size, err := staging.ArchiveDigest(dir, compression)
 	if err != nil {
-		return "", nil, err
-	}
-	if !info.IsDir() {
-		return "", nil, fmt.Errorf("path %s is not a directory", dir)
+		return ocispec.Descriptor{}, err
 	}
 
-	archiveFile, err := os.CreateTemp("", "ds-porter-archive-*.tar.gz")
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to create temporary archive: %w", err)
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      size,
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: filepath.Base(filepath.Clean(dir)) + ".tar",
+		},
 	}
-
-	gzipWriter := gzip.NewWriter(archiveFile)
-	tarWriter := tar.NewWriter(gzipWriter)
-
-	dirAbs, err := filepath.Abs(dir)
-	if err != nil {
-		_ = tarWriter.Close()
-		_ = gzipWriter.Close()
-		_ = archiveFile.Close()
-		_ = os.Remove(archiveFile.Name())
-		return "", nil, fmt.Errorf("failed to resolve directory %s: %w", dir, err)
+	s.files[desc.Digest.String()] = fileEntry{
+		desc:        desc,
+		archive:     dir,
+		compression: compression,
+		staging:     staging,
 	}
+	return desc, nil
+}
 
-	walkErr := filepath.WalkDir(dirAbs, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-
-		relPath, relErr := filepath.Rel(dirAbs, path)
-		if relErr != nil {
-			return relErr
-		}
-		if relPath == "." {
-			return nil
-		}
-
-		info, infoErr := d.Info()
-		if infoErr != nil {
-			return infoErr
-		}
-
-		header, headerErr := tar.FileInfoHeader(info, "")
-		if headerErr != nil {
-			return headerErr
-		}
-		header.Name = filepath.ToSlash(relPath)
-
-		if d.Type()&os.ModeSymlink != 0 {
-			target, linkErr := os.Readlink(path)
-			if linkErr != nil {
-				return linkErr
-			}
-			header.Linkname = target
-		}
-
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-
-		if d.IsDir() {
-			return nil
-		}
-
-		file, openErr := os.Open(path)
-		if openErr != nil {
-			return openErr
-		}
-		if _, copyErr := io.Copy(tarWriter, file); copyErr != nil {
-			_ = file.Close()
-			return copyErr
+// streamArchive returns a reader producing the archive of a directory entry as it
+// is written. The stream fails when the archive no longer matches the digest the
+// entry was added with, because the directory changed since.
+func streamArchive(entry fileEntry) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		verifier := entry.desc.Digest.Verifier()
+		err := writeArchive(io.MultiWriter(writer, verifier), entry.archive, entry.compression)
+		if err == nil && !verifier.Verified() {
+			entry.staging.forgetArchiveDigest(entry.archive, entry.compression)
+			err = fmt.Errorf("directory %s changed while it was pushed", entry.archive)
 		}
-		return file.Close()
-	})
-
-	firstErr := walkErr
-	if closeErr := tarWriter.Close(); firstErr == nil {
-		firstErr = closeErr
-	}
-	if gzipErr := gzipWriter.Close(); firstErr == nil {
-		firstErr = gzipErr
-	}
-	if closeErr := archiveFile.Close(); firstErr == nil {
-		firstErr = closeErr
-	}
-
-	if firstErr != nil {
-		_ = os.Remove(archiveFile.Name())
-		return "", nil, fmt.Errorf("failed to archive directory %s: %w", dir, firstErr)
-	}
-
-	archivePath := archiveFile.Name()
-	return archivePath, func() {
-		_ = os.Remove(archivePath)
-	}, nil
+		_ = writer.CloseWithError(err)
+	}()
+	return reader
 }
@@ -0,0 +1,44 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPusherRepositoryHandlesPortsAndDigests(t *testing.T) {
+	tests := []struct {
+		ref  string
+		repo string
+		tag  string
+	}{
+		{ref: "localhost:5000/tool", repo: "localhost:5000/tool", tag: "latest"},
+		{ref: "localhost:5000/team/tool:1.0", repo: "localhost:5000/team/tool", tag: "1.0"},
+		{ref: "localhost/tool:dev", repo: "localhost/tool", tag: "dev"},
+		{ref: "ghcr.io/team/tool:1.0@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", repo: "ghcr.io/team/tool", tag: "1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			pusher, err := NewPusher(ReleaseConfig{Reference: tt.ref, Username: "user", Password: "pass"})
+			require.NoError(t, err)
+			repo, tag, err := pusher.repository()
+			require.NoError(t, err)
+			assert.Equal(t, tt.repo, repo.Reference.Registry+"/"+repo.Reference.Repository)
+			assert.Equal(t, tt.tag, tag)
+		})
+	}
+
+	_, err := NewPusher(ReleaseConfig{Reference: "ghcr.io/Tool", Username: "user", Password: "pass"})
+	assert.Error(t, err)
+}
+
+func TestIsBinaryMediaType(t *testing.T) {
+	for _, mediaType := range []string{MediaTypeArtifactBinary, "application/vnd.acme.tool.v1+binary", "application/x-executable"} {
+		assert.True(t, IsBinaryMediaType(mediaType), mediaType)
+	}
+	for _, mediaType := range []string{"", MediaTypeArtifactArchive, "application/octet-stream", "text/plain"} {
+		assert.False(t, IsBinaryMediaType(mediaType), mediaType)
+	}
+}
@@ -0,0 +1,183 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/internal/spans"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"oras.land/oras-go/v2"
+)
+
+// SBOM artifact types attached as referrers.
+const (
+	ArtifactTypeCycloneDXJSON = "application/vnd.cyclonedx+json"
+	ArtifactTypeCycloneDXXML  = "application/vnd.cyclonedx+xml"
+	ArtifactTypeSPDXJSON      = "application/spdx+json"
+	ArtifactTypeSPDXTagValue  = "text/spdx"
+)
+
+// PushReferrer uploads files as an artifact whose manifest points at subject via the
+// OCI 1.1 subject field. Registries without the referrers API are handled by ORAS,
+// which maintains the sha256-<digest> referrers tag instead.
+func (p *Pusher) PushReferrer(ctx context.Context, subject ocispec.Descriptor, artifactType string, files []string, annotations map[string]string) (_ ocispec.Descriptor, err error) {
+	ctx, span := p.tracer().Start(ctx, "release.push_referrer", trace.WithAttributes(attribute.String("oci.artifact_type", artifactType)))
+	defer func() { spans.End(span, err) }()
+	if strings.TrimSpace(artifactType) == "" {
+		return ocispec.Descriptor{}, fmt.Errorf("artifact type required")
+	}
+	if len(files) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("at least one file required")
+	}
+
+	store := NewFileStore()
+	layers := make([]ocispec.Descriptor, 0, len(files))
+	for _, file := range files {
+		desc, err := store.AddFile(file, artifactType)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to add %s: %w", file, err)
+		}
+		layers = append(layers, desc)
+	}
+
+	manifestAnnotations := map[string]string{
+		ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range annotations {
+		manifestAnnotations[k] = v
+	}
+
+	manifestDesc, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, artifactType, oras.PackManifestOptions{
+		Subject:             &subject,
+		Layers:              layers,
+		ManifestAnnotations: manifestAnnotations,
+	})
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to pack referrer manifest: %w", err)
+	}
+
+	repo, _, err := p.repository()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if _, err := oras.Copy(ctx, store, manifestDesc.Digest.String(), p.target(repo), manifestDesc.Digest.String(), p.copyOptions()); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push referrer: %w", err)
+	}
+
+	manifestDesc.ArtifactType = artifactType
+	return manifestDesc, nil
+}
+
+// AttachSBOMs uploads each SBOM document as a referrer of subject.
+func (p *Pusher) AttachSBOMs(ctx context.Context, subject ocispec.Descriptor, paths []string) ([]ocispec.Descriptor, error) {
+	attached := make([]ocispec.Descriptor, 0, len(paths))
+	for _, path := range paths {
+		artifactType, err := DetectSBOMType(path)
+		if err != nil {
+			return nil, err
+		}
+
+		desc, err := p.PushReferrer(ctx, subject, artifactType, []string{path}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach SBOM %s: %w", path, err)
+		}
+		attached = append(attached, desc)
+	}
+	return attached, nil
+}
+
+// AttachIndexSBOMs attaches the SBOMs of a push as referrers of its index: those
+// of the config and those manifest lists, which are relative to manifestDir.
+// It attaches nothing when the push has no SBOMs.
+func (p *Pusher) AttachIndexSBOMs(ctx context.Context, manifest *Manifest, manifestDir string, progress io.Writer) ([]ocispec.Descriptor, error) {
+	sboms := append([]string(nil), p.config.SBOMs...)
+	for _, sbom := range manifest.SBOMs {
+		if !filepath.IsAbs(sbom) {
+			sbom = filepath.Join(manifestDir, sbom)
+		}
+		sboms = append(sboms, sbom)
+	}
+	if len(sboms) == 0 {
+		return nil, nil
+	}
+	if err := writeProgressLine(progress, "Attaching SBOMs..."); err != nil {
+		return nil, err
+	}
+	indexDesc, err := p.ResolveIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attached, err := p.AttachSBOMs(ctx, indexDesc, sboms)
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range attached {
+		if err := writeProgressLine(progress, "✓ Attached %s → %s", desc.ArtifactType, desc.Digest); err != nil {
+			return nil, err
+		}
+	}
+	return attached, nil
+}
+
+// DetectSBOMType inspects an SBOM document and returns its artifact type.
+// CycloneDX (JSON/XML) and SPDX (JSON/tag-value) documents are recognised.
+func DetectSBOMType(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read SBOM %s: %w", path, err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var doc struct {
+			BOMFormat   string `json:"bomFormat"`
+			SPDXVersion string `json:"spdxVersion"`
+		}
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return "", fmt.Errorf("failed to parse SBOM %s: %w", path, err)
+		}
+		if strings.EqualFold(doc.BOMFormat, "CycloneDX") {
+			return ArtifactTypeCycloneDXJSON, nil
+		}
+		if doc.SPDXVersion != "" {
+			return ArtifactTypeSPDXJSON, nil
+		}
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var doc struct {
+			XMLName xml.Name
+		}
+		if err := xml.Unmarshal(trimmed, &doc); err == nil && doc.XMLName.Local == "bom" && strings.Contains(doc.XMLName.Space, "cyclonedx") {
+			return ArtifactTypeCycloneDXXML, nil
+		}
+	case bytes.HasPrefix(trimmed, []byte("SPDXVersion:")):
+		return ArtifactTypeSPDXTagValue, nil
+	}
+
+	return "", fmt.Errorf("unrecognised SBOM format in %s (expected CycloneDX or SPDX)", filepath.Base(path))
+}
+
+// ResolveIndex resolves the index published under the configured tag.
+func (p *Pusher) ResolveIndex(ctx context.Context) (ocispec.Descriptor, error) {
+	repo, tag, err := p.repository()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to resolve index %s: %w", tag, err)
+	}
+	return desc, nil
+}
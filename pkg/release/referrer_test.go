@@ -0,0 +1,41 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSBOMType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "cyclonedx json", content: `{"bomFormat":"CycloneDX","specVersion":"1.5"}`, want: ArtifactTypeCycloneDXJSON},
+		{name: "spdx json", content: `{"spdxVersion":"SPDX-2.3","SPDXID":"SPDXRef-DOCUMENT"}`, want: ArtifactTypeSPDXJSON},
+		{name: "cyclonedx xml", content: `<?xml version="1.0"?><bom xmlns="http://cyclonedx.org/schema/bom/1.5" version="1"></bom>`, want: ArtifactTypeCycloneDXXML},
+		{name: "spdx tag-value", content: "SPDXVersion: SPDX-2.3\nDataLicense: CC0-1.0\n", want: ArtifactTypeSPDXTagValue},
+		{name: "unknown json", content: `{"name":"not-an-sbom"}`, wantErr: true},
+		{name: "plain text", content: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sbom")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			got, err := DetectSBOMType(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2/registry"
+)
+
+// SourceDateEpochEnv names the reproducible-builds variable holding the build
+// time in seconds since the epoch; platform manifests are dated by it when set.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// manifestCreated returns the creation time packed into the platform manifest of
+// the content at path: the metadata's build date, SOURCE_DATE_EPOCH, or else the
+// newest modification time of the content. Unlike the time of the push, it is
+// the same when a release is pushed again, so the manifests a failed release
+// already pushed have the same digest and are found in the registry.
+func manifestCreated(path string, metadata *ArtifactMetadata) (string, error) {
+	if metadata != nil && metadata.Created != "" {
+		return metadata.Created, nil
+	}
+	if epoch := strings.TrimSpace(os.Getenv(SourceDateEpochEnv)); epoch != "" {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+		}
+	}
+	var newest time.Time
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newest.UTC().Truncate(time.Second).Format(time.RFC3339), nil
+}
+
+// uploadSessions records the chunked upload session of each blob being pushed,
+// so a push that dies part way through a blob continues its session on the next
+// run instead of uploading the blob from the start. An empty dir records nothing.
+// Recording is best effort: a session that cannot be saved is only not resumed.
+type uploadSessions struct {
+	dir string
+}
+
+type uploadSession struct {
+	Repository string        `json:"repository"`
+	Digest     digest.Digest `json:"digest"`
+	Location   string        `json:"location"`
+}
+
+func (s uploadSessions) path(ref registry.Reference, dgst digest.Digest) string {
+	sum := sha256.Sum256([]byte(ref.Host() + "/" + ref.Repository + "@" + dgst.String()))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the location of the session recorded for dgst in ref, or nil
+// when there is none.
+func (s uploadSessions) load(ref registry.Reference, dgst digest.Digest) *url.URL {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path(ref, dgst))
+	if err != nil {
+		return nil
+	}
+	var session uploadSession
+	if err := json.Unmarshal(data, &session); err != nil || session.Digest != dgst {
+		return nil
+	}
+	location, err := url.Parse(session.Location)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// save records location as the session of dgst in ref.
+func (s uploadSessions) save(ref registry.Reference, dgst digest.Digest, location *url.URL) {
+	if s.dir == "" {
+		return
+	}
+	data, err := json.Marshal(uploadSession{Repository: ref.Host() + "/" + ref.Repository, Digest: dgst, Location: location.String()})
+	if err != nil || os.MkdirAll(s.dir, 0700) != nil {
+		return
+	}
+	// The location may carry upload state that grants access to the session.
+	_ = os.WriteFile(s.path(ref, dgst), data, 0600)
+}
+
+// remove forgets the session of dgst in ref.
+func (s uploadSessions) remove(ref registry.Reference, dgst digest.Digest) {
+	if s.dir == "" {
+		return
+	}
+	_ = os.Remove(s.path(ref, dgst))
+}
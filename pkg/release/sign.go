@@ -0,0 +1,549 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delivery-station/porter/internal/spans"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// Cosign signature media types and annotations.
+const (
+	MediaTypeCosignSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	AnnotationCosignSignature    = "dev.cosignproject.cosign/signature"
+	AnnotationCosignCertificate  = "dev.sigstore.cosign/certificate"
+	AnnotationCosignChain        = "dev.sigstore.cosign/chain"
+	AnnotationCosignBundle       = "dev.sigstore.cosign/bundle"
+)
+
+// Public Sigstore endpoints used for keyless signing.
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+// SignConfig controls cosign-compatible signing of pushed manifests.
+// When KeyPath is empty, keyless signing is used: an ambient OIDC token is exchanged
+// for a short-lived Fulcio certificate and the signature is recorded in Rekor.
+type SignConfig struct {
+	Enabled       bool
+	KeyPath       string
+	KeyPassword   string
+	FulcioURL     string
+	RekorURL      string
+	IdentityToken string
+}
+
+// Sign produces a cosign signature for every platform manifest and for the index
+// published under the configured tag.
+func (p *Pusher) Sign(ctx context.Context, descriptors map[Platform]ocispec.Descriptor, progress io.Writer) (err error) {
+	ctx, span := p.tracer().Start(ctx, "release.sign")
+	defer func() { spans.End(span, err) }()
+	repo, _, err := p.repository()
+	if err != nil {
+		return err
+	}
+
+	indexDesc, err := p.ResolveIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	signer, err := newSigner(ctx, p.config.Sign)
+	if err != nil {
+		return err
+	}
+
+	platforms := make([]Platform, 0, len(descriptors))
+	for platform := range descriptors {
+		platforms = append(platforms, platform)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].FormatString() < platforms[j].FormatString()
+	})
+
+	targets := make([]ocispec.Descriptor, 0, len(platforms)+1)
+	for _, platform := range platforms {
+		targets = append(targets, descriptors[platform])
+	}
+	targets = append(targets, indexDesc)
+
+	dockerReference := repo.Reference.Registry + "/" + repo.Reference.Repository
+	for _, target := range targets {
+		sigTag, err := p.signManifest(ctx, repo, signer, dockerReference, target)
+		if err != nil {
+			return fmt.Errorf("failed to sign %s: %w", target.Digest, err)
+		}
+		if err := writeProgressLine(progress, "✓ Signed %s → %s", target.Digest, sigTag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Pusher) signManifest(ctx context.Context, repo *remote.Repository, s *signer, dockerReference string, target ocispec.Descriptor) (string, error) {
+	payload, err := simpleSigningPayload(dockerReference, target.Digest)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := s.sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	annotations := map[string]string{
+		AnnotationCosignSignature: base64.StdEncoding.EncodeToString(signature),
+	}
+	if len(s.certPEM) > 0 {
+		annotations[AnnotationCosignCertificate] = string(s.certPEM)
+		annotations[AnnotationCosignChain] = string(s.chainPEM)
+
+		bundle, err := s.uploadTransparencyLog(ctx, payload, signature)
+		if err != nil {
+			return "", err
+		}
+		annotations[AnnotationCosignBundle] = string(bundle)
+	}
+
+	store := memory.New()
+	layerDesc := content.NewDescriptorFromBytes(MediaTypeCosignSimpleSigning, payload)
+	if err := store.Push(ctx, layerDesc, bytes.NewReader(payload)); err != nil {
+		return "", fmt.Errorf("failed to stage signature payload: %w", err)
+	}
+	layerDesc.Annotations = annotations
+
+	configBytes, err := json.Marshal(ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{layerDesc.Digest}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal signature config: %w", err)
+	}
+	configDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageConfig, configBytes)
+	if err := store.Push(ctx, configDesc, bytes.NewReader(configBytes)); err != nil {
+		return "", fmt.Errorf("failed to stage signature config: %w", err)
+	}
+
+	manifestBytes, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal signature manifest: %w", err)
+	}
+	manifestDesc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestBytes)
+	if err := store.Push(ctx, manifestDesc, bytes.NewReader(manifestBytes)); err != nil {
+		return "", fmt.Errorf("failed to stage signature manifest: %w", err)
+	}
+
+	sigTag := SignatureTag(target.Digest)
+	if err := store.Tag(ctx, manifestDesc, sigTag); err != nil {
+		return "", fmt.Errorf("failed to tag signature manifest: %w", err)
+	}
+	if _, err := oras.Copy(ctx, store, sigTag, p.target(repo), sigTag, p.copyOptions()); err != nil {
+		return "", fmt.Errorf("failed to push signature: %w", err)
+	}
+
+	return sigTag, nil
+}
+
+// SignatureTag returns the cosign tag under which the signature for dgst is stored.
+func SignatureTag(dgst digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Encoded())
+}
+
+func simpleSigningPayload(dockerReference string, dgst digest.Digest) ([]byte, error) {
+	payload := map[string]interface{}{
+		"critical": map[string]interface{}{
+			"identity": map[string]string{"docker-reference": dockerReference},
+			"image":    map[string]string{"docker-manifest-digest": dgst.String()},
+			"type":     "cosign container image signature",
+		},
+		"optional": nil,
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing payload: %w", err)
+	}
+	return data, nil
+}
+
+type signer struct {
+	key        crypto.Signer
+	certPEM    []byte
+	chainPEM   []byte
+	rekorURL   string
+	httpClient *http.Client
+}
+
+func newSigner(ctx context.Context, cfg SignConfig) (*signer, error) {
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+
+	if strings.TrimSpace(cfg.KeyPath) != "" {
+		key, err := loadSigningKey(cfg.KeyPath, cfg.KeyPassword)
+		if err != nil {
+			return nil, err
+		}
+		return &signer{key: key, httpClient: httpClient}, nil
+	}
+
+	token := strings.TrimSpace(cfg.IdentityToken)
+	if token == "" {
+		ambient, err := ambientIdentityToken(ctx, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		token = ambient
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral signing key: %w", err)
+	}
+
+	s := &signer{key: key, rekorURL: cfg.RekorURL, httpClient: httpClient}
+	if s.rekorURL == "" {
+		s.rekorURL = DefaultRekorURL
+	}
+	fulcioURL := cfg.FulcioURL
+	if fulcioURL == "" {
+		fulcioURL = DefaultFulcioURL
+	}
+
+	if err := s.requestCertificate(ctx, fulcioURL, token); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *signer) sign(payload []byte) ([]byte, error) {
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		return s.key.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	sum := sha256.Sum256(payload)
+	return s.key.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+// loadSigningKey reads a PEM private key. Both plain PKCS#8/SEC1/PKCS#1 keys and
+// cosign's encrypted key format (scrypt + nacl/secretbox) are supported.
+func loadSigningKey(path, password string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+
+	der := block.Bytes
+	if strings.HasPrefix(block.Type, "ENCRYPTED") && strings.HasSuffix(block.Type, "PRIVATE KEY") && block.Type != "ENCRYPTED PRIVATE KEY" {
+		der, err = decryptCosignKey(block.Bytes, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", path, err)
+		}
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+}
+
+func decryptCosignKey(data []byte, password string) ([]byte, error) {
+	var encrypted struct {
+		KDF struct {
+			Name   string `json:"name"`
+			Params struct {
+				N int `json:"N"`
+				R int `json:"r"`
+				P int `json:"p"`
+			} `json:"params"`
+			Salt []byte `json:"salt"`
+		} `json:"kdf"`
+		Cipher struct {
+			Name  string `json:"name"`
+			Nonce []byte `json:"nonce"`
+		} `json:"cipher"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, err
+	}
+	if encrypted.KDF.Name != "scrypt" || encrypted.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", encrypted.KDF.Name, encrypted.Cipher.Name)
+	}
+	if len(encrypted.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid nonce length %d", len(encrypted.Cipher.Nonce))
+	}
+
+	derived, err := scrypt.Key([]byte(password), encrypted.KDF.Salt, encrypted.KDF.Params.N, encrypted.KDF.Params.R, encrypted.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], derived)
+	copy(nonce[:], encrypted.Cipher.Nonce)
+
+	plain, ok := secretbox.Open(nil, encrypted.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("incorrect password")
+	}
+	return plain, nil
+}
+
+// ambientIdentityToken discovers an OIDC token from the environment: an explicit
+// SIGSTORE_ID_TOKEN, or the GitHub Actions ID token endpoint.
+func ambientIdentityToken(ctx context.Context, httpClient *http.Client) (string, error) {
+	if token := strings.TrimSpace(os.Getenv("SIGSTORE_ID_TOKEN")); token != "" {
+		return token, nil
+	}
+
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("keyless signing requires an ambient OIDC token (SIGSTORE_ID_TOKEN or GitHub Actions id-token permission)")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL+"&audience=sigstore", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "bearer "+requestToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub Actions ID token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub Actions ID token request failed with status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub Actions ID token: %w", err)
+	}
+	if payload.Value == "" {
+		return "", fmt.Errorf("GitHub Actions returned an empty ID token")
+	}
+	return payload.Value, nil
+}
+
+// requestCertificate exchanges the OIDC token for a short-lived signing certificate.
+func (s *signer) requestCertificate(ctx context.Context, fulcioURL, token string) error {
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return err
+	}
+
+	proof, err := s.sign([]byte(subject))
+	if err != nil {
+		return fmt.Errorf("failed to create proof of possession: %w", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	request := map[string]interface{}{
+		"credentials": map[string]string{"oidcIdentityToken": token},
+		"publicKeyRequest": map[string]interface{}{
+			"publicKey": map[string]string{
+				"algorithm": "ECDSA",
+				"content":   string(publicPEM),
+			},
+			"proofOfPossession": base64.StdEncoding.EncodeToString(proof),
+		},
+	}
+
+	var response struct {
+		Embedded *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateEmbeddedSct"`
+		Detached *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateDetachedSct"`
+	}
+	if err := s.postJSON(ctx, strings.TrimSuffix(fulcioURL, "/")+"/api/v2/signingCert", request, &response); err != nil {
+		return fmt.Errorf("failed to obtain signing certificate: %w", err)
+	}
+
+	var certs []string
+	switch {
+	case response.Embedded != nil:
+		certs = response.Embedded.Chain.Certificates
+	case response.Detached != nil:
+		certs = response.Detached.Chain.Certificates
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("certificate authority returned no certificates")
+	}
+
+	s.certPEM = []byte(certs[0])
+	s.chainPEM = []byte(strings.Join(certs[1:], ""))
+	return nil
+}
+
+// uploadTransparencyLog records the signature in Rekor and returns the cosign bundle annotation.
+func (s *signer) uploadTransparencyLog(ctx context.Context, payload, signature []byte) ([]byte, error) {
+	sum := sha256.Sum256(payload)
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString(signature),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(s.certPEM)},
+			},
+			"data": map[string]interface{}{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])},
+			},
+		},
+	}
+
+	var response map[string]struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+		Verification   struct {
+			SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}
+	if err := s.postJSON(ctx, strings.TrimSuffix(s.rekorURL, "/")+"/api/v1/log/entries", entry, &response); err != nil {
+		return nil, fmt.Errorf("failed to upload transparency log entry: %w", err)
+	}
+
+	for _, logEntry := range response {
+		bundle := map[string]interface{}{
+			"SignedEntryTimestamp": logEntry.Verification.SignedEntryTimestamp,
+			"Payload": map[string]interface{}{
+				"body":           logEntry.Body,
+				"integratedTime": logEntry.IntegratedTime,
+				"logIndex":       logEntry.LogIndex,
+				"logID":          logEntry.LogID,
+			},
+		}
+		return json.Marshal(bundle)
+	}
+	return nil, fmt.Errorf("transparency log returned no entries")
+}
+
+func (s *signer) postJSON(ctx context.Context, target string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s answered %d: %s", target, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// tokenSubject returns the identity Fulcio expects to be signed as proof of possession.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("identity token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode identity token: %w", err)
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("failed to parse identity token claims: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("identity token has no subject")
+	}
+	return claims.Subject, nil
+}
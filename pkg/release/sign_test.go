@@ -0,0 +1,108 @@
+package release
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+func writeEncryptedCosignKey(t *testing.T, key *ecdsa.PrivateKey, password string) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	salt := make([]byte, 32)
+	_, err = rand.Read(salt)
+	require.NoError(t, err)
+	var nonce [24]byte
+	_, err = rand.Read(nonce[:])
+	require.NoError(t, err)
+
+	derived, err := scrypt.Key([]byte(password), salt, 1<<10, 8, 1, 32)
+	require.NoError(t, err)
+	var secret [32]byte
+	copy(secret[:], derived)
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"kdf": map[string]interface{}{
+			"name":   "scrypt",
+			"params": map[string]int{"N": 1 << 10, "r": 8, "p": 1},
+			"salt":   salt,
+		},
+		"cipher":     map[string]interface{}{"name": "nacl/secretbox", "nonce": nonce[:]},
+		"ciphertext": secretbox.Seal(nil, der, &nonce, &secret),
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: payload}), 0o600))
+	return path
+}
+
+func TestLoadSigningKeyEncrypted(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	path := writeEncryptedCosignKey(t, key, "hunter2")
+
+	loaded, err := loadSigningKey(path, "hunter2")
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(loaded.Public()))
+
+	_, err = loadSigningKey(path, "wrong")
+	assert.Error(t, err)
+}
+
+func TestSignerProducesVerifiableSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ec.key")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+
+	loaded, err := loadSigningKey(path, "")
+	require.NoError(t, err)
+
+	dgst := digest.FromString("manifest")
+	payload, err := simpleSigningPayload("registry.test/team/app", dgst)
+	require.NoError(t, err)
+
+	s := &signer{key: loaded}
+	signature, err := s.sign(payload)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(payload)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, sum[:], signature))
+
+	var decoded struct {
+		Critical struct {
+			Identity map[string]string `json:"identity"`
+			Image    map[string]string `json:"image"`
+			Type     string            `json:"type"`
+		} `json:"critical"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+	assert.Equal(t, "registry.test/team/app", decoded.Critical.Identity["docker-reference"])
+	assert.Equal(t, dgst.String(), decoded.Critical.Image["docker-manifest-digest"])
+	assert.Equal(t, "cosign container image signature", decoded.Critical.Type)
+}
+
+func TestSignatureTag(t *testing.T) {
+	dgst := digest.Digest("sha256:0123abcd")
+	assert.Equal(t, "sha256-0123abcd.sig", SignatureTag(dgst))
+}
@@ -0,0 +1,312 @@
+package release
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delivery-station/porter/internal/tarxattr"
+)
+
+const (
+	// stagingPrefix starts the name of every staged archive. The pid of the process
+	// that staged it follows, so a sweep can tell abandoned archives from live ones.
+	stagingPrefix = "ds-porter-archive-"
+
+	// staleStagingAge is the age past which a sweep removes a staged archive even
+	// when its process looks alive, e.g. because the pid was reused.
+	staleStagingAge = 24 * time.Hour
+)
+
+// StagingOptions configures a Staging workspace.
+type StagingOptions struct {
+	// MaxBytes bounds the total size of the archives staged at once; zero leaves
+	// it unbounded.
+	MaxBytes int64
+	// Stream archives directories straight into their upload instead of staging
+	// them on disk first. The directory is read twice: once for the digest and
+	// once for the upload.
+	Stream bool
+}
+
+// Staging is the workspace directories are archived into before they are pushed.
+// Archives left behind by processes that died before cleaning up are removed when
+// the workspace is opened.
+type Staging struct {
+	dir  string
+	opts StagingOptions
+
+	mu    sync.Mutex
+	bytes int64
+}
+
+// OpenStaging creates the staging workspace dir and sweeps it.
+func OpenStaging(dir string, opts StagingOptions) (*Staging, error) {
+	if opts.MaxBytes < 0 {
+		return nil, fmt.Errorf("staging size limit must not be negative")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	s := &Staging{dir: dir, opts: opts}
+	if _, err := s.Sweep(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Dir returns the directory archives are staged in.
+func (s *Staging) Dir() string {
+	if s == nil {
+		return os.TempDir()
+	}
+	return s.dir
+}
+
+// Streaming reports whether directories are archived straight into their upload.
+func (s *Staging) Streaming() bool {
+	return s != nil && s.opts.Stream
+}
+
+// Usage returns the bytes currently held by staged archives.
+func (s *Staging) Usage() int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// Sweep removes the archives of processes that are no longer running, and any
+// archive older than a day, returning how many were removed.
+func (s *Staging) Sweep() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), stagingPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		pid, ok := stagingPID(entry.Name())
+		abandoned := ok && pid != os.Getpid() && !processAlive(pid)
+		if !abandoned && time.Since(info.ModTime()) < staleStagingAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove staged archive %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// stagingPID returns the pid recorded in the name of a staged archive.
+func stagingPID(name string) (int, bool) {
+	rest := strings.TrimPrefix(name, stagingPrefix)
+	end := strings.IndexByte(rest, '-')
+	if end <= 0 {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(rest[:end])
+	return pid, err == nil && pid > 0
+}
+
+// reserve accounts n more staged bytes, refusing them past the size limit.
+func (s *Staging) reserve(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opts.MaxBytes > 0 && s.bytes+n > s.opts.MaxBytes {
+		return fmt.Errorf("staged archives would exceed the staging limit of %d bytes", s.opts.MaxBytes)
+	}
+	s.bytes += n
+	return nil
+}
+
+func (s *Staging) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes -= n
+}
+
+// stagingWriter counts the bytes written to a staged archive against the limit.
+type stagingWriter struct {
+	staging *Staging
+	w       io.Writer
+	written int64
+}
+
+func (w *stagingWriter) Write(p []byte) (int, error) {
+	if err := w.staging.reserve(int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(p)
+	w.written += int64(len(p))
+	return n, err
+}
+
+// ArchiveDirectory writes dir as a compressed tar archive into the workspace and
+// returns its path with a cleanup func that removes it. A nil Staging archives
+// into the system temp directory.
+func (s *Staging) ArchiveDirectory(dir, compression string) (string, func(), error) {
+	if s == nil {
+		s = &Staging{dir: os.TempDir()}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to stat directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", nil, fmt.Errorf("path %s is not a directory", dir)
+	}
+
+	archiveFile, err := os.CreateTemp(s.dir, stagingPrefix+strconv.Itoa(os.Getpid())+"-*.tar")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+	counter := &stagingWriter{staging: s, w: archiveFile}
+	archiveErr := writeArchive(counter, dir, compression)
+	if closeErr := archiveFile.Close(); archiveErr == nil {
+		archiveErr = closeErr
+	}
+	if archiveErr != nil {
+		_ = os.Remove(archiveFile.Name())
+		s.release(counter.written)
+		return "", nil, fmt.Errorf("failed to archive directory %s: %w", dir, archiveErr)
+	}
+
+	archivePath := archiveFile.Name()
+	var once sync.Once
+	return archivePath, func() {
+		once.Do(func() {
+			_ = os.Remove(archivePath)
+			s.release(counter.written)
+		})
+	}, nil
+}
+
+// archiveDirectory archives dir into the system temp directory.
+func archiveDirectory(dir, compression string) (string, func(), error) {
+	return (*Staging)(nil).ArchiveDirectory(dir, compression)
+}
+
+// writeArchive writes the contents of dir to w as a tar archive compressed with
+// compression. Entries are written in lexical order, so an unchanged directory
+// always yields the same bytes.
+func writeArchive(w io.Writer, dir, compression string) error {
+	compressor, err := NewCompressor(w, compression)
+	if err != nil {
+		return err
+	}
+	tarWriter := tar.NewWriter(compressor)
+
+	walkErr := walkArchive(dir, func(path string, header *tar.Header) error {
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		file, openErr := os.Open(path)
+		if openErr != nil {
+			return openErr
+		}
+		if _, copyErr := io.Copy(tarWriter, file); copyErr != nil {
+			_ = file.Close()
+			return copyErr
+		}
+		return file.Close()
+	})
+
+	firstErr := walkErr
+	if closeErr := tarWriter.Close(); firstErr == nil {
+		firstErr = closeErr
+	}
+	if compressErr := compressor.Close(); firstErr == nil {
+		firstErr = compressErr
+	}
+	return firstErr
+}
+
+// walkArchive calls fn with the tar header of every entry below dir, in the order
+// writeArchive writes them. Headers use the PAX format, which keeps sub-second
+// modification times and extended attributes. Files linked from more than one
+// path are archived once; later paths become hard link entries to the first.
+func walkArchive(dir string, fn func(path string, header *tar.Header) error) error {
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory %s: %w", dir, err)
+	}
+
+	linked := make(map[fileID]string)
+	return filepath.WalkDir(dirAbs, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		relPath, relErr := filepath.Rel(dirAbs, path)
+		if relErr != nil {
+			return relErr
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			return infoErr
+		}
+
+		link := ""
+		if d.Type()&os.ModeSymlink != 0 {
+			target, linkErr := os.Readlink(path)
+			if linkErr != nil {
+				return linkErr
+			}
+			link = target
+		}
+		header, headerErr := tar.FileInfoHeader(info, link)
+		if headerErr != nil {
+			return headerErr
+		}
+		header.Name = filepath.ToSlash(relPath)
+		header.Format = tar.FormatPAX
+		// Access and change times are left out: reading the directory to archive it
+		// moves the former, which would change the digest between the two reads of a
+		// streamed push, and extraction cannot restore the latter.
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+
+		if id, ok := hardLinkID(info); ok {
+			if first, seen := linked[id]; seen {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = first
+				header.Size = 0
+			} else {
+				linked[id] = header.Name
+			}
+		}
+		if header.Typeflag != tar.TypeSymlink {
+			xattrs, xattrErr := readXattrs(path)
+			if xattrErr != nil {
+				return fmt.Errorf("failed to read extended attributes of %s: %w", path, xattrErr)
+			}
+			tarxattr.Set(header, xattrs)
+		}
+		return fn(path, header)
+	})
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package release
+
+// processAlive cannot probe other processes here, so archives are only swept once
+// they are stale.
+func processAlive(pid int) bool {
+	return true
+}
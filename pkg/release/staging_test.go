@@ -0,0 +1,163 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenStagingSweepsAbandonedArchives(t *testing.T) {
+	dir := t.TempDir()
+	abandoned := filepath.Join(dir, stagingPrefix+"999999999-1.tar")
+	stale := filepath.Join(dir, stagingPrefix+"1-2.tar")
+	live := filepath.Join(dir, stagingPrefix+"1-3.tar")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{abandoned, stale, live, other} {
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+	}
+	old := time.Now().Add(-2 * staleStagingAge)
+	require.NoError(t, os.Chtimes(stale, old, old))
+	require.NoError(t, os.Chtimes(other, old, old))
+
+	_, err := OpenStaging(dir, StagingOptions{})
+	require.NoError(t, err)
+	assert.NoFileExists(t, abandoned)
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, live, "pid 1 is running, so its recent archive stays")
+	assert.FileExists(t, other)
+}
+
+func TestStagingAccountsArchiveSize(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "tool"), []byte("tool binary"), 0o755))
+
+	staging, err := OpenStaging(t.TempDir(), StagingOptions{})
+	require.NoError(t, err)
+	path, cleanup, err := staging.ArchiveDirectory(src, CompressionGzip)
+	require.NoError(t, err)
+	assert.Equal(t, staging.Dir(), filepath.Dir(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), staging.Usage())
+	cleanup()
+	cleanup()
+	assert.NoFileExists(t, path)
+	assert.Zero(t, staging.Usage())
+
+	limited, err := OpenStaging(t.TempDir(), StagingOptions{MaxBytes: 16})
+	require.NoError(t, err)
+	_, _, err = limited.ArchiveDirectory(src, CompressionGzip)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "staging limit")
+	assert.Zero(t, limited.Usage())
+	entries, err := os.ReadDir(limited.Dir())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestAddDirectoryArchiveStreamsStagedBytes(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("tool binary"), 0o755))
+
+	staging, err := OpenStaging(t.TempDir(), StagingOptions{})
+	require.NoError(t, err)
+	path, cleanup, err := staging.ArchiveDirectory(src, CompressionZstd)
+	require.NoError(t, err)
+	defer cleanup()
+	staged, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	store := NewFileStore()
+	desc, err := store.AddDirectoryArchive(src, MediaTypeArtifactArchiveZstd, CompressionZstd, nil)
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromBytes(staged), desc.Digest)
+	assert.Equal(t, int64(len(staged)), desc.Size)
+
+	reader, err := store.Fetch(context.Background(), desc)
+	require.NoError(t, err)
+	streamed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, staged, streamed)
+}
+
+func TestArchiveDigestReusesUnchangedDirectories(t *testing.T) {
+	src := t.TempDir()
+	tool := filepath.Join(src, "tool")
+	require.NoError(t, os.WriteFile(tool, []byte("tool v1"), 0o755))
+	staging, err := OpenStaging(t.TempDir(), StagingOptions{Stream: true})
+	require.NoError(t, err)
+
+	first, size, err := staging.ArchiveDigest(src, CompressionGzip)
+	require.NoError(t, err)
+	assert.NotZero(t, size)
+
+	// Rewrite the file in place without changing its size or modification time:
+	// the fingerprint still matches, so the cached digest is returned unread.
+	info, err := os.Stat(tool)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(tool, []byte("tool v2"), 0o755))
+	require.NoError(t, os.Chtimes(tool, info.ModTime(), info.ModTime()))
+	cached, _, err := staging.ArchiveDigest(src, CompressionGzip)
+	require.NoError(t, err)
+	assert.Equal(t, first, cached)
+
+	// Streaming catches the stale digest and forgets it.
+	store := NewFileStore()
+	desc, err := store.AddDirectoryArchive(src, MediaTypeArtifactArchive, CompressionGzip, staging)
+	require.NoError(t, err)
+	reader, err := store.Fetch(context.Background(), desc)
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "changed")
+	_ = reader.Close()
+
+	fresh, _, err := staging.ArchiveDigest(src, CompressionGzip)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, fresh)
+}
+
+func TestWriteArchiveStoresHardLinksOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are archived as copies on Windows")
+	}
+	src := t.TempDir()
+	content := bytes.Repeat([]byte("tool binary "), 1024)
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a"), content, 0o755))
+	require.NoError(t, os.Link(filepath.Join(src, "a"), filepath.Join(src, "b")))
+
+	var archive bytes.Buffer
+	require.NoError(t, writeArchive(&archive, src, CompressionGzip))
+	decompressed, err := NewDecompressor(&archive, CompressionGzip)
+	require.NoError(t, err)
+	defer decompressed.Close()
+	reader := tar.NewReader(decompressed)
+	var headers []*tar.Header
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		headers = append(headers, header)
+	}
+	require.Len(t, headers, 2)
+	assert.Equal(t, byte(tar.TypeReg), headers[0].Typeflag)
+	assert.Equal(t, int64(len(content)), headers[0].Size)
+	assert.Equal(t, byte(tar.TypeLink), headers[1].Typeflag)
+	assert.Equal(t, "a", headers[1].Linkname)
+	assert.Zero(t, headers[1].Size)
+	assert.True(t, headers[1].AccessTime.IsZero())
+}
@@ -0,0 +1,55 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+
+	"github.com/delivery-station/porter/internal/spans"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// throttleAttempts bounds how often a platform is pushed while the registry keeps
+// throttling its uploads.
+const throttleAttempts = 5
+
+// isThrottled reports whether err is a registry answering 429 Too Many Requests
+// or 503 Service Unavailable.
+func isThrottled(err error) bool {
+	var errResp *errcode.ErrorResponse
+	if !errors.As(err, &errResp) {
+		return false
+	}
+	return errResp.StatusCode == http.StatusTooManyRequests || errResp.StatusCode == http.StatusServiceUnavailable
+}
+
+// pushPlatform pushes entry, pushing it again when the registry throttles an
+// upload the HTTP client could not retry itself, such as a streamed blob. The
+// blobs uploaded before are found in the registry and skipped, so a throttled
+// platform resumes where it stopped rather than failing the whole release.
+func (p *Pusher) pushPlatform(ctx context.Context, platform Platform, entry ManifestEntry, progress io.Writer) (_ ocispec.Descriptor, err error) {
+	ctx, span := p.tracer().Start(ctx, "release.push_platform", trace.WithAttributes(attribute.String("oci.platform", platform.FormatString())))
+	defer func() { spans.End(span, err) }()
+	desc, err := p.pushBinary(ctx, platform, entry, progress)
+	for attempt := 1; err != nil && isThrottled(err) && attempt < throttleAttempts; attempt++ {
+		delay := time.Duration(attempt) * time.Second
+		if err := writeProgressLine(progress, "  registry is throttling uploads, retrying %s in %s", platform.FormatString(), delay); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ocispec.Descriptor{}, ctx.Err()
+		case <-timer.C:
+		}
+		desc, err = p.pushBinary(ctx, platform, entry, progress)
+	}
+	return desc, err
+}
@@ -0,0 +1,16 @@
+package release
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of push spans from the configured tracer provider, or
+// from the global one, a no-op unless the process installs a provider.
+func (p *Pusher) tracer() trace.Tracer {
+	provider := p.config.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer("github.com/delivery-station/porter/pkg/release")
+}
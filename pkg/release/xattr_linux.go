@@ -0,0 +1,48 @@
+//go:build linux
+
+package release
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// readXattrs returns the extended attributes of path; a file system without them
+// has none.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, ignoreXattrErr(err)
+	}
+	names := make([]byte, size)
+	if size, err = syscall.Listxattr(path, names); err != nil {
+		return nil, ignoreXattrErr(err)
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		valueSize, err := syscall.Getxattr(path, string(name), nil)
+		if err != nil {
+			return nil, ignoreXattrErr(err)
+		}
+		value := make([]byte, valueSize)
+		if valueSize, err = syscall.Getxattr(path, string(name), value); err != nil {
+			return nil, ignoreXattrErr(err)
+		}
+		xattrs[string(name)] = string(value[:valueSize])
+	}
+	return xattrs, nil
+}
+
+// ignoreXattrErr drops the errors of file systems without extended attributes and
+// of attributes removed while they were read.
+func ignoreXattrErr(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.ENODATA) {
+		return nil
+	}
+	return err
+}
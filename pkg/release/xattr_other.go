@@ -0,0 +1,8 @@
+//go:build !linux
+
+package release
+
+// readXattrs reads extended attributes on Linux only; elsewhere files have none.
+func readXattrs(string) (map[string]string, error) {
+	return nil, nil
+}